@@ -0,0 +1,44 @@
+// Command calibrate benchmarks each SLA tier's solver on generated instances
+// of several sizes and saves the fitted solve-time model the server predicts
+// runtimes with (SOLVE_MODEL). Run it on hardware like production's; the
+// model built into the server, internal/predict/model.json, is its output
+// on a developer machine.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"milesconnect-optimization/internal/api"
+	"milesconnect-optimization/internal/predict"
+	_ "milesconnect-optimization/internal/solver/genetic" // Registers the GA solvers
+	"strconv"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", "solve-model.json", "model file to write")
+	sizes := flag.String("sizes", "10,25,50,100", "comma-separated instance sizes, in stops")
+	flag.Parse()
+
+	var ns []int
+	for _, f := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			log.Fatalf("bad size %q", f)
+		}
+		ns = append(ns, n)
+	}
+
+	m, err := predict.Calibrate(context.Background(), api.CalibrationTargets(), ns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range m.Entries {
+		log.Printf("%-60s %.3gs × stops^%.2f (%d runs)", predict.Key(e.Solver, e.Params), e.A, e.B, e.Samples)
+	}
+	if err := m.Save(*out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Saved %d entries to %s", len(m.Entries), *out)
+}
@@ -0,0 +1,54 @@
+// Command drivertoken issues the bearer token a driver app uses for the
+// /driver endpoints. It signs with DRIVER_TOKEN_SECRET, the same secret the
+// server is started with, opening it with SECRETS_KEYS when sealed.
+//
+// With -tenant as well, the driver token is for that tenant's vehicle; with
+// -tenant alone it issues a planner token instead, for the tenant and
+// -role, signed with PLANNER_TOKEN_SECRET.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/secrets"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	vehicle := flag.String("vehicle", "", "vehicle ID the token is for (required for driver tokens)")
+	tenant := flag.String("tenant", "", "tenant the token is for; without -vehicle, issues a planner token")
+	role := flag.String("role", auth.RolePlanner, "planner token role: viewer, planner or admin")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	flag.Parse()
+
+	subject, env := auth.DriverSubject(*tenant, *vehicle), "DRIVER_TOKEN_SECRET"
+	if *tenant != "" && *vehicle == "" {
+		p, err := auth.NewPrincipal(*tenant, *role)
+		if err != nil {
+			log.Fatal(err)
+		}
+		subject, env = p.Subject(), "PLANNER_TOKEN_SECRET"
+	} else if *vehicle == "" {
+		log.Fatal("-vehicle or -tenant is required")
+	} else if strings.Contains(*tenant, ":") {
+		log.Fatal("-tenant must be free of colons")
+	}
+	secret := os.Getenv(env)
+	if secret == "" {
+		log.Fatal(env + " is not set")
+	}
+	if secrets.Sealed(secret) {
+		keyring, err := secrets.ParseKeyring(os.Getenv("SECRETS_KEYS"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if secret, err = keyring.Open(secret); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Println(auth.Sign([]byte(secret), subject, time.Now().Add(*ttl)))
+}
@@ -0,0 +1,95 @@
+// Command loadtest fires generated instances at a running optimization
+// service and reports latency percentiles.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"milesconnect-optimization/internal/generator"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8081", "service base URL")
+	endpoint := flag.String("endpoint", "/optimize", "endpoint to hit: /optimize or /optimize-load")
+	requests := flag.Int("n", 100, "total requests")
+	concurrency := flag.Int("c", 4, "concurrent workers")
+	size := flag.Int("size", 200, "waypoints or shipments per instance")
+	dist := flag.String("dist", "random", "point distribution: random, clustered, grid")
+	flag.Parse()
+
+	// Pre-build payloads so generation cost doesn't skew the timings
+	payloads := make([][]byte, *requests)
+	for i := range payloads {
+		cfg := generator.Config{Size: *size, Distribution: generator.Distribution(*dist), Seed: int64(i)}
+		var v any
+		if *endpoint == "/optimize-load" {
+			v = generator.LoadRequest(cfg)
+		} else {
+			req, err := generator.RouteRequest(cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			v = req
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payloads[i] = b
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		failures  int
+		wg        sync.WaitGroup
+		jobs      = make(chan []byte)
+	)
+
+	start := time.Now()
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for body := range jobs {
+				t := time.Now()
+				resp, err := http.Post(*baseURL+*endpoint, "application/json", bytes.NewReader(body))
+				elapsed := time.Since(t)
+
+				mu.Lock()
+				if err != nil || resp.StatusCode != http.StatusOK {
+					failures++
+				} else {
+					latencies = append(latencies, elapsed)
+				}
+				mu.Unlock()
+				if err == nil {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	for _, p := range payloads {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	total := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pct := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	log.Printf("%d requests in %s (%.1f req/s), %d failed", *requests, total, float64(*requests)/total.Seconds(), failures)
+	log.Printf("p50=%s p90=%s p99=%s max=%s", pct(0.5), pct(0.9), pct(0.99), pct(1))
+}
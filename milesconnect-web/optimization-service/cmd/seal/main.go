@@ -0,0 +1,52 @@
+// Command seal encrypts a setting such as OSRM_URL or SHARE_TOKEN_SECRET
+// for the server's environment, with the first key of SECRETS_KEYS. It
+// reads the value from stdin so it stays out of shell history, and prints
+// the sealed value.
+//
+//	seal -new-key                  print a fresh key for SECRETS_KEYS
+//	seal < secret.txt              seal a plaintext value
+//	seal -reseal < sealed.txt      move a sealed value onto the first key
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/secrets"
+	"os"
+	"strings"
+)
+
+func main() {
+	newKey := flag.Bool("new-key", false, "print a fresh keyring key and exit")
+	reseal := flag.Bool("reseal", false, "reseal a sealed value under the current key, after a rotation")
+	flag.Parse()
+
+	if *newKey {
+		fmt.Println(secrets.NewKey())
+		return
+	}
+	spec := os.Getenv("SECRETS_KEYS")
+	if spec == "" {
+		log.Fatal("SECRETS_KEYS is not set")
+	}
+	keyring, err := secrets.ParseKeyring(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	value := strings.TrimRight(in, "\r\n")
+	if value == "" {
+		log.Fatalf("no value on stdin: %v", err)
+	}
+	if *reseal != secrets.Sealed(value) {
+		log.Fatal("-reseal takes a sealed value; without it, a plaintext one")
+	}
+	sealed, err := keyring.Reseal(value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(sealed)
+}
@@ -0,0 +1,72 @@
+// Command tune runs an offline random search over solver hyperparameters
+// and saves the best parameter set as a named profile.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"milesconnect-optimization/internal/solver"
+	_ "milesconnect-optimization/internal/solver/genetic" // Registers the GA solver
+	"milesconnect-optimization/internal/tuning"
+	"time"
+)
+
+func main() {
+	solverName := flag.String("solver", "genetic", "solver to tune")
+	name := flag.String("name", "", "profile name to save (required)")
+	dir := flag.String("dir", "profiles", "profile directory")
+	trials := flag.Int("trials", 20, "parameter sets to evaluate")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "search seed")
+	timeWeight := flag.Float64("time-weight", 1, "gap percentage points one second of solve time is worth")
+	flag.Parse()
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+	if *trials < 1 {
+		log.Fatal("-trials must be at least 1")
+	}
+
+	spaces := map[string]tuning.Space{"genetic": tuning.GeneticSpace}
+	space, ok := spaces[*solverName]
+	if !ok {
+		log.Fatalf("no search space defined for solver %q", *solverName)
+	}
+	if _, ok := solver.Get(*solverName); !ok {
+		log.Fatalf("unknown solver %q", *solverName)
+	}
+
+	results, err := tuning.RandomSearch(context.Background(), tuning.Config{
+		Solver:     *solverName,
+		Space:      space,
+		Suite:      tuning.DefaultSuite(),
+		Trials:     *trials,
+		Seed:       *seed,
+		TimeWeight: *timeWeight,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i, t := range results {
+		log.Printf("#%d score=%.3f gap=%.2f%% mean=%.0fms params=%v", i+1, t.Score, t.GapPct, t.MeanMillis, t.Params)
+	}
+
+	if len(results) == 0 {
+		log.Fatal("no trials ran; nothing to save")
+	}
+	best := results[0]
+	err = tuning.SaveProfile(*dir, tuning.Profile{
+		Name:      *name,
+		Solver:    *solverName,
+		Params:    best.Params,
+		GapPct:    best.GapPct,
+		Trials:    len(results),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Saved profile %q to %s", *name, *dir)
+}
@@ -1,3 +1,10 @@
 module milesconnect-optimization
 
 go 1.25.0
+
+require golang.org/x/crypto v0.50.0
+
+require (
+	golang.org/x/net v0.52.0 // indirect
+	golang.org/x/text v0.36.0 // indirect
+)
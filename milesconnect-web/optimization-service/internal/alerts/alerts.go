@@ -0,0 +1,362 @@
+// Package alerts watches the service's own metrics for signs that
+// optimization is failing, saturated or getting worse, and pushes alerts to
+// Alertmanager, which pages on-call. The same rules render as Prometheus
+// alerting rules for deployments that would rather alert on scraped
+// metrics.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service is the service label on every alert
+const Service = "milesconnect-optimization"
+
+// Severities: page wakes on-call, ticket waits for working hours
+const (
+	Page   = "page"
+	Ticket = "ticket"
+)
+
+// Rule fires when a metric passes a threshold. A gauge is compared as it
+// stands; a counter by how much it rose over Window, or, with Per, by that
+// rise as a share of Per's.
+type Rule struct {
+	Name     string // alertname
+	Severity string
+	Summary  string
+
+	// Metric is the metric's name. One * in it matches part of a name, e.g.
+	// a provider's, and the rule fires for each match apart, labelled Label.
+	Metric string
+	Label  string
+	Gauge  bool
+
+	Per    string  // Counter the rise is a share of
+	MinPer float64 // Per's rise below which the share is too noisy to judge
+	Window time.Duration
+
+	Above float64
+	For   time.Duration // How long past the threshold before the alert fires
+}
+
+// Alert is an alert as Alertmanager's API takes it. Firing alerts end a
+// few evaluations ahead, so Alertmanager resolves them should the service
+// stop sending.
+type Alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// sample is the metrics as they stood at a time
+type sample struct {
+	at     time.Time
+	values map[string]float64
+}
+
+// state is one rule's, or one match's, progress toward firing
+type state struct {
+	since  time.Time // Past the threshold since; zero when not
+	firing *Alert
+}
+
+// Evaluator checks rules against samples of the metrics
+type Evaluator struct {
+	rules    []Rule
+	interval time.Duration
+	labels   map[string]string // Added to every alert, e.g. the instance
+
+	mu      sync.Mutex
+	samples []sample
+	states  map[string]*state // By rule name and match
+}
+
+// NewEvaluator checks rules every interval, labelling alerts with labels
+func NewEvaluator(rules []Rule, interval time.Duration, labels map[string]string) *Evaluator {
+	return &Evaluator{rules: rules, interval: interval, labels: labels, states: map[string]*state{}}
+}
+
+// Evaluate records values, the metrics at now, and returns the alerts to
+// send: every firing alert, renewed, and those resolved since the last
+// evaluation
+func (e *Evaluator) Evaluate(now time.Time, values map[string]float64) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.samples = append(e.samples, sample{now, values})
+	keep := now.Add(-e.longestWindow() - e.interval)
+	for len(e.samples) > 2 && e.samples[1].at.Before(keep) {
+		e.samples = e.samples[1:]
+	}
+
+	var out []Alert
+	seen := map[string]bool{}
+	for _, r := range e.rules {
+		for _, name := range matches(r.Metric, values) {
+			key := r.Name + "\x00" + name
+			seen[key] = true
+			v, ok := e.value(r, name, now)
+			st := e.states[key]
+			if st == nil {
+				st = &state{}
+				e.states[key] = st
+			}
+			if !ok || v <= r.Above {
+				st.since = time.Time{}
+				if st.firing != nil {
+					out = append(out, e.resolve(st, now))
+				}
+				continue
+			}
+			if st.since.IsZero() {
+				st.since = now
+			}
+			if now.Sub(st.since) < r.For {
+				continue
+			}
+			if st.firing == nil {
+				st.firing = e.alert(r, name, now)
+			}
+			st.firing.Annotations["value"] = strconv.FormatFloat(v, 'g', 4, 64)
+			st.firing.EndsAt = now.Add(4 * e.interval)
+			out = append(out, st.firing.clone())
+		}
+	}
+	// A metric that went away takes its alert with it
+	for key, st := range e.states {
+		if !seen[key] {
+			if st.firing != nil {
+				out = append(out, e.resolve(st, now))
+			}
+			delete(e.states, key)
+		}
+	}
+	return out
+}
+
+// Active returns the firing alerts, by rule and match
+func (e *Evaluator) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := []Alert{}
+	for _, key := range slices.Sorted(maps.Keys(e.states)) {
+		if st := e.states[key]; st.firing != nil {
+			list = append(list, st.firing.clone())
+		}
+	}
+	return list
+}
+
+func (e *Evaluator) resolve(st *state, now time.Time) Alert {
+	a := st.firing.clone()
+	a.EndsAt = now
+	st.firing = nil
+	return a
+}
+
+// clone copies a, so later evaluations leave the copy be
+func (a Alert) clone() Alert {
+	a.Labels, a.Annotations = maps.Clone(a.Labels), maps.Clone(a.Annotations)
+	return a
+}
+
+func (e *Evaluator) alert(r Rule, name string, now time.Time) *Alert {
+	labels := maps.Clone(e.labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["alertname"] = r.Name
+	labels["severity"] = r.Severity
+	labels["service"] = Service
+	annotations := map[string]string{"summary": r.Summary}
+	if m, ok := wildcard(r.Metric, name); ok {
+		labels[r.Label] = m
+	}
+	return &Alert{Labels: labels, Annotations: annotations, StartsAt: now}
+}
+
+// value is the rule's measure of the metric name at now, or false when
+// there is too little to judge by
+func (e *Evaluator) value(r Rule, name string, now time.Time) (float64, bool) {
+	cur := e.samples[len(e.samples)-1].values
+	if r.Gauge {
+		v, ok := cur[name]
+		return v, ok
+	}
+	// The rise since the last sample a window back, or since the first
+	// while the service has run for less than a window
+	then := e.samples[0].values
+	for _, s := range e.samples {
+		if s.at.After(now.Add(-r.Window)) {
+			break
+		}
+		then = s.values
+	}
+	rise := cur[name] - then[name]
+	if r.Per == "" {
+		return rise, true
+	}
+	per := cur[r.Per] - then[r.Per]
+	if per <= 0 || per < r.MinPer {
+		return 0, false
+	}
+	return rise / per, true
+}
+
+func (e *Evaluator) longestWindow() time.Duration {
+	var w time.Duration
+	for _, r := range e.rules {
+		w = max(w, r.Window)
+	}
+	return w
+}
+
+// matches returns the metrics in values that pattern names
+func matches(pattern string, values map[string]float64) []string {
+	if !strings.Contains(pattern, "*") {
+		return []string{pattern}
+	}
+	var names []string
+	for name := range values {
+		if _, ok := wildcard(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// wildcard returns what the * in pattern matches in name
+func wildcard(pattern, name string) (string, bool) {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok || len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// Run evaluates the rules against snapshot every interval until ctx ends,
+// pushing what each evaluation returns to am, if set
+func (e *Evaluator) Run(ctx context.Context, snapshot func() map[string]float64, am *Alertmanager) {
+	tick := time.NewTicker(e.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			alerts := e.Evaluate(now, snapshot())
+			if am != nil && len(alerts) > 0 {
+				am.push(ctx, alerts)
+			}
+		}
+	}
+}
+
+// Alertmanager posts alerts to an Alertmanager's v2 API, or to anything
+// that takes the same, e.g. Grafana OnCall's Alertmanager integration
+type Alertmanager struct {
+	url    string
+	client *http.Client
+	errors func(error)
+}
+
+// NewAlertmanager posts to the Alertmanager at base, e.g.
+// http://alertmanager:9093, reporting failed posts to errors
+func NewAlertmanager(base string, client *http.Client, errors func(error)) *Alertmanager {
+	return &Alertmanager{url: strings.TrimSuffix(base, "/") + "/api/v2/alerts", client: client, errors: errors}
+}
+
+func (am *Alertmanager) push(ctx context.Context, alerts []Alert) {
+	if err := am.Push(ctx, alerts); err != nil {
+		am.errors(err)
+	}
+}
+
+// Push posts alerts. Alertmanager deduplicates by labels, so alerts still
+// firing are simply pushed again.
+func (am *Alertmanager) Push(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, am.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alertmanager returned %s", resp.Status)
+	}
+	return nil
+}
+
+// PrometheusRules renders rules as a Prometheus rule file, one group named
+// for the service. A wildcard rule gets one rule per metric in names it
+// matches, since Prometheus cannot take a match apart into a label.
+func PrometheusRules(rules []Rule, names []string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "groups:\n  - name: %s\n    rules:\n", Service)
+	for _, r := range rules {
+		targets := []string{r.Metric}
+		if strings.Contains(r.Metric, "*") {
+			targets = nil
+			for _, name := range names {
+				if _, ok := wildcard(r.Metric, name); ok {
+					targets = append(targets, name)
+				}
+			}
+		}
+		for _, name := range targets {
+			expr := name
+			if !r.Gauge {
+				expr = "increase(" + name + "[" + promDuration(r.Window) + "])"
+			}
+			if r.Per != "" {
+				per := "increase(" + r.Per + "[" + promDuration(r.Window) + "])"
+				expr = "(" + expr + " / " + per + " and " + per + " >= " + promNumber(max(r.MinPer, 1)) + ")"
+			}
+			fmt.Fprintf(&b, "      - alert: %s\n        expr: %s > %s\n", r.Name, expr, promNumber(r.Above))
+			if r.For > 0 {
+				fmt.Fprintf(&b, "        for: %s\n", promDuration(r.For))
+			}
+			fmt.Fprintf(&b, "        labels:\n          severity: %s\n          service: %s\n", r.Severity, Service)
+			if m, ok := wildcard(r.Metric, name); ok {
+				fmt.Fprintf(&b, "          %s: %s\n", r.Label, strconv.Quote(m))
+			}
+			fmt.Fprintf(&b, "        annotations:\n          summary: %s\n", strconv.Quote(r.Summary))
+		}
+	}
+	return b.Bytes()
+}
+
+// promDuration formats d as Prometheus does, e.g. 5m or 90s
+func promDuration(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return strconv.Itoa(int(d/time.Second)) + "s"
+}
+
+func promNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
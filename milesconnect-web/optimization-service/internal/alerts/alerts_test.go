@@ -0,0 +1,101 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rules = []Rule{
+	{Name: "SolverErrorRateHigh", Severity: Page, Summary: "Solves are failing", Metric: "solver_errors_total", Per: "solver_solves_total", MinPer: 10, Window: 5 * time.Minute, Above: 0.05},
+	{Name: "SolverSaturated", Severity: Page, Summary: "Solver pool is full", Metric: "solver_load", Gauge: true, Above: 0.9, For: 2 * time.Minute},
+	{Name: "ProviderBreakerOpen", Severity: Ticket, Summary: "Provider breaker open", Metric: "provider_*_breaker_open", Label: "provider", Gauge: true},
+}
+
+func TestEvaluate(t *testing.T) {
+	e := NewEvaluator(rules, time.Minute, map[string]string{"instance": "pod-a"})
+	start := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	names := func(now time.Time, alerts []Alert) string {
+		var out []string
+		for _, a := range alerts {
+			n := a.Labels["alertname"]
+			if p := a.Labels["provider"]; p != "" {
+				n += "/" + p
+			}
+			if a.EndsAt.Equal(now) {
+				n += " resolved"
+			}
+			out = append(out, n)
+		}
+		return strings.Join(out, ",")
+	}
+
+	e.Evaluate(at(0), map[string]float64{"solver_solves_total": 0, "solver_errors_total": 0, "solver_load": 0.95, "provider_osrm_breaker_open": 0})
+	// 3 errors in 5 solves is too few solves to judge; the load has not
+	// been high for long enough
+	if got := names(at(1), e.Evaluate(at(1), map[string]float64{"solver_solves_total": 5, "solver_errors_total": 3, "solver_load": 0.95, "provider_osrm_breaker_open": 1})); got != "ProviderBreakerOpen/osrm" {
+		t.Errorf("minute 1: %s", got)
+	}
+	if got := names(at(2), e.Evaluate(at(2), map[string]float64{"solver_solves_total": 100, "solver_errors_total": 8, "solver_load": 0.95, "provider_osrm_breaker_open": 1})); got != "SolverErrorRateHigh,SolverSaturated,ProviderBreakerOpen/osrm" {
+		t.Errorf("minute 2: %s", got)
+	}
+	active := e.Active()
+	if len(active) != 3 || active[0].Labels["instance"] != "pod-a" || active[0].Labels["severity"] != Ticket || active[1].Annotations["value"] != "0.08" {
+		t.Errorf("active = %+v", active)
+	}
+
+	// After a window the early errors no longer count: 2 in 200 solves
+	got := names(at(8), e.Evaluate(at(8), map[string]float64{"solver_solves_total": 300, "solver_errors_total": 10, "solver_load": 0.5}))
+	if got != "SolverErrorRateHigh resolved,SolverSaturated resolved,ProviderBreakerOpen/osrm resolved" {
+		t.Errorf("minute 8: %s", got)
+	}
+	if len(e.Active()) != 0 {
+		t.Errorf("still active: %+v", e.Active())
+	}
+}
+
+func TestPush(t *testing.T) {
+	var got []Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	am := NewAlertmanager(srv.URL+"/", srv.Client(), func(err error) { t.Error(err) })
+	alert := Alert{Labels: map[string]string{"alertname": "SolverSaturated"}, Annotations: map[string]string{}, StartsAt: now, EndsAt: now.Add(time.Minute)}
+	if err := am.Push(context.Background(), []Alert{alert}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Labels["alertname"] != "SolverSaturated" || !got[0].EndsAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("pushed %+v", got)
+	}
+	if err := NewAlertmanager(srv.URL+"/nope", srv.Client(), nil).Push(context.Background(), []Alert{alert}); err == nil {
+		t.Error("a 404 went unreported")
+	}
+}
+
+func TestPrometheusRules(t *testing.T) {
+	out := string(PrometheusRules(rules, []string{"provider_osrm_breaker_open", "provider_google_breaker_open", "solver_load"}))
+	for _, want := range []string{
+		"groups:\n  - name: milesconnect-optimization\n    rules:\n",
+		"expr: (increase(solver_errors_total[5m]) / increase(solver_solves_total[5m]) and increase(solver_solves_total[5m]) >= 10) > 0.05\n",
+		"      - alert: SolverSaturated\n        expr: solver_load > 0.9\n        for: 2m\n        labels:\n          severity: page\n",
+		"expr: provider_google_breaker_open > 0\n",
+		`provider: "osrm"`,
+		`summary: "Provider breaker open"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rules lack %q:\n%s", want, out)
+		}
+	}
+}
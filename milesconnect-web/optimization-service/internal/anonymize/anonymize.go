@@ -0,0 +1,282 @@
+// Package anonymize rewrites problem instances for sharing outside the
+// tenant: IDs and names become pseudonyms and coordinates move a short way,
+// the same way every time under the same key, so an instance still shows
+// the problem a customer saw without saying who or where their customers
+// are.
+package anonymize
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultJitter is how far, in metres, coordinates move at most by default
+const DefaultJitter = 300
+
+// metresPerDegree is the length of a degree of latitude
+const metresPerDegree = 111320
+
+// named are fields that name people and places; their values, and every
+// string inside them when they are objects, become pseudonyms
+var named = map[string]bool{
+	"name": true, "customer": true, "consignee": true, "city": true, "cities": true, "pincode": true,
+	"address": true, "phone": true, "email": true, "contact": true, "note": true, "label": true,
+	"carrier": true, "eway_bill": true, "tenant": true, "lane": true, "template": true,
+}
+
+// dropped are fields that give locations or links away in forms jitter
+// cannot move; they are left out
+var dropped = map[string]bool{
+	"polyline": true, "geometry": true, "geohash": true, "coordinates": true,
+	"url": true, "calendar_url": true, "navigation_urls": true, "token": true,
+}
+
+// Named reports whether a field, by its name in any case, names people or
+// places
+func Named(field string) bool {
+	return named[strings.ToLower(field)]
+}
+
+// Dropped reports whether a field, by its name in any case, gives
+// locations or links away in a form jitter cannot move
+func Dropped(field string) bool {
+	return dropped[strings.ToLower(field)]
+}
+
+// Anonymizer rewrites instances under one key. It remembers what it
+// replaced, for Text, so it is meant for one export at a time and is not
+// safe for concurrent use.
+type Anonymizer struct {
+	key    []byte
+	jitter float64 // metres
+
+	// pseudonyms maps what it has replaced to the replacement, to find
+	// the same IDs in free text
+	pseudonyms map[string]string
+}
+
+// New returns an anonymizer moving coordinates up to jitter metres. The
+// same key gives the same pseudonyms and moves; without it neither can be
+// traced back.
+func New(key []byte, jitter float64) *Anonymizer {
+	return &Anonymizer{key: key, jitter: jitter, pseudonyms: map[string]string{}}
+}
+
+// JSON rewrites a JSON document
+func (a *Anonymizer) JSON(body []byte) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(a.Value(v))
+}
+
+// Value rewrites a decoded JSON value, numbers decoded as json.Number, in
+// place
+func (a *Anonymizer) Value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		a.point(v)
+		for k, field := range v {
+			key := strings.ToLower(k)
+			switch {
+			case dropped[key]:
+				delete(v, k)
+			case named[key]:
+				v[k] = a.replace(field, "name-")
+			case isID(key):
+				v[k] = a.replace(field, "id-")
+			default:
+				v[k] = a.Value(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = a.Value(v[i])
+		}
+	}
+	return v
+}
+
+// isID reports whether a field holds IDs: id, *_id or *_ids, and the
+// unassigned lists, which are lists of IDs
+func isID(key string) bool {
+	return key == "id" || key == "ids" || key == "unassigned" || strings.HasSuffix(key, "_id") || strings.HasSuffix(key, "_ids")
+}
+
+// replace replaces the strings in v, alone, in a list or anywhere in an
+// object, e.g. an e-way bill's number, with pseudonyms starting prefix.
+// Locations in it move and numbers are kept.
+func (a *Anonymizer) replace(v any, prefix string) any {
+	switch v := v.(type) {
+	case string:
+		return a.Pseudonym(v, prefix)
+	case []any:
+		for i, item := range v {
+			v[i] = a.replace(item, prefix)
+		}
+	case map[string]any:
+		a.point(v)
+		for k, field := range v {
+			if dropped[strings.ToLower(k)] {
+				delete(v, k)
+			} else if k != "lat" && k != "lng" {
+				v[k] = a.replace(field, prefix)
+			}
+		}
+	}
+	return v
+}
+
+// Query rewrites a URL query as JSON fields are rewritten: named and ID
+// parameters' values become pseudonyms and those giving locations or links
+// away are left out
+func (a *Anonymizer) Query(raw string) string {
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	for k, vs := range q {
+		key := strings.ToLower(k)
+		switch {
+		case dropped[key]:
+			q.Del(k)
+		case named[key] || isID(key):
+			prefix := "name-"
+			if !named[key] {
+				prefix = "id-"
+			}
+			for i, v := range vs {
+				vs[i] = a.Pseudonym(v, prefix)
+			}
+		}
+	}
+	return q.Encode()
+}
+
+// Pseudonym replaces s with prefix and a digest of s under the key; the
+// empty string stays empty
+func (a *Anonymizer) Pseudonym(s, prefix string) string {
+	if s == "" {
+		return s
+	}
+	if p, ok := a.pseudonyms[s]; ok {
+		return p
+	}
+	sum := a.mac("name", s)
+	p := prefix + hex.EncodeToString(sum[:5])
+	a.pseudonyms[s] = p
+	return p
+}
+
+// Text replaces what the anonymizer has already replaced wherever s
+// mentions it as a whole, e.g. the IDs and multi-word names such as "New
+// Delhi" in an error message about the instance it rewrote. Longer ones go
+// first, so "New Delhi Hub" is not replaced as "New Delhi" and " Hub".
+func (a *Anonymizer) Text(s string) string {
+	found := make([]string, 0, len(a.pseudonyms))
+	for orig := range a.pseudonyms {
+		if strings.Contains(s, orig) {
+			found = append(found, orig)
+		}
+	}
+	slices.SortFunc(found, func(x, y string) int { return len(y) - len(x) })
+	for _, orig := range found {
+		s = ReplaceWhole(s, orig, a.pseudonyms[orig])
+	}
+	return s
+}
+
+// ReplaceWhole replaces old in s with with where it stands on its own, not
+// inside a longer ID or word: between characters other than letters,
+// digits, _ and -
+func ReplaceWhole(s, old, with string) string {
+	if old == "" {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(old)
+		if wordAt(s[:i], true) || wordAt(s[end:], false) {
+			b.WriteString(s[:end])
+		} else {
+			b.WriteString(s[:i] + with)
+		}
+		s = s[end:]
+	}
+}
+
+// wordAt reports whether s ends, or starts, with a character IDs and words
+// are made of
+func wordAt(s string, last bool) bool {
+	if s == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	if last {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// point moves v's coordinates when it is a location, an object with
+// numeric lat and lng
+func (a *Anonymizer) point(v map[string]any) {
+	lat, ok1 := number(v["lat"])
+	lng, ok2 := number(v["lng"])
+	if !ok1 || !ok2 {
+		return
+	}
+	lat, lng = a.Move(lat, lng)
+	v["lat"], v["lng"] = json.Number(strconv.FormatFloat(lat, 'f', 6, 64)), json.Number(strconv.FormatFloat(lng, 'f', 6, 64))
+}
+
+// Move shifts a coordinate up to the jitter in a direction and distance
+// drawn from it under the key, so a place shared by several stops moves
+// with all of them
+func (a *Anonymizer) Move(lat, lng float64) (float64, float64) {
+	sum := a.mac("point", strconv.FormatFloat(lat, 'f', 6, 64)+","+strconv.FormatFloat(lng, 'f', 6, 64))
+	bearing := float64(binary.BigEndian.Uint32(sum[0:4])) / (1 << 32) * 2 * math.Pi
+	// The square root spreads points evenly over the disc, not bunched at
+	// its centre
+	dist := a.jitter * math.Sqrt(float64(binary.BigEndian.Uint32(sum[4:8]))/(1<<32))
+	lat2 := lat + dist*math.Cos(bearing)/metresPerDegree
+	lng2 := lng + dist*math.Sin(bearing)/(metresPerDegree*math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	return math.Max(-90, math.Min(90, lat2)), math.Mod(lng2+540, 360) - 180
+}
+
+func (a *Anonymizer) mac(kind, s string) []byte {
+	m := hmac.New(sha256.New, a.key)
+	m.Write([]byte(kind + "\x00" + s))
+	return m.Sum(nil)
+}
+
+// number reads a JSON number as decoded with or without UseNumber
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,120 @@
+package anonymize
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const instance = `{
+	"depot": {"lat": 18.52, "lng": 73.8567, "name": "Hadapsar DC"},
+	"shipments": [
+		{"id": "SHP-1001", "weight_kg": 120, "customer": "Asha Traders", "phone": "+91 98220 00000",
+		 "pickup": {"lat": 18.5204, "lng": 73.8567}, "drop": {"lat": 19.076, "lng": 72.8777, "city": "Mumbai"}},
+		{"id": "SHP-1002", "weight_kg": 80, "customer": "Asha Traders",
+		 "pickup": {"lat": 18.5204, "lng": 73.8567}, "drop": {"lat": 18.6298, "lng": 73.7997, "city": "Pune"}}
+	],
+	"vehicles": [{"id": "MH12AB1234", "capacity_kg": 1000}],
+	"unassigned": ["SHP-1002"],
+	"stop_ids": ["SHP-1001"],
+	"polyline": "_p~iF~ps|U_ulLnnqC",
+	"options": {"time_limit_ms": 2000}
+}`
+
+func TestAnonymize(t *testing.T) {
+	a := New([]byte("tenant key"), DefaultJitter)
+	out, err := a.JSON([]byte(instance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(out)
+	for _, leak := range []string{"SHP-", "Asha", "98220", "Mumbai", "Pune", "Hadapsar", "MH12", "_p~iF", "18.5204", "73.8567"} {
+		if strings.Contains(text, leak) {
+			t.Errorf("%q survived: %s", leak, text)
+		}
+	}
+
+	var v struct {
+		Depot     models.Location
+		Shipments []struct {
+			ID       string
+			WeightKg float64 `json:"weight_kg"`
+			Customer string
+			Pickup   models.Location
+			Drop     models.Location
+		}
+		Unassigned []string
+		StopIDs    []string `json:"stop_ids"`
+		Options    map[string]float64
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		t.Fatal(err)
+	}
+	s1, s2 := v.Shipments[0], v.Shipments[1]
+	// The same thing gets the same pseudonym wherever it appears, and the
+	// same place moves the same way
+	if s1.ID == s2.ID || v.StopIDs[0] != s1.ID || v.Unassigned[0] != s2.ID || !strings.HasPrefix(s1.ID, "id-") {
+		t.Errorf("IDs: %s %s, stop_ids %v, unassigned %v", s1.ID, s2.ID, v.StopIDs, v.Unassigned)
+	}
+	if s1.Customer != s2.Customer || !strings.HasPrefix(s1.Customer, "name-") || s1.Pickup != s2.Pickup {
+		t.Errorf("customers %q %q, pickups %v %v", s1.Customer, s2.Customer, s1.Pickup, s2.Pickup)
+	}
+	// Nothing moves further than the jitter, and what shapes the solve is
+	// left as it was
+	orig := models.Location{Lat: 19.076, Lng: 72.8777}
+	if d := problem.Haversine(orig, s1.Drop) * 1000; d == 0 || d > DefaultJitter+1 {
+		t.Errorf("drop moved %.0f m", d)
+	}
+	if s1.WeightKg != 120 || v.Options["time_limit_ms"] != 2000 {
+		t.Errorf("weights and options changed: %s", text)
+	}
+
+	// The same key gives the same export; another key a different one
+	again, _ := New([]byte("tenant key"), DefaultJitter).JSON([]byte(instance))
+	other, _ := New([]byte("another key"), DefaultJitter).JSON([]byte(instance))
+	if string(again) != text || string(other) == text {
+		t.Error("exports are not deterministic under the key")
+	}
+
+	if got := a.Text("shipment SHP-1002 does not fit vehicle MH12AB1234 (1000 kg)"); got != "shipment "+s2.ID+" does not fit vehicle "+a.Pseudonym("MH12AB1234", "id-")+" (1000 kg)" {
+		t.Errorf("Text = %q", got)
+	}
+}
+
+func TestAnonymizeDispatchRequest(t *testing.T) {
+	req := models.DispatchRequest{
+		Date:   "2026-10-15",
+		Routes: []models.FleetRoute{{VehicleID: "MH12AB1234", StopIDs: []string{"SHP-1001"}}},
+		Shipments: []models.ShipmentDocs{{StopID: "SHP-1001", ValueInr: 90000, EWayBill: &models.EWayBill{
+			Number: "331004829157", ValidUntil: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), DistanceKm: 148}}},
+		Consignees: []models.Consignee{{StopID: "SHP-1001", Name: "Asha Traders", City: "New Delhi"}},
+	}
+	body, _ := json.Marshal(map[string]any{"plan": req, "lane": "Pune-Mumbai", "template": "Monday Pune run"})
+	a := New([]byte("tenant key"), DefaultJitter)
+	out, err := a.JSON(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"331004829157", "Asha", "New Delhi", "SHP-", "MH12", "Pune"} {
+		if strings.Contains(string(out), leak) {
+			t.Errorf("%q survived: %s", leak, out)
+		}
+	}
+	if !strings.Contains(string(out), `"distance_km":148`) || !strings.Contains(string(out), `"value_inr":90000`) {
+		t.Errorf("numbers changed: %s", out)
+	}
+
+	// Names of several words are found in text as a whole, and only there
+	city := a.Pseudonym("New Delhi", "name-")
+	if got := a.Text("no road to New Delhi; New Delhiite"); got != "no road to "+city+"; New Delhiite" {
+		t.Errorf("Text = %q", got)
+	}
+	q, _ := url.ParseQuery(a.Query("stop_id=SHP-1001&customer=Asha+Traders&url=https://x.example&time_limit_ms=500"))
+	if q.Get("stop_id") != a.Pseudonym("SHP-1001", "id-") || q.Get("customer") != a.Pseudonym("Asha Traders", "name-") || q.Has("url") || q.Get("time_limit_ms") != "500" {
+		t.Errorf("Query = %v", q)
+	}
+}
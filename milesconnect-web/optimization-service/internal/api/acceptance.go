@@ -0,0 +1,136 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"net/http"
+)
+
+// RateCard prices spot orders: a base charge plus weight and distance from
+// the vehicle's start, no less than the minimum. Orders whose margin over
+// their marginal cost reaches AcceptMarginPct are accepted outright.
+type RateCard struct {
+	BaseInr         float64
+	PerKgInr        float64
+	PerKmInr        float64
+	MinimumInr      float64
+	AcceptMarginPct float64
+}
+
+// rateCard is the card spot orders are priced with until SetRateCard
+var rateCard = RateCard{BaseInr: 500, PerKgInr: 2, PerKmInr: 25, MinimumInr: 1000, AcceptMarginPct: 20}
+
+// DefaultRateCard returns the card in use, for adjusting before SetRateCard
+func DefaultRateCard() RateCard {
+	return rateCard
+}
+
+// SetRateCard replaces the spot order rate card; call before serving
+// requests
+func SetRateCard(c RateCard) {
+	rateCard = c
+}
+
+// Price returns the card's price for kg carried km
+func (c RateCard) Price(kg, km float64) float64 {
+	return math.Max(c.MinimumInr, c.BaseInr+kg*c.PerKgInr+km*c.PerKmInr)
+}
+
+// OrderAcceptanceHandler recommends whether to take a spot order onto a
+// dispatched plan, from its price against the cost of fitting it in
+func OrderAcceptanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.OrderAcceptanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := resolveLocation(&req.Shipment.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateMarginalCostRequest(req.MarginalCostRequest); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !finite(req.PriceInr) || req.PriceInr < 0 {
+		http.Error(w, "Price must not be negative", http.StatusBadRequest)
+		return
+	}
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+	b, ok := dispatched.Board(tenant, date)
+	if !ok {
+		http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, acceptOrder(b, req))
+}
+
+// acceptOrder weighs req's price against the cheapest place for it on b
+func acceptOrder(b dispatch.Board, req models.OrderAcceptanceRequest) models.OrderAcceptance {
+	mc := marginalCost(b, req.MarginalCostRequest)
+	round := func(x float64) float64 { return math.Round(x*100) / 100 }
+
+	// Distance is charged from where the vehicle that would carry it
+	// starts, or the plan's first vehicle when none can
+	var ins *models.Insertion
+	if len(mc.Options) > 0 {
+		ins = &mc.Options[0]
+	}
+	km := 0.0
+	for _, run := range b.Runs {
+		if ins == nil || run.VehicleID == ins.VehicleID {
+			km = problem.Haversine(run.Start, req.Shipment.Location)
+			break
+		}
+	}
+
+	out := models.OrderAcceptance{
+		RateCardInr: round(rateCard.Price(req.Shipment.DemandKg, km)),
+		Insertion:   ins,
+	}
+	out.PriceInr = cmp.Or(req.PriceInr, out.RateCardInr)
+	if ins != nil {
+		out.CostInr = ins.AddedCostInr
+	}
+	out.MarginInr = round(out.PriceInr - out.CostInr)
+	if out.PriceInr > 0 {
+		out.MarginPct = round(out.MarginInr / out.PriceInr * 100)
+	}
+
+	switch {
+	case !mc.Feasible:
+		out.Recommendation, out.Reason = "reject", "No vehicle can take it"
+		if ins != nil {
+			out.Reason += ": " + ins.Reason
+		}
+	case out.MarginInr < 0:
+		out.Recommendation, out.Reason = "reject", "It costs more to carry than it pays"
+	case out.MarginPct < rateCard.AcceptMarginPct:
+		out.Recommendation = "needs_review"
+		out.Reason = fmt.Sprintf("Margin is below %.0f%%", rateCard.AcceptMarginPct)
+	case out.PriceInr < out.RateCardInr:
+		out.Recommendation, out.Reason = "needs_review", "Price is below the rate card"
+	default:
+		out.Recommendation = "accept"
+		out.Reason = fmt.Sprintf("Fits on %s with a %.0f%% margin", ins.VehicleID, out.MarginPct)
+	}
+	return out
+}
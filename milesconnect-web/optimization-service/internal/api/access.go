@@ -0,0 +1,68 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/auth"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// plannerSecret signs planner tokens. Without one every caller acts as an
+// admin of the one unnamed tenant, as single-tenant deployments always
+// have; with one, stored templates, standing orders, maintenance schedules,
+// planning sessions and scenarios are scoped to the tenant and role a token
+// names.
+var plannerSecret []byte
+
+// SetPlannerSecret sets the secret planner tokens are signed with
+func SetPlannerSecret(secret string) {
+	plannerSecret = []byte(secret)
+}
+
+// principal returns who the request's planner token speaks for, writing a
+// 401 when it has none or a bad one
+func principal(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	if len(plannerSecret) == 0 {
+		return auth.Principal{Role: auth.RoleAdmin}, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="planner"`)
+		http.Error(w, "Planner token required", http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	p, err := plannerPrincipal(token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="planner", error="invalid_token"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	return p, true
+}
+
+// plannerPrincipal verifies a planner token and reads who it speaks for
+func plannerPrincipal(token string) (auth.Principal, error) {
+	subject, err := auth.Verify(plannerSecret, token, time.Now())
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.ParsePrincipal(subject)
+}
+
+// recordTenant returns the tenant a record p saves belongs to: p's own, or
+// for an admin the one the record names
+func recordTenant(p auth.Principal, named string) string {
+	if p.Role == auth.RoleAdmin && named != "" {
+		return named
+	}
+	return p.Tenant
+}
+
+// writable writes a 403 unless p may change tenant's records
+func writable(w http.ResponseWriter, p auth.Principal, tenant string) bool {
+	if !p.Writes(tenant) {
+		http.Error(w, "Your role cannot change these records", http.StatusForbidden)
+		return false
+	}
+	return true
+}
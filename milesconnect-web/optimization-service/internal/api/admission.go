@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/queue"
+	"milesconnect-optimization/internal/solver"
+	"milesconnect-optimization/internal/usage"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// solverPool limits concurrent CPU-intensive solves; see ConfigureSolverPool
+var solverPool = queue.New(runtime.GOMAXPROCS(0), 32, 30*time.Second)
+
+var (
+	queueRejected = metrics.NewCounter("solver_queue_rejected_total", "Solves rejected because the queue was full or timed out")
+	solves        = metrics.NewCounter("solver_solves_total", "Solves admitted to run")
+	solveErrors   = metrics.NewCounter("solver_errors_total", "Solves that failed or ran out of time, the solver's fault rather than the request's")
+)
+
+func init() {
+	metrics.GaugeFunc("solver_queue_depth", "Solves waiting for a slot", func() float64 {
+		return float64(solverPool.Waiting())
+	})
+	metrics.GaugeFunc("solver_running", "Solves currently holding a slot", func() float64 {
+		return float64(solverPool.Running())
+	})
+}
+
+// ConfigureSolverPool replaces the pool; call before serving requests
+func ConfigureSolverPool(concurrency, depth int, timeout time.Duration) {
+	solverPool = queue.New(concurrency, depth, timeout)
+}
+
+// admit refuses a solve when the caller's tenant has used its quota or,
+// for a batch solve, when the pool is loaded past shedBatchAt, and
+// otherwise waits for a pool slot when s is CPU-intensive. Once a planner
+// secret is set the caller must present a valid planner token, so no solve
+// goes unmetered. On failure it writes the error response and returns
+// false; otherwise the caller must run the returned release on the same
+// goroutine, which meters the CPU time the solve took against the tenant.
+func admit(w http.ResponseWriter, r *http.Request, s solver.Solver) (func(), bool) {
+	who, ok := principal(w, r)
+	if !ok || !withinQuota(w, who.Tenant) || shedBatch(w, r) {
+		return nil, false
+	}
+	release := func() {}
+	if solver.IsCPUIntensive(s) {
+		var err error
+		release, err = solverPool.Acquire(r.Context())
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "Deadline exceeded waiting for a solver slot", http.StatusGatewayTimeout)
+			return nil, false
+		}
+		if err != nil {
+			queueRejected.Inc()
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return nil, false
+		}
+	}
+	solves.Inc()
+	// Solvers run on the caller's goroutine, so its thread's CPU time is
+	// the solve's, whatever else the process is doing
+	runtime.LockOSThread()
+	start := usage.ThreadCPU()
+	return func() {
+		used := usage.ThreadCPU() - start
+		runtime.UnlockOSThread()
+		release()
+		meter(r, who.Tenant, used)
+	}, true
+}
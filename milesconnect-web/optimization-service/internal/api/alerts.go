@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"milesconnect-optimization/internal/alerts"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// healthRules are what pages on-call: solves failing, the solver pool
+// full, distance providers down, and plans getting worse than the
+// reference solver's
+var healthRules = []alerts.Rule{
+	{
+		Name: "SolverErrorRateHigh", Severity: alerts.Page,
+		Summary: "More than 5% of solves are failing or running out of time",
+		Metric:  "solver_errors_total", Per: "solver_solves_total", MinPer: 20, Window: 5 * time.Minute,
+		Above: 0.05,
+	},
+	{
+		Name: "SolverSaturated", Severity: alerts.Page,
+		Summary: "The solver pool has been over 90% full for 5 minutes; requests are moved to the fast tier and batches shed",
+		Metric:  "solver_load", Gauge: true,
+		Above: 0.9, For: 5 * time.Minute,
+	},
+	{
+		Name: "SolverQueueRejecting", Severity: alerts.Page,
+		Summary: "Solves are being turned away because the solver queue is full",
+		Metric:  "solver_queue_rejected_total", Window: 5 * time.Minute,
+		Above: 10,
+	},
+	{
+		Name: "ProviderBreakerOpen", Severity: alerts.Ticket,
+		Summary: "A distance provider's circuit breaker has stayed open; solves use fallback distances",
+		Metric:  "provider_*_breaker_open", Label: "provider", Gauge: true,
+		For: 5 * time.Minute,
+	},
+	{
+		Name: "ProviderFailing", Severity: alerts.Ticket,
+		Summary: "A distance provider is failing calls",
+		Metric:  "provider_*_failures_total", Label: "provider", Window: 5 * time.Minute,
+		Above: 20,
+	},
+	{
+		Name: "PlanQualityRegressed", Severity: alerts.Page,
+		Summary: "More than 10% of shadowed solves are significantly worse than the reference solver's",
+		Metric:  "shadow_regressions_total", Per: "shadow_compared_total", MinPer: 10, Window: 30 * time.Minute,
+		Above: 0.1,
+	},
+	{
+		Name: "JobFailureRateHigh", Severity: alerts.Page,
+		Summary: "More than 20% of background jobs are failing",
+		Metric:  "jobs_failed_total", Per: "jobs_finished_total", MinPer: 5, Window: 15 * time.Minute,
+		Above: 0.2,
+	},
+}
+
+// alertInterval is how often the health rules are evaluated
+const alertInterval = 30 * time.Second
+
+// healthAlerts evaluates healthRules; see StartAlerts
+var healthAlerts = alerts.NewEvaluator(healthRules, alertInterval, nil)
+
+// StartAlerts evaluates the health rules in the background, labelling
+// alerts with instance and pushing them to am when it is set; call before
+// serving requests
+func StartAlerts(instance string, am *alerts.Alertmanager) {
+	healthAlerts = alerts.NewEvaluator(healthRules, alertInterval, map[string]string{"instance": instance})
+	go healthAlerts.Run(context.Background(), metrics.Snapshot, am)
+}
+
+// AlertsHandler lists the health alerts firing on this replica, as
+// Alertmanager's API takes them (admins)
+func AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if !alertsAdmin(w, r) {
+		return
+	}
+	writeResponse(w, r, healthAlerts.Active())
+}
+
+// AlertRulesHandler serves the health rules as a Prometheus rule file, for
+// deployments that alert on scraped metrics (admins)
+func AlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if !alertsAdmin(w, r) {
+		return
+	}
+	names := slices.Sorted(maps.Keys(metrics.Snapshot()))
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(alerts.PrometheusRules(healthRules, names))
+}
+
+// alertsAdmin checks a GET from an admin, writing the error otherwise
+func alertsAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return false
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins see alerts", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"milesconnect-optimization/internal/anonymize"
+	"net/http"
+	"strconv"
+)
+
+// maxJitter bounds how far ?jitter_m= may move coordinates; further and the
+// instance no longer shows the problem
+const maxJitter = 5000
+
+// anonymizeSecret keys every tenant's pseudonyms and moves. Until it is
+// set each run of the service draws its own, so exports line up with each
+// other only until a restart.
+var anonymizeSecret = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+// SetAnonymizeSecret sets the secret anonymized exports are keyed with
+func SetAnonymizeSecret(secret string) {
+	anonymizeSecret = []byte(secret)
+}
+
+// anonymizer returns an anonymizer keyed for tenant, so tenants' exports
+// cannot be matched up with each other, moving coordinates by r's
+// ?jitter_m= (default anonymize.DefaultJitter). On a bad jitter it writes
+// a 400 and returns false.
+func anonymizer(w http.ResponseWriter, r *http.Request, tenant string) (*anonymize.Anonymizer, bool) {
+	jitter := float64(anonymize.DefaultJitter)
+	if v := r.URL.Query().Get("jitter_m"); v != "" {
+		j, err := strconv.ParseFloat(v, 64)
+		if err != nil || !finite(j) || j < 0 || j > maxJitter {
+			http.Error(w, "jitter_m must be 0 to "+strconv.Itoa(maxJitter)+" metres", http.StatusBadRequest)
+			return nil, false
+		}
+		jitter = j
+	}
+	m := hmac.New(sha256.New, anonymizeSecret)
+	m.Write([]byte(tenant))
+	return anonymize.New(m.Sum(nil), jitter), true
+}
+
+// AnonymizeHandler rewrites a problem instance, any request body, for
+// sharing with support: IDs and names become pseudonyms and coordinates
+// move up to ?jitter_m= metres, the same way each time for the caller's
+// tenant (POST)
+func AnonymizeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	a, ok := anonymizer(w, r, who.Tenant)
+	if !ok {
+		return
+	}
+	limitBody(w, r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	out, err := a.JSON(body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="instance-anonymized.json"`)
+	w.Write(out)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxAuditScan bounds how many matching events one request sorts and
+// pages; continue past them with ?after=
+const maxAuditScan = 100000
+
+// auditLog records planning actions; OpenAuditLog backs it with a file
+var auditLog, _ = audit.Open("")
+
+var auditFailures = metrics.NewCounter("audit_append_failures_total", "Planning actions that could not be written to the audit log")
+
+// OpenAuditLog opens the audit log at path and appends to it from now on,
+// returning how many events it already holds
+func OpenAuditLog(path string) (int64, error) {
+	l, err := audit.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	if l.Torn > 0 {
+		log.Printf("audit: dropped %d bytes of a last event cut short in %s", l.Torn, path)
+	}
+	auditLog = l
+	return l.Len(), nil
+}
+
+// AuditHandler lists recorded events in the order they happened, filtered
+// by ?kind=, ?actor=, ?date=, ?shipment=, ?vehicle= and the ?since=/?until=
+// RFC 3339 range, and paged like every list. ?after= starts past a sequence
+// number; each request considers at most maxAuditScan events from there.
+// Callers see only the events of tenants they read.
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filter{
+		Shipment: q.Get("shipment"),
+		Vehicle:  q.Get("vehicle"),
+		Limit:    maxAuditScan,
+		Brief:    true,
+		Tenants:  who.Reads,
+	}
+	// A single kind or date also narrows the scan; writeList handles several
+	if len(q["kind"]) == 1 {
+		f.Kind = q.Get("kind")
+	}
+	if len(q["date"]) == 1 {
+		f.Date = q.Get("date")
+	}
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "after must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		f.After = n
+	}
+
+	events, err := auditLog.Query(f)
+	if err != nil {
+		log.Printf("audit: %v", err)
+		http.Error(w, "Failed to read the audit log", http.StatusInternalServerError)
+		return
+	}
+	writeList(w, r, events, auditList)
+}
+
+// auditList keys events by zero-padded sequence number, so the default
+// order is the order they happened. Listed events are read without their
+// detail, which is filled in for the page alone.
+var auditList = listSpec[audit.Event]{
+	key: func(ev audit.Event) string { return fmt.Sprintf("%019d", ev.Seq) },
+	fields: map[string]listField[audit.Event]{
+		"kind":  {value: func(ev audit.Event) string { return ev.Kind }},
+		"actor": {value: func(ev audit.Event) string { return ev.Actor }},
+		"date":  {value: func(ev audit.Event) string { return ev.Date }},
+	},
+	fill: func(page []audit.Event) ([]audit.Event, error) {
+		seqs := make([]int64, len(page))
+		for i, ev := range page {
+			seqs[i] = ev.Seq
+		}
+		return auditLog.Get(seqs)
+	},
+}
+
+// record appends a planning action to the audit log with data as its
+// detail; ev.Actor defaults to the caller and ev.Tenant to the caller's
+// tenant. A failed write is logged rather than failing the request, which
+// has already taken effect.
+func record(r *http.Request, ev audit.Event, data any) {
+	if ev.Actor == "" {
+		ev.Actor = actor(r)
+	}
+	if ev.Tenant == "" {
+		ev.Tenant = requestTenant(r)
+	}
+	if data != nil {
+		body, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("audit: encoding %s: %v", ev.Kind, err)
+		}
+		ev.Data = body
+	}
+	if _, err := auditLog.Append(ev); err != nil {
+		auditFailures.Inc()
+		log.Printf("audit: recording %s: %v", ev.Kind, err)
+	}
+}
+
+// actor names who made a request from the X-Actor header a trusted frontend
+// sets
+func actor(r *http.Request) string {
+	if v := r.Header.Get("X-Actor"); v != "" {
+		return v
+	}
+	return "anonymous"
+}
+
+// solveRecord is the detail of an optimization run: enough to see which
+// solver put each shipment where, and to rerun it
+type solveRecord struct {
+	Request  any `json:"request"`
+	Response any `json:"response"`
+}
+
+// fleetIDs lists the shipments and vehicles of a fleet plan, including the
+// shipments it left unassigned
+func fleetIDs(routes []models.FleetRoute, unassigned []string) (shipments, vehicles []string) {
+	for _, rt := range routes {
+		vehicles = append(vehicles, rt.VehicleID)
+		shipments = append(shipments, rt.StopIDs...)
+	}
+	return append(shipments, unassigned...), vehicles
+}
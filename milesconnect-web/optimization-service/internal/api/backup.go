@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/backup"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxBackupBytes bounds an imported bundle, well above a solve request
+// since it carries a whole dataset
+const maxBackupBytes = 256 << 20
+
+// BackupHandler exports the stored planning data as a bundle (GET) or
+// imports one (POST), replacing records with the same names. Bundles are
+// JSON, or NDJSON with Accept or Content-Type application/x-ndjson or
+// ?format=ndjson. Planners export and import their own tenant's records;
+// admins every tenant's, or one with ?tenant=, and the dispatched plans,
+// which belong to no tenant.
+func BackupHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	ndjson := r.URL.Query().Get("format") == "ndjson"
+	switch r.Method {
+	case http.MethodGet:
+		b := exportBundle(who, r.URL.Query().Get("tenant"))
+		record(r, audit.Event{Kind: "backup.exported"}, map[string]string{"tenant": b.Tenant})
+		if ndjson || strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			backup.WriteNDJSON(w, b)
+			return
+		}
+		writeResponse(w, r, b)
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxBackupBytes)
+		var b backup.Bundle
+		var err error
+		if ndjson || strings.HasPrefix(r.Header.Get("Content-Type"), ndjsonContentType) {
+			b, err = backup.ReadNDJSON(r.Body)
+		} else if err = json.NewDecoder(r.Body).Decode(&b); err == nil {
+			err = b.Check()
+		}
+		if err != nil {
+			http.Error(w, "Invalid bundle: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !importBundle(w, who, &b) {
+			return
+		}
+		counts := models.BackupImport{
+			Templates:      len(b.Templates),
+			StandingOrders: len(b.StandingOrders),
+			Maintenance:    len(b.Maintenance),
+			Sessions:       len(b.Sessions),
+			Boards:         len(b.Boards),
+		}
+		record(r, audit.Event{Kind: "backup.imported"}, counts)
+		writeResponse(w, r, counts)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// exportBundle collects the records who reads, narrowed to tenant if an
+// admin names one
+func exportBundle(who auth.Principal, tenant string) backup.Bundle {
+	if who.Role != auth.RoleAdmin {
+		tenant = who.Tenant
+	}
+	all := who.Role == auth.RoleAdmin && tenant == ""
+	include := func(owner string) bool { return all || owner == tenant }
+	b := backup.Bundle{
+		Version:        backup.Version,
+		ExportedAt:     time.Now().UTC(),
+		Tenant:         tenant,
+		Templates:      []templates.Template{},
+		StandingOrders: []templates.StandingOrder{},
+		Maintenance:    []templates.Maintenance{},
+		Sessions:       []models.PlanningSession{},
+		Boards:         []dispatch.Board{},
+	}
+
+	templatesMu.RLock()
+	for _, t := range routeTemplates {
+		if include(t.Tenant) {
+			b.Templates = append(b.Templates, t)
+		}
+	}
+	for _, o := range standingOrders {
+		if include(o.Tenant) {
+			b.StandingOrders = append(b.StandingOrders, o)
+		}
+	}
+	for _, m := range maintenance {
+		if include(m.Tenant) {
+			b.Maintenance = append(b.Maintenance, m)
+		}
+	}
+	templatesMu.RUnlock()
+
+	sessionsMu.Lock()
+	for _, s := range planningSessions {
+		if include(s.Tenant) {
+			b.Sessions = append(b.Sessions, session(s))
+		}
+	}
+	sessionsMu.Unlock()
+
+	if all {
+		b.Boards = dispatched.Boards("", "9999-12-31")
+	}
+	slices.SortFunc(b.Templates, func(x, y templates.Template) int { return strings.Compare(x.Name, y.Name) })
+	slices.SortFunc(b.StandingOrders, func(x, y templates.StandingOrder) int { return strings.Compare(x.ID, y.ID) })
+	slices.SortFunc(b.Maintenance, func(x, y templates.Maintenance) int { return strings.Compare(x.VehicleID, y.VehicleID) })
+	slices.SortFunc(b.Sessions, func(x, y models.PlanningSession) int { return strings.Compare(x.Name, y.Name) })
+	return b
+}
+
+// importBundle checks every record in b before storing any, so a bad
+// bundle changes nothing, and writes the first problem found
+func importBundle(w http.ResponseWriter, who auth.Principal, b *backup.Bundle) bool {
+	owned := func(tenant *string, existing string, exists bool, what string) bool {
+		*tenant = recordTenant(who, *tenant)
+		if !writable(w, who, *tenant) {
+			return false
+		}
+		if exists && existing != *tenant {
+			http.Error(w, what+" is taken", http.StatusConflict)
+			return false
+		}
+		return true
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for i := range b.Templates {
+		t := &b.Templates[i]
+		if err := t.Validate(); err != nil {
+			http.Error(w, "Template "+t.Name+": "+err.Error(), http.StatusBadRequest)
+			return false
+		}
+		old, ok := routeTemplates[t.Name]
+		if !owned(&t.Tenant, old.Tenant, ok, "Template name "+t.Name) {
+			return false
+		}
+	}
+	for i := range b.StandingOrders {
+		o := &b.StandingOrders[i]
+		if err := o.Validate(); err != nil {
+			http.Error(w, "Standing order "+o.ID+": "+err.Error(), http.StatusBadRequest)
+			return false
+		}
+		old, ok := standingOrders[o.ID]
+		if !owned(&o.Tenant, old.Tenant, ok, "Standing order ID "+o.ID) {
+			return false
+		}
+	}
+	for i := range b.Maintenance {
+		m := &b.Maintenance[i]
+		if err := m.Validate(); err != nil {
+			http.Error(w, "Maintenance for "+m.VehicleID+": "+err.Error(), http.StatusBadRequest)
+			return false
+		}
+		old, ok := maintenance[m.VehicleID]
+		if !owned(&m.Tenant, old.Tenant, ok, "Vehicle "+m.VehicleID) {
+			return false
+		}
+	}
+	for i := range b.Sessions {
+		s := &b.Sessions[i]
+		if s.Name == "" {
+			http.Error(w, "Planning sessions need a name", http.StatusBadRequest)
+			return false
+		}
+		old, ok := planningSessions[s.Name]
+		if ok && old.Status == sessionPlanning {
+			http.Error(w, "Planning session "+s.Name+" is being planned", http.StatusConflict)
+			return false
+		}
+		if !owned(&s.Tenant, tenantOf(old), ok, "Planning session "+s.Name) {
+			return false
+		}
+		if s.Status == sessionPlanning { // Its planner did not survive the export
+			s.Status = sessionClosed
+		}
+	}
+	if len(b.Boards) > 0 && who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins import dispatched plans", http.StatusForbidden)
+		return false
+	}
+	for _, board := range b.Boards {
+		if err := board.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+
+	for _, t := range b.Templates {
+		if templateDir != "" {
+			if err := templates.Save(templateDir, t); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return false
+			}
+		}
+		routeTemplates[t.Name] = t
+	}
+	for _, o := range b.StandingOrders {
+		if templateDir != "" {
+			if err := templates.SaveOrder(templateDir, o); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return false
+			}
+		}
+		standingOrders[o.ID] = o
+	}
+	for _, m := range b.Maintenance {
+		if templateDir != "" {
+			if err := templates.SaveMaintenance(templateDir, m); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return false
+			}
+		}
+		maintenance[m.VehicleID] = m
+	}
+	for _, s := range b.Sessions {
+		planningSessions[s.Name] = &s
+	}
+	for _, board := range b.Boards {
+		dispatched.Restore(board)
+	}
+	return true
+}
+
+func tenantOf(s *models.PlanningSession) string {
+	if s == nil {
+		return ""
+	}
+	return s.Tenant
+}
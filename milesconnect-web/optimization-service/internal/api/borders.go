@@ -0,0 +1,13 @@
+package api
+
+import "milesconnect-optimization/internal/problem"
+
+// borderDelays is the checkpoint time fleet plans add to legs between
+// states; see SetBorderDelays
+var borderDelays problem.BorderDelays
+
+// SetBorderDelays sets the hours lost at each state border, keyed like
+// problem.BorderDelays; call before serving requests
+func SetBorderDelays(d problem.BorderDelays) {
+	borderDelays = d
+}
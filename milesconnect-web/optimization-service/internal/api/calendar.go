@@ -0,0 +1,135 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/calendar"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCalendarDays bounds a holiday listing
+const maxCalendarDays = 3 * 366
+
+// holidays knows the fixed-date holidays until a holiday file is loaded
+var holidays = &calendar.Calendar{}
+
+// LoadHolidayFile adds holidays from a CSV file (date, name, optional state)
+func LoadHolidayFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return holidays.Load(f)
+}
+
+// CalendarHandler lists holidays for ?state= between ?from= and ?to=
+// (default: the current year)
+func CalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	year := time.Now().Year()
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	for _, p := range []struct {
+		key string
+		dst *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := q.Get(p.key); v != "" {
+			d, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			*p.dst = d
+		}
+	}
+	if to.Before(from) || to.Sub(from) > maxCalendarDays*24*time.Hour {
+		http.Error(w, "to must be after from and at most three years later", http.StatusBadRequest)
+		return
+	}
+	state := strings.ToUpper(q.Get("state"))
+	if !calendar.ValidState(state) {
+		http.Error(w, "state must be a two-letter state code", http.StatusBadRequest)
+		return
+	}
+
+	writeResponse(w, r, holidays.Between(from, to, state))
+}
+
+// WorkingDaysHandler computes a deadline: the date ?add= working days after
+// ?date=, skipping ?weekly_off= days (default sun; "none" for none) and the
+// holidays of ?state=
+func WorkingDaysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	date, err := time.Parse(time.DateOnly, q.Get("date"))
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	add := 0
+	if v := q.Get("add"); v != "" {
+		if add, err = strconv.Atoi(v); err != nil || add < 0 || add > maxCalendarDays {
+			http.Error(w, "add must be a non-negative number of days", http.StatusBadRequest)
+			return
+		}
+	}
+	state := strings.ToUpper(q.Get("state"))
+	if !calendar.ValidState(state) {
+		http.Error(w, "state must be a two-letter state code", http.StatusBadRequest)
+		return
+	}
+	offDays := []string{"sun"}
+	if v := q.Get("weekly_off"); v == "none" {
+		offDays = nil
+	} else if v != "" {
+		offDays = strings.Split(v, ",")
+	}
+	weeklyOff, err := calendar.ParseWeekdays(offDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	due, err := holidays.AddWorkingDays(date, add, state, weeklyOff)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	type workingDays struct {
+		From        string             `json:"from"`
+		WorkingDays int                `json:"working_days"`
+		Date        string             `json:"date"`
+		Skipped     []calendar.Holiday `json:"skipped_holidays"` // Weekly offs are not listed
+	}
+	writeResponse(w, r, workingDays{
+		From:        date.Format(time.DateOnly),
+		WorkingDays: add,
+		Date:        due.Format(time.DateOnly),
+		Skipped:     holidays.Between(date, due, state),
+	})
+}
+
+// holidayWarnings describes the holidays on date for a plan in state
+func holidayWarnings(date time.Time, state string) []string {
+	var warnings []string
+	for _, h := range holidays.Holidays(date, state) {
+		scope := "national holiday"
+		if h.State != "" {
+			scope = h.State + " state holiday"
+		}
+		warnings = append(warnings, h.Date+" is "+h.Name+" ("+scope+")")
+	}
+	return warnings
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/toll"
+	"slices"
+)
+
+// maxCarriers bounds the carriers one plan prices shipments with
+const maxCarriers = 50
+
+// carriers are the third-party carriers plans fall back on when a request
+// names none; SetCarriers configures them
+var carriers []models.Carrier
+
+// SetCarriers sets the configured third-party carriers; call before
+// serving requests
+func SetCarriers(c []models.Carrier) {
+	carriers = c
+}
+
+func validateCarriers(list []models.Carrier) error {
+	if len(list) > maxCarriers {
+		return errors.New("Too many carriers")
+	}
+	for _, c := range list {
+		if c.Name == "" {
+			return errors.New("Carriers need a name")
+		}
+		if !finite(c.BaseInr, c.PerKgInr, c.PerKmInr, c.MaxKg) || c.BaseInr < 0 || c.PerKgInr < 0 || c.PerKmInr < 0 || c.MaxKg < 0 {
+			return errors.New("Carrier rates and max_kg must not be negative")
+		}
+	}
+	return nil
+}
+
+// carrierQuote returns the cheapest carrier in list that takes kg over km
+func carrierQuote(list []models.Carrier, kg, km float64) (models.Carrier, float64, bool) {
+	best, price := models.Carrier{}, math.Inf(1)
+	for _, c := range list {
+		if c.MaxKg > 0 && kg > c.MaxKg {
+			continue
+		}
+		if p := c.BaseInr + kg*c.PerKgInr + km*c.PerKmInr; p < price {
+			best, price = c, p
+		}
+	}
+	return best, price, !math.IsInf(price, 1)
+}
+
+// outsource suggests carriers for sol's shipments: each unassigned one a
+// carrier takes, and each planned one a carrier moves for less than the
+// running cost its detour adds to the route. Carriers charge by distance
+// from the depot.
+func outsource(p *problem.Problem, sol problem.Solution, req models.FleetRequest) []models.OutsourceSuggestion {
+	list := req.Carriers
+	if len(list) == 0 {
+		list = carriers
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	costPerKm := cmp.Or(req.CostPerKm, toll.DefaultCostPerKm)
+	round := func(x float64) float64 { return math.Round(x*100) / 100 }
+
+	var out []models.OutsourceSuggestion
+	for _, n := range sol.Unassigned {
+		node := p.Nodes[n]
+		if c, price, ok := carrierQuote(list, node.DemandKg, p.Distance(0, n)); ok {
+			out = append(out, models.OutsourceSuggestion{StopID: node.ID, Carrier: c.Name, CarrierInr: round(price), Reason: "no_capacity"})
+		}
+	}
+	var cheaper []models.OutsourceSuggestion
+	for _, r := range sol.Routes {
+		for k := 1; k+1 < len(r.Stops); k++ {
+			prev, n, next := r.Stops[k-1], r.Stops[k], r.Stops[k+1]
+			node := p.Nodes[n]
+			c, price, ok := carrierQuote(list, node.DemandKg, p.Distance(0, n))
+			internal := (p.Distance(prev, n) + p.Distance(n, next) - p.Distance(prev, next)) * costPerKm
+			if ok && price < internal {
+				cheaper = append(cheaper, models.OutsourceSuggestion{
+					StopID:      node.ID,
+					Carrier:     c.Name,
+					CarrierInr:  round(price),
+					InternalInr: round(internal),
+					SavingInr:   round(internal - price),
+					Reason:      "cheaper",
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(cheaper, func(a, b models.OutsourceSuggestion) int { return cmp.Compare(b.SavingInr, a.SavingInr) })
+	return append(out, cheaper...)
+}
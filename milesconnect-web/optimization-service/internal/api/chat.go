@@ -0,0 +1,199 @@
+package api
+
+import (
+	"fmt"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/notify"
+	"milesconnect-optimization/internal/templates"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chat posts planning events to the Slack and Teams channels in tenants'
+// settings; nil posts nothing
+var chat *notify.Chat
+
+// maxChatShipments bounds the shipment IDs, or batch lines, listed in one
+// message
+const maxChatShipments = 20
+
+// chatBatchWait is how long a batch's failed jobs are gathered before
+// channels hear of them, in one message rather than one per job
+const chatBatchWait = time.Minute
+
+// chatBatches gathers failed jobs by batch until they are posted
+var (
+	chatBatchMu sync.Mutex
+	chatBatches = map[string][]jobs.Job{}
+)
+
+// ConfigureChat posts tenants' planning events through c; call before
+// serving requests
+func ConfigureChat(c *notify.Chat) {
+	chat = c
+}
+
+// chatChannels returns tenant's channels
+func chatChannels(tenant string) []templates.ChatChannel {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	return tenantSettings[tenant].Chat
+}
+
+// postChat sends msg to each of channels subscribed to event
+func postChat(channels []templates.ChatChannel, event string, msg notify.ChatMessage) {
+	for _, c := range channels {
+		if c.Wants(event) {
+			msg.Kind, msg.URL = c.Kind, c.URL
+			chat.Send(msg)
+		}
+	}
+}
+
+// chatPlanPublished tells tenant's channels that b was published, and
+// those whose threshold it passes how many shipments it left unassigned
+func chatPlanPublished(tenant string, b dispatch.Board) {
+	channels := chatChannels(tenant)
+	if chat == nil || len(channels) == 0 {
+		return
+	}
+	stops, distance := 0, 0.0
+	for _, run := range b.Runs {
+		stops += len(run.Stops)
+		distance += run.DistanceKm
+	}
+	postChat(channels, templates.ChatPlanPublished, notify.ChatMessage{
+		Title: "Plan for " + b.Date + " published",
+		Text:  fmt.Sprintf("Version %d: %d vehicles run %d stops over %v km.", b.Version, len(b.Runs), stops, round2(distance)),
+		Facts: []notify.Fact{{Name: "Unassigned", Value: strconv.Itoa(len(b.Unassigned))}},
+	})
+
+	ids := b.Unassigned
+	more := ""
+	if len(ids) > maxChatShipments {
+		ids, more = ids[:maxChatShipments], fmt.Sprintf(" and %d more", len(ids)-maxChatShipments)
+	}
+	for _, c := range channels {
+		if len(b.Unassigned) > c.UnassignedAbove {
+			postChat([]templates.ChatChannel{c}, templates.ChatUnassigned, notify.ChatMessage{
+				Title: fmt.Sprintf("%d shipments unassigned on the %s plan", len(b.Unassigned), b.Date),
+				Text:  fmt.Sprintf("Version %d leaves more than %d shipments without a vehicle.", b.Version, c.UnassignedAbove),
+				Facts: []notify.Fact{{Name: "Shipments", Value: strings.Join(ids, ", ") + more}},
+			})
+		}
+	}
+}
+
+// chatJobFailed tells j's tenant's channels that it failed. A batch's
+// jobs are told of together, chatBatchWait after the first of them fails.
+func chatJobFailed(j jobs.Job) {
+	if chat == nil || len(chatChannels(j.Tenant)) == 0 {
+		return
+	}
+	if j.Batch == "" {
+		facts := []notify.Fact{{Name: "Endpoint", Value: j.Path}, {Name: "Attempts", Value: strconv.Itoa(j.Attempts)}}
+		if j.Code != 0 {
+			facts = append(facts, notify.Fact{Name: "Status", Value: strconv.Itoa(j.Code)})
+		}
+		postChat(chatChannels(j.Tenant), templates.ChatJobFailed, notify.ChatMessage{
+			Title: "Job " + j.ID + " failed",
+			Text:  clipText(j.Error, 500),
+			Facts: facts,
+		})
+		return
+	}
+	chatBatchMu.Lock()
+	defer chatBatchMu.Unlock()
+	if len(chatBatches[j.Batch]) == 0 {
+		time.AfterFunc(chatBatchWait, func() { chatBatchFailed(j.Batch) })
+	}
+	chatBatches[j.Batch] = append(chatBatches[j.Batch], j)
+}
+
+// chatBatchFailed tells the batch's tenant's channels of the failed jobs
+// gathered for it, with the first one's error
+func chatBatchFailed(batch string) {
+	chatBatchMu.Lock()
+	failed := chatBatches[batch]
+	delete(chatBatches, batch)
+	chatBatchMu.Unlock()
+	if len(failed) == 0 {
+		return
+	}
+	first := failed[0]
+	var lines []string
+	for _, j := range failed[:min(len(failed), maxChatShipments)] {
+		lines = append(lines, strconv.Itoa(j.Line))
+	}
+	more := ""
+	if len(failed) > len(lines) {
+		more = fmt.Sprintf(" and %d more", len(failed)-len(lines))
+	}
+	facts := []notify.Fact{{Name: "Endpoint", Value: first.Path}, {Name: "Lines", Value: strings.Join(lines, ", ") + more}}
+	if first.Code != 0 {
+		facts = append(facts, notify.Fact{Name: "Status", Value: strconv.Itoa(first.Code)})
+	}
+	postChat(chatChannels(first.Tenant), templates.ChatJobFailed, notify.ChatMessage{
+		Title: fmt.Sprintf("%d jobs failed in batch %s", len(failed), batch),
+		Text:  clipText("Line "+strconv.Itoa(first.Line)+": "+first.Error, 500),
+		Facts: facts,
+	})
+}
+
+// clipText cuts s to n characters
+func clipText(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// maskChatURL hides the token a chat webhook URL carries in its path or
+// query, leaving the host
+func maskChatURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "****"
+	}
+	return u.Scheme + "://" + u.Host + "/****"
+}
+
+// maskChat hides s's chat webhook URLs, for listing
+func maskChat(s templates.TenantSettings) templates.TenantSettings {
+	if len(s.Chat) == 0 {
+		return s
+	}
+	channels := make([]templates.ChatChannel, len(s.Chat))
+	for i, c := range s.Chat {
+		c.URL = maskChatURL(c.URL)
+		channels[i] = c
+	}
+	s.Chat = channels
+	return s
+}
+
+// unmaskChat puts back the URLs of channels in s sent as listed, masked,
+// from the tenant's saved settings prev, so an admin can save what they
+// read. Callers hold tenantsMu.
+func unmaskChat(s *templates.TenantSettings, prev templates.TenantSettings) {
+	for i, c := range s.Chat {
+		if !strings.HasSuffix(c.URL, "/****") {
+			continue
+		}
+		// Channels on one host mask alike; prefer the one in the same place
+		if i < len(prev.Chat) && maskChatURL(prev.Chat[i].URL) == c.URL {
+			s.Chat[i].URL = prev.Chat[i].URL
+			continue
+		}
+		for _, old := range prev.Chat {
+			if maskChatURL(old.URL) == c.URL {
+				s.Chat[i].URL = old.URL
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/compliance"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+)
+
+// OverloadCheckHandler checks planned loads (the request's routes, or the
+// runs dispatched for its date) and weighbridge readings against each
+// vehicle's legal GVW, so an overloaded truck is caught before it leaves
+func OverloadCheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.OverloadCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Vehicles) == 0 || len(req.Vehicles) > maxVehicles {
+		http.Error(w, "Between 1 and 1000 vehicles are required", http.StatusBadRequest)
+		return
+	}
+	vehicles := map[string]models.VehicleWeights{}
+	for _, v := range req.Vehicles {
+		if !finite(v.UnladenKg, v.GVWKg) || v.UnladenKg < 0 || v.GVWKg < 0 {
+			http.Error(w, "Vehicle weights must not be negative", http.StatusBadRequest)
+			return
+		}
+		vehicles[v.ID] = v
+	}
+
+	planned := map[string]float64{} // Payload per vehicle
+	var order []string
+	add := func(id string, kg float64) {
+		if _, ok := planned[id]; !ok {
+			order = append(order, id)
+		}
+		planned[id] += kg
+	}
+	if len(req.Routes) > 0 {
+		for _, route := range req.Routes {
+			add(route.VehicleID, route.LoadKg)
+		}
+	} else if req.Date != "" {
+		_, tenant, ok := boardTenant(w, r)
+		if !ok {
+			return
+		}
+		date, ok := dispatchDate(w, req.Date)
+		if !ok {
+			return
+		}
+		b, ok := dispatched.Board(tenant, date)
+		if !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+		for _, run := range b.Runs {
+			add(run.VehicleID, run.LoadKg)
+		}
+	}
+
+	report := models.OverloadReport{Compliant: true, Findings: []models.OverloadFinding{}}
+	check := func(id string, grossKg float64, source string) bool {
+		v, ok := vehicles[id]
+		if !ok {
+			http.Error(w, "Vehicle "+id+" is not in vehicles", http.StatusBadRequest)
+			return false
+		}
+		f, err := compliance.Check(v, grossKg, source)
+		if errors.Is(err, compliance.ErrUnknownGVW) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		if f.Status == "overloaded" {
+			report.Compliant = false
+		}
+		report.Findings = append(report.Findings, f)
+		return true
+	}
+	for _, id := range order {
+		if !check(id, vehicles[id].UnladenKg+planned[id], "planned") {
+			return
+		}
+	}
+	for _, m := range req.Actual {
+		if !finite(m.GrossKg, m.PayloadKg) || m.GrossKg < 0 || m.PayloadKg < 0 {
+			http.Error(w, "Weighbridge readings must not be negative", http.StatusBadRequest)
+			return
+		}
+		gross := m.GrossKg
+		if gross == 0 {
+			gross = vehicles[m.VehicleID].UnladenKg + m.PayloadKg
+		}
+		if !check(m.VehicleID, gross, "actual") {
+			return
+		}
+	}
+
+	if !report.Compliant {
+		var overloaded []string
+		for _, f := range report.Findings {
+			if f.Status == "overloaded" {
+				overloaded = append(overloaded, f.VehicleID)
+			}
+		}
+		record(r, audit.Event{Kind: "compliance.overload", Date: req.Date, Vehicles: overloaded}, report)
+	}
+	writeResponse(w, r, report)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/connector"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/events"
+	"milesconnect-optimization/internal/secrets"
+	"net/http"
+	"time"
+)
+
+// sinks push published plans' line items to downstream systems
+var sinks []*connector.Sink
+
+// ConfigureConnectors starts delivering plan changes to list, whose broker
+// connectors publish on b, through outboxes in dir; call before serving
+// requests
+func ConfigureConnectors(list []connector.Connector, b events.Broker, dir string) error {
+	var started []*connector.Sink
+	for _, c := range list {
+		s, err := connector.Start(c, b, dir)
+		if err != nil {
+			for _, s := range started {
+				s.Close()
+			}
+			return err
+		}
+		started = append(started, s)
+	}
+	sinks = started
+	return nil
+}
+
+// sendPlanChanges passes tenant's connectors the stops publishing b moved,
+// added or dropped since prev
+func sendPlanChanges(tenant string, prev *dispatch.Board, b dispatch.Board) {
+	var items []connector.Item
+	for _, s := range sinks {
+		if s.Tenant != tenant {
+			continue
+		}
+		if items == nil {
+			items = connector.Changes(prev, b, notifyPolicy.DayStart, notifyPolicy.Zone)
+		}
+		s.Send(items)
+	}
+}
+
+// connectorView is a connector as shown to admins, with its URL's
+// credentials and its header values masked
+type connectorView struct {
+	connector.Connector
+	connector.Stats
+}
+
+var connectorList = listSpec[connectorView]{
+	key: func(c connectorView) string { return c.Name },
+	fields: map[string]listField[connectorView]{
+		"kind": {value: func(c connectorView) string { return c.Kind }},
+		"last_delivery": {
+			value:   func(c connectorView) string { return c.LastDelivery.Format(time.RFC3339) },
+			compare: func(a, b connectorView) int { return a.LastDelivery.Compare(b.LastDelivery) },
+		},
+	},
+}
+
+// ConnectorsHandler lists the downstream systems plans are pushed to, and
+// how deliveries to each have gone (GET, admins)
+func ConnectorsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins see connectors", http.StatusForbidden)
+		return
+	}
+	list := make([]connectorView, len(sinks))
+	for i, s := range sinks {
+		c := s.Connector
+		c.URL = secrets.MaskURL(c.URL)
+		c.Headers = make(map[string]string, len(s.Headers))
+		for k, v := range s.Headers {
+			c.Headers[k] = secrets.Mask(v)
+		}
+		list[i] = connectorView{c, s.Stats()}
+	}
+	writeList(w, r, list, connectorList)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+)
+
+// credentials are the configured secrets, masked; SetCredentials lists them
+var credentials []models.Credential
+
+// SetCredentials lists the service's configured credentials for
+// CredentialsHandler. Values must already be masked; call before serving
+// requests.
+func SetCredentials(list []models.Credential) {
+	credentials = list
+}
+
+// CredentialsHandler lists the configured credentials with their values
+// masked: which are sealed, with which key, and which still need
+// resealing after a key rotation. Only admins see them.
+func CredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins see credentials", http.StatusForbidden)
+		return
+	}
+	list := append([]models.Credential{}, credentials...)
+	writeList(w, r, list, listSpec[models.Credential]{key: func(c models.Credential) string { return c.Name }})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"math"
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"slices"
+)
+
+var crews = map[string]problem.Crew{
+	problem.SingleCrew.Name: problem.SingleCrew,
+	problem.RelayCrew.Name:  problem.RelayCrew,
+}
+
+// withCrews returns a copy of p in which each vehicle rests as its own crew
+// requires, or as crew does when it has none or force is set; vehicles
+// without either drive without a limit
+func withCrews(p *problem.Problem, vehicles []models.VehicleInfo, crew string, force bool) *problem.Problem {
+	cp := *p
+	cp.Curfews = slices.Clone(p.Curfews)
+	for i, v := range vehicles {
+		name := crew
+		if v.Crew != "" && !force {
+			name = v.Crew
+		}
+		if c, ok := crews[name]; ok {
+			cp.Curfews = append(cp.Curfews, c.Rests(p.Vehicles[i]))
+		}
+	}
+	return &cp
+}
+
+// compareCrews plans p with every vehicle single-crewed and then relayed
+func compareCrews(ctx context.Context, s solver.Solver, p *problem.Problem, vehicles []models.VehicleInfo) ([]models.CrewPlan, error) {
+	var plans []models.CrewPlan
+	for _, name := range []string{problem.SingleCrew.Name, problem.RelayCrew.Name} {
+		cp := withCrews(p, vehicles, name, true)
+		sol, err := s.Solve(ctx, cp)
+		if err != nil {
+			return nil, err
+		}
+		plan := models.CrewPlan{Crew: name, Unassigned: len(sol.Unassigned), Feasible: feasibility.Check(cp, sol).Feasible}
+		resp := sol.ToFleetResponse(cp)
+		plan.Vehicles = len(resp.Routes)
+		plan.DistanceKm = math.Round(resp.TotalDistKm*100) / 100
+		for _, r := range sol.Routes {
+			if len(r.Stops) <= 2 {
+				continue
+			}
+			v := cp.Vehicles[r.Vehicle]
+			times := cp.Schedule(v, r.Stops)
+			plan.FinishHours = math.Max(plan.FinishHours, times[len(times)-1])
+			for _, h := range cp.Holds(v, r.Stops) {
+				if h.Curfew == crews[name].Rest() {
+					plan.RestHours += h.Hours
+				}
+			}
+		}
+		plan.FinishHours = math.Round(plan.FinishHours*100) / 100
+		plan.RestHours = math.Round(plan.RestHours*100) / 100
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
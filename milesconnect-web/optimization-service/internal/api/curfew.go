@@ -0,0 +1,80 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"milesconnect-optimization/internal/calendar"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/toll"
+	"slices"
+	"time"
+)
+
+// curfewDays is how many days of curfew windows plans see, from the day
+// before the plan starts (for overnight windows still running) onward
+const curfewDays = 4
+
+func validateCurfews(curfews []models.Curfew) error {
+	for _, c := range curfews {
+		from, err1 := time.Parse("15:04", c.From)
+		to, err2 := time.Parse("15:04", c.To)
+		if err1 != nil || err2 != nil || from.Equal(to) {
+			return errors.New("Curfews need distinct from and to times as HH:MM")
+		}
+		if c.Center != nil && (!validLocation(*c.Center) || !finite(c.RadiusKm) || c.RadiusKm <= 0) {
+			return errors.New("Curfew areas need valid center coordinates and a positive radius_km")
+		}
+		if c.Center != nil && c.State != "" {
+			return errors.New("A curfew covers either a circle or a state, not both")
+		}
+		if !calendar.ValidState(c.State) {
+			return errors.New("Curfew state must be a two-letter state code")
+		}
+		for _, class := range c.Classes {
+			if !slices.Contains(toll.Classes, class) {
+				return errors.New("Curfew classes must be lcv, 2-axle, 3-axle or 4-axle")
+			}
+		}
+	}
+	return nil
+}
+
+// applyCurfews gives p the request's curfews as windows in plan hours,
+// which count from the notification policy's day start
+func applyCurfews(p *problem.Problem, req models.FleetRequest) {
+	for _, c := range req.Curfews {
+		pc := problem.Curfew{Name: cmp.Or(c.Name, c.From+"-"+c.To)}
+		if len(c.VehicleIDs) > 0 || len(c.Classes) > 0 {
+			pc.Vehicles = map[string]bool{}
+			for _, v := range req.Vehicles {
+				if slices.Contains(c.VehicleIDs, v.ID) || slices.Contains(c.Classes, cmp.Or(v.Class, toll.DefaultClass)) {
+					pc.Vehicles[v.ID] = true
+				}
+			}
+		}
+		if c.Center != nil || c.State != "" {
+			pc.Nodes = make([]bool, len(p.Nodes))
+			for n, node := range p.Nodes {
+				if c.Center != nil {
+					pc.Nodes[n] = problem.Haversine(*c.Center, node.Location) <= c.RadiusKm
+				} else {
+					pc.Nodes[n] = node.Location.State == c.State
+				}
+			}
+		}
+
+		from, _ := time.Parse("15:04", c.From)
+		to, _ := time.Parse("15:04", c.To)
+		length := to.Sub(from)
+		if length < 0 {
+			length += 24 * time.Hour
+		}
+		start := time.Duration(from.Hour())*time.Hour + time.Duration(from.Minute())*time.Minute - notifyPolicy.DayStart
+		for day := -1; day < curfewDays-1; day++ {
+			at := (start + time.Duration(day)*24*time.Hour).Hours()
+			pc.Windows = append(pc.Windows, problem.Window{From: at, To: at + length.Hours()})
+		}
+		p.Curfews = append(p.Curfews, pc)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/deadhead"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDeadheadDays bounds the date range one deadhead report covers
+const maxDeadheadDays = 92
+
+// DeadheadHandler reports the empty running in the plans dispatched from
+// ?from= through ?to= (default the week to today) and suggests backhaul
+// pairings that cut at least ?min_saving_km= (default 20)
+func DeadheadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	to, ok := dispatchDate(w, q.Get("to"))
+	if !ok {
+		return
+	}
+	end, _ := time.Parse(time.DateOnly, to)
+	from := end.AddDate(0, 0, -6).Format(time.DateOnly)
+	if v := q.Get("from"); v != "" {
+		if from, ok = dispatchDate(w, v); !ok {
+			return
+		}
+	}
+	start, _ := time.Parse(time.DateOnly, from)
+	if start.After(end) || end.Sub(start) >= maxDeadheadDays*24*time.Hour {
+		http.Error(w, "from must be on or before to, at most 92 days earlier", http.StatusBadRequest)
+		return
+	}
+	minSaving := float64(deadhead.DefaultMinSavingKm)
+	if v := q.Get("min_saving_km"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !finite(f) || f < 0 {
+			http.Error(w, "min_saving_km must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		minSaving = f
+	}
+
+	writeResponse(w, r, deadhead.Report(tenantBoards(tenant, from, to), from, to, minSaving))
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"time"
+)
+
+// TimeoutHeader lets a client ask for a shorter deadline than the
+// endpoint's, e.g. "X-Request-Timeout: 5s" when its own caller is waiting
+const TimeoutHeader = "X-Request-Timeout"
+
+// PartialHeader is set on responses that carry the best answer found before
+// the deadline
+const PartialHeader = "X-Partial-Result"
+
+// endpointTimeouts bounds each solving endpoint; see ConfigureTimeouts
+var (
+	endpointTimeouts = map[string]time.Duration{
+		"/optimize":              60 * time.Second,
+		"/optimize-load":         60 * time.Second,
+		"/optimize-fleet":        60 * time.Second,
+		"/optimize-india":        120 * time.Second,
+		"/templates/instantiate": 60 * time.Second,
+	}
+	defaultTimeout time.Duration // Other endpoints; 0 means none
+)
+
+// ConfigureTimeouts overrides endpoint deadlines by unversioned path and sets
+// the deadline for every other path (0 for none); call before serving
+// requests
+func ConfigureTimeouts(def time.Duration, perPath map[string]time.Duration) {
+	defaultTimeout = def
+	for path, d := range perPath {
+		endpointTimeouts[path] = d
+	}
+}
+
+// Deadlines gives each request a context deadline from its endpoint's
+// timeout, shortened by an SLA tier's budget and by X-Request-Timeout.
+// Solvers, the queue and external providers all stop when it passes.
+func Deadlines(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := endpointTimeouts[routePath(r.URL.Path)]
+		if !ok {
+			limit = defaultTimeout
+		}
+		if d, ok := slaBudgets[requestProfile(r)]; ok && (limit == 0 || d < limit) {
+			limit = d
+		}
+		if v := r.Header.Get(TimeoutHeader); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				http.Error(w, TimeoutHeader+" must be a positive duration, e.g. 5s", http.StatusBadRequest)
+				return
+			}
+			if limit == 0 || d < limit {
+				limit = d
+			}
+		}
+		if limit > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deadlineStatus returns the status for a solved response: 504 with meta
+// marked partial when the deadline passed during the solve, since the
+// answer is only the best found in time, and 200 otherwise. It also notes
+// in meta a tier pickSolver degraded the request to.
+func deadlineStatus(w http.ResponseWriter, r *http.Request, meta *models.SolveMeta) int {
+	meta.Degraded = w.Header().Get(DegradedHeader)
+	if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		return http.StatusOK
+	}
+	meta.Partial = true
+	w.Header().Set(PartialHeader, "true")
+	return http.StatusGatewayTimeout
+}
@@ -0,0 +1,440 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"milesconnect-optimization/internal/anonymize"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugHeader names the debug bundle kept of a solve, to quote when
+// reporting a failure or a poor plan
+const DebugHeader = "X-Debug-Bundle"
+
+const (
+	debugBundles   = 200      // Most recent solves kept
+	debugBytes     = 32 << 20 // Most bytes they hold between them
+	debugBodyBytes = 1 << 20  // Larger requests are kept without their body
+	debugErrorLen  = 2048
+)
+
+// debugBundle is what was asked of a solve and how it went: the request with
+// names blanked and links left out, the solver and parameters picked, and
+// the trace, seeds included, that its solver kept. The names are blanked
+// wherever the error and trace mention them too.
+type debugBundle struct {
+	ID      string             `json:"id"`
+	At      time.Time          `json:"at"`
+	Tenant  string             `json:"tenant,omitempty"`
+	Role    string             `json:"role,omitempty"`
+	Actor   string             `json:"actor"`
+	Method  string             `json:"method"`
+	Path    string             `json:"path"`
+	Query   string             `json:"query,omitempty"`
+	Request json.RawMessage    `json:"request,omitempty"`
+	Status  int                `json:"status"`
+	Error   string             `json:"error,omitempty"`
+	Millis  int64              `json:"ms"`
+	Solver  string             `json:"solver,omitempty"`
+	Params  map[string]float64 `json:"params,omitempty"`
+	Trace   solver.TraceReport `json:"trace"`
+}
+
+// debugReplay is how a bundle's request went when run again
+type debugReplay struct {
+	BundleID string             `json:"bundle_id"`
+	Status   int                `json:"status"`
+	Millis   int64              `json:"ms"`
+	Response json.RawMessage    `json:"response,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Trace    solver.TraceReport `json:"trace"`
+}
+
+// bundleRing keeps the most recent bundles, oldest first, up to
+// debugBundles of them and debugBytes between them
+type bundleRing struct {
+	mu      sync.Mutex
+	bundles []debugBundle
+	bytes   int
+}
+
+var debugRing bundleRing
+
+func (br *bundleRing) add(b debugBundle) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.bundles = append(br.bundles, b)
+	br.bytes += b.size()
+	drop := 0
+	for ; len(br.bundles)-drop > debugBundles || br.bytes > debugBytes && drop < len(br.bundles)-1; drop++ {
+		br.bytes -= br.bundles[drop].size()
+	}
+	br.bundles = slices.Delete(br.bundles, 0, drop)
+}
+
+// size is roughly how many bytes b holds: its request, error and notes
+func (b debugBundle) size() int {
+	n := len(b.Query) + len(b.Request) + len(b.Error)
+	for _, note := range b.Trace.Notes {
+		n += len(note.Message)
+	}
+	return n
+}
+
+func (br *bundleRing) get(id string) (debugBundle, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	for _, b := range br.bundles {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return debugBundle{}, false
+}
+
+func (br *bundleRing) list() []debugBundle {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	list := slices.Clone(br.bundles)
+	slices.Reverse(list)
+	return list
+}
+
+// debugCapture is where pickSolver notes the solver a request runs
+type debugCapture struct {
+	id     string // The bundle's
+	mu     sync.Mutex
+	solver string
+	params map[string]float64
+}
+
+type debugKey struct{}
+
+// noteSolver records s and params as the solver r runs, for its bundle
+func noteSolver(r *http.Request, s solver.Solver, params map[string]float64) {
+	if c, ok := r.Context().Value(debugKey{}).(*debugCapture); ok {
+		c.mu.Lock()
+		c.solver, c.params = s.Name(), params
+		c.mu.Unlock()
+	}
+}
+
+// debugBundleID returns the ID of r's bundle, if it is kept in one
+func debugBundleID(r *http.Request) string {
+	if c, ok := r.Context().Value(debugKey{}).(*debugCapture); ok {
+		return c.id
+	}
+	return ""
+}
+
+// replayHandler serves replayed requests; see ConfigureReplay
+var replayHandler http.Handler
+
+// ConfigureReplay sets the handler replays run through: the server's own,
+// so they pass the same middleware as the request they rerun
+func ConfigureReplay(h http.Handler) {
+	replayHandler = h
+}
+
+// Debug keeps a bundle of each solve, answering with its ID in
+// X-Debug-Bundle, so a failure or a poor plan can be downloaded and
+// replayed later. Replays are not bundled again.
+func Debug(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := routePath(r.URL.Path)
+		if method, ok := jobEndpoints[path]; !ok || r.Method != method || solver.TraceFrom(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body []byte
+		if r.Method == http.MethodPost {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, debugBodyBytes+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+		b := debugBundle{ID: newBundleID(), At: time.Now().UTC(), Actor: actor(r), Method: r.Method, Path: path, Query: r.URL.RawQuery}
+		b.Tenant, b.Role = debugPrincipal(r)
+		var names []string
+		if len(body) <= debugBodyBytes {
+			b.Request, names = sanitize(body)
+		}
+
+		trace := solver.NewTrace(false)
+		capture := &debugCapture{id: b.ID}
+		ctx := context.WithValue(solver.WithTrace(r.Context(), trace), debugKey{}, capture)
+		dw := &debugWriter{ResponseWriter: w}
+		w.Header().Set(DebugHeader, b.ID)
+		next.ServeHTTP(dw, r.WithContext(ctx))
+
+		b.Status, b.Millis = dw.status, time.Since(b.At).Milliseconds()
+		if b.Status == 0 {
+			b.Status = http.StatusOK
+		}
+		b.Error = redactText(strings.TrimSpace(dw.errBody.String()), names)
+		capture.mu.Lock()
+		b.Solver, b.Params = capture.solver, capture.params
+		capture.mu.Unlock()
+		b.Trace = trace.Report()
+		for i, n := range b.Trace.Notes {
+			n.Message = redactText(n.Message, names)
+			b.Trace.Notes[i] = n
+		}
+		debugRing.add(b)
+	})
+}
+
+// debugPrincipal is who r's token speaks for, if anyone; unlike principal
+// it writes no error, leaving that to the handler
+func debugPrincipal(r *http.Request) (tenant, role string) {
+	if len(plannerSecret) == 0 {
+		return "", auth.RoleAdmin
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if p, err := plannerPrincipal(token); err == nil {
+			return p.Tenant, p.Role
+		}
+	}
+	return "", ""
+}
+
+// debugWriter notes a response's status, and the body of an error
+type debugWriter struct {
+	http.ResponseWriter
+	status  int
+	errBody bytes.Buffer
+}
+
+func (dw *debugWriter) WriteHeader(status int) {
+	if dw.status == 0 {
+		dw.status = status
+	}
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *debugWriter) Write(b []byte) (int, error) {
+	if dw.status == 0 {
+		dw.status = http.StatusOK
+	}
+	if dw.status >= 400 && dw.errBody.Len() < debugErrorLen {
+		dw.errBody.Write(b[:min(len(b), debugErrorLen-dw.errBody.Len())])
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+// Flush keeps event streams flowing through the writer
+func (dw *debugWriter) Flush() {
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (dw *debugWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// sanitize blanks the strings of a JSON body's fields that name people and
+// places, and leaves out those giving locations or links away, as the
+// anonymizer knows them, returning what it blanked longest first; a body
+// that is not JSON is not kept
+func sanitize(body []byte) (json.RawMessage, []string) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, nil
+	}
+	blanked := map[string]bool{}
+	out, err := json.Marshal(redact(v, false, blanked))
+	if err != nil {
+		return nil, nil
+	}
+	names := slices.Collect(maps.Keys(blanked))
+	slices.SortFunc(names, func(a, b string) int { return len(b) - len(a) })
+	return out, names
+}
+
+// redact blanks v's strings under named fields, named when v is one, noting
+// them in blanked
+func redact(v any, named bool, blanked map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if anonymize.Dropped(k) {
+				delete(v, k)
+			} else {
+				v[k] = redact(field, named || anonymize.Named(k), blanked)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redact(v[i], named, blanked)
+		}
+	case string:
+		if named && v != "" {
+			blanked[v] = true
+			return "redacted"
+		}
+	}
+	return v
+}
+
+// redactText blanks names, longest first, wherever s mentions them whole
+func redactText(s string, names []string) string {
+	for _, name := range names {
+		if strings.Contains(s, name) {
+			s = anonymize.ReplaceWhole(s, name, "redacted")
+		}
+	}
+	return s
+}
+
+func newBundleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// DebugBundlesHandler lists the debug bundles of recent solves (GET), or
+// downloads one with ?id=, with ?anonymize=true rewritten for sharing with
+// support. Callers see their own tenant's; admins every tenant's.
+func DebugBundlesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		b, ok := debugRing.get(id)
+		if !ok || !who.Reads(b.Tenant) {
+			http.Error(w, "Unknown debug bundle", http.StatusNotFound)
+			return
+		}
+		name := "debug-" + b.ID
+		if r.URL.Query().Get("anonymize") == "true" {
+			a, ok := anonymizer(w, r, b.Tenant)
+			if !ok {
+				return
+			}
+			b, name = anonymizeBundle(a, b), name+"-anonymized"
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+		writeResponse(w, r, b)
+		return
+	}
+	list := []debugBundle{}
+	for _, b := range debugRing.list() {
+		if who.Reads(b.Tenant) {
+			list = append(list, b)
+		}
+	}
+	writeList(w, r, list, bundleList)
+}
+
+// anonymizeBundle rewrites b for sharing outside its tenant: the request
+// and its query through a, the IDs and names it replaced wherever the error
+// and trace mention them, and who asked left out
+func anonymizeBundle(a *anonymize.Anonymizer, b debugBundle) debugBundle {
+	if b.Request != nil {
+		b.Request, _ = a.JSON(b.Request)
+	}
+	b.Query = a.Query(b.Query)
+	b.Tenant, b.Actor = "", ""
+	b.Error = a.Text(b.Error)
+	notes := make([]solver.TraceNote, len(b.Trace.Notes))
+	for i, n := range b.Trace.Notes {
+		n.Message = a.Text(n.Message)
+		notes[i] = n
+	}
+	b.Trace.Notes = notes
+	return b
+}
+
+var bundleList = listSpec[debugBundle]{
+	key: func(b debugBundle) string { return b.ID },
+	fields: map[string]listField[debugBundle]{
+		"path":   {value: func(b debugBundle) string { return b.Path }},
+		"solver": {value: func(b debugBundle) string { return b.Solver }},
+		"tenant": {value: func(b debugBundle) string { return b.Tenant }},
+	},
+}
+
+// DebugReplayHandler reruns a bundle's request, ?id=, as its tenant would,
+// drawing the seeds it drew and tracing every improvement (POST, admins
+// only). Replays solve while the caller waits, and neither read nor add
+// to the plan pool, so they run as the bundled solve did.
+func DebugReplayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Replaying solves takes an admin token", http.StatusForbidden)
+		return
+	}
+	b, ok := debugRing.get(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "Unknown debug bundle", http.StatusNotFound)
+		return
+	}
+	if b.Method == http.MethodPost && b.Request == nil {
+		http.Error(w, "Bundle was kept without its request body", http.StatusUnprocessableEntity)
+		return
+	}
+	if replayHandler == nil {
+		http.Error(w, "Replays are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	query, _ := url.ParseQuery(b.Query)
+	query.Set("pool", "false")
+	trace := solver.NewTrace(true)
+	trace.FixSeeds(b.Trace.Seeds...)
+	req, err := jobRequest(solver.WithTrace(r.Context(), trace), jobs.Job{
+		Path: b.Path, Query: query.Encode(), Body: b.Request, Tenant: b.Tenant, Role: b.Role, Actor: b.Actor,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	record(r, audit.Event{Kind: "debug.replayed"}, map[string]string{"id": b.ID, "path": b.Path})
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	replayHandler.ServeHTTP(rec, req)
+	out := debugReplay{BundleID: b.ID, Status: rec.Code, Millis: time.Since(start).Milliseconds(), Trace: trace.Report()}
+	if body := rec.Body.Bytes(); json.Valid(body) {
+		out.Response = body
+	} else {
+		out.Error = strings.TrimSpace(string(body))
+	}
+	writeResponse(w, r, out)
+}
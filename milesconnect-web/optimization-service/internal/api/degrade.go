@@ -0,0 +1,63 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+)
+
+// DegradedHeader names the SLA tier a response was solved on in place of
+// the one asked for, because the solver pool was under load
+const DegradedHeader = "X-Degraded"
+
+// Load shedding thresholds on the solver pool's load, 0 to 1; see
+// ConfigureDegradation
+var (
+	degradeAt   = 0.5 // Solve on the fast tier
+	shedBatchAt = 0.8 // Refuse ?mode=batch solves
+)
+
+var (
+	degradedSolves = metrics.NewCounter("solver_degraded_total", "Solves moved to the fast tier under load")
+	shedBatches    = metrics.NewCounter("batch_shed_total", "Batch solves refused under load")
+)
+
+func init() {
+	metrics.GaugeFunc("solver_load", "Share of solver slots and queue places taken", func() float64 {
+		return solverPool.Load()
+	})
+}
+
+// ConfigureDegradation sets the pool loads past which requests are solved
+// on the fast tier and batch solves are refused; above 1 never happens.
+// Call before serving requests.
+func ConfigureDegradation(degrade, shedBatch float64) {
+	degradeAt, shedBatchAt = degrade, shedBatch
+}
+
+// degradeProfile returns the profile to solve a request under: the fast
+// tier in place of profile when the pool is loaded past degradeAt, noting
+// it in DegradedHeader. Tuned profiles are kept, like a ?solver=, since
+// the caller asked for that solver.
+func degradeProfile(w http.ResponseWriter, profile, def string) string {
+	if profile == tierFast || solverPool.Load() < degradeAt {
+		return profile
+	}
+	if _, tier := slaTiers[def][profile]; profile != "" && !tier {
+		return profile
+	}
+	degradedSolves.Inc()
+	w.Header().Set(DegradedHeader, tierFast)
+	return tierFast
+}
+
+// shedBatch writes a 503 for a ?mode=batch solve when the pool is loaded
+// past shedBatchAt, so interactive requests keep their slots
+func shedBatch(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Query().Get("mode") != "batch" || solverPool.Load() < shedBatchAt {
+		return false
+	}
+	shedBatches.Inc()
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "The service is under load and is not taking batch solves", http.StatusServiceUnavailable)
+	return true
+}
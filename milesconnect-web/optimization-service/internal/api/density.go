@@ -0,0 +1,64 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/density"
+	"milesconnect-optimization/internal/geo"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDensityDays bounds the date range one density grid covers
+const maxDensityDays = 366
+
+// DensityHandler buckets the stops dispatched from ?from= through ?to=
+// (default the 28 days to today) into geohash cells of ?precision=
+// characters (default 5) and returns them as GeoJSON, busiest first.
+// ?min_stops= drops sparser cells.
+func DensityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	to, ok := dispatchDate(w, q.Get("to"))
+	if !ok {
+		return
+	}
+	end, _ := time.Parse(time.DateOnly, to)
+	from := end.AddDate(0, 0, -27).Format(time.DateOnly)
+	if v := q.Get("from"); v != "" {
+		if from, ok = dispatchDate(w, v); !ok {
+			return
+		}
+	}
+	start, _ := time.Parse(time.DateOnly, from)
+	if start.After(end) || end.Sub(start) >= maxDensityDays*24*time.Hour {
+		http.Error(w, "from must be on or before to, at most 366 days earlier", http.StatusBadRequest)
+		return
+	}
+	precision, minStops := density.DefaultPrecision, 1
+	if v := q.Get("precision"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > geo.MaxGeohashPrecision {
+			http.Error(w, "precision must be between 1 and "+strconv.Itoa(geo.MaxGeohashPrecision), http.StatusBadRequest)
+			return
+		}
+		precision = n
+	}
+	if v := q.Get("min_stops"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "min_stops must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		minStops = n
+	}
+
+	writeResponse(w, r, density.Grid(tenantBoards(tenant, from, to), from, to, precision, minStops))
+}
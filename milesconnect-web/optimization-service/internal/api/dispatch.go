@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/ewaybill"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/notify"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dispatched holds the live plans shown on the dispatch board
+var dispatched = dispatch.NewStore()
+
+// maxStatusScans bounds one bulk status update; a hub sorts in batches
+const maxStatusScans = 2000
+
+// notifier receives shipment events when plans are published; nil sends
+// nothing
+var (
+	notifier     *notify.Webhook
+	notifyPolicy = notify.DefaultPolicy
+)
+
+// ConfigureNotifications sends shipment ETA events to h; call before serving
+// requests
+func ConfigureNotifications(h *notify.Webhook, p notify.Policy) {
+	notifier, notifyPolicy = h, p
+}
+
+// DispatchHandler returns the caller's board for ?date= (default today) on
+// GET, with every vehicle's run and the status of each stop, and publishes
+// a day's plan on POST. The board's version is its ETag; replacing a
+// published plan needs it in If-Match.
+func DispatchHandler(w http.ResponseWriter, r *http.Request) {
+	who, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		date, ok := dispatchDate(w, r.URL.Query().Get("date"))
+		if !ok {
+			return
+		}
+		b, ok := dispatched.Board(tenant, date)
+		if !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag(b.Version))
+		if tag := r.Header.Get("If-None-Match"); tag != "" && etagMatches(tag, b.Version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeResponse(w, r, b)
+
+	case http.MethodPost:
+		if !writable(w, who, tenant) {
+			return
+		}
+		limitBody(w, r)
+		var req models.DispatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if _, err := time.Parse(time.DateOnly, req.Date); err != nil {
+			http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		version, ok := precondition(w, r, tenant, req.Date, true)
+		if !ok {
+			return
+		}
+		b, err := publishPlan(r, tenant, req.Date, version, req.Routes, req.Unassigned, req.Shipments, req.Consignees)
+		if !dispatchError(w, err) {
+			return
+		}
+		w.Header().Set("ETag", etag(b.Version))
+		writeResponse(w, r, b)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DispatchStatusHandler moves a stop to en_route or completed and returns
+// its vehicle's run, with the board's new version as its ETag. If-Match
+// must carry the version the dispatcher read.
+func DispatchStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	who, tenant, ok := boardTenant(w, r)
+	if !ok || !writable(w, who, tenant) {
+		return
+	}
+	limitBody(w, r)
+	var req models.StopStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := precondition(w, r, tenant, req.Date, true)
+	if !ok {
+		return
+	}
+	run, version, err := dispatched.SetStatus(tenant, req.Date, version, req.VehicleID, req.StopID, dispatch.Status(req.Status))
+	if !dispatchError(w, err) {
+		return
+	}
+	record(r, audit.Event{Kind: "stop.status", Tenant: tenant, Date: req.Date, Shipments: []string{req.StopID}, Vehicles: []string{req.VehicleID}}, req)
+	w.Header().Set("ETag", etag(version))
+	writeResponse(w, r, run)
+}
+
+// ShipmentStatusHandler applies a batch of stop status updates on PATCH, as
+// a hub scans parcels at sort time, and reports how each went. Updates
+// apply one by one, so a bad scan does not hold up the rest. Scans record
+// what happened on the floor rather than edit a plan the scanner read, so
+// unlike /dispatch/status they need no If-Match; one sent is still checked.
+func ShipmentStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	who, tenant, ok := boardTenant(w, r)
+	if !ok || !writable(w, who, tenant) {
+		return
+	}
+	limitBody(w, r)
+	var req models.BulkStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse(time.DateOnly, req.Date); err != nil {
+		http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if len(req.Updates) == 0 || len(req.Updates) > maxStatusScans {
+		http.Error(w, "Send between 1 and "+strconv.Itoa(maxStatusScans)+" updates", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := precondition(w, r, tenant, req.Date, false)
+	if !ok {
+		return
+	}
+	changes := make([]dispatch.StatusChange, len(req.Updates))
+	for i, u := range req.Updates {
+		changes[i] = dispatch.StatusChange{VehicleID: u.VehicleID, StopID: u.StopID, Status: dispatch.Status(u.Status)}
+	}
+	errs, version, err := dispatched.SetStatuses(tenant, req.Date, version, changes)
+	if !dispatchError(w, err) {
+		return
+	}
+
+	res := models.BulkStatusResult{Results: make([]models.StopScanResult, len(errs))}
+	var updated []string
+	for i, err := range errs {
+		res.Results[i] = models.StopScanResult{StopID: req.Updates[i].StopID, Code: http.StatusOK}
+		if err != nil {
+			res.Results[i].Code, res.Results[i].Error = dispatchCode(err), err.Error()
+			res.Failed++
+			continue
+		}
+		updated = append(updated, req.Updates[i].StopID)
+		res.Updated++
+	}
+	if res.Updated > 0 {
+		record(r, audit.Event{Kind: "stop.status", Tenant: tenant, Date: req.Date, Shipments: updated}, req)
+	}
+	w.Header().Set("ETag", etag(version))
+	writeResponse(w, r, res)
+}
+
+// publishPlan checks the plan's e-way bills, puts it on tenant's board in
+// place of the plan at version, records it, notifies customers whose
+// shipments were added, moved or dropped and announces it on the message
+// broker
+func publishPlan(r *http.Request, tenant, date string, version int, routes []models.FleetRoute, unassigned []string, docs []models.ShipmentDocs, consignees []models.Consignee) (dispatch.Board, error) {
+	if err := checkEWayBills(date, routes, docs); err != nil {
+		return dispatch.Board{}, err
+	}
+	var prev *dispatch.Board
+	if b, ok := dispatched.Board(tenant, date); ok {
+		prev = &b
+	}
+	b, err := dispatched.Publish(tenant, date, version, routes, unassigned, consignees)
+	if err != nil {
+		return dispatch.Board{}, err
+	}
+	shipments, vehicles := fleetIDs(routes, unassigned)
+	record(r, audit.Event{Kind: "plan.published", Tenant: tenant, Date: date, Shipments: shipments, Vehicles: vehicles}, b)
+	diff := notify.Diff(prev, b, notifyPolicy)
+	notifier.Send(diff)
+	publishPlanEvents(tenant, b, shipments, vehicles, diff)
+	sendPlanChanges(tenant, prev, b)
+	chatPlanPublished(tenant, b)
+	return b, nil
+}
+
+// etag formats a board version as an entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// precondition returns the version of tenant's board for date an edit was
+// based on, from If-Match, or 0 if nothing is dispatched for the date or,
+// when the edit may skip If-Match and does, to apply to whatever version
+// is current. Editing a published plan without If-Match where it is
+// required is refused with a 428, so nobody overwrites changes they have
+// not seen; a tag that is not the board's current version gets a 412, here
+// or when the edit is applied.
+func precondition(w http.ResponseWriter, r *http.Request, tenant, date string, required bool) (int, bool) {
+	tag := r.Header.Get("If-Match")
+	b, published := dispatched.Board(tenant, date)
+	switch {
+	case tag == "" && published && required:
+		http.Error(w, "If-Match is required to change a published plan; send the ETag from GET /dispatch", http.StatusPreconditionRequired)
+		return 0, false
+	case tag == "":
+		return 0, true
+	case published && etagMatches(tag, b.Version):
+		return b.Version, true
+	}
+	http.Error(w, dispatch.ErrVersion.Error(), http.StatusPreconditionFailed)
+	return 0, false
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header names
+// version: "*", or any tag in its list compared weakly, so a W/ prefix a
+// proxy added does not matter
+func etagMatches(header string, version int) bool {
+	want := etag(version)
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// boardTenant returns who is calling and whose boards they mean: their own
+// tenant's or, for an admin, the one ?tenant= names. It writes a 401 when
+// the caller has no valid token.
+func boardTenant(w http.ResponseWriter, r *http.Request) (auth.Principal, string, bool) {
+	who, ok := principal(w, r)
+	if !ok {
+		return auth.Principal{}, "", false
+	}
+	return who, recordTenant(who, r.URL.Query().Get("tenant")), true
+}
+
+// tenantBoards returns tenant's plans for from through to
+func tenantBoards(tenant, from, to string) []dispatch.Board {
+	return slices.DeleteFunc(dispatched.Boards(from, to), func(b dispatch.Board) bool { return b.Tenant != tenant })
+}
+
+// dispatchDate checks a YYYY-MM-DD date, defaulting to today, and writes a
+// 400 if it is malformed
+func dispatchDate(w http.ResponseWriter, date string) (string, bool) {
+	if date == "" {
+		return time.Now().Format(time.DateOnly), true
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return "", false
+	}
+	return date, true
+}
+
+// dispatchError maps board errors to a status and reports whether err was
+// nil. Shipments blocked by their e-way bills are listed as JSON.
+func dispatchError(w http.ResponseWriter, err error) bool {
+	var bills *ewaybill.Error
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &bills):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]any{"error": "Plan not published: shipments lack valid e-way bills", "shipments": bills.Shipments})
+	default:
+		http.Error(w, err.Error(), dispatchCode(err))
+	}
+	return false
+}
+
+// dispatchCode returns the HTTP status for a board error
+func dispatchCode(err error) int {
+	switch {
+	case errors.Is(err, dispatch.ErrUnknown):
+		return http.StatusNotFound
+	case errors.Is(err, dispatch.ErrTransition), errors.Is(err, dispatch.ErrStarted):
+		return http.StatusConflict
+	case errors.Is(err, dispatch.ErrVersion):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, dispatch.ErrInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
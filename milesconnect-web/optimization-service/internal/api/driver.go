@@ -0,0 +1,255 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// driverSecret signs driver tokens; the driver API rejects every request
+// until it is set
+var driverSecret []byte
+
+// SetDriverSecret sets the secret driver tokens are signed with
+func SetDriverSecret(secret string) {
+	driverSecret = []byte(secret)
+}
+
+// DriverRouteHandler returns the calling driver's run for ?date= (default
+// today)
+func DriverRouteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, vehicleID, ok := driverVehicle(w, r)
+	if !ok {
+		return
+	}
+	run, ok := driverRun(w, tenant, r.URL.Query().Get("date"), vehicleID)
+	if !ok {
+		return
+	}
+	writeResponse(w, r, run)
+}
+
+// DriverNextStopHandler returns the first stop on the driver's run that is
+// not completed, with a link that opens turn-by-turn navigation to it
+func DriverNextStopHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, vehicleID, ok := driverVehicle(w, r)
+	if !ok {
+		return
+	}
+	run, ok := driverRun(w, tenant, r.URL.Query().Get("date"), vehicleID)
+	if !ok {
+		return
+	}
+
+	resp := struct {
+		Stop          *dispatch.Stop `json:"stop,omitempty"` // Absent once the run is done
+		NavigationURL string         `json:"navigation_url,omitempty"`
+		Remaining     int            `json:"remaining"`
+	}{}
+	for _, st := range run.Stops {
+		if st.Status != dispatch.Completed {
+			resp.Remaining++
+		}
+	}
+	if st, ok := run.Next(); ok {
+		resp.Stop = &st
+		resp.NavigationURL = navigationURL(st.Location)
+	}
+	writeResponse(w, r, resp)
+}
+
+// DriverArriveHandler records the driver reaching a stop
+func DriverArriveHandler(w http.ResponseWriter, r *http.Request) {
+	driverStopEvent(w, r, "stop.arrived", dispatched.Arrive)
+}
+
+// DriverDepartHandler records the driver leaving a stop, completing it
+func DriverDepartHandler(w http.ResponseWriter, r *http.Request) {
+	driverStopEvent(w, r, "stop.departed", dispatched.Depart)
+}
+
+// DriverIssuesHandler records a problem the driver reports, which then shows
+// on the dispatch board
+func DriverIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, vehicleID, ok := driverVehicle(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var req models.DriverIssueReport
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+
+	issue, err := dispatched.Report(tenant, date, dispatch.Issue{VehicleID: vehicleID, StopID: req.StopID, Kind: req.Kind, Note: req.Note})
+	if !dispatchError(w, err) {
+		return
+	}
+	ev := audit.Event{Kind: "issue.reported", Actor: "driver:" + vehicleID, Tenant: tenant, Date: date, Vehicles: []string{vehicleID}}
+	if req.StopID != "" {
+		ev.Shipments = []string{req.StopID}
+	}
+	record(r, ev, issue)
+	writeResponse(w, r, issue)
+}
+
+func driverStopEvent(w http.ResponseWriter, r *http.Request, kind string, apply func(tenant, date, vehicleID, stopID string) (dispatch.Run, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, vehicleID, ok := driverVehicle(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var req models.DriverStopEvent
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+	var track []models.Location
+	if req.Track != "" {
+		if kind != "stop.arrived" {
+			http.Error(w, "track is only accepted on arrival", http.StatusBadRequest)
+			return
+		}
+		precision := cmp.Or(req.TrackPrecision, geo.Precision5)
+		if precision != geo.Precision5 && precision != geo.Precision6 {
+			http.Error(w, "track_precision must be 5 or 6", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if track, err = geo.DecodePolyline(req.Track, precision); err != nil {
+			http.Error(w, "track: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	run, err := apply(tenant, date, vehicleID, req.StopID)
+	if !dispatchError(w, err) {
+		return
+	}
+	if len(track) > 0 {
+		path, km, source := matchTrack(r, track)
+		t := dispatch.Track{
+			Polyline: geo.EncodePolyline(path, geo.Precision5), // Whatever precision the app sent
+			DrivenKm: km,
+			RawKm:    geo.PathKm(track),
+			Source:   source,
+		}
+		if run, err = dispatched.RecordTrack(tenant, date, vehicleID, req.StopID, t); !dispatchError(w, err) {
+			return
+		}
+	}
+	record(r, audit.Event{Kind: kind, Actor: "driver:" + vehicleID, Tenant: tenant, Date: date, Shipments: []string{req.StopID}, Vehicles: []string{vehicleID}}, nil)
+	writeResponse(w, r, run)
+}
+
+// driverVehicle returns the vehicle, and its tenant, named by the request's
+// bearer token and writes a 401 if there is no valid one
+func driverVehicle(w http.ResponseWriter, r *http.Request) (tenant, vehicleID string, ok bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(driverSecret) == 0 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="driver"`)
+		http.Error(w, "Driver token required", http.StatusUnauthorized)
+		return "", "", false
+	}
+	subject, err := auth.Verify(driverSecret, token, time.Now())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="driver", error="invalid_token"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return "", "", false
+	}
+	tenant, vehicleID = auth.ParseDriver(subject)
+	return tenant, vehicleID, true
+}
+
+func driverRun(w http.ResponseWriter, tenant, date, vehicleID string) (dispatch.Run, bool) {
+	date, ok := dispatchDate(w, date)
+	if !ok {
+		return dispatch.Run{}, false
+	}
+	run, ok := dispatched.Run(tenant, date, vehicleID)
+	if !ok {
+		http.Error(w, "No run dispatched for this vehicle on "+date, http.StatusNotFound)
+		return dispatch.Run{}, false
+	}
+	return run, true
+}
+
+// mapsMaxWaypoints is how many intermediate stops a Google Maps directions
+// link may carry; with the destination that is 10 stops per link
+const mapsMaxWaypoints = 9
+
+// navigationURL opens driving directions to loc in Google Maps, which the
+// Android and iOS apps also handle
+func navigationURL(loc models.Location) string {
+	return mapsDirections(nil, loc, nil)
+}
+
+// navigationURLs turns a route (start, stops..., end) into Google Maps
+// multi-stop links to open in turn, each starting where the last one ended
+func navigationURLs(route []models.Location) []string {
+	var links []string
+	for start := 0; start+1 < len(route); {
+		end := min(start+mapsMaxWaypoints+1, len(route)-1)
+		origin := route[start]
+		links = append(links, mapsDirections(&origin, route[end], route[start+1:end]))
+		start = end
+	}
+	return links
+}
+
+// mapsDirections builds a Google Maps directions link; a nil origin means
+// the device's current location
+func mapsDirections(origin *models.Location, dest models.Location, waypoints []models.Location) string {
+	coords := func(l models.Location) string { return fmt.Sprintf("%.6f,%.6f", l.Lat, l.Lng) }
+	q := url.Values{}
+	q.Set("api", "1")
+	if origin != nil {
+		q.Set("origin", coords(*origin))
+	}
+	q.Set("destination", coords(dest))
+	if len(waypoints) > 0 {
+		via := make([]string, len(waypoints))
+		for i, w := range waypoints {
+			via[i] = coords(w)
+		}
+		q.Set("waypoints", strings.Join(via, "|"))
+	}
+	q.Set("travelmode", "driving")
+	return "https://www.google.com/maps/dir/?" + q.Encode()
+}
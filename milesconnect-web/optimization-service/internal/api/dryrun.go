@@ -0,0 +1,40 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+)
+
+// dryRun answers a ?dry_run=true request, which has passed validation, with
+// the checks its solve would face and how long it should take, in place of
+// solving problems with s; it returns false for any other request. Dry runs
+// are not admitted or metered.
+func dryRun(w http.ResponseWriter, r *http.Request, s solver.Solver, params map[string]float64, problems ...*problem.Problem) bool {
+	if r.URL.Query().Get("dry_run") != "true" {
+		return false
+	}
+	resp := models.DryRunResponse{Solver: s.Name()}
+	report := models.FeasibilityReport{Feasible: true, Violations: []models.Violation{}}
+	for _, p := range problems {
+		if p == nil {
+			continue
+		}
+		p.SolverParams = params
+		resp.Stops += len(p.Stops())
+		resp.Vehicles += len(p.Vehicles)
+		pre := feasibility.Precheck(p)
+		report.Feasible = report.Feasible && pre.Feasible
+		report.Violations = append(report.Violations, pre.Violations...)
+
+	}
+	if d, ok := predictSolve(s, params, problems...); ok {
+		estimate := d.Seconds()
+		resp.EstimatedSolveSeconds = &estimate
+	}
+	resp.Feasibility = &report
+	writeResponse(w, r, resp)
+	return true
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/elite"
+	"milesconnect-optimization/internal/problem"
+	"net/http"
+)
+
+// planPool keeps good fleet plans by tenant and problem signature, so a
+// recurring plan (the same fleet from the same depots, day after day)
+// starts from the best of its earlier ones
+var planPool = elite.New()
+
+// seedFromPool hands p the tenant's pooled plans for problems like it,
+// unless the request opts out with ?pool=false, and returns how many
+func seedFromPool(r *http.Request, p *problem.Problem) int {
+	if r.URL.Query().Get("pool") == "false" {
+		return 0
+	}
+	p.Seeds = planPool.Seeds(requestTenant(r), p)
+	return len(p.Seeds)
+}
+
+// poolPlan keeps sol, a plan for p, to seed later requests like r's
+func poolPlan(r *http.Request, p *problem.Problem, sol problem.Solution) {
+	if r.URL.Query().Get("pool") == "false" {
+		return
+	}
+	planPool.Add(requestTenant(r), p, sol)
+}
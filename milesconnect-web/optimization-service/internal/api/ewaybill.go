@@ -0,0 +1,65 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/ewaybill"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"time"
+)
+
+// E-way bill checks before publication: shipments dispatched with details
+// are always checked; with ewayBillsRequired every shipment must have them
+var (
+	ewayBillsRequired bool
+	ewayBillThreshold float64 = ewaybill.DefaultThresholdInr
+)
+
+// RequireEWayBills makes every dispatched shipment declare its value and,
+// above thresholdInr, its e-way bill
+func RequireEWayBills(required bool, thresholdInr float64) {
+	ewayBillsRequired, ewayBillThreshold = required, thresholdInr
+}
+
+// checkEWayBills returns an *ewaybill.Error listing every shipment on the
+// plan whose details are missing or would not last the trip
+func checkEWayBills(date string, routes []models.FleetRoute, docs []models.ShipmentDocs) error {
+	if len(docs) == 0 && !ewayBillsRequired {
+		return nil
+	}
+	byStop := map[string]models.ShipmentDocs{}
+	for _, d := range docs {
+		byStop[d.StopID] = d
+	}
+	day, _ := time.ParseInLocation(time.DateOnly, date, notifyPolicy.Zone)
+	endOfDay := day.AddDate(0, 0, 1)
+
+	var problems []models.ShipmentProblems
+	for _, r := range routes {
+		for i, id := range r.StopIDs {
+			d, ok := byStop[id]
+			if !ok {
+				if ewayBillsRequired {
+					problems = append(problems, models.ShipmentProblems{StopID: id, Errors: []string{"consignment value and e-way bill are missing"}})
+				}
+				continue
+			}
+			minKm := 0.0
+			if i+1 < len(r.Route) {
+				minKm = problem.Haversine(r.Route[0], r.Route[i+1]) // Route starts at the vehicle's start
+			}
+			arrival := endOfDay
+			if i < len(r.ArrivalHours) {
+				if w := notifyPolicy.Window(date, r.ArrivalHours[i]); w != nil {
+					arrival = w.To
+				}
+			}
+			if errs := ewaybill.Check(d, arrival, minKm, ewayBillThreshold); len(errs) > 0 {
+				problems = append(problems, models.ShipmentProblems{StopID: id, Errors: errs})
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &ewaybill.Error{Shipments: problems}
+	}
+	return nil
+}
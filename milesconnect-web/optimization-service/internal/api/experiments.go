@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math/rand"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/experiments"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"time"
+)
+
+// experimentRegistry holds the solver experiments running
+var experimentRegistry = experiments.NewRegistry()
+
+// experimentEndpoints are the endpoints experiments can enroll requests
+// to, with each one's default solver and what it needs of a solver at least
+var experimentEndpoints = map[string]struct {
+	def  string
+	need solver.Capabilities
+}{
+	"/optimize":       {defaultRouteSolver, solver.CapRouting},
+	"/optimize-load":  {defaultLoadSolver, solver.CapAllocation},
+	"/optimize-fleet": {defaultFleetSolver, solver.CapRouting | solver.CapCapacity},
+}
+
+// Shadow solves run in the background, at most shadowSlots at a time so
+// they never crowd out requests; enrolled requests past that are skipped
+var (
+	shadowSlots   = make(chan struct{}, 1)
+	shadowTimeout = 2 * time.Minute
+)
+
+// trial is a request's solve as experiments and regression shadowing see
+// it: the solver it runs, and the experiment it is enrolled in if any
+type trial struct {
+	endpoint string
+	solver   solver.Solver
+	params   map[string]float64
+	actor    string
+
+	exp experiments.Experiment // Zero when not enrolled
+	arm string
+
+	// Shadow mode: the variant, to solve the request's problem afterwards
+	shadow       solver.Solver
+	shadowParams map[string]float64
+}
+
+// enroll draws whether r takes part in an experiment on its endpoint and
+// returns what to solve it with, the variant for split mode's share of
+// requests, else s under params, and the trial to observe the solve with.
+// Requests that pin a solver or profile are left out, as are those solved
+// on the fast tier under load and those the variant cannot take.
+func enroll(w http.ResponseWriter, r *http.Request, def string, need solver.Capabilities, s solver.Solver, params map[string]float64) (solver.Solver, map[string]float64, *trial) {
+	t := &trial{endpoint: routePath(r.URL.Path), solver: s, params: params, actor: actor(r)}
+	q := r.URL.Query()
+	if q.Get("solver") != "" || q.Get("profile") != "" || w.Header().Get(DegradedHeader) != "" {
+		return s, params, t
+	}
+	e, ok := experimentRegistry.For(t.endpoint, requestTenant(r))
+	if !ok {
+		return s, params, t
+	}
+	drawn := rand.Float64()*100 < e.Percent
+	if !drawn && e.Mode == experiments.ModeShadow {
+		return s, params, t
+	}
+	t.exp, t.arm = e, experiments.ArmControl
+	if !drawn {
+		return s, params, t
+	}
+
+	v, vparams, ok := pickArm(httptest.NewRecorder(), r, def, need, e.Variant)
+	if !ok {
+		t.exp = experiments.Experiment{}
+		return s, params, t
+	}
+	if e.Mode == experiments.ModeShadow {
+		t.shadow, t.shadowParams = v, vparams
+		return s, params, t
+	}
+	t.arm = experiments.ArmVariant
+	t.solver, t.params = v, vparams
+	noteSolver(r, v, vparams)
+	return v, vparams, t
+}
+
+// pickArm is pickSolver for an experiment's arm in place of r's query
+func pickArm(w http.ResponseWriter, r *http.Request, def string, need solver.Capabilities, arm experiments.Arm) (solver.Solver, map[string]float64, bool) {
+	ar := r.Clone(context.WithValue(r.Context(), debugKey{}, nil))
+	q := ar.URL.Query()
+	q.Set("solver", arm.Solver)
+	q.Set("profile", arm.Profile)
+	ar.URL.RawQuery = q.Encode()
+	return pickSolver(w, ar, def, need)
+}
+
+// label names t's experiment and arm for the response's meta
+func (t *trial) label() string {
+	if t.exp.Name == "" {
+		return ""
+	}
+	if t.shadow != nil {
+		return t.exp.Name + "/" + experiments.ModeShadow
+	}
+	return t.exp.Name + "/" + t.arm
+}
+
+// observe records how r's solve of p went for its experiment, in shadow
+// mode starting the variant on p to compare with it, and has the
+// endpoint's reference solver check it for regressions
+func (t *trial) observe(r *http.Request, p *problem.Problem, sol problem.Solution, err error, took time.Duration) {
+	got := outcome(p, sol, err, took)
+	t.checkRegression(r, p, got)
+	name := t.exp.Name
+	if name == "" {
+		return
+	}
+	if t.shadow == nil {
+		experimentRegistry.Record(name, t.arm, got)
+		record(r, audit.Event{Kind: "experiment.trial", Actor: t.actor}, map[string]any{"experiment": name, t.arm: got})
+		return
+	}
+
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		experimentRegistry.Skip(name)
+		return
+	}
+	q := *p
+	q.SolverParams = t.shadowParams
+	go func() {
+		defer func() { <-shadowSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+		start := time.Now()
+		vsol, verr := t.shadow.Solve(ctx, &q)
+		variant := outcome(&q, vsol, verr, time.Since(start))
+		experimentRegistry.RecordPair(name, got, variant)
+		record(r, audit.Event{Kind: "experiment.trial", Actor: t.actor},
+			map[string]any{"experiment": name, experiments.ArmControl: got, experiments.ArmVariant: variant})
+	}()
+}
+
+// outcome measures sol's routes in km, rather than by the costs solvers
+// report, so solvers minimising tolls compare on the same footing
+func outcome(p *problem.Problem, sol problem.Solution, err error, took time.Duration) experiments.Outcome {
+	o := experiments.Outcome{Millis: took.Milliseconds(), Failed: err != nil}
+	if err != nil {
+		return o
+	}
+	o.Unassigned = len(sol.Unassigned)
+	for _, route := range sol.Routes {
+		for k := 1; k < len(route.Stops); k++ {
+			o.DistanceKm += p.Distance(route.Stops[k-1], route.Stops[k])
+		}
+	}
+	return o
+}
+
+var experimentList = listSpec[experiments.Report]{
+	key: func(r experiments.Report) string { return r.Name },
+	fields: map[string]listField[experiments.Report]{
+		"endpoint": {value: func(r experiments.Report) string { return r.Endpoint }},
+		"mode":     {value: func(r experiments.Report) string { return r.Mode }},
+	},
+}
+
+// ExperimentsHandler lists the solver experiments running with how each
+// arm has done, or one with ?name= (GET), starts one (POST) or stops one
+// (DELETE ?name=). Admins only.
+func ExperimentsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins run experiments", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if name := r.URL.Query().Get("name"); name != "" {
+			report, ok := experimentRegistry.Get(name)
+			if !ok {
+				http.Error(w, "Unknown experiment", http.StatusNotFound)
+				return
+			}
+			writeResponse(w, r, report)
+			return
+		}
+		writeList(w, r, experimentRegistry.List(), experimentList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var e experiments.Experiment
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateExperiment(r, e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e, err := experimentRegistry.Start(e, time.Now())
+		switch {
+		case errors.Is(err, experiments.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		record(r, audit.Event{Kind: "experiment.started"}, e)
+		writeResponse(w, r, e)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		report, ok := experimentRegistry.Get(name)
+		if !ok || !experimentRegistry.Stop(name) {
+			http.Error(w, "Unknown experiment", http.StatusNotFound)
+			return
+		}
+		record(r, audit.Event{Kind: "experiment.stopped"}, report)
+		writeResponse(w, r, report)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateExperiment checks e's endpoint takes experiments and its variant
+// resolves to a solver that can serve it
+func validateExperiment(r *http.Request, e experiments.Experiment) error {
+	endpoint, ok := experimentEndpoints[e.Endpoint]
+	if !ok {
+		return errors.New("endpoint must be one of " + strings.Join(slices.Sorted(maps.Keys(experimentEndpoints)), ", "))
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	rec := httptest.NewRecorder()
+	if _, _, ok := pickArm(rec, r, endpoint.def, endpoint.need, e.Variant); !ok {
+		return errors.New("variant: " + strings.TrimSpace(rec.Body.String()))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+)
+
+// maxHubs bounds how many hubs one first-mile plan consolidates into
+const maxHubs = 100
+
+// FirstMileHandler plans first-mile milk runs: each pickup goes to the
+// nearest hub that has vehicles, and each hub's vehicles collect their
+// pickups before the cutoffs and bring them in. It is the delivery planner
+// run the other way, one fleet problem per hub.
+func FirstMileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	if !keepBody(w, r) {
+		return
+	}
+	var req models.FirstMileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Hubs {
+		if err := resolveLocation(&req.Hubs[i].Location); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	settings := requestSettings(r)
+	u := requestUnits(settings)
+	req.SpeedKmph = cmp.Or(req.SpeedKmph, settings.SpeedKmph)
+	firstMileToMetric(&req, u)
+	for i := range req.Pickups {
+		req.Pickups[i].ID = cmp.Or(req.Pickups[i].ID, fmt.Sprintf("pickup-%d", i))
+	}
+	if err := validateFirstMileRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fleetSize := 0
+	for _, hub := range req.Hubs {
+		fleetSize += len(hub.Vehicles)
+	}
+	if !withinTenantLimits(w, settings, len(req.Pickups), fleetSize) {
+		return
+	}
+
+	fleets := hubFleets(req)
+	problems := make([]*problem.Problem, len(fleets))
+	need := solver.CapRouting | solver.CapCapacity
+	for i, fleet := range fleets {
+		if len(fleet.Vehicles) == 0 {
+			continue
+		}
+		if err := resolveFleetRequest(&fleet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateFleetRequest(fleet); err != nil {
+			http.Error(w, "Hub "+req.Hubs[i].ID+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fleets[i] = fleet
+		if len(fleet.Stops) == 0 {
+			continue
+		}
+		p := problem.FromFleetRequest(fleet)
+		p.BorderDelays = borderDelays
+		applySpeedFactors(p, fleet.Vehicles)
+		if p.Constraints.TimeWindows {
+			need |= solver.CapTimeWindows
+		}
+		if p.Constraints.Compatibility {
+			need |= solver.CapCompatibility
+		}
+		problems[i] = p
+	}
+
+	s, params, ok := pickSolver(w, r, defaultFleetSolver, need)
+	if !ok {
+		return
+	}
+	if !constraintsEnabled(w, r, problems...) || dryRun(w, r, s, params, problems...) || runAsync(w, r, s, params, problems...) {
+		return
+	}
+	release, ok := admit(w, r, s)
+	if !ok {
+		return
+	}
+	defer release()
+
+	resp := models.FirstMileResponse{Hubs: []models.HubRuns{}, Unassigned: []string{}}
+	var sol problem.Solution
+	for i, p := range problems {
+		hub := models.HubRuns{HubID: req.Hubs[i].ID}
+		if p == nil {
+			hub.FleetResponse = models.FleetResponse{Routes: []models.FleetRoute{}, Unassigned: []string{}}
+			resp.Hubs = append(resp.Hubs, hub)
+			continue
+		}
+		p.SolverParams = params
+		var err error
+		if sol, err = s.Solve(r.Context(), p); err != nil {
+			solveError(w, err)
+			return
+		}
+		hub.FleetResponse = sol.ToFleetResponse(p)
+		addRouteLinks(r, hub.Routes)
+		addFuelEstimates(hub.Routes, fleets[i].Vehicles, 0)
+		report := feasibility.Check(p, sol)
+		hub.Feasibility = &report
+
+		resp.Unassigned = append(resp.Unassigned, hub.Unassigned...)
+		resp.TotalDistKm += hub.TotalDistKm
+		resp.Hubs = append(resp.Hubs, hub)
+	}
+	resp.TotalDistKm = math.Round(resp.TotalDistKm*100) / 100
+	resp.Meta = solveMeta(s, sol)
+	status := deadlineStatus(w, r, resp.Meta)
+
+	var pickups, vehicles []string
+	for _, h := range resp.Hubs {
+		p, v := fleetIDs(h.Routes, nil)
+		pickups, vehicles = append(pickups, p...), append(vehicles, v...)
+	}
+	record(r, audit.Event{Kind: "optimize.first_mile", Shipments: append(pickups, resp.Unassigned...), Vehicles: vehicles}, solveRecord{req, resp})
+
+	km, _ := unitScales(u)
+	for i := range resp.Hubs {
+		fleetFromMetric(&resp.Hubs[i].FleetResponse, u)
+	}
+	resp.TotalDistKm = math.Round(resp.TotalDistKm/km*100) / 100
+	resp.Units = &u
+
+	writeStatus(w, r, status, resp)
+}
+
+func validateFirstMileRequest(req models.FirstMileRequest) error {
+	if len(req.Hubs) == 0 || len(req.Hubs) > maxHubs {
+		return errors.New("Between 1 and 100 hubs are required")
+	}
+	if len(req.Pickups) > maxWaypoints {
+		return errors.New("Too many pickups")
+	}
+	ids := map[string]bool{}
+	staffed := false
+	for _, h := range req.Hubs {
+		if h.ID == "" || ids[h.ID] {
+			return errors.New("Hub IDs must be set and unique")
+		}
+		ids[h.ID] = true
+		if !validLocation(h.Location) {
+			return errors.New("Hubs must be valid coordinates")
+		}
+		staffed = staffed || len(h.Vehicles) > 0
+	}
+	if !staffed {
+		return errors.New("At least one hub needs vehicles")
+	}
+	for _, p := range req.Pickups {
+		if !validLocation(p.Location) {
+			return errors.New("Pickups must be valid coordinates")
+		}
+	}
+	return nil
+}
+
+// hubFleets splits req into a fleet request per hub over the pickups
+// nearest it, among hubs with vehicles
+func hubFleets(req models.FirstMileRequest) []models.FleetRequest {
+	fleets := make([]models.FleetRequest, len(req.Hubs))
+	for i, h := range req.Hubs {
+		fleets[i] = models.FleetRequest{Depot: h.Location, Vehicles: h.Vehicles, Stops: []models.FleetStop{}, SpeedKmph: req.SpeedKmph}
+	}
+	for _, p := range req.Pickups {
+		nearest, best := -1, math.Inf(1)
+		for i, h := range req.Hubs {
+			if d := problem.Haversine(h.Location, p.Location); len(h.Vehicles) > 0 && d < best {
+				nearest, best = i, d
+			}
+		}
+		fleets[nearest].Stops = append(fleets[nearest].Stops, p)
+	}
+	return fleets
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/flags"
+	"milesconnect-optimization/internal/problem"
+	"net/http"
+	"slices"
+)
+
+// featureFlags gate solvers, constraint types and road distances per
+// tenant, to trial them with one customer before everyone gets them
+var featureFlags = flags.New()
+
+// FeatureFlags returns the flags in force, to load them at startup or keep
+// them in step with a remote source
+func FeatureFlags() *flags.Set {
+	return featureFlags
+}
+
+// featureOn reports whether the feature name is on for r's tenant
+func featureOn(r *http.Request, name string) bool {
+	return featureFlags.Allows(name, requestTenant(r))
+}
+
+// constraintTypes names the constraint types p uses, as gated by
+// constraint.<type> flags
+func constraintTypes(p *problem.Problem) []string {
+	var types []string
+	if p.Constraints.Capacity {
+		types = append(types, "capacity")
+	}
+	if p.Constraints.Deadlines {
+		types = append(types, "deadlines")
+	}
+	if p.Constraints.TimeWindows {
+		types = append(types, "time_windows")
+	}
+	if p.Constraints.Compatibility {
+		types = append(types, "compatibility")
+	}
+	if len(p.Curfews) > 0 {
+		types = append(types, "curfews")
+	}
+	if p.Tolls != nil {
+		types = append(types, "tolls")
+	}
+	return types
+}
+
+// constraintsEnabled checks every constraint type problems use is on for
+// r's tenant, writing 403 for the first that is not
+func constraintsEnabled(w http.ResponseWriter, r *http.Request, problems ...*problem.Problem) bool {
+	for _, p := range problems {
+		if p == nil {
+			continue
+		}
+		for _, c := range constraintTypes(p) {
+			if !featureOn(r, flags.ConstraintPrefix+c) {
+				http.Error(w, c+" constraints are not enabled for your tenant", http.StatusForbidden)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// flagStatus is a flag and whether it is on for the caller's tenant
+type flagStatus struct {
+	flags.Flag
+	On bool `json:"on"`
+}
+
+var flagList = listSpec[flagStatus]{
+	key: func(f flagStatus) string { return f.Name },
+}
+
+// FlagsHandler lists the feature flags and whether each is on for the
+// caller's tenant (GET; only admins see which tenants trial a feature),
+// and lets admins save a flag (POST) or drop it (DELETE ?name=). Flags
+// kept in step with a remote source are replaced on its next refresh.
+func FlagsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet && who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins manage feature flags", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list := []flagStatus{}
+		for _, f := range featureFlags.List() {
+			s := flagStatus{Flag: f, On: f.On(who.Tenant)}
+			if who.Role != auth.RoleAdmin {
+				s.Tenants = nil
+			}
+			list = append(list, s)
+		}
+		writeList(w, r, list, flagList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var f flags.Flag
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		f.Tenants = slices.Compact(slices.Sorted(slices.Values(f.Tenants)))
+		if err := featureFlags.Put(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		record(r, audit.Event{Kind: "flag.saved"}, f)
+		writeResponse(w, r, f)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if !featureFlags.Delete(name) {
+			http.Error(w, "Unknown feature flag", http.StatusNotFound)
+			return
+		}
+		record(r, audit.Event{Kind: "flag.deleted"}, map[string]string{"name": name})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
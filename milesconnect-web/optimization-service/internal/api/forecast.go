@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"milesconnect-optimization/internal/forecast"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Forecast request limits
+const (
+	defaultHorizonDays = 7
+	maxHorizonDays     = 90
+	maxHistoryDays     = 3 * 366
+	maxHistoryRows     = 100000
+)
+
+// ForecastHandler projects each lane's daily shipments and weight from its
+// history and sizes the fleet to carry them
+func ForecastHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.ForecastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateForecastRequest(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := forecastDemand(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeResponse(w, r, resp)
+}
+
+func validateForecastRequest(req *models.ForecastRequest) error {
+	if len(req.History) == 0 || len(req.History) > maxHistoryRows {
+		return errors.New("history must have between 1 and 100000 entries")
+	}
+	for _, d := range req.History {
+		if _, err := time.Parse(time.DateOnly, d.Date); err != nil {
+			return errors.New("History dates must be YYYY-MM-DD")
+		}
+		if !finite(d.Shipments, d.WeightKg) || d.Shipments < 0 || d.WeightKg < 0 {
+			return errors.New("Shipments and weight must not be negative")
+		}
+	}
+	switch forecast.Method(req.Method) {
+	case "", forecast.MovingAverage, forecast.HoltWinters:
+	default:
+		return errors.New("method must be moving_average or holt_winters")
+	}
+	if req.HorizonDays == 0 {
+		req.HorizonDays = defaultHorizonDays
+	}
+	if req.WindowDays == 0 {
+		req.WindowDays = 7
+	}
+	if req.SeasonDays == 0 {
+		req.SeasonDays = 7
+	}
+	if req.HorizonDays < 1 || req.HorizonDays > maxHorizonDays {
+		return errors.New("horizon_days must be between 1 and 90")
+	}
+	if req.WindowDays < 1 || req.SeasonDays < 2 {
+		return errors.New("window_days must be positive and season_days at least 2")
+	}
+	if !finite(req.VehicleCapacityKg) || req.VehicleCapacityKg < 0 || req.StopsPerVehicle < 0 {
+		return errors.New("Vehicle capacity and stops per vehicle must not be negative")
+	}
+	return nil
+}
+
+// forecastDemand forecasts each lane separately: lanes differ in level and
+// weekly pattern, and summing first would blur both
+func forecastDemand(req models.ForecastRequest) (models.ForecastResponse, error) {
+	type series struct {
+		first, last time.Time
+		shipments   map[time.Time]float64
+		weight      map[time.Time]float64
+	}
+	lanes := map[string]*series{}
+	for _, d := range req.History {
+		day, _ := time.Parse(time.DateOnly, d.Date)
+		s := lanes[d.Lane]
+		if s == nil {
+			s = &series{first: day, last: day, shipments: map[time.Time]float64{}, weight: map[time.Time]float64{}}
+			lanes[d.Lane] = s
+		}
+		if day.Before(s.first) {
+			s.first = day
+		}
+		if day.After(s.last) {
+			s.last = day
+		}
+		s.shipments[day] += d.Shipments
+		s.weight[day] += d.WeightKg
+	}
+
+	// Every lane is projected from the day after the latest history
+	var end time.Time
+	for _, s := range lanes {
+		if s.last.After(end) {
+			end = s.last
+		}
+	}
+	resp := models.ForecastResponse{Lanes: []models.LaneForecast{}, Total: make([]models.ForecastDay, req.HorizonDays)}
+	for h := range resp.Total {
+		resp.Total[h].Date = end.AddDate(0, 0, h+1).Format(time.DateOnly)
+	}
+
+	for _, lane := range slices.Sorted(maps.Keys(lanes)) {
+		s := lanes[lane]
+		if end.Sub(s.first) > maxHistoryDays*24*time.Hour {
+			return models.ForecastResponse{}, fmt.Errorf("lane %q spans more than three years of history", lane)
+		}
+		var shipments, weight []float64
+		for day := s.first; !day.After(end); day = day.AddDate(0, 0, 1) {
+			shipments = append(shipments, s.shipments[day])
+			weight = append(weight, s.weight[day])
+		}
+
+		method := forecast.Method(req.Method)
+		if method == "" {
+			method = forecast.MovingAverage
+			if len(shipments) >= 2*req.SeasonDays {
+				method = forecast.HoltWinters
+			}
+		}
+		project := func(vals []float64) ([]float64, error) {
+			if method == forecast.HoltWinters {
+				return forecast.Smooth(vals, req.SeasonDays, req.HorizonDays, forecast.DefaultAlpha, forecast.DefaultBeta, forecast.DefaultGamma)
+			}
+			return forecast.Average(vals, min(req.WindowDays, len(vals)), req.HorizonDays)
+		}
+		fs, err := project(shipments)
+		if err != nil {
+			return models.ForecastResponse{}, fmt.Errorf("%w: lane %q has %d days, %s needs %d", err, lane, len(shipments), method, 2*req.SeasonDays)
+		}
+		fw, _ := project(weight)
+
+		lf := models.LaneForecast{Lane: lane, Method: string(method)}
+		for h := range req.HorizonDays {
+			day := models.ForecastDay{
+				Date:      resp.Total[h].Date,
+				Shipments: math.Round(fs[h]*100) / 100,
+				WeightKg:  math.Round(fw[h]*100) / 100,
+				Vehicles:  vehiclesNeeded(fs[h], fw[h], req.VehicleCapacityKg, req.StopsPerVehicle),
+			}
+			lf.Days = append(lf.Days, day)
+			resp.Total[h].Shipments += day.Shipments
+			resp.Total[h].WeightKg += day.WeightKg
+			resp.Total[h].Vehicles += day.Vehicles
+		}
+		resp.Lanes = append(resp.Lanes, lf)
+	}
+	for _, d := range resp.Total {
+		resp.SuggestedFleetSize = max(resp.SuggestedFleetSize, d.Vehicles)
+	}
+	return resp, nil
+}
+
+// vehiclesNeeded is the fleet a day's volume takes, bounded by weight or by
+// how many drops a vehicle makes, whichever binds; 0 when neither is given
+func vehiclesNeeded(shipments, weightKg, capacityKg float64, stopsPerVehicle int) int {
+	n := 0.0
+	if capacityKg > 0 {
+		n = math.Ceil(weightKg / capacityKg)
+	}
+	if stopsPerVehicle > 0 {
+		n = math.Max(n, math.Ceil(shipments/float64(stopsPerVehicle)))
+	}
+	return int(n)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/fuel"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"time"
+)
+
+// fuelWindow is how far back mileage is measured: long enough for several
+// full-to-full stretches, short enough to follow a vehicle as it ages
+const fuelWindow = 90 * 24 * time.Hour
+
+// fuelLog holds odometer readings and fills; OpenFuelLog backs it with a file
+var fuelLog, _ = fuel.Open("")
+
+// OpenFuelLog loads the fuel log at path and appends to it from now on,
+// returning how many vehicles it has fills for
+func OpenFuelLog(path string) (int, error) {
+	l, err := fuel.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	fuelLog = l
+	return len(l.Vehicles()), nil
+}
+
+var fillList = listSpec[fuel.Fill]{
+	key: func(f fuel.Fill) string { return f.VehicleID + "/" + f.At.Format(time.RFC3339Nano) },
+	fields: map[string]listField[fuel.Fill]{
+		"vehicle_id": {value: func(f fuel.Fill) string { return f.VehicleID }},
+		"at": {
+			value:   func(f fuel.Fill) string { return f.At.Format(time.RFC3339) },
+			compare: func(a, b fuel.Fill) int { return a.At.Compare(b.At) },
+		},
+	},
+}
+
+// FuelHandler lists logged odometer readings and fills (GET) or records
+// one or an array of them (POST)
+func FuelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeList(w, r, fuelLog.Fills(""), fillList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var fills []fuel.Fill
+		if bytes.HasPrefix(body, []byte("[")) {
+			if err := json.Unmarshal(body, &fills); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		} else {
+			var f fuel.Fill
+			if err := json.Unmarshal(body, &f); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			fills = []fuel.Fill{f}
+		}
+		for _, f := range fills {
+			if !finite(f.OdometerKm, f.Litres, f.PricePerLitre) {
+				http.Error(w, "Odometer, litres and price must be numbers", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err := fuelLog.Add(fills...)
+		switch {
+		case errors.Is(err, fuel.ErrInvalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, fuel.ErrOdometer):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "Failed to write the fuel log", http.StatusInternalServerError)
+			return
+		}
+		var vehicles []string
+		seen := map[string]bool{}
+		for _, f := range fills {
+			if !seen[f.VehicleID] {
+				seen[f.VehicleID] = true
+				vehicles = append(vehicles, f.VehicleID)
+			}
+		}
+		record(r, audit.Event{Kind: "fuel.logged", Vehicles: vehicles}, map[string]int{"fills": len(fills)})
+		writeStatus(w, r, http.StatusCreated, fills)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// FuelEfficiencyHandler reports each vehicle's measured km per litre since
+// ?since= (default: the last 90 days), or only ?vehicle_id='s. Vehicles
+// without two full tanks in that time are left out.
+func FuelEfficiencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	since := time.Now().Add(-fuelWindow)
+	if v := q.Get("since"); v != "" {
+		d, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "since must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	vehicles := fuelLog.Vehicles()
+	if v := q.Get("vehicle_id"); v != "" {
+		vehicles = []string{v}
+	}
+
+	list := []fuel.Efficiency{}
+	for _, id := range vehicles {
+		if e, ok := fuelLog.Measure(id, since); ok {
+			e.KmPerLitre = math.Round(e.KmPerLitre*100) / 100
+			list = append(list, e)
+		}
+	}
+	writeResponse(w, r, list)
+}
+
+// addFuelEstimates prices each route's fuel at its vehicle's measured
+// mileage, or its rated one when the fuel log has too little to go on.
+// price defaults to the vehicle's latest logged price; routes of vehicles
+// with neither mileage get no estimate.
+func addFuelEstimates(routes []models.FleetRoute, vehicles []models.VehicleInfo, price float64) {
+	rated := map[string]float64{}
+	for _, v := range vehicles {
+		rated[v.ID] = v.KmPerLitre
+	}
+	since := time.Now().Add(-fuelWindow)
+	for i, r := range routes {
+		e, measured := fuelLog.Measure(r.VehicleID, since)
+		est := &models.FuelEstimate{KmPerLitre: e.KmPerLitre, Basis: "measured"}
+		if !measured {
+			if rated[r.VehicleID] <= 0 {
+				continue
+			}
+			est.KmPerLitre, est.Basis = rated[r.VehicleID], "rated"
+		}
+		est.Litres = r.DistanceKm / est.KmPerLitre
+		if p := cmp.Or(price, e.PricePerLitre); p > 0 {
+			est.Cost = math.Round(est.Litres*p*100) / 100
+		}
+		est.Litres = math.Round(est.Litres*100) / 100
+		est.KmPerLitre = math.Round(est.KmPerLitre*100) / 100
+		routes[i].Fuel = est
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"milesconnect-optimization/internal/fixtures"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fuzzHandler feeds arbitrary bodies to h; the only failure is a panic or a
+// 5xx, since bad input must always be rejected with a 4xx
+func fuzzHandler(f *testing.F, h http.HandlerFunc, target string, seeds []any) {
+	for _, s := range seeds {
+		b, _ := json.Marshal(s)
+		f.Add(b)
+	}
+	f.Add([]byte(`{"start":{"lat":"NaN"}}`))
+	f.Add([]byte(`{"vehicles":[{"id":"V","capacity_kg":-5}],"shipments":[{"id":"S","weight_kg":1e308}]}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+		if rec.Code >= 500 {
+			t.Fatalf("status %d for body %q: %s", rec.Code, body, rec.Body)
+		}
+	})
+}
+
+func FuzzOptimizeRoute(f *testing.F) {
+	var seeds []any
+	for _, inst := range fixtures.RouteInstances() {
+		seeds = append(seeds, inst.Request)
+	}
+	fuzzHandler(f, OptimizeRouteHandler, "/optimize", seeds)
+}
+
+func FuzzOptimizeLoad(f *testing.F) {
+	var seeds []any
+	for _, inst := range fixtures.LoadInstances() {
+		seeds = append(seeds, inst.Request)
+	}
+	fuzzHandler(f, OptimizeLoadHandler, "/optimize-load", seeds)
+}
+
+func FuzzValidatePlan(f *testing.F) {
+	var seeds []any
+	for _, inst := range fixtures.LoadInstances() {
+		seeds = append(seeds, map[string]any{"load": inst.Request, "allocations": []any{}})
+	}
+	fuzzHandler(f, ValidatePlanHandler, "/validate-plan", seeds)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/generator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GenerateHandler returns a synthetic instance for load testing and demos.
+// Query: kind=route|load|fleet, size, distribution=random|clustered|grid, clusters, seed.
+// The seed used is echoed in X-Generator-Seed so any instance can be reproduced.
+func GenerateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	cfg := generator.Config{
+		Size:         100,
+		Distribution: generator.Distribution(q.Get("distribution")),
+		Seed:         time.Now().UnixNano(),
+	}
+
+	var err error
+	if v := q.Get("size"); v != "" {
+		if cfg.Size, err = strconv.Atoi(v); err != nil || cfg.Size < 1 || cfg.Size > maxWaypoints {
+			http.Error(w, "size must be between 1 and 5000", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("clusters"); v != "" {
+		if cfg.Clusters, err = strconv.Atoi(v); err != nil || cfg.Clusters < 1 || cfg.Clusters > cfg.Size {
+			http.Error(w, "clusters must be between 1 and size ("+strconv.Itoa(cfg.Size)+")", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("seed"); v != "" {
+		if cfg.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "seed must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("X-Generator-Seed", strconv.FormatInt(cfg.Seed, 10))
+
+	switch q.Get("kind") {
+	case "", "route":
+		req, err := generator.RouteRequest(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeResponse(w, r, req)
+	case "load":
+		writeResponse(w, r, generator.LoadRequest(cfg))
+	case "fleet":
+		req, err := generator.FleetRequest(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeResponse(w, r, req)
+	default:
+		http.Error(w, "kind must be route, load or fleet", http.StatusBadRequest)
+	}
+}
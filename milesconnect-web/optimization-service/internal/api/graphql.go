@@ -0,0 +1,241 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/graphql"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// GraphQLHandler answers GraphQL queries over the dispatch boards, POSTed
+// as JSON or sent with GET ?query=, so a frontend can fetch a board with
+// its runs, stops and vehicles in one round trip:
+//
+//	{ board(date: "2026-10-16") { version runs { vehicle_id stops { id status } vehicle { maintenance { km_since_service } } } } }
+//
+// Fields are named as in the REST responses, and only the caller's
+// tenants' plans are visible. A request that cannot be parsed, or uses
+// more than maxGraphQLAliases aliases, gets a 400; errors resolving fields
+// come back beside the data, as does the error for a response past
+// maxGraphQLNodes fields, whose rest is null. Date ranges span at most
+// maxGraphQLDays.
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		limitBody(w, r)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := graphQLSchema.Execute(context.WithValue(r.Context(), principalKey{}, who), req)
+	status := http.StatusOK
+	if err != nil {
+		resp, status = graphql.Response{Errors: []graphql.Error{{Message: err.Error()}}}, http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Limits on one GraphQL query, which can otherwise ask for a great deal
+// in a few lines
+const (
+	maxGraphQLAliases = 20
+	maxGraphQLNodes   = 50000
+	maxGraphQLDays    = 31
+)
+
+// principalKey carries the caller's principal to the resolvers
+type principalKey struct{}
+
+// boardRun is a run with the date of its board, so its vehicle can be
+// looked up for that day
+type boardRun struct {
+	dispatch.Run
+	date string
+}
+
+// gqlVehicle is a vehicle as the schema shows it
+type gqlVehicle struct {
+	ID string `json:"id"`
+}
+
+var graphQLSchema = func() *graphql.Schema {
+	vehicle := &graphql.Object{Name: "Vehicle"}
+	run := &graphql.Object{Name: "Run"}
+	board := &graphql.Object{Name: "Board"}
+	shipment := &graphql.Object{Name: "Shipment"}
+
+	vehicleOf := &graphql.Field{Type: vehicle, Resolve: func(_ context.Context, src any, _ map[string]any) (any, error) {
+		var id string
+		switch src := src.(type) {
+		case boardRun:
+			id = src.VehicleID
+		case dispatch.Hit:
+			id = src.VehicleID
+		}
+		if id == "" {
+			return nil, nil
+		}
+		return gqlVehicle{id}, nil
+	}}
+
+	board.Fields = map[string]*graphql.Field{
+		"runs": {Args: []string{"vehicle_id", "status"}, Type: run, Resolve: func(_ context.Context, src any, args map[string]any) (any, error) {
+			b := src.(dispatch.Board)
+			vehicleID, _ := args["vehicle_id"].(string)
+			status, _ := args["status"].(string)
+			runs := []boardRun{}
+			for _, r := range b.Runs {
+				if (vehicleID == "" || r.VehicleID == vehicleID) && (status == "" || string(r.Status) == status) {
+					runs = append(runs, boardRun{r, b.Date})
+				}
+			}
+			return runs, nil
+		}},
+	}
+	run.Fields = map[string]*graphql.Field{
+		"vehicle": vehicleOf,
+		"stops": {Args: []string{"status"}, Resolve: func(_ context.Context, src any, args map[string]any) (any, error) {
+			status, _ := args["status"].(string)
+			stops := []dispatch.Stop{}
+			for _, st := range src.(boardRun).Stops {
+				if status == "" || string(st.Status) == status {
+					stops = append(stops, st)
+				}
+			}
+			return stops, nil
+		}},
+	}
+	shipment.Fields = map[string]*graphql.Field{"vehicle": vehicleOf}
+	vehicle.Fields = map[string]*graphql.Field{
+		// The vehicle's maintenance schedule, if the caller's tenant reads it
+		"maintenance": {Resolve: func(ctx context.Context, src any, _ map[string]any) (any, error) {
+			who := ctx.Value(principalKey{}).(auth.Principal)
+			templatesMu.RLock()
+			defer templatesMu.RUnlock()
+			m, ok := maintenance[src.(gqlVehicle).ID]
+			if !ok || !who.Reads(m.Tenant) {
+				return nil, nil
+			}
+			return m, nil
+		}},
+		"runs": {Args: []string{"from", "to"}, Type: run, Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			who := ctx.Value(principalKey{}).(auth.Principal)
+			from, to, err := dateRange(args)
+			if err != nil {
+				return nil, err
+			}
+			runs := []boardRun{}
+			for _, b := range dispatched.Boards(from, to) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				if !who.Reads(b.Tenant) {
+					continue
+				}
+				for _, r := range b.Runs {
+					if r.VehicleID == src.(gqlVehicle).ID {
+						runs = append(runs, boardRun{r, b.Date})
+					}
+				}
+			}
+			return runs, nil
+		}},
+	}
+
+	return &graphql.Schema{MaxAliases: maxGraphQLAliases, MaxNodes: maxGraphQLNodes, Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"board": {Args: []string{"date", "tenant"}, Type: board, Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+			who := ctx.Value(principalKey{}).(auth.Principal)
+			date, _ := args["date"].(string)
+			tenant, _ := args["tenant"].(string)
+			if date == "" {
+				date = time.Now().Format(time.DateOnly)
+			} else if _, err := time.Parse(time.DateOnly, date); err != nil {
+				return nil, errors.New("date must be YYYY-MM-DD")
+			}
+			if b, ok := dispatched.Board(recordTenant(who, tenant), date); ok {
+				return b, nil
+			}
+			return nil, nil
+		}},
+		"boards": {Args: []string{"from", "to"}, Type: board, Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+			who := ctx.Value(principalKey{}).(auth.Principal)
+			from, to, err := dateRange(args)
+			if err != nil {
+				return nil, err
+			}
+			return slices.DeleteFunc(dispatched.Boards(from, to), func(b dispatch.Board) bool { return !who.Reads(b.Tenant) }), nil
+		}},
+		"shipments": {Args: []string{"shipment", "customer", "city", "from", "to", "status"}, Type: shipment, Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+			who := ctx.Value(principalKey{}).(auth.Principal)
+			str := func(name string) string { s, _ := args[name].(string); return s }
+			from, to, err := dateRange(args)
+			if err != nil {
+				return nil, err
+			}
+			hits := findShipments(str("shipment"), str("customer"), str("city"), from, to, who.Reads)
+			kept := hits[:0]
+			for _, h := range hits {
+				if status := str("status"); status == "" || string(h.Status) == status {
+					kept = append(kept, h)
+				}
+			}
+			return kept, nil
+		}},
+		"vehicle": {Args: []string{"id"}, Type: vehicle, Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+			id, _ := args["id"].(string)
+			if id == "" {
+				return nil, errors.New("id is required")
+			}
+			return gqlVehicle{id}, nil
+		}},
+	}}}
+}()
+
+// dateRange reads optional from and to dates, YYYY-MM-DD, which default
+// to each other and, both missing, to today. A range spans at most
+// maxGraphQLDays, so one query cannot walk every plan ever published.
+func dateRange(args map[string]any) (from, to string, err error) {
+	from, _ = args["from"].(string)
+	to, _ = args["to"].(string)
+	from = cmp.Or(from, to, time.Now().Format(time.DateOnly))
+	to = cmp.Or(to, from)
+	f, err1 := time.Parse(time.DateOnly, from)
+	t, err2 := time.Parse(time.DateOnly, to)
+	switch {
+	case err1 != nil || err2 != nil:
+		return "", "", errors.New("dates must be YYYY-MM-DD")
+	case t.Before(f):
+		return "", "", errors.New("to must not be before from")
+	case t.Sub(f) >= maxGraphQLDays*24*time.Hour:
+		return "", "", fmt.Errorf("a date range spans at most %d days", maxGraphQLDays)
+	}
+	return from, to, nil
+}
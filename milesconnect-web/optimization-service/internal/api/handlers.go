@@ -1,12 +1,30 @@
 package api
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/audit"
 	"milesconnect-optimization/internal/data"
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/flags"
 	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/network"
+	"milesconnect-optimization/internal/problem"
 	"milesconnect-optimization/internal/solver"
-	"milesconnect-optimization/internal/solver/genetic"
+	_ "milesconnect-optimization/internal/solver/genetic" // Registers the GA solver
 	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default solver per endpoint, overridable with ?solver=
+const (
+	defaultRouteSolver = "guided-local-search"
+	defaultLoadSolver  = "best-fit-decreasing"
+	defaultFleetSolver = "alns"
+	allIndiaSolver     = "genetic"
 )
 
 func OptimizeRouteHandler(w http.ResponseWriter, r *http.Request) {
@@ -15,16 +33,90 @@ func OptimizeRouteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limitBody(w, r)
+	if !keepBody(w, r) {
+		return
+	}
 	var req models.OptimizationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp := solver.SolveTSPNearestNeighbor(req)
+	p, sol, meta, ok := solveRoute(w, r, &req)
+	if !ok {
+		return
+	}
+	resp := sol.ToRouteResponse(p)
+	resp.Polyline = routePolyline(r, resp.Route)
+	report := feasibility.Check(p, sol)
+	resp.Feasibility = &report
+	resp.Meta = meta
+	status := deadlineStatus(w, r, resp.Meta)
+	record(r, audit.Event{Kind: "optimize.route"}, solveRecord{req, resp})
+
+	u := requestUnits(requestSettings(r))
+	routeFromMetric(&resp, u)
+	resp.Units = &u
+
+	if wantsNDJSON(r) {
+		writeRouteNDJSON(w, resp)
+		return
+	}
+
+	writeStatus(w, r, status, resp)
+}
+
+// solveRoute resolves, validates and solves a route request for every
+// version of /optimize. On failure it writes the error and returns false.
+func solveRoute(w http.ResponseWriter, r *http.Request, req *models.OptimizationRequest) (*problem.Problem, problem.Solution, *models.SolveMeta, bool) {
+	routeToMetric(req, requestUnits(requestSettings(r)))
+	if err := resolveRouteRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, problem.Solution{}, nil, false
+	}
+	if err := validateRouteRequest(*req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, problem.Solution{}, nil, false
+	}
+	if !withinTenantLimits(w, requestSettings(r), len(req.Waypoints), 1) {
+		return nil, problem.Solution{}, nil, false
+	}
+
+	need := solver.CapRouting
+	if req.DistanceMatrix != nil {
+		need |= solver.CapMatrix
+	}
+
+	s, params, ok := pickSolver(w, r, defaultRouteSolver, need)
+	if !ok {
+		return nil, problem.Solution{}, nil, false
+	}
+	s, params, trial := enroll(w, r, defaultRouteSolver, need, s, params)
+	p := problem.FromRouteRequest(*req)
+	if !constraintsEnabled(w, r, p) || dryRun(w, r, s, params, p) || runAsync(w, r, s, params, p) {
+		return nil, problem.Solution{}, nil, false
+	}
+	release, ok := admit(w, r, s)
+	if !ok {
+		return nil, problem.Solution{}, nil, false
+	}
+	defer release()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	p.Batch = r.URL.Query().Get("mode") == "batch"
+	p.SolverParams = params
+	distances := applyRoadDistances(r, p, s)
+	start := time.Now()
+	sol, err := s.Solve(r.Context(), p)
+	trial.observe(r, p, sol, err, time.Since(start))
+	if err != nil {
+		solveError(w, err)
+		return nil, problem.Solution{}, nil, false
+	}
+	meta := solveMeta(s, sol)
+	meta.Distances = distances
+	meta.Experiment = trial.label()
+	return p, sol, meta, true
 }
 
 func OptimizeLoadHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,24 +125,192 @@ func OptimizeLoadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limitBody(w, r)
+	if !keepBody(w, r) {
+		return
+	}
 	var req models.LoadRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validation: Ensure valid weights
-	for _, s := range req.Shipments {
-		if s.WeightKg <= 0 {
-			http.Error(w, "Shipment weight must be positive", http.StatusBadRequest)
+	u := requestUnits(requestSettings(r))
+	loadToMetric(&req, u)
+	if err := validateLoadRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !withinTenantLimits(w, requestSettings(r), len(req.Shipments), len(req.Vehicles)) {
+		return
+	}
+
+	p := problem.FromLoadRequest(req)
+	need := solver.CapAllocation
+	if p.Constraints.Compatibility {
+		need |= solver.CapCompatibility
+	}
+	s, params, ok := pickSolver(w, r, defaultLoadSolver, need)
+	if !ok {
+		return
+	}
+	s, params, trial := enroll(w, r, defaultLoadSolver, need, s, params)
+	if !constraintsEnabled(w, r, p) || dryRun(w, r, s, params, p) || runAsync(w, r, s, params, p) {
+		return
+	}
+	release, ok := admit(w, r, s)
+	if !ok {
+		return
+	}
+	defer release()
+
+	p.SolverParams = params
+	start := time.Now()
+	sol, err := s.Solve(r.Context(), p)
+	trial.observe(r, p, sol, err, time.Since(start))
+	if err != nil {
+		solveError(w, err)
+		return
+	}
+
+	resp := sol.ToLoadResponse(p)
+	report := feasibility.Check(p, sol)
+	resp.Feasibility = &report
+	resp.Meta = solveMeta(s, sol)
+	resp.Meta.Experiment = trial.label()
+
+	ev := audit.Event{Kind: "optimize.load", Shipments: resp.Unassigned}
+	for _, a := range resp.Allocations {
+		ev.Vehicles = append(ev.Vehicles, a.VehicleID)
+		ev.Shipments = append(ev.Shipments, a.ShipmentIDs...)
+	}
+	status := deadlineStatus(w, r, resp.Meta)
+	record(r, ev, solveRecord{req, resp})
+
+	loadFromMetric(&resp, u)
+	resp.Units = &u
+
+	writeStatus(w, r, status, resp)
+}
+
+// OptimizeFleetHandler routes several capacitated vehicles from a depot
+func OptimizeFleetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	if !keepBody(w, r) {
+		return
+	}
+	var req models.FleetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	settings := requestSettings(r)
+	u := requestUnits(settings)
+	applyFleetDefaults(&req, settings)
+	fleetToMetric(&req, u)
+
+	if err := resolveFleetRequest(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateFleetRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !withinTenantLimits(w, settings, len(req.Stops), len(req.Vehicles)) {
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+	day, _ := time.Parse(time.DateOnly, date)
+	var unavailable []models.Violation
+	if req.Vehicles, unavailable = availableVehicles(req.Vehicles, day); len(req.Vehicles) == 0 {
+		http.Error(w, "Every vehicle is down for maintenance or due for service on "+date, http.StatusConflict)
+		return
+	}
+
+	p := problem.FromFleetRequest(req)
+	p.BorderDelays = borderDelays
+	applySpeedFactors(p, req.Vehicles)
+	reserveCapacity(p, req.Vehicles, req.ReservePct)
+	overbook(p, req)
+	priceTolls(p, req)
+	applyCurfews(p, req)
+	need := solver.CapRouting | solver.CapCapacity
+	if p.Constraints.TimeWindows {
+		need |= solver.CapTimeWindows
+	}
+	if p.Constraints.Compatibility {
+		need |= solver.CapCompatibility
+	}
+	if req.DistanceMatrix != nil {
+		need |= solver.CapMatrix
+	}
+
+	s, params, ok := pickSolver(w, r, defaultFleetSolver, need)
+	if !ok {
+		return
+	}
+	s, params, trial := enroll(w, r, defaultFleetSolver, need, s, params)
+	if !constraintsEnabled(w, r, p) || dryRun(w, r, s, params, p) || runAsync(w, r, s, params, p) {
+		return
+	}
+	release, ok := admit(w, r, s)
+	if !ok {
+		return
+	}
+	defer release()
+
+	p.Batch = r.URL.Query().Get("mode") == "batch"
+	p.SolverParams = params
+	distances := applyRoadDistances(r, p, s)
+	base := p
+	p = withCrews(base, req.Vehicles, req.Crew, false)
+	seeds := seedFromPool(r, p)
+	start := time.Now()
+	sol, err := s.Solve(r.Context(), p)
+	trial.observe(r, p, sol, err, time.Since(start))
+	if err != nil {
+		solveError(w, err)
+		return
+	}
+	poolPlan(r, p, sol)
+
+	resp := sol.ToFleetResponse(p)
+	if req.CompareCrews {
+		if resp.Crews, err = compareCrews(r.Context(), s, base, req.Vehicles); err != nil {
+			solveError(w, err)
 			return
 		}
 	}
+	addRouteLinks(r, resp.Routes)
+	addFuelEstimates(resp.Routes, req.Vehicles, req.FuelPricePerLitre)
+	addTolls(resp.Routes, req)
+	addCapacityUse(resp.Routes, req.Vehicles, req.ReservePct)
+	addStandby(resp.Routes, req)
+	resp.Outsource = outsource(p, sol, req)
+	report := feasibility.Check(p, sol)
+	report.Violations = append(append(report.Violations, unavailable...), serviceWarnings(resp.Routes)...)
+	resp.Feasibility = &report
+	resp.Meta = solveMeta(s, sol)
+	resp.Meta.Distances = distances
+	resp.Meta.Seeds = seeds
+	resp.Meta.Experiment = trial.label()
+	status := deadlineStatus(w, r, resp.Meta)
 
-	resp := solver.OptimizeFleetAllocation(req)
+	shipments, vehicles := fleetIDs(resp.Routes, resp.Unassigned)
+	record(r, audit.Event{Kind: "optimize.fleet", Shipments: shipments, Vehicles: vehicles}, solveRecord{req, resp})
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	fleetFromMetric(&resp, u)
+	resp.Units = &u
+	writeStatus(w, r, status, resp)
 }
 
 func OptimizeAllIndiaHandler(w http.ResponseWriter, r *http.Request) {
@@ -59,9 +319,23 @@ func OptimizeAllIndiaHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Get All India Data
-	locations := data.GetAllIndiaLocations()
-	start := locations[0]      // Delhi
+	// 1. Get All India Data (or another built-in/loaded dataset)
+	points := data.IndianCities
+	if name := r.URL.Query().Get("dataset"); name != "" {
+		var ok bool
+		points, ok = data.Dataset(name)
+		if !ok || len(points) == 0 {
+			http.Error(w, "Unknown dataset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	s, params, ok := pickSolver(w, r, allIndiaSolver, solver.CapRouting)
+	if !ok {
+		return
+	}
+	locations := data.Locations(points)
+	start := locations[0]      // Delhi (first point of the dataset)
 	end := locations[0]        // Round trip
 	waypoints := locations[1:] // All other cities
 
@@ -70,15 +344,298 @@ func OptimizeAllIndiaHandler(w http.ResponseWriter, r *http.Request) {
 		End:       end,
 		Waypoints: waypoints,
 	}
+	p := problem.FromRouteRequest(req)
+
+	// A hub-and-spoke plan is checked and predicted as the one tour it
+	// splits up
+	if !constraintsEnabled(w, r, p) || dryRun(w, r, s, params, p) || runAsync(w, r, s, params, p) {
+		return
+	}
+	release, ok := admit(w, r, s)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// ?hubs=N switches to the hierarchical hub-and-spoke network plan
+	if v := r.URL.Query().Get("hubs"); v != "" {
+		hubs, err := strconv.Atoi(v)
+		if err != nil || hubs < 1 {
+			http.Error(w, "hubs must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		solve := func(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+			p.SolverParams = params
+			return s.Solve(ctx, p)
+		}
+		plan, err := network.Plan(r.Context(), points, hubs, solve)
+		if err != nil {
+			solveError(w, err)
+			return
+		}
+		record(r, audit.Event{Kind: "optimize.network"}, solveRecord{r.URL.Query(), plan})
+		writeResponse(w, r, plan)
+		return
+	}
 
 	// 2. Solve using Genetic Algorithm
-	resp := genetic.SolveTSPGenetic(req)
+	p.Batch = r.URL.Query().Get("mode") == "batch"
+	p.SolverParams = params
+	sol, err := s.Solve(r.Context(), p)
+	if err != nil {
+		solveError(w, err)
+		return
+	}
+	resp := sol.ToRouteResponse(p)
+	report := feasibility.Check(p, sol)
+	resp.Feasibility = &report
+	resp.Meta = solveMeta(s, sol)
+	status := deadlineStatus(w, r, resp.Meta)
+	record(r, audit.Event{Kind: "optimize.india"}, solveRecord{req, resp})
+
+	if wantsNDJSON(r) {
+		writeRouteNDJSON(w, resp)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	writeStatus(w, r, status, resp)
+}
+
+// DatasetsHandler lists the datasets, or returns one with ?name=
+func DatasetsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		points, ok := data.Dataset(name)
+		if !ok {
+			http.Error(w, "Unknown dataset", http.StatusNotFound)
+			return
+		}
+		writeResponse(w, r, points)
+		return
+	}
+
+	type datasetInfo struct {
+		Name   string `json:"name"`
+		Points int    `json:"points"`
+	}
+	list := []datasetInfo{}
+	for _, name := range data.DatasetNames() {
+		points, _ := data.Dataset(name)
+		list = append(list, datasetInfo{Name: name, Points: len(points)})
+	}
+	writeList(w, r, list, listSpec[datasetInfo]{
+		key: func(d datasetInfo) string { return d.Name },
+		fields: map[string]listField[datasetInfo]{
+			"points": {
+				value:   func(d datasetInfo) string { return strconv.Itoa(d.Points) },
+				compare: func(a, b datasetInfo) int { return cmp.Compare(a.Points, b.Points) },
+			},
+		},
+	})
+}
+
+// ValidatePlanHandler runs the feasibility checker on a submitted plan
+func ValidatePlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.ValidatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var report models.FeasibilityReport
+	switch {
+	case req.Load != nil:
+		if err := validateLoadRequest(*req.Load); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report = feasibility.CheckLoadPlan(*req.Load, req.Allocations)
+	case req.Route != nil:
+		if err := resolveRouteRequest(req.Route); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i := range req.Plan {
+			if err := resolveLocation(&req.Plan[i]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := validateRouteRequest(*req.Route); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report = feasibility.CheckRoutePlan(*req.Route, req.Plan)
+	default:
+		http.Error(w, "Either load or route must be set", http.StatusBadRequest)
+		return
+	}
+
+	writeResponse(w, r, report)
+}
+
+// SolversHandler lists the registered solvers and their capabilities
+func SolversHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type solverInfo struct {
+		Name         string   `json:"name"`
+		Capabilities []string `json:"capabilities"`
+	}
+	list := []solverInfo{}
+	for _, s := range solver.List() {
+		if featureOn(r, flags.SolverPrefix+s.Name()) {
+			list = append(list, solverInfo{Name: s.Name(), Capabilities: s.Capabilities().Names()})
+		}
+	}
+
+	writeList(w, r, list, listSpec[solverInfo]{key: func(s solverInfo) string { return s.Name }})
 }
 
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// solveError maps solver errors to a status: problems the solver cannot take
+// are the caller's fault, anything else is ours
+func solveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, solver.ErrUnsupportedProblem), errors.Is(err, solver.ErrProblemTooLarge):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, solver.ErrNotEnabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, context.DeadlineExceeded):
+		solveErrors.Inc()
+		http.Error(w, "Deadline exceeded before a solution was found", http.StatusGatewayTimeout)
+	default:
+		solveErrors.Inc()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// solveMeta reports the solver that actually ran, which differs from s
+// when s delegates (e.g. auto)
+func solveMeta(s solver.Solver, sol problem.Solution) *models.SolveMeta {
+	meta := &models.SolveMeta{Solver: s.Name(), Reason: sol.Reason}
+	if sol.Solver != "" {
+		meta.Solver = sol.Solver
+	}
+	return meta
+}
+
+// pickSolver resolves ?solver= (or the profile's solver, or the default) and
+// checks it supports the endpoint. A ?profile=, or the tenant's default
+// profile when the request names neither, also supplies solver parameters:
+// an SLA tier's for the endpoint, or a tuned profile's. A tenant's default
+// tuned profile applies only where its solver can run, so one tuned for
+// fleets leaves /optimize and /optimize-load on their own solvers. Under
+// load a request that does not pin its solver runs on the fast tier. On
+// failure it writes the error response and returns false.
+func pickSolver(w http.ResponseWriter, r *http.Request, def string, need solver.Capabilities) (solver.Solver, map[string]float64, bool) {
+	q := r.URL.Query()
+	name := q.Get("solver")
+	pname := requestProfile(r)
+	tenantDefault := !q.Has("profile") && !q.Has("solver")
+	if name == "" {
+		pname = degradeProfile(w, pname, def)
+	}
+
+	var params map[string]float64
+	if tier, ok := lookupTier(pname, def); ok {
+		if name != "" && name != tier.Solver {
+			http.Error(w, "An SLA profile picks its own solver", http.StatusBadRequest)
+			return nil, nil, false
+		}
+		name, params = tier.Solver, tier.Params
+	} else if pname != "" {
+		prof, ok := lookupProfile(pname)
+		switch {
+		case tenantDefault && (!ok || !solverSupports(prof.Solver, need)):
+			// Left on the endpoint's default
+		case !ok:
+			http.Error(w, "Unknown profile", http.StatusBadRequest)
+			return nil, nil, false
+		case name != "" && name != prof.Solver:
+			http.Error(w, "Profile was tuned for a different solver", http.StatusBadRequest)
+			return nil, nil, false
+		default:
+			name, params = prof.Solver, prof.Params
+		}
+	}
+	chosen := name != ""
+	if !chosen {
+		name = def
+	}
+
+	s, ok := solver.Get(name)
+	if !ok {
+		http.Error(w, "Unknown solver", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !featureOn(r, flags.SolverPrefix+name) {
+		if chosen {
+			http.Error(w, "Solver is not enabled for your tenant", http.StatusForbidden)
+			return nil, nil, false
+		}
+		if s, ok = enabledSolver(r, need); !ok {
+			http.Error(w, "No solver for this endpoint is enabled for your tenant", http.StatusForbidden)
+			return nil, nil, false
+		}
+	}
+	if !s.Capabilities().Has(need) {
+		http.Error(w, "Solver does not support this endpoint", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	noteSolver(r, s, params)
+	if s.Name() == "auto" {
+		s = flaggedSolver{s, func(name string) bool { return featureOn(r, flags.SolverPrefix+name) }}
+	}
+	return s, params, true
+}
+
+// enabledSolver stands in for an endpoint default the tenant may not use:
+// auto when it is enabled, since it only delegates to enabled solvers, else
+// the first enabled solver by name that supports need
+func enabledSolver(r *http.Request, need solver.Capabilities) (solver.Solver, bool) {
+	if s, ok := solver.Get("auto"); ok && featureOn(r, flags.SolverPrefix+"auto") {
+		return s, true
+	}
+	for _, s := range solver.List() {
+		if s.Capabilities().Has(need) && featureOn(r, flags.SolverPrefix+s.Name()) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// flaggedSolver runs a delegating solver (auto) with the tenant's solver
+// flags in ctx, so it never hands the problem to one the tenant may not use
+type flaggedSolver struct {
+	solver.Solver
+	allowed func(name string) bool
+}
+
+func (f flaggedSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	return f.Solver.Solve(solver.WithAllowed(ctx, f.allowed), p)
+}
+
+// solverSupports reports whether the solver called name exists and has
+// need
+func solverSupports(name string, need solver.Capabilities) bool {
+	s, ok := solver.Get(name)
+	return ok && s.Capabilities().Has(need)
+}
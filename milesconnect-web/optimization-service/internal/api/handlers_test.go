@@ -0,0 +1,3496 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"milesconnect-optimization/internal/alerts"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/connector"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/elite"
+	"milesconnect-optimization/internal/email"
+	"milesconnect-optimization/internal/events"
+	"milesconnect-optimization/internal/experiments"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/flags"
+	"milesconnect-optimization/internal/fuel"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/graphql"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/notify"
+	"milesconnect-optimization/internal/objstore"
+	"milesconnect-optimization/internal/predict"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"milesconnect-optimization/internal/templates"
+	"milesconnect-optimization/internal/tuning"
+	"milesconnect-optimization/internal/usage"
+	"milesconnect-optimization/internal/xlsx"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// checkGolden compares got against testdata/<name>.golden
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func serve(t *testing.T, h http.HandlerFunc, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(method, target, &buf))
+	return rec
+}
+
+// serveIfMatch is serve for edits of a dispatched plan, based on the
+// version tagged etag
+func serveIfMatch(t *testing.T, h http.HandlerFunc, method, target string, body any, etag string) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(b))
+	req.Header.Set("If-Match", etag)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+// serveAs is serve for a caller holding a planner token for p, signed with
+// "planner-secret"; the test sets that secret
+func serveAs(t *testing.T, p auth.Principal, h http.HandlerFunc, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+auth.Sign([]byte("planner-secret"), p.Subject(), time.Now().Add(time.Hour)))
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestOptimizeRouteGolden(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", inst.Request)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			checkGolden(t, "optimize_"+inst.Name, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestOptimizeLoadGolden(t *testing.T) {
+	for _, inst := range fixtures.LoadInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			rec := serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load", inst.Request)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			checkGolden(t, "optimize_load_"+inst.Name, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestLoadAllocationsExplainThemselves(t *testing.T) {
+	req := models.LoadRequest{
+		Vehicles: []models.VehicleInfo{
+			{ID: "truck-7", CapacityKg: 500, CurrentLoad: 300},
+			{ID: "truck-8", CapacityKg: 1000},
+			{ID: "truck-9", CapacityKg: 200, DepartHours: 6},
+		},
+		Shipments: []models.ShipmentInfo{
+			{ID: "S1", WeightKg: 80, DeadlineHours: 2, LatePenaltyPerHour: 10},
+		},
+	}
+	rec := serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load", req)
+	var resp models.LoadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Allocations) != 1 {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	a := resp.Allocations[0]
+	if a.VehicleID != "truck-7" || len(a.Reasons) != 1 {
+		t.Fatalf("allocation = %+v", a)
+	}
+	why := a.Reasons[0]
+	if why.ShipmentID != "S1" || why.Reason != "tightest_fit" || why.FreeKg != 120 || why.Detail == "" {
+		t.Errorf("reason = %+v", why)
+	}
+	want := []models.RejectedVehicle{
+		{VehicleID: "truck-8", Reason: "looser_fit", FreeKg: 920},
+		{VehicleID: "truck-9", Reason: "late", LatePenalty: 40},
+	}
+	if !reflect.DeepEqual(why.Alternatives, want) {
+		t.Errorf("alternatives = %+v, want %+v", why.Alternatives, want)
+	}
+}
+
+func TestOptimizeFleetServesEveryStop(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 25, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Feasibility == nil || !resp.Feasibility.Feasible {
+		t.Errorf("plan is not feasible: %+v", resp.Feasibility)
+	}
+	served := 0
+	for _, r := range resp.Routes {
+		served += len(r.StopIDs)
+	}
+	if served+len(resp.Unassigned) != len(req.Stops) || len(resp.Unassigned) != 0 {
+		t.Errorf("served %d and left %d of %d stops", served, len(resp.Unassigned), len(req.Stops))
+	}
+}
+
+func TestOptimizeFleetVehicleStartsAtHome(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 10, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := models.Location{Lat: req.Depot.Lat + 0.2, Lng: req.Depot.Lng}
+	req.Vehicles = req.Vehicles[:1]
+	req.Vehicles[0].CapacityKg = 1e6
+	req.Vehicles[0].Start = &home
+
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Routes) != 1 {
+		t.Fatalf("got %d routes, want 1", len(resp.Routes))
+	}
+	route := resp.Routes[0].Route
+	if route[0] != home || route[len(route)-1] != req.Depot {
+		t.Errorf("route runs %v to %v, want home to depot", route[0], route[len(route)-1])
+	}
+	if resp.Feasibility == nil || !resp.Feasibility.Feasible {
+		t.Errorf("plan is not feasible: %+v", resp.Feasibility)
+	}
+}
+
+func TestOptimizeFleetRespectsMaintenance(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 10, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Vehicles = []models.VehicleInfo{{ID: "M1", CapacityKg: 1e6}, {ID: "M2", CapacityKg: 1e6}}
+	req.Date = "2026-10-15"
+
+	for _, m := range []templates.Maintenance{
+		{VehicleID: "M1", Downtime: []templates.Downtime{{From: "2026-10-14", To: "2026-10-16", Reason: "clutch"}}},
+		{VehicleID: "M2", ServiceEveryKm: 5000, KmSinceService: 4999},
+	} {
+		if rec := serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", m); rec.Code != http.StatusOK {
+			t.Fatalf("saving maintenance: status = %d: %s", rec.Code, rec.Body)
+		}
+		t.Cleanup(func() { serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id="+m.VehicleID, nil) })
+	}
+
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Routes) != 1 || resp.Routes[0].VehicleID != "M2" {
+		t.Fatalf("routes = %+v, want only M2 while M1 is down", resp.Routes)
+	}
+	constraints := map[string]string{}
+	for _, v := range resp.Feasibility.Violations {
+		constraints[v.Constraint] = v.VehicleID
+	}
+	if constraints["maintenance"] != "M1" || constraints["service_km"] != "M2" || !resp.Feasibility.Feasible {
+		t.Errorf("feasibility = %+v, want M1 left out and M2 over its service budget", resp.Feasibility)
+	}
+
+	// Once M2 is due as well there is nothing left to plan with
+	serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", templates.Maintenance{VehicleID: "M2", ServiceEveryKm: 5000, KmSinceService: 5000})
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409 with every vehicle unavailable", rec.Code)
+	}
+}
+
+func TestFuelEstimatesUseMeasuredMileage(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 10, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Vehicles = []models.VehicleInfo{{ID: "F1", CapacityKg: 1e6, KmPerLitre: 6}}
+
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if f := resp.Routes[0].Fuel; f == nil || f.Basis != "rated" || f.KmPerLitre != 6 || f.Cost != 0 {
+		t.Errorf("fuel before any fills = %+v, want the rated 6 km/l unpriced", f)
+	}
+
+	// Two full tanks 400 km apart on 100 l measure 4 km/l
+	at := time.Now().Add(-48 * time.Hour)
+	fills := []fuel.Fill{
+		{VehicleID: "F1", At: at, OdometerKm: 5000, Litres: 50, Full: true},
+		{VehicleID: "F1", At: at.Add(24 * time.Hour), OdometerKm: 5400, Litres: 100, Full: true, PricePerLitre: 90},
+	}
+	if rec := serve(t, FuelHandler, http.MethodPost, "/fuel", fills); rec.Code != http.StatusCreated {
+		t.Fatalf("logging fills: status = %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { fuelLog, _ = fuel.Open("") })
+
+	rec = serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	resp = models.FleetResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	route := resp.Routes[0]
+	if f := route.Fuel; f == nil || f.Basis != "measured" || f.KmPerLitre != 4 || math.Abs(f.Cost-route.DistanceKm/4*90) > 0.01 {
+		t.Errorf("fuel = %+v for %.1f km, want 4 km/l at 90 a litre", f, route.DistanceKm)
+	}
+
+	// Odometers only run forwards
+	back := fuel.Fill{VehicleID: "F1", At: at.Add(36 * time.Hour), OdometerKm: 5300}
+	if rec := serve(t, FuelHandler, http.MethodPost, "/fuel", back); rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409 for a reading below the last", rec.Code)
+	}
+}
+
+func TestOverloadCheckFlagsPlannedAndWeighed(t *testing.T) {
+	req := models.OverloadCheckRequest{
+		Vehicles: []models.VehicleWeights{
+			{ID: "T1", Class: "2-axle", UnladenKg: 6500},
+			{ID: "T2", Class: "3-axle", UnladenKg: 9000, GVWKg: 25000}, // Registered below the class limit
+		},
+		Routes: []models.FleetRoute{{VehicleID: "T1", LoadKg: 9000}, {VehicleID: "T2", LoadKg: 16500}},
+		Actual: []models.WeighbridgeReading{{VehicleID: "T1", GrossKg: 19100}},
+	}
+	rec := serve(t, OverloadCheckHandler, http.MethodPost, "/compliance/overload", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var report models.OverloadReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ vehicle, source, status string }{
+		{"T1", "planned", "ok"},
+		{"T2", "planned", "overloaded"},
+		{"T1", "actual", "overloaded"},
+	}
+	if report.Compliant || len(report.Findings) != len(want) {
+		t.Fatalf("report = %+v", report)
+	}
+	for i, w := range want {
+		if f := report.Findings[i]; f.VehicleID != w.vehicle || f.Source != w.source || f.Status != w.status {
+			t.Errorf("finding %d = %+v, want %s %s %s", i, f, w.vehicle, w.source, w.status)
+		}
+	}
+}
+
+func TestBorderDelaysAddToCrossStateLegs(t *testing.T) {
+	SetBorderDelays(problem.BorderDelays{"KA-TN": 1.5, "*": 0.5})
+	t.Cleanup(func() { SetBorderDelays(nil) })
+
+	depot := models.Location{Lat: 12.9716, Lng: 77.5946, State: "KA"} // Bengaluru
+	hosur := models.Location{Lat: 12.7409, Lng: 77.8253, State: "TN"}
+	req := models.FleetRequest{
+		Depot:    depot,
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "HOSUR", Location: hosur, DemandKg: 10},
+			{ID: "LOCAL", Location: depot, DemandKg: 10},
+		},
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	r := resp.Routes[0]
+	if r.BorderDelayHours != 3 {
+		t.Errorf("border delay = %v h, want 1.5 h each way across KA-TN", r.BorderDelayHours)
+	}
+	i := slices.Index(r.StopIDs, "HOSUR")
+	drive := problem.Haversine(depot, hosur) / problem.DefaultSpeedKmph
+	if len(r.ArrivalHours) != 2 || math.Abs(r.ArrivalHours[i]-(drive+1.5)) > 0.01 {
+		t.Errorf("arrival hours = %v for %v, want the 1.5 h wait in HOSUR's ETA", r.ArrivalHours, r.StopIDs)
+	}
+
+	req.Stops[0].Location.State = "tamil nadu"
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for a state that is not a code", rec.Code)
+	}
+}
+
+func TestCostObjectiveAvoidsTolls(t *testing.T) {
+	// Neighbours on the ring depot-1-2-3 are 1 km apart, the rest 2 km, and
+	// the road between stops 1 and 2 is tolled at Rs 10,000
+	dist := [][]float64{{0, 1, 2, 1}, {1, 0, 1, 2}, {2, 1, 0, 1}, {1, 2, 1, 0}}
+	tolls := [][]float64{{0, 0, 0, 0}, {0, 0, 10000, 0}, {0, 10000, 0, 0}, {0, 0, 0, 0}}
+	loc := models.Location{Lat: 12.97, Lng: 77.59}
+	req := models.FleetRequest{
+		Depot:          loc,
+		Vehicles:       []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops:          []models.FleetStop{{ID: "1", Location: loc}, {ID: "2", Location: loc}, {ID: "3", Location: loc}},
+		DistanceMatrix: dist,
+		TollMatrix:     tolls,
+	}
+	solve := func(objective string) models.FleetRoute {
+		t.Helper()
+		req.Objective = objective
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", objective, rec.Code, rec.Body)
+		}
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Routes[0]
+	}
+
+	if r := solve("distance"); r.DistanceKm != 4 || r.TollInr != 10000 {
+		t.Errorf("distance objective: %v km with Rs %v tolls, want the 4 km ring through the toll", r.DistanceKm, r.TollInr)
+	}
+	if r := solve("cost"); r.DistanceKm != 6 || r.TollInr != 0 {
+		t.Errorf("cost objective: %v km with Rs %v tolls (%v), want 6 km toll-free", r.DistanceKm, r.TollInr, r.StopIDs)
+	}
+
+	req.Vehicles[0].Class = "9-axle"
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an unknown toll class", rec.Code)
+	}
+}
+
+func TestCurfewsHoldArrivals(t *testing.T) {
+	// Routes leave at 09:00; heavy trucks may not enter the city until 22:00
+	city := models.Location{Lat: 19.0760, Lng: 72.8777}
+	req := models.FleetRequest{
+		Depot:    models.Location{Lat: 19.2183, Lng: 73.0867}, // Bhiwandi, 27 km out
+		Vehicles: []models.VehicleInfo{{ID: "TRUCK", CapacityKg: 1000, Class: "3-axle"}},
+		Stops:    []models.FleetStop{{ID: "CITY", Location: city, DemandKg: 10}},
+		Curfews: []models.Curfew{{
+			Name: "no-entry", From: "08:00", To: "22:00",
+			Center: &city, RadiusKm: 15, Classes: []string{"2-axle", "3-axle", "4-axle"},
+		}},
+	}
+	solve := func() models.FleetResponse {
+		t.Helper()
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := solve()
+	if got := resp.Routes[0].ArrivalHours; len(got) != 1 || got[0] != 13 {
+		t.Errorf("arrival hours = %v, want 13 (22:00) once the no-entry window lifts", got)
+	}
+	if v := resp.Feasibility.Violations; len(v) != 1 || v[0].Constraint != "curfew" || !v[0].Soft || v[0].NodeID != "CITY" {
+		t.Errorf("violations = %+v, want a soft curfew hold at CITY", v)
+	}
+
+	// An LCV is not held; a night driving ban stops a long drive at 22:00
+	// and resumes it at 06:00
+	req.Vehicles[0].Class = "lcv"
+	req.DistanceMatrix = [][]float64{{0, 700}, {700, 0}}
+	req.Curfews = append(req.Curfews, models.Curfew{Name: "night", From: "22:00", To: "06:00"})
+	if got := solve().Routes[0].ArrivalHours; len(got) != 1 || got[0] != 22 {
+		t.Errorf("arrival hours = %v, want 14 h of driving around an 8 h ban", got)
+	}
+
+	req.Curfews[1].To = "22:00"
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an empty curfew window", rec.Code)
+	}
+}
+
+func TestVehicleTypesDriveAtTheirOwnSpeed(t *testing.T) {
+	loc := models.Location{Lat: 19.07, Lng: 72.87}
+	req := models.FleetRequest{
+		Depot:          loc,
+		Stops:          []models.FleetStop{{ID: "A", Location: loc, DemandKg: 10}},
+		DistanceMatrix: [][]float64{{0, 100}, {100, 0}},
+	}
+	for _, tc := range []struct {
+		vehicle models.VehicleInfo
+		want    float64
+	}{
+		{models.VehicleInfo{ID: "V", CapacityKg: 100, Type: "32ft"}, 2.5},
+		{models.VehicleInfo{ID: "V", CapacityKg: 100, Type: "mini-truck"}, 1.82},
+		{models.VehicleInfo{ID: "V", CapacityKg: 100, Type: "32ft", SpeedFactor: 0.5}, 4},
+	} {
+		req.Vehicles = []models.VehicleInfo{tc.vehicle}
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v: %s", tc.vehicle.Type, err, rec.Body)
+		}
+		if got := resp.Routes[0].ArrivalHours; len(got) != 1 || got[0] != tc.want {
+			t.Errorf("%+v: arrival hours = %v, want %v", tc.vehicle, got, tc.want)
+		}
+	}
+
+	req.Vehicles[0].Type = "hovercraft"
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an unknown vehicle type", rec.Code)
+	}
+}
+
+func TestRelayCrewsComparedOnLongHauls(t *testing.T) {
+	// 1000 km each way is 20 h of driving at the default 50 km/h
+	loc := models.Location{Lat: 28.61, Lng: 77.21}
+	req := models.FleetRequest{
+		Depot:          loc,
+		Vehicles:       []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops:          []models.FleetStop{{ID: "FAR", Location: loc, DemandKg: 10}},
+		DistanceMatrix: [][]float64{{0, 1000}, {1000, 0}},
+		Crew:           "single",
+		CompareCrews:   true,
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single driver drives 8 h, rests 16 h, twice, then the last 4 h
+	if got := resp.Routes[0].ArrivalHours; len(got) != 1 || got[0] != 52 {
+		t.Errorf("single-crew arrival = %v, want 52 h", got)
+	}
+	want := []models.CrewPlan{
+		{Crew: "single", Vehicles: 1, DistanceKm: 2000, FinishHours: 104, RestHours: 64, Feasible: true},
+		{Crew: "relay", Vehicles: 1, DistanceKm: 2000, FinishHours: 56, RestHours: 16, Feasible: true},
+	}
+	if !slices.Equal(resp.Crews, want) {
+		t.Errorf("crews = %+v, want %+v", resp.Crews, want)
+	}
+
+	req.Crew = "solo"
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an unknown crew", rec.Code)
+	}
+}
+
+func TestReserveCapacityLeavesHeadroom(t *testing.T) {
+	loc := models.Location{Lat: 12.97, Lng: 77.59}
+	req := models.FleetRequest{
+		Depot:    loc,
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: loc, DemandKg: 300},
+			{ID: "B", Location: loc, DemandKg: 300},
+			{ID: "C", Location: loc, DemandKg: 300},
+		},
+		ReservePct: 20,
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(resp.Unassigned) != 1 {
+		t.Errorf("unassigned = %v, want one stop left out of the 800 kg available", resp.Unassigned)
+	}
+	want := models.CapacityUse{CapacityKg: 1000, ReservedKg: 200, UsedKg: 600, FreeKg: 200}
+	if c := resp.Routes[0].Capacity; c == nil || *c != want {
+		t.Errorf("capacity = %+v, want %+v", c, want)
+	}
+
+	// The vehicle's own reserve wins over the request's
+	req.Vehicles[0].ReservePct = 10
+	rec = serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	resp = models.FleetResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if c := resp.Routes[0].Capacity; len(resp.Unassigned) != 0 || c == nil || c.ReservedKg != 100 || c.FreeKg != 0 {
+		t.Errorf("with a 10%% reserve: unassigned %v, capacity %+v", resp.Unassigned, c)
+	}
+}
+
+func TestOverbookingListsStandbyStops(t *testing.T) {
+	loc := models.Location{Lat: 12.97, Lng: 77.59}
+	req := models.FleetRequest{
+		Depot:    loc,
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: loc, DemandKg: 300},
+			{ID: "B", Location: loc, DemandKg: 300},
+			{ID: "C", Location: loc, DemandKg: 300},
+			{ID: "D", Location: loc, DemandKg: 300, NoShowRate: 0.4},
+		},
+		Overbook:   true,
+		NoShowRate: 0.2,
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	// A quarter of the demand is expected to drop out, so 1000 kg plans as 1333
+	if len(resp.Unassigned) != 0 || len(resp.Routes) != 1 || len(resp.Routes[0].StopIDs) != 4 {
+		t.Fatalf("unassigned %v, routes %+v: want all four stops planned", resp.Unassigned, resp.Routes)
+	}
+	r := resp.Routes[0]
+	if len(r.StandbyStopIDs) != 1 || r.StandbyStopIDs[0] != r.StopIDs[3] {
+		t.Errorf("standby = %v, want the last stop of %v", r.StandbyStopIDs, r.StopIDs)
+	}
+
+	req.Overbook = false
+	rec = serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	resp = models.FleetResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Unassigned) != 1 || resp.Routes[0].StandbyStopIDs != nil {
+		t.Errorf("without overbooking: unassigned %v, standby %v", resp.Unassigned, resp.Routes[0].StandbyStopIDs)
+	}
+
+	req.NoShowRate = 0.6
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("no-show rate 0.6: status %d, want 400", rec.Code)
+	}
+}
+
+func TestPlanningSessionPlansSubmissionsTogether(t *testing.T) {
+	loc := models.Location{Lat: 12.97, Lng: 77.59}
+	open := models.PlanningSession{
+		Name:   "morning",
+		Cutoff: time.Now().Add(time.Hour),
+		Fleet:  models.FleetRequest{Depot: loc, Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}}},
+	}
+	if rec := serve(t, SessionsHandler, http.MethodPost, "/sessions", open); rec.Code != http.StatusCreated {
+		t.Fatalf("open: status %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { delete(planningSessions, "morning") })
+
+	for client, id := range map[string]string{"acme": "A", "globex": "B"} {
+		sub := models.SessionSubmission{Client: client, Stops: []models.FleetStop{{ID: id, Location: loc, DemandKg: 400}}}
+		if rec := serve(t, SessionShipmentsHandler, http.MethodPost, "/sessions/shipments?name=morning", sub); rec.Code != http.StatusOK {
+			t.Fatalf("submit %s: status %d: %s", client, rec.Code, rec.Body)
+		}
+	}
+	dup := models.SessionSubmission{Client: "acme", Stops: []models.FleetStop{{ID: "A", Location: loc, DemandKg: 1}}}
+	if rec := serve(t, SessionShipmentsHandler, http.MethodPost, "/sessions/shipments?name=morning", dup); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate stop: status %d, want 409", rec.Code)
+	}
+	if rec := serve(t, SessionPlanHandler, http.MethodPost, "/sessions/plan?name=morning", nil); rec.Code != http.StatusConflict {
+		t.Errorf("plan before cutoff: status %d, want 409", rec.Code)
+	}
+
+	planningSessions["morning"].Cutoff = time.Now()
+	late := models.SessionSubmission{Client: "initech", Stops: []models.FleetStop{{ID: "C", Location: loc, DemandKg: 1}}}
+	if rec := serve(t, SessionShipmentsHandler, http.MethodPost, "/sessions/shipments?name=morning", late); rec.Code != http.StatusConflict {
+		t.Errorf("submission after cutoff: status %d, want 409", rec.Code)
+	}
+	rec := serve(t, SessionPlanHandler, http.MethodPost, "/sessions/plan?name=morning", nil)
+	var s models.PlanningSession
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if s.Status != "planned" || s.Plan == nil || len(s.Plan.Routes) != 1 || len(s.Plan.Routes[0].StopIDs) != 2 {
+		t.Fatalf("session = %+v, want both clients' stops on one vehicle", s)
+	}
+}
+
+func TestFirstMileConsolidatesPickupsAtNearestHub(t *testing.T) {
+	west, east := models.Location{Lat: 19.07, Lng: 72.88}, models.Location{Lat: 19.07, Lng: 73.3}
+	req := models.FirstMileRequest{
+		Hubs: []models.Hub{
+			{ID: "west", Location: west, Vehicles: []models.VehicleInfo{{ID: "W1", CapacityKg: 500}}},
+			{ID: "east", Location: east, Vehicles: []models.VehicleInfo{{ID: "E1", CapacityKg: 500}}},
+			{ID: "idle", Location: models.Location{Lat: 19.07, Lng: 72.9}}, // No vehicles: its pickups go west
+		},
+		Pickups: []models.FleetStop{
+			{ID: "P1", Location: models.Location{Lat: 19.1, Lng: 72.9}, DemandKg: 100, DueHours: 4},
+			{ID: "P2", Location: models.Location{Lat: 19.05, Lng: 72.91}, DemandKg: 100, DueHours: 4},
+			{ID: "P3", Location: models.Location{Lat: 19.1, Lng: 73.28}, DemandKg: 100, DueHours: 4},
+			{ID: "P4", Location: models.Location{Lat: 19.0, Lng: 73.3}, DemandKg: 100, DueHours: 0.01}, // Cutoff already past
+		},
+	}
+	rec := serve(t, FirstMileHandler, http.MethodPost, "/optimize-first-mile", req)
+	var resp models.FirstMileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(resp.Hubs) != 3 {
+		t.Fatalf("hubs = %+v", resp.Hubs)
+	}
+	picked := func(h models.HubRuns) []string {
+		var ids []string
+		for _, r := range h.Routes {
+			ids = append(ids, r.StopIDs...)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got := picked(resp.Hubs[0]); !slices.Equal(got, []string{"P1", "P2"}) {
+		t.Errorf("west picks up %v, want P1 and P2", got)
+	}
+	if got := picked(resp.Hubs[1]); !slices.Equal(got, []string{"P3"}) {
+		t.Errorf("east picks up %v, want P3", got)
+	}
+	if len(resp.Hubs[2].Routes) != 0 || !slices.Equal(resp.Unassigned, []string{"P4"}) {
+		t.Errorf("idle hub %+v, unassigned %v: want P4 missed", resp.Hubs[2], resp.Unassigned)
+	}
+	for _, r := range resp.Hubs[0].Routes {
+		if first, last := r.Route[0], r.Route[len(r.Route)-1]; first != west || last != west {
+			t.Errorf("west route %+v does not start and end at the hub", r.Route)
+		}
+	}
+
+	req.Hubs = req.Hubs[2:]
+	if rec := serve(t, FirstMileHandler, http.MethodPost, "/optimize-first-mile", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("no vehicles anywhere: status %d, want 400", rec.Code)
+	}
+}
+
+func TestReturnsRideInRoomFreedByDeliveries(t *testing.T) {
+	depot := models.Location{Lat: 12.97, Lng: 77.59}
+	req := models.FleetRequest{
+		Depot:    depot,
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: models.Location{Lat: 12.97, Lng: 77.79}, DemandKg: 600},
+			{ID: "R", Location: models.Location{Lat: 12.97, Lng: 77.69}, ReturnKg: 500},
+			{ID: "BIG", Location: models.Location{Lat: 12.97, Lng: 77.69}, ReturnKg: 1200},
+		},
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	// 600 kg out and 500 back fit in 1000 kg only if A is unloaded first
+	if len(resp.Routes) != 1 || !slices.Equal(resp.Routes[0].StopIDs, []string{"A", "R"}) {
+		t.Fatalf("routes = %+v, want A then R", resp.Routes)
+	}
+	if r := resp.Routes[0]; r.LoadKg != 600 || r.ReturnKg != 500 || r.PeakLoadKg != 600 {
+		t.Errorf("load %v, returns %v, peak %v: want 600, 500, 600", r.LoadKg, r.ReturnKg, r.PeakLoadKg)
+	}
+	if !slices.Equal(resp.Unassigned, []string{"BIG"}) || !resp.Feasibility.Feasible {
+		t.Errorf("unassigned %v, feasibility %+v: want only BIG left out", resp.Unassigned, resp.Feasibility)
+	}
+}
+
+func TestOutsourceSuggestsCarriers(t *testing.T) {
+	depot := models.Location{Lat: 12.97, Lng: 77.59}
+	near := models.Location{Lat: 12.98, Lng: 77.6}
+	req := models.FleetRequest{
+		Depot:    depot,
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: near, DemandKg: 600},
+			{ID: "B", Location: near, DemandKg: 600},
+			{ID: "FAR", Location: models.Location{Lat: 13.97, Lng: 77.59}, DemandKg: 100},
+		},
+		Carriers: []models.Carrier{
+			{Name: "local", BaseInr: 500, PerKgInr: 1, PerKmInr: 2},
+			{Name: "parcel", BaseInr: 100, PerKgInr: 1, MaxKg: 50}, // Too small for any of them
+		},
+	}
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(resp.Unassigned) != 1 || len(resp.Outsource) != 2 {
+		t.Fatalf("unassigned %v, outsource %+v: want the left-out stop and FAR", resp.Unassigned, resp.Outsource)
+	}
+	left, far := resp.Outsource[0], resp.Outsource[1]
+	if left.StopID != resp.Unassigned[0] || left.Reason != "no_capacity" || left.Carrier != "local" || left.CarrierInr < 1100 {
+		t.Errorf("unassigned suggestion = %+v", left)
+	}
+	if far.StopID != "FAR" || far.Reason != "cheaper" || far.SavingInr <= 0 || math.Abs(far.InternalInr-far.CarrierInr-far.SavingInr) > 0.02 {
+		t.Errorf("FAR suggestion = %+v", far)
+	}
+
+	req.Carriers = nil
+	rec = serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	resp = models.FleetResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outsource != nil {
+		t.Errorf("without carriers: outsource %+v", resp.Outsource)
+	}
+}
+
+func TestFleetPlansInConfiguredUnits(t *testing.T) {
+	req := models.FleetRequest{
+		Depot:    models.Location{Lat: 12.97, Lng: 77.59},
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: models.Location{Lat: 13.07, Lng: 77.59}, DemandKg: 400},
+			{ID: "B", Location: models.Location{Lat: 12.97, Lng: 77.69}, DemandKg: 500},
+		},
+	}
+	plan := func() models.FleetResponse {
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body)
+		}
+		return resp
+	}
+	metric := plan()
+	if metric.Units == nil || *metric.Units != (models.Units{Currency: "INR", Distance: "km", Weight: "kg"}) {
+		t.Fatalf("default units = %+v", metric.Units)
+	}
+
+	imperial := models.Units{Currency: "USD", Distance: "mi", Weight: "lb"}
+	if err := SetUnits(imperial); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetUnits(models.Units{Currency: "INR", Distance: "km", Weight: "kg"}) })
+	// The same loads in pounds; a 1,000 lb vehicle could not take both
+	req.Vehicles[0].CapacityKg = 2205
+	req.Stops[0].DemandKg, req.Stops[1].DemandKg = 882, 1102
+	resp := plan()
+	if resp.Units == nil || *resp.Units != imperial {
+		t.Fatalf("units = %+v, want %+v", resp.Units, imperial)
+	}
+	if len(resp.Unassigned) != 0 || len(resp.Routes) != 1 || resp.Routes[0].LoadKg != 1984 {
+		t.Fatalf("routes %+v, unassigned %v: want one route carrying 1984 lb", resp.Routes, resp.Unassigned)
+	}
+	if want := metric.TotalDistKm / 1.609344; math.Abs(resp.TotalDistKm-want) > 0.02 {
+		t.Errorf("total distance = %v mi, want %v", resp.TotalDistKm, want)
+	}
+
+	if err := SetUnits(models.Units{Currency: "USD", Distance: "furlong", Weight: "lb"}); err == nil {
+		t.Error("furlongs accepted")
+	}
+}
+
+func TestRouteAndLoadInConfiguredUnits(t *testing.T) {
+	imperial := models.Units{Currency: "USD", Distance: "mi", Weight: "lb"}
+	if err := SetUnits(imperial); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetUnits(models.Units{Currency: "INR", Distance: "km", Weight: "kg"}) })
+
+	// A 10 mi matrix leg is 16.09 km to the solver, and 10 mi again out
+	route := models.OptimizationRequest{
+		Start: models.Location{Lat: 1}, End: models.Location{Lat: 2}, Waypoints: []models.Location{{Lat: 3}},
+		DistanceMatrix: [][]float64{{0, 10, 10}, {10, 0, 10}, {10, 10, 0}},
+	}
+	rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", route)
+	var rr models.OptimizationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &rr); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if rr.TotalDistKm != 20 || rr.Units == nil || *rr.Units != imperial {
+		t.Errorf("route = %v %+v, want 20 mi", rr.TotalDistKm, rr.Units)
+	}
+
+	// 1,500 lb fits a 1,000 kg truck, but not a 1,000 lb one
+	load := models.LoadRequest{
+		Vehicles:  []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+		Shipments: []models.ShipmentInfo{{ID: "A", WeightKg: 600}, {ID: "B", WeightKg: 900}},
+	}
+	rec = serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load", load)
+	var lr models.LoadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &lr); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(lr.Unassigned) != 1 || len(lr.Allocations) != 1 || lr.Allocations[0].TotalWeight != 900 || lr.Units == nil {
+		t.Errorf("load = %+v, want B alone, 900 lb", lr)
+	}
+}
+
+func TestLocalizeTranslatesErrors(t *testing.T) {
+	h := Localize(http.HandlerFunc(OptimizeFleetHandler))
+	get := func(lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/optimize-fleet", nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("hi-IN,hi;q=0.9,en;q=0.8")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Body.String() != "यह विधि अनुमत नहीं है\n" || rec.Header().Get("Content-Language") != "hi" {
+		t.Errorf("Hindi = %d %q, language %q", rec.Code, rec.Body, rec.Header().Get("Content-Language"))
+	}
+	if rec := get("en"); rec.Body.String() != "Method not allowed\n" {
+		t.Errorf("English = %q", rec.Body)
+	}
+
+	if err := SetLanguage("hi"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetLanguage("en") })
+	if rec := get(""); rec.Body.String() != "यह विधि अनुमत नहीं है\n" {
+		t.Errorf("deployment default = %q", rec.Body)
+	}
+	if SetLanguage("fr") == nil {
+		t.Error("fr accepted")
+	}
+}
+
+func TestCredentialsAreMasked(t *testing.T) {
+	SetCredentials([]models.Credential{{Name: "SHARE_TOKEN_SECRET", Masked: "****-key", Sealed: true, KeyID: "a", Rotate: true}})
+	t.Cleanup(func() { SetCredentials(nil) })
+	rec := serve(t, CredentialsHandler, http.MethodGet, "/credentials", nil)
+	var list []models.Credential
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(list) != 1 || list[0].Masked != "****-key" || !list[0].Rotate {
+		t.Errorf("credentials = %+v", list)
+	}
+
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	for role, want := range map[string]int{"": http.StatusUnauthorized, auth.RolePlanner: http.StatusForbidden, auth.RoleAdmin: http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/credentials", nil)
+		if role != "" {
+			token := auth.Sign([]byte("planner-secret"), auth.Principal{Tenant: "acme", Role: role}.Subject(), time.Now().Add(time.Hour))
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		CredentialsHandler(rec, req)
+		if rec.Code != want {
+			t.Errorf("role %q: status %d, want %d", role, rec.Code, want)
+		}
+	}
+}
+
+func TestPlannersOnlySeeTheirTenant(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	as := func(tenant, role string, method, target string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, target, &buf)
+		if tenant != "" {
+			token := auth.Sign([]byte("planner-secret"), auth.Principal{Tenant: tenant, Role: role}.Subject(), time.Now().Add(time.Hour))
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		MaintenanceHandler(rec, req)
+		return rec
+	}
+	ids := func(rec *httptest.ResponseRecorder) []string {
+		var list []templates.Maintenance
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body)
+		}
+		var out []string
+		for _, m := range list {
+			out = append(out, m.Tenant+"/"+m.VehicleID)
+		}
+		return out
+	}
+
+	if rec := as("", "", http.MethodGet, "/maintenance", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: %d", rec.Code)
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		m := templates.Maintenance{VehicleID: tenant + "-V1", ServiceEveryKm: 5000, Tenant: "someone-else"}
+		if rec := as(tenant, auth.RolePlanner, http.MethodPost, "/maintenance", m); rec.Code != http.StatusOK {
+			t.Fatalf("%s save: %d %s", tenant, rec.Code, rec.Body)
+		}
+	}
+	t.Cleanup(func() {
+		as("ops", auth.RoleAdmin, http.MethodDelete, "/maintenance?vehicle_id=acme-V1", nil)
+		as("ops", auth.RoleAdmin, http.MethodDelete, "/maintenance?vehicle_id=globex-V1", nil)
+	})
+
+	if got := ids(as("acme", auth.RoleViewer, http.MethodGet, "/maintenance", nil)); !slices.Equal(got, []string{"acme/acme-V1"}) {
+		t.Errorf("acme sees %v", got)
+	}
+	if got := ids(as("ops", auth.RoleAdmin, http.MethodGet, "/maintenance", nil)); !slices.Equal(got, []string{"acme/acme-V1", "globex/globex-V1"}) {
+		t.Errorf("admin sees %v", got)
+	}
+	if rec := as("globex", auth.RolePlanner, http.MethodDelete, "/maintenance?vehicle_id=acme-V1", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("globex deleting acme's vehicle: %d", rec.Code)
+	}
+	if rec := as("globex", auth.RolePlanner, http.MethodPost, "/maintenance", templates.Maintenance{VehicleID: "acme-V1"}); rec.Code != http.StatusConflict {
+		t.Errorf("globex overwriting acme's vehicle: %d", rec.Code)
+	}
+	if rec := as("acme", auth.RoleViewer, http.MethodDelete, "/maintenance?vehicle_id=acme-V1", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("acme viewer deleting: %d", rec.Code)
+	}
+}
+
+func TestBackupExportsAndRestores(t *testing.T) {
+	m := templates.Maintenance{VehicleID: "BK1", ServiceEveryKm: 8000, KmSinceService: 1200}
+	if rec := serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", m); rec.Code != http.StatusOK {
+		t.Fatalf("save: %d %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=BK1", nil) })
+
+	rec := serve(t, BackupHandler, http.MethodGet, "/backup?format=ndjson", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("export: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	bundle := rec.Body.String()
+	if !strings.Contains(bundle, `"kind":"maintenance","record":{"vehicle_id":"BK1"`) {
+		t.Fatalf("export lacks BK1:\n%s", bundle)
+	}
+
+	serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=BK1", nil)
+	// A bundle with one bad record stores nothing
+	bad := bundle + `{"kind":"maintenance","record":{"vehicle_id":"BK2","service_every_km":-1}}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/backup", strings.NewReader(bad))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	rec = httptest.NewRecorder()
+	BackupHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad bundle: %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := maintenance["BK1"]; ok {
+		t.Fatal("bad bundle was partly imported")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/backup", strings.NewReader(bundle))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	rec = httptest.NewRecorder()
+	BackupHandler(rec, req)
+	var counts models.BackupImport
+	if err := json.Unmarshal(rec.Body.Bytes(), &counts); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("import: %d %s", rec.Code, rec.Body)
+	}
+	if got := maintenance["BK1"]; counts.Maintenance == 0 || got.KmSinceService != 1200 {
+		t.Errorf("restored %+v, counts %+v", got, counts)
+	}
+}
+
+func TestDeletedRecordsAreRestorable(t *testing.T) {
+	m := templates.Maintenance{VehicleID: "TR1", ServiceEveryKm: 6000, KmSinceService: 900}
+	serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", m)
+	t.Cleanup(func() {
+		serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=TR1", nil)
+		serve(t, TrashHandler, http.MethodDelete, "/trash?kind=maintenance&id=TR1", nil)
+	})
+	if rec := serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=TR1", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+
+	rec := serve(t, TrashHandler, http.MethodGet, "/trash?kind=maintenance", nil)
+	if !strings.Contains(rec.Body.String(), `"id":"TR1"`) {
+		t.Fatalf("trash lacks TR1: %s", rec.Body)
+	}
+
+	// The name was reused since, so restoring would overwrite it
+	serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", templates.Maintenance{VehicleID: "TR1", ServiceEveryKm: 5000})
+	if rec := serve(t, TrashRestoreHandler, http.MethodPost, "/trash/restore?kind=maintenance&id=TR1", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("restore over a live record: %d %s", rec.Code, rec.Body)
+	}
+
+	templatesMu.Lock()
+	delete(maintenance, "TR1")
+	templatesMu.Unlock()
+	if rec := serve(t, TrashRestoreHandler, http.MethodPost, "/trash/restore?kind=maintenance&id=TR1", nil); rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body)
+	}
+	if got := maintenance["TR1"]; got.KmSinceService != 900 {
+		t.Errorf("restored %+v", got)
+	}
+	if rec := serve(t, TrashRestoreHandler, http.MethodPost, "/trash/restore?kind=maintenance&id=TR1", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("restoring twice: %d", rec.Code)
+	}
+}
+
+func TestOptimizeFleetDeadlineReturnsBestEffort(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 25, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/optimize-fleet", bytes.NewReader(body))
+	httpReq.Header.Set(TimeoutHeader, "1ns") // Expires before ALNS improves on its first plan
+	rec := httptest.NewRecorder()
+	Deadlines(http.HandlerFunc(OptimizeFleetHandler)).ServeHTTP(rec, httpReq)
+
+	if rec.Code != http.StatusGatewayTimeout || rec.Header().Get(PartialHeader) != "true" {
+		t.Fatalf("status = %d, partial = %q: %s", rec.Code, rec.Header().Get(PartialHeader), rec.Body)
+	}
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Meta == nil || !resp.Meta.Partial || len(resp.Routes) == 0 {
+		t.Errorf("want a best-effort plan marked partial, got meta %+v with %d routes", resp.Meta, len(resp.Routes))
+	}
+}
+
+func TestSLAProfilesPickSolverAndBudget(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 12, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for profile, want := range map[string]string{"fast": "alns", "best": "alns"} {
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?profile="+profile, req)
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", profile, rec.Code, rec.Body)
+		}
+		if resp.Meta == nil || resp.Meta.Solver != want {
+			t.Errorf("%s: meta = %+v, want %s", profile, resp.Meta, want)
+		}
+	}
+	route := fixtures.RouteInstances()[0].Request
+	rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize?profile=fast", route)
+	var resp models.OptimizationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Meta == nil || resp.Meta.Solver != "two-opt" {
+		t.Errorf("fast route: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize?profile=fast&solver=exact", route); rec.Code != http.StatusBadRequest {
+		t.Errorf("tier with another solver: status = %d", rec.Code)
+	}
+
+	// The fast tier's budget shortens the endpoint's deadline
+	var deadline time.Time
+	probe := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { deadline, _ = r.Context().Deadline() })
+	Deadlines(probe).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/optimize-fleet?profile=fast", nil))
+	if left := time.Until(deadline); left <= 0 || left > slaBudgets[tierFast] {
+		t.Errorf("fast deadline in %v", left)
+	}
+
+	rec = serve(t, SLAProfilesHandler, http.MethodGet, "/profiles/sla", nil)
+	var tiers []struct {
+		Name      string
+		Endpoints map[string]slaTier
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tiers); err != nil || len(tiers) != 3 || tiers[2].Endpoints["/optimize-india"].Solver != "memetic" {
+		t.Errorf("tiers: %v: %s", err, rec.Body)
+	}
+}
+
+func TestTenantSettingsApplyAsDefaults(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 12, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := templates.TenantSettings{
+		Profile:  tierFast,
+		MaxStops: len(req.Stops) - 1,
+		Units:    &models.Units{Currency: "USD", Distance: "mi", Weight: "lb"},
+	}
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", settings); rec.Code != http.StatusOK {
+		t.Fatalf("saving settings: status = %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { serve(t, TenantSettingsHandler, http.MethodDelete, "/tenants/settings?tenant=", nil) })
+
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("over the stop limit: status = %d", rec.Code)
+	}
+	req.Stops = req.Stops[:settings.MaxStops]
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", req)
+	var resp models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if resp.Units == nil || resp.Units.Distance != "mi" {
+		t.Errorf("units = %+v, want the tenant's", resp.Units)
+	}
+
+	// A request naming its own solver overrides the tenant's profile
+	var deadline time.Time
+	probe := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { deadline, _ = r.Context().Deadline() })
+	Deadlines(probe).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/optimize-fleet", nil))
+	if left := time.Until(deadline); left <= 0 || left > slaBudgets[tierFast] {
+		t.Errorf("tenant default deadline in %v", left)
+	}
+	Deadlines(probe).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/optimize-fleet?solver=alns", nil))
+	if left := time.Until(deadline); left <= slaBudgets[tierFast] {
+		t.Errorf("deadline with ?solver= in %v", left)
+	}
+
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", templates.TenantSettings{Profile: "no-such-profile"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown profile: status = %d", rec.Code)
+	}
+
+	// A default profile tuned for fleets leaves routes on their own solver,
+	// and the tenant's cost per km prices its scenarios
+	profilesMu.Lock()
+	profiles["fleet-tuned"] = tuning.Profile{Name: "fleet-tuned", Solver: "alns", Params: map[string]float64{"alns_iterations": 100}}
+	profilesMu.Unlock()
+	t.Cleanup(func() { profilesMu.Lock(); delete(profiles, "fleet-tuned"); profilesMu.Unlock() })
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", templates.TenantSettings{Profile: "fleet-tuned", CostPerKm: 10}); rec.Code != http.StatusOK {
+		t.Fatalf("saving a tuned default: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", fixtures.RouteInstances()[0].Request); rec.Code != http.StatusOK {
+		t.Errorf("route under a fleet profile: %d %s", rec.Code, rec.Body)
+	}
+	load := fixtures.LoadInstances()[0].Request
+	if rec := serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load", load); rec.Code != http.StatusOK {
+		t.Errorf("load under a fleet profile: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load?profile=fleet-tuned", load); rec.Code != http.StatusBadRequest {
+		t.Errorf("load asking for the fleet profile: %d", rec.Code)
+	}
+	plan := models.FleetResponse{Routes: []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A"}, DistanceKm: 10}}}
+	rec = serve(t, ScenariosHandler, http.MethodPost, "/scenarios", models.Scenario{Name: "tenant-cost", Date: "2027-01-22", Plan: plan})
+	var sc models.Scenario
+	if json.Unmarshal(rec.Body.Bytes(), &sc); sc.KPIs.CostInr != 100 {
+		t.Errorf("scenario cost = %v, want 10 km at the tenant's 10/km", sc.KPIs.CostInr)
+	}
+}
+
+func TestQuotasRefuseSolvesOnceUsed(t *testing.T) {
+	route := fixtures.RouteInstances()[0].Request
+	month := usage.Month(time.Now())
+	settings := templates.TenantSettings{MonthlyRequests: usageMeter.Used("", month).Requests + 1}
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", settings); rec.Code != http.StatusOK {
+		t.Fatalf("saving settings: status = %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { serve(t, TenantSettingsHandler, http.MethodDelete, "/tenants/settings?tenant=", nil) })
+
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", route); rec.Code != http.StatusOK {
+		t.Fatalf("last request in quota: status = %d: %s", rec.Code, rec.Body)
+	}
+	rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", route)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("over the request quota: status = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	settings = templates.TenantSettings{MonthlySolverSeconds: 1e-9}
+	serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", settings)
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", route); rec.Code != http.StatusPaymentRequired {
+		t.Errorf("over the solver time quota: status = %d", rec.Code)
+	}
+
+	rec = serve(t, UsageHandler, http.MethodGet, "/usage", nil)
+	var report []models.TenantUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || len(report) != 1 {
+		t.Fatalf("usage: %v: %s", err, rec.Body)
+	}
+	if u := report[0]; u.Month != month || u.Requests == 0 || u.SolverSeconds <= 0 || u.MonthlySolverSeconds != 1e-9 {
+		t.Errorf("usage = %+v", u)
+	}
+	if rec := serve(t, UsageHandler, http.MethodGet, "/usage?month=October", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad month: status = %d", rec.Code)
+	}
+}
+
+func TestSolvesAreMeteredToAVerifiedTenant(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	route := fixtures.RouteInstances()[0].Request
+	month := usage.Month(time.Now())
+	used := usageMeter.Used("metered", month).Requests
+
+	for _, token := range []string{"", "Bearer forged"} {
+		req := httptest.NewRequest(http.MethodPost, "/optimize", strings.NewReader(`{}`))
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		rec := httptest.NewRecorder()
+		OptimizeRouteHandler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d", token, rec.Code)
+		}
+	}
+	planner := auth.Principal{Tenant: "metered", Role: auth.RolePlanner}
+	if rec := serveAs(t, planner, OptimizeRouteHandler, http.MethodPost, "/optimize", route); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := usageMeter.Used("metered", month).Requests; got != used+1 {
+		t.Errorf("metered %d requests, want %d", got, used+1)
+	}
+}
+
+func TestDryRunChecksWithoutSolving(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 8, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Stops[0].DemandKg = 1e6
+	req.Stops[1].DueHours = 1e-3
+	used := usageMeter.Used("", usage.Month(time.Now())).Requests
+
+	rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?dry_run=true&solver=alns", req)
+	var resp models.DryRunResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if resp.Solver != "alns" || resp.Stops != len(req.Stops) || resp.Vehicles != len(req.Vehicles) {
+		t.Errorf("dry run = %+v", resp)
+	}
+	if e := resp.EstimatedSolveSeconds; e == nil || *e <= 0 || *e > solver.ALNSTimeLimit.Seconds() {
+		t.Errorf("estimate = %v, want within the ALNS time limit", e)
+	}
+	warned := map[string]string{}
+	for _, v := range resp.Feasibility.Violations {
+		warned[v.Constraint] += v.NodeID
+	}
+	if warned["capacity"] != req.Stops[0].ID || warned["time_window"] != req.Stops[1].ID {
+		t.Errorf("warnings = %+v", resp.Feasibility.Violations)
+	}
+	if got := usageMeter.Used("", usage.Month(time.Now())).Requests; got != used {
+		t.Errorf("dry run metered: %d requests, was %d", got, used)
+	}
+
+	// Validation still fails as usual
+	req.Vehicles = nil
+	if rec := serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?dry_run=true", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid dry run: status = %d", rec.Code)
+	}
+	load := fixtures.LoadInstances()[0].Request
+	rec = serve(t, OptimizeLoadHandler, http.MethodPost, "/optimize-load?dry_run=true", load)
+	resp = models.DryRunResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Stops != len(load.Shipments) || resp.EstimatedSolveSeconds == nil {
+		t.Errorf("load dry run: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLoadSheddingDegradesAndRefusesBatch(t *testing.T) {
+	// Thresholds of zero count an idle pool as loaded
+	defer ConfigureDegradation(degradeAt, shedBatchAt)
+	ConfigureDegradation(0, 0)
+
+	route := fixtures.RouteInstances()[0].Request
+	rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize", route)
+	var resp models.OptimizationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if resp.Meta == nil || resp.Meta.Solver != "two-opt" || resp.Meta.Degraded != tierFast || rec.Header().Get(DegradedHeader) != tierFast {
+		t.Errorf("meta = %+v, want solved on the fast tier", resp.Meta)
+	}
+
+	// A request pinning its solver keeps it
+	rec = serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize?solver=guided-local-search", route)
+	resp = models.OptimizationResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Meta == nil || resp.Meta.Degraded != "" {
+		t.Errorf("pinned solver: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize?mode=batch", route); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("batch under load: status = %d", rec.Code)
+	}
+}
+
+func TestFleetSolvesSeedFromEarlierPlans(t *testing.T) {
+	planPool = elite.New()
+	req, err := generator.FleetRequest(generator.Config{Size: 20, Seed: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	solve := func(target string) models.FleetResponse {
+		t.Helper()
+		rec := serve(t, OptimizeFleetHandler, http.MethodPost, target, req)
+		var resp models.FleetResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK || resp.Meta == nil {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		return resp
+	}
+
+	if first := solve("/optimize-fleet"); first.Meta.Seeds != 0 {
+		t.Errorf("first solve started from %d pooled plans", first.Meta.Seeds)
+	}
+	// The next day's plan drops a stop; it still starts from yesterday's
+	req.Stops = req.Stops[1:]
+	if next := solve("/optimize-fleet"); next.Meta.Seeds != 1 {
+		t.Errorf("repeat solve started from %d pooled plans, want 1", next.Meta.Seeds)
+	}
+	if opted := solve("/optimize-fleet?pool=false"); opted.Meta.Seeds != 0 {
+		t.Errorf("?pool=false started from %d pooled plans", opted.Meta.Seeds)
+	}
+}
+
+func TestJobsRunQueuedSolves(t *testing.T) {
+	if err := OpenJobStore(t.TempDir(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobStore = nil })
+
+	body, _ := json.Marshal(fixtures.RouteInstances()[0].Request)
+	rec := serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{Path: "/optimize", Query: "solver=two-opt", Body: body})
+	var j jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || rec.Code != http.StatusAccepted || j.Status != jobs.Queued {
+		t.Fatalf("submit: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{Path: "/audit"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("job on a non-solving endpoint: status = %d", rec.Code)
+	}
+
+	claimed, ok, err := jobStore.Claim("test-replica", time.Now())
+	if err != nil || !ok || claimed.ID != j.ID {
+		t.Fatalf("claim: %+v, %v, %v", claimed, ok, err)
+	}
+	runJob(jobStore, claimed, Deadlines(http.HandlerFunc(OptimizeRouteHandler)))
+
+	rec = serve(t, JobsHandler, http.MethodGet, "/jobs?id="+j.ID, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || j.Status != jobs.Done || j.Code != http.StatusOK || j.Owner != "test-replica" {
+		t.Fatalf("finished job: %s", rec.Body)
+	}
+	var resp models.OptimizationResponse
+	if err := json.Unmarshal(j.Result, &resp); err != nil || resp.Meta == nil || resp.Meta.Solver != "two-opt" {
+		t.Errorf("result: %s", j.Result)
+	}
+}
+
+func TestJobsExportToExcel(t *testing.T) {
+	if err := OpenJobStore(t.TempDir(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobStore = nil })
+	depot := models.Location{Lat: 28.6, Lng: 77.2}
+	req := models.FleetRequest{
+		Depot:    depot,
+		Vehicles: []models.VehicleInfo{{ID: "TRUCK/1", CapacityKg: 1000}, {ID: "V2", CapacityKg: 100}},
+		Stops: []models.FleetStop{
+			{ID: "A", Location: models.Location{Lat: 28.7, Lng: 77.1}, DemandKg: 300},
+			{ID: "B", Location: models.Location{Lat: 28.5, Lng: 77.3}, DemandKg: 200},
+		},
+	}
+	body, _ := json.Marshal(req)
+	rec := serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{Path: "/optimize-fleet", Body: body})
+	var j jobs.Job
+	json.Unmarshal(rec.Body.Bytes(), &j)
+	if rec := serve(t, JobsHandler, http.MethodGet, "/jobs?format=xlsx&id="+j.ID, nil); rec.Code != http.StatusConflict {
+		t.Errorf("queued job exported: %d", rec.Code)
+	}
+	claimed, _, _ := jobStore.Claim("test-replica", time.Now())
+	runJob(jobStore, claimed, http.HandlerFunc(OptimizeFleetHandler))
+
+	rec = serve(t, JobsHandler, http.MethodGet, "/jobs?format=xlsx&id="+j.ID, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != xlsx.ContentType {
+		t.Fatalf("export: %d %s", rec.Code, rec.Body)
+	}
+	z, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := func(name string) string {
+		f, err := z.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		b, _ := io.ReadAll(f)
+		return string(b)
+	}
+	if wb := part("xl/workbook.xml"); !strings.Contains(wb, `name="Summary"`) || !strings.Contains(wb, `name="TRUCK_1"`) {
+		t.Errorf("sheets: %s", wb)
+	}
+	for _, want := range []string{"<t xml:space=\"preserve\">TRUCK/1</t>", "<v>50</v>", "<t xml:space=\"preserve\">Total</t>"} {
+		if summary := part("xl/worksheets/sheet1.xml"); !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %s: %s", want, summary)
+		}
+	}
+
+	body, _ = json.Marshal(fixtures.RouteInstances()[0].Request)
+	rec = serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{Path: "/optimize", Body: body})
+	json.Unmarshal(rec.Body.Bytes(), &j)
+	claimed, _, _ = jobStore.Claim("test-replica", time.Now())
+	runJob(jobStore, claimed, http.HandlerFunc(OptimizeRouteHandler))
+	if rec := serve(t, JobsHandler, http.MethodGet, "/jobs?format=xlsx&id="+j.ID, nil); rec.Code != http.StatusConflict {
+		t.Errorf("single-route job exported: %d", rec.Code)
+	}
+}
+
+func TestBulkJobsStreamResultsInOrder(t *testing.T) {
+	if err := OpenJobStore(t.TempDir(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobStore = nil })
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/jobs/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", ndjsonContentType)
+		BulkJobsHandler(rec, req)
+		return rec
+	}
+
+	var lines []string
+	for _, inst := range fixtures.RouteInstances()[:3] {
+		body, _ := json.Marshal(inst.Request)
+		line, _ := json.Marshal(jobs.Job{Path: "/optimize", Query: "solver=two-opt", Body: body})
+		lines = append(lines, string(line))
+	}
+	if rec := post(lines[0] + "\n" + `{"path":"/audit"}` + "\n"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "line 2") {
+		t.Errorf("bad line: %d %s", rec.Code, rec.Body)
+	}
+	if list, _ := jobStore.List(); len(list) != 0 {
+		t.Errorf("rejected batch queued %d jobs", len(list))
+	}
+
+	rec := post(strings.Join(lines, "\n") + "\n\n")
+	var sub models.BulkSubmission
+	if err := json.Unmarshal(rec.Body.Bytes(), &sub); err != nil || rec.Code != http.StatusAccepted || sub.Jobs != 3 {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, BulkJobsHandler, http.MethodGet, "/jobs/bulk?batch="+sub.Batch, nil); rec.Header().Get("X-Batch-Pending") != "3" {
+		t.Errorf("pending before the workers ran: %v", rec.Header())
+	}
+	for {
+		claimed, ok, _ := jobStore.Claim("test-replica", time.Now())
+		if !ok {
+			break
+		}
+		runJob(jobStore, claimed, http.HandlerFunc(OptimizeRouteHandler))
+	}
+
+	rec = serve(t, BulkJobsHandler, http.MethodGet, "/jobs/bulk?batch="+sub.Batch, nil)
+	if rec.Header().Get("Content-Type") != ndjsonContentType || rec.Header().Get("X-Batch-Pending") != "0" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	dec := json.NewDecoder(rec.Body)
+	i := 0
+	for dec.More() {
+		i++
+		var j jobs.Job
+		if err := dec.Decode(&j); err != nil {
+			t.Fatal(err)
+		}
+		var resp models.OptimizationResponse
+		if j.Line != i || j.Status != jobs.Done || j.Body != nil || json.Unmarshal(j.Result, &resp) != nil || len(resp.Route) == 0 {
+			t.Errorf("line %d: %+v", i, j)
+		}
+	}
+	if i != 3 {
+		t.Errorf("streamed %d of 3 jobs", i)
+	}
+	if rec := serve(t, BulkJobsHandler, http.MethodGet, "/jobs/bulk?batch=nope", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown batch: %d", rec.Code)
+	}
+}
+
+func TestJobsReadAndWriteObjectStorage(t *testing.T) {
+	if err := OpenJobStore(t.TempDir(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	input, _ := json.Marshal(fixtures.RouteInstances()[0].Request)
+	var mu sync.Mutex
+	var output []byte
+	bucket := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("X-Amz-Signature") != "sig" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/in.json":
+			w.Write(input)
+		case r.Method == http.MethodPut && r.URL.Path == "/out.json":
+			mu.Lock()
+			output, _ = io.ReadAll(r.Body)
+			mu.Unlock()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer bucket.Close()
+	saved := objectStore
+	objectStore = objstore.New([]string{"127.0.0.1"}, bucket.Client())
+	t.Cleanup(func() { jobStore, objectStore = nil, saved })
+
+	if rec := serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{Path: "/optimize", InputURL: "https://169.254.169.254/latest/meta-data"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("input from an untrusted host: %d", rec.Code)
+	}
+	rec := serve(t, JobsHandler, http.MethodPost, "/jobs", jobs.Job{
+		Path:      "/optimize",
+		Query:     "solver=two-opt",
+		InputURL:  bucket.URL + "/in.json?X-Amz-Signature=sig",
+		OutputURL: bucket.URL + "/out.json?X-Amz-Signature=sig",
+	})
+	var j jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "sig") || j.InputURL != bucket.URL+"/in.json" {
+		t.Errorf("job shows its signatures: %s", rec.Body)
+	}
+
+	claimed, _, _ := jobStore.Claim("test-replica", time.Now())
+	runJob(jobStore, claimed, http.HandlerFunc(OptimizeRouteHandler))
+	got, _ := jobStore.Get(j.ID)
+	if got.Status != jobs.Done || got.Code != http.StatusOK || got.Result != nil || got.Error != "" {
+		t.Fatalf("finished job: %+v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var resp models.OptimizationResponse
+	if err := json.Unmarshal(output, &resp); err != nil || resp.Meta == nil || resp.Meta.Solver != "two-opt" {
+		t.Errorf("written result: %s", output)
+	}
+}
+
+func TestLongSolvesRunAsJobs(t *testing.T) {
+	if err := OpenJobStore(t.TempDir(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobStore = nil })
+	defer ConfigurePrediction(solveModel, asyncAbove)
+	slow := predict.Model{Entries: []predict.Entry{{Solver: "two-opt", A: 60}}}
+	ConfigurePrediction(slow, time.Minute/2)
+
+	// Only callers that prefer it are answered asynchronously
+	route := fixtures.RouteInstances()[0].Request
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/v1/optimize?solver=two-opt", route); rec.Code != http.StatusOK {
+		t.Errorf("without Prefer: status = %d", rec.Code)
+	}
+	body, _ := json.Marshal(route)
+	req := httptest.NewRequest(http.MethodPost, "/v1/optimize?solver=two-opt", bytes.NewReader(body))
+	req.Header.Set("Prefer", "wait=10, Respond-Async")
+	rec := httptest.NewRecorder()
+	OptimizeRouteHandler(rec, req)
+	var j jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || rec.Code != http.StatusAccepted || j.Path != "/optimize" {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Location") != "/v1/jobs?id="+j.ID || rec.Header().Get(PredictedHeader) != "60.0" || rec.Header().Get("Preference-Applied") != "respond-async" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if string(j.Body) != string(body) {
+		t.Errorf("queued body %s", j.Body)
+	}
+
+	big := httptest.NewRequest(http.MethodPost, "/v1/optimize", strings.NewReader(strings.Repeat(" ", maxBodyBytes+1)))
+	rec = httptest.NewRecorder()
+	OptimizeRouteHandler(rec, big)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d", rec.Code)
+	}
+
+	// The job itself solves rather than queueing again
+	claimed, ok, _ := jobStore.Claim("test-replica", time.Now())
+	if !ok || claimed.ID != j.ID {
+		t.Fatalf("claim: %+v", claimed)
+	}
+	runJob(jobStore, claimed, http.HandlerFunc(OptimizeRouteHandler))
+	if got, _ := jobStore.Get(j.ID); got.Status != jobs.Done || got.Code != http.StatusOK {
+		t.Errorf("job = %+v", got)
+	}
+}
+
+func TestTemplateInstanceAddsExtraStops(t *testing.T) {
+	loc := func(lat, lng float64) models.Location { return models.Location{Lat: lat, Lng: lng} }
+	stop := func(id string, l models.Location) templates.Stop {
+		return templates.Stop{FleetStop: models.FleetStop{ID: id, Location: l, DemandKg: 100}}
+	}
+	tmpl := templates.Template{
+		Name:     "test-milk-run",
+		Depot:    loc(28.61, 77.21),
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}, {ID: "V2", CapacityKg: 1000}},
+		Routes: []templates.Route{
+			{VehicleID: "V1", Stops: []templates.Stop{stop("A", loc(28.7, 77.1)), stop("B", loc(28.75, 77.2))}},
+			{VehicleID: "V2", Days: []string{"sat"}, Stops: []templates.Stop{stop("C", loc(28.4, 77.0))}},
+		},
+	}
+	if rec := serve(t, TemplatesHandler, http.MethodPost, "/templates", tmpl); rec.Code != http.StatusOK {
+		t.Fatalf("saving template: status = %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { serve(t, TemplatesHandler, http.MethodDelete, "/templates?name="+tmpl.Name, nil) })
+
+	// A Thursday standing order joins the day's plan without being submitted
+	order := templates.StandingOrder{
+		ID: "S", Template: tmpl.Name, Schedule: "FREQ=WEEKLY;BYDAY=TH", StartDate: "2026-10-01",
+		Stop: models.FleetStop{Location: loc(28.74, 77.18), DemandKg: 20},
+	}
+	if rec := serve(t, StandingOrdersHandler, http.MethodPost, "/standing-orders", order); rec.Code != http.StatusOK {
+		t.Fatalf("saving standing order: status = %d: %s", rec.Code, rec.Body)
+	}
+	t.Cleanup(func() { serve(t, StandingOrdersHandler, http.MethodDelete, "/standing-orders?id=S", nil) })
+
+	req := models.TemplateInstanceRequest{
+		Template:   tmpl.Name,
+		Date:       "2026-10-15", // A Thursday: V2's Saturday route does not run
+		ExtraStops: []models.FleetStop{{ID: "X", Location: loc(28.72, 77.15), DemandKg: 50}},
+	}
+	rec := serve(t, InstantiateTemplateHandler, http.MethodPost, "/templates/instantiate", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.TemplateInstanceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Routes) != 1 || resp.Routes[0].VehicleID != "V1" {
+		t.Fatalf("routes = %+v, want only V1", resp.Routes)
+	}
+	var kept []string
+	for _, id := range resp.Routes[0].StopIDs {
+		if id != "X" && id != "S" {
+			kept = append(kept, id)
+		}
+	}
+	if len(resp.Routes[0].StopIDs) != 4 || len(kept) != 2 || kept[0] != "A" || kept[1] != "B" {
+		t.Errorf("V1 stops = %v, want A and B in order plus S and X", resp.Routes[0].StopIDs)
+	}
+	if len(resp.StandingOrderIDs) != 1 || resp.StandingOrderIDs[0] != "S" {
+		t.Errorf("standing orders = %v, want [S]", resp.StandingOrderIDs)
+	}
+	if len(resp.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none", resp.Warnings)
+	}
+
+	// A plan on Gandhi Jayanti is flagged
+	req = models.TemplateInstanceRequest{Template: tmpl.Name, Date: "2026-10-02"}
+	rec = serve(t, InstantiateTemplateHandler, http.MethodPost, "/templates/instantiate", req)
+	resp = models.TemplateInstanceResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "Gandhi Jayanti") {
+		t.Errorf("warnings = %v, want a holiday warning", resp.Warnings)
+	}
+	if resp.Feasibility == nil || !resp.Feasibility.Feasible {
+		t.Errorf("plan is not feasible: %+v", resp.Feasibility)
+	}
+}
+
+func TestDispatchBoardTracksStops(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-03",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, loc, loc, loc}},
+		},
+	}
+	rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+	published := rec.Header().Get("ETag")
+
+	update := models.StopStatusUpdate{Date: plan.Date, VehicleID: "V1", StopID: "A", Status: "en_route"}
+	if rec := serve(t, DispatchStatusHandler, http.MethodPost, "/dispatch/status", update); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("editing without If-Match: status = %d, want %d", rec.Code, http.StatusPreconditionRequired)
+	}
+	// Tags compare weakly, so one a proxy marked W/ still matches
+	rec = serveIfMatch(t, DispatchStatusHandler, http.MethodPost, "/dispatch/status", update, `"0", W/`+published)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("en route: status = %d: %s", rec.Code, rec.Body)
+	}
+	current := rec.Header().Get("ETag")
+	if current == published {
+		t.Errorf("ETag %s did not change with the edit", current)
+	}
+	// A second dispatcher still looking at the plan as published
+	update.StopID, update.Status = "B", "completed"
+	if rec := serveIfMatch(t, DispatchStatusHandler, http.MethodPost, "/dispatch/status", update, published); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("editing an old version: status = %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+	update.StopID, update.Status = "A", "pending"
+	if rec := serveIfMatch(t, DispatchStatusHandler, http.MethodPost, "/dispatch/status", update, current); rec.Code != http.StatusConflict {
+		t.Errorf("moving back: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := serveIfMatch(t, DispatchHandler, http.MethodPost, "/dispatch", plan, current); rec.Code != http.StatusConflict {
+		t.Errorf("republishing a started day: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = serve(t, DispatchHandler, http.MethodGet, "/dispatch?date="+plan.Date, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != current {
+		t.Fatalf("status = %d, ETag %s: %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+	var board dispatch.Board
+	if err := json.Unmarshal(rec.Body.Bytes(), &board); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/dispatch?date="+plan.Date, nil)
+	req.Header.Set("If-None-Match", "W/"+current)
+	unchanged := httptest.NewRecorder()
+	DispatchHandler(unchanged, req)
+	if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 {
+		t.Errorf("unchanged board: status = %d", unchanged.Code)
+	}
+	if len(board.Runs) != 1 || board.Runs[0].Status != dispatch.EnRoute {
+		t.Fatalf("runs = %+v, want V1 en route", board.Runs)
+	}
+	if stops := board.Runs[0].Stops; stops[0].Status != dispatch.EnRoute || stops[1].Status != dispatch.Pending {
+		t.Errorf("stops = %+v, want A en route and B pending", stops)
+	}
+
+	// The audit log explains how stop A got where it is
+	rec = serve(t, AuditHandler, http.MethodGet, "/audit?shipment=A&date="+plan.Date, nil)
+	var events []audit.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	if strings.Join(kinds, ",") != "plan.published,stop.status" || len(events[0].Data) == 0 {
+		t.Errorf("audit kinds = %v", kinds)
+	}
+
+	// It pages and sorts like every other list, detail included
+	rec = serve(t, AuditHandler, http.MethodGet, "/audit?shipment=A&date="+plan.Date+"&sort=-kind&limit=1", nil)
+	events = nil
+	json.Unmarshal(rec.Body.Bytes(), &events)
+	if len(events) != 1 || events[0].Kind != "stop.status" || len(events[0].Data) == 0 ||
+		rec.Header().Get("X-Total-Count") != "2" || rec.Header().Get("X-Next-Cursor") == "" {
+		t.Errorf("first page by kind = %+v, headers %v", events, rec.Header())
+	}
+}
+
+func TestBulkStatusReportsEachScan(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-09",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, loc, loc, loc}},
+			{VehicleID: "V2", StopIDs: []string{"C"}, Route: []models.Location{loc, loc, loc}},
+		},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	scans := models.BulkStatusUpdate{Date: plan.Date, Updates: []models.StopStatusScan{
+		{StopID: "A", Status: "en_route"},
+		{StopID: "C", Status: "completed"},
+		{StopID: "NOPE", Status: "completed"},
+		{StopID: "B", Status: "en_route"}, // V1 is already en route to A
+	}}
+	rec := serve(t, ShipmentStatusHandler, http.MethodPatch, "/shipments/status", scans)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Fatalf("status = %d, ETag %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+	var res models.BulkStatusResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var codes []int
+	for _, r := range res.Results {
+		codes = append(codes, r.Code)
+	}
+	if res.Updated != 2 || res.Failed != 2 || !slices.Equal(codes, []int{200, 200, 404, 409}) {
+		t.Errorf("result = %+v", res)
+	}
+	if b, _ := dispatched.Board("", plan.Date); b.Counts[dispatch.Completed] != 1 || b.Counts[dispatch.EnRoute] != 1 {
+		t.Errorf("counts = %v", b.Counts)
+	}
+	// If-Match is optional, but one sent must be current
+	if rec := serveIfMatch(t, ShipmentStatusHandler, http.MethodPatch, "/shipments/status", scans, etag(1)); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: status = %d", rec.Code)
+	}
+
+	scans.Date = "1999-01-01"
+	if rec := serve(t, ShipmentStatusHandler, http.MethodPatch, "/shipments/status", scans); rec.Code != http.StatusNotFound {
+		t.Errorf("nothing dispatched: status = %d", rec.Code)
+	}
+	if rec := serve(t, ShipmentStatusHandler, http.MethodPatch, "/shipments/status", models.BulkStatusUpdate{Date: plan.Date}); rec.Code != http.StatusBadRequest {
+		t.Errorf("no updates: status = %d", rec.Code)
+	}
+}
+
+func TestSearchFindsWhereShipmentsArePlanned(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-10",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"SRCH-1", "SRCH-2"}, Route: []models.Location{loc, loc, loc, loc}},
+		},
+		Unassigned: []string{"SRCH-3"},
+		Consignees: []models.Consignee{
+			{StopID: "SRCH-1", Name: "Sharma Traders", City: "Delhi"},
+			{StopID: "SRCH-2", Name: "Gupta & Sons", City: "Gurugram"},
+		},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	search := func(query string) []dispatch.Hit {
+		t.Helper()
+		rec := serve(t, SearchHandler, http.MethodGet, "/search?"+query, nil)
+		var hits []dispatch.Hit
+		if err := json.Unmarshal(rec.Body.Bytes(), &hits); err != nil {
+			t.Fatalf("%s: %v: %s", query, err, rec.Body)
+		}
+		return hits
+	}
+	if hits := search("shipment=SRCH-2"); len(hits) != 1 || hits[0].VehicleID != "V1" || hits[0].Position != 2 || hits[0].Date != plan.Date {
+		t.Errorf("SRCH-2 = %+v", hits)
+	}
+	if hits := search("shipment=SRCH&customer=sharma"); len(hits) != 1 || hits[0].ID != "SRCH-1" {
+		t.Errorf("customer sharma = %+v", hits)
+	}
+	if hits := search("shipment=SRCH&city=GURUGRAM&from=2026-11-10&to=2026-11-10"); len(hits) != 1 || hits[0].ID != "SRCH-2" {
+		t.Errorf("city gurugram = %+v", hits)
+	}
+	if hits := search("shipment=SRCH&status=unassigned"); len(hits) != 1 || hits[0].ID != "SRCH-3" {
+		t.Errorf("unassigned = %+v", hits)
+	}
+	if hits := search("shipment=SRCH&to=2026-11-09"); len(hits) != 0 {
+		t.Errorf("before the plan = %+v", hits)
+	}
+	if rec := serve(t, SearchHandler, http.MethodGet, "/search?from=2026-11-10&to=2026-11-01", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("reversed range: status = %d", rec.Code)
+	}
+}
+
+func TestDispatchBoardsAreTenantScoped(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	as := func(h http.HandlerFunc, tenant, role, method, target string, body any) *httptest.ResponseRecorder {
+		return serveAs(t, auth.Principal{Tenant: tenant, Role: role}, h, method, target, body)
+	}
+
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date:   "2026-12-01",
+		Routes: []models.FleetRoute{{VehicleID: "ISO-V", StopIDs: []string{"ISO-1"}, Route: []models.Location{loc, loc, loc}}},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: %d", rec.Code)
+	}
+	if rec := as(DispatchHandler, "acme", auth.RoleViewer, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer published: %d", rec.Code)
+	}
+	if rec := as(DispatchHandler, "acme", auth.RolePlanner, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("acme publishing: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := as(DispatchHandler, "globex", auth.RolePlanner, http.MethodGet, "/dispatch?date=2026-12-01", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("globex read acme's board: %d %s", rec.Code, rec.Body)
+	}
+	if rec := as(DispatchHandler, "globex", auth.RolePlanner, http.MethodGet, "/dispatch?date=2026-12-01&tenant=acme", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("globex named acme: %d %s", rec.Code, rec.Body)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/dispatch/status", strings.NewReader(`{"date":"2026-12-01","vehicle_id":"ISO-V","stop_id":"ISO-1","status":"completed"}`))
+	req.Header.Set("Authorization", "Bearer "+auth.Sign([]byte("planner-secret"), auth.Principal{Tenant: "globex", Role: auth.RolePlanner}.Subject(), time.Now().Add(time.Hour)))
+	req.Header.Set("If-Match", etag(1))
+	rec := httptest.NewRecorder()
+	DispatchStatusHandler(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Errorf("globex completed acme's stop: %s", rec.Body)
+	}
+	if b, _ := dispatched.Board("acme", plan.Date); b.Runs[0].Stops[0].Status == dispatch.Completed {
+		t.Errorf("acme's stop moved to %s", b.Runs[0].Stops[0].Status)
+	}
+
+	var hits []dispatch.Hit
+	json.Unmarshal(as(SearchHandler, "globex", auth.RoleViewer, http.MethodGet, "/search?shipment=ISO-", nil).Body.Bytes(), &hits)
+	if len(hits) != 0 {
+		t.Errorf("globex found %+v", hits)
+	}
+	json.Unmarshal(as(SearchHandler, "acme", auth.RoleViewer, http.MethodGet, "/search?shipment=ISO-", nil).Body.Bytes(), &hits)
+	if len(hits) != 1 || hits[0].Tenant != "acme" {
+		t.Errorf("acme found %+v", hits)
+	}
+
+	gql := graphql.Request{Query: `{ boards(from: "2026-12-01") { tenant } board(date: "2026-12-01", tenant: "acme") { version } }`}
+	if rec := as(GraphQLHandler, "globex", auth.RoleViewer, http.MethodPost, "/graphql", gql); !strings.Contains(rec.Body.String(), `{"data":{"boards":[],"board":null}}`) {
+		t.Errorf("globex on GraphQL: %s", rec.Body)
+	}
+
+	var events []audit.Event
+	json.Unmarshal(as(AuditHandler, "globex", auth.RolePlanner, http.MethodGet, "/audit?kind=plan.published&date=2026-12-01", nil).Body.Bytes(), &events)
+	if len(events) != 0 {
+		t.Errorf("globex lists acme's events: %+v", events)
+	}
+	json.Unmarshal(as(AuditHandler, "acme", auth.RoleViewer, http.MethodGet, "/audit?kind=plan.published&date=2026-12-01", nil).Body.Bytes(), &events)
+	if len(events) != 1 || events[0].Tenant != "acme" {
+		t.Errorf("acme's events: %+v", events)
+	}
+}
+
+func TestGraphQLFetchesABoardInOneQuery(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-11",
+		Routes: []models.FleetRoute{
+			{VehicleID: "GQ1", StopIDs: []string{"GQ-A", "GQ-B"}, Route: []models.Location{loc, loc, loc, loc}},
+			{VehicleID: "GQ2", StopIDs: []string{"GQ-C"}, Route: []models.Location{loc, loc, loc}},
+		},
+		Consignees: []models.Consignee{{StopID: "GQ-C", Name: "Mehta Stores", City: "Noida"}},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+	m := templates.Maintenance{VehicleID: "GQ1", ServiceEveryKm: 10000, KmSinceService: 4200}
+	serve(t, MaintenanceHandler, http.MethodPost, "/maintenance", m)
+	t.Cleanup(func() { serve(t, MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=GQ1", nil) })
+
+	query := graphql.Request{
+		Query: `query Board($date: String) {
+			board(date: $date) {
+				version
+				runs(vehicle_id: "GQ1") { vehicle_id stops { id status } vehicle { maintenance { km_since_service } } }
+			}
+			shipments(city: "noida", from: $date) { id vehicle_id position }
+		}`,
+		Variables: map[string]any{"date": plan.Date},
+	}
+	rec := serve(t, GraphQLHandler, http.MethodPost, "/graphql", query)
+	want := `{"data":{"board":{"version":1,"runs":[{"vehicle_id":"GQ1","stops":[{"id":"GQ-A","status":"pending"},{"id":"GQ-B","status":"pending"}],` +
+		`"vehicle":{"maintenance":{"km_since_service":4200}}}]},"shipments":[{"id":"GQ-C","vehicle_id":"GQ2","position":1}]}}`
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("status = %d:\n got %s\nwant %s", rec.Code, rec.Body, want)
+	}
+
+	rec = serve(t, GraphQLHandler, http.MethodGet, "/graphql?query="+url.QueryEscape(`{ board(date: "11/11/2026") { version } }`), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"board":null`) || !strings.Contains(rec.Body.String(), "YYYY-MM-DD") {
+		t.Errorf("bad date: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, GraphQLHandler, http.MethodPost, "/graphql", graphql.Request{Query: `{ board {`}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unparseable query: status = %d", rec.Code)
+	}
+
+	// One query cannot repeat a field without end or walk every plan
+	aliased := "{"
+	for i := range maxGraphQLAliases + 1 {
+		aliased += " b" + strconv.Itoa(i) + ": board { version }"
+	}
+	if rec := serve(t, GraphQLHandler, http.MethodPost, "/graphql", graphql.Request{Query: aliased + " }"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("too many aliases: status = %d", rec.Code)
+	}
+	rec = serve(t, GraphQLHandler, http.MethodPost, "/graphql", graphql.Request{Query: `{ boards(from: "2026-01-01", to: "2026-12-31") { date } }`})
+	if !strings.Contains(rec.Body.String(), `"boards":null`) || !strings.Contains(rec.Body.String(), "at most 31 days") {
+		t.Errorf("a year of boards: %s", rec.Body)
+	}
+}
+
+func TestMapDrawsRoutesAsPNG(t *testing.T) {
+	depot := models.Location{Lat: 19.07, Lng: 72.87}
+	routes := []models.FleetRoute{
+		{VehicleID: "V1", StopIDs: []string{"MAP-1"}, Route: []models.Location{depot, {Lat: 19.2, Lng: 72.9}, depot}},
+		{VehicleID: "V2", StopIDs: []string{"MAP-2"}, Route: []models.Location{depot, {Lat: 18.95, Lng: 73.1}, depot}},
+	}
+	plan := models.DispatchRequest{Date: "2026-11-12", Routes: routes}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	decode := func(rec *httptest.ResponseRecorder) image.Config {
+		t.Helper()
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("status = %d, type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+		}
+		cfg, err := png.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	if cfg := decode(serve(t, MapHandler, http.MethodGet, "/map?date=2026-11-12", nil)); cfg.Width != 640 || cfg.Height != 400 {
+		t.Errorf("default size = %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg := decode(serve(t, MapHandler, http.MethodGet, "/map?date=2026-11-12&vehicle_id=V2&width=300&height=200", nil)); cfg.Width != 300 || cfg.Height != 200 {
+		t.Errorf("sized = %dx%d", cfg.Width, cfg.Height)
+	}
+	decode(serve(t, MapHandler, http.MethodPost, "/map", models.MapRequest{Routes: routes}))
+
+	for target, want := range map[string]int{
+		"/map?date=2026-11-12&width=5000":      http.StatusBadRequest,
+		"/map?date=2026-11-12&vehicle_id=NOPE": http.StatusNotFound,
+		"/map?date=2026-11-13":                 http.StatusNotFound,
+	} {
+		if rec := serve(t, MapHandler, http.MethodGet, target, nil); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+	if rec := serve(t, MapHandler, http.MethodPost, "/map", models.MapRequest{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("no routes: status = %d", rec.Code)
+	}
+}
+
+func TestMarginalCostInsertsIntoDispatchedPlan(t *testing.T) {
+	depot := models.Location{Lat: 28.6, Lng: 77.2}
+	a, b := models.Location{Lat: 28.6, Lng: 77.3}, models.Location{Lat: 28.6, Lng: 77.4}
+	north := models.Location{Lat: 28.9, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-20",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{depot, a, b, depot}, LoadKg: 800},
+			{VehicleID: "V2", StopIDs: []string{"C"}, Route: []models.Location{depot, north, depot}, LoadKg: 100},
+		},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	req := models.MarginalCostRequest{
+		Date:     plan.Date,
+		Shipment: models.FleetStop{ID: "N", Location: models.Location{Lat: 28.6, Lng: 77.35}, DemandKg: 150},
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}, {ID: "V2", CapacityKg: 1000}},
+	}
+	quote := func() models.MarginalCost {
+		t.Helper()
+		rec := serve(t, MarginalCostHandler, http.MethodPost, "/dispatch/marginal-cost", req)
+		var mc models.MarginalCost
+		if err := json.Unmarshal(rec.Body.Bytes(), &mc); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body)
+		}
+		return mc
+	}
+	mc := quote()
+	if !mc.Feasible || mc.Best.VehicleID != "V1" || mc.Best.AfterStopID != "A" || mc.Best.BeforeStopID != "B" || mc.Best.AddedKm > 0.01 {
+		t.Fatalf("quote = %+v, want N between A and B on V1 at no extra distance", mc)
+	}
+
+	// Too heavy for what V1 has left: V2 takes it, at a detour
+	req.Shipment.DemandKg = 300
+	mc = quote()
+	if !mc.Feasible || mc.Best.VehicleID != "V2" || mc.Best.AddedKm <= 0 || math.Abs(mc.Best.AddedCostInr-mc.Best.AddedKm*30) > 1 {
+		t.Errorf("heavy quote best = %+v", mc.Best)
+	}
+	if last := mc.Options[len(mc.Options)-1]; last.VehicleID != "V1" || last.Feasible || last.Reason == "" {
+		t.Errorf("V1 option = %+v, want infeasible with a reason", last)
+	}
+
+	// Stops already reached stay put
+	req.Shipment.DemandKg = 150
+	update := models.StopStatusUpdate{Date: plan.Date, VehicleID: "V1", StopID: "B", Status: "en_route"}
+	if rec := serveIfMatch(t, DispatchStatusHandler, http.MethodPost, "/dispatch/status", update, "*"); rec.Code != http.StatusOK {
+		t.Fatalf("en route: status = %d: %s", rec.Code, rec.Body)
+	}
+	if mc = quote(); mc.Best.VehicleID == "V1" && mc.Best.Position < 2 {
+		t.Errorf("quote = %+v, want N after B once B is under way", mc.Best)
+	}
+}
+
+func TestMarginalCostKeepsLaterStopsOnTime(t *testing.T) {
+	depot, end := models.Location{Lat: 28.6, Lng: 77.2}, models.Location{Lat: 28.6, Lng: 77.6}
+	a, b := models.Location{Lat: 28.6, Lng: 77.3}, models.Location{Lat: 28.6, Lng: 77.4}
+	plan := models.DispatchRequest{
+		Date: "2026-11-23",
+		Routes: []models.FleetRoute{{
+			VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{depot, a, b, end},
+			ArrivalHours: []float64{0.2, 0.4}, DueHours: []float64{0, 0.8},
+		}},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// Before A is cheapest, but the half hour spent there makes B late
+	req := models.MarginalCostRequest{
+		Date:     plan.Date,
+		Shipment: models.FleetStop{ID: "N", Location: models.Location{Lat: 28.65, Lng: 77.25}, DemandKg: 10, ServiceHours: 0.5},
+	}
+	rec := serve(t, MarginalCostHandler, http.MethodPost, "/dispatch/marginal-cost", req)
+	var mc models.MarginalCost
+	if err := json.Unmarshal(rec.Body.Bytes(), &mc); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if !mc.Feasible || mc.Best.Position != 2 || mc.Best.AfterStopID != "B" {
+		t.Errorf("quote = %+v, want N after B", mc.Best)
+	}
+
+	// With no slack at all, no place works and the cheapest says why
+	req.Shipment.DueHours = 0.3
+	rec = serve(t, MarginalCostHandler, http.MethodPost, "/dispatch/marginal-cost", req)
+	mc = models.MarginalCost{}
+	json.Unmarshal(rec.Body.Bytes(), &mc)
+	if mc.Feasible || len(mc.Options) != 1 || mc.Options[0].Position != 0 || !strings.Contains(mc.Options[0].Reason, "B late") {
+		t.Errorf("quote = %+v, want before A, infeasible for B", mc)
+	}
+}
+
+func TestOrderAcceptanceWeighsPriceAgainstInsertion(t *testing.T) {
+	depot, a := models.Location{Lat: 28.6, Lng: 77.2}, models.Location{Lat: 28.6, Lng: 77.3}
+	plan := models.DispatchRequest{
+		Date:   "2026-11-21",
+		Routes: []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A"}, Route: []models.Location{depot, a, depot}, LoadKg: 500}},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	req := models.OrderAcceptanceRequest{MarginalCostRequest: models.MarginalCostRequest{
+		Date:     plan.Date,
+		Shipment: models.FleetStop{ID: "SPOT", Location: models.Location{Lat: 28.65, Lng: 77.3}, DemandKg: 200},
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 1000}},
+	}}
+	decide := func() models.OrderAcceptance {
+		t.Helper()
+		rec := serve(t, OrderAcceptanceHandler, http.MethodPost, "/dispatch/order-acceptance", req)
+		var oa models.OrderAcceptance
+		if err := json.Unmarshal(rec.Body.Bytes(), &oa); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body)
+		}
+		return oa
+	}
+
+	// At the rate card the short detour pays well
+	oa := decide()
+	if oa.Recommendation != "accept" || oa.PriceInr != oa.RateCardInr || oa.CostInr <= 0 || oa.MarginPct < 20 {
+		t.Errorf("at the rate card: %+v", oa)
+	}
+	cost := oa.CostInr
+
+	for _, tc := range []struct {
+		price float64
+		want  string
+	}{
+		{cost / 2, "reject"},
+		{cost * 1.1, "needs_review"},
+		{oa.RateCardInr - 1, "needs_review"},
+	} {
+		req.PriceInr = tc.price
+		if oa := decide(); oa.Recommendation != tc.want || oa.Reason == "" {
+			t.Errorf("price %.2f: %s (%s), want %s", tc.price, oa.Recommendation, oa.Reason, tc.want)
+		}
+	}
+
+	req.PriceInr, req.Shipment.DemandKg = 0, 800
+	if oa := decide(); oa.Recommendation != "reject" || oa.Insertion == nil || oa.Insertion.Feasible {
+		t.Errorf("over capacity: %+v", oa)
+	}
+}
+
+func TestDeadheadReportCoversDispatchedDays(t *testing.T) {
+	depot, far := models.Location{Lat: 28.6, Lng: 77.2}, models.Location{Lat: 26.9, Lng: 75.8}
+	plan := models.DispatchRequest{
+		Date:   "2026-12-01",
+		Routes: []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A"}, Route: []models.Location{depot, far, depot}, DistanceKm: 480, LoadKg: 900}},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := serve(t, DeadheadHandler, http.MethodGet, "/dispatch/deadhead?from=2026-12-01&to=2026-12-02", nil)
+	var rep models.DeadheadReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(rep.Days) != 1 || len(rep.Runs) != 1 || rep.Runs[0].DeadheadKm < 200 || rep.DistanceKm != 480 {
+		t.Errorf("report = %+v, want V1 back empty from Jaipur", rep)
+	}
+
+	for _, target := range []string{
+		"/dispatch/deadhead?from=2026-12-03&to=2026-12-01",
+		"/dispatch/deadhead?from=2026-01-01&to=2026-12-01",
+		"/dispatch/deadhead?min_saving_km=-1",
+	} {
+		if rec := serve(t, DeadheadHandler, http.MethodGet, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestScenariosCompareKPIs(t *testing.T) {
+	two := models.FleetResponse{
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, DistanceKm: 60},
+			{VehicleID: "V2", StopIDs: []string{"C"}, DistanceKm: 40, TollInr: 150},
+		},
+		Unassigned: []string{},
+	}
+	one := models.FleetResponse{
+		Routes:      []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A", "B", "C"}, DistanceKm: 80}, {VehicleID: "V2"}},
+		Unassigned:  []string{},
+		Feasibility: &models.FeasibilityReport{Violations: []models.Violation{{Constraint: "time_window", NodeID: "C", Soft: true}}},
+	}
+	for name, plan := range map[string]models.FleetResponse{"two-vans": two, "one-van": one} {
+		rec := serve(t, ScenariosHandler, http.MethodPost, "/scenarios", models.Scenario{Name: name, Date: "2027-01-20", Plan: plan})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("saving %s: status = %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	rec := serve(t, ScenarioCompareHandler, http.MethodGet, "/scenarios/compare?date=2027-01-20&a=two-vans&b=one-van", nil)
+	var cmp models.ScenarioComparison
+	if err := json.Unmarshal(rec.Body.Bytes(), &cmp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if cmp.AKPIs.CostInr != 100*30+150 || cmp.AKPIs.Vehicles != 2 || cmp.BKPIs.Vehicles != 1 {
+		t.Errorf("kpis = %+v / %+v", cmp.AKPIs, cmp.BKPIs)
+	}
+	want := models.ScenarioKPIs{DistanceKm: -20, CostInr: 80*30 - (100*30 + 150), Vehicles: -1, Violations: 1}
+	if cmp.Delta != want {
+		t.Errorf("delta = %+v, want %+v", cmp.Delta, want)
+	}
+
+	rec = serve(t, ScenariosHandler, http.MethodGet, "/scenarios?date=2027-01-20", nil)
+	var list []models.Scenario
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 {
+		t.Errorf("list: %v: %s", err, rec.Body)
+	}
+	if rec := serve(t, ScenariosHandler, http.MethodDelete, "/scenarios?date=2027-01-20&name=one-van", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", rec.Code)
+	}
+	if rec := serve(t, ScenarioCompareHandler, http.MethodGet, "/scenarios/compare?date=2027-01-20&a=two-vans&b=one-van", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("compare with a deleted scenario: status = %d", rec.Code)
+	}
+	if rec := serve(t, ScenariosHandler, http.MethodPost, "/scenarios", models.Scenario{Name: "x", Date: "20-01-2027"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad date: status = %d", rec.Code)
+	}
+}
+
+func TestScenariosAreKeptPerTenant(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	acme, globex := auth.Principal{Tenant: "acme", Role: auth.RolePlanner}, auth.Principal{Tenant: "globex", Role: auth.RolePlanner}
+
+	for i := range maxScenariosPerDay {
+		s := models.Scenario{Name: "plan-" + strconv.Itoa(i), Date: "2027-01-21"}
+		if rec := serveAs(t, acme, ScenariosHandler, http.MethodPost, "/scenarios", s); rec.Code != http.StatusCreated {
+			t.Fatalf("acme %s: %d %s", s.Name, rec.Code, rec.Body)
+		}
+	}
+	if rec := serveAs(t, acme, ScenariosHandler, http.MethodPost, "/scenarios", models.Scenario{Name: "one-more", Date: "2027-01-21"}); rec.Code != http.StatusConflict {
+		t.Errorf("acme past the cap: %d", rec.Code)
+	}
+	// acme's full day neither blocks globex nor shows it which names acme used
+	if rec := serveAs(t, globex, ScenariosHandler, http.MethodPost, "/scenarios", models.Scenario{Name: "plan-0", Date: "2027-01-21"}); rec.Code != http.StatusCreated {
+		t.Errorf("globex plan-0: %d %s", rec.Code, rec.Body)
+	}
+	var list []models.Scenario
+	json.Unmarshal(serveAs(t, globex, ScenariosHandler, http.MethodGet, "/scenarios?limit=100", nil).Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Tenant != "globex" {
+		t.Errorf("globex lists %d scenarios", len(list))
+	}
+	if rec := serveAs(t, globex, ScenariosHandler, http.MethodDelete, "/scenarios?date=2027-01-21&name=plan-1&tenant=acme", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("globex deleting acme's plan-1: %d", rec.Code)
+	}
+	if rec := serveAs(t, acme, ScenariosHandler, http.MethodGet, "/scenarios?date=2027-01-21&name=plan-0", nil); !strings.Contains(rec.Body.String(), `"tenant":"acme"`) {
+		t.Errorf("acme's plan-0: %s", rec.Body)
+	}
+}
+
+func TestDensityGridsDispatchedStops(t *testing.T) {
+	depot, stop := models.Location{Lat: 12.97, Lng: 77.59}, models.Location{Lat: 12.93, Lng: 77.62}
+	for _, date := range []string{"2027-01-11", "2027-01-12"} {
+		plan := models.DispatchRequest{
+			Date:   date,
+			Routes: []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"D-" + date}, Route: []models.Location{depot, stop, depot}}},
+		}
+		if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+			t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec := serve(t, DensityHandler, http.MethodGet, "/analytics/density?from=2027-01-11&to=2027-01-12&precision=6", nil)
+	var grid models.DensityGrid
+	if err := json.Unmarshal(rec.Body.Bytes(), &grid); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if grid.Type != "FeatureCollection" || grid.Days != 2 || len(grid.Features) != 1 {
+		t.Fatalf("grid = %+v, want one cell over two days", grid)
+	}
+	if p := grid.Features[0].Properties; p.Stops != 2 || p.StopsPerDay != 1 || len(p.Geohash) != 6 {
+		t.Errorf("cell = %+v", p)
+	}
+
+	for _, target := range []string{
+		"/analytics/density?from=2027-01-12&to=2027-01-11",
+		"/analytics/density?from=2025-01-01&to=2027-01-11",
+		"/analytics/density?precision=8",
+		"/analytics/density?min_stops=0",
+	} {
+		if rec := serve(t, DensityHandler, http.MethodGet, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestDispatchBlockedByEWayBills(t *testing.T) {
+	depot, far := models.Location{Lat: 28.6, Lng: 77.2}, models.Location{Lat: 26.9, Lng: 75.8} // Delhi to Jaipur
+	plan := models.DispatchRequest{
+		Date: "2026-11-06",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{depot, far, far, depot}, ArrivalHours: []float64{6, 6.5}},
+		},
+		Shipments: []models.ShipmentDocs{
+			{StopID: "A", ValueInr: 90000, EWayBill: &models.EWayBill{Number: "331000123456", ValidUntil: time.Date(2026, 11, 7, 0, 0, 0, 0, time.UTC), DistanceKm: 280}},
+			{StopID: "B", ValueInr: 90000},
+		},
+	}
+	rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Shipments []models.ShipmentProblems `json:"shipments"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Shipments) != 1 || body.Shipments[0].StopID != "B" {
+		t.Errorf("blocked shipments = %+v, want only B", body.Shipments)
+	}
+	if _, ok := dispatched.Board("", plan.Date); ok {
+		t.Error("a blocked plan was published")
+	}
+
+	// Once required, a shipment without details blocks the plan too
+	RequireEWayBills(true, 50000)
+	t.Cleanup(func() { RequireEWayBills(false, 50000) })
+	plan.Shipments[1].EWayBill = plan.Shipments[0].EWayBill
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	plan.Date, plan.Shipments = "2026-11-07", plan.Shipments[:1]
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422 for B without details", rec.Code)
+	}
+}
+
+func TestDriverSeesOnlyOwnRun(t *testing.T) {
+	SetDriverSecret("test-secret")
+	t.Cleanup(func() { SetDriverSecret("") })
+
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-04",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A"}, Route: []models.Location{loc, loc, loc}},
+			{VehicleID: "V2", StopIDs: []string{"B"}, Route: []models.Location{loc, loc, loc}},
+		},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	token := auth.Sign([]byte("test-secret"), "V1", time.Now().Add(time.Hour))
+	call := func(h http.HandlerFunc, method, target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	if rec := call(DriverRouteHandler, http.MethodGet, "/driver/route?date=2026-11-04", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d", rec.Code)
+	}
+	rec := call(DriverNextStopHandler, http.MethodGet, "/driver/next-stop?date=2026-11-04", "", token)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":"A"`) || !strings.Contains(rec.Body.String(), "google.com/maps/dir") {
+		t.Fatalf("next stop: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// V2's stop is not on V1's run
+	if rec := call(DriverArriveHandler, http.MethodPost, "/driver/arrive", `{"date":"2026-11-04","stop_id":"B"}`, token); rec.Code != http.StatusNotFound {
+		t.Errorf("arriving at another vehicle's stop: status = %d", rec.Code)
+	}
+	if rec := call(DriverArriveHandler, http.MethodPost, "/driver/arrive", `{"date":"2026-11-04","stop_id":"A","track":"abc def"}`, token); rec.Code != http.StatusBadRequest {
+		t.Errorf("garbled track: status = %d", rec.Code)
+	}
+	if rec := call(DriverArriveHandler, http.MethodPost, "/driver/arrive", `{"date":"2026-11-04","stop_id":"A","track":"_p~iF~ps|U_ulLnnqC"}`, token); rec.Code != http.StatusOK {
+		t.Fatalf("arrive: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(DriverDepartHandler, http.MethodPost, "/driver/depart", `{"date":"2026-11-04","stop_id":"A"}`, token); rec.Code != http.StatusOK {
+		t.Fatalf("depart: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(DriverIssuesHandler, http.MethodPost, "/driver/issues", `{"date":"2026-11-04","kind":"vehicle","note":"puncture"}`, token); rec.Code != http.StatusOK {
+		t.Fatalf("issue: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	board, _ := dispatched.Board("", "2026-11-04")
+	if board.Runs[0].Status != dispatch.Completed || board.Runs[1].Status != dispatch.Pending {
+		t.Errorf("runs = %+v, want V1 completed and V2 untouched", board.Runs)
+	}
+	if len(board.Issues) != 1 || board.Issues[0].VehicleID != "V1" {
+		t.Errorf("issues = %+v", board.Issues)
+	}
+	if tr := board.Runs[0].Stops[0].Track; tr == nil || tr.Polyline != "_p~iF~ps|U_ulLnnqC" || tr.DrivenKm < 250 || tr.DrivenKm > 260 || tr.Source != "denoised" {
+		t.Errorf("track = %+v, want the reported one of about 255 km", tr)
+	}
+}
+
+func TestNavigationURLsSplitLongRoutes(t *testing.T) {
+	route := make([]models.Location, 25) // Depot, 23 stops, depot
+	for i := range route {
+		route[i] = models.Location{Lat: 28 + float64(i)/100, Lng: 77}
+	}
+	links := navigationURLs(route)
+	if len(links) != 3 {
+		t.Fatalf("%d links for 23 stops, want 3", len(links))
+	}
+	var prevDest string
+	visited := 0
+	for i, link := range links {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		waypoints := strings.Split(q.Get("waypoints"), "|")
+		if len(waypoints) > mapsMaxWaypoints {
+			t.Errorf("link %d has %d waypoints", i, len(waypoints))
+		}
+		if i > 0 && q.Get("origin") != prevDest {
+			t.Errorf("link %d starts at %s, not where link %d ended (%s)", i, q.Get("origin"), i-1, prevDest)
+		}
+		prevDest = q.Get("destination")
+		visited += len(waypoints) + 1
+	}
+	if visited != len(route)-1 || prevDest != "28.240000,77.000000" {
+		t.Errorf("links visit %d points ending at %s", visited, prevDest)
+	}
+}
+
+func TestShareLinkShowsOnlyItsRun(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date: "2026-11-05",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"SHIP-A"}, Route: []models.Location{loc, loc, loc}, ArrivalHours: []float64{1.5}},
+			{VehicleID: "V2", StopIDs: []string{"SHIP-B"}, Route: []models.Location{loc, loc, loc}},
+		},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	share := models.ShareRequest{Date: "2026-11-05", VehicleID: "V1"}
+	if rec := serve(t, ShareHandler, http.MethodPost, "/share", share); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("sharing without a secret: status = %d", rec.Code)
+	}
+	SetShareSecret("share-secret")
+	t.Cleanup(func() { SetShareSecret("") })
+
+	rec := serve(t, ShareHandler, http.MethodPost, "/share", share)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("share: status = %d: %s", rec.Code, rec.Body)
+	}
+	var link models.ShareLink
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = serve(t, SharedPlanHandler, http.MethodGet, link.URL, nil)
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, "SHIP-A") || strings.Contains(page, "SHIP-B") || !strings.Contains(page, "10:00–11:00") {
+		t.Errorf("page: status = %d:\n%s", rec.Code, page)
+	}
+	if rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Error("the token could leak through the Referer header")
+	}
+	rec = serve(t, SharedPlanHandler, http.MethodGet, link.CalendarURL, nil)
+	if feed := rec.Body.String(); rec.Code != http.StatusOK || strings.Count(feed, "BEGIN:VEVENT") != 1 || !strings.Contains(feed, "DTSTART:20261105T043000Z") {
+		t.Errorf("calendar: status = %d:\n%s", rec.Code, feed)
+	}
+
+	driverToken := auth.Sign([]byte("test-secret"), "2026-11-05/", time.Now().Add(time.Hour))
+	expired := auth.Sign([]byte("share-secret"), "2026-11-05/", time.Now().Add(-time.Minute))
+	for target, want := range map[string]int{"/shared/" + driverToken: http.StatusNotFound, "/shared/" + expired: http.StatusGone} {
+		if rec := serve(t, SharedPlanHandler, http.MethodGet, target, nil); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestForecastSizesFleetPerLane(t *testing.T) {
+	var req models.ForecastRequest
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for d := range 28 {
+		date := start.AddDate(0, 0, d).Format(time.DateOnly)
+		req.History = append(req.History,
+			models.DemandDay{Date: date, Lane: "DEL-JAI", Shipments: 30, WeightKg: 2400},
+			models.DemandDay{Date: date, Lane: "DEL-AGR", Shipments: 12, WeightKg: 900})
+	}
+	req.History = req.History[:len(req.History)-1] // DEL-AGR's last day is missing: a zero
+	req.HorizonDays, req.VehicleCapacityKg, req.StopsPerVehicle = 3, 1000, 20
+
+	rec := serve(t, ForecastHandler, http.MethodPost, "/forecast", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.ForecastResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Lanes) != 2 || resp.Lanes[1].Lane != "DEL-JAI" || resp.Lanes[1].Method != "holt_winters" {
+		t.Fatalf("lanes = %+v", resp.Lanes)
+	}
+	if len(resp.Total) != 3 || resp.Total[0].Date != "2026-09-29" {
+		t.Fatalf("total = %+v", resp.Total)
+	}
+	// 2400 kg on DEL-JAI needs 3 vehicles of 1000 kg; DEL-AGR's 12 drops
+	// need 1 whatever the dip on its last day
+	if jai := resp.Lanes[1].Days[0]; jai.Vehicles != 3 {
+		t.Errorf("DEL-JAI = %+v, want 3 vehicles", jai)
+	}
+	if resp.SuggestedFleetSize != 4 {
+		t.Errorf("suggested fleet = %d, want 4 (total %+v)", resp.SuggestedFleetSize, resp.Total)
+	}
+}
+
+func TestOptimizeRouteV2KeepsNames(t *testing.T) {
+	point := func(id, name string, lat, lng float64) models.RoutePoint {
+		return models.RoutePoint{ID: id, Name: name, Location: models.Location{Lat: lat, Lng: lng}}
+	}
+	req := models.OptimizationRequestV2{
+		Start: point("depot", "Okhla depot", 28.53, 77.27),
+		End:   point("depot", "Okhla depot", 28.53, 77.27),
+		Waypoints: []models.RoutePoint{
+			point("c1", "Karol Bagh", 28.65, 77.19),
+			point("c2", "Saket", 28.52, 77.21),
+			point("c3", "Rohini", 28.74, 77.11),
+		},
+	}
+	rec := serve(t, OptimizeRouteV2Handler, http.MethodPost, "/v2/optimize?geometry=polyline", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.OptimizationResponseV2
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.APIVersion != "2" || len(resp.Stops) != 5 || len(resp.Legs) != 4 {
+		t.Fatalf("got %d stops and %d legs: %+v", len(resp.Stops), len(resp.Legs), resp)
+	}
+	if first, last := resp.Stops[0], resp.Stops[4]; first.Kind != "start" || last.Kind != "end" || first.Name != "Okhla depot" {
+		t.Errorf("endpoints = %+v, %+v", first, last)
+	}
+	for i, st := range resp.Stops[1:4] {
+		wp := req.Waypoints[*st.WaypointIndex]
+		if st.Kind != "waypoint" || st.ID != wp.ID || st.Name != wp.Name || st.Location.Lat != wp.Lat {
+			t.Errorf("stop %d = %+v, want waypoint %+v", i+1, st, wp)
+		}
+	}
+	legs := 0.0
+	for _, l := range resp.Legs {
+		legs += l.DistanceKm
+	}
+	if diff := legs - resp.TotalDistKm; diff > 1e-6 || diff < -1e-6 || resp.Stops[4].CumulativeKm-legs > 1e-6 {
+		t.Errorf("legs sum to %v, total %v, last cumulative %v", legs, resp.TotalDistKm, resp.Stops[4].CumulativeKm)
+	}
+	path, err := geo.DecodePolyline(resp.Polyline, geo.Precision5)
+	if err != nil || len(path) != 5 || path[2].Lat != resp.Stops[2].Location.Lat {
+		t.Errorf("polyline %q decodes to %v, %v", resp.Polyline, path, err)
+	}
+}
+
+func TestDeprecatedRouteAnnouncesSuccessor(t *testing.T) {
+	legacy := Deprecation{Since: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), Sunset: time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)}
+	rec := httptest.NewRecorder()
+	legacy.Wrap("/v1/solvers", http.HandlerFunc(SolversHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/solvers", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	h := rec.Header()
+	if h.Get("Deprecation") != "@1792022400" || h.Get("Sunset") != "Thu, 01 Apr 2027 00:00:00 GMT" || h.Get("Link") != `</v1/solvers>; rel="successor-version"` {
+		t.Errorf("headers = %v", h)
+	}
+	if routePath("/v1/optimize-fleet") != "/optimize-fleet" || routePath("/optimize") != "/optimize" {
+		t.Error("routePath should strip only the version prefix")
+	}
+}
+
+func TestListPagesFollowLinks(t *testing.T) {
+	var want []string
+	for _, s := range solver.List() {
+		want = append(want, s.Name())
+	}
+	slices.Sort(want)
+	slices.Reverse(want)
+
+	var got []string
+	target := "/v1/solvers?limit=2&sort=-id"
+	for target != "" {
+		rec := serve(t, SolversHandler, http.MethodGet, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var page []struct{ Name string }
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 2 || rec.Header().Get("X-Total-Count") != strconv.Itoa(len(want)) {
+			t.Fatalf("page of %d, total %q", len(page), rec.Header().Get("X-Total-Count"))
+		}
+		for _, p := range page {
+			got = append(got, p.Name)
+		}
+		target, _, _ = strings.Cut(strings.TrimPrefix(rec.Header().Get("Link"), "<"), ">")
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("paged %v, want %v", got, want)
+	}
+
+	rec := serve(t, DatasetsHandler, http.MethodGet, "/v1/datasets?sort=-points&points=0", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Count") != "0" {
+		t.Errorf("filter: status %d, total %q", rec.Code, rec.Header().Get("X-Total-Count"))
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		want    int
+	}{
+		{"wrong method", OptimizeRouteHandler, http.MethodGet, "/optimize", "", http.StatusMethodNotAllowed},
+		{"bad json", OptimizeRouteHandler, http.MethodPost, "/optimize", "{", http.StatusBadRequest},
+		{"unknown solver", OptimizeRouteHandler, http.MethodPost, "/optimize?solver=nope", "{}", http.StatusBadRequest},
+		{"load solver on route", OptimizeRouteHandler, http.MethodPost, "/optimize?solver=best-fit-decreasing", "{}", http.StatusBadRequest},
+		{"bad matrix", OptimizeRouteHandler, http.MethodPost, "/optimize", `{"distance_matrix":[[0]]}`, http.StatusBadRequest},
+		{"zero weight", OptimizeLoadHandler, http.MethodPost, "/optimize-load", `{"shipments":[{"id":"A","weight_kg":0}]}`, http.StatusBadRequest},
+		{"no vehicles", OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", `{"stops":[]}`, http.StatusBadRequest},
+		{"window closes before it opens", OptimizeFleetHandler, http.MethodPost, "/optimize-fleet",
+			`{"vehicles":[{"id":"V1","capacity_kg":100}],"stops":[{"id":"A","demand_kg":1,"ready_hours":5,"due_hours":2}]}`, http.StatusBadRequest},
+		{"vehicle start with matrix", OptimizeFleetHandler, http.MethodPost, "/optimize-fleet",
+			`{"depot":{"lat":28.6,"lng":77.2},"vehicles":[{"id":"V1","capacity_kg":100,"start":{"lat":28.7,"lng":77.1}}],"stops":[],"distance_matrix":[[0]]}`, http.StatusBadRequest},
+		{"route solver on fleet", OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?solver=two-opt",
+			`{"vehicles":[{"id":"V1","capacity_kg":100}]}`, http.StatusBadRequest},
+		{"unknown template", InstantiateTemplateHandler, http.MethodPost, "/templates/instantiate", `{"template":"nope","date":"2026-10-15"}`, http.StatusNotFound},
+		{"bad template date", InstantiateTemplateHandler, http.MethodPost, "/templates/instantiate", `{"template":"nope","date":"15/10/2026"}`, http.StatusBadRequest},
+		{"bad standing order schedule", StandingOrdersHandler, http.MethodPost, "/standing-orders",
+			`{"id":"S1","template":"t","schedule":"FREQ=HOURLY","start_date":"2026-10-01"}`, http.StatusBadRequest},
+		{"bad calendar range", CalendarHandler, http.MethodGet, "/calendar?from=2026-12-01&to=2026-01-01", "", http.StatusBadRequest},
+		{"bad working days", WorkingDaysHandler, http.MethodGet, "/calendar/working-days?date=2026-10-01&add=-1", "", http.StatusBadRequest},
+		{"no working days", WorkingDaysHandler, http.MethodGet, "/calendar/working-days?date=2026-10-01&weekly_off=sun,mon,tue,wed,thu,fri,sat", "", http.StatusBadRequest},
+		{"bad dispatch date", DispatchHandler, http.MethodGet, "/dispatch?date=03-11-2026", "", http.StatusBadRequest},
+		{"bad list limit", SolversHandler, http.MethodGet, "/solvers?limit=0", "", http.StatusBadRequest},
+		{"unknown list sort", ProfilesHandler, http.MethodGet, "/profiles?sort=colour", "", http.StatusBadRequest},
+		{"bad list cursor", TemplatesHandler, http.MethodGet, "/templates?cursor=!!", "", http.StatusBadRequest},
+		{"nothing dispatched", DispatchHandler, http.MethodGet, "/dispatch?date=1999-01-01", "", http.StatusNotFound},
+		{"unknown dispatched stop", DispatchStatusHandler, http.MethodPost, "/dispatch/status",
+			`{"date":"1999-01-01","vehicle_id":"V1","stop_id":"A","status":"completed"}`, http.StatusNotFound},
+		{"bad audit range", AuditHandler, http.MethodGet, "/audit?since=yesterday", "", http.StatusBadRequest},
+		{"template without routes", TemplatesHandler, http.MethodPost, "/templates", `{"name":"empty"}`, http.StatusBadRequest},
+		{"downtime ends before it starts", MaintenanceHandler, http.MethodPost, "/maintenance",
+			`{"vehicle_id":"V1","downtime":[{"from":"2026-10-16","to":"2026-10-14"}]}`, http.StatusBadRequest},
+		{"unknown maintenance schedule", MaintenanceHandler, http.MethodDelete, "/maintenance?vehicle_id=nope", "", http.StatusNotFound},
+		{"full tank without litres", FuelHandler, http.MethodPost, "/fuel",
+			`{"vehicle_id":"V1","at":"2026-10-01T08:00:00Z","odometer_km":100,"full_tank":true}`, http.StatusBadRequest},
+		{"bad efficiency since", FuelEfficiencyHandler, http.MethodGet, "/fuel/efficiency?since=last-week", "", http.StatusBadRequest},
+		{"overload check without a GVW", OverloadCheckHandler, http.MethodPost, "/compliance/overload",
+			`{"vehicles":[{"id":"TT","class":"tractor-trailer"}],"routes":[{"vehicle_id":"TT","load_kg":1}]}`, http.StatusBadRequest},
+		{"overload check of an unknown vehicle", OverloadCheckHandler, http.MethodPost, "/compliance/overload",
+			`{"vehicles":[{"id":"T1","class":"lcv"}],"actual":[{"vehicle_id":"T9","gross_kg":1}]}`, http.StatusBadRequest},
+		{"empty plan", ValidatePlanHandler, http.MethodPost, "/validate-plan", "{}", http.StatusBadRequest},
+		{"empty forecast history", ForecastHandler, http.MethodPost, "/forecast", `{"history":[]}`, http.StatusBadRequest},
+		{"short holt-winters history", ForecastHandler, http.MethodPost, "/forecast",
+			`{"method":"holt_winters","history":[{"date":"2026-10-01","lane":"DEL-JAI","shipments":4}]}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugBundlesReplaySolves(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 8, Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Carriers = []models.Carrier{{Name: "Acme Logistics", BaseInr: 1e6}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/optimize-fleet", OptimizeFleetHandler)
+	h := Debug(mux)
+	defer ConfigureReplay(replayHandler)
+	ConfigureReplay(h)
+
+	rec := serve(t, h.ServeHTTP, http.MethodPost, "/v1/optimize-fleet?solver=alns&pool=false", req)
+	id := rec.Header().Get(DebugHeader)
+	if rec.Code != http.StatusOK || id == "" {
+		t.Fatalf("status = %d, bundle %q: %s", rec.Code, id, rec.Body)
+	}
+	var solved models.FleetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &solved); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = serve(t, DebugBundlesHandler, http.MethodGet, "/v1/debug/bundles?id="+id, nil)
+	var b debugBundle
+	if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("bundle: %d %s", rec.Code, rec.Body)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("bundle is not a download: %v", rec.Header())
+	}
+	if b.Path != "/optimize-fleet" || b.Solver != "alns" || b.Status != http.StatusOK || len(b.Trace.Seeds) != 1 || len(b.Trace.Notes) == 0 {
+		t.Errorf("bundle = %+v", b)
+	}
+	if bytes.Contains(b.Request, []byte("Acme")) || !bytes.Contains(b.Request, []byte(`"redacted"`)) {
+		t.Errorf("request kept names: %s", b.Request)
+	}
+
+	// Shared with support, it keeps no IDs or exact places
+	rec = serve(t, DebugBundlesHandler, http.MethodGet, "/v1/debug/bundles?id="+id+"&anonymize=true", nil)
+	var shared debugBundle
+	if err := json.Unmarshal(rec.Body.Bytes(), &shared); err != nil || rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), "anonymized") {
+		t.Fatalf("anonymized bundle: %d %s", rec.Code, rec.Body)
+	}
+	var sharedReq models.FleetRequest
+	json.Unmarshal(shared.Request, &sharedReq)
+	if len(sharedReq.Stops) != len(req.Stops) || sharedReq.Stops[0].ID == req.Stops[0].ID || sharedReq.Stops[0].Location == req.Stops[0].Location ||
+		sharedReq.Vehicles[0].ID == req.Vehicles[0].ID || sharedReq.Vehicles[0].CapacityKg != req.Vehicles[0].CapacityKg {
+		t.Errorf("anonymized request: %s", shared.Request)
+	}
+
+	rec = serve(t, DebugReplayHandler, http.MethodPost, "/v1/debug/replay?id="+id, nil)
+	var replay debugReplay
+	if err := json.Unmarshal(rec.Body.Bytes(), &replay); err != nil || rec.Code != http.StatusOK || replay.Status != http.StatusOK {
+		t.Fatalf("replay: %d %s", rec.Code, rec.Body)
+	}
+	var again models.FleetResponse
+	if err := json.Unmarshal(replay.Response, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.TotalDistKm != solved.TotalDistKm || !slices.Equal(replay.Trace.Seeds, b.Trace.Seeds) {
+		t.Errorf("replay = %.3f km, seeds %v; solve was %.3f km, seeds %v", again.TotalDistKm, replay.Trace.Seeds, solved.TotalDistKm, b.Trace.Seeds)
+	}
+	if len(replay.Trace.Notes) <= len(b.Trace.Notes) {
+		t.Errorf("replay trace is not verbose: %d notes, solve had %d", len(replay.Trace.Notes), len(b.Trace.Notes))
+	}
+	if rec := serve(t, DebugReplayHandler, http.MethodPost, "/v1/debug/replay?id=missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown bundle: status = %d", rec.Code)
+	}
+}
+
+func TestDebugBundlesRedactAndStayBounded(t *testing.T) {
+	body := `{"stops":[{"id":"S1","city":"New Delhi","eway_bill":{"number":"EWB-123"},"demand_kg":5}],"url":"https://x.example/?sig=1"}`
+	req, names := sanitize([]byte(body))
+	got := string(req)
+	for _, leak := range []string{"New Delhi", "EWB-123", "sig="} {
+		if strings.Contains(got, leak) {
+			t.Errorf("bundle keeps %q: %s", leak, got)
+		}
+	}
+	if !strings.Contains(got, `"S1"`) || !strings.Contains(got, `"demand_kg":5`) {
+		t.Errorf("bundle lost the solve's shape: %s", got)
+	}
+	if msg := redactText("S1 in New Delhi has bill EWB-123", names); msg != "S1 in redacted has bill redacted" {
+		t.Errorf("error = %q", msg)
+	}
+
+	a, _ := anonymizer(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "acme")
+	shared := anonymizeBundle(a, debugBundle{Query: "stop_id=S1&customer=Asha&solver=alns", Request: req, Error: "stop S1 is out of reach"})
+	if strings.Contains(shared.Query, "S1") || strings.Contains(shared.Query, "Asha") || !strings.Contains(shared.Query, "solver=alns") || strings.Contains(shared.Error, "S1") {
+		t.Errorf("anonymized query %q, error %q", shared.Query, shared.Error)
+	}
+
+	var ring bundleRing
+	big := json.RawMessage(`"` + strings.Repeat("x", debugBodyBytes-2) + `"`)
+	for i := range 3 * debugBytes / debugBodyBytes {
+		ring.add(debugBundle{ID: strconv.Itoa(i), Request: big})
+	}
+	if ring.bytes > debugBytes || len(ring.bundles) != debugBytes/debugBodyBytes || ring.bundles[len(ring.bundles)-1].ID != strconv.Itoa(3*debugBytes/debugBodyBytes-1) {
+		t.Errorf("ring holds %d bundles, %d bytes", len(ring.bundles), ring.bytes)
+	}
+}
+
+func TestFeatureFlagsTrialSolversWithOneTenant(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	t.Cleanup(func() { featureFlags.Replace(nil) })
+	as := func(h http.HandlerFunc, tenant, role, method, target string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, target, &buf)
+		token := auth.Sign([]byte("planner-secret"), auth.Principal{Tenant: tenant, Role: role}.Subject(), time.Now().Add(time.Hour))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	trial := flags.Flag{Name: flags.SolverPrefix + "tabu", Tenants: []string{"acme"}}
+	if rec := as(FlagsHandler, "acme", auth.RolePlanner, http.MethodPost, "/flags", trial); rec.Code != http.StatusForbidden {
+		t.Errorf("planner saved a flag: %d", rec.Code)
+	}
+	if rec := as(FlagsHandler, "ops", auth.RoleAdmin, http.MethodPost, "/flags", trial); rec.Code != http.StatusOK {
+		t.Fatalf("admin save: %d %s", rec.Code, rec.Body)
+	}
+	featureFlags.Put(flags.Flag{Name: flags.ConstraintPrefix + "time_windows", Tenants: []string{"acme"}})
+
+	fleet, err := generator.FleetRequest(generator.Config{Size: 6, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := as(OptimizeFleetHandler, "acme", auth.RolePlanner, http.MethodPost, "/optimize-fleet?solver=tabu", fleet); rec.Code != http.StatusOK {
+		t.Errorf("acme on tabu: %d %s", rec.Code, rec.Body)
+	}
+	if rec := as(OptimizeFleetHandler, "globex", auth.RolePlanner, http.MethodPost, "/optimize-fleet?solver=tabu", fleet); rec.Code != http.StatusForbidden {
+		t.Errorf("globex on tabu: %d %s", rec.Code, rec.Body)
+	}
+	var solvers []struct{ Name string }
+	json.Unmarshal(as(SolversHandler, "globex", auth.RoleViewer, http.MethodGet, "/solvers", nil).Body.Bytes(), &solvers)
+	for _, s := range solvers {
+		if s.Name == "tabu" {
+			t.Error("globex is offered tabu")
+		}
+	}
+
+	fleet.Stops[0].DueHours = 100
+	if rec := as(OptimizeFleetHandler, "globex", auth.RolePlanner, http.MethodPost, "/optimize-fleet", fleet); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "time_windows") {
+		t.Errorf("globex with time windows: %d %s", rec.Code, rec.Body)
+	}
+
+	var list []flagStatus
+	json.Unmarshal(as(FlagsHandler, "globex", auth.RoleViewer, http.MethodGet, "/flags", nil).Body.Bytes(), &list)
+	if len(list) != 2 || list[0].On || list[0].Tenants != nil {
+		t.Errorf("globex sees %+v", list)
+	}
+	if rec := as(FlagsHandler, "ops", auth.RoleAdmin, http.MethodDelete, "/flags?name=solver.tabu", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", rec.Code)
+	}
+	fleet.Stops[0].DueHours = 0
+	if rec := as(OptimizeFleetHandler, "globex", auth.RolePlanner, http.MethodPost, "/optimize-fleet?solver=tabu&dry_run=true", fleet); rec.Code != http.StatusOK {
+		t.Errorf("tabu after its flag was dropped: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRestrictedSolversAreNotReachedThroughDefaultsOrAuto(t *testing.T) {
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	t.Cleanup(func() { featureFlags.Replace(nil) })
+	featureFlags.Put(flags.Flag{Name: flags.SolverPrefix + "alns", Tenants: []string{"acme"}})
+	globex := auth.Principal{Tenant: "globex", Role: auth.RolePlanner}
+
+	fleet, err := generator.FleetRequest(generator.Config{Size: 6, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveAs(t, globex, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?solver=alns", fleet); rec.Code != http.StatusForbidden {
+		t.Errorf("globex on alns: %d %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{"/optimize-fleet", "/optimize-fleet?solver=auto"} {
+		rec := serveAs(t, globex, OptimizeFleetHandler, http.MethodPost, target, fleet)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", target, rec.Code, rec.Body)
+		}
+		var resp models.FleetResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Meta == nil || resp.Meta.Solver == "alns" || !strings.Contains(resp.Meta.Reason, "alns is not enabled") {
+			t.Errorf("%s ran %+v", target, resp.Meta)
+		}
+	}
+
+	acme := auth.Principal{Tenant: "acme", Role: auth.RolePlanner}
+	var resp models.FleetResponse
+	json.Unmarshal(serveAs(t, acme, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", fleet).Body.Bytes(), &resp)
+	if resp.Meta == nil || resp.Meta.Solver != "alns" {
+		t.Errorf("acme ran %+v", resp.Meta)
+	}
+}
+
+func TestExperimentsSplitAndShadowSolves(t *testing.T) {
+	t.Cleanup(func() {
+		for _, r := range experimentRegistry.List() {
+			experimentRegistry.Stop(r.Name)
+		}
+	})
+	fleet, err := generator.FleetRequest(generator.Config{Size: 6, Seed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := experiments.Experiment{Name: "bad", Endpoint: "/optimize-fleet", Mode: experiments.ModeSplit, Percent: 50, Variant: experiments.Arm{Solver: "two-opt"}}
+	if rec := serve(t, ExperimentsHandler, http.MethodPost, "/experiments", bad); rec.Code != http.StatusBadRequest {
+		t.Errorf("variant that cannot plan fleets: %d %s", rec.Code, rec.Body)
+	}
+	split := experiments.Experiment{Name: "tabu-trial", Endpoint: "/optimize-fleet", Mode: experiments.ModeSplit, Percent: 100, Variant: experiments.Arm{Solver: "tabu"}}
+	if rec := serve(t, ExperimentsHandler, http.MethodPost, "/experiments", split); rec.Code != http.StatusOK {
+		t.Fatalf("start: %d %s", rec.Code, rec.Body)
+	}
+	var resp models.FleetResponse
+	json.Unmarshal(serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", fleet).Body.Bytes(), &resp)
+	if resp.Meta == nil || resp.Meta.Solver != "tabu" || resp.Meta.Experiment != "tabu-trial/variant" {
+		t.Errorf("meta = %+v", resp.Meta)
+	}
+	resp = models.FleetResponse{}
+	json.Unmarshal(serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet?solver=alns", fleet).Body.Bytes(), &resp)
+	if resp.Meta == nil || resp.Meta.Experiment != "" {
+		t.Errorf("pinned solver enrolled: %+v", resp.Meta)
+	}
+	if r, _ := experimentRegistry.Get("tabu-trial"); r.Variant.Runs != 1 || r.Control.Runs != 0 || r.Variant.MeanKm <= 0 {
+		t.Errorf("split report = %+v", r)
+	}
+
+	shadow := split
+	shadow.Mode = experiments.ModeShadow
+	if rec := serve(t, ExperimentsHandler, http.MethodPost, "/experiments", shadow); rec.Code != http.StatusOK {
+		t.Fatalf("restart as shadow: %d %s", rec.Code, rec.Body)
+	}
+	resp = models.FleetResponse{}
+	json.Unmarshal(serve(t, OptimizeFleetHandler, http.MethodPost, "/optimize-fleet", fleet).Body.Bytes(), &resp)
+	if resp.Meta == nil || resp.Meta.Solver != defaultFleetSolver || resp.Meta.Experiment != "tabu-trial/shadow" {
+		t.Errorf("shadowed meta = %+v", resp.Meta)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := serve(t, ExperimentsHandler, http.MethodGet, "/experiments?name=tabu-trial", nil)
+		var r experiments.Report
+		json.Unmarshal(rec.Body.Bytes(), &r)
+		if r.Pairs != nil && r.Pairs.Count == 1 {
+			if r.Control.Runs != 1 || r.Variant.Runs != 1 || r.Pairs.VariantBetter+r.Pairs.ControlBetter+r.Pairs.Ties != 1 {
+				t.Errorf("shadow report = %+v %+v", r, r.Pairs)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow solve never reported: %s", rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := serve(t, ExperimentsHandler, http.MethodDelete, "/experiments?name=tabu-trial", nil); rec.Code != http.StatusOK {
+		t.Errorf("stop: %d", rec.Code)
+	}
+}
+
+func TestShadowReferenceFlagsRegressions(t *testing.T) {
+	if err := ConfigureRegressionShadowing(map[string]string{"/optimize-fleet": "two-opt"}, 5); err == nil {
+		t.Error("reference that cannot plan fleets accepted")
+	}
+	if err := ConfigureRegressionShadowing(map[string]string{"/optimize": "exact"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ConfigureRegressionShadowing(map[string]string{}, 5)
+		regressions = regressionLog{}
+	})
+	req, err := generator.RouteRequest(generator.Config{Size: 9, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(t, OptimizeRouteHandler, http.MethodPost, "/optimize?solver=nearest-neighbor", req); rec.Code != http.StatusOK {
+		t.Fatalf("optimize: %d %s", rec.Code, rec.Body)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := serve(t, RegressionsHandler, http.MethodGet, "/regressions", nil)
+		var list []regression
+		json.Unmarshal(rec.Body.Bytes(), &list)
+		if len(list) == 1 {
+			reg := list[0]
+			if reg.Solver != "nearest-neighbor" || reg.Reference != "exact" || reg.Endpoint != "/optimize" || reg.GapPct <= 1 {
+				t.Errorf("regression = %+v", reg)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("regression never flagged: %s", rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// recordingBroker keeps what is published to it
+type recordingBroker struct {
+	mu   sync.Mutex
+	sent map[string][]events.Event
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, topic string, msg []byte) error {
+	var ev events.Event
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent[topic] = append(b.sent[topic], ev)
+	return nil
+}
+
+func (b *recordingBroker) Subscribe(ctx context.Context, topic string, fn func([]byte)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (b *recordingBroker) Close() error { return nil }
+
+func TestEventsSyncRegistriesAndAnnouncePlans(t *testing.T) {
+	t.Cleanup(func() {
+		eventPublisher = nil
+		shipmentRegistry = map[registryKey]registeredShipment{}
+		vehicleRegistry = map[registryKey]registeredVehicle{}
+	})
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	apply := func(apply func(events.Event) error, at time.Time, data string) error {
+		return apply(events.Event{ID: "e", Tenant: "acme", OccurredAt: at, Data: json.RawMessage(data)})
+	}
+	if err := apply(applyShipmentCreated, at, `{"id":"S1","location":{"lat":28.6,"lng":77.2},"demand_kg":40,"date":"2026-10-16"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(applyShipmentCreated, at, `{"id":"S2","location":{"lat":128.6,"lng":77.2}}`); err == nil {
+		t.Error("shipment off the map registered")
+	}
+	apply(applyVehicleUpdated, at, `{"id":"V1","capacity_kg":900}`)
+	apply(applyVehicleUpdated, at.Add(-time.Minute), `{"id":"V1","capacity_kg":500}`) // Late
+	apply(applyVehicleUpdated, at, `{"id":"V2","capacity_kg":700}`)
+	apply(applyVehicleUpdated, at.Add(time.Minute), `{"id":"V2","retired":true}`)
+
+	var ships []registeredShipment
+	json.Unmarshal(serve(t, ShipmentsHandler, http.MethodGet, "/shipments?date=2026-10-16", nil).Body.Bytes(), &ships)
+	if len(ships) != 1 || ships[0].ID != "S1" || ships[0].Tenant != "acme" || ships[0].DemandKg != 40 {
+		t.Errorf("shipments = %+v", ships)
+	}
+	var fleet []registeredVehicle
+	json.Unmarshal(serve(t, VehiclesHandler, http.MethodGet, "/vehicles", nil).Body.Bytes(), &fleet)
+	if len(fleet) != 1 || fleet[0].ID != "V1" || fleet[0].CapacityKg != 900 {
+		t.Errorf("vehicles = %+v", fleet)
+	}
+
+	broker := &recordingBroker{sent: map[string][]events.Event{}}
+	ConfigureEvents(broker, "mc.")
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	far := models.Location{Lat: 28.9, Lng: 77.5}
+	plan := models.DispatchRequest{
+		Date: "2026-11-24",
+		Routes: []models.FleetRoute{
+			{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, far, loc, loc}},
+		},
+	}
+	SetPlannerSecret("planner-secret")
+	t.Cleanup(func() { SetPlannerSecret("") })
+	acme := auth.Principal{Tenant: "acme", Role: auth.RolePlanner}
+	rec := serveAs(t, acme, DispatchHandler, http.MethodPost, "/dispatch", plan)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("publishing: %d %s", rec.Code, rec.Body)
+	}
+	plan.Routes[0].VehicleID = "V3"
+	body, _ := json.Marshal(plan)
+	req := httptest.NewRequest(http.MethodPost, "/dispatch", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+auth.Sign([]byte("planner-secret"), acme.Subject(), time.Now().Add(time.Hour)))
+	req.Header.Set("If-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	if DispatchHandler(rec, req); rec.Code != http.StatusOK {
+		t.Fatalf("replanning: %d %s", rec.Code, rec.Body)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		broker.mu.Lock()
+		plans, etas := broker.sent["mc.plan.published"], broker.sent["mc.eta.changed"]
+		broker.mu.Unlock()
+		if len(plans) == 2 && len(etas) == 2 {
+			var p events.Plan
+			if err := json.Unmarshal(plans[1].Data, &p); err != nil || p.Date != "2026-11-24" || p.Version != 2 || !slices.Equal(p.Vehicles, []string{"V3"}) {
+				t.Errorf("plan event = %s", plans[1].Data)
+			}
+			if plans[1].Tenant != "acme" || etas[0].Tenant != "acme" {
+				t.Errorf("events for tenants %q and %q, want acme", plans[1].Tenant, etas[0].Tenant)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("published %d plans and %d ETA changes", len(plans), len(etas))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShipmentsExpire(t *testing.T) {
+	t.Cleanup(func() { shipmentRegistry = map[registryKey]registeredShipment{} })
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	for id, s := range map[string]registeredShipment{
+		"due-yesterday": {Shipment: events.Shipment{Date: "2026-10-14"}, CreatedAt: now.AddDate(0, 0, -40)},
+		"due-last-week": {Shipment: events.Shipment{Date: "2026-10-08"}, CreatedAt: now},
+		"undated":       {CreatedAt: now.AddDate(0, 0, -29)},
+		"undated-old":   {CreatedAt: now.AddDate(0, 0, -31)},
+	} {
+		s.ID = id
+		shipmentRegistry[registryKey{"acme", id}] = s
+	}
+	if n := expireShipments(now); n != 2 {
+		t.Errorf("expired %d shipments, want 2", n)
+	}
+	for _, id := range []string{"due-yesterday", "undated"} {
+		if _, ok := shipmentRegistry[registryKey{"acme", id}]; !ok {
+			t.Errorf("%s expired", id)
+		}
+	}
+}
+
+func TestConnectorsReceivePlanChanges(t *testing.T) {
+	t.Setenv("WMS_KEY", "wms-api-key-123")
+	var mu sync.Mutex
+	var batches []string
+	wms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		batches = append(batches, r.Header.Get("X-Api-Key")+" "+string(body))
+		mu.Unlock()
+	}))
+	defer wms.Close()
+	err := ConfigureConnectors([]connector.Connector{{
+		Name:    "wms",
+		Kind:    connector.KindHTTP,
+		URL:     wms.URL + "?token=wms-url-token",
+		Format:  connector.FormatNDJSON,
+		Headers: map[string]string{"X-Api-Key": "$WMS_KEY"},
+		Fields:  []connector.Field{{Name: "op", Template: "{{.Op}}"}, {Name: "order", Template: "{{.ShipmentID}}"}, {Name: "route", Template: "{{.VehicleID}}"}},
+	}, {
+		Name:    "acme-wms",
+		Tenant:  "acme",
+		Kind:    connector.KindHTTP,
+		URL:     wms.URL,
+		Headers: map[string]string{"X-Api-Key": "acme"},
+	}}, nil, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sinks = nil })
+
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date:   "2026-11-25",
+		Routes: []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, loc, loc, loc}}},
+	}
+	rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("publishing: %d %s", rec.Code, rec.Body)
+	}
+	plan.Routes[0].StopIDs, plan.Routes[0].Route = []string{"A"}, []models.Location{loc, loc, loc}
+	if rec := serveIfMatch(t, DispatchHandler, http.MethodPost, "/dispatch", plan, rec.Header().Get("ETag")); rec.Code != http.StatusOK {
+		t.Fatalf("replanning: %d %s", rec.Code, rec.Body)
+	}
+	sink := sinks[0]
+	for _, s := range sinks {
+		s.Close()
+	}
+	sinks = nil
+
+	want := []string{
+		"wms-api-key-123 {\"op\":\"upsert\",\"order\":\"A\",\"route\":\"V1\"}\n{\"op\":\"upsert\",\"order\":\"B\",\"route\":\"V1\"}\n",
+		"wms-api-key-123 {\"op\":\"delete\",\"order\":\"B\",\"route\":\"\"}\n",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(batches, want) {
+		t.Errorf("delivered %q", batches)
+	}
+
+	sinks = []*connector.Sink{sink}
+	var list []struct {
+		Name      string            `json:"name"`
+		URL       string            `json:"url"`
+		Headers   map[string]string `json:"headers"`
+		Delivered int               `json:"delivered_items"`
+	}
+	json.Unmarshal(serve(t, ConnectorsHandler, http.MethodGet, "/connectors", nil).Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Delivered != 3 || list[0].Headers["X-Api-Key"] != "****" || strings.Contains(list[0].URL, "wms-url-token") {
+		t.Errorf("connectors = %+v", list)
+	}
+}
+
+func TestDispatchSummaryDownloadsAndMails(t *testing.T) {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date:       "2026-11-26",
+		Routes:     []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, loc, loc, loc}}},
+		Unassigned: []string{"C"},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch?tenant=acme", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: %d %s", rec.Code, rec.Body)
+	}
+	rec := serve(t, DispatchSummaryHandler, http.MethodGet, "/dispatch/summary?tenant=acme&date=2026-11-26", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rec.Body.String(), "%PDF-") {
+		t.Errorf("pdf: %d %.40q", rec.Code, rec.Body)
+	}
+	rec = serve(t, DispatchSummaryHandler, http.MethodGet, "/dispatch/summary?tenant=acme&date=2026-11-26&format=xlsx", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != xlsx.ContentType {
+		t.Errorf("xlsx: %d %s", rec.Code, rec.Header())
+	}
+	if rec := serve(t, DispatchSummaryHandler, http.MethodGet, "/dispatch/summary?tenant=acme&date=2026-11-26&format=docx", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("docx: %d", rec.Code)
+	}
+	if rec := serve(t, DispatchSummaryHandler, http.MethodPost, "/dispatch/summary?tenant=acme&date=2026-11-26", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("mailing without a relay: %d", rec.Code)
+	}
+
+	// A relay that takes one message and reports its recipients
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	rcpts := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 relay")
+		var to []string
+		for data := false; ; {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case data && line == ".":
+				data = false
+				tp.PrintfLine("250 queued")
+			case data:
+			case strings.HasPrefix(line, "RCPT TO:"):
+				to = append(to, strings.TrimPrefix(line, "RCPT TO:"))
+				tp.PrintfLine("250 ok")
+			case line == "DATA":
+				data = true
+				tp.PrintfLine("354 go ahead")
+			case line == "QUIT":
+				tp.PrintfLine("221 bye")
+				rcpts <- to
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	m, err := email.New("smtp://"+ln.Addr().String(), "plans@milesconnect.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ConfigureSummaryEmail(m)
+	dir := t.TempDir()
+	if err := OpenJobStore(dir, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tenantsMu.Lock()
+	tenantSettings["acme"] = templates.TenantSettings{Tenant: "acme", Summary: &templates.SummarySchedule{Recipients: []string{"ops@acme.example"}, At: "07:30", Formats: []string{"xlsx"}}}
+	tenantsMu.Unlock()
+	t.Cleanup(func() {
+		mailer, jobStore = nil, nil
+		tenantsMu.Lock()
+		delete(tenantSettings, "acme")
+		tenantsMu.Unlock()
+	})
+
+	if rec := serve(t, DispatchSummaryHandler, http.MethodGet, "/dispatch/summary?tenant=globex&date=2026-11-26", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("another tenant's summary: %d", rec.Code)
+	}
+
+	day := time.Date(2026, 11, 26, 0, 0, 0, 0, notifyPolicy.Zone)
+	sendDueSummaries(context.Background(), day.Add(7*time.Hour)) // Not yet due; no connection
+	sendDueSummaries(context.Background(), day.Add(8*time.Hour))
+	select {
+	case to := <-rcpts:
+		if !slices.Equal(to, []string{"<ops@acme.example>"}) {
+			t.Errorf("mailed %v", to)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing mailed")
+	}
+	ln.Close()
+	sent, failed := summariesSent.Value(), summariesFailed.Value()
+	sendDueSummaries(context.Background(), day.Add(9*time.Hour)) // Already sent today
+	if err := OpenJobStore(dir, time.Minute); err != nil {       // By a restarted or another replica
+		t.Fatal(err)
+	}
+	sendDueSummaries(context.Background(), day.Add(9*time.Hour))
+	if summariesSent.Value() != sent || summariesFailed.Value() != failed {
+		t.Error("summary mailed twice in a day")
+	}
+}
+
+func TestChatNotifiesPlanningEvents(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string][]string{}
+	)
+	hooks := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+		mu.Unlock()
+	}))
+	defer hooks.Close()
+	ConfigureChat(notify.NewChat(hooks.Client()))
+	t.Cleanup(func() {
+		chat = nil
+		tenantsMu.Lock()
+		delete(tenantSettings, "")
+		tenantsMu.Unlock()
+	})
+
+	settings := templates.TenantSettings{Chat: []templates.ChatChannel{
+		{Kind: "slack", URL: hooks.URL + "/services/T0/B0/secret", UnassignedAbove: 2},
+		{Kind: "teams", URL: hooks.URL + "/workflows/secret", Events: []string{templates.ChatJobFailed}},
+	}}
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", settings); rec.Code != http.StatusOK {
+		t.Fatalf("saving: %d %s", rec.Code, rec.Body)
+	}
+	// The list hides the webhooks' tokens, and saving it back keeps them
+	rec := serve(t, TenantSettingsHandler, http.MethodGet, "/tenants/settings", nil)
+	var listed []templates.TenantSettings
+	json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed) != 1 || len(listed[0].Chat) != 2 || strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("listed %s", rec.Body)
+	}
+	if rec := serve(t, TenantSettingsHandler, http.MethodPost, "/tenants/settings", listed[0]); rec.Code != http.StatusOK {
+		t.Fatalf("saving the list back: %d %s", rec.Code, rec.Body)
+	}
+	tenantsMu.RLock()
+	saved := tenantSettings[""].Chat
+	tenantsMu.RUnlock()
+	if saved[0].URL != settings.Chat[0].URL || saved[1].URL != settings.Chat[1].URL {
+		t.Errorf("saved back %+v", saved)
+	}
+
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	plan := models.DispatchRequest{
+		Date:       "2026-11-27",
+		Routes:     []models.FleetRoute{{VehicleID: "V1", StopIDs: []string{"A"}, Route: []models.Location{loc, loc, loc}}},
+		Unassigned: []string{"B", "C", "D"},
+	}
+	if rec := serve(t, DispatchHandler, http.MethodPost, "/dispatch", plan); rec.Code != http.StatusOK {
+		t.Fatalf("publishing: %d %s", rec.Code, rec.Body)
+	}
+	chatJobFailed(jobs.Job{ID: "j1", Path: "/optimize-fleet", Status: jobs.Failed, Code: 422, Attempts: 1, Error: "No vehicles"})
+	// A batch's failures come in one message
+	for line := 1; line <= 25; line++ {
+		chatJobFailed(jobs.Job{ID: "b" + strconv.Itoa(line), Path: "/optimize-fleet", Batch: "b1", Line: line, Status: jobs.Failed, Code: 422, Error: "No vehicles"})
+	}
+	chatBatchFailed("b1")
+	chat.Close()
+
+	slack, teams := received["/services/T0/B0/secret"], received["/workflows/secret"]
+	// Slack hears of everything, Teams only of failed jobs
+	if len(slack) != 4 || !strings.Contains(slack[0], "Plan for 2026-11-27 published") || !strings.Contains(slack[1], "B, C, D") || !strings.Contains(slack[2], "Job j1 failed") {
+		t.Errorf("slack received %q", slack)
+	}
+	if len(teams) != 2 || !strings.Contains(teams[0], "Job j1 failed") || !strings.Contains(teams[0], "AdaptiveCard") {
+		t.Errorf("teams received %q", teams)
+	}
+	if len(slack) == 4 && (!strings.Contains(slack[3], "25 jobs failed in batch b1") || !strings.Contains(slack[3], "19, 20 and 5 more")) {
+		t.Errorf("batch message %q", slack[3])
+	}
+}
+
+func TestHealthAlerts(t *testing.T) {
+	saved := healthAlerts
+	healthAlerts = alerts.NewEvaluator(healthRules, alertInterval, map[string]string{"instance": "pod-a"})
+	t.Cleanup(func() { healthAlerts = saved })
+
+	now := time.Now()
+	healthAlerts.Evaluate(now, metrics.Snapshot())
+	solves.Add(40)
+	solveErrors.Add(10)
+	healthAlerts.Evaluate(now.Add(alertInterval), metrics.Snapshot())
+
+	rec := serve(t, AlertsHandler, http.MethodGet, "/alerts", nil)
+	var firing []alerts.Alert
+	json.Unmarshal(rec.Body.Bytes(), &firing)
+	if rec.Code != http.StatusOK || len(firing) != 1 || firing[0].Labels["alertname"] != "SolverErrorRateHigh" || firing[0].Labels["severity"] != alerts.Page || firing[0].Labels["instance"] != "pod-a" {
+		t.Errorf("alerts: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(t, AlertRulesHandler, http.MethodGet, "/alerts/rules", nil)
+	for _, want := range []string{"- alert: SolverErrorRateHigh", "- alert: PlanQualityRegressed", "expr: solver_load > 0.9"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("rules lack %q:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestGenerateBoundsClusters(t *testing.T) {
+	for target, want := range map[string]int{
+		"/generate?kind=fleet&size=50&distribution=clustered&clusters=5":            http.StatusOK,
+		"/generate?kind=fleet&size=50&distribution=clustered&clusters=51":           http.StatusBadRequest,
+		"/generate?distribution=clustered&clusters=2000000000":                      http.StatusBadRequest,
+		"/generate?size=5000&distribution=clustered&clusters=0":                     http.StatusBadRequest,
+		"/generate?kind=load&size=5000&distribution=clustered&clusters=5000&seed=1": http.StatusOK,
+	} {
+		if rec := serve(t, GenerateHandler, http.MethodGet, target, nil); rec.Code != want {
+			t.Errorf("%s: %d %s", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestAnonymizeHandler(t *testing.T) {
+	instance := map[string]any{
+		"depot": map[string]any{"lat": 12.9716, "lng": 77.5946},
+		"stops": []map[string]any{{"id": "BLR-17", "location": map[string]any{"lat": 12.9352, "lng": 77.6245}, "demand": 4}},
+	}
+	rec := serve(t, AnonymizeHandler, http.MethodPost, "/anonymize?jitter_m=100", instance)
+	var out struct {
+		Stops []struct {
+			ID       string
+			Location models.Location
+		}
+	}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "BLR-17") || len(out.Stops) != 1 || out.Stops[0].Location == (models.Location{Lat: 12.9352, Lng: 77.6245}) || !strings.Contains(rec.Body.String(), `"demand":4`) {
+		t.Errorf("anonymized: %d %s", rec.Code, rec.Body)
+	}
+	if again := serve(t, AnonymizeHandler, http.MethodPost, "/anonymize?jitter_m=100", instance); again.Body.String() != rec.Body.String() {
+		t.Errorf("not deterministic: %s then %s", rec.Body, again.Body)
+	}
+	for _, bad := range []string{"/anonymize?jitter_m=-1", "/anonymize?jitter_m=NaN", "/anonymize?jitter_m=90000"} {
+		if rec := serve(t, AnonymizeHandler, http.MethodPost, bad, instance); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d", bad, rec.Code)
+		}
+	}
+}
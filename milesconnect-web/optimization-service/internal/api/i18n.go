@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"milesconnect-optimization/internal/i18n"
+	"net/http"
+	"strings"
+)
+
+// language is what error messages are given in when the caller's
+// Accept-Language names none the service speaks; SetLanguage configures it
+var language = i18n.English
+
+// SetLanguage sets the deployment's default language, en or hi; call
+// before serving requests
+func SetLanguage(lang string) error {
+	if !i18n.Supported(lang) {
+		return errors.New("language must be en or hi")
+	}
+	language = lang
+	return nil
+}
+
+// Localize translates plain-text error responses into the language the
+// caller's Accept-Language asks for, falling back to the deployment's.
+// Handlers keep writing their errors in English with http.Error.
+func Localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r.Header.Get("Accept-Language"), language)
+		w.Header().Add("Vary", "Accept-Language")
+		if lang == i18n.English {
+			next.ServeHTTP(w, r)
+			return
+		}
+		lw := &localizedWriter{ResponseWriter: w, lang: lang}
+		next.ServeHTTP(lw, r)
+		lw.flushError()
+	})
+}
+
+// localizedWriter holds back plain-text error bodies so they can be
+// translated whole; everything else passes straight through
+type localizedWriter struct {
+	http.ResponseWriter
+	lang   string
+	status int // Set while an error body is held back
+	body   bytes.Buffer
+}
+
+func (lw *localizedWriter) WriteHeader(status int) {
+	if status >= 400 && strings.HasPrefix(lw.Header().Get("Content-Type"), "text/plain") {
+		lw.status = status
+		return
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *localizedWriter) Write(b []byte) (int, error) {
+	if lw.status != 0 {
+		return lw.body.Write(b)
+	}
+	return lw.ResponseWriter.Write(b)
+}
+
+// Flush keeps event streams flowing through the writer
+func (lw *localizedWriter) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok && lw.status == 0 {
+		f.Flush()
+	}
+}
+
+func (lw *localizedWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+func (lw *localizedWriter) flushError() {
+	if lw.status == 0 {
+		return
+	}
+	msg := strings.TrimSuffix(lw.body.String(), "\n")
+	if t := i18n.T(lw.lang, msg); t != msg {
+		lw.Header().Set("Content-Language", lw.lang)
+		msg = t
+	}
+	lw.Header().Del("Content-Length")
+	lw.ResponseWriter.WriteHeader(lw.status)
+	lw.ResponseWriter.Write([]byte(msg + "\n"))
+}
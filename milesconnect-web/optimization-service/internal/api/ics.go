@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/ics"
+	"net/http"
+	"time"
+)
+
+// calendarRefresh is how often subscribed calendars are asked to re-fetch,
+// so replans and completed stops show up during the day
+const calendarRefresh = 15 * time.Minute
+
+// DispatchCalendarHandler exports the planned stops for ?date= (default
+// today) as an iCalendar feed, one event per stop over its ETA window, for
+// one vehicle with ?vehicle_id= or every one
+func DispatchCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	date, ok := dispatchDate(w, q.Get("date"))
+	if !ok {
+		return
+	}
+	b, ok := dispatched.Board(tenant, date)
+	if !ok {
+		http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+		return
+	}
+	runs := b.Runs
+	name := "Deliveries " + date
+	if v := q.Get("vehicle_id"); v != "" {
+		run, ok := dispatched.Run(tenant, date, v)
+		if !ok {
+			http.Error(w, "No run dispatched for this vehicle on "+date, http.StatusNotFound)
+			return
+		}
+		runs = []dispatch.Run{run}
+		name = v + " " + date
+	}
+	writeCalendar(w, name, date, runs)
+}
+
+// writeCalendar writes runs as a feed. Stops without an ETA become all-day
+// events; the UID ties each event to its stop so a replan moves it instead
+// of adding another.
+func writeCalendar(w http.ResponseWriter, name, date string, runs []dispatch.Run) {
+	day, _ := time.ParseInLocation(time.DateOnly, date, notifyPolicy.Zone)
+	cal := ics.Calendar{Name: name, Refresh: calendarRefresh}
+	for _, run := range runs {
+		for i, st := range run.Stops {
+			ev := ics.Event{
+				UID:         fmt.Sprintf("%s-%s@milesconnect", date, st.ID),
+				Day:         day,
+				Summary:     fmt.Sprintf("Stop %d: %s", i+1, st.ID),
+				Description: fmt.Sprintf("Vehicle %s, stop %d of %d. Status: %s.", run.VehicleID, i+1, len(run.Stops), st.Status),
+				Location:    fmt.Sprintf("%.6f,%.6f", st.Location.Lat, st.Location.Lng),
+				Lat:         st.Location.Lat,
+				Lng:         st.Location.Lng,
+				Modified:    st.UpdatedAt,
+			}
+			if win := notifyPolicy.Window(date, st.ETAHours); win != nil {
+				ev.Start, ev.End = win.From, win.To
+			}
+			cal.Events = append(cal.Events, ev)
+		}
+	}
+	w.Header().Set("Content-Type", ics.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="`+date+`.ics"`)
+	cal.Write(w, time.Now())
+}
@@ -0,0 +1,483 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/objstore"
+	"milesconnect-optimization/internal/solver"
+	"milesconnect-optimization/internal/xlsx"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobPoll is how long an idle worker waits before looking for jobs again
+const jobPoll = time.Second
+
+// jobPrune is how often finished jobs past jobRetention are deleted
+const jobPrune = time.Hour
+
+// jobRetention is how long finished jobs and their results are kept; see
+// ConfigureJobRetention
+var jobRetention = 7 * 24 * time.Hour
+
+// jobEndpoints are the solving endpoints a job may run, with their methods
+var jobEndpoints = map[string]string{
+	"/optimize":            http.MethodPost,
+	"/optimize-load":       http.MethodPost,
+	"/optimize-fleet":      http.MethodPost,
+	"/optimize-first-mile": http.MethodPost,
+	"/optimize-india":      http.MethodGet,
+}
+
+// Bulk submissions bound the stream and the jobs in it; each line is held
+// to a request body's limit
+const (
+	maxBulkBytes = 256 << 20
+	maxBulkJobs  = 20000
+)
+
+// objectStore reads job bodies from and writes results to object storage;
+// see ConfigureObjectStorage. S3 and GCS are public, so it never dials an
+// internal address, whatever their names resolve to.
+var objectStore = objstore.New(objstore.DefaultHosts, &http.Client{Timeout: 10 * time.Minute, Transport: objstore.PublicTransport()})
+
+// maxObjectBytes bounds a job body read from object storage, and with it
+// any background job's body
+const maxObjectBytes = 512 << 20
+
+// ConfigureObjectStorage trusts hosts, names or patterns such as
+// "*.minio.internal", in place of S3's and GCS's with jobs' signed URLs,
+// e.g. for a MinIO deployment, which may well be on a private address
+func ConfigureObjectStorage(hosts []string) {
+	objectStore = objstore.New(hosts, &http.Client{Timeout: 10 * time.Minute})
+}
+
+// jobStore is the job directory replicas share; see OpenJobStore
+var jobStore *jobs.Store
+
+var (
+	jobsTakenOver = metrics.NewCounter("jobs_taken_over_total", "Running jobs put back in the queue after their replica stopped heartbeating")
+	jobsFinished  = metrics.NewCounter("jobs_finished_total", "Jobs done or failed, including those abandoned")
+	jobsFailed    = metrics.NewCounter("jobs_failed_total", "Jobs failed, including those abandoned")
+)
+
+// OpenJobStore opens the job directory at dir. A running job is taken over
+// once its replica has not heartbeat for lease.
+func OpenJobStore(dir string, lease time.Duration) error {
+	s, err := jobs.Open(dir, lease)
+	if err != nil {
+		return err
+	}
+	jobStore = s
+	return nil
+}
+
+// ConfigureJobRetention keeps finished jobs and their results for d before
+// they are deleted; 0 keeps them for good. Call before StartJobWorkers.
+func ConfigureJobRetention(d time.Duration) {
+	jobRetention = d
+}
+
+// StartJobWorkers runs workers goroutines that claim queued jobs as replica
+// owner and serve them through h, the server's whole handler chain, and
+// one that deletes finished jobs once they are past jobRetention
+func StartJobWorkers(owner string, workers int, h http.Handler) {
+	for range workers {
+		go runJobWorker(jobStore, owner, h)
+	}
+	if jobRetention > 0 {
+		go pruneJobs(jobStore)
+	}
+}
+
+// pruneJobs deletes finished jobs past jobRetention every jobPrune. Every
+// replica prunes; deleting a job twice is harmless.
+func pruneJobs(store *jobs.Store) {
+	for ; ; time.Sleep(jobPrune) {
+		n, err := store.Prune(time.Now().Add(-jobRetention))
+		if err != nil {
+			log.Printf("jobs: pruning: %v", err)
+		}
+		if n > 0 {
+			log.Printf("jobs: deleted %d finished jobs older than %s", n, jobRetention)
+		}
+	}
+}
+
+func runJobWorker(store *jobs.Store, owner string, h http.Handler) {
+	for {
+		moved, err := store.Reap(time.Now())
+		if err != nil {
+			log.Printf("jobs: reaping: %v", err)
+		}
+		if len(moved) > 0 {
+			jobsTakenOver.Add(int64(len(moved)))
+			log.Printf("jobs: took over %d orphaned jobs", len(moved))
+		}
+		for _, j := range moved {
+			if j.Status == jobs.Failed {
+				jobsFinished.Inc()
+				jobsFailed.Inc()
+				chatJobFailed(j)
+			}
+		}
+		j, ok, err := store.Claim(owner, time.Now())
+		if err != nil {
+			log.Printf("jobs: claiming: %v", err)
+		}
+		if !ok {
+			time.Sleep(jobPoll)
+			continue
+		}
+		runJob(store, j, h)
+	}
+}
+
+// runJob serves a claimed job's request, heartbeating until it is done.
+// Solvers that checkpoint keep their progress in the job store. A job taken
+// over meanwhile is abandoned; its new owner resumes it.
+func runJob(store *jobs.Store, j jobs.Job, h http.Handler) {
+	ctx := solver.WithCheckpointer(context.WithValue(context.Background(), jobKey{}, j.ID), jobCheckpoints{store, j})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	beats := make(chan struct{})
+	go func() {
+		defer close(beats)
+		tick := time.NewTicker(store.Lease() / 3)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := store.Heartbeat(&j, time.Now()); err != nil {
+					log.Printf("jobs: %s: %v", j.ID, err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	rec := httptest.NewRecorder()
+	in := j
+	var err error
+	if j.InputURL != "" {
+		in.Body, err = objectStore.Get(ctx, j.InputURL, maxObjectBytes)
+	}
+	if err != nil {
+		http.Error(rec, err.Error(), http.StatusBadGateway)
+	} else if req, err := jobRequest(ctx, in); err != nil {
+		http.Error(rec, err.Error(), http.StatusInternalServerError)
+	} else {
+		h.ServeHTTP(rec, req)
+	}
+	lost := ctx.Err() != nil
+	cancel()
+	<-beats
+	if lost {
+		return
+	}
+
+	j.Code = rec.Code
+	if body := rec.Body.Bytes(); j.OutputURL != "" && j.Code < http.StatusBadRequest {
+		if err := objectStore.Put(context.Background(), j.OutputURL, body, cmp.Or(rec.Header().Get("Content-Type"), "application/json")); err != nil {
+			j.Status, j.Error = jobs.Failed, "writing result: "+err.Error()
+		}
+	} else if json.Valid(body) {
+		j.Result = body
+	} else {
+		j.Error = strings.TrimSpace(string(body))
+	}
+	if j.Code >= http.StatusBadRequest && j.Result == nil {
+		j.Status = jobs.Failed
+	}
+	if err := store.Finish(j, time.Now()); err != nil {
+		log.Printf("jobs: finishing %s: %v", j.ID, err)
+		return
+	}
+	jobsFinished.Inc()
+	if j.Status == jobs.Failed {
+		jobsFailed.Inc()
+		chatJobFailed(j)
+	}
+}
+
+// jobCheckpoints keeps a running job's solver checkpoints in the job store
+type jobCheckpoints struct {
+	store *jobs.Store
+	job   jobs.Job
+}
+
+func (c jobCheckpoints) Save(name string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.store.SaveCheckpoint(c.job, name, body)
+}
+
+func (c jobCheckpoints) Load(name string, v any) (bool, error) {
+	body, ok, err := c.store.LoadCheckpoint(c.job.ID, name)
+	if !ok || err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(body, v)
+}
+
+// jobRequest rebuilds a job's request, acting for its submitter
+func jobRequest(ctx context.Context, j jobs.Job) (*http.Request, error) {
+	target := "/v1" + j.Path
+	if j.Query != "" {
+		target += "?" + j.Query
+	}
+	req, err := http.NewRequestWithContext(ctx, jobEndpoints[j.Path], target, bytes.NewReader(j.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if j.Actor != "" {
+		req.Header.Set("X-Actor", j.Actor)
+	}
+	if len(plannerSecret) > 0 && j.Tenant != "" {
+		p := auth.Principal{Tenant: j.Tenant, Role: j.Role}
+		req.Header.Set("Authorization", "Bearer "+auth.Sign(plannerSecret, p.Subject(), time.Now().Add(time.Hour)))
+	}
+	return req, nil
+}
+
+var jobList = listSpec[jobs.Job]{
+	key: func(j jobs.Job) string { return j.ID },
+	fields: map[string]listField[jobs.Job]{
+		"status": {value: func(j jobs.Job) string { return j.Status }},
+		"path":   {value: func(j jobs.Job) string { return j.Path }},
+		"owner":  {value: func(j jobs.Job) string { return j.Owner }},
+	},
+}
+
+// JobsHandler queues an optimization request to run in the background on
+// any replica (POST), and lists jobs or returns one with ?id= (GET). A
+// finished /optimize-load or /optimize-fleet job downloads as an Excel
+// workbook with ?format=xlsx.
+func JobsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if jobStore == nil {
+		http.Error(w, "Background jobs are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if id := r.URL.Query().Get("id"); id != "" {
+			j, err := jobStore.Get(id)
+			if errors.Is(err, jobs.ErrNotFound) || err == nil && !who.Reads(j.Tenant) {
+				http.Error(w, "Unknown job", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if r.URL.Query().Get("format") == "xlsx" || strings.Contains(r.Header.Get("Accept"), xlsx.ContentType) {
+				writeJobWorkbook(w, j)
+				return
+			}
+			writeResponse(w, r, jobView(j))
+			return
+		}
+		all, err := jobStore.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list := []jobs.Job{}
+		for _, j := range all {
+			if who.Reads(j.Tenant) {
+				list = append(list, jobView(j))
+			}
+		}
+		writeList(w, r, list, jobList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var j jobs.Job
+		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateJob(j); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		j.Tenant, j.Role, j.Actor = who.Tenant, who.Role, r.Header.Get("X-Actor")
+		j, err := jobStore.Submit(j, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		record(r, audit.Event{Kind: "job.submitted"}, map[string]string{"id": j.ID, "path": j.Path})
+		w.Header().Set("Location", "/v1/jobs?id="+j.ID)
+		writeStatus(w, r, http.StatusAccepted, jobView(j))
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// BulkJobsHandler queues an NDJSON stream of jobs, one per line as POST
+// /jobs takes them, as a batch the workers run in order (POST), and streams
+// back a batch's jobs and their results as NDJSON, ?batch= (GET). Workers
+// take turns between tenants, so a batch does not hold up other tenants'
+// jobs. A stream with a bad line queues nothing. Results leave each job's request out;
+// X-Batch-Pending counts the jobs yet to finish.
+func BulkJobsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if jobStore == nil {
+		http.Error(w, "Background jobs are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		m, list, err := jobStore.Batch(r.URL.Query().Get("batch"))
+		if errors.Is(err, jobs.ErrNotFound) || err == nil && !who.Reads(m.Tenant) {
+			http.Error(w, "Unknown batch", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := r.URL.Query().Get("status")
+		pending := 0
+		for _, j := range list {
+			if j.Status == jobs.Queued || j.Status == jobs.Running {
+				pending++
+			}
+		}
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(list)))
+		w.Header().Set("X-Batch-Pending", strconv.Itoa(pending))
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for i, j := range list {
+			if status != "" && j.Status != status {
+				continue
+			}
+			j.Body = nil
+			if err := enc.Encode(jobView(j)); err != nil {
+				return // Client went away
+			}
+			if flusher != nil && i%100 == 99 {
+				flusher.Flush()
+			}
+		}
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxBulkBytes)
+		list, err := readBulkJobs(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i := range list {
+			list[i].Tenant, list[i].Role, list[i].Actor = who.Tenant, who.Role, r.Header.Get("X-Actor")
+		}
+		batch, err := jobStore.SubmitBatch(list, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sub := models.BulkSubmission{Batch: batch, Jobs: len(list)}
+		record(r, audit.Event{Kind: "job.bulk_submitted"}, sub)
+		w.Header().Set("Location", "/v1/jobs/bulk?batch="+batch)
+		writeStatus(w, r, http.StatusAccepted, sub)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// readBulkJobs reads and checks an NDJSON stream of jobs, skipping blank
+// lines. Errors name the line at fault.
+func readBulkJobs(body io.Reader) ([]jobs.Job, error) {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(nil, maxBodyBytes)
+	var list []jobs.Job
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(list) == maxBulkJobs {
+			return nil, fmt.Errorf("a batch holds at most %d jobs", maxBulkJobs)
+		}
+		var j jobs.Job
+		if err := json.Unmarshal(line, &j); err != nil {
+			return nil, fmt.Errorf("line %d: invalid job", n)
+		}
+		if err := validateJob(j); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		list = append(list, jobs.Job{Path: j.Path, Query: j.Query, Body: j.Body, InputURL: j.InputURL, OutputURL: j.OutputURL})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading jobs: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no jobs to queue")
+	}
+	return list, nil
+}
+
+func validateJob(j jobs.Job) error {
+	method, ok := jobEndpoints[j.Path]
+	if !ok {
+		return errors.New("path must be one of /optimize, /optimize-load, /optimize-fleet, /optimize-first-mile or /optimize-india")
+	}
+	if _, err := url.ParseQuery(j.Query); err != nil {
+		return errors.New("query must be a URL query string")
+	}
+	for name, raw := range map[string]string{"input_url": j.InputURL, "output_url": j.OutputURL} {
+		if raw == "" {
+			continue
+		}
+		if err := objectStore.Check(raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	switch {
+	case j.InputURL != "" && (method != http.MethodPost || len(j.Body) > 0):
+		return errors.New("input_url takes the place of a POST endpoint's body")
+	case j.InputURL == "" && method == http.MethodPost && !json.Valid(j.Body):
+		return errors.New("body must be the endpoint's JSON request")
+	}
+	return nil
+}
+
+// jobView is j as the job API shows it, its signed URLs without their
+// signatures
+func jobView(j jobs.Job) jobs.Job {
+	j.InputURL, j.OutputURL = objstore.Location(j.InputURL), objstore.Location(j.OutputURL)
+	return j
+}
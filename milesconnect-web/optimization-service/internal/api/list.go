@@ -0,0 +1,144 @@
+package api
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listField is an attribute of a listed item: ?<name>=v keeps the items
+// whose value is v (repeat the parameter for any of several) and
+// ?sort=<name> or ?sort=-<name> orders by it
+type listField[T any] struct {
+	value   func(T) string
+	compare func(a, b T) int // Orders by value when nil
+}
+
+// listSpec describes a list endpoint's items. key is unique per item and is
+// both the default order and what a cursor resumes after. fill, when set,
+// completes the page's items before they are written, for lists filtered
+// and sorted on a summary of each item.
+type listSpec[T any] struct {
+	key    func(T) string
+	fields map[string]listField[T]
+	fill   func([]T) ([]T, error)
+}
+
+// listCursor is where the next page starts, handed out base64-encoded in
+// the Link header and taken back as ?cursor=
+type listCursor struct {
+	Sort   string `json:"s,omitempty"`
+	After  string `json:"a"`
+	Offset int    `json:"o"` // Used when the After item has since gone
+}
+
+// writeList filters, sorts and pages items by the request's query, the same
+// way on every list endpoint. The body stays a plain array; the total after
+// filtering is in X-Total-Count and, if there is more, the next page's
+// cursor is in X-Next-Cursor and a Link rel="next".
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T, spec listSpec[T]) {
+	q := r.URL.Query()
+
+	limit := defaultListLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	sortBy := q.Get("sort")
+	name, desc := strings.CutPrefix(sortBy, "-")
+	compare := func(a, b T) int { return strings.Compare(spec.key(a), spec.key(b)) }
+	if name != "" && name != "id" {
+		f, ok := spec.fields[name]
+		if !ok {
+			http.Error(w, "Cannot sort by "+name, http.StatusBadRequest)
+			return
+		}
+		byField := f.compare
+		if byField == nil {
+			byField = func(a, b T) int { return strings.Compare(f.value(a), f.value(b)) }
+		}
+		compare = func(a, b T) int { return cmp.Or(byField(a, b), strings.Compare(spec.key(a), spec.key(b))) }
+	}
+
+	var cur listCursor
+	if v := q.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || json.Unmarshal(raw, &cur) != nil || cur.Offset < 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if cur.Sort != sortBy {
+			http.Error(w, "cursor was issued for a different sort", http.StatusBadRequest)
+			return
+		}
+	}
+
+	kept := make([]T, 0, len(items))
+	for _, it := range items {
+		if matches(q, it, spec.fields) {
+			kept = append(kept, it)
+		}
+	}
+	slices.SortStableFunc(kept, func(a, b T) int {
+		if desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+
+	start := 0
+	if cur.After != "" {
+		start = min(cur.Offset, len(kept))
+		if i := slices.IndexFunc(kept, func(it T) bool { return spec.key(it) == cur.After }); i >= 0 {
+			start = i + 1
+		}
+	}
+	end := min(start+limit, len(kept))
+	page := kept[start:end]
+	if spec.fill != nil {
+		var err error
+		if page, err = spec.fill(page); err != nil {
+			log.Printf("list %s: %v", r.URL.Path, err)
+			http.Error(w, "Failed to read the list", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(kept)))
+	if end < len(kept) {
+		raw, _ := json.Marshal(listCursor{Sort: sortBy, After: spec.key(kept[end-1]), Offset: end})
+		next := base64.RawURLEncoding.EncodeToString(raw)
+		nq := r.URL.Query()
+		nq.Set("cursor", next)
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", "<"+(&url.URL{Path: r.URL.Path, RawQuery: nq.Encode()}).String()+`>; rel="next"`)
+	}
+	writeResponse(w, r, page)
+}
+
+// matches reports whether it passes every field filter in q
+func matches[T any](q url.Values, it T, fields map[string]listField[T]) bool {
+	for name, f := range fields {
+		want, ok := q[name]
+		if ok && !slices.Contains(want, f.value(it)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maintenance holds vehicle service schedules by vehicle ID, under
+// templatesMu and saved beside the templates
+var maintenance = map[string]templates.Maintenance{}
+
+// LoadMaintenance registers every vehicle maintenance schedule in dir
+func LoadMaintenance(dir string) (int, error) {
+	list, err := templates.LoadMaintenance(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	for _, m := range list {
+		maintenance[m.VehicleID] = m
+	}
+	return len(list), nil
+}
+
+var maintenanceList = listSpec[templates.Maintenance]{
+	key: func(m templates.Maintenance) string { return m.VehicleID },
+	fields: map[string]listField[templates.Maintenance]{
+		"due": {value: func(m templates.Maintenance) string { return strconv.FormatBool(m.Due()) }},
+		"updated_at": {
+			value:   func(m templates.Maintenance) string { return m.UpdatedAt.Format(time.RFC3339) },
+			compare: func(a, b templates.Maintenance) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+		},
+	},
+}
+
+// MaintenanceHandler lists vehicle maintenance schedules (GET), saves one
+// (POST) or deletes one (DELETE ?vehicle_id=)
+func MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		templatesMu.RLock()
+		list := make([]templates.Maintenance, 0, len(maintenance))
+		for _, m := range maintenance {
+			if who.Reads(m.Tenant) {
+				list = append(list, m)
+			}
+		}
+		templatesMu.RUnlock()
+		writeList(w, r, list, maintenanceList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var m templates.Maintenance
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := m.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.Tenant = recordTenant(who, m.Tenant)
+		if !writable(w, who, m.Tenant) {
+			return
+		}
+		m.UpdatedAt = time.Now().UTC()
+
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		if old, ok := maintenance[m.VehicleID]; ok && old.Tenant != m.Tenant {
+			http.Error(w, "Vehicle "+m.VehicleID+" belongs to another tenant", http.StatusConflict)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.SaveMaintenance(templateDir, m); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		maintenance[m.VehicleID] = m
+		record(r, audit.Event{Kind: "maintenance.saved", Vehicles: []string{m.VehicleID}}, m)
+		writeResponse(w, r, m)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("vehicle_id")
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		m, ok := maintenance[id]
+		if !ok || !who.Reads(m.Tenant) {
+			http.Error(w, "No maintenance schedule for this vehicle", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, m.Tenant) {
+			return
+		}
+		if err := moveToTrash(trashMaintenance, id, m.Tenant, m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.DeleteMaintenance(templateDir, id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		delete(maintenance, id)
+		record(r, audit.Event{Kind: "maintenance.deleted", Vehicles: []string{id}}, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// availableVehicles leaves out the vehicles that are down for maintenance
+// on date or due for service, saying why for each
+func availableVehicles(vehicles []models.VehicleInfo, date time.Time) ([]models.VehicleInfo, []models.Violation) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	kept := make([]models.VehicleInfo, 0, len(vehicles))
+	var out []models.Violation
+	for _, v := range vehicles {
+		m, ok := maintenance[v.ID]
+		if !ok {
+			kept = append(kept, v)
+			continue
+		}
+		if d, down := m.Down(date); down {
+			msg := fmt.Sprintf("vehicle %s left out: down for maintenance %s to %s", v.ID, d.From, d.To)
+			if d.Reason != "" {
+				msg += " (" + d.Reason + ")"
+			}
+			out = append(out, models.Violation{Constraint: "maintenance", VehicleID: v.ID, Message: msg, Soft: true})
+			continue
+		}
+		if m.Due() {
+			out = append(out, models.Violation{Constraint: "maintenance", VehicleID: v.ID, Soft: true,
+				Message: fmt.Sprintf("vehicle %s left out: due for service (%.0f of %.0f km run)", v.ID, m.KmSinceService, m.ServiceEveryKm)})
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept, out
+}
+
+// serviceWarnings flags routes longer than their vehicle may run before its
+// next service
+func serviceWarnings(routes []models.FleetRoute) []models.Violation {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	var out []models.Violation
+	for _, r := range routes {
+		left, ok := maintenance[r.VehicleID].RemainingKm()
+		if !ok || r.DistanceKm <= left {
+			continue
+		}
+		out = append(out, models.Violation{Constraint: "service_km", VehicleID: r.VehicleID, Soft: true,
+			Message: fmt.Sprintf("route of %.1f km exceeds the %.1f km left before vehicle %s is due for service", r.DistanceKm, left, r.VehicleID)})
+	}
+	return out
+}
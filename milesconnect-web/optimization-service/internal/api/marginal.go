@@ -0,0 +1,160 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/toll"
+	"net/http"
+	"slices"
+)
+
+// MarginalCostHandler prices adding one shipment to a dispatched plan: the
+// cheapest place on each run that has not finished and makes no stop late,
+// without replanning
+func MarginalCostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.MarginalCostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := resolveLocation(&req.Shipment.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+	settings := settingsOf(tenant)
+	req.SpeedKmph = cmp.Or(req.SpeedKmph, settings.SpeedKmph)
+	req.CostPerKm = cmp.Or(req.CostPerKm, settings.CostPerKm)
+	if err := validateMarginalCostRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+	b, ok := dispatched.Board(tenant, date)
+	if !ok {
+		http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, marginalCost(b, req))
+}
+
+func validateMarginalCostRequest(req models.MarginalCostRequest) error {
+	if err := validateFleetStop(req.Shipment); err != nil {
+		return err
+	}
+	if len(req.Vehicles) > maxVehicles {
+		return errors.New("Too many vehicles")
+	}
+	for _, v := range req.Vehicles {
+		if !finite(v.CapacityKg, v.DepartHours) || v.CapacityKg <= 0 {
+			return errors.New("Vehicle capacity must be positive")
+		}
+	}
+	if !finite(req.SpeedKmph, req.CostPerKm) || req.SpeedKmph < 0 || req.CostPerKm < 0 {
+		return errors.New("Speed and cost per km must not be negative")
+	}
+	return nil
+}
+
+// marginalCost finds the best insertion of req's shipment on every run of b,
+// trying each run's places cheapest first until one keeps every due time
+func marginalCost(b dispatch.Board, req models.MarginalCostRequest) models.MarginalCost {
+	vehicles := map[string]models.VehicleInfo{}
+	for _, v := range req.Vehicles {
+		vehicles[v.ID] = v
+	}
+	speed := cmp.Or(req.SpeedKmph, problem.DefaultSpeedKmph)
+	costPerKm := cmp.Or(req.CostPerKm, toll.DefaultCostPerKm)
+	s := req.Shipment
+
+	out := models.MarginalCost{Options: []models.Insertion{}}
+	for _, run := range b.Runs {
+		slots := run.Insertions(s.Location, problem.Haversine)
+		if len(slots) == 0 {
+			continue
+		}
+		// The cheapest slot that breaks no time window, or else the
+		// cheapest with why it cannot be used
+		var best models.Insertion
+		for i, sl := range slots {
+			ins := insertion(run, sl, s, vehicles[run.VehicleID], speed, costPerKm)
+			if i == 0 || ins.Feasible {
+				best = ins
+			}
+			if ins.Feasible {
+				break
+			}
+		}
+		out.Options = append(out.Options, best)
+	}
+
+	slices.SortStableFunc(out.Options, func(a, b models.Insertion) int {
+		if a.Feasible != b.Feasible {
+			if a.Feasible {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.AddedKm, b.AddedKm)
+	})
+	if len(out.Options) > 0 && out.Options[0].Feasible {
+		out.Feasible, out.Best = true, &out.Options[0]
+	}
+	return out
+}
+
+// insertion prices adding s to run at sl and checks it against v's
+// capacity, s's due time and the due times of the stops it delays
+func insertion(run dispatch.Run, sl dispatch.Slot, s models.FleetStop, v models.VehicleInfo, speed, costPerKm float64) models.Insertion {
+	round := func(x float64) float64 { return math.Round(x*100) / 100 }
+	ins := models.Insertion{
+		VehicleID:    run.VehicleID,
+		Position:     sl.At,
+		AddedKm:      round(sl.AddedKm),
+		AddedCostInr: round(sl.AddedKm * costPerKm),
+		Feasible:     true,
+	}
+	prev, depart := run.Start, v.DepartHours
+	if sl.At > 0 {
+		ins.AfterStopID = run.Stops[sl.At-1].ID
+		prev, depart = run.Stops[sl.At-1].Location, run.Stops[sl.At-1].ETAHours
+	}
+	if sl.At < len(run.Stops) {
+		ins.BeforeStopID = run.Stops[sl.At].ID
+	}
+	arrival := math.Max(depart+problem.Haversine(prev, s.Location)/speed, s.ReadyHours)
+	ins.ArrivalHours = round(arrival)
+
+	// Every later stop is reached as much later as the detour and the
+	// stop's own service take
+	delay := sl.AddedKm/speed + s.ServiceHours
+	if v.CapacityKg > 0 && run.LoadKg+s.DemandKg > v.CapacityKg {
+		ins.Feasible = false
+		ins.Reason = fmt.Sprintf("needs %.0f kg, %.0f kg free", s.DemandKg, math.Max(v.CapacityKg-run.LoadKg, 0))
+	} else if s.DueHours > 0 && ins.ArrivalHours > s.DueHours {
+		ins.Feasible = false
+		ins.Reason = fmt.Sprintf("arrives at %.2fh, after its due time", ins.ArrivalHours)
+	} else if late, ok := run.LateAfter(sl.At, delay); ok {
+		ins.Feasible = false
+		ins.Reason = fmt.Sprintf("makes %s late for its due time", late.ID)
+	}
+	return ins
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+)
+
+// maxNoShowRate caps the no-show rates a plan overbooks on; beyond it the
+// standby lists outgrow the confirmed loads
+const maxNoShowRate = 0.5
+
+func noShowRate(s models.FleetStop, rate float64) float64 {
+	if s.NoShowRate > 0 {
+		return s.NoShowRate
+	}
+	return rate
+}
+
+// overbook stretches each vehicle's plannable capacity by the no-show rate
+// expected across req's stops, weighted by demand, when req overbooks
+func overbook(p *problem.Problem, req models.FleetRequest) {
+	if !req.Overbook {
+		return
+	}
+	demand, missing := 0.0, 0.0
+	for _, s := range req.Stops {
+		demand += s.DemandKg
+		missing += s.DemandKg * noShowRate(s, req.NoShowRate)
+	}
+	if demand == 0 || missing == 0 {
+		return
+	}
+	for i := range p.Vehicles {
+		p.Vehicles[i].CapacityKg /= 1 - missing/demand
+	}
+}
+
+// addStandby moves the tail of each overbooked route to its standby list
+// until what is left fits the vehicle's capacity after its reserve, so a
+// vehicle that fills up at the dock drops the end of its run
+func addStandby(routes []models.FleetRoute, req models.FleetRequest) {
+	if !req.Overbook {
+		return
+	}
+	capacity := map[string]float64{}
+	for _, v := range req.Vehicles {
+		capacity[v.ID] = v.CapacityKg*(1-reservePct(v, req.ReservePct)/100) - v.CurrentLoad
+	}
+	demand := map[string]float64{}
+	for i, s := range req.Stops {
+		demand[cmp.Or(s.ID, fmt.Sprintf("stop-%d", i))] = s.DemandKg
+	}
+
+	for i, r := range routes {
+		load := 0.0
+		for _, id := range r.StopIDs {
+			load += demand[id]
+		}
+		for k := len(r.StopIDs) - 1; k >= 0 && load > capacity[r.VehicleID]+1e-9; k-- {
+			routes[i].StandbyStopIDs = append(routes[i].StandbyStopIDs, r.StopIDs[k])
+			load -= demand[r.StopIDs[k]]
+		}
+		slices.Reverse(routes[i].StandbyStopIDs)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"milesconnect-optimization/internal/data"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/pincode"
+	"net/http"
+	"os"
+)
+
+// pincodes starts with the built-in centroids; LoadPincodeFile extends it
+var pincodes = func() *pincode.Resolver {
+	r, err := pincode.NewResolver(data.PincodeFile())
+	if err != nil {
+		panic(err) // Embedded file is fixed at build time
+	}
+	return r
+}()
+
+// LoadPincodeFile adds centroids from an external CSV (pincode,lat,lng[,name])
+func LoadPincodeFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := pincodes.Load(f); err != nil {
+		return 0, err
+	}
+	return pincodes.Len(), nil
+}
+
+// resolveLocation fills in coordinates for a location given only by pincode.
+// Explicit coordinates always win.
+func resolveLocation(l *models.Location) error {
+	if l.Pincode == "" || l.Lat != 0 || l.Lng != 0 {
+		return nil
+	}
+	loc, err := pincodes.Locate(l.Pincode)
+	if err != nil {
+		return err
+	}
+	loc.State = l.State
+	*l = loc
+	return nil
+}
+
+func resolveRouteRequest(req *models.OptimizationRequest) error {
+	if err := resolveLocation(&req.Start); err != nil {
+		return err
+	}
+	if err := resolveLocation(&req.End); err != nil {
+		return err
+	}
+	for i := range req.Waypoints {
+		if err := resolveLocation(&req.Waypoints[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveFleetRequest(req *models.FleetRequest) error {
+	if err := resolveLocation(&req.Depot); err != nil {
+		return err
+	}
+	for i := range req.Stops {
+		if err := resolveLocation(&req.Stops[i].Location); err != nil {
+			return err
+		}
+	}
+	for _, v := range req.Vehicles {
+		for _, loc := range []*models.Location{v.Start, v.End} {
+			if loc == nil {
+				continue
+			}
+			if err := resolveLocation(loc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PincodeHandler resolves ?code= to its centroid
+func PincodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, err := pincodes.Resolve(r.URL.Query().Get("code"))
+	if errors.Is(err, pincode.ErrUnknown) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeResponse(w, r, res)
+}
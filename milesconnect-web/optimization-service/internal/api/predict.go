@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/predict"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PredictedHeader carries the predicted solve time, in seconds, of a
+// request queued as a background job because it would run long
+const PredictedHeader = "X-Predicted-Solve-Seconds"
+
+// respondAsync is the Prefer preference (RFC 7240) a caller sends to have
+// a long solve queued as a job rather than wait for it
+const respondAsync = "respond-async"
+
+// Solve time prediction; see ConfigurePrediction
+var (
+	solveModel = predict.Default()
+	asyncAbove = 30 * time.Second
+)
+
+// ConfigurePrediction sets the model solve times are predicted with, and
+// how long a predicted solve may take before a request preferring
+// respond-async is queued as a background job in place of being solved
+// while the caller waits; 0 never queues. Call before serving requests.
+func ConfigurePrediction(m predict.Model, async time.Duration) {
+	solveModel, asyncAbove = m, async
+}
+
+// CalibrationTargets are what the prediction model is calibrated on: each
+// SLA tier's solver and parameters, and each tiered solver's defaults
+func CalibrationTargets() []predict.Target {
+	kinds := map[string]string{
+		defaultRouteSolver: predict.KindRoute,
+		defaultLoadSolver:  predict.KindLoad,
+		defaultFleetSolver: predict.KindFleet,
+		allIndiaSolver:     predict.KindRoute,
+	}
+	seen := map[string]bool{}
+	var targets []predict.Target
+	add := func(t predict.Target) {
+		if key := predict.Key(t.Solver, t.Params); !seen[key] {
+			seen[key] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, def := range []string{defaultRouteSolver, defaultLoadSolver, defaultFleetSolver, allIndiaSolver} {
+		for _, tier := range []string{tierFast, tierBalanced, tierBest} {
+			t, _ := lookupTier(tier, def)
+			add(predict.Target{Solver: t.Solver, Kind: kinds[def]})
+			add(predict.Target{Solver: t.Solver, Params: t.Params, Kind: kinds[def]})
+		}
+	}
+	return targets
+}
+
+// predictSolve is how long s should take to solve problems one after
+// another under params; ok is false when there is no prediction for s
+func predictSolve(s solver.Solver, params map[string]float64, problems ...*problem.Problem) (time.Duration, bool) {
+	var total time.Duration
+	for _, p := range problems {
+		if p == nil {
+			continue
+		}
+		d, ok := solveModel.Predict(s, params, p)
+		if !ok {
+			return 0, false
+		}
+		total += d
+	}
+	return total, true
+}
+
+// keepBody reads r's body into memory so it can be read again, to queue
+// the request as a job should it run long. When the body cannot be read it
+// writes a 413 for one over the limit, else a 400, and returns false.
+func keepBody(w http.ResponseWriter, r *http.Request) bool {
+	body, err := io.ReadAll(r.Body)
+	if tooBig := (*http.MaxBytesError)(nil); errors.As(err, &tooBig) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return true
+}
+
+// prefersAsync reports whether r's Prefer header asks for respond-async
+func prefersAsync(r *http.Request) bool {
+	for _, v := range r.Header.Values("Prefer") {
+		for pref := range strings.SplitSeq(v, ",") {
+			name, _, _ := strings.Cut(pref, "=")
+			if strings.EqualFold(strings.TrimSpace(name), respondAsync) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runAsync queues a request predicted to solve for longer than asyncAbove
+// as a background job, answering 202 with where to find it, when the
+// caller sent Prefer: respond-async; it returns false when the request is
+// to be solved now. Requests already running as jobs always solve now.
+func runAsync(w http.ResponseWriter, r *http.Request, s solver.Solver, params map[string]float64, problems ...*problem.Problem) bool {
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	if asyncAbove <= 0 || jobStore == nil || runningJob(r.Context()) || !prefersAsync(r) {
+		return false
+	}
+	if _, ok := jobEndpoints[path]; !ok || r.Method == http.MethodPost && r.GetBody == nil {
+		return false
+	}
+	d, ok := predictSolve(s, params, problems...)
+	if !ok || d <= asyncAbove {
+		return false
+	}
+
+	who, ok := principal(w, r)
+	if !ok {
+		return true
+	}
+	j := jobs.Job{Path: path, Query: r.URL.RawQuery, Tenant: who.Tenant, Role: who.Role, Actor: r.Header.Get("X-Actor")}
+	if r.Method == http.MethodPost {
+		body, _ := r.GetBody()
+		j.Body, _ = io.ReadAll(body)
+	}
+	j, err := jobStore.Submit(j, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	record(r, audit.Event{Kind: "job.submitted"}, map[string]string{"id": j.ID, "path": j.Path, "predicted_seconds": strconv.FormatFloat(d.Seconds(), 'f', 1, 64)})
+	w.Header().Set("Location", "/v1/jobs?id="+j.ID)
+	w.Header().Set(PredictedHeader, strconv.FormatFloat(d.Seconds(), 'f', 1, 64))
+	w.Header().Set("Preference-Applied", respondAsync)
+	writeStatus(w, r, http.StatusAccepted, j)
+	return true
+}
+
+type jobKey struct{}
+
+// runningJob reports whether ctx is a background job's request
+func runningJob(ctx context.Context) bool {
+	return ctx.Value(jobKey{}) != nil
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"cmp"
+	"milesconnect-optimization/internal/tuning"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]tuning.Profile{}
+)
+
+// LoadProfiles registers every tuning profile found in dir
+func LoadProfiles(dir string) (int, error) {
+	list, err := tuning.LoadProfiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	for _, p := range list {
+		profiles[p.Name] = p
+	}
+	return len(list), nil
+}
+
+func lookupProfile(name string) (tuning.Profile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	p, ok := profiles[name]
+	return p, ok
+}
+
+var profileList = listSpec[tuning.Profile]{
+	key: func(p tuning.Profile) string { return p.Name },
+	fields: map[string]listField[tuning.Profile]{
+		"solver": {value: func(p tuning.Profile) string { return p.Solver }},
+		"gap_pct": {
+			value:   func(p tuning.Profile) string { return strconv.FormatFloat(p.GapPct, 'f', -1, 64) },
+			compare: func(a, b tuning.Profile) int { return cmp.Compare(a.GapPct, b.GapPct) },
+		},
+		"created_at": {
+			value:   func(p tuning.Profile) string { return p.CreatedAt.Format(time.RFC3339) },
+			compare: func(a, b tuning.Profile) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		},
+	},
+}
+
+// ProfilesHandler lists the loaded tuning profiles
+func ProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profilesMu.RLock()
+	list := make([]tuning.Profile, 0, len(profiles))
+	for _, p := range profiles {
+		list = append(list, p)
+	}
+	profilesMu.RUnlock()
+
+	writeList(w, r, list, profileList)
+}
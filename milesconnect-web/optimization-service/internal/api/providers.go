@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/flags"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/provider"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+)
+
+// roadDistances supplies road distance matrices; nil means great-circle
+// distances only
+var roadDistances *provider.Resilient
+
+// ConfigureDistanceProvider makes solves use road distances from p when the
+// request has no matrix of its own; call before serving requests
+func ConfigureDistanceProvider(p *provider.Resilient) {
+	roadDistances = p
+}
+
+// distanceCache answers for depots and regular customers without a provider
+// call; PrecomputeDistances fills it
+var distanceCache, _ = provider.OpenCache("")
+
+// OpenDistanceCache loads the precomputed matrix at path and saves
+// refreshed ones there, returning how many locations it covers
+func OpenDistanceCache(path string) (int, error) {
+	c, err := provider.OpenCache(path)
+	if err != nil {
+		return 0, err
+	}
+	distanceCache = c
+	return c.Info().Points, nil
+}
+
+// PrecomputeDistances refreshes the distance cache with road distances among
+// every template depot, vehicle start and end, template stop and standing
+// order, returning how many provider calls it took
+func PrecomputeDistances(ctx context.Context) (int, error) {
+	if roadDistances == nil {
+		return 0, errors.New("no distance provider is configured")
+	}
+	return distanceCache.Precompute(ctx, roadDistances, knownLocations())
+}
+
+// knownLocations lists the places plans regularly visit
+func knownLocations() []models.Location {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	var locs []models.Location
+	for _, t := range routeTemplates {
+		locs = append(locs, t.Depot)
+		for _, v := range t.Vehicles {
+			for _, l := range []*models.Location{v.Start, v.End} {
+				if l != nil {
+					locs = append(locs, *l)
+				}
+			}
+		}
+		for _, rt := range t.Routes {
+			for _, st := range rt.Stops {
+				locs = append(locs, st.Location)
+			}
+		}
+	}
+	for _, o := range standingOrders {
+		locs = append(locs, o.Stop.Location)
+	}
+	return locs
+}
+
+// PrecomputeDistancesHandler refreshes the distance cache now (POST) rather
+// than waiting for the nightly run, or reports what it holds (GET)
+func PrecomputeDistancesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		calls, err := PrecomputeDistances(r.Context())
+		if err != nil {
+			http.Error(w, "Precomputing distances: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		record(r, audit.Event{Kind: "distances.precomputed"}, map[string]int{"points": distanceCache.Info().Points, "calls": calls})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeResponse(w, r, distanceCache.Info())
+}
+
+// ReadyHandler reports readiness with the health of each external provider.
+// The service stays ready while a provider is down, since it falls back to
+// great-circle distances, but reports itself degraded.
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		Status        string             `json:"status"` // ready or degraded
+		Providers     []provider.Health  `json:"providers"`
+		DistanceCache provider.CacheInfo `json:"distance_cache"`
+	}{Status: "ready", Providers: []provider.Health{}, DistanceCache: distanceCache.Info()}
+	if roadDistances != nil {
+		h := roadDistances.Health()
+		if h.Breaker != provider.Closed.String() {
+			resp.Status = "degraded"
+		}
+		resp.Providers = append(resp.Providers, h)
+	}
+	writeResponse(w, r, resp)
+}
+
+// matchTrack snaps a driver's GPS track to roads through the distance
+// provider, or when there is none or it cannot answer, drops the fixes GPS
+// noise adds. It returns the path, its length and which of the two it is.
+func matchTrack(r *http.Request, track []models.Location) ([]models.Location, float64, string) {
+	source := "denoised"
+	if roadDistances != nil {
+		path, km, matched := roadDistances.Match(r.Context(), track)
+		if path != nil {
+			return path, km, matched
+		}
+		source += " (" + matched + ")"
+	}
+	path := geo.Denoise(track)
+	return path, geo.PathKm(path), source
+}
+
+// applyRoadDistances fills p.Matrix from the distance cache or provider when
+// the request did not bring one, s can use it and road distances are on for
+// the tenant, and returns the meta note on where distances came from
+func applyRoadDistances(r *http.Request, p *problem.Problem, s solver.Solver) string {
+	if p.Matrix != nil || !s.Capabilities().Has(solver.CapMatrix) || !featureOn(r, flags.RoadDistances) {
+		return ""
+	}
+	locs := make([]models.Location, len(p.Nodes))
+	for i, n := range p.Nodes {
+		locs[i] = n.Location
+	}
+	if m, ok := distanceCache.Lookup(locs); ok {
+		p.Matrix = m
+		return distanceCache.Info().Source + " (precomputed)"
+	}
+	if roadDistances == nil {
+		return ""
+	}
+	m, source := roadDistances.Matrix(r.Context(), locs)
+	p.Matrix = m
+	return source
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/events"
+	"milesconnect-optimization/internal/notify"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The shipments and vehicles the platform announces on its message
+// broker, by tenant and ID, kept in sync by ConsumeEvents
+var (
+	registryMu       sync.RWMutex
+	shipmentRegistry = map[registryKey]registeredShipment{}
+	vehicleRegistry  = map[registryKey]registeredVehicle{}
+)
+
+type registryKey struct{ tenant, id string }
+
+const (
+	// shipmentSweep is how often expired shipments leave the registry
+	shipmentSweep = time.Hour
+	// shipmentRetention is how long a shipment without a delivery date
+	// stays registered once announced
+	shipmentRetention = 30 * 24 * time.Hour
+)
+
+type registeredShipment struct {
+	Tenant string `json:"tenant,omitempty"`
+	events.Shipment
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type registeredVehicle struct {
+	Tenant string `json:"tenant,omitempty"`
+	events.Vehicle
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// eventPublisher publishes plans and ETA changes; nil publishes nothing
+var eventPublisher *events.Publisher
+
+// ConfigureEvents publishes plan and ETA events to b on prefix + the event
+// type; call before serving requests
+func ConfigureEvents(b events.Broker, prefix string) {
+	eventPublisher = events.NewPublisher(b, prefix)
+}
+
+// ConsumeEvents keeps the shipment and vehicle registries in sync with the
+// events on b under prefix until ctx ends, expiring shipments as they go
+// out of date
+func ConsumeEvents(ctx context.Context, b events.Broker, prefix string) {
+	go sweepShipments(ctx)
+	events.Consume(ctx, b, prefix, map[string]func(events.Event) error{
+		events.ShipmentCreated: applyShipmentCreated,
+		events.VehicleUpdated:  applyVehicleUpdated,
+	})
+}
+
+// applyShipmentCreated registers a shipment; a redelivered event replaces
+// it with the same details
+func applyShipmentCreated(ev events.Event) error {
+	var s events.Shipment
+	if err := json.Unmarshal(ev.Data, &s); err != nil {
+		return err
+	}
+	if s.ID == "" || !validLocation(s.Location) || s.DemandKg < 0 {
+		return errors.New("shipment needs an id, a valid location and a demand of at least 0")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	shipmentRegistry[registryKey{ev.Tenant, s.ID}] = registeredShipment{Tenant: ev.Tenant, Shipment: s, CreatedAt: ev.OccurredAt}
+	return nil
+}
+
+func sweepShipments(ctx context.Context) {
+	t := time.NewTicker(shipmentSweep)
+	defer t.Stop()
+	for {
+		if n := expireShipments(time.Now()); n > 0 {
+			log.Printf("events: expired %d shipments", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// expireShipments drops the shipments due for delivery before yesterday,
+// a day's grace for time zones, and those without a date announced over
+// shipmentRetention ago, and returns how many it dropped
+func expireShipments(now time.Time) int {
+	yesterday := now.AddDate(0, 0, -1).Format(time.DateOnly)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	n := 0
+	for k, s := range shipmentRegistry {
+		if s.Date != "" && s.Date < yesterday || s.Date == "" && now.Sub(s.CreatedAt) > shipmentRetention {
+			delete(shipmentRegistry, k)
+			n++
+		}
+	}
+	return n
+}
+
+// applyVehicleUpdated registers a vehicle's details, or drops it once
+// retired. Updates older than the one registered arrived out of order and
+// are ignored.
+func applyVehicleUpdated(ev events.Event) error {
+	var v events.Vehicle
+	if err := json.Unmarshal(ev.Data, &v); err != nil {
+		return err
+	}
+	if v.ID == "" || v.CapacityKg < 0 || v.Location != nil && !validLocation(*v.Location) {
+		return errors.New("vehicle needs an id, a capacity of at least 0 and a valid location if any")
+	}
+	key := registryKey{ev.Tenant, v.ID}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if cur, ok := vehicleRegistry[key]; ok && ev.OccurredAt.Before(cur.UpdatedAt) {
+		return nil
+	}
+	if v.Retired {
+		delete(vehicleRegistry, key)
+		return nil
+	}
+	vehicleRegistry[key] = registeredVehicle{Tenant: ev.Tenant, Vehicle: v, UpdatedAt: ev.OccurredAt}
+	return nil
+}
+
+// publishPlanEvents announces tenant's published board, and the ETAs among
+// diff that it moved
+func publishPlanEvents(tenant string, b dispatch.Board, shipmentIDs, vehicleIDs []string, diff []notify.Event) {
+	if eventPublisher == nil {
+		return
+	}
+	eventPublisher.Send(events.PlanPublished, tenant, events.Plan{Date: b.Date, Version: b.Version, Vehicles: vehicleIDs, Shipments: shipmentIDs, Unassigned: b.Unassigned})
+	for _, ev := range diff {
+		if ev.Type == notify.ETAUpdated {
+			eventPublisher.Send(events.ETAChanged, tenant, ev)
+		}
+	}
+}
+
+var shipmentList = listSpec[registeredShipment]{
+	key: func(s registeredShipment) string { return s.Tenant + "/" + s.ID },
+	fields: map[string]listField[registeredShipment]{
+		"tenant": {value: func(s registeredShipment) string { return s.Tenant }},
+		"date":   {value: func(s registeredShipment) string { return s.Date }},
+	},
+}
+
+var vehicleList = listSpec[registeredVehicle]{
+	key: func(v registeredVehicle) string { return v.Tenant + "/" + v.ID },
+	fields: map[string]listField[registeredVehicle]{
+		"tenant": {value: func(v registeredVehicle) string { return v.Tenant }},
+		"type":   {value: func(v registeredVehicle) string { return v.Type }},
+	},
+}
+
+// ShipmentsHandler lists the shipments announced on the message broker
+// (GET)
+func ShipmentsHandler(w http.ResponseWriter, r *http.Request) {
+	listRegistry(w, r, shipmentRegistry, shipmentList, func(s registeredShipment) string { return s.Tenant })
+}
+
+// VehiclesHandler lists the vehicles announced on the message broker with
+// their latest details (GET)
+func VehiclesHandler(w http.ResponseWriter, r *http.Request) {
+	listRegistry(w, r, vehicleRegistry, vehicleList, func(v registeredVehicle) string { return v.Tenant })
+}
+
+// listRegistry writes the records of registry the caller reads
+func listRegistry[T any](w http.ResponseWriter, r *http.Request, registry map[registryKey]T, spec listSpec[T], tenant func(T) string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	registryMu.RLock()
+	list := make([]T, 0, len(registry))
+	for _, it := range registry {
+		if who.Reads(tenant(it)) {
+			list = append(list, it)
+		}
+	}
+	registryMu.RUnlock()
+	writeList(w, r, list, spec)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/experiments"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Regression shadowing: each endpoint's reference solver, and how much
+// longer a production plan may be than the reference's before it counts
+// as a regression; see ConfigureRegressionShadowing
+var (
+	referenceSolvers  = map[string]string{}
+	regressionWorseBy = 5.0 // Percent
+)
+
+// referenceSlots bounds the reference solves running at once. They run at
+// low priority: only while the solver pool has room to spare, and outside
+// it, so they never hold up requests.
+var referenceSlots = make(chan struct{}, 1)
+
+var (
+	shadowCompared  = metrics.NewCounter("shadow_compared_total", "Production solves checked against their endpoint's reference solver")
+	shadowRegressed = metrics.NewCounter("shadow_regressions_total", "Production solves significantly worse than their reference solver's")
+	shadowSkipped   = metrics.NewCounter("shadow_skipped_total", "Production solves not checked, the solver pool being busy")
+)
+
+// ConfigureRegressionShadowing has every production solve on an endpoint
+// checked against a reference solver, by unversioned path, e.g.
+// {"/optimize-fleet": "tabu"}, and flags plans more than worseBy percent
+// longer than the reference's, or leaving more stops unassigned. Call
+// before serving requests.
+func ConfigureRegressionShadowing(refs map[string]string, worseBy float64) error {
+	for endpoint, name := range refs {
+		e, ok := experimentEndpoints[endpoint]
+		if !ok {
+			return fmt.Errorf("%s: regressions are only checked on %s", endpoint, strings.Join(slices.Sorted(maps.Keys(experimentEndpoints)), ", "))
+		}
+		s, ok := solver.Get(name)
+		if !ok {
+			return fmt.Errorf("%s: unknown reference solver %q", endpoint, name)
+		}
+		if !s.Capabilities().Has(e.need) {
+			return fmt.Errorf("%s: %s cannot serve the endpoint", endpoint, name)
+		}
+	}
+	referenceSolvers, regressionWorseBy = refs, worseBy
+	return nil
+}
+
+// regression is a production solve the reference solver beat
+type regression struct {
+	ID         string              `json:"id"`
+	At         time.Time           `json:"at"`
+	Endpoint   string              `json:"endpoint"`
+	Tenant     string              `json:"tenant,omitempty"`
+	Solver     string              `json:"solver"`
+	Reference  string              `json:"reference"`
+	Production experiments.Outcome `json:"production"`
+	Against    experiments.Outcome `json:"reference_outcome"`
+	GapPct     float64             `json:"gap_pct"`                // How much longer the production plan is
+	Bundle     string              `json:"debug_bundle,omitempty"` // To download or replay the request
+}
+
+// regressionLog keeps the most recent regressions
+type regressionLog struct {
+	mu   sync.Mutex
+	list []regression
+}
+
+const keptRegressions = 100
+
+var regressions regressionLog
+
+func (l *regressionLog) add(reg regression) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.list) >= keptRegressions {
+		l.list = slices.Delete(l.list, 0, len(l.list)-keptRegressions+1)
+	}
+	l.list = append(l.list, reg)
+}
+
+func (l *regressionLog) all() []regression {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.list)
+}
+
+// checkRegression solves p again with the endpoint's reference solver in
+// the background, and flags the production outcome got when it is
+// significantly worse. Solves that failed are not checked, nor those the
+// reference solver cannot take on equal terms.
+func (t *trial) checkRegression(r *http.Request, p *problem.Problem, got experiments.Outcome) {
+	name, ok := referenceSolvers[t.endpoint]
+	if !ok || got.Failed || name == t.solver.Name() {
+		return
+	}
+	ref, _ := solver.Get(name)
+	need := solver.Capabilities(0)
+	if p.Constraints.TimeWindows {
+		need |= solver.CapTimeWindows
+	}
+	if p.Constraints.Compatibility {
+		need |= solver.CapCompatibility
+	}
+	if p.Matrix != nil {
+		need |= solver.CapMatrix
+	}
+	if !ref.Capabilities().Has(need) {
+		return
+	}
+	if solverPool.Load() >= degradeAt {
+		shadowSkipped.Inc()
+		return
+	}
+	select {
+	case referenceSlots <- struct{}{}:
+	default:
+		shadowSkipped.Inc()
+		return
+	}
+
+	q := *p
+	q.SolverParams, q.Seeds = nil, nil
+	reg := regression{Endpoint: t.endpoint, Tenant: requestTenant(r), Solver: t.solver.Name(), Reference: name, Production: got, Bundle: debugBundleID(r)}
+	go func() {
+		defer func() { <-referenceSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+		start := time.Now()
+		sol, err := ref.Solve(ctx, &q)
+		reg.Against = outcome(&q, sol, err, time.Since(start))
+		if reg.Against.Failed {
+			return
+		}
+		shadowCompared.Inc()
+		if reg.Against.DistanceKm > 0 {
+			reg.GapPct = (got.DistanceKm - reg.Against.DistanceKm) / reg.Against.DistanceKm * 100
+		}
+		if got.Unassigned <= reg.Against.Unassigned && (got.Unassigned < reg.Against.Unassigned || reg.GapPct <= regressionWorseBy) {
+			return
+		}
+		shadowRegressed.Inc()
+		reg.ID, reg.At = newBundleID(), time.Now().UTC()
+		regressions.add(reg)
+		log.Printf("shadow: %s plan on %s is %.1f%% longer than %s's, %d unassigned against %d",
+			reg.Solver, reg.Endpoint, reg.GapPct, reg.Reference, got.Unassigned, reg.Against.Unassigned)
+		record(r, audit.Event{Kind: "shadow.regression", Actor: t.actor}, reg)
+	}()
+}
+
+var regressionList = listSpec[regression]{
+	key: func(reg regression) string { return reg.ID },
+	fields: map[string]listField[regression]{
+		"at": {
+			value:   func(reg regression) string { return reg.At.Format(time.RFC3339) },
+			compare: func(a, b regression) int { return a.At.Compare(b.At) },
+		},
+		"endpoint": {value: func(reg regression) string { return reg.Endpoint }},
+		"solver":   {value: func(reg regression) string { return reg.Solver }},
+		"tenant":   {value: func(reg regression) string { return reg.Tenant }},
+	},
+}
+
+// RegressionsHandler lists the recent production solves their reference
+// solver beat; ?sort=-at puts the newest first (GET, admins only)
+func RegressionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins see regressions", http.StatusForbidden)
+		return
+	}
+	writeList(w, r, regressions.all(), regressionList)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"math"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+)
+
+// reserveCapacity holds back each vehicle's reserve, its own percentage or
+// else pct, from what p may load onto it
+func reserveCapacity(p *problem.Problem, vehicles []models.VehicleInfo, pct float64) {
+	for i, v := range vehicles {
+		p.Vehicles[i].CapacityKg -= v.CapacityKg * reservePct(v, pct) / 100
+	}
+}
+
+func reservePct(v models.VehicleInfo, pct float64) float64 {
+	if v.ReservePct > 0 {
+		return v.ReservePct
+	}
+	return pct
+}
+
+// addCapacityUse reports reserved against used capacity on each route whose
+// vehicle keeps a reserve
+func addCapacityUse(routes []models.FleetRoute, vehicles []models.VehicleInfo, pct float64) {
+	byID := map[string]models.VehicleInfo{}
+	for _, v := range vehicles {
+		byID[v.ID] = v
+	}
+	round := func(kg float64) float64 { return math.Round(kg*100) / 100 }
+	for i, r := range routes {
+		v := byID[r.VehicleID]
+		reserved := v.CapacityKg * reservePct(v, pct) / 100
+		if reserved == 0 {
+			continue
+		}
+		routes[i].Capacity = &models.CapacityUse{
+			CapacityKg: v.CapacityKg,
+			ReservedKg: round(reserved),
+			UsedKg:     round(r.LoadKg),
+			FreeKg:     round(math.Max(v.CapacityKg-reserved-r.LoadKg, 0)),
+		}
+	}
+}
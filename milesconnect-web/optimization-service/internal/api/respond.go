@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/encoding/msgpack"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"strings"
+)
+
+// writeResponse encodes v according to the Accept header. MessagePack is
+// offered to high-throughput internal consumers; everyone else gets JSON.
+// Protobuf is not offered since the service carries no generated schemas.
+func writeResponse(w http.ResponseWriter, r *http.Request, v any) {
+	writeStatus(w, r, http.StatusOK, v)
+}
+
+// routePolyline encodes a route's stop sequence as a Google encoded polyline
+// when the caller asked for ?geometry=polyline, for map clients that would
+// rather not carry coordinate arrays. It joins the stops in order; it is not
+// the road path between them.
+func routePolyline(r *http.Request, route []models.Location) string {
+	if r.URL.Query().Get("geometry") != "polyline" {
+		return ""
+	}
+	return geo.EncodePolyline(route, geo.Precision5)
+}
+
+// addRouteLinks gives each fleet route its Google Maps navigation links and,
+// if asked for, its polyline
+func addRouteLinks(r *http.Request, routes []models.FleetRoute) {
+	for i := range routes {
+		routes[i].NavigationURLs = navigationURLs(routes[i].Route)
+		routes[i].Polyline = routePolyline(r, routes[i].Route)
+	}
+}
+
+// writeStatus is writeResponse with a status other than 200
+func writeStatus(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, msgpack.ContentType) || strings.Contains(accept, "application/x-msgpack") {
+		body, err := msgpack.Marshal(v)
+		if err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", msgpack.ContentType)
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	// Marshal up front so an unencodable value (e.g. NaN) becomes a 500
+	// instead of a silently empty 200
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
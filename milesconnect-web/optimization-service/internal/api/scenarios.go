@@ -0,0 +1,210 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/toll"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxScenariosPerDay bounds the candidate plans a tenant keeps for one date
+const maxScenariosPerDay = 20
+
+var (
+	scenariosMu sync.Mutex
+	scenarios   = map[string]map[string]map[string]*models.Scenario{} // By tenant, date, then name
+)
+
+var scenarioList = listSpec[models.Scenario]{
+	key: func(s models.Scenario) string { return s.Tenant + "/" + s.Date + "/" + s.Name },
+	fields: map[string]listField[models.Scenario]{
+		"date": {value: func(s models.Scenario) string { return s.Date }},
+		"saved_at": {
+			value:   func(s models.Scenario) string { return s.SavedAt.Format(time.RFC3339) },
+			compare: func(a, b models.Scenario) int { return a.SavedAt.Compare(b.SavedAt) },
+		},
+	},
+}
+
+// ScenariosHandler keeps each tenant's candidate plans for a day: GET lists
+// them, or returns one with ?date= and ?name=; POST saves one, replacing
+// the tenant's scenario of the same name; DELETE ?date=&name= drops one.
+// Admins name the tenant with ?tenant=, or in a saved scenario.
+func ScenariosHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	tenant := recordTenant(who, q.Get("tenant"))
+	switch r.Method {
+	case http.MethodGet:
+		scenariosMu.Lock()
+		defer scenariosMu.Unlock()
+		if name := q.Get("name"); name != "" {
+			s, ok := scenarios[tenant][q.Get("date")][name]
+			if !ok {
+				http.Error(w, "Unknown scenario", http.StatusNotFound)
+				return
+			}
+			writeResponse(w, r, s)
+			return
+		}
+		list := []models.Scenario{}
+		for t, days := range scenarios {
+			if !who.Reads(t) {
+				continue
+			}
+			for _, day := range days {
+				for _, s := range day {
+					list = append(list, *s)
+				}
+			}
+		}
+		writeList(w, r, list, scenarioList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var s models.Scenario
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if s.Name == "" {
+			http.Error(w, "A scenario needs a name", http.StatusBadRequest)
+			return
+		}
+		if _, err := time.Parse(time.DateOnly, s.Date); err != nil {
+			http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		if !finite(s.CostPerKm) || s.CostPerKm < 0 {
+			http.Error(w, "Cost per km must not be negative", http.StatusBadRequest)
+			return
+		}
+		s.Tenant = recordTenant(who, s.Tenant)
+		if !writable(w, who, s.Tenant) {
+			return
+		}
+		s.CostPerKm = cmp.Or(s.CostPerKm, settingsOf(s.Tenant).CostPerKm)
+		s.KPIs = scenarioKPIs(s.Plan, s.CostPerKm)
+		s.SavedAt = time.Now().UTC()
+
+		scenariosMu.Lock()
+		defer scenariosMu.Unlock()
+		day := scenarios[s.Tenant][s.Date]
+		if _, ok := day[s.Name]; !ok && len(day) >= maxScenariosPerDay {
+			http.Error(w, "At most "+strconv.Itoa(maxScenariosPerDay)+" scenarios are kept for a day", http.StatusConflict)
+			return
+		}
+		if day == nil {
+			if scenarios[s.Tenant] == nil {
+				scenarios[s.Tenant] = map[string]map[string]*models.Scenario{}
+			}
+			day = map[string]*models.Scenario{}
+			scenarios[s.Tenant][s.Date] = day
+		}
+		day[s.Name] = &s
+		record(r, audit.Event{Kind: "scenario.saved", Tenant: s.Tenant, Date: s.Date}, map[string]any{"name": s.Name, "kpis": s.KPIs})
+		writeStatus(w, r, http.StatusCreated, s)
+
+	case http.MethodDelete:
+		date, name := q.Get("date"), q.Get("name")
+		scenariosMu.Lock()
+		defer scenariosMu.Unlock()
+		if _, ok := scenarios[tenant][date][name]; !ok {
+			http.Error(w, "Unknown scenario", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, tenant) {
+			return
+		}
+		delete(scenarios[tenant][date], name)
+		if len(scenarios[tenant][date]) == 0 {
+			delete(scenarios[tenant], date)
+		}
+		if len(scenarios[tenant]) == 0 {
+			delete(scenarios, tenant)
+		}
+		record(r, audit.Event{Kind: "scenario.deleted", Tenant: tenant, Date: date}, map[string]string{"name": name})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ScenarioCompareHandler sets two of a tenant's scenarios for a day side
+// by side: ?date=, ?a= and ?b= name them, and the deltas are b less a
+func ScenarioCompareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	date, an, bn := q.Get("date"), q.Get("a"), q.Get("b")
+	if an == "" || bn == "" {
+		http.Error(w, "a and b must name two scenarios", http.StatusBadRequest)
+		return
+	}
+
+	tenant := recordTenant(who, q.Get("tenant"))
+	scenariosMu.Lock()
+	a, aok := scenarios[tenant][date][an]
+	b, bok := scenarios[tenant][date][bn]
+	scenariosMu.Unlock()
+	if !aok || !bok {
+		http.Error(w, "Unknown scenario", http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, compareScenarios(a, b))
+}
+
+// scenarioKPIs measures plan with running cost at costPerKm, default 30
+func scenarioKPIs(plan models.FleetResponse, costPerKm float64) models.ScenarioKPIs {
+	costPerKm = cmp.Or(costPerKm, toll.DefaultCostPerKm)
+	k := models.ScenarioKPIs{Unassigned: len(plan.Unassigned)}
+	tolls := 0.0
+	for _, route := range plan.Routes {
+		k.DistanceKm += route.DistanceKm
+		tolls += route.TollInr
+		if len(route.StopIDs) > 0 {
+			k.Vehicles++
+			k.Stops += len(route.StopIDs)
+		}
+	}
+	if f := plan.Feasibility; f != nil {
+		k.Violations = len(f.Violations)
+	}
+	k.CostInr = math.Round((k.DistanceKm*costPerKm+tolls)*100) / 100
+	k.DistanceKm = math.Round(k.DistanceKm*100) / 100
+	return k
+}
+
+func compareScenarios(a, b *models.Scenario) models.ScenarioComparison {
+	return models.ScenarioComparison{
+		Date:  a.Date,
+		A:     a.Name,
+		B:     b.Name,
+		AKPIs: a.KPIs,
+		BKPIs: b.KPIs,
+		Delta: models.ScenarioKPIs{
+			DistanceKm: math.Round((b.KPIs.DistanceKm-a.KPIs.DistanceKm)*100) / 100,
+			CostInr:    math.Round((b.KPIs.CostInr-a.KPIs.CostInr)*100) / 100,
+			Vehicles:   b.KPIs.Vehicles - a.KPIs.Vehicles,
+			Stops:      b.KPIs.Stops - a.KPIs.Stops,
+			Unassigned: b.KPIs.Unassigned - a.KPIs.Unassigned,
+			Violations: b.KPIs.Violations - a.KPIs.Violations,
+		},
+	}
+}
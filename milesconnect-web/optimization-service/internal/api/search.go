@@ -0,0 +1,69 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/dispatch"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var searchList = listSpec[dispatch.Hit]{
+	key: func(h dispatch.Hit) string { return h.ID + "/" + h.Date + "/" + h.Tenant },
+	fields: map[string]listField[dispatch.Hit]{
+		"tenant":     {value: func(h dispatch.Hit) string { return h.Tenant }},
+		"status":     {value: func(h dispatch.Hit) string { return string(h.Status) }},
+		"vehicle_id": {value: func(h dispatch.Hit) string { return h.VehicleID }},
+		"date":       {value: func(h dispatch.Hit) string { return h.Date }},
+	},
+}
+
+// SearchHandler finds where shipments are planned across the dispatched
+// plans: ?shipment= is an ID prefix, looked up in the board's ID index;
+// ?customer= matches part of the customer's name and ?city= the city, both
+// ignoring case; ?from= and ?to= bound the dates; ?status= and
+// ?vehicle_id= filter as on other lists. Shipments a plan left off are
+// found too, with status unassigned. Only the plans of tenants the
+// caller reads are searched.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse(time.DateOnly, d); d != "" && err != nil {
+			http.Error(w, "Dates must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if from != "" && to != "" && to < from {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	writeList(w, r, findShipments(q.Get("shipment"), q.Get("customer"), q.Get("city"), from, to, who.Reads), searchList)
+}
+
+// findShipments searches the plans for from through to of the tenants
+// reads accepts by ID prefix, then by part of the customer's name and by
+// city, ignoring case
+func findShipments(prefix, customer, city, from, to string, reads func(string) bool) []dispatch.Hit {
+	customer = strings.ToLower(customer)
+	hits := dispatched.Search(prefix, from, to, reads)
+	kept := hits[:0]
+	for _, h := range hits {
+		if customer != "" && !strings.Contains(strings.ToLower(h.Customer), customer) {
+			continue
+		}
+		if city != "" && !strings.EqualFold(h.City, city) {
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept
+}
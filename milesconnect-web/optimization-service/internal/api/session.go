@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Planning session states. A session is closed once its cutoff passes and
+// until someone asks for its plan.
+const (
+	sessionOpen     = "open"
+	sessionClosed   = "closed"
+	sessionPlanning = "planning"
+	sessionPlanned  = "planned"
+)
+
+var (
+	sessionsMu       sync.Mutex
+	planningSessions = map[string]*models.PlanningSession{}
+)
+
+var sessionList = listSpec[models.PlanningSession]{
+	key: func(s models.PlanningSession) string { return s.Name },
+	fields: map[string]listField[models.PlanningSession]{
+		"status": {value: func(s models.PlanningSession) string { return s.Status }},
+		"cutoff": {
+			value:   func(s models.PlanningSession) string { return s.Cutoff.Format(time.RFC3339) },
+			compare: func(a, b models.PlanningSession) int { return a.Cutoff.Compare(b.Cutoff) },
+		},
+	},
+}
+
+// session returns a copy of s with its status brought up to date. Callers
+// hold sessionsMu.
+func session(s *models.PlanningSession) models.PlanningSession {
+	if s.Status == sessionOpen && !time.Now().Before(s.Cutoff) {
+		s.Status = sessionClosed
+	}
+	return *s
+}
+
+// SessionsHandler lists planning sessions (GET, or one with ?name=), opens
+// one (POST) or drops one (DELETE ?name=)
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		if name := r.URL.Query().Get("name"); name != "" {
+			s, ok := planningSessions[name]
+			if !ok || !who.Reads(s.Tenant) {
+				http.Error(w, "Unknown planning session", http.StatusNotFound)
+				return
+			}
+			writeResponse(w, r, session(s))
+			return
+		}
+		list := []models.PlanningSession{}
+		for _, s := range planningSessions {
+			if who.Reads(s.Tenant) {
+				list = append(list, session(s))
+			}
+		}
+		writeList(w, r, list, sessionList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var s models.PlanningSession
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if s.Name == "" || !s.Cutoff.After(time.Now()) {
+			http.Error(w, "A planning session needs a name and a cutoff in the future", http.StatusBadRequest)
+			return
+		}
+		if len(s.Fleet.Stops) > 0 {
+			http.Error(w, "Stops are submitted to a session, not opened with it", http.StatusBadRequest)
+			return
+		}
+		s.Tenant = recordTenant(who, s.Tenant)
+		if !writable(w, who, s.Tenant) {
+			return
+		}
+		applyFleetDefaults(&s.Fleet, settingsOf(s.Tenant))
+		if err := resolveFleetRequest(&s.Fleet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateFleetRequest(s.Fleet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Status, s.Submissions, s.Plan = sessionOpen, nil, nil
+
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		if _, ok := planningSessions[s.Name]; ok {
+			http.Error(w, "Planning session "+s.Name+" already exists", http.StatusConflict)
+			return
+		}
+		planningSessions[s.Name] = &s
+		record(r, audit.Event{Kind: "session.opened"}, s)
+		writeStatus(w, r, http.StatusCreated, s)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		templatesMu.Lock() // For the trash; always taken before sessionsMu
+		defer templatesMu.Unlock()
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		s, ok := planningSessions[name]
+		if !ok || !who.Reads(s.Tenant) {
+			http.Error(w, "Unknown planning session", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, s.Tenant) {
+			return
+		}
+		if s.Status == sessionPlanning {
+			http.Error(w, "Planning session "+name+" is being planned", http.StatusConflict)
+			return
+		}
+		moveToTrash(trashSession, name, s.Tenant, session(s))
+		delete(planningSessions, name)
+		record(r, audit.Event{Kind: "session.deleted"}, map[string]string{"name": name})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// SessionShipmentsHandler adds a client's shipments to the open session
+// ?name=
+func SessionShipmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var sub models.SessionSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if sub.Client == "" || len(sub.Stops) == 0 {
+		http.Error(w, "A submission needs a client and at least one stop", http.StatusBadRequest)
+		return
+	}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	s, ok := planningSessions[r.URL.Query().Get("name")]
+	if !ok || !who.Reads(s.Tenant) {
+		http.Error(w, "Unknown planning session", http.StatusNotFound)
+		return
+	}
+	if !writable(w, who, s.Tenant) {
+		return
+	}
+	if session(s).Status != sessionOpen {
+		http.Error(w, "Planning session "+s.Name+" closed at "+s.Cutoff.Format(time.RFC3339), http.StatusConflict)
+		return
+	}
+	seen := map[string]bool{}
+	for _, st := range s.Fleet.Stops {
+		seen[st.ID] = true
+	}
+	for _, st := range sub.Stops {
+		if st.ID == "" || seen[st.ID] {
+			http.Error(w, "Stop IDs must be set and unique across the session", http.StatusConflict)
+			return
+		}
+		seen[st.ID] = true
+	}
+
+	// The session's fleet with these stops must still be a valid request
+	fleet := s.Fleet
+	fleet.Stops = append(append([]models.FleetStop{}, s.Fleet.Stops...), sub.Stops...)
+	if err := resolveFleetRequest(&fleet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateFleetRequest(fleet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub.Stops = fleet.Stops[len(s.Fleet.Stops):]
+	sub.SubmittedAt = time.Now().UTC()
+	s.Fleet.Stops = fleet.Stops
+	s.Submissions = append(s.Submissions, sub)
+
+	ids := make([]string, len(sub.Stops))
+	for i, st := range sub.Stops {
+		ids[i] = st.ID
+	}
+	record(r, audit.Event{Kind: "session.submitted", Shipments: ids}, sub)
+	writeResponse(w, r, session(s))
+}
+
+// SessionPlanHandler plans every shipment in the session ?name= once its
+// cutoff has passed. The first call runs the optimization; later ones
+// return the same plan.
+func SessionPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+
+	sessionsMu.Lock()
+	s, ok := planningSessions[r.URL.Query().Get("name")]
+	if !ok || !who.Reads(s.Tenant) {
+		sessionsMu.Unlock()
+		http.Error(w, "Unknown planning session", http.StatusNotFound)
+		return
+	}
+	// Anyone who can see a session can read its plan; making it is a change
+	if session(s).Status != sessionPlanned && !writable(w, who, s.Tenant) {
+		sessionsMu.Unlock()
+		return
+	}
+	switch session(s).Status {
+	case sessionOpen:
+		sessionsMu.Unlock()
+		http.Error(w, "Planning session "+s.Name+" is open until "+s.Cutoff.Format(time.RFC3339), http.StatusConflict)
+		return
+	case sessionPlanning:
+		sessionsMu.Unlock()
+		http.Error(w, "Planning session "+s.Name+" is being planned", http.StatusConflict)
+		return
+	case sessionPlanned:
+		defer sessionsMu.Unlock()
+		writeResponse(w, r, *s)
+		return
+	}
+	if len(s.Fleet.Stops) == 0 {
+		sessionsMu.Unlock()
+		http.Error(w, "Planning session "+s.Name+" has no shipments", http.StatusConflict)
+		return
+	}
+	s.Status = sessionPlanning
+	fleet := s.Fleet
+	sessionsMu.Unlock()
+
+	// Plan through the fleet handler so the session gets every option a
+	// direct request would, then keep its answer
+	body, _ := json.Marshal(fleet)
+	fr := r.Clone(r.Context())
+	fr.Body = io.NopCloser(bytes.NewReader(body))
+	fr.ContentLength = int64(len(body))
+	fr.Header.Set("Accept", "application/json")
+	rec := &capturedResponse{header: http.Header{}, status: http.StatusOK}
+	OptimizeFleetHandler(rec, fr)
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	var plan models.FleetResponse
+	if rec.status != http.StatusOK || json.Unmarshal(rec.body.Bytes(), &plan) != nil {
+		s.Status = sessionClosed // Let a later call try again
+		http.Error(w, strings.TrimSpace(rec.body.String()), failedPlanStatus(rec.status))
+		return
+	}
+	s.Status, s.Plan = sessionPlanned, &plan
+	record(r, audit.Event{Kind: "session.planned"}, map[string]string{"name": s.Name})
+	writeResponse(w, r, *s)
+}
+
+// failedPlanStatus reports a failed session plan with its own status, or 502 when
+// the plan came back but could not be read
+func failedPlanStatus(status int) int {
+	if status == http.StatusOK {
+		return http.StatusBadGateway
+	}
+	return status
+}
+
+// capturedResponse holds a handler's response for the caller to use
+type capturedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *capturedResponse) Header() http.Header { return c.header }
+
+func (c *capturedResponse) Write(b []byte) (int, error) { return c.body.Write(b) }
+
+func (c *capturedResponse) WriteHeader(status int) { c.status = status }
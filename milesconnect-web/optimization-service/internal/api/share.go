@@ -0,0 +1,246 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/notify"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultShareTTL = 24 * time.Hour
+	maxShareTTL     = 7 * 24 * time.Hour
+)
+
+// shareSecret signs share links; sharing is off until it is set. It is kept
+// apart from the driver secret so a share link can never act as a driver.
+var shareSecret []byte
+
+// SetShareSecret sets the secret share links are signed with
+func SetShareSecret(secret string) {
+	shareSecret = []byte(secret)
+}
+
+// ShareHandler mints a read-only link to a dispatched plan, or to one
+// vehicle's run in it, that expires after ttl_hours (default 24, at most a
+// week)
+func ShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(shareSecret) == 0 {
+		http.Error(w, "Sharing is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	_, tenant, ok := boardTenant(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var req models.ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, ok := dispatchDate(w, req.Date)
+	if !ok {
+		return
+	}
+	ttl := defaultShareTTL
+	if req.TTLHours != 0 {
+		ttl = time.Duration(req.TTLHours * float64(time.Hour))
+		if ttl <= 0 || ttl > maxShareTTL {
+			http.Error(w, "ttl_hours must be between 0 and 168", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.VehicleID == "" {
+		if _, ok := dispatched.Board(tenant, date); !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+	} else if _, ok := dispatched.Run(tenant, date, req.VehicleID); !ok {
+		http.Error(w, "No run dispatched for this vehicle on "+date, http.StatusNotFound)
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	token := auth.Sign(shareSecret, shareSubject(tenant, date, req.VehicleID), expires)
+	ev := audit.Event{Kind: "share.created", Tenant: tenant, Date: date}
+	if req.VehicleID != "" {
+		ev.Vehicles = []string{req.VehicleID}
+	}
+	record(r, ev, map[string]any{"expires_at": expires.UTC()})
+	writeResponse(w, r, models.ShareLink{Token: token, URL: "/shared/" + token, CalendarURL: "/shared/" + token + ".ics", ExpiresAt: expires.UTC()})
+}
+
+// shareSubject names what a share link shows: "date/vehicle", prefixed
+// with "tenant:" for a tenant's plans. Links minted before tenants were
+// recorded carry no prefix and stay on the shared board.
+func shareSubject(tenant, date, vehicleID string) string {
+	s := date + "/" + vehicleID
+	if tenant != "" {
+		s = tenant + ":" + s
+	}
+	return s
+}
+
+// parseShareSubject splits a shareSubject. A colon counts as the tenant's
+// only before the first slash, since vehicle IDs may contain one.
+func parseShareSubject(subject string) (tenant, date, vehicleID string, ok bool) {
+	head, vehicleID, ok := strings.Cut(subject, "/")
+	if !ok {
+		return "", "", "", false
+	}
+	if t, d, found := strings.Cut(head, ":"); found {
+		tenant, head = t, d
+	}
+	return tenant, head, vehicleID, true
+}
+
+// sharedStop is what a share link shows of a stop: where it is in the day
+// and when it is due, but not driver tracks or dispatcher notes
+type sharedStop struct {
+	ID         string          `json:"id"`
+	Status     dispatch.Status `json:"status"`
+	Window     *notify.Window  `json:"eta_window,omitempty"`
+	ArrivedAt  time.Time       `json:"arrived_at,omitzero"`
+	DepartedAt time.Time       `json:"departed_at,omitzero"`
+}
+
+type sharedRun struct {
+	VehicleID string          `json:"vehicle_id"`
+	Status    dispatch.Status `json:"status"`
+	Stops     []sharedStop    `json:"stops"`
+}
+
+type sharedPlan struct {
+	Date      string      `json:"date"`
+	Runs      []sharedRun `json:"runs"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// SharedPlanHandler serves /shared/<token> without other authentication:
+// the plan or run the token names, as a page or, to clients that accept
+// JSON, as JSON; /shared/<token>.ics is the same as a calendar feed to
+// subscribe to. It reflects live stop progress until the token expires.
+func SharedPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Links get forwarded and pasted; keep the token out of referrers, caches
+	// and search indexes
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	token, feed := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/shared/"), ".ics")
+	if len(shareSecret) == 0 {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+	subject, err := auth.Verify(shareSecret, token, time.Now())
+	if errors.Is(err, auth.ErrExpired) {
+		http.Error(w, "This link has expired", http.StatusGone)
+		return
+	}
+	tenant, date, vehicleID, ok := parseShareSubject(subject)
+	if err != nil || !ok {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	b, ok := dispatched.Board(tenant, date)
+	if !ok {
+		http.Error(w, "This plan is no longer available", http.StatusGone)
+		return
+	}
+	var runs []dispatch.Run
+	for _, run := range b.Runs {
+		if vehicleID == "" || run.VehicleID == vehicleID {
+			runs = append(runs, run)
+		}
+	}
+	if len(runs) == 0 {
+		http.Error(w, "This run is no longer part of the plan", http.StatusGone)
+		return
+	}
+	if feed {
+		writeCalendar(w, cmp.Or(vehicleID, "Deliveries")+" "+date, date, runs)
+		return
+	}
+
+	view := sharedPlan{Date: date, Runs: []sharedRun{}, UpdatedAt: b.PublishedAt}
+	for _, run := range runs {
+		sr := sharedRun{VehicleID: run.VehicleID, Status: run.Status, Stops: []sharedStop{}}
+		for _, st := range run.Stops {
+			sr.Stops = append(sr.Stops, sharedStop{
+				ID:         st.ID,
+				Status:     st.Status,
+				Window:     notifyPolicy.Window(date, st.ETAHours),
+				ArrivedAt:  st.ArrivedAt,
+				DepartedAt: st.DepartedAt,
+			})
+			if st.UpdatedAt.After(view.UpdatedAt) {
+				view.UpdatedAt = st.UpdatedAt
+			}
+		}
+		view.Runs = append(view.Runs, sr)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "json") {
+		writeResponse(w, r, view)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sharedPage.Execute(w, view); err != nil {
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+	}
+}
+
+var sharedPage = template.Must(template.New("shared").Funcs(template.FuncMap{
+	"clock": func(t time.Time) string { return t.In(notifyPolicy.Zone).Format("15:04") },
+	"zone":  func() string { return notifyPolicy.Zone.String() },
+	"inc":   func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Deliveries for {{.Date}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 1.5rem; color: #1f2933; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; }
+th, td { text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #e4e7eb; }
+.completed { color: #3f9142; } .en_route { color: #c65d00; }
+</style>
+</head>
+<body>
+<h1>Deliveries for {{.Date}}</h1>
+{{range .Runs}}
+<h2>Vehicle {{.VehicleID}} <small class="{{.Status}}">{{.Status}}</small></h2>
+<table>
+<tr><th>#</th><th>Stop</th><th>Expected</th><th>Status</th></tr>
+{{range $i, $s := .Stops}}<tr>
+<td>{{inc $i}}</td><td>{{$s.ID}}</td>
+<td>{{with $s.Window}}{{clock .From}}–{{clock .To}}{{end}}</td>
+<td class="{{$s.Status}}">{{$s.Status}}{{if not $s.DepartedAt.IsZero}} at {{clock $s.DepartedAt}}{{else if not $s.ArrivedAt.IsZero}} (arrived {{clock $s.ArrivedAt}}){{end}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+<p><small>Updated {{clock .UpdatedAt}}. Times are {{zone}}.</small></p>
+</body>
+</html>
+`))
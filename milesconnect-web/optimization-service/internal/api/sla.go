@@ -0,0 +1,96 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"time"
+)
+
+// SLA tiers, picked with ?profile=. Each bundles a time budget with a
+// solver and parameters per endpoint, so a frontend gets predictable
+// latency or quality without knowing the solvers. They take precedence
+// over tuned profiles of the same name.
+const (
+	tierFast     = "fast"
+	tierBalanced = "balanced"
+	tierBest     = "best"
+)
+
+// slaTier is what a tier runs on one endpoint
+type slaTier struct {
+	Solver string             `json:"solver"`
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// slaBudgets caps a tiered request's deadline; best keeps the endpoint's
+var slaBudgets = map[string]time.Duration{
+	tierFast:     5 * time.Second,
+	tierBalanced: 20 * time.Second,
+}
+
+// slaTiers are keyed by the endpoint's default solver, then tier. The
+// budget bounds the whole request; time_limit_ms is what the solver itself
+// spends searching.
+var slaTiers = map[string]map[string]slaTier{
+	defaultRouteSolver: {
+		tierFast:     {Solver: "two-opt"},
+		tierBalanced: {Solver: "guided-local-search"},
+		tierBest:     {Solver: "guided-local-search", Params: map[string]float64{"time_limit_ms": 3000}},
+	},
+	defaultLoadSolver: { // One allocation solver; it is quick on any tier
+		tierFast:     {Solver: "best-fit-decreasing"},
+		tierBalanced: {Solver: "best-fit-decreasing"},
+		tierBest:     {Solver: "best-fit-decreasing"},
+	},
+	defaultFleetSolver: {
+		tierFast:     {Solver: "alns", Params: map[string]float64{"alns_iterations": 500, "time_limit_ms": 1000}},
+		tierBalanced: {Solver: "alns"},
+		tierBest:     {Solver: "alns", Params: map[string]float64{"alns_iterations": 50000, "time_limit_ms": 30000}},
+	},
+	allIndiaSolver: {
+		tierFast:     {Solver: "genetic", Params: map[string]float64{"population_size": 50, "generations": 150}},
+		tierBalanced: {Solver: "genetic"},
+		tierBest:     {Solver: "memetic", Params: map[string]float64{"generations": 1000}},
+	},
+}
+
+// lookupTier returns what tier runs on the endpoint defaulting to solver def
+func lookupTier(name, def string) (slaTier, bool) {
+	t, ok := slaTiers[def][name]
+	t.Params = maps.Clone(t.Params)
+	return t, ok
+}
+
+// slaEndpoints names each tiered endpoint's default solver for /profiles/sla
+var slaEndpoints = map[string]string{
+	"/optimize":            defaultRouteSolver,
+	"/optimize-load":       defaultLoadSolver,
+	"/optimize-fleet":      defaultFleetSolver,
+	"/optimize-first-mile": defaultFleetSolver,
+	"/sessions/plan":       defaultFleetSolver,
+	"/optimize-india":      allIndiaSolver,
+}
+
+// SLAProfilesHandler lists the SLA tiers: each tier's budget and what it
+// runs on every endpoint
+func SLAProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type tierInfo struct {
+		Name      string             `json:"name"`
+		BudgetMs  int64              `json:"budget_ms,omitempty"` // Omitted when the endpoint's own timeout applies
+		Endpoints map[string]slaTier `json:"endpoints"`
+	}
+	list := []tierInfo{}
+	for _, name := range []string{tierFast, tierBalanced, tierBest} {
+		info := tierInfo{Name: name, BudgetMs: slaBudgets[name].Milliseconds(), Endpoints: map[string]slaTier{}}
+		for path, def := range slaEndpoints {
+			info.Endpoints[path] = slaTiers[def][name]
+		}
+		list = append(list, info)
+	}
+	writeResponse(w, r, list)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"maps"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+)
+
+// speedFactors is how fast each vehicle type drives relative to the plan's
+// speed: small trucks keep up with traffic, long bodies and trailers crawl
+// through ghats and towns. SetSpeedFactors adjusts it.
+var speedFactors = map[string]float64{
+	"mini-truck": 1.1, // Tata Ace class
+	"pickup":     1.05,
+	"14ft":       1,
+	"17ft":       1,
+	"19ft":       0.95,
+	"22ft":       0.9,
+	"24ft":       0.9,
+	"32ft":       0.8, // SXL and MXL containers
+	"trailer":    0.7,
+}
+
+// SetSpeedFactors adds or replaces vehicle types' speed factors; call
+// before serving requests
+func SetSpeedFactors(f map[string]float64) {
+	maps.Copy(speedFactors, f)
+}
+
+// applySpeedFactors sets each of p's vehicles to drive at its own speed
+// factor or its type's
+func applySpeedFactors(p *problem.Problem, vehicles []models.VehicleInfo) {
+	for i, v := range vehicles {
+		if v.SpeedFactor == 0 {
+			p.Vehicles[i].SpeedFactor = speedFactors[v.Type]
+		}
+	}
+}
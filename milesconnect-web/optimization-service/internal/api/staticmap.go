@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/staticmap"
+	"net/http"
+	"strconv"
+)
+
+// Default static map size, suited to an email body
+const (
+	mapWidth  = 640
+	mapHeight = 400
+)
+
+// MapHandler draws routes onto a PNG for emails and PDFs. GET draws the
+// runs dispatched for ?date=, or one with ?vehicle_id=; POST draws the
+// routes in the body, e.g. a fleet response. ?width= and ?height= size the
+// image.
+func MapHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	width, height := mapWidth, mapHeight
+	for _, d := range []struct {
+		name string
+		n    *int
+	}{{"width", &width}, {"height", &height}} {
+		if v := q.Get(d.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, d.name+" must be an integer", http.StatusBadRequest)
+				return
+			}
+			*d.n = n
+		}
+	}
+
+	var routes [][]models.Location
+	switch r.Method {
+	case http.MethodGet:
+		_, tenant, ok := boardTenant(w, r)
+		if !ok {
+			return
+		}
+		date, ok := dispatchDate(w, q.Get("date"))
+		if !ok {
+			return
+		}
+		b, ok := dispatched.Board(tenant, date)
+		if !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+		runs := b.Runs
+		if v := q.Get("vehicle_id"); v != "" {
+			run, ok := dispatched.Run(tenant, date, v)
+			if !ok {
+				http.Error(w, "No run dispatched for this vehicle on "+date, http.StatusNotFound)
+				return
+			}
+			runs = []dispatch.Run{run}
+		}
+		for _, run := range runs {
+			routes = append(routes, runPath(run))
+		}
+	case http.MethodPost:
+		limitBody(w, r)
+		var req models.MapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		for _, fr := range req.Routes {
+			for _, l := range fr.Route {
+				if !validLocation(l) {
+					http.Error(w, "Route points must be valid coordinates", http.StatusBadRequest)
+					return
+				}
+			}
+			routes = append(routes, fr.Route)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported as an error
+	var buf bytes.Buffer
+	if err := staticmap.Render(&buf, routes, width, height); err != nil {
+		if errors.Is(err, staticmap.ErrNothingToDraw) {
+			http.Error(w, "No route has a point to draw", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// runPath is where a run goes: its start, its stops in order and its end,
+// leaving out whichever of the two the board does not know
+func runPath(run dispatch.Run) []models.Location {
+	var path []models.Location
+	if run.Start != (models.Location{}) {
+		path = append(path, run.Start)
+	}
+	for _, st := range run.Stops {
+		path = append(path, st.Location)
+	}
+	if run.End != (models.Location{}) {
+		path = append(path, run.End)
+	}
+	return path
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"strings"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// wantsNDJSON reports whether the client asked for a streamed NDJSON response,
+// either via the Accept header or ?stream=ndjson
+func wantsNDJSON(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "ndjson" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// writeRouteNDJSON streams a route one leg per line, followed by a summary line.
+// Each line is flushed so neither side has to buffer the whole route.
+func writeRouteNDJSON(w http.ResponseWriter, resp models.OptimizationResponse) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	for i := 1; i < len(resp.Route); i++ {
+		leg := models.RouteLeg{
+			Seq:    i,
+			From:   resp.Route[i-1],
+			To:     resp.Route[i],
+			DistKm: solver.DistanceKm(resp.Route[i-1], resp.Route[i]),
+		}
+		if err := enc.Encode(leg); err != nil {
+			return // Client went away
+		}
+		if flusher != nil && i%100 == 0 {
+			flusher.Flush()
+		}
+	}
+
+	enc.Encode(models.RouteSummary{
+		Legs:        max(len(resp.Route)-1, 0),
+		TotalDistKm: resp.TotalDistKm,
+	})
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/email"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/pdf"
+	"milesconnect-optimization/internal/templates"
+	"milesconnect-optimization/internal/toll"
+	"milesconnect-optimization/internal/xlsx"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailer sends tenants' daily plan summaries; nil sends none
+var mailer *email.Mailer
+
+var (
+	summariesSent   = metrics.NewCounter("summary_emails_sent_total", "Daily plan summaries mailed")
+	summariesFailed = metrics.NewCounter("summary_emails_failed_total", "Daily plan summaries the mail relay refused or could not be reached for")
+)
+
+// ConfigureSummaryEmail mails daily plan summaries through m; call before
+// serving requests
+func ConfigureSummaryEmail(m *email.Mailer) {
+	mailer = m
+}
+
+// RunSummaryEmails mails each tenant's summary of the day's plan at the
+// time its settings schedule, or once the plan is published if that is
+// later, until ctx ends. Each send is claimed in the job store replicas
+// share, so one replica mails it, once, restarts or not; without a job
+// store none go out.
+func RunSummaryEmails(ctx context.Context) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+	for {
+		sendDueSummaries(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// sendDueSummaries mails the summaries due by now that have not gone out
+// today. One the relay refuses is given up and tried again on the next
+// pass, by whichever replica claims it.
+func sendDueSummaries(ctx context.Context, now time.Time) {
+	if jobStore == nil {
+		return
+	}
+	now = now.In(notifyPolicy.Zone)
+	date, clock := now.Format(time.DateOnly), now.Format("15:04")
+	tenantsMu.RLock()
+	var due []templates.TenantSettings
+	for _, s := range tenantSettings {
+		if s.Summary != nil && clock >= s.Summary.At {
+			due = append(due, s)
+		}
+	}
+	tenantsMu.RUnlock()
+
+	for _, s := range due {
+		b, ok := dispatched.Board(s.Tenant, date)
+		if !ok {
+			continue
+		}
+		key := "summary/" + s.Tenant + "/" + date
+		if claimed, err := jobStore.Mark(key); !claimed {
+			if err != nil {
+				log.Printf("Claiming %q's plan summary for %s: %v", s.Tenant, date, err)
+			}
+			continue
+		}
+		if err := mailSummary(ctx, b, s); err != nil {
+			log.Printf("Mailing %q its plan summary for %s: %v", s.Tenant, date, err)
+			if err := jobStore.Unmark(key); err != nil {
+				log.Printf("Giving up %q's plan summary for %s: %v", s.Tenant, date, err)
+			}
+		}
+	}
+}
+
+// mailSummary mails b's summary to s's distribution list
+func mailSummary(ctx context.Context, b dispatch.Board, s templates.TenantSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	sum := planSummary(b, s)
+	msg := email.Message{
+		To:      s.Summary.Recipients,
+		Subject: "Dispatch plan for " + b.Date,
+		Text:    sum.text(),
+	}
+	formats := s.Summary.Formats
+	if len(formats) == 0 {
+		formats = []string{templates.SummaryPDF, templates.SummaryXLSX}
+	}
+	for _, f := range formats {
+		var buf bytes.Buffer
+		contentType, err := sum.write(&buf, f)
+		if err != nil {
+			return err
+		}
+		msg.Attachments = append(msg.Attachments, email.Attachment{Name: "plan-" + b.Date + "." + f, ContentType: contentType, Data: buf.Bytes()})
+	}
+	if err := mailer.Send(ctx, msg); err != nil {
+		summariesFailed.Inc()
+		return err
+	}
+	summariesSent.Inc()
+	return nil
+}
+
+// summary is a published plan laid out for people: per vehicle, how full
+// it runs and what it costs, then each run's stops
+type summary struct {
+	board     dispatch.Board
+	costPerKm float64
+	stops     int
+	distance  float64 // km
+	cost      float64
+	vehicles  summaryTable
+	runs      []summaryTable
+}
+
+type summaryTable struct {
+	heading string
+	header  []string
+	rows    [][]any
+}
+
+// planSummary summarizes b for tenant s: capacities come from the vehicles
+// it announces on the message broker, costs from its cost per km
+func planSummary(b dispatch.Board, s templates.TenantSettings) summary {
+	sum := summary{board: b, costPerKm: cmp.Or(s.CostPerKm, toll.DefaultCostPerKm)}
+	sum.vehicles = summaryTable{
+		heading: "Vehicles",
+		header:  []string{"Vehicle", "Status", "Stops", "Completed", "Distance (km)", "Load (kg)", "Capacity (kg)", "Utilization (%)", "Cost"},
+	}
+	day, _ := time.ParseInLocation(time.DateOnly, b.Date, notifyPolicy.Zone)
+	completed, load := 0, 0.0
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, run := range b.Runs {
+		done := 0
+		for _, st := range run.Stops {
+			if st.Status == dispatch.Completed {
+				done++
+			}
+		}
+		var capacity, util any
+		if v, ok := vehicleRegistry[registryKey{s.Tenant, run.VehicleID}]; ok && v.CapacityKg > 0 {
+			capacity, util = v.CapacityKg, round2(run.LoadKg/v.CapacityKg*100)
+		}
+		c := round2(run.DistanceKm * sum.costPerKm)
+		sum.vehicles.rows = append(sum.vehicles.rows, []any{run.VehicleID, string(run.Status), len(run.Stops), done, round2(run.DistanceKm), round2(run.LoadKg), capacity, util, c})
+		sum.stops += len(run.Stops)
+		completed += done
+		sum.distance += run.DistanceKm
+		load += run.LoadKg
+		sum.cost += c
+
+		t := summaryTable{heading: run.VehicleID, header: []string{"#", "Stop", "Customer", "City", "ETA", "Status"}}
+		for i, st := range run.Stops {
+			var eta any
+			if st.ETAHours > 0 {
+				eta = day.Add(notifyPolicy.DayStart + time.Duration(st.ETAHours*float64(time.Hour))).Format("15:04")
+			}
+			t.rows = append(t.rows, []any{i + 1, st.ID, orEmpty(st.Customer), orEmpty(st.City), eta, string(st.Status)})
+		}
+		sum.runs = append(sum.runs, t)
+	}
+	sum.distance, sum.cost = round2(sum.distance), round2(sum.cost)
+	sum.vehicles.rows = append(sum.vehicles.rows, []any{"Total", nil, sum.stops, completed, sum.distance, round2(load), nil, nil, sum.cost})
+	if len(b.Unassigned) > 0 {
+		sum.vehicles.rows = append(sum.vehicles.rows, []any{"Unassigned", nil, len(b.Unassigned), nil, nil, nil, nil, nil, nil})
+	}
+	return sum
+}
+
+// text is the summary's mail body
+func (sum summary) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dispatch plan for %s, version %d, published %s.\n\n", sum.board.Date, sum.board.Version, sum.board.PublishedAt.In(notifyPolicy.Zone).Format("2 Jan 15:04 MST"))
+	fmt.Fprintf(&b, "%d vehicles run %d stops over %v km, costing %v at %v per km.\n", len(sum.board.Runs), sum.stops, sum.distance, sum.cost, sum.costPerKm)
+	if len(sum.board.Unassigned) > 0 {
+		fmt.Fprintf(&b, "Unassigned: %s\n", strings.Join(sum.board.Unassigned, ", "))
+	}
+	b.WriteString("\nEach vehicle's run is attached.\n")
+	return b.String()
+}
+
+// write encodes the summary as a pdf or xlsx file, returning its media type
+func (sum summary) write(buf *bytes.Buffer, format string) (string, error) {
+	tables := append([]summaryTable{sum.vehicles}, sum.runs...)
+	if format == templates.SummaryXLSX {
+		var book xlsx.Workbook
+		for _, t := range tables {
+			book.Sheets = append(book.Sheets, xlsx.Sheet{Name: t.heading, Header: t.header, Rows: t.rows})
+		}
+		return xlsx.ContentType, book.Write(buf)
+	}
+	doc := pdf.Document{Title: "Dispatch plan for " + sum.board.Date, Created: time.Now()}
+	for _, t := range tables {
+		pt := pdf.Table{Heading: t.heading, Header: t.header}
+		for _, cells := range t.rows {
+			pt.Rows = append(pt.Rows, summaryCells(cells))
+		}
+		doc.Tables = append(doc.Tables, pt)
+	}
+	if len(sum.board.Unassigned) > 0 {
+		t := pdf.Table{Heading: "Unassigned"}
+		for ids := range slices.Chunk(sum.board.Unassigned, 8) {
+			t.Rows = append(t.Rows, ids)
+		}
+		doc.Tables = append(doc.Tables, t)
+	}
+	return pdf.ContentType, doc.Write(buf)
+}
+
+// summaryCells prints a row's cells, leaving empty ones blank
+func summaryCells(cells []any) []string {
+	out := make([]string, len(cells))
+	for i, v := range cells {
+		switch v := v.(type) {
+		case nil:
+		case float64:
+			out[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			out[i] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// DispatchSummaryHandler downloads the summary of ?date='s plan (default
+// today) as ?format=pdf (default) or xlsx on GET, and mails it to the
+// tenant's distribution list now on POST (planners and admins; admins name
+// the tenant with ?tenant=)
+func DispatchSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	date, ok := dispatchDate(w, r.URL.Query().Get("date"))
+	if !ok {
+		return
+	}
+	tenant := who.Tenant
+	if who.Role == auth.RoleAdmin && r.URL.Query().Has("tenant") {
+		tenant = r.URL.Query().Get("tenant")
+	}
+	tenantsMu.RLock()
+	settings := tenantSettings[tenant]
+	tenantsMu.RUnlock()
+	settings.Tenant = tenant
+
+	switch r.Method {
+	case http.MethodGet:
+		format := cmp.Or(r.URL.Query().Get("format"), templates.SummaryPDF)
+		if !slices.Contains([]string{templates.SummaryPDF, templates.SummaryXLSX}, format) {
+			http.Error(w, "Format must be pdf or xlsx", http.StatusBadRequest)
+			return
+		}
+		b, ok := dispatched.Board(tenant, date)
+		if !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+		var buf bytes.Buffer
+		contentType, err := planSummary(b, settings).write(&buf, format)
+		if err != nil {
+			http.Error(w, "Failed to write the summary", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="plan-`+date+`.`+format+`"`)
+		w.Write(buf.Bytes())
+
+	case http.MethodPost:
+		if who.Role == auth.RoleViewer {
+			http.Error(w, "Viewers cannot mail summaries", http.StatusForbidden)
+			return
+		}
+		if mailer == nil {
+			http.Error(w, "Mail is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		if settings.Summary == nil {
+			http.Error(w, "The tenant has no summary distribution list", http.StatusConflict)
+			return
+		}
+		b, ok := dispatched.Board(tenant, date)
+		if !ok {
+			http.Error(w, "Nothing dispatched for that date", http.StatusNotFound)
+			return
+		}
+		if err := mailSummary(r.Context(), b, settings); err != nil {
+			http.Error(w, "Mailing the summary: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		record(r, audit.Event{Kind: "summary.mailed", Tenant: tenant, Date: date}, map[string]any{"tenant": tenant, "recipients": settings.Summary.Recipients})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
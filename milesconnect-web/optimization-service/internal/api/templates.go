@@ -0,0 +1,446 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"milesconnect-optimization/internal/templates"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	templatesMu    sync.RWMutex
+	routeTemplates = map[string]templates.Template{}
+	standingOrders = map[string]templates.StandingOrder{}
+	templateDir    string
+)
+
+// LoadTemplates registers every route template and standing order in dir;
+// ones saved through the API are written there too
+func LoadTemplates(dir string) (nTemplates, nOrders int, err error) {
+	list, err := templates.Load(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	orders, err := templates.LoadOrders(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	templateDir = dir
+	for _, t := range list {
+		routeTemplates[t.Name] = t
+	}
+	for _, o := range orders {
+		standingOrders[o.ID] = o
+	}
+	return len(list), len(orders), nil
+}
+
+var templateList = listSpec[templates.Template]{
+	key: func(t templates.Template) string { return t.Name },
+	fields: map[string]listField[templates.Template]{
+		"state": {value: func(t templates.Template) string { return t.State }},
+		"updated_at": {
+			value:   func(t templates.Template) string { return t.UpdatedAt.Format(time.RFC3339) },
+			compare: func(a, b templates.Template) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+		},
+	},
+}
+
+var standingOrderList = listSpec[templates.StandingOrder]{
+	key: func(o templates.StandingOrder) string { return o.ID },
+	fields: map[string]listField[templates.StandingOrder]{
+		"template":   {value: func(o templates.StandingOrder) string { return o.Template }},
+		"start_date": {value: func(o templates.StandingOrder) string { return o.StartDate }},
+		"updated_at": {
+			value:   func(o templates.StandingOrder) string { return o.UpdatedAt.Format(time.RFC3339) },
+			compare: func(a, b templates.StandingOrder) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+		},
+	},
+}
+
+// TemplatesHandler lists route templates (GET, or one with ?name=), saves
+// one (POST) or deletes one (DELETE ?name=)
+func TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		templatesMu.RLock()
+		defer templatesMu.RUnlock()
+		if name := r.URL.Query().Get("name"); name != "" {
+			t, ok := routeTemplates[name]
+			if !ok || !who.Reads(t.Tenant) {
+				http.Error(w, "Unknown template", http.StatusNotFound)
+				return
+			}
+			writeResponse(w, r, t)
+			return
+		}
+		list := []templates.Template{}
+		for _, t := range routeTemplates {
+			if who.Reads(t.Tenant) {
+				list = append(list, t)
+			}
+		}
+		writeList(w, r, list, templateList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var t templates.Template
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := resolveTemplate(&t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := t.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Every day's instance must be a valid fleet request
+		for day := 0; day < 7; day++ {
+			req, _, _, _ := t.Instantiate(time.Date(2024, 1, 7+day, 0, 0, 0, 0, time.UTC), nil)
+			if err := validateFleetRequest(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		t.Tenant = recordTenant(who, t.Tenant)
+		if !writable(w, who, t.Tenant) {
+			return
+		}
+		t.UpdatedAt = time.Now().UTC()
+
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		if old, ok := routeTemplates[t.Name]; ok && old.Tenant != t.Tenant {
+			http.Error(w, "Template name "+t.Name+" is taken", http.StatusConflict)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.Save(templateDir, t); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		routeTemplates[t.Name] = t
+		record(r, audit.Event{Kind: "template.saved", Tenant: t.Tenant}, t)
+		writeResponse(w, r, t)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		t, ok := routeTemplates[name]
+		if !ok || !who.Reads(t.Tenant) {
+			http.Error(w, "Unknown template", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, t.Tenant) {
+			return
+		}
+		if err := moveToTrash(trashTemplate, name, t.Tenant, t); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.Delete(templateDir, name); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		delete(routeTemplates, name)
+		record(r, audit.Event{Kind: "template.deleted", Tenant: t.Tenant}, map[string]string{"name": name})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// StandingOrdersHandler lists standing orders (GET, filtered to those due on
+// ?date=), saves one (POST) or deletes one (DELETE ?id=)
+func StandingOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		var date time.Time
+		if v := q.Get("date"); v != "" {
+			var err error
+			if date, err = time.Parse(time.DateOnly, v); err != nil {
+				http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+		}
+
+		templatesMu.RLock()
+		list := []templates.StandingOrder{}
+		for _, o := range standingOrders {
+			if !who.Reads(o.Tenant) {
+				continue
+			}
+			if !date.IsZero() {
+				if rule, err := o.Rule(); err != nil || !rule.Occurs(date) {
+					continue
+				}
+			}
+			list = append(list, o)
+		}
+		templatesMu.RUnlock()
+		writeList(w, r, list, standingOrderList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var o templates.StandingOrder
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := o.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o.Stop.ID = o.ID
+		if err := resolveLocation(&o.Stop.Location); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateFleetStop(o.Stop); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o.Tenant = recordTenant(who, o.Tenant)
+		if !writable(w, who, o.Tenant) {
+			return
+		}
+		o.UpdatedAt = time.Now().UTC()
+
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		if old, ok := standingOrders[o.ID]; ok && old.Tenant != o.Tenant {
+			http.Error(w, "Standing order ID "+o.ID+" is taken", http.StatusConflict)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.SaveOrder(templateDir, o); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		standingOrders[o.ID] = o
+		record(r, audit.Event{Kind: "standing_order.saved", Tenant: o.Tenant, Shipments: []string{o.ID}}, o)
+		writeResponse(w, r, o)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		o, ok := standingOrders[id]
+		if !ok || !who.Reads(o.Tenant) {
+			http.Error(w, "Unknown standing order", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, o.Tenant) {
+			return
+		}
+		if err := moveToTrash(trashStandingOrder, id, o.Tenant, o); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if templateDir != "" {
+			if err := templates.DeleteOrder(templateDir, id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		delete(standingOrders, id)
+		record(r, audit.Event{Kind: "standing_order.deleted", Tenant: o.Tenant, Shipments: []string{id}}, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// InstantiateTemplateHandler plans a date from a template: the template's
+// routes for that weekday stay as they are and only the day's standing
+// orders and extra stops are routed, into those routes or onto idle
+// vehicles
+func InstantiateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+
+	limitBody(w, r)
+	var req models.TemplateInstanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		http.Error(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	templatesMu.RLock()
+	t, ok := routeTemplates[req.Template]
+	orders := make([]templates.StandingOrder, 0, len(standingOrders))
+	for _, o := range standingOrders {
+		if o.Tenant == t.Tenant {
+			orders = append(orders, o)
+		}
+	}
+	templatesMu.RUnlock()
+	if !ok || !who.Reads(t.Tenant) {
+		http.Error(w, "Unknown template", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("dispatch") == "true" && !writable(w, who, t.Tenant) {
+		return
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	due, closedOrders := templates.Due(orders, t.Name, date, func(d time.Time) bool {
+		return len(holidays.Holidays(d, t.State)) > 0
+	})
+
+	fleet, fixed, closed, err := t.Instantiate(date, append(due, req.ExtraStops...))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	applyFleetDefaults(&fleet, settingsOf(t.Tenant))
+	if err := resolveFleetRequest(&fleet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateFleetRequest(fleet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var unavailable []models.Violation
+	if fleet.Vehicles, unavailable = availableVehicles(fleet.Vehicles, date); len(fleet.Vehicles) == 0 {
+		http.Error(w, "Every vehicle is down for maintenance or due for service on "+req.Date, http.StatusConflict)
+		return
+	}
+
+	p := problem.FromFleetRequest(fleet)
+	p.BorderDelays = borderDelays
+	applySpeedFactors(p, fleet.Vehicles)
+	reserveCapacity(p, fleet.Vehicles, 0)
+	index := map[string]int{} // Stop ID to node; node 0 is the depot
+	for i, s := range fleet.Stops {
+		index[s.ID] = i + 1
+	}
+	routes := make([][]int, len(p.Vehicles))
+	kept := 0
+	for vi, v := range p.Vehicles {
+		for _, id := range fixed[v.ID] {
+			routes[vi] = append(routes[vi], index[id])
+			kept++
+		}
+	}
+
+	sol := solver.ExtendPlan(r.Context(), p, routes)
+	resp := models.TemplateInstanceResponse{
+		Template:         t.Name,
+		Date:             req.Date,
+		StandingOrderIDs: []string{},
+		ClosedStopIDs:    append(closed, closedOrders...),
+		Warnings:         holidayWarnings(date, t.State),
+		FleetResponse:    sol.ToFleetResponse(p),
+	}
+	addRouteLinks(r, resp.Routes)
+	addFuelEstimates(resp.Routes, fleet.Vehicles, 0)
+	addCapacityUse(resp.Routes, fleet.Vehicles, 0)
+	for _, s := range due {
+		resp.StandingOrderIDs = append(resp.StandingOrderIDs, s.ID)
+	}
+	if resp.ClosedStopIDs == nil {
+		resp.ClosedStopIDs = []string{}
+	}
+	for _, id := range resp.ClosedStopIDs {
+		resp.Warnings = append(resp.Warnings, "stop "+id+" left out: the customer is closed on "+req.Date)
+	}
+
+	for _, v := range unavailable {
+		resp.Warnings = append(resp.Warnings, v.Message+"; its template stops were re-planned")
+	}
+	report := feasibility.Check(p, sol)
+	report.Violations = append(append(report.Violations, unavailable...), serviceWarnings(resp.Routes)...)
+	resp.Feasibility = &report
+	resp.Meta = &models.SolveMeta{
+		Solver: "template",
+		Reason: fmt.Sprintf("%d template stops kept in order; %d of %d standing orders and extra stops inserted",
+			kept, len(fleet.Stops)-kept-len(sol.Unassigned), len(fleet.Stops)-kept),
+	}
+	status := deadlineStatus(w, r, resp.Meta)
+	shipments, vehicles := fleetIDs(resp.Routes, resp.Unassigned)
+	record(r, audit.Event{Kind: "template.instantiate", Tenant: t.Tenant, Date: req.Date, Shipments: shipments, Vehicles: vehicles}, solveRecord{req, resp})
+
+	// ?dispatch=true puts the plan straight on the dispatch board, unless the
+	// deadline cut it short; replacing a published plan needs If-Match
+	if r.URL.Query().Get("dispatch") == "true" && !resp.Meta.Partial {
+		version, ok := precondition(w, r, t.Tenant, req.Date, true)
+		if !ok {
+			return
+		}
+		b, err := publishPlan(r, t.Tenant, req.Date, version, resp.Routes, resp.Unassigned, req.Shipments, req.Consignees)
+		if !dispatchError(w, err) {
+			return
+		}
+		w.Header().Set("ETag", etag(b.Version))
+	}
+
+	writeStatus(w, r, status, resp)
+}
+
+// resolveTemplate replaces pincodes with coordinates before the template is
+// stored
+func resolveTemplate(t *templates.Template) error {
+	if err := resolveLocation(&t.Depot); err != nil {
+		return err
+	}
+	for _, v := range t.Vehicles {
+		for _, loc := range []*models.Location{v.Start, v.End} {
+			if loc == nil {
+				continue
+			}
+			if err := resolveLocation(loc); err != nil {
+				return err
+			}
+		}
+	}
+	for ri := range t.Routes {
+		for si := range t.Routes[ri].Stops {
+			if err := resolveLocation(&t.Routes[ri].Stops[si].Location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
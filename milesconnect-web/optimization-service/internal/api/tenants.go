@@ -0,0 +1,226 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	tenantsMu      sync.RWMutex
+	tenantSettings = map[string]templates.TenantSettings{}
+)
+
+// LoadTenantSettings registers the tenant settings saved in dir
+func LoadTenantSettings(dir string) (int, error) {
+	list, err := templates.LoadTenantSettings(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+	for _, s := range list {
+		tenantSettings[s.Tenant] = s
+	}
+	return len(list), nil
+}
+
+var tenantSettingsList = listSpec[templates.TenantSettings]{
+	key: func(s templates.TenantSettings) string { return s.Tenant },
+	fields: map[string]listField[templates.TenantSettings]{
+		"profile": {value: func(s templates.TenantSettings) string { return s.Profile }},
+	},
+}
+
+// TenantSettingsHandler lists tenants' default settings (GET; planners and
+// viewers see their own tenant's), and lets admins save a tenant's (POST)
+// or drop them (DELETE ?tenant=)
+func TenantSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet && who.Role != auth.RoleAdmin {
+		http.Error(w, "Only admins manage tenant settings", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		tenantsMu.RLock()
+		list := []templates.TenantSettings{}
+		for _, s := range tenantSettings {
+			if who.Reads(s.Tenant) {
+				list = append(list, maskChat(s))
+			}
+		}
+		tenantsMu.RUnlock()
+		writeList(w, r, list, tenantSettingsList)
+
+	case http.MethodPost:
+		limitBody(w, r)
+		var s templates.TenantSettings
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		tenantsMu.RLock()
+		unmaskChat(&s, tenantSettings[s.Tenant])
+		tenantsMu.RUnlock()
+		if err := validateTenantSettings(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.UpdatedAt = time.Now().UTC()
+
+		tenantsMu.Lock()
+		defer tenantsMu.Unlock()
+		next := maps.Clone(tenantSettings)
+		next[s.Tenant] = s
+		if !saveTenantSettings(w, next) {
+			return
+		}
+		tenantSettings = next
+		record(r, audit.Event{Kind: "tenant.settings.saved"}, maskChat(s))
+		writeResponse(w, r, maskChat(s))
+
+	case http.MethodDelete:
+		tenant := r.URL.Query().Get("tenant")
+		tenantsMu.Lock()
+		defer tenantsMu.Unlock()
+		if _, ok := tenantSettings[tenant]; !ok {
+			http.Error(w, "No settings for that tenant", http.StatusNotFound)
+			return
+		}
+		next := maps.Clone(tenantSettings)
+		delete(next, tenant)
+		if !saveTenantSettings(w, next) {
+			return
+		}
+		tenantSettings = next
+		record(r, audit.Event{Kind: "tenant.settings.deleted"}, map[string]string{"tenant": tenant})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveTenantSettings writes settings to the template directory, when there
+// is one. Callers hold tenantsMu.
+func saveTenantSettings(w http.ResponseWriter, settings map[string]templates.TenantSettings) bool {
+	templatesMu.RLock()
+	dir := templateDir
+	templatesMu.RUnlock()
+	if dir == "" {
+		return true
+	}
+	list := slices.Collect(maps.Values(settings))
+	if err := templates.SaveTenantSettings(dir, list); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func validateTenantSettings(s templates.TenantSettings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if !finite(s.CostPerKm, s.SpeedKmph, s.FuelPricePerLitre) {
+		return errors.New("cost per km, speed and fuel price must be numbers")
+	}
+	if s.Units != nil {
+		if err := validateUnits(*s.Units); err != nil {
+			return err
+		}
+	}
+	if _, tier := slaTiers[defaultFleetSolver][s.Profile]; s.Profile != "" && !tier {
+		if _, ok := lookupProfile(s.Profile); !ok {
+			return fmt.Errorf("profile %q is neither an SLA tier nor a tuned profile", s.Profile)
+		}
+	}
+	return nil
+}
+
+// requestSettings returns the settings of the tenant the request's planner
+// token names, or the unnamed tenant's when it has none. Endpoints that do
+// not require a planner token use it only to pick defaults, so a token that
+// does not verify simply gets the unnamed tenant's.
+func requestSettings(r *http.Request) templates.TenantSettings {
+	return settingsOf(requestTenant(r))
+}
+
+// settingsOf returns tenant's settings, for records kept on a tenant's
+// behalf rather than by its own request
+func settingsOf(tenant string) templates.TenantSettings {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	return tenantSettings[tenant]
+}
+
+// requestTenant names the tenant the request's planner token speaks for,
+// or the unnamed tenant
+func requestTenant(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && len(plannerSecret) > 0 {
+		if p, err := plannerPrincipal(token); err == nil {
+			return p.Tenant
+		}
+	}
+	return ""
+}
+
+// requestProfile is the request's ?profile=, or its tenant's default
+// profile when it names neither a solver nor a profile
+func requestProfile(r *http.Request) string {
+	q := r.URL.Query()
+	if q.Has("profile") || q.Has("solver") {
+		return q.Get("profile")
+	}
+	return requestSettings(r).Profile
+}
+
+// requestUnits are the units the request is read and answered in: its
+// tenant's, or the deployment's
+func requestUnits(s templates.TenantSettings) models.Units {
+	if s.Units != nil {
+		return *s.Units
+	}
+	return units
+}
+
+// applyFleetDefaults fills the cost model fields req leaves unset from its
+// tenant's settings. Planning sessions and template instances take them
+// too; first-mile plans, scenarios and marginal costs take the fields they
+// have.
+func applyFleetDefaults(req *models.FleetRequest, s templates.TenantSettings) {
+	req.Objective = cmp.Or(req.Objective, s.Objective)
+	req.CostPerKm = cmp.Or(req.CostPerKm, s.CostPerKm)
+	req.SpeedKmph = cmp.Or(req.SpeedKmph, s.SpeedKmph)
+	req.FuelPricePerLitre = cmp.Or(req.FuelPricePerLitre, s.FuelPricePerLitre)
+}
+
+// withinTenantLimits writes a 400 when a request has more stops or vehicles
+// than its tenant allows
+func withinTenantLimits(w http.ResponseWriter, s templates.TenantSettings, stops, vehicles int) bool {
+	if s.MaxStops > 0 && stops > s.MaxStops {
+		http.Error(w, fmt.Sprintf("Your tenant plans at most %d stops per request", s.MaxStops), http.StatusBadRequest)
+		return false
+	}
+	if s.MaxVehicles > 0 && vehicles > s.MaxVehicles {
+		http.Error(w, fmt.Sprintf("Your tenant plans at most %d vehicles per request", s.MaxVehicles), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
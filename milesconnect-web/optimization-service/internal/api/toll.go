@@ -0,0 +1,125 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/data"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/toll"
+	"net/http"
+	"os"
+)
+
+// maxTollStops bounds plans whose tolls are estimated from the plaza
+// directory, which checks every plaza on every leg; larger plans need a
+// toll_matrix
+const maxTollStops = 1000
+
+// tollPlazas starts with the built-in plazas; LoadTollPlazaFile extends it
+var tollPlazas = func() *toll.Directory {
+	d, err := toll.NewDirectory(data.TollPlazaFile())
+	if err != nil {
+		panic(err) // Embedded file is fixed at build time
+	}
+	return d
+}()
+
+// LoadTollPlazaFile adds plazas from an external CSV
+// (id,name,highway,lat,lng and a rate column per class)
+func LoadTollPlazaFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := tollPlazas.Load(f); err != nil {
+		return 0, err
+	}
+	return tollPlazas.Len(), nil
+}
+
+var plazaList = listSpec[toll.Plaza]{
+	key: func(p toll.Plaza) string { return p.ID },
+	fields: map[string]listField[toll.Plaza]{
+		"highway": {value: func(p toll.Plaza) string { return p.Highway }},
+	},
+}
+
+// TollPlazasHandler lists the toll plazas tolls are estimated from
+func TollPlazasHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeList(w, r, tollPlazas.Plazas(), plazaList)
+}
+
+// tolled reports whether req's routes are priced for tolls
+func tolled(req models.FleetRequest) bool {
+	return req.Objective == "cost" || req.TollMatrix != nil
+}
+
+// priceTolls makes p's solvers weigh tolls against distance when req asks
+// to minimise cost. Solvers share one toll matrix, so it is priced for the
+// fleet's most common class.
+func priceTolls(p *problem.Problem, req models.FleetRequest) {
+	if req.Objective != "cost" {
+		return
+	}
+	p.CostPerKm = cmp.Or(req.CostPerKm, toll.DefaultCostPerKm)
+	if req.TollMatrix != nil {
+		p.Tolls = req.TollMatrix
+		return
+	}
+	count := map[string]int{}
+	class := toll.DefaultClass
+	for _, v := range req.Vehicles {
+		c := cmp.Or(v.Class, toll.DefaultClass)
+		if count[c]++; count[c] > count[class] {
+			class = c
+		}
+	}
+	locs := make([]models.Location, len(p.Nodes))
+	for i, n := range p.Nodes {
+		locs[i] = n.Location
+	}
+	p.Tolls = tollPlazas.Matrix(locs, class)
+}
+
+// addTolls sets each route's tolls at its vehicle's class, from the
+// request's toll matrix or the plazas its legs pass
+func addTolls(routes []models.FleetRoute, req models.FleetRequest) {
+	if !tolled(req) {
+		return
+	}
+	class := map[string]string{}
+	for _, v := range req.Vehicles {
+		class[v.ID] = v.Class
+	}
+	node := map[string]int{} // Stop ID to toll matrix row; row 0 is the depot
+	for i, s := range req.Stops {
+		node[cmp.Or(s.ID, fmt.Sprintf("stop-%d", i))] = i + 1
+	}
+
+	for i, r := range routes {
+		total := 0.0
+		if req.TollMatrix != nil {
+			prev := 0
+			for _, id := range r.StopIDs {
+				total += req.TollMatrix[prev][node[id]]
+				prev = node[id]
+			}
+			total += req.TollMatrix[prev][0]
+		} else {
+			for k := 1; k < len(r.Route); k++ {
+				leg, plazas := tollPlazas.Leg(r.Route[k-1], r.Route[k], class[r.VehicleID])
+				total += leg
+				routes[i].TollPlazas = append(routes[i].TollPlazas, plazas...)
+			}
+		}
+		routes[i].TollInr = math.Round(total*100) / 100
+	}
+}
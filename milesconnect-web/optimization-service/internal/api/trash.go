@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"net/http"
+	"time"
+)
+
+// Kinds of trashed record. Vehicles and shipments belong to the backend
+// API, and dispatched plans are never deleted here, so none has a kind.
+const (
+	trashTemplate      = "template"
+	trashStandingOrder = "standing_order"
+	trashMaintenance   = "maintenance"
+	trashSession       = "session"
+)
+
+var (
+	// trash holds deleted records by kind and ID, under templatesMu
+	trash = map[string]templates.Trashed{}
+
+	// trashRetention is how long deleted records can be restored;
+	// SetTrashRetention configures it
+	trashRetention = 7 * 24 * time.Hour
+)
+
+// SetTrashRetention sets how long deleted records can be restored; call
+// before serving requests
+func SetTrashRetention(d time.Duration) {
+	trashRetention = d
+}
+
+// LoadTrash registers the deleted records kept in dir, dropping those past
+// their retention
+func LoadTrash(dir string) (int, error) {
+	list, err := templates.LoadTrash(dir)
+	if err != nil {
+		return 0, err
+	}
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	for _, t := range list {
+		trash[t.Kind+"/"+t.ID] = t
+	}
+	purgeTrash(time.Now())
+	return len(trash), nil
+}
+
+var trashList = listSpec[templates.Trashed]{
+	key: func(t templates.Trashed) string { return t.Kind + "/" + t.ID },
+	fields: map[string]listField[templates.Trashed]{
+		"kind": {value: func(t templates.Trashed) string { return t.Kind }},
+		"deleted_at": {
+			value:   func(t templates.Trashed) string { return t.DeletedAt.Format(time.RFC3339) },
+			compare: func(a, b templates.Trashed) int { return a.DeletedAt.Compare(b.DeletedAt) },
+		},
+	},
+}
+
+// moveToTrash keeps a deleted record for restoring. Callers hold
+// templatesMu and have removed the live record.
+func moveToTrash(kind, id, tenant string, record any) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	t := templates.Trashed{Kind: kind, ID: id, Tenant: tenant, DeletedAt: now, PurgeAt: now.Add(trashRetention), Record: raw}
+	// Sessions live in memory only, and so does their trash
+	if templateDir != "" && kind != trashSession {
+		if err := templates.SaveTrashed(templateDir, t); err != nil {
+			return err
+		}
+	}
+	trash[kind+"/"+id] = t
+	return nil
+}
+
+// purgeTrash drops records past their retention. Callers hold templatesMu.
+func purgeTrash(now time.Time) {
+	for key, t := range trash {
+		if now.Before(t.PurgeAt) {
+			continue
+		}
+		if templateDir != "" && t.Kind != trashSession {
+			templates.DeleteTrashed(templateDir, t.Kind, t.ID)
+		}
+		delete(trash, key)
+	}
+}
+
+// TrashHandler lists deleted templates, standing orders, maintenance
+// schedules and planning sessions that can still be restored (GET), or
+// purges one now (DELETE ?kind=&id=)
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	purgeTrash(time.Now())
+
+	switch r.Method {
+	case http.MethodGet:
+		list := []templates.Trashed{}
+		for _, t := range trash {
+			if who.Reads(t.Tenant) {
+				list = append(list, t)
+			}
+		}
+		writeList(w, r, list, trashList)
+
+	case http.MethodDelete:
+		q := r.URL.Query()
+		t, ok := trash[q.Get("kind")+"/"+q.Get("id")]
+		if !ok || !who.Reads(t.Tenant) {
+			http.Error(w, "Nothing by that kind and ID in the trash", http.StatusNotFound)
+			return
+		}
+		if !writable(w, who, t.Tenant) {
+			return
+		}
+		if templateDir != "" && t.Kind != trashSession {
+			if err := templates.DeleteTrashed(templateDir, t.Kind, t.ID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		delete(trash, t.Kind+"/"+t.ID)
+		record(r, audit.Event{Kind: "trash.purged"}, map[string]string{"kind": t.Kind, "id": t.ID})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TrashRestoreHandler puts a deleted record back (POST ?kind=&id=), as it
+// was when deleted
+func TrashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	purgeTrash(time.Now())
+	t, ok := trash[q.Get("kind")+"/"+q.Get("id")]
+	if !ok || !who.Reads(t.Tenant) {
+		http.Error(w, "Nothing by that kind and ID in the trash", http.StatusNotFound)
+		return
+	}
+	if !writable(w, who, t.Tenant) {
+		return
+	}
+
+	restored, err := restoreTrashed(t)
+	switch {
+	case errors.Is(err, errRestoreTaken):
+		http.Error(w, "A "+t.Kind+" named "+t.ID+" exists again; delete or rename it first", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if templateDir != "" && t.Kind != trashSession {
+		templates.DeleteTrashed(templateDir, t.Kind, t.ID)
+	}
+	delete(trash, t.Kind+"/"+t.ID)
+	record(r, audit.Event{Kind: "trash.restored"}, map[string]string{"kind": t.Kind, "id": t.ID})
+	writeResponse(w, r, restored)
+}
+
+// errRestoreTaken is a restore whose name was reused since the delete
+var errRestoreTaken = errors.New("name taken")
+
+// restoreTrashed puts t's record back in its registry and on disk and
+// returns it. Callers hold templatesMu.
+func restoreTrashed(t templates.Trashed) (any, error) {
+	switch t.Kind {
+	case trashTemplate:
+		return restoreInto(t, routeTemplates, templates.Save)
+	case trashStandingOrder:
+		return restoreInto(t, standingOrders, templates.SaveOrder)
+	case trashMaintenance:
+		return restoreInto(t, maintenance, templates.SaveMaintenance)
+	}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	if _, ok := planningSessions[t.ID]; ok {
+		return nil, errRestoreTaken
+	}
+	var s models.PlanningSession
+	if err := json.Unmarshal(t.Record, &s); err != nil {
+		return nil, err
+	}
+	planningSessions[t.ID] = &s
+	return session(&s), nil
+}
+
+func restoreInto[T any](t templates.Trashed, registry map[string]T, save func(string, T) error) (any, error) {
+	if _, ok := registry[t.ID]; ok {
+		return nil, errRestoreTaken
+	}
+	var v T
+	if err := json.Unmarshal(t.Record, &v); err != nil {
+		return nil, err
+	}
+	if templateDir != "" {
+		if err := save(templateDir, v); err != nil {
+			return nil, err
+		}
+	}
+	registry[t.ID] = v
+	return v, nil
+}
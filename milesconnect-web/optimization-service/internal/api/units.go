@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/models"
+)
+
+const (
+	kmPerMile = 1.609344
+	kgPerLb   = 0.45359237
+)
+
+// units are the deployment's currency and measures; SetUnits configures
+// them. Plans are solved in km and kg, so requests are converted on the
+// way in and responses on the way out.
+var units = models.Units{Currency: "INR", Distance: "km", Weight: "kg"}
+
+// SetUnits sets the currency and measures requests and responses use; call
+// before serving requests
+func SetUnits(u models.Units) error {
+	if err := validateUnits(u); err != nil {
+		return err
+	}
+	units = u
+	return nil
+}
+
+func validateUnits(u models.Units) error {
+	if len(u.Currency) != 3 {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	if u.Distance != "km" && u.Distance != "mi" {
+		return errors.New("distance unit must be km or mi")
+	}
+	if u.Weight != "kg" && u.Weight != "lb" {
+		return errors.New("weight unit must be kg or lb")
+	}
+	return nil
+}
+
+// unitScales returns the km in u's distance unit and the kg in its weight
+// unit
+func unitScales(u models.Units) (km, kg float64) {
+	km, kg = 1, 1
+	if u.Distance == "mi" {
+		km = kmPerMile
+	}
+	if u.Weight == "lb" {
+		kg = kgPerLb
+	}
+	return km, kg
+}
+
+// routeToMetric converts req's distance matrix from units u to km
+func routeToMetric(req *models.OptimizationRequest, u models.Units) {
+	km, _ := unitScales(u)
+	for _, row := range req.DistanceMatrix {
+		for j := range row {
+			row[j] *= km
+		}
+	}
+}
+
+// loadToMetric converts req from units u to kg, like fleetToMetric
+func loadToMetric(req *models.LoadRequest, u models.Units) {
+	km, kg := unitScales(u)
+	vehiclesToMetric(req.Vehicles, km, kg)
+	for i := range req.Shipments {
+		req.Shipments[i].WeightKg *= kg
+	}
+}
+
+// fleetToMetric converts req from units u, the deployment's or its
+// tenant's, to the km and kg plans are solved in. Currency is not
+// converted: rates are taken to be in u's currency throughout.
+func fleetToMetric(req *models.FleetRequest, u models.Units) {
+	km, kg := unitScales(u)
+	if km == 1 && kg == 1 {
+		return
+	}
+	req.SpeedKmph *= km
+	req.CostPerKm /= km
+	for _, row := range req.DistanceMatrix {
+		for j := range row {
+			row[j] *= km
+		}
+	}
+	for i := range req.Curfews {
+		req.Curfews[i].RadiusKm *= km
+	}
+	vehiclesToMetric(req.Vehicles, km, kg)
+	stopsToMetric(req.Stops, kg)
+	for i := range req.Carriers {
+		c := &req.Carriers[i]
+		c.PerKgInr /= kg
+		c.PerKmInr /= km
+		c.MaxKg *= kg
+	}
+}
+
+// firstMileToMetric converts req like fleetToMetric
+func firstMileToMetric(req *models.FirstMileRequest, u models.Units) {
+	km, kg := unitScales(u)
+	req.SpeedKmph *= km
+	for _, h := range req.Hubs {
+		vehiclesToMetric(h.Vehicles, km, kg)
+	}
+	stopsToMetric(req.Pickups, kg)
+}
+
+func vehiclesToMetric(vehicles []models.VehicleInfo, km, kg float64) {
+	for i := range vehicles {
+		v := &vehicles[i]
+		v.CapacityKg *= kg
+		v.CurrentLoad *= kg
+		v.KmPerLitre *= km
+	}
+}
+
+func stopsToMetric(stops []models.FleetStop, kg float64) {
+	for i := range stops {
+		stops[i].DemandKg *= kg
+		stops[i].ReturnKg *= kg
+	}
+}
+
+// fleetFromMetric converts resp's distances and weights to units u
+func fleetFromMetric(resp *models.FleetResponse, u models.Units) {
+	km, kg := unitScales(u)
+	if km == 1 && kg == 1 {
+		return
+	}
+	dist := func(x float64) float64 { return math.Round(x/km*100) / 100 }
+	weight := func(x float64) float64 { return math.Round(x/kg*100) / 100 }
+
+	resp.TotalDistKm = dist(resp.TotalDistKm)
+	for i := range resp.Routes {
+		r := &resp.Routes[i]
+		r.DistanceKm = dist(r.DistanceKm)
+		r.LoadKg, r.ReturnKg, r.PeakLoadKg = weight(r.LoadKg), weight(r.ReturnKg), weight(r.PeakLoadKg)
+		if r.Fuel != nil {
+			r.Fuel.KmPerLitre = dist(r.Fuel.KmPerLitre)
+		}
+		if c := r.Capacity; c != nil {
+			c.CapacityKg, c.ReservedKg = weight(c.CapacityKg), weight(c.ReservedKg)
+			c.UsedKg, c.FreeKg = weight(c.UsedKg), weight(c.FreeKg)
+		}
+	}
+	for i := range resp.Crews {
+		resp.Crews[i].DistanceKm = dist(resp.Crews[i].DistanceKm)
+	}
+}
+
+// routeFromMetric converts resp's distance to units u
+func routeFromMetric(resp *models.OptimizationResponse, u models.Units) {
+	km, _ := unitScales(u)
+	if km != 1 {
+		resp.TotalDistKm = math.Round(resp.TotalDistKm/km*100) / 100
+	}
+}
+
+// routeV2FromMetric converts resp's distances to units u
+func routeV2FromMetric(resp *models.OptimizationResponseV2, u models.Units) {
+	km, _ := unitScales(u)
+	if km == 1 {
+		return
+	}
+	dist := func(x float64) float64 { return math.Round(x/km*100) / 100 }
+	resp.TotalDistKm = dist(resp.TotalDistKm)
+	for i := range resp.Stops {
+		resp.Stops[i].CumulativeKm = dist(resp.Stops[i].CumulativeKm)
+	}
+	for i := range resp.Legs {
+		resp.Legs[i].DistanceKm = dist(resp.Legs[i].DistanceKm)
+	}
+}
+
+// loadFromMetric converts resp's weights to units u
+func loadFromMetric(resp *models.LoadResponse, u models.Units) {
+	_, kg := unitScales(u)
+	if kg == 1 {
+		return
+	}
+	weight := func(x float64) float64 { return math.Round(x/kg*100) / 100 }
+	for i := range resp.Allocations {
+		a := &resp.Allocations[i]
+		a.TotalWeight = weight(a.TotalWeight)
+		for j := range a.Reasons {
+			rs := &a.Reasons[j]
+			rs.FreeKg = weight(rs.FreeKg)
+			for k := range rs.Alternatives {
+				alt := &rs.Alternatives[k]
+				alt.OverKg, alt.FreeKg = weight(alt.OverKg), weight(alt.FreeKg)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"log"
+	"milesconnect-optimization/internal/auth"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/usage"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// usageMeter sums each tenant's solves; OpenUsageLog backs it with a file
+var usageMeter, _ = usage.Open("")
+
+var quotaRejected = metrics.NewCounter("quota_rejected_total", "Solves refused because the tenant's monthly quota was used up")
+
+// OpenUsageLog loads the usage log at path and appends to it from now on,
+// returning how many tenants have solved this month
+func OpenUsageLog(path string) (int, error) {
+	m, err := usage.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	usageMeter = m
+	return len(m.Report(usage.Month(time.Now()))), nil
+}
+
+// withinQuota writes a 429 when tenant has made its monthly optimization
+// requests, or a 402 when its solvers have run their monthly seconds
+func withinQuota(w http.ResponseWriter, tenant string) bool {
+	tenantsMu.RLock()
+	s := tenantSettings[tenant]
+	tenantsMu.RUnlock()
+	if s.MonthlyRequests == 0 && s.MonthlySolverSeconds == 0 {
+		return true
+	}
+
+	now := time.Now()
+	used := usageMeter.Used(tenant, usage.Month(now))
+	retry := strconv.Itoa(int(usage.NextMonth(now).Sub(now).Seconds()) + 1)
+	if s.MonthlyRequests > 0 && used.Requests >= s.MonthlyRequests {
+		quotaRejected.Inc()
+		w.Header().Set("Retry-After", retry)
+		http.Error(w, "Your tenant has made its "+strconv.Itoa(s.MonthlyRequests)+" optimization requests this month", http.StatusTooManyRequests)
+		return false
+	}
+	if s.MonthlySolverSeconds > 0 && used.SolverSeconds >= s.MonthlySolverSeconds {
+		quotaRejected.Inc()
+		w.Header().Set("Retry-After", retry)
+		http.Error(w, "Your tenant has used its monthly solver time; raise its quota or wait for next month", http.StatusPaymentRequired)
+		return false
+	}
+	return true
+}
+
+// meter records a solve of tenant's request that took cpu of CPU time
+func meter(r *http.Request, tenant string, cpu time.Duration) {
+	err := usageMeter.Record(usage.Solve{
+		Tenant:        tenant,
+		At:            time.Now(),
+		Endpoint:      routePath(r.URL.Path),
+		SolverSeconds: cpu.Seconds(),
+	})
+	if err != nil {
+		log.Printf("usage: recording %s: %v", r.URL.Path, err)
+	}
+}
+
+var usageList = listSpec[models.TenantUsage]{
+	key: func(u models.TenantUsage) string { return u.Tenant },
+}
+
+// UsageHandler reports each tenant's optimization requests and solver
+// seconds for ?month= (YYYY-MM, default this month) beside its quotas.
+// Admins see every tenant; planners and viewers their own.
+func UsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	who, ok := principal(w, r)
+	if !ok {
+		return
+	}
+	month := usage.Month(time.Now())
+	if v := r.URL.Query().Get("month"); v != "" {
+		if _, err := time.Parse("2006-01", v); err != nil {
+			http.Error(w, "month must be YYYY-MM", http.StatusBadRequest)
+			return
+		}
+		month = v
+	}
+
+	var totals []usage.Totals
+	if who.Role == auth.RoleAdmin {
+		totals = usageMeter.Report(month)
+	} else {
+		totals = []usage.Totals{usageMeter.Used(who.Tenant, month)}
+	}
+	tenantsMu.RLock()
+	list := []models.TenantUsage{}
+	for _, t := range totals {
+		s := tenantSettings[t.Tenant]
+		list = append(list, models.TenantUsage{
+			Tenant:               t.Tenant,
+			Month:                t.Month,
+			Requests:             t.Requests,
+			SolverSeconds:        t.SolverSeconds,
+			MonthlyRequests:      s.MonthlyRequests,
+			MonthlySolverSeconds: s.MonthlySolverSeconds,
+		})
+	}
+	tenantsMu.RUnlock()
+	writeList(w, r, list, usageList)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/audit"
+	"milesconnect-optimization/internal/feasibility"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+)
+
+// OptimizeRouteV2Handler is /v2/optimize: the same solve as v1, answered
+// with named stops and per-leg distances and times instead of a bare list of
+// coordinates
+func OptimizeRouteV2Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limitBody(w, r)
+	var req models.OptimizationRequestV2
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Node i of the problem is points[i]: start, waypoints..., end
+	points := append(append([]models.RoutePoint{req.Start}, req.Waypoints...), req.End)
+	v1 := models.OptimizationRequest{Start: req.Start.Location, End: req.End.Location, DistanceMatrix: req.DistanceMatrix}
+	for _, wp := range req.Waypoints {
+		v1.Waypoints = append(v1.Waypoints, wp.Location)
+	}
+
+	p, sol, meta, ok := solveRoute(w, r, &v1)
+	if !ok {
+		return
+	}
+	report := feasibility.Check(p, sol)
+	resp := models.OptimizationResponseV2{
+		APIVersion:  "2",
+		Stops:       []models.RouteStop{},
+		Legs:        []models.RouteLegV2{},
+		TotalDistKm: sol.DistanceKm,
+		Feasibility: &report,
+		Meta:        meta,
+	}
+	if len(sol.Routes) > 0 {
+		cumulative := 0.0
+		for seq, node := range sol.Routes[0].Stops {
+			if seq > 0 {
+				prev := sol.Routes[0].Stops[seq-1]
+				leg := models.RouteLegV2{FromSeq: seq - 1, ToSeq: seq, DistanceKm: p.Distance(prev, node), Hours: p.TravelHours(prev, node)}
+				cumulative += leg.DistanceKm
+				resp.TotalHours += leg.Hours
+				resp.Legs = append(resp.Legs, leg)
+			}
+			stop := models.RouteStop{
+				Seq:          seq,
+				ID:           points[node].ID,
+				Name:         points[node].Name,
+				Location:     p.Nodes[node].Location, // Resolved from a pincode if need be
+				CumulativeKm: cumulative,
+			}
+			switch node {
+			case 0:
+				stop.Kind = "start"
+			case len(points) - 1:
+				stop.Kind = "end"
+			default:
+				stop.Kind = "waypoint"
+				idx := node - 1
+				stop.WaypointIndex = &idx
+			}
+			resp.Stops = append(resp.Stops, stop)
+		}
+	}
+
+	path := make([]models.Location, len(resp.Stops))
+	for i, st := range resp.Stops {
+		path[i] = st.Location
+	}
+	resp.Polyline = routePolyline(r, path)
+
+	status := deadlineStatus(w, r, resp.Meta)
+	record(r, audit.Event{Kind: "optimize.route"}, solveRecord{req, resp})
+
+	u := requestUnits(requestSettings(r))
+	routeV2FromMetric(&resp, u)
+	resp.Units = &u
+	writeStatus(w, r, status, resp)
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/calendar"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/toll"
+	"net/http"
+	"slices"
+)
+
+// Request size limits keep a single call from exhausting the solver
+const (
+	maxBodyBytes = 8 << 20
+	maxWaypoints = 5000
+	maxVehicles  = 1000
+	maxShipments = 20000
+)
+
+// limitBody caps how much of the request body the decoder will read.
+// Background jobs were checked when queued, and may have read their body
+// from object storage, so they get that limit instead.
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	limit := int64(maxBodyBytes)
+	if runningJob(r.Context()) {
+		limit = maxObjectBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+}
+
+func finite(vals ...float64) bool {
+	for _, v := range vals {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func validLocation(l models.Location) bool {
+	return finite(l.Lat, l.Lng) && l.Lat >= -90 && l.Lat <= 90 && l.Lng >= -180 && l.Lng <= 180
+}
+
+func validateRouteRequest(req models.OptimizationRequest) error {
+	if len(req.Waypoints) > maxWaypoints {
+		return errors.New("Too many waypoints")
+	}
+	if !validLocation(req.Start) || !validLocation(req.End) {
+		return errors.New("Start and end must be valid coordinates")
+	}
+	for _, wp := range req.Waypoints {
+		if !validLocation(wp) {
+			return errors.New("Waypoints must be valid coordinates")
+		}
+	}
+
+	if req.DistanceMatrix != nil {
+		if !validMatrix(req.DistanceMatrix, len(req.Waypoints)+2) {
+			return errors.New("Distance matrix must be square over start, waypoints and end")
+		}
+		for _, row := range req.DistanceMatrix {
+			for _, d := range row {
+				if !finite(d) || d < 0 {
+					return errors.New("Distance matrix entries must be non-negative")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateLoadRequest(req models.LoadRequest) error {
+	if len(req.Vehicles) > maxVehicles || len(req.Shipments) > maxShipments {
+		return errors.New("Too many vehicles or shipments")
+	}
+	for _, v := range req.Vehicles {
+		if !finite(v.CapacityKg, v.CurrentLoad, v.DepartHours) || v.CapacityKg <= 0 || v.CurrentLoad < 0 {
+			return errors.New("Vehicle capacity must be positive and current load non-negative")
+		}
+	}
+
+	// Validation: Ensure valid weights
+	for _, s := range req.Shipments {
+		if !finite(s.WeightKg, s.DeadlineHours, s.LatePenaltyPerHour, s.DropPenalty) || s.WeightKg <= 0 {
+			return errors.New("Shipment weight must be positive")
+		}
+		if s.LatePenaltyPerHour < 0 || s.DropPenalty < 0 {
+			return errors.New("Shipment penalties must not be negative")
+		}
+	}
+	return nil
+}
+
+func validateFleetRequest(req models.FleetRequest) error {
+	if len(req.Vehicles) == 0 || len(req.Vehicles) > maxVehicles {
+		return errors.New("Between 1 and 1000 vehicles are required")
+	}
+	if len(req.Stops) > maxWaypoints {
+		return errors.New("Too many stops")
+	}
+	if !validLocation(req.Depot) {
+		return errors.New("Depot must be valid coordinates")
+	}
+	states := []string{req.Depot.State}
+	if !finite(req.SpeedKmph, req.FuelPricePerLitre) || req.SpeedKmph < 0 || req.FuelPricePerLitre < 0 {
+		return errors.New("Speed and fuel price must not be negative")
+	}
+	for _, v := range req.Vehicles {
+		if !finite(v.CapacityKg, v.CurrentLoad, v.DepartHours) || v.CapacityKg <= 0 || v.CurrentLoad < 0 {
+			return errors.New("Vehicle capacity must be positive and current load non-negative")
+		}
+		if !finite(v.KmPerLitre) || v.KmPerLitre < 0 {
+			return errors.New("Vehicle km_per_litre must not be negative")
+		}
+		if v.Class != "" && !slices.Contains(toll.Classes, v.Class) {
+			return errors.New("Vehicle class must be lcv, 2-axle, 3-axle or 4-axle")
+		}
+		if _, ok := speedFactors[v.Type]; v.Type != "" && !ok {
+			return errors.New("Unknown vehicle type " + v.Type)
+		}
+		if !finite(v.SpeedFactor) || v.SpeedFactor < 0 || v.SpeedFactor > 2 {
+			return errors.New("Vehicle speed_factor must be between 0 and 2")
+		}
+		if _, ok := crews[v.Crew]; v.Crew != "" && !ok {
+			return errors.New("Vehicle crew must be single or relay")
+		}
+		if !finite(v.ReservePct) || v.ReservePct < 0 || v.ReservePct >= 100 {
+			return errors.New("Vehicle reserve_pct must be at least 0 and below 100")
+		}
+		for _, loc := range []*models.Location{v.Start, v.End} {
+			if loc == nil {
+				continue
+			}
+			if !validLocation(*loc) {
+				return errors.New("Vehicle start and end must be valid coordinates")
+			}
+			states = append(states, loc.State)
+			if req.DistanceMatrix != nil || req.TollMatrix != nil {
+				return errors.New("Vehicle start and end locations cannot be combined with a distance or toll matrix")
+			}
+		}
+	}
+	for _, s := range req.Stops {
+		if err := validateFleetStop(s); err != nil {
+			return err
+		}
+		states = append(states, s.Location.State)
+	}
+	for _, st := range states {
+		if !calendar.ValidState(st) {
+			return errors.New("Location states must be two-letter state codes")
+		}
+	}
+
+	if req.DistanceMatrix != nil {
+		if !validMatrix(req.DistanceMatrix, len(req.Stops)+1) {
+			return errors.New("Distance matrix must be square over depot and stops")
+		}
+		for _, row := range req.DistanceMatrix {
+			for _, d := range row {
+				if !finite(d) || d < 0 {
+					return errors.New("Distance matrix entries must be non-negative")
+				}
+			}
+		}
+	}
+
+	switch req.Objective {
+	case "", "distance", "cost":
+	default:
+		return errors.New("Objective must be distance or cost")
+	}
+	if !finite(req.CostPerKm) || req.CostPerKm < 0 {
+		return errors.New("Cost per km must not be negative")
+	}
+	if req.TollMatrix != nil {
+		if !validMatrix(req.TollMatrix, len(req.Stops)+1) {
+			return errors.New("Toll matrix must be square over depot and stops")
+		}
+		for _, row := range req.TollMatrix {
+			for _, t := range row {
+				if !finite(t) || t < 0 {
+					return errors.New("Toll matrix entries must be non-negative")
+				}
+			}
+		}
+	} else if req.Objective == "cost" && len(req.Stops) > maxTollStops {
+		return errors.New("Costing tolls from toll plazas is limited to 1000 stops; send a toll_matrix")
+	}
+	if !finite(req.ReservePct) || req.ReservePct < 0 || req.ReservePct >= 100 {
+		return errors.New("Reserve percentage must be at least 0 and below 100")
+	}
+	if !finite(req.NoShowRate) || req.NoShowRate < 0 || req.NoShowRate > maxNoShowRate {
+		return errors.New("No-show rate must be between 0 and 0.5")
+	}
+	if _, ok := crews[req.Crew]; req.Crew != "" && !ok {
+		return errors.New("Crew must be single or relay")
+	}
+	if err := validateCarriers(req.Carriers); err != nil {
+		return err
+	}
+	return validateCurfews(req.Curfews)
+}
+
+func validateFleetStop(s models.FleetStop) error {
+	if !validLocation(s.Location) {
+		return errors.New("Stops must be valid coordinates")
+	}
+	if !finite(s.DemandKg, s.ReadyHours, s.DueHours, s.ServiceHours) || s.DemandKg < 0 || s.ReadyHours < 0 || s.ServiceHours < 0 {
+		return errors.New("Stop demand and times must not be negative")
+	}
+	if !finite(s.ReturnKg) || s.ReturnKg < 0 {
+		return errors.New("Stop return_kg must not be negative")
+	}
+	if !finite(s.NoShowRate) || s.NoShowRate < 0 || s.NoShowRate > maxNoShowRate {
+		return errors.New("Stop no_show_rate must be between 0 and 0.5")
+	}
+	if s.DueHours != 0 && s.DueHours < s.ReadyHours {
+		return errors.New("Stop due time must not be before its ready time")
+	}
+	return nil
+}
+
+// validMatrix checks m is n x n
+func validMatrix(m [][]float64, n int) bool {
+	if len(m) != n {
+		return false
+	}
+	for _, row := range m {
+		if len(row) != n {
+			return false
+		}
+	}
+	return true
+}
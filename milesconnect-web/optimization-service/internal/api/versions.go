@@ -0,0 +1,49 @@
+package api
+
+import (
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Versioning: every API route is served under /v1/, whose request and
+// response shapes only ever gain optional fields. A change that would break
+// a v1 client (renamed fields, new required fields, restructured responses)
+// ships as the same route under /v2/ while v1 keeps its shape, so /v2/ holds
+// only the routes that changed. Superseded routes are wrapped in Deprecation
+// and announce their successor until their sunset date.
+
+var legacyRequests = metrics.NewCounter("deprecated_requests_total", "Requests to deprecated routes, e.g. unversioned paths")
+
+// Deprecation describes when a route was superseded and when it goes away
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time // Zero when no removal date is set
+}
+
+// Wrap serves h with Deprecation (RFC 9745), Sunset (RFC 8594) and a Link to
+// the successor route
+func (d Deprecation) Wrap(successor string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		legacyRequests.Inc()
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+		if !d.Sunset.IsZero() {
+			w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// routePath strips the version prefix so per-route settings such as
+// timeouts apply to every version of a route
+func routePath(path string) string {
+	for _, prefix := range []string{"/v1/", "/v2/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			return "/" + rest
+		}
+	}
+	return path
+}
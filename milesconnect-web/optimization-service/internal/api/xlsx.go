@@ -0,0 +1,179 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/jobs"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/toll"
+	"milesconnect-optimization/internal/xlsx"
+	"net/http"
+	"strings"
+)
+
+// writeJobWorkbook downloads a finished load allocation or fleet routing
+// job as an Excel workbook: a summary sheet with each vehicle's utilization
+// and cost, then a sheet per vehicle with its shipments or stops in order
+func writeJobWorkbook(w http.ResponseWriter, j jobs.Job) {
+	switch {
+	case j.Status != jobs.Done || j.Code >= 400:
+		http.Error(w, "Only jobs that finished successfully export to Excel", http.StatusConflict)
+		return
+	case len(j.Result) == 0:
+		http.Error(w, "The job's result was written to object storage", http.StatusConflict)
+		return
+	}
+	var book xlsx.Workbook
+	var err error
+	switch j.Path {
+	case "/optimize-load":
+		var req models.LoadRequest
+		var resp models.LoadResponse
+		if err = json.Unmarshal(j.Result, &resp); err == nil && len(j.Body) > 0 {
+			err = json.Unmarshal(j.Body, &req)
+		}
+		book = loadWorkbook(req, resp)
+	case "/optimize-fleet":
+		var req models.FleetRequest
+		var resp models.FleetResponse
+		if err = json.Unmarshal(j.Result, &resp); err == nil && len(j.Body) > 0 {
+			err = json.Unmarshal(j.Body, &req)
+		}
+		book = fleetWorkbook(req, resp)
+	default:
+		http.Error(w, "Only /optimize-load and /optimize-fleet results export to Excel", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read the job's result", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", xlsx.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="job-%s.xlsx"`, j.ID))
+	book.Write(w)
+}
+
+// loadWorkbook lays out a load allocation. req, which may be empty when the
+// job read it from object storage, gives capacities and weights.
+func loadWorkbook(req models.LoadRequest, resp models.LoadResponse) xlsx.Workbook {
+	capacity := map[string]float64{}
+	for _, v := range req.Vehicles {
+		capacity[v.ID] = v.CapacityKg
+	}
+	weight := map[string]float64{}
+	for _, s := range req.Shipments {
+		weight[s.ID] = s.WeightKg
+	}
+
+	summary := xlsx.Sheet{Name: "Summary", Header: []string{"Vehicle", "Shipments", "Load (kg)", "Capacity (kg)", "Utilization (%)", "Late penalty"}}
+	sheets := []xlsx.Sheet{}
+	var load, late float64
+	shipments := 0
+	for _, a := range resp.Allocations {
+		summary.Rows = append(summary.Rows, []any{a.VehicleID, len(a.ShipmentIDs), a.TotalWeight, orEmpty(capacity[a.VehicleID]), a.UtilizationPct, a.LatePenalty})
+		load += a.TotalWeight
+		late += a.LatePenalty
+		shipments += len(a.ShipmentIDs)
+
+		reasons := map[string]models.AllocationReason{}
+		for _, why := range a.Reasons {
+			reasons[why.ShipmentID] = why
+		}
+		sheet := xlsx.Sheet{Name: a.VehicleID, Header: []string{"#", "Shipment", "Weight (kg)", "Reason", "Detail"}}
+		for i, id := range a.ShipmentIDs {
+			sheet.Rows = append(sheet.Rows, []any{i + 1, id, orEmpty(weight[id]), orEmpty(reasons[id].Reason), orEmpty(reasons[id].Detail)})
+		}
+		sheets = append(sheets, sheet)
+	}
+	summary.Rows = append(summary.Rows,
+		[]any{},
+		[]any{"Total", shipments, round2(load), nil, nil, round2(late)},
+		[]any{"Unassigned", len(resp.Unassigned), nil, nil, nil, nil, strings.Join(resp.Unassigned, ", ")},
+		[]any{"Penalty cost", nil, nil, nil, nil, resp.PenaltyCost},
+	)
+	return xlsx.Workbook{Sheets: append([]xlsx.Sheet{summary}, sheets...)}
+}
+
+// fleetWorkbook lays out a fleet plan, costed like scenario KPIs: distance
+// at the request's cost per km plus tolls. req may be empty when the job
+// read it from object storage.
+func fleetWorkbook(req models.FleetRequest, resp models.FleetResponse) xlsx.Workbook {
+	capacity := map[string]float64{}
+	for _, v := range req.Vehicles {
+		capacity[v.ID] = v.CapacityKg
+	}
+	stops := map[string]models.FleetStop{}
+	for _, st := range req.Stops {
+		stops[st.ID] = st
+	}
+	costPerKm := cmp.Or(req.CostPerKm, toll.DefaultCostPerKm)
+
+	summary := xlsx.Sheet{Name: "Summary", Header: []string{"Vehicle", "Stops", "Distance (km)", "Load (kg)", "Capacity (kg)", "Utilization (%)", "Fuel (l)", "Fuel cost", "Tolls", "Cost"}}
+	sheets := []xlsx.Sheet{}
+	var distance, load, fuel, tolls, cost float64
+	count := 0
+	for _, route := range resp.Routes {
+		if len(route.StopIDs) == 0 {
+			continue
+		}
+		kg := cmp.Or(capacity[route.VehicleID], capacityOf(route))
+		var util, litres, fuelCost any
+		if kg > 0 {
+			util = round2(math.Max(route.LoadKg, route.PeakLoadKg) / kg * 100)
+		}
+		if route.Fuel != nil {
+			litres, fuelCost = route.Fuel.Litres, orEmpty(route.Fuel.Cost)
+			fuel += route.Fuel.Litres
+		}
+		c := round2(route.DistanceKm*costPerKm + route.TollInr)
+		summary.Rows = append(summary.Rows, []any{route.VehicleID, len(route.StopIDs), route.DistanceKm, route.LoadKg, orEmpty(kg), util, litres, fuelCost, route.TollInr, c})
+		distance += route.DistanceKm
+		load += route.LoadKg
+		tolls += route.TollInr
+		cost += c
+		count += len(route.StopIDs)
+
+		sheet := xlsx.Sheet{Name: route.VehicleID, Header: []string{"#", "Stop", "Lat", "Lng", "Demand (kg)", "Return (kg)", "Arrival (h)"}}
+		for i, id := range route.StopIDs {
+			st := stops[id]
+			loc := st.Location
+			if i+1 < len(route.Route) {
+				loc = route.Route[i+1] // Route starts at the depot
+			}
+			var arrival any
+			if i < len(route.ArrivalHours) {
+				arrival = route.ArrivalHours[i]
+			}
+			sheet.Rows = append(sheet.Rows, []any{i + 1, id, loc.Lat, loc.Lng, orEmpty(st.DemandKg), orEmpty(st.ReturnKg), arrival})
+		}
+		sheets = append(sheets, sheet)
+	}
+	summary.Rows = append(summary.Rows,
+		[]any{},
+		[]any{"Total", count, round2(distance), round2(load), nil, nil, orEmpty(round2(fuel)), nil, round2(tolls), round2(cost)},
+		[]any{"Unassigned", len(resp.Unassigned), nil, nil, nil, nil, nil, nil, nil, nil, strings.Join(resp.Unassigned, ", ")},
+		[]any{"Cost per km", nil, nil, nil, nil, nil, nil, nil, nil, costPerKm},
+	)
+	return xlsx.Workbook{Sheets: append([]xlsx.Sheet{summary}, sheets...)}
+}
+
+// capacityOf is the capacity a route reports, when capacity is reserved
+func capacityOf(route models.FleetRoute) float64 {
+	if route.Capacity == nil {
+		return 0
+	}
+	return route.Capacity.CapacityKg
+}
+
+// orEmpty leaves a zero value's cell empty rather than showing 0
+func orEmpty[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+func round2(x float64) float64 { return math.Round(x*100) / 100 }
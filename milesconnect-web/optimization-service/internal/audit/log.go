@@ -0,0 +1,281 @@
+// Package audit keeps an append-only log of planning actions: optimization
+// runs, edits to templates and standing orders, published plans and stop
+// status changes. Each event carries the hash of the one before it, so a
+// log file that was edited or truncated in the middle fails to open.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrChain means a log file's hash chain does not verify
+var ErrChain = errors.New("audit: hash chain broken")
+
+// Event is one recorded action. Events are never changed once appended.
+type Event struct {
+	Seq       int64           `json:"seq"`
+	At        time.Time       `json:"at"`
+	Kind      string          `json:"kind"` // e.g. optimize.fleet, template.saved, stop.status
+	Actor     string          `json:"actor"`
+	Tenant    string          `json:"tenant,omitempty"` // Tenant whose records the action concerns
+	Date      string          `json:"date,omitempty"`   // Plan date the action concerns
+	Shipments []string        `json:"shipment_ids,omitempty"`
+	Vehicles  []string        `json:"vehicle_ids,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"` // Kind-specific detail, e.g. the request and the plan
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// Filter selects events; zero fields match everything
+type Filter struct {
+	Kind     string
+	Shipment string
+	Vehicle  string
+	Date     string
+	Since    time.Time
+	Until    time.Time
+	After    int64 // Only events with a higher Seq
+	Limit    int
+	Brief    bool                     // Leave Data out, for listings that read it with Get for a page only
+	Tenants  func(tenant string) bool // Only events of tenants it accepts, when set
+}
+
+// memoryEvents bounds a log kept in memory only; the oldest events go
+// first
+const memoryEvents = 10000
+
+// Log is an audit log, optionally backed by a JSON Lines file. A file-backed
+// log keeps only where each event starts in memory and reads events from
+// the file as they are queried. It is safe for concurrent use.
+type Log struct {
+	mu       sync.RWMutex
+	file     *os.File
+	offsets  []int64 // Where each event's line starts, by Seq-1, and where the next goes
+	memory   []Event // Without a file: the latest events
+	seq      int64   // Of the last event
+	lastHash string
+	now      func() time.Time
+
+	// Torn is how many bytes of a partly written last line Open cut off
+	Torn int64
+}
+
+// Open reads the log at path, verifying its chain, and appends new events to
+// it. A last line cut short, e.g. by a crash mid-append, is dropped; a bad
+// line anywhere else fails. An empty path keeps the log in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{now: time.Now, offsets: []int64{0}}
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	rd := bufio.NewReaderSize(f, 64*1024)
+	var off int64
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		_, more := rd.Peek(1)
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			if more == nil {
+				f.Close()
+				return nil, fmt.Errorf("audit: line %d: %w", l.seq+1, err)
+			}
+			// The last line was cut short
+			if err := f.Truncate(off); err != nil {
+				f.Close()
+				return nil, err
+			}
+			l.Torn = int64(len(line))
+			break
+		}
+		if ev.PrevHash != l.lastHash || ev.Hash != hash(ev) || ev.Seq != l.seq+1 {
+			f.Close()
+			return nil, fmt.Errorf("%w at seq %d", ErrChain, ev.Seq)
+		}
+		if line[len(line)-1] != '\n' {
+			// A whole event whose newline was not written
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+			line = append(line, '\n')
+		}
+		off += int64(len(line))
+		l.seq, l.lastHash = ev.Seq, ev.Hash
+		l.offsets = append(l.offsets, off)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Len returns how many events the log holds
+func (l *Log) Len() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.seq
+}
+
+// Append stamps ev with its sequence number, time and hashes, writes it and
+// returns it
+func (l *Log) Append(ev Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ev.Seq = l.seq + 1
+	ev.At = l.now().UTC()
+	ev.PrevHash = l.lastHash
+	ev.Hash = hash(ev)
+	if l.file != nil {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return Event{}, err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return Event{}, err
+		}
+		l.offsets = append(l.offsets, l.offsets[len(l.offsets)-1]+int64(len(line))+1)
+	} else {
+		if len(l.memory) == memoryEvents {
+			l.memory = slices.Delete(l.memory, 0, 1)
+		}
+		l.memory = append(l.memory, ev)
+	}
+	l.seq, l.lastHash = ev.Seq, ev.Hash
+	return ev, nil
+}
+
+// Query returns matching events in the order they happened, reading no
+// further than it needs to fill f.Limit
+func (l *Log) Query(f Filter) ([]Event, error) {
+	l.mu.RLock()
+	seq, file := l.seq, l.file
+	var memory []Event
+	var from, to int64
+	if file == nil {
+		memory = slices.Clone(l.memory)
+	} else {
+		from, to = l.offsets[min(max(f.After, 0), seq)], l.offsets[seq]
+	}
+	l.mu.RUnlock()
+
+	list := []Event{}
+	keep := func(ev Event) bool {
+		if f.Limit > 0 && len(list) == f.Limit {
+			return false
+		}
+		if ev.Seq > f.After && f.matches(ev) {
+			if f.Brief {
+				ev.Data = nil
+			}
+			list = append(list, ev)
+		}
+		return true
+	}
+	if file == nil {
+		for _, ev := range memory {
+			if !keep(ev) {
+				break
+			}
+		}
+		return list, nil
+	}
+
+	// Events appended since are left for the next query
+	rd := bufio.NewReaderSize(io.NewSectionReader(file, from, to-from), 64*1024)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 0 {
+			var ev Event
+			if err := json.Unmarshal(line, &ev); err != nil {
+				return nil, fmt.Errorf("audit: reading: %w", err)
+			}
+			if !keep(ev) {
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Get returns the events with the given sequence numbers, in that order.
+// Events a memory-only log has already dropped are left out.
+func (l *Log) Get(seqs []int64) ([]Event, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := make([]Event, 0, len(seqs))
+	for _, seq := range seqs {
+		if seq < 1 || seq > l.seq {
+			continue
+		}
+		if l.file == nil {
+			if i := seq - l.memory[0].Seq; i >= 0 {
+				list = append(list, l.memory[i])
+			}
+			continue
+		}
+		line := make([]byte, l.offsets[seq]-l.offsets[seq-1])
+		if _, err := l.file.ReadAt(line, l.offsets[seq-1]); err != nil {
+			return nil, err
+		}
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return nil, fmt.Errorf("audit: reading: %w", err)
+		}
+		list = append(list, ev)
+	}
+	return list, nil
+}
+
+func (f Filter) matches(ev Event) bool {
+	return (f.Kind == "" || ev.Kind == f.Kind) &&
+		(f.Shipment == "" || slices.Contains(ev.Shipments, f.Shipment)) &&
+		(f.Vehicle == "" || slices.Contains(ev.Vehicles, f.Vehicle)) &&
+		(f.Date == "" || ev.Date == f.Date) &&
+		(f.Since.IsZero() || !ev.At.Before(f.Since)) &&
+		(f.Until.IsZero() || ev.At.Before(f.Until)) &&
+		(f.Tenants == nil || f.Tenants(ev.Tenant))
+}
+
+// Close closes the backing file
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// hash covers every field but Hash itself
+func hash(ev Event) string {
+	ev.Hash = ""
+	body, _ := json.Marshal(ev)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
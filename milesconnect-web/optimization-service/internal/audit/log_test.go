@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendQueryAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{
+		{Kind: "optimize.fleet", Shipments: []string{"A", "B"}, Vehicles: []string{"V1"}, Data: []byte(`{"solver": "alns"}`)},
+		{Kind: "plan.published", Date: "2026-10-14", Shipments: []string{"A", "B"}, Vehicles: []string{"V1"}},
+		{Kind: "stop.status", Date: "2026-10-14", Shipments: []string{"A"}, Vehicles: []string{"V1"}},
+	}
+	for _, ev := range events {
+		if _, err := l.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer l.Close()
+	query := func(f Filter) []Event {
+		t.Helper()
+		got, err := l.Query(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if got := query(Filter{Shipment: "A"}); len(got) != 3 || got[0].Seq != 1 || got[2].PrevHash != got[1].Hash || string(got[0].Data) != `{"solver":"alns"}` {
+		t.Errorf("shipment A = %+v", got)
+	}
+	if got := query(Filter{Date: "2026-10-14", Kind: "stop.status"}); len(got) != 1 || got[0].Seq != 3 {
+		t.Errorf("status changes = %+v", got)
+	}
+	if got := query(Filter{After: 1, Limit: 1}); len(got) != 1 || got[0].Seq != 2 {
+		t.Errorf("page after 1 = %+v", got)
+	}
+	if got := query(Filter{Brief: true}); len(got) != 3 || got[0].Data != nil {
+		t.Errorf("brief = %+v", got)
+	}
+	if got, err := l.Get([]int64{3, 1, 9}); err != nil || len(got) != 2 || got[0].Seq != 3 || string(got[1].Data) != `{"solver":"alns"}` {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if ev, _ := l.Append(Event{Kind: "template.saved"}); ev.Seq != 4 || l.Len() != 4 {
+		t.Errorf("seq after reopen = %d", ev.Seq)
+	}
+	if got := query(Filter{After: 3}); len(got) != 1 || got[0].Kind != "template.saved" {
+		t.Errorf("after 3 = %+v", got)
+	}
+}
+
+func TestTornLastLineIsDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, _ := Open(path)
+	l.Append(Event{Kind: "optimize.fleet", Vehicles: []string{"V1"}})
+	l.Append(Event{Kind: "plan.published", Vehicles: []string{"V1"}})
+	l.Close()
+
+	// A crash mid-append leaves half a line
+	body, _ := os.ReadFile(path)
+	os.WriteFile(path, append(body, `{"seq":3,"kind":"stop.st`...), 0o644)
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("torn tail: %v", err)
+	}
+	if l.Torn == 0 || l.Len() != 2 {
+		t.Errorf("torn %d bytes, %d events", l.Torn, l.Len())
+	}
+	if ev, err := l.Append(Event{Kind: "stop.status"}); err != nil || ev.Seq != 3 {
+		t.Fatalf("append after the torn tail: %+v, %v", ev, err)
+	}
+	l.Close()
+	if l, err = Open(path); err != nil || l.Len() != 3 || l.Torn != 0 {
+		t.Fatalf("reopening: %v", err)
+	}
+	l.Close()
+
+	// The same damage mid-file is not a torn tail
+	lines := strings.SplitAfter(string(body), "\n")
+	os.WriteFile(path, []byte(lines[0]+`{"seq":2,"ki`+"\n"+lines[1]), 0o644)
+	if _, err := Open(path); err == nil {
+		t.Error("a broken line mid-file opened")
+	}
+}
+
+func TestMemoryLogIsBounded(t *testing.T) {
+	l, _ := Open("")
+	for range memoryEvents + 5 {
+		l.Append(Event{Kind: "stop.status"})
+	}
+	got, _ := l.Query(Filter{})
+	if len(got) != memoryEvents || got[0].Seq != 6 || l.Len() != memoryEvents+5 {
+		t.Errorf("%d events from seq %d", len(got), got[0].Seq)
+	}
+	if got, _ := l.Query(Filter{After: memoryEvents + 3}); len(got) != 2 {
+		t.Errorf("after = %d events", len(got))
+	}
+}
+
+func TestEditedLogFailsToOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, _ := Open(path)
+	l.Append(Event{Kind: "optimize.fleet", Vehicles: []string{"V1"}})
+	l.Append(Event{Kind: "plan.published", Vehicles: []string{"V1"}})
+	l.Close()
+
+	body, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(body), `"V1"`, `"V2"`, 1)), 0o644)
+	if _, err := Open(path); !errors.Is(err, ErrChain) {
+		t.Errorf("edited event: got %v", err)
+	}
+
+	lines := strings.SplitAfter(string(body), "\n")
+	os.WriteFile(path, []byte(lines[1]), 0o644)
+	if _, err := Open(path); !errors.Is(err, ErrChain) {
+		t.Errorf("dropped first event: got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+// Planner roles. Viewers read their tenant's plans, vehicles and shipments,
+// planners also change them, and admins act on every tenant's.
+const (
+	RoleViewer  = "viewer"
+	RolePlanner = "planner"
+	RoleAdmin   = "admin"
+)
+
+// Principal is who a planner token speaks for: a tenant and a role in it
+type Principal struct {
+	Tenant string
+	Role   string
+}
+
+// Subject returns the token subject naming p
+func (p Principal) Subject() string {
+	return "planner:" + p.Tenant + ":" + p.Role
+}
+
+// ParsePrincipal reads a planner token subject
+func ParsePrincipal(subject string) (Principal, error) {
+	rest, ok := strings.CutPrefix(subject, "planner:")
+	tenant, role, ok2 := strings.Cut(rest, ":")
+	p := Principal{Tenant: tenant, Role: role}
+	if !ok || !ok2 || tenant == "" || !validRole(role) {
+		return Principal{}, ErrInvalid
+	}
+	return p, nil
+}
+
+// NewPrincipal checks tenant and role, e.g. before issuing a token
+func NewPrincipal(tenant, role string) (Principal, error) {
+	if tenant == "" || strings.Contains(tenant, ":") {
+		return Principal{}, errors.New("auth: tenant must be set and free of colons")
+	}
+	if !validRole(role) {
+		return Principal{}, errors.New("auth: role must be viewer, planner or admin")
+	}
+	return Principal{Tenant: tenant, Role: role}, nil
+}
+
+// Reads reports whether p may see a record belonging to tenant
+func (p Principal) Reads(tenant string) bool {
+	return p.Role == RoleAdmin || p.Tenant == tenant
+}
+
+// Writes reports whether p may change a record belonging to tenant
+func (p Principal) Writes(tenant string) bool {
+	return p.Role == RoleAdmin || p.Role == RolePlanner && p.Tenant == tenant
+}
+
+// DriverSubject returns the driver token subject for a vehicle of tenant.
+// In a single-tenant deployment, with no tenant, it is the vehicle ID
+// alone.
+func DriverSubject(tenant, vehicleID string) string {
+	if tenant == "" {
+		return vehicleID
+	}
+	return "driver:" + tenant + ":" + vehicleID
+}
+
+// ParseDriver reads a driver token subject
+func ParseDriver(subject string) (tenant, vehicleID string) {
+	if rest, ok := strings.CutPrefix(subject, "driver:"); ok {
+		if tenant, vehicleID, ok := strings.Cut(rest, ":"); ok && tenant != "" {
+			return tenant, vehicleID
+		}
+	}
+	return "", subject
+}
+
+func validRole(role string) bool {
+	return role == RoleViewer || role == RolePlanner || role == RoleAdmin
+}
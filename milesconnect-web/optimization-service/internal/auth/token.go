@@ -0,0 +1,60 @@
+// Package auth signs and checks the tokens the service hands out: bearer
+// tokens for driver apps, which name a vehicle, and share links, which name a
+// plan. A token carries its subject and expiry and is signed with
+// HMAC-SHA256, so the service needs only the secret to verify it.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("auth: invalid token")
+	ErrExpired = errors.New("auth: token expired")
+)
+
+var b64 = base64.RawURLEncoding
+
+// Sign returns a token for subject that is valid until expires
+func Sign(secret []byte, subject string, expires time.Time) string {
+	payload := b64.EncodeToString([]byte(strconv.FormatInt(expires.Unix(), 10) + ":" + subject))
+	return payload + "." + b64.EncodeToString(mac(secret, payload))
+}
+
+// Verify checks a token's signature and expiry and returns its subject
+func Verify(secret []byte, token string, now time.Time) (string, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalid
+	}
+	got, err := b64.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, payload)) {
+		return "", ErrInvalid
+	}
+
+	raw, err := b64.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	exp, subject, ok := strings.Cut(string(raw), ":")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || err != nil || subject == "" {
+		return "", ErrInvalid
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return "", ErrExpired
+	}
+	return subject, nil
+}
+
+func mac(secret []byte, payload string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
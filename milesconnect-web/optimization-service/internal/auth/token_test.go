@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	token := Sign(secret, "MH-12:AB", now.Add(12*time.Hour))
+
+	id, err := Verify(secret, token, now)
+	if err != nil || id != "MH-12:AB" {
+		t.Fatalf("Verify = %q, %v", id, err)
+	}
+	if _, err := Verify(secret, token, now.Add(12*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("after expiry: got %v", err)
+	}
+	if _, err := Verify([]byte("other"), token, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("wrong secret: got %v", err)
+	}
+
+	// Another vehicle's payload with this token's signature
+	payload, _, _ := strings.Cut(Sign(secret, "V2", now.Add(time.Hour)), ".")
+	_, sig, _ := strings.Cut(token, ".")
+	forged := payload + "." + sig
+	for _, bad := range []string{"", "abc", token + "x", forged} {
+		if _, err := Verify(secret, bad, now); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Verify(%q): got %v", bad, err)
+		}
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	p, err := NewPrincipal("acme", RolePlanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParsePrincipal(p.Subject())
+	if err != nil || got != p {
+		t.Fatalf("ParsePrincipal(%q) = %+v, %v", p.Subject(), got, err)
+	}
+	if !p.Reads("acme") || !p.Writes("acme") || p.Reads("globex") || p.Writes("globex") {
+		t.Errorf("planner access wrong")
+	}
+	viewer := Principal{Tenant: "acme", Role: RoleViewer}
+	if !viewer.Reads("acme") || viewer.Writes("acme") {
+		t.Errorf("viewer access wrong")
+	}
+	admin := Principal{Tenant: "ops", Role: RoleAdmin}
+	if !admin.Reads("acme") || !admin.Writes("globex") {
+		t.Errorf("admin access wrong")
+	}
+
+	for _, bad := range []string{"MH-12:AB", "planner:acme", "planner::planner", "planner:acme:owner"} {
+		if _, err := ParsePrincipal(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ParsePrincipal(%q): got %v", bad, err)
+		}
+	}
+	if _, err := NewPrincipal("a:b", RoleViewer); err == nil {
+		t.Error("tenant with a colon accepted")
+	}
+}
+
+func TestDriverSubject(t *testing.T) {
+	for _, tc := range []struct{ tenant, vehicle string }{{"acme", "MH-12:AB"}, {"", "MH-12:AB"}, {"", "V1"}} {
+		if tenant, vehicle := ParseDriver(DriverSubject(tc.tenant, tc.vehicle)); tenant != tc.tenant || vehicle != tc.vehicle {
+			t.Errorf("%q/%q came back as %q/%q", tc.tenant, tc.vehicle, tenant, vehicle)
+		}
+	}
+}
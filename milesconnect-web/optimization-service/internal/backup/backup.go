@@ -0,0 +1,163 @@
+// Package backup bundles the service's stored planning data for backups
+// and for moving it between environments: route templates with their
+// depots and vehicles, standing orders, vehicle maintenance schedules,
+// planning sessions and dispatched plans.
+//
+// A bundle is one JSON document, or NDJSON with a header line followed by
+// one record per line, which streams and diffs well for large datasets.
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"time"
+)
+
+// Version is the bundle format written; readers accept it and older ones
+const Version = 1
+
+// Bundle is a dataset export
+type Bundle struct {
+	Version        int                       `json:"version"`
+	ExportedAt     time.Time                 `json:"exported_at"`
+	Tenant         string                    `json:"tenant,omitempty"` // When exported for one tenant
+	Templates      []templates.Template      `json:"templates"`
+	StandingOrders []templates.StandingOrder `json:"standing_orders"`
+	Maintenance    []templates.Maintenance   `json:"maintenance"`
+	Sessions       []models.PlanningSession  `json:"sessions"`
+	Boards         []dispatch.Board          `json:"boards"` // Dispatched plans
+}
+
+// Record kinds in an NDJSON bundle
+const (
+	kindBundle        = "bundle" // Header: the bundle's fields other than its records
+	kindTemplate      = "template"
+	kindStandingOrder = "standing_order"
+	kindMaintenance   = "maintenance"
+	kindSession       = "session"
+	kindBoard         = "board"
+)
+
+// line is one NDJSON line
+type line struct {
+	Kind   string          `json:"kind"`
+	Record json.RawMessage `json:"record"`
+}
+
+// header is the first NDJSON line's record
+type header struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Tenant     string    `json:"tenant,omitempty"`
+}
+
+// WriteNDJSON writes b as a header line and one line per record
+func WriteNDJSON(w io.Writer, b Bundle) error {
+	enc := json.NewEncoder(w)
+	put := func(kind string, v any) error {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(line{Kind: kind, Record: raw})
+	}
+	if err := put(kindBundle, header{b.Version, b.ExportedAt, b.Tenant}); err != nil {
+		return err
+	}
+	for _, t := range b.Templates {
+		if err := put(kindTemplate, t); err != nil {
+			return err
+		}
+	}
+	for _, o := range b.StandingOrders {
+		if err := put(kindStandingOrder, o); err != nil {
+			return err
+		}
+	}
+	for _, m := range b.Maintenance {
+		if err := put(kindMaintenance, m); err != nil {
+			return err
+		}
+	}
+	for _, s := range b.Sessions {
+		if err := put(kindSession, s); err != nil {
+			return err
+		}
+	}
+	for _, board := range b.Boards {
+		if err := put(kindBoard, board); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadNDJSON reads a bundle written by WriteNDJSON
+func ReadNDJSON(r io.Reader) (Bundle, error) {
+	var b Bundle
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20) // A dispatched board with tracks runs to megabytes
+	n := 0
+	for sc.Scan() {
+		n++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var l line
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			return Bundle{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		if n == 1 && l.Kind != kindBundle {
+			return Bundle{}, errors.New("line 1 must be the bundle header")
+		}
+		var err error
+		switch l.Kind {
+		case kindBundle:
+			var h header
+			if err = json.Unmarshal(l.Record, &h); err == nil {
+				b.Version, b.ExportedAt, b.Tenant = h.Version, h.ExportedAt, h.Tenant
+			}
+		case kindTemplate:
+			b.Templates, err = appendRecord(b.Templates, l.Record)
+		case kindStandingOrder:
+			b.StandingOrders, err = appendRecord(b.StandingOrders, l.Record)
+		case kindMaintenance:
+			b.Maintenance, err = appendRecord(b.Maintenance, l.Record)
+		case kindSession:
+			b.Sessions, err = appendRecord(b.Sessions, l.Record)
+		case kindBoard:
+			b.Boards, err = appendRecord(b.Boards, l.Record)
+		default:
+			err = fmt.Errorf("unknown kind %q", l.Kind)
+		}
+		if err != nil {
+			return Bundle{}, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Bundle{}, err
+	}
+	return b, b.Check()
+}
+
+func appendRecord[T any](list []T, raw json.RawMessage) ([]T, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return list, err
+	}
+	return append(list, v), nil
+}
+
+// Check rejects bundles from a newer format or with no header
+func (b Bundle) Check() error {
+	if b.Version < 1 || b.Version > Version {
+		return fmt.Errorf("bundle version %d is not supported; this service reads up to %d", b.Version, Version)
+	}
+	return nil
+}
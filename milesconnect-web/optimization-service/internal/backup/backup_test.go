@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"bytes"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/templates"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	b := Bundle{
+		Version:        Version,
+		ExportedAt:     time.Date(2026, 10, 15, 6, 0, 0, 0, time.UTC),
+		Tenant:         "acme",
+		Templates:      []templates.Template{{Name: "north", Depot: models.Location{Lat: 19.07, Lng: 72.88}, Tenant: "acme"}},
+		StandingOrders: []templates.StandingOrder{{ID: "O1", Template: "north", Schedule: "FREQ=DAILY", StartDate: "2026-10-01"}},
+		Maintenance:    []templates.Maintenance{{VehicleID: "V1", ServiceEveryKm: 5000}},
+		Sessions:       []models.PlanningSession{{Name: "diwali", Status: "open"}},
+		Boards:         []dispatch.Board{{Date: "2026-10-15", Runs: []dispatch.Run{{VehicleID: "V1", Status: dispatch.Pending}}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 6 {
+		t.Errorf("%d lines, want a header and 5 records:\n%s", n, buf.String())
+	}
+	got, err := ReadNDJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, b)
+	}
+}
+
+func TestReadNDJSONRejects(t *testing.T) {
+	for name, body := range map[string]string{
+		"no header":     `{"kind":"template","record":{"name":"x"}}`,
+		"newer version": `{"kind":"bundle","record":{"version":99}}`,
+		"unknown kind":  `{"kind":"bundle","record":{"version":1}}` + "\n" + `{"kind":"invoice","record":{}}`,
+		"broken line":   `{"kind":"bundle","record":{"version":1}}` + "\n" + `{"kind":`,
+		"empty":         ``,
+	} {
+		if _, err := ReadNDJSON(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package calendar knows which days are not working days: Indian national
+// and state holidays, weekly offs and customer-specific closures. Holidays
+// whose date moves each year (Diwali, Holi, Eid, ...) come from a holiday
+// file, since they follow lunar calendars and official notifications.
+package calendar
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Holiday is a closed day; State is an ISO 3166-2:IN subdivision code (e.g.
+// MH) or empty for national holidays
+type Holiday struct {
+	Date  string `json:"date"`
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+}
+
+// fixedHoliday recurs on the same date every year
+type fixedHoliday struct {
+	month time.Month
+	day   int
+	name  string
+	state string
+}
+
+// Gazetted holidays with a fixed date
+var fixedHolidays = []fixedHoliday{
+	{time.January, 26, "Republic Day", ""},
+	{time.August, 15, "Independence Day", ""},
+	{time.October, 2, "Gandhi Jayanti", ""},
+	{time.December, 25, "Christmas", ""},
+	{time.April, 14, "Puthandu (Tamil New Year)", "TN"},
+	{time.May, 1, "Maharashtra Day", "MH"},
+	{time.May, 1, "Gujarat Day", "GJ"},
+	{time.November, 1, "Kannada Rajyotsava", "KA"},
+}
+
+var validState = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Calendar holds the known holidays. The zero value knows only the fixed
+// holidays; use Load to add more. It is safe for concurrent use.
+type Calendar struct {
+	mu     sync.RWMutex
+	byDate map[string][]Holiday
+}
+
+// Load adds holidays from CSV with date (YYYY-MM-DD) and name columns and an
+// optional state column
+func (c *Calendar) Load(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	dateCol, ok1 := col["date"]
+	nameCol, ok2 := col["name"]
+	if !ok1 || !ok2 {
+		return errors.New("holiday CSV header must contain date and name")
+	}
+	stateCol, hasState := col["state"]
+
+	var added []Holiday
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) <= max(dateCol, nameCol) {
+			return fmt.Errorf("line %d: missing columns", line)
+		}
+		h := Holiday{Date: strings.TrimSpace(rec[dateCol]), Name: strings.TrimSpace(rec[nameCol])}
+		if hasState && stateCol < len(rec) {
+			h.State = strings.ToUpper(strings.TrimSpace(rec[stateCol]))
+		}
+		if _, err := time.Parse(time.DateOnly, h.Date); err != nil || h.Name == "" || (h.State != "" && !validState.MatchString(h.State)) {
+			return fmt.Errorf("line %d: invalid holiday entry", line)
+		}
+		added = append(added, h)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byDate == nil {
+		c.byDate = map[string][]Holiday{}
+	}
+	for _, h := range added {
+		c.byDate[h.Date] = append(c.byDate[h.Date], h)
+	}
+	return nil
+}
+
+// Holidays returns the national holidays on date and those of state
+func (c *Calendar) Holidays(date time.Time, state string) []Holiday {
+	key := date.Format(time.DateOnly)
+	var list []Holiday
+	for _, f := range fixedHolidays {
+		if date.Month() == f.month && date.Day() == f.day && (f.state == "" || f.state == state) {
+			list = append(list, Holiday{Date: key, Name: f.name, State: f.state})
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, h := range c.byDate[key] {
+		if h.State == "" || h.State == state {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+// Between lists the holidays for state from from to to inclusive, by date
+func (c *Calendar) Between(from, to time.Time, state string) []Holiday {
+	list := []Holiday{}
+	for d := day(from); !d.After(day(to)); d = d.AddDate(0, 0, 1) {
+		list = append(list, c.Holidays(d, state)...)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	return list
+}
+
+// WorkingDay reports whether date is neither a weekly off nor a holiday in
+// state
+func (c *Calendar) WorkingDay(date time.Time, state string, weeklyOff []time.Weekday) bool {
+	for _, wd := range weeklyOff {
+		if date.Weekday() == wd {
+			return false
+		}
+	}
+	return len(c.Holidays(date, state)) == 0
+}
+
+// AddWorkingDays returns the date n working days after date; n = 0 gives
+// date itself when it is a working day, else the next one
+func (c *Calendar) AddWorkingDays(date time.Time, n int, state string, weeklyOff []time.Weekday) (time.Time, error) {
+	if len(weeklyOff) >= 7 {
+		return time.Time{}, errors.New("calendar: every day is a weekly off")
+	}
+	d := day(date)
+	for !c.WorkingDay(d, state, weeklyOff) {
+		d = d.AddDate(0, 0, 1)
+	}
+	for ; n > 0; n-- {
+		d = d.AddDate(0, 0, 1)
+		for !c.WorkingDay(d, state, weeklyOff) {
+			d = d.AddDate(0, 0, 1)
+		}
+	}
+	return d, nil
+}
+
+// ValidState reports whether s is empty or looks like a state code
+func ValidState(s string) bool {
+	return s == "" || validState.MatchString(s)
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,100 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+const holidayCSV = `date,name,state
+2026-11-08,Diwali,
+2026-03-04,Holi,
+2026-09-14,Ganesh Chaturthi,MH
+`
+
+func TestHolidays(t *testing.T) {
+	var c Calendar
+	if err := c.Load(strings.NewReader(holidayCSV)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date, state string
+		want        int
+	}{
+		{"2026-01-26", "", 1},   // Fixed national
+		{"2027-08-15", "KA", 1}, // Fixed national, any year
+		{"2026-11-08", "", 1},   // Loaded national
+		{"2026-09-14", "MH", 1}, // Loaded state
+		{"2026-09-14", "KA", 0},
+		{"2026-05-01", "MH", 1}, // Fixed state
+		{"2026-05-01", "", 0},
+		{"2026-10-15", "MH", 0},
+	}
+	for _, tc := range tests {
+		if got := c.Holidays(date(tc.date), tc.state); len(got) != tc.want {
+			t.Errorf("%s in %q: %v, want %d holidays", tc.date, tc.state, got, tc.want)
+		}
+	}
+
+	if got := c.Between(date("2026-01-01"), date("2026-12-31"), "MH"); len(got) != 8 || got[0].Name != "Republic Day" {
+		t.Errorf("2026 in MH: %v", got)
+	}
+}
+
+func TestAddWorkingDays(t *testing.T) {
+	var c Calendar
+	sunday := []time.Weekday{time.Sunday}
+
+	tests := []struct {
+		from  string
+		n     int
+		state string
+		off   []time.Weekday
+		want  string
+	}{
+		{"2026-10-01", 0, "", sunday, "2026-10-01"},
+		{"2026-10-01", 1, "", sunday, "2026-10-03"}, // Skips Gandhi Jayanti on Friday the 2nd
+		{"2026-10-01", 2, "", sunday, "2026-10-05"}, // ... and Sunday the 4th
+		{"2026-10-02", 0, "", nil, "2026-10-03"},    // Starts on a holiday
+		{"2026-04-30", 1, "MH", sunday, "2026-05-02"},
+		{"2026-04-30", 1, "KA", sunday, "2026-05-01"},
+	}
+	for _, tc := range tests {
+		got, err := c.AddWorkingDays(date(tc.from), tc.n, tc.state, tc.off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Format(time.DateOnly) != tc.want {
+			t.Errorf("%s + %d in %q: %s, want %s", tc.from, tc.n, tc.state, got.Format(time.DateOnly), tc.want)
+		}
+	}
+
+	all := []time.Weekday{0, 1, 2, 3, 4, 5, 6}
+	if _, err := c.AddWorkingDays(date("2026-10-01"), 1, "", all); err == nil {
+		t.Error("no working days: expected an error")
+	}
+}
+
+func TestClosure(t *testing.T) {
+	cl := Closure{Weekdays: []string{"Sun"}, Dates: []string{"2026-10-20"}}
+	if err := cl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for d, want := range map[string]bool{"2026-10-18": true, "2026-10-20": true, "2026-10-19": false} {
+		if cl.Closed(date(d)) != want {
+			t.Errorf("%s: closed = %v, want %v", d, !want, want)
+		}
+	}
+	if err := (Closure{Dates: []string{"20/10/2026"}}).Validate(); err == nil {
+		t.Error("bad date: expected an error")
+	}
+}
@@ -0,0 +1,70 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWeekday reads mon..sun, case-insensitively
+func ParseWeekday(s string) (time.Weekday, error) {
+	wd, ok := weekdays[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown day %q; use mon, tue, wed, thu, fri, sat or sun", s)
+	}
+	return wd, nil
+}
+
+// ParseWeekdays reads a list of days
+func ParseWeekdays(days []string) ([]time.Weekday, error) {
+	list := make([]time.Weekday, 0, len(days))
+	for _, d := range days {
+		wd, err := ParseWeekday(d)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, wd)
+	}
+	return list, nil
+}
+
+// Closure is when a customer does not accept deliveries: weekly closed days
+// and specific dates
+type Closure struct {
+	Weekdays []string `json:"weekdays,omitempty"` // mon..sun
+	Dates    []string `json:"dates,omitempty"`    // YYYY-MM-DD
+}
+
+// Validate checks the days and dates
+func (cl Closure) Validate() error {
+	if _, err := ParseWeekdays(cl.Weekdays); err != nil {
+		return err
+	}
+	for _, d := range cl.Dates {
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			return fmt.Errorf("closure date %q must be YYYY-MM-DD", d)
+		}
+	}
+	return nil
+}
+
+// Closed reports whether the customer is closed on date
+func (cl Closure) Closed(date time.Time) bool {
+	for _, d := range cl.Weekdays {
+		if wd, err := ParseWeekday(d); err == nil && wd == date.Weekday() {
+			return true
+		}
+	}
+	key := date.Format(time.DateOnly)
+	for _, d := range cl.Dates {
+		if d == key {
+			return true
+		}
+	}
+	return false
+}
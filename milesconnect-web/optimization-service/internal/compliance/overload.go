@@ -0,0 +1,71 @@
+// Package compliance checks loads against Indian road rules before and
+// after dispatch.
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+)
+
+// ClassGVWKg is the legal gross vehicle weight of rigid goods vehicles by
+// axle count, as revised by MoRTH in 2018. The GVW on a vehicle's
+// registration certificate is what it is held to, so a vehicle's own GVWKg
+// wins; articulated vehicles have no class here and must declare theirs.
+var ClassGVWKg = map[string]float64{
+	"lcv":    7500,
+	"2-axle": 18500,
+	"3-axle": 28500,
+	"4-axle": 36000,
+}
+
+// NearLimit is the share of the legal GVW from which a load is flagged as
+// close: weighbridges and declared weights disagree by a few percent
+const NearLimit = 0.95
+
+// Section 194(1) of the Motor Vehicles Act (as amended in 2019) fines
+// overloading Rs 20,000 plus Rs 2,000 per tonne over the limit
+const (
+	baseFineInr     = 20000
+	perTonneFineInr = 2000
+)
+
+var ErrUnknownGVW = errors.New("compliance: no legal GVW")
+
+// LegalGVW returns the weight v may not exceed
+func LegalGVW(v models.VehicleWeights) (float64, error) {
+	if v.GVWKg > 0 {
+		return v.GVWKg, nil
+	}
+	if gvw, ok := ClassGVWKg[v.Class]; ok {
+		return gvw, nil
+	}
+	return 0, fmt.Errorf("%w for vehicle %s: set gvw_kg or a class of lcv, 2-axle, 3-axle or 4-axle", ErrUnknownGVW, v.ID)
+}
+
+// Check weighs grossKg against v's legal GVW. source says whether the
+// weight is planned or actual.
+func Check(v models.VehicleWeights, grossKg float64, source string) (models.OverloadFinding, error) {
+	gvw, err := LegalGVW(v)
+	if err != nil {
+		return models.OverloadFinding{}, err
+	}
+	f := models.OverloadFinding{
+		VehicleID:  v.ID,
+		Source:     source,
+		GrossKg:    math.Round(grossKg*10) / 10,
+		LegalGVWKg: gvw,
+		Status:     "ok",
+	}
+	switch over := grossKg - gvw; {
+	case over > 0:
+		f.Status = "overloaded"
+		f.OverloadKg = math.Round(over*10) / 10
+		f.OverloadPct = math.Round(over/gvw*1000) / 10
+		f.FineInr = baseFineInr + perTonneFineInr*math.Ceil(over/1000)
+	case grossKg >= NearLimit*gvw:
+		f.Status = "near_limit"
+	}
+	return f, nil
+}
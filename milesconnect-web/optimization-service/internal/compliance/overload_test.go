@@ -0,0 +1,37 @@
+package compliance
+
+import (
+	"errors"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	truck := models.VehicleWeights{ID: "T1", Class: "2-axle", UnladenKg: 6500}
+	tests := []struct {
+		v       models.VehicleWeights
+		gross   float64
+		status  string
+		overKg  float64
+		fineInr float64
+	}{
+		{truck, 16000, "ok", 0, 0},
+		{truck, 17600, "near_limit", 0, 0},
+		{truck, 18500, "near_limit", 0, 0},
+		{truck, 19700, "overloaded", 1200, 24000}, // 1.2 t over: two started tonnes
+		{models.VehicleWeights{ID: "T2", Class: "2-axle", GVWKg: 16200}, 17000, "overloaded", 800, 22000},
+	}
+	for _, tt := range tests {
+		f, err := Check(tt.v, tt.gross, "planned")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Status != tt.status || f.OverloadKg != tt.overKg || f.FineInr != tt.fineInr {
+			t.Errorf("%s at %.0f kg: got %+v", tt.v.ID, tt.gross, f)
+		}
+	}
+
+	if _, err := Check(models.VehicleWeights{ID: "TT", Class: "tractor-trailer"}, 1, "planned"); !errors.Is(err, ErrUnknownGVW) {
+		t.Errorf("unknown class: got %v", err)
+	}
+}
@@ -0,0 +1,598 @@
+// Package connector pushes dispatched plans to downstream warehouse and
+// transport systems (WMS, TMS) as they change, in place of hand-carried CSV
+// exports. Each publish of a day's plan yields line items, one per stop
+// that is new or moved since the previous version and one per stop that
+// was dropped; every connector maps them to its system's fields through
+// templates and delivers them over HTTP or the message broker. Items wait
+// in an outbox on disk until their system takes them, so an outage or a
+// restart delays them rather than losing them.
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/events"
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Line item operations
+const (
+	OpUpsert = "upsert" // The stop is new on the plan, or moved
+	OpDelete = "delete" // The stop was dropped from the plan
+)
+
+// Item is a line item: one stop of a dispatched plan
+type Item struct {
+	Op         string
+	Date       string
+	Version    int
+	VehicleID  string // Empty for deletes
+	Seq        int    // Position on the vehicle's run, from 1
+	ShipmentID string
+	Lat, Lng   float64
+	ETA        time.Time // Zero when the plan has no ETA for the stop
+	Customer   string
+	City       string
+}
+
+// Changes returns the line items publishing cur over prev, which is nil the
+// first time a date is dispatched. ETAs, planned in hours from the start of
+// the day, are placed on the clock by dayStart in zone.
+func Changes(prev *dispatch.Board, cur dispatch.Board, dayStart time.Duration, zone *time.Location) []Item {
+	day, _ := time.ParseInLocation(time.DateOnly, cur.Date, zone)
+	// items lists b's stops in run order as cur's line items
+	items := func(b dispatch.Board) []Item {
+		var list []Item
+		for _, r := range b.Runs {
+			for i, st := range r.Stops {
+				it := Item{Op: OpUpsert, Date: cur.Date, Version: cur.Version, VehicleID: r.VehicleID, Seq: i + 1, ShipmentID: st.ID,
+					Lat: st.Location.Lat, Lng: st.Location.Lng, Customer: st.Customer, City: st.City}
+				if st.ETAHours > 0 {
+					it.ETA = day.Add(dayStart + time.Duration(st.ETAHours*float64(time.Hour))).Round(time.Minute)
+				}
+				list = append(list, it)
+			}
+		}
+		return list
+	}
+	before, after := map[string]Item{}, map[string]bool{}
+	if prev != nil {
+		for _, it := range items(*prev) {
+			before[it.ShipmentID] = it
+		}
+	}
+
+	var out []Item
+	for _, it := range items(cur) {
+		after[it.ShipmentID] = true
+		if old, ok := before[it.ShipmentID]; !ok || old != it {
+			out = append(out, it)
+		}
+	}
+	if prev != nil {
+		for _, it := range items(*prev) {
+			if !after[it.ShipmentID] {
+				out = append(out, Item{Op: OpDelete, Date: it.Date, Version: cur.Version, ShipmentID: it.ShipmentID,
+					Lat: it.Lat, Lng: it.Lng, Customer: it.Customer, City: it.City})
+			}
+		}
+	}
+	return out
+}
+
+// Kinds of connector
+const (
+	KindHTTP   = "http"   // POSTs each batch of line items to URL
+	KindBroker = "broker" // Publishes each line item to Topic
+)
+
+// Formats of a batch
+const (
+	FormatJSON   = "json"   // An array of objects; a broker message is one object
+	FormatNDJSON = "ndjson" // An object per line
+	FormatCSV    = "csv"    // A header row, then a row per item
+)
+
+// Connector describes a downstream system and its format
+type Connector struct {
+	Name   string `json:"name"`
+	Tenant string `json:"tenant,omitempty"` // Whose plans it receives; no other tenant's reach it
+	Kind   string `json:"kind"`
+	URL    string `json:"url,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	Format string `json:"format,omitempty"` // Default json
+
+	// Headers are sent with each HTTP batch, e.g. Authorization; $NAME or
+	// ${NAME} in a value is replaced by the environment variable, so secrets
+	// stay out of the file
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Fields map line items to the system's fields, in order; when empty
+	// every Item field goes out under its snake_case name
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// Field is a target field and the text/template producing it from an Item,
+// e.g. {{.ShipmentID}} or {{date "02/01/2006 15:04" .ETA}}. Templates may
+// use upper, lower and date.
+type Field struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	Number   bool   `json:"number,omitempty"` // A JSON number rather than a string
+}
+
+// DefaultFields are used by connectors that map no fields
+var DefaultFields = []Field{
+	{Name: "op", Template: "{{.Op}}"},
+	{Name: "date", Template: "{{.Date}}"},
+	{Name: "version", Template: "{{.Version}}", Number: true},
+	{Name: "vehicle_id", Template: "{{.VehicleID}}"},
+	{Name: "seq", Template: "{{.Seq}}", Number: true},
+	{Name: "shipment_id", Template: "{{.ShipmentID}}"},
+	{Name: "lat", Template: "{{.Lat}}", Number: true},
+	{Name: "lng", Template: "{{.Lng}}", Number: true},
+	{Name: "eta", Template: `{{date "2006-01-02T15:04:05Z07:00" .ETA}}`},
+	{Name: "customer", Template: "{{.Customer}}"},
+	{Name: "city", Template: "{{.City}}"},
+}
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// date formats t by layout, or is empty for the zero time
+	"date": func(layout string, t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(layout)
+	},
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Parse reads a JSON array of connectors
+func Parse(body []byte) ([]Connector, error) {
+	var list []Connector
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, c := range list {
+		if seen[c.Name] {
+			return nil, fmt.Errorf("connector %s listed twice", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return list, nil
+}
+
+// Load reads the connectors in a JSON file
+func Load(path string) ([]Connector, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list, err := Parse(body)
+	if err != nil {
+		return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return list, nil
+}
+
+var (
+	delivered = metrics.NewCounter("connector_items_delivered_total", "Plan line items accepted by downstream systems")
+	failed    = metrics.NewCounter("connector_items_failed_total", "Plan line items dropped because a system refused them or they could not be queued")
+)
+
+const (
+	maxAttempts = 4
+	// retryEvery is how long a batch its system did not take waits in the
+	// outbox before the next round of attempts
+	retryEvery = 30 * time.Second
+)
+
+// Stats are how a connector's deliveries have gone since startup
+type Stats struct {
+	Delivered    int       `json:"delivered_items"`
+	Failed       int       `json:"failed_items"`
+	Pending      int       `json:"pending_items"` // In the outbox, from before a restart too
+	LastDelivery time.Time `json:"last_delivery,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// Sink delivers a connector's line items from a background worker,
+// retrying 5xx responses and network errors with backoff, and then again
+// every retryEvery for as long as its system is down
+type Sink struct {
+	Connector
+	fields  []*template.Template
+	headers map[string]string
+	client  *http.Client
+	broker  events.Broker
+	outbox  string
+	backoff time.Duration
+	retry   time.Duration
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu    sync.Mutex
+	seq   int
+	stats Stats
+}
+
+// Start checks c and starts delivering to it; b is the message broker, for
+// broker connectors, and batches wait in dir/<name> until delivered,
+// including any an earlier run left there. Close stops it.
+func Start(c Connector, b events.Broker, dir string) (*Sink, error) {
+	if !validName.MatchString(c.Name) {
+		return nil, fmt.Errorf("connector name %q must be lowercase letters, digits and dashes", c.Name)
+	}
+	if c.Format == "" {
+		c.Format = FormatJSON
+	}
+	switch {
+	case c.Kind == KindHTTP && !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://"):
+		return nil, fmt.Errorf("connector %s: url must be http:// or https://", c.Name)
+	case c.Kind == KindBroker && (c.Topic == "" || c.Format != FormatJSON):
+		return nil, fmt.Errorf("connector %s: broker connectors need a topic and publish json", c.Name)
+	case c.Kind == KindBroker && b == nil:
+		return nil, fmt.Errorf("connector %s: no message broker is configured", c.Name)
+	case c.Kind != KindHTTP && c.Kind != KindBroker:
+		return nil, fmt.Errorf("connector %s: kind must be http or broker", c.Name)
+	case c.Format != FormatJSON && c.Format != FormatNDJSON && c.Format != FormatCSV:
+		return nil, fmt.Errorf("connector %s: format must be json, ndjson or csv", c.Name)
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = DefaultFields
+	}
+	s := &Sink{Connector: c, headers: map[string]string{}, client: &http.Client{Timeout: 30 * time.Second}, broker: b,
+		outbox: filepath.Join(dir, c.Name), backoff: time.Second, retry: retryEvery,
+		wake: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	for _, f := range c.Fields {
+		if f.Name == "" || f.Template == "" {
+			return nil, fmt.Errorf("connector %s: every field needs a name and a template", c.Name)
+		}
+		t, err := template.New(f.Name).Funcs(funcs).Option("missingkey=error").Parse(f.Template)
+		if err != nil {
+			return nil, fmt.Errorf("connector %s: field %s: %v", c.Name, f.Name, err)
+		}
+		s.fields = append(s.fields, t)
+	}
+	for k, v := range c.Headers {
+		s.headers[k] = os.ExpandEnv(v)
+	}
+	if err := os.MkdirAll(s.outbox, 0o755); err != nil {
+		return nil, fmt.Errorf("connector %s: %v", c.Name, err)
+	}
+	pending, err := s.pending()
+	if err != nil {
+		return nil, fmt.Errorf("connector %s: %v", c.Name, err)
+	}
+	for _, name := range pending {
+		if items, err := s.read(name); err == nil {
+			s.stats.Pending += len(items)
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+// Send puts a batch of line items in the outbox for the worker to deliver;
+// when the outbox cannot be written the batch is dropped and counted as
+// failed. A nil Sink discards it.
+func (s *Sink) Send(items []Item) {
+	if s == nil || len(items) == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.stats.Pending += len(items)
+	s.mu.Unlock()
+	body, err := json.Marshal(items)
+	if err == nil {
+		s.mu.Lock()
+		s.seq++
+		// Names sort in the order batches were sent, across restarts too
+		name := fmt.Sprintf("%019d-%06d.json", time.Now().UnixNano(), s.seq%1000000)
+		s.mu.Unlock()
+		err = s.write(name, body)
+	}
+	if err != nil {
+		s.fail(len(items), err)
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write puts body in the outbox as name, whole or not at all
+func (s *Sink) write(name string, body []byte) error {
+	tmp := filepath.Join(s.outbox, name+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.outbox, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pending lists the outbox's batches, oldest first
+func (s *Sink) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.outbox)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *Sink) read(name string) ([]Item, error) {
+	body, err := os.ReadFile(filepath.Join(s.outbox, name))
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	return items, json.Unmarshal(body, &items)
+}
+
+// Stats returns how deliveries have gone
+func (s *Sink) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Close tries once more to deliver what is in the outbox and stops the
+// worker; what its system still does not take is delivered after the next
+// Start
+func (s *Sink) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	for {
+		var retry <-chan time.Time
+		if !s.drain() {
+			retry = time.After(s.retry)
+		}
+		select {
+		case <-s.stop:
+			s.drain()
+			return
+		case <-s.wake:
+		case <-retry:
+		}
+	}
+}
+
+// drain delivers the outbox's batches in order, and reports whether it
+// emptied it. When a system stays down it stops at the batch it could not
+// deliver, so none overtakes another.
+func (s *Sink) drain() bool {
+	names, err := s.pending()
+	if err != nil {
+		log.Printf("connector %s: reading the outbox: %v", s.Name, err)
+		return false
+	}
+	for _, name := range names {
+		items, err := s.read(name)
+		if err == nil && !s.deliverBatch(name, items) {
+			return false
+		}
+		if err != nil {
+			s.fail(0, fmt.Errorf("reading %s: %v", name, err))
+		}
+		os.Remove(filepath.Join(s.outbox, name))
+	}
+	return true
+}
+
+// deliverBatch sends items, and reports false when they are to stay in the
+// outbox as name for a later try; for a broker, whose items go one at a
+// time, what stays is those not yet published
+func (s *Sink) deliverBatch(name string, items []Item) bool {
+	rows, err := s.render(items)
+	if err != nil {
+		s.fail(len(items), err)
+		return true
+	}
+	if s.Kind == KindBroker {
+		for i, row := range rows {
+			msg, _ := json.Marshal(row)
+			if !s.deliver(1, func(ctx context.Context) (bool, error) { return true, s.broker.Publish(ctx, s.Topic, msg) }) {
+				if body, err := json.Marshal(items[i:]); err == nil && i > 0 {
+					s.write(name, body)
+				}
+				return false
+			}
+		}
+		return true
+	}
+	body, contentType := s.encode(rows)
+	return s.deliver(len(items), func(ctx context.Context) (bool, error) { return s.post(ctx, body, contentType) })
+}
+
+// deliver tries send up to maxAttempts times while it reports the failure
+// worth retrying, counting n items delivered or failed. It reports false
+// when the failure is worth retrying later, leaving the items pending.
+func (s *Sink) deliver(n int, send func(ctx context.Context) (retry bool, err error)) bool {
+	var err error
+	var retry bool
+	for attempt := range maxAttempts {
+		if attempt > 0 {
+			time.Sleep(s.backoff << (attempt - 1))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		retry, err = send(ctx)
+		cancel()
+		if err == nil || !retry {
+			break
+		}
+	}
+	if err != nil && retry {
+		log.Printf("connector %s: %d line items wait for retry: %v", s.Name, n, err)
+		s.mu.Lock()
+		s.stats.LastError = err.Error()
+		s.mu.Unlock()
+		return false
+	}
+	if err != nil {
+		s.fail(n, err)
+		return true
+	}
+	delivered.Add(int64(n))
+	s.mu.Lock()
+	s.stats.Delivered += n
+	s.stats.Pending -= n
+	s.stats.LastDelivery = time.Now().UTC()
+	s.mu.Unlock()
+	return true
+}
+
+func (s *Sink) fail(n int, err error) {
+	log.Printf("connector %s: dropping %d line items: %v", s.Name, n, err)
+	failed.Add(int64(n))
+	s.mu.Lock()
+	s.stats.Failed += n
+	s.stats.Pending -= n
+	s.stats.LastError = err.Error()
+	s.mu.Unlock()
+}
+
+// row is an item mapped to the connector's fields, kept in their order
+type row struct {
+	names  []string
+	values []any
+}
+
+func (r row) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range r.names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(name)
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// render maps items through the connector's field templates
+func (s *Sink) render(items []Item) ([]row, error) {
+	rows := make([]row, len(items))
+	var buf strings.Builder
+	for i, it := range items {
+		r := row{names: make([]string, len(s.fields)), values: make([]any, len(s.fields))}
+		for j, t := range s.fields {
+			buf.Reset()
+			if err := t.Execute(&buf, it); err != nil {
+				return nil, err
+			}
+			r.names[j] = s.Fields[j].Name
+			r.values[j] = buf.String()
+			if s.Fields[j].Number && buf.Len() > 0 {
+				n, err := strconv.ParseFloat(buf.String(), 64)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %q is not a number", s.Fields[j].Name, buf.String())
+				}
+				r.values[j] = n
+			}
+		}
+		rows[i] = r
+	}
+	return rows, nil
+}
+
+// encode writes rows in the connector's format
+func (s *Sink) encode(rows []row) ([]byte, string) {
+	var b bytes.Buffer
+	switch s.Format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(&b)
+		for _, r := range rows {
+			enc.Encode(r)
+		}
+		return b.Bytes(), "application/x-ndjson"
+	case FormatCSV:
+		w := csv.NewWriter(&b)
+		names := make([]string, len(s.Fields))
+		for i, f := range s.Fields {
+			names[i] = f.Name
+		}
+		w.Write(names)
+		for _, r := range rows {
+			rec := make([]string, len(r.values))
+			for i, v := range r.values {
+				rec[i] = fmt.Sprint(v)
+			}
+			w.Write(rec)
+		}
+		w.Flush()
+		return b.Bytes(), "text/csv"
+	}
+	json.NewEncoder(&b).Encode(rows)
+	return b.Bytes(), "application/json"
+}
+
+// post sends a batch, retrying server errors but not refusals
+func (s *Sink) post(ctx context.Context, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if ue, ok := err.(*url.Error); ok {
+		// The URL may carry a key; the connector's name says which it was
+		return true, fmt.Errorf("%s: %w", s.Name, ue.Err)
+	}
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("%s answered %s", s.Name, resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,197 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var ist = time.FixedZone("IST", 5*3600+1800)
+
+func board(version int, runs ...dispatch.Run) dispatch.Board {
+	return dispatch.Board{Date: "2026-10-15", Runs: runs, Version: version}
+}
+
+func run(vehicle string, stops ...dispatch.Stop) dispatch.Run {
+	return dispatch.Run{VehicleID: vehicle, Stops: stops}
+}
+
+func TestChanges(t *testing.T) {
+	a := dispatch.Stop{ID: "A", ETAHours: 1.5, Location: models.Location{Lat: 28.6, Lng: 77.2}, Customer: "Acme"}
+	b := dispatch.Stop{ID: "B", ETAHours: 2}
+	c := dispatch.Stop{ID: "C", ETAHours: 3}
+	first := board(1, run("V1", a, b, c))
+	items := Changes(nil, first, 9*time.Hour, ist)
+	if len(items) != 3 || items[0].Op != OpUpsert || items[0].Seq != 1 || items[0].Customer != "Acme" || items[2].ShipmentID != "C" {
+		t.Fatalf("first publish = %+v", items)
+	}
+	if want := time.Date(2026, 10, 15, 10, 30, 0, 0, ist); !items[0].ETA.Equal(want) {
+		t.Errorf("ETA = %s, want %s", items[0].ETA, want)
+	}
+
+	// A stays put; C moves to V2; B is dropped
+	second := board(2, run("V1", a), run("V2", c))
+	items = Changes(&first, second, 9*time.Hour, ist)
+	if len(items) != 2 {
+		t.Fatalf("replan = %+v", items)
+	}
+	if it := items[0]; it.Op != OpUpsert || it.ShipmentID != "C" || it.VehicleID != "V2" || it.Seq != 1 || it.Version != 2 {
+		t.Errorf("moved = %+v", it)
+	}
+	if it := items[1]; it.Op != OpDelete || it.ShipmentID != "B" || it.VehicleID != "" {
+		t.Errorf("dropped = %+v", it)
+	}
+	if items := Changes(&second, board(3, second.Runs...), 9*time.Hour, ist); len(items) != 0 {
+		t.Errorf("republishing unchanged = %+v", items)
+	}
+}
+
+func TestHTTPConnectorMapsFieldsToCSV(t *testing.T) {
+	t.Setenv("TMS_TOKEN", "s3cret")
+	var mu sync.Mutex
+	var bodies []string
+	calls := 0
+	tms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("Content-Type") != "text/csv" {
+			http.Error(w, "bad headers", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer tms.Close()
+
+	s, err := Start(Connector{
+		Name:    "tms",
+		Kind:    KindHTTP,
+		URL:     tms.URL,
+		Format:  FormatCSV,
+		Headers: map[string]string{"Authorization": "Bearer ${TMS_TOKEN}"},
+		Fields: []Field{
+			{Name: "ACTION", Template: "{{upper .Op}}"},
+			{Name: "Consignment", Template: "{{.ShipmentID}}"},
+			{Name: "Truck", Template: "{{.VehicleID}}"},
+			{Name: "Arrive", Template: `{{date "02/01/2006 15:04" .ETA}}`},
+		},
+	}, nil, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backoff = time.Millisecond
+	prev := board(1, run("V1", dispatch.Stop{ID: "A", ETAHours: 1}, dispatch.Stop{ID: "B", ETAHours: 2}))
+	s.Send(Changes(&prev, board(2, run("V1", dispatch.Stop{ID: "A", ETAHours: 1.25})), 9*time.Hour, ist))
+	s.Close()
+
+	want := "ACTION,Consignment,Truck,Arrive\nUPSERT,A,V1,15/10/2026 10:15\nDELETE,B,,\n"
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || bodies[0] != want {
+		t.Errorf("delivered %q, want %q", bodies, want)
+	}
+	if st := s.Stats(); st.Delivered != 2 || st.Failed != 0 || st.LastDelivery.IsZero() {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestItemsWaitOutAnOutageAndARestart(t *testing.T) {
+	var mu sync.Mutex
+	down, bodies := true, []string{}
+	tms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer tms.Close()
+	c := Connector{Name: "tms", Kind: KindHTTP, URL: tms.URL, Format: FormatNDJSON, Fields: []Field{{Name: "id", Template: "{{.ShipmentID}}"}}}
+	dir := t.TempDir()
+
+	s, err := Start(c, nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backoff = time.Millisecond
+	s.Send(Changes(nil, board(1, run("V1", dispatch.Stop{ID: "A"})), 9*time.Hour, ist))
+	s.Send(Changes(nil, board(1, run("V1", dispatch.Stop{ID: "B"})), 9*time.Hour, ist))
+	s.Close()
+	if st := s.Stats(); st.Delivered != 0 || st.Failed != 0 || st.Pending != 2 || !strings.Contains(st.LastError, "502") {
+		t.Errorf("stats while down = %+v", st)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	if s, err = Start(c, nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if st := s.Stats(); st.Delivered != 2 || st.Pending != 0 {
+		t.Errorf("stats after restart = %+v", st)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"{\"id\":\"A\"}\n", "{\"id\":\"B\"}\n"}; !slices.Equal(bodies, want) {
+		t.Errorf("delivered %q, want %q", bodies, want)
+	}
+}
+
+type recordingBroker struct{ msgs []string }
+
+func (b *recordingBroker) Publish(_ context.Context, topic string, msg []byte) error {
+	b.msgs = append(b.msgs, topic+" "+string(msg))
+	return nil
+}
+func (b *recordingBroker) Subscribe(context.Context, string, func([]byte)) error { return nil }
+func (b *recordingBroker) Close() error                                          { return nil }
+
+func TestBrokerConnectorPublishesAnItemPerMessage(t *testing.T) {
+	b := &recordingBroker{}
+	wms := Connector{Name: "wms", Kind: KindBroker, Topic: "wms.lines", Fields: []Field{
+		{Name: "sku_ref", Template: "{{.ShipmentID}}"},
+		{Name: "seq", Template: "{{.Seq}}", Number: true},
+	}}
+	dir := t.TempDir()
+	if _, err := Start(wms, nil, dir); err == nil {
+		t.Error("broker connector started without a broker")
+	}
+	s, err := Start(wms, b, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Send(Changes(nil, board(1, run("V1", dispatch.Stop{ID: "A"}, dispatch.Stop{ID: "B"})), 9*time.Hour, ist))
+	s.Close()
+	want := `wms.lines {"sku_ref":"A","seq":1}|wms.lines {"sku_ref":"B","seq":2}`
+	if got := strings.Join(b.msgs, "|"); got != want {
+		t.Errorf("published %s", got)
+	}
+
+	for _, c := range []Connector{
+		{Name: "Bad Name", Kind: KindHTTP, URL: "https://tms.example"},
+		{Name: "ftp", Kind: KindHTTP, URL: "ftp://tms.example"},
+		{Name: "csv", Kind: KindBroker, Topic: "t", Format: FormatCSV},
+		{Name: "tpl", Kind: KindHTTP, URL: "https://tms.example", Fields: []Field{{Name: "x", Template: "{{.Nope"}}},
+	} {
+		if _, err := Start(c, b, dir); err == nil {
+			t.Errorf("%s started", c.Name)
+		}
+	}
+}
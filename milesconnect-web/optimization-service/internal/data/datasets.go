@@ -0,0 +1,62 @@
+package data
+
+import (
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"sort"
+	"sync"
+)
+
+var (
+	datasetsMu sync.RWMutex
+	datasets   = map[string][]models.NamedLocation{
+		"india-cities":  IndianCities,
+		"districts":     DistrictHQs,
+		"regional-hubs": RegionalHubs,
+	}
+)
+
+func init() {
+	pins, err := parseCSV(PincodeFile())
+	if err != nil {
+		panic(fmt.Sprintf("data: embedded pincodes.csv: %v", err))
+	}
+	datasets["pincodes"] = pins
+}
+
+// RegisterDataset adds or replaces a named point set
+func RegisterDataset(name string, points []models.NamedLocation) {
+	datasetsMu.Lock()
+	defer datasetsMu.Unlock()
+	datasets[name] = points
+}
+
+// Dataset returns a named point set
+func Dataset(name string) ([]models.NamedLocation, bool) {
+	datasetsMu.RLock()
+	defer datasetsMu.RUnlock()
+	pts, ok := datasets[name]
+	return pts, ok
+}
+
+// DatasetNames lists every registered dataset, sorted
+func DatasetNames() []string {
+	datasetsMu.RLock()
+	defer datasetsMu.RUnlock()
+
+	names := make([]string, 0, len(datasets))
+	for n := range datasets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Locations strips names from a point set
+func Locations(points []models.NamedLocation) []models.Location {
+	locs := make([]models.Location, len(points))
+	for i, c := range points {
+		locs[i] = models.Location{Lat: c.Lat, Lng: c.Lng}
+	}
+	return locs
+}
@@ -0,0 +1,67 @@
+package data
+
+import "milesconnect-optimization/internal/models"
+
+// DistrictHQs is a sample of district headquarters beyond the major-city list,
+// giving denser coverage of tier-2 and tier-3 towns
+var DistrictHQs = []models.NamedLocation{
+	// North
+	{Name: "Ambala", Lat: 30.3782, Lng: 76.7767},
+	{Name: "Bathinda", Lat: 30.2110, Lng: 74.9455},
+	{Name: "Bikaner", Lat: 28.0229, Lng: 73.3119},
+	{Name: "Ajmer", Lat: 26.4499, Lng: 74.6399},
+	{Name: "Udaipur", Lat: 24.5854, Lng: 73.7125},
+	{Name: "Bareilly", Lat: 28.3670, Lng: 79.4304},
+	{Name: "Gorakhpur", Lat: 26.7606, Lng: 83.3732},
+	{Name: "Jhansi", Lat: 25.4484, Lng: 78.5685},
+	{Name: "Dehradun", Lat: 30.3165, Lng: 78.0322},
+	{Name: "Shimla", Lat: 31.1048, Lng: 77.1734},
+	{Name: "Jammu", Lat: 32.7266, Lng: 74.8570},
+	{Name: "Hisar", Lat: 29.1492, Lng: 75.7217},
+	{Name: "Aligarh", Lat: 27.8974, Lng: 78.0880},
+	{Name: "Moradabad", Lat: 28.8386, Lng: 78.7733},
+
+	// West
+	{Name: "Kolhapur", Lat: 16.7050, Lng: 74.2433},
+	{Name: "Solapur", Lat: 17.6599, Lng: 75.9064},
+	{Name: "Amravati", Lat: 20.9374, Lng: 77.7796},
+	{Name: "Jalgaon", Lat: 21.0077, Lng: 75.5626},
+	{Name: "Bhavnagar", Lat: 21.7645, Lng: 72.1519},
+	{Name: "Jamnagar", Lat: 22.4707, Lng: 70.0577},
+	{Name: "Bhuj", Lat: 23.2420, Lng: 69.6669},
+	{Name: "Panaji", Lat: 15.4909, Lng: 73.8278},
+	{Name: "Ratnagiri", Lat: 16.9902, Lng: 73.3120},
+
+	// South
+	{Name: "Mangalore", Lat: 12.9141, Lng: 74.8560},
+	{Name: "Hubli", Lat: 15.3647, Lng: 75.1240},
+	{Name: "Belgaum", Lat: 15.8497, Lng: 74.4977},
+	{Name: "Tiruchirappalli", Lat: 10.7905, Lng: 78.7047},
+	{Name: "Salem", Lat: 11.6643, Lng: 78.1460},
+	{Name: "Tirunelveli", Lat: 8.7139, Lng: 77.7567},
+	{Name: "Kozhikode", Lat: 11.2588, Lng: 75.7804},
+	{Name: "Thrissur", Lat: 10.5276, Lng: 76.2144},
+	{Name: "Guntur", Lat: 16.3067, Lng: 80.4365},
+	{Name: "Nellore", Lat: 14.4426, Lng: 79.9865},
+	{Name: "Warangal", Lat: 17.9689, Lng: 79.5941},
+	{Name: "Kurnool", Lat: 15.8281, Lng: 78.0373},
+	{Name: "Puducherry", Lat: 11.9416, Lng: 79.8083},
+
+	// East, Central & North-East
+	{Name: "Cuttack", Lat: 20.4625, Lng: 85.8830},
+	{Name: "Sambalpur", Lat: 21.4669, Lng: 83.9812},
+	{Name: "Gaya", Lat: 24.7914, Lng: 85.0002},
+	{Name: "Muzaffarpur", Lat: 26.1209, Lng: 85.3647},
+	{Name: "Bhagalpur", Lat: 25.2425, Lng: 86.9842},
+	{Name: "Jamshedpur", Lat: 22.8046, Lng: 86.2029},
+	{Name: "Siliguri", Lat: 26.7271, Lng: 88.3953},
+	{Name: "Durgapur", Lat: 23.5204, Lng: 87.3119},
+	{Name: "Bilaspur", Lat: 22.0797, Lng: 82.1409},
+	{Name: "Sagar", Lat: 23.8388, Lng: 78.7378},
+	{Name: "Ujjain", Lat: 23.1765, Lng: 75.7885},
+	{Name: "Shillong", Lat: 25.5788, Lng: 91.8933},
+	{Name: "Imphal", Lat: 24.8170, Lng: 93.9368},
+	{Name: "Agartala", Lat: 23.8315, Lng: 91.2868},
+	{Name: "Dibrugarh", Lat: 27.4728, Lng: 94.9120},
+	{Name: "Silchar", Lat: 24.8333, Lng: 92.7789},
+}
@@ -63,9 +63,5 @@ var IndianCities = []models.NamedLocation{
 }
 
 func GetAllIndiaLocations() []models.Location {
-	locs := make([]models.Location, len(IndianCities))
-	for i, c := range IndianCities {
-		locs[i] = models.Location{Lat: c.Lat, Lng: c.Lng}
-	}
-	return locs
+	return Locations(IndianCities)
 }
@@ -0,0 +1,145 @@
+package data
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"milesconnect-optimization/internal/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//go:embed files
+var embedded embed.FS
+
+func mustOpenEmbedded(name string) io.Reader {
+	f, err := embedded.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// PincodeFile opens the built-in pincode centroid CSV
+func PincodeFile() io.Reader {
+	return mustOpenEmbedded("files/pincodes.csv")
+}
+
+// TollPlazaFile opens the built-in toll plaza CSV: a sample of national
+// highway plazas with approximate locations and single-journey rates by
+// vehicle class, to be replaced by a current NHAI export in production
+func TollPlazaFile() io.Reader {
+	return mustOpenEmbedded("files/toll_plazas.csv")
+}
+
+// LoadFile reads a CSV (name,lat,lng columns) or GeoJSON FeatureCollection of
+// Points and registers it under the file's base name
+func LoadFile(path string) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	var points []models.NamedLocation
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		points, err = parseCSV(f)
+	case ".geojson", ".json":
+		points, err = parseGeoJSON(f)
+	default:
+		return "", 0, fmt.Errorf("unsupported dataset format %q", ext)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	RegisterDataset(name, points)
+	return name, len(points), nil
+}
+
+// parseCSV expects a header row containing name, lat and lng (any order,
+// extra columns ignored)
+func parseCSV(r io.Reader) ([]models.NamedLocation, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	nameCol, okName := col["name"]
+	latCol, okLat := col["lat"]
+	lngCol, okLng := col["lng"]
+	if !okName || !okLat || !okLng {
+		return nil, errors.New("CSV header must contain name, lat and lng")
+	}
+
+	var points []models.NamedLocation
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lat, err1 := strconv.ParseFloat(rec[latCol], 64)
+		lng, err2 := strconv.ParseFloat(rec[lngCol], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("line %d: invalid coordinates", line)
+		}
+		points = append(points, models.NamedLocation{Name: rec[nameCol], Lat: lat, Lng: lng})
+	}
+	return points, nil
+}
+
+type geoJSONCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"` // Shape depends on Type
+
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+// parseGeoJSON reads Point features; GeoJSON orders coordinates lng, lat
+func parseGeoJSON(r io.Reader) ([]models.NamedLocation, error) {
+	var fc geoJSONCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, err
+	}
+
+	var points []models.NamedLocation
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" {
+			continue
+		}
+		var coords []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &coords); err != nil || len(coords) < 2 {
+			return nil, fmt.Errorf("feature %d: invalid Point coordinates", i)
+		}
+		name, _ := f.Properties["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("feature-%d", i)
+		}
+		points = append(points, models.NamedLocation{
+			Name: name,
+			Lat:  coords[1],
+			Lng:  coords[0],
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVIgnoresExtraColumns(t *testing.T) {
+	pts, err := parseCSV(strings.NewReader("id,lng,name,lat\n1,77.2,Delhi,28.6\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 1 || pts[0].Name != "Delhi" || pts[0].Lat != 28.6 || pts[0].Lng != 77.2 {
+		t.Errorf("got %+v", pts)
+	}
+}
+
+func TestParseCSVRejectsMissingColumns(t *testing.T) {
+	if _, err := parseCSV(strings.NewReader("name,lat\nDelhi,28.6\n")); err == nil {
+		t.Error("expected an error for a header without lng")
+	}
+}
+
+func TestLoadFileGeoJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "depots.geojson")
+	body := `{"type":"FeatureCollection","features":[
+		{"geometry":{"type":"Point","coordinates":[72.88,19.08]},"properties":{"name":"Mumbai"}},
+		{"geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]}}
+	]}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	name, n, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "depots" || n != 1 {
+		t.Fatalf("got %q with %d points, want depots with 1", name, n)
+	}
+	pts, _ := Dataset("depots")
+	if pts[0].Lat != 19.08 || pts[0].Lng != 72.88 {
+		t.Errorf("coordinates not swapped from GeoJSON order: %+v", pts[0])
+	}
+}
+
+func TestEmbeddedPincodesLoaded(t *testing.T) {
+	if pts, ok := Dataset("pincodes"); !ok || len(pts) == 0 {
+		t.Error("pincodes dataset is empty")
+	}
+}
@@ -0,0 +1,33 @@
+package data
+
+import "milesconnect-optimization/internal/models"
+
+// RegionalHubs covers logistics hubs in neighbouring South Asian and ASEAN
+// countries for cross-border planning
+var RegionalHubs = []models.NamedLocation{
+	// South Asia
+	{Name: "Kathmandu", Lat: 27.7172, Lng: 85.3240},
+	{Name: "Birgunj", Lat: 27.0104, Lng: 84.8770},
+	{Name: "Thimphu", Lat: 27.4728, Lng: 89.6390},
+	{Name: "Phuentsholing", Lat: 26.8516, Lng: 89.3884},
+	{Name: "Dhaka", Lat: 23.8103, Lng: 90.4125},
+	{Name: "Chittagong", Lat: 22.3569, Lng: 91.7832},
+	{Name: "Colombo", Lat: 6.9271, Lng: 79.8612},
+
+	// ASEAN
+	{Name: "Yangon", Lat: 16.8409, Lng: 96.1735},
+	{Name: "Mandalay", Lat: 21.9588, Lng: 96.0891},
+	{Name: "Bangkok", Lat: 13.7563, Lng: 100.5018},
+	{Name: "Chiang Mai", Lat: 18.7883, Lng: 98.9853},
+	{Name: "Vientiane", Lat: 17.9757, Lng: 102.6331},
+	{Name: "Phnom Penh", Lat: 11.5564, Lng: 104.9282},
+	{Name: "Hanoi", Lat: 21.0278, Lng: 105.8342},
+	{Name: "Ho Chi Minh City", Lat: 10.8231, Lng: 106.6297},
+	{Name: "Kuala Lumpur", Lat: 3.1390, Lng: 101.6869},
+	{Name: "Penang", Lat: 5.4141, Lng: 100.3288},
+	{Name: "Singapore", Lat: 1.3521, Lng: 103.8198},
+	{Name: "Jakarta", Lat: -6.2088, Lng: 106.8456},
+	{Name: "Surabaya", Lat: -7.2575, Lng: 112.7521},
+	{Name: "Manila", Lat: 14.5995, Lng: 120.9842},
+	{Name: "Bandar Seri Begawan", Lat: 4.9031, Lng: 114.9398},
+}
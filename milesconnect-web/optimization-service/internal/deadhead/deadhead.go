@@ -0,0 +1,137 @@
+// Package deadhead measures the empty running in dispatched plans and pairs
+// runs so a vehicle carries another run's loads instead of driving home
+// empty.
+package deadhead
+
+import (
+	"cmp"
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"time"
+)
+
+// DefaultMinSavingKm is the least empty running a pairing must cut to be
+// suggested; below it the rearrangement is not worth a dispatcher's time
+const DefaultMinSavingKm = 20
+
+// run is a dispatched run with its empty running
+type run struct {
+	date     string
+	day      time.Time
+	r        dispatch.Run
+	deadhead float64
+	homeward bool // Empty on the way back from its last stop
+}
+
+// emptyKm returns the km r drives empty, judged from what it carries: a run
+// that only delivers comes back empty from its last stop, one that only
+// collects returns goes out empty to its first, and one with nothing on
+// board is empty throughout. Runs doing both are taken as never empty.
+func emptyKm(r dispatch.Run) (km float64, homeward bool) {
+	if len(r.Stops) == 0 {
+		return 0, false
+	}
+	switch {
+	case r.LoadKg == 0 && r.ReturnKg == 0:
+		return r.DistanceKm, false
+	case r.ReturnKg == 0:
+		return problem.Haversine(r.Stops[len(r.Stops)-1].Location, r.End), true
+	case r.LoadKg == 0:
+		return problem.Haversine(r.Start, r.Stops[0].Location), false
+	}
+	return 0, false
+}
+
+// Report measures the empty running in boards, which cover from through to,
+// and suggests pairings that save at least minSavingKm
+func Report(boards []dispatch.Board, from, to string, minSavingKm float64) models.DeadheadReport {
+	rep := models.DeadheadReport{From: from, To: to, Days: []models.DeadheadDay{}, Runs: []models.DeadheadRun{}}
+	var runs []run
+	for _, b := range boards {
+		day := models.DeadheadDay{Date: b.Date}
+		date, _ := time.Parse(time.DateOnly, b.Date)
+		for _, r := range b.Runs {
+			km, homeward := emptyKm(r)
+			runs = append(runs, run{date: b.Date, day: date, r: r, deadhead: km, homeward: homeward})
+			rep.Runs = append(rep.Runs, models.DeadheadRun{
+				Date:        b.Date,
+				VehicleID:   r.VehicleID,
+				DistanceKm:  r.DistanceKm,
+				DeadheadKm:  round(km),
+				DeadheadPct: pct(km, r.DistanceKm),
+			})
+			day.DistanceKm += r.DistanceKm
+			day.DeadheadKm += km
+		}
+		rep.DistanceKm += day.DistanceKm
+		rep.DeadheadKm += day.DeadheadKm
+		day.DeadheadPct = pct(day.DeadheadKm, day.DistanceKm)
+		day.DistanceKm, day.DeadheadKm = round(day.DistanceKm), round(day.DeadheadKm)
+		rep.Days = append(rep.Days, day)
+	}
+	rep.DeadheadPct = pct(rep.DeadheadKm, rep.DistanceKm)
+	rep.DistanceKm, rep.DeadheadKm = round(rep.DistanceKm), round(rep.DeadheadKm)
+	rep.Pairings = pairings(runs, minSavingKm)
+	return rep
+}
+
+// pairings matches runs that come back empty, greedily by the empty km
+// saved: a's vehicle drives from its last stop to where b starts, carries
+// b's loads the same or the next day and goes home from b's last stop,
+// while b's vehicle stays put
+func pairings(runs []run, minSavingKm float64) []models.BackhaulPairing {
+	type pair struct {
+		a, b         int
+		paired, save float64
+	}
+	var pairs []pair
+	for i, a := range runs {
+		if !a.homeward {
+			continue
+		}
+		last := a.r.Stops[len(a.r.Stops)-1].Location
+		for j, b := range runs {
+			gap := b.day.Sub(a.day)
+			if i == j || !b.homeward || gap < 0 || gap > 24*time.Hour || b.r.VehicleID == a.r.VehicleID {
+				continue
+			}
+			paired := problem.Haversine(last, b.r.Start) + problem.Haversine(b.r.Stops[len(b.r.Stops)-1].Location, a.r.End)
+			if save := a.deadhead + b.deadhead - paired; save >= minSavingKm {
+				pairs = append(pairs, pair{i, j, paired, save})
+			}
+		}
+	}
+	slices.SortStableFunc(pairs, func(x, y pair) int { return cmp.Compare(y.save, x.save) })
+
+	out := []models.BackhaulPairing{}
+	used := make([]bool, len(runs))
+	for _, p := range pairs {
+		if used[p.a] || used[p.b] {
+			continue
+		}
+		used[p.a], used[p.b] = true, true
+		a, b := runs[p.a], runs[p.b]
+		out = append(out, models.BackhaulPairing{
+			Date:            a.date,
+			VehicleID:       a.r.VehicleID,
+			PairedDate:      b.date,
+			PairedVehicleID: b.r.VehicleID,
+			DeadheadKm:      round(a.deadhead + b.deadhead),
+			PairedKm:        round(p.paired),
+			SavedKm:         round(p.save),
+		})
+	}
+	return out
+}
+
+func pct(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return round(part / whole * 100)
+}
+
+func round(x float64) float64 { return math.Round(x*100) / 100 }
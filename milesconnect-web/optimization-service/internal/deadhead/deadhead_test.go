@@ -0,0 +1,49 @@
+package deadhead
+
+import (
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func TestReportPairsOppositeLanes(t *testing.T) {
+	delhi, jaipur := models.Location{Lat: 28.6, Lng: 77.2}, models.Location{Lat: 26.9, Lng: 75.8}
+	nearJaipur, nearDelhi := models.Location{Lat: 26.95, Lng: 75.85}, models.Location{Lat: 28.55, Lng: 77.15}
+	outbound := func(vehicle string, depot, drop models.Location, load, returns float64) dispatch.Run {
+		return dispatch.Run{
+			VehicleID: vehicle, DistanceKm: 560, LoadKg: load, ReturnKg: returns,
+			Start: depot, End: depot, Stops: []dispatch.Stop{{ID: vehicle + "-1", Location: drop}},
+		}
+	}
+	boards := []dispatch.Board{
+		{Date: "2026-11-02", Runs: []dispatch.Run{outbound("DL1", delhi, nearJaipur, 900, 0), outbound("DL2", delhi, nearJaipur, 400, 300)}},
+		{Date: "2026-11-03", Runs: []dispatch.Run{outbound("RJ1", jaipur, nearDelhi, 700, 0)}},
+		{Date: "2026-11-05", Runs: []dispatch.Run{outbound("RJ2", jaipur, nearDelhi, 700, 0)}}, // Too late to pair with DL1
+	}
+	rep := Report(boards, "2026-11-01", "2026-11-05", DefaultMinSavingKm)
+
+	if len(rep.Runs) != 4 || rep.Runs[0].DeadheadKm < 200 || rep.Runs[1].DeadheadKm != 0 {
+		t.Fatalf("runs = %+v, want DL1 empty on the way home and DL2 full both ways", rep.Runs)
+	}
+	if len(rep.Days) != 3 || rep.DeadheadKm != rep.Days[0].DeadheadKm+rep.Days[1].DeadheadKm+rep.Days[2].DeadheadKm {
+		t.Errorf("days = %+v, total %v", rep.Days, rep.DeadheadKm)
+	}
+	if rep.DeadheadPct <= 0 || rep.DeadheadPct >= 100 {
+		t.Errorf("deadhead pct = %v", rep.DeadheadPct)
+	}
+	if len(rep.Pairings) != 1 {
+		t.Fatalf("pairings = %+v, want one", rep.Pairings)
+	}
+	p := rep.Pairings[0]
+	if p.VehicleID != "DL1" || p.PairedVehicleID != "RJ1" || p.PairedDate != "2026-11-03" {
+		t.Errorf("pairing = %+v, want DL1 carrying RJ1's loads the next day", p)
+	}
+	if p.PairedKm > 20 || p.SavedKm < 400 || math.Abs(p.SavedKm-(p.DeadheadKm-p.PairedKm)) > 0.02 {
+		t.Errorf("pairing km = %+v", p)
+	}
+
+	if rep := Report(boards, "2026-11-01", "2026-11-05", 1e6); len(rep.Pairings) != 0 {
+		t.Errorf("pairings below the minimum saving: %+v", rep.Pairings)
+	}
+}
@@ -0,0 +1,87 @@
+// Package density buckets dispatched stops into geohash cells, so the areas
+// that take the most deliveries day after day stand out as candidates for
+// a new hub or a dedicated route.
+package density
+
+import (
+	"cmp"
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+	"slices"
+	"strings"
+)
+
+// DefaultPrecision is the geohash length cells default to, about 5 km a
+// side: a delivery area one vehicle could serve
+const DefaultPrecision = 5
+
+// cell accumulates one geohash's stops
+type cell struct {
+	stops    int
+	lat, lng float64 // Sums, for the centroid
+	days     map[string]bool
+	vehicles map[string]bool
+}
+
+// Grid buckets the stops on boards, which cover from through to, into
+// geohash cells of precision characters and returns the cells with at
+// least minStops stops, busiest first. Stops without a location are left
+// out.
+func Grid(boards []dispatch.Board, from, to string, precision, minStops int) models.DensityGrid {
+	grid := models.DensityGrid{Type: "FeatureCollection", From: from, To: to, Precision: precision, Features: []models.DensityCell{}}
+	cells := map[string]*cell{}
+	for _, b := range boards {
+		grid.Days++
+		for _, r := range b.Runs {
+			for _, st := range r.Stops {
+				if st.Location == (models.Location{}) {
+					continue
+				}
+				hash := geo.EncodeGeohash(st.Location, precision)
+				c := cells[hash]
+				if c == nil {
+					c = &cell{days: map[string]bool{}, vehicles: map[string]bool{}}
+					cells[hash] = c
+				}
+				c.stops++
+				c.lat += st.Location.Lat
+				c.lng += st.Location.Lng
+				c.days[b.Date] = true
+				c.vehicles[r.VehicleID] = true
+				grid.Stops++
+			}
+		}
+	}
+
+	for hash, c := range cells {
+		if c.stops < minStops {
+			continue
+		}
+		sw, ne, _ := geo.GeohashBounds(hash)
+		grid.Features = append(grid.Features, models.DensityCell{
+			Type: "Feature",
+			Geometry: models.Polygon{Type: "Polygon", Coordinates: [][][2]float64{{
+				{sw.Lng, sw.Lat}, {ne.Lng, sw.Lat}, {ne.Lng, ne.Lat}, {sw.Lng, ne.Lat}, {sw.Lng, sw.Lat},
+			}}},
+			Properties: models.DensityCellKPI{
+				Geohash:     hash,
+				Stops:       c.stops,
+				SharePct:    round(100 * float64(c.stops) / float64(grid.Stops)),
+				StopsPerDay: round(float64(c.stops) / float64(grid.Days)),
+				Days:        len(c.days),
+				Vehicles:    len(c.vehicles),
+				Centroid:    models.Location{Lat: c.lat / float64(c.stops), Lng: c.lng / float64(c.stops)},
+			},
+		})
+	}
+	slices.SortFunc(grid.Features, func(a, b models.DensityCell) int {
+		return cmp.Or(cmp.Compare(b.Properties.Stops, a.Properties.Stops), strings.Compare(a.Properties.Geohash, b.Properties.Geohash))
+	})
+	return grid
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
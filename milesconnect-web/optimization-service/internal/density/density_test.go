@@ -0,0 +1,47 @@
+package density
+
+import (
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func TestGridBucketsStopsBusiestFirst(t *testing.T) {
+	// Two stops a few hundred metres apart in Andheri, one in Thane
+	andheri, andheri2 := models.Location{Lat: 19.1197, Lng: 72.8468}, models.Location{Lat: 19.1210, Lng: 72.8480}
+	thane := models.Location{Lat: 19.2183, Lng: 72.9781}
+	run := func(vehicle string, stops ...models.Location) dispatch.Run {
+		r := dispatch.Run{VehicleID: vehicle}
+		for _, l := range stops {
+			r.Stops = append(r.Stops, dispatch.Stop{ID: vehicle, Location: l})
+		}
+		return r
+	}
+	boards := []dispatch.Board{
+		{Date: "2026-11-02", Runs: []dispatch.Run{run("V1", andheri, thane), run("V2", andheri2, models.Location{})}},
+		{Date: "2026-11-03", Runs: []dispatch.Run{run("V1", andheri)}},
+	}
+	grid := Grid(boards, "2026-11-01", "2026-11-03", DefaultPrecision, 1)
+
+	if grid.Type != "FeatureCollection" || grid.Days != 2 || grid.Stops != 4 {
+		t.Fatalf("grid = %+v, want 4 located stops over 2 days", grid)
+	}
+	if len(grid.Features) != 2 {
+		t.Fatalf("features = %+v, want Andheri and Thane", grid.Features)
+	}
+	busiest := grid.Features[0].Properties
+	if busiest.Stops != 3 || busiest.Days != 2 || busiest.Vehicles != 2 || busiest.SharePct != 75 || busiest.StopsPerDay != 1.5 {
+		t.Errorf("busiest = %+v", busiest)
+	}
+	ring := grid.Features[0].Geometry.Coordinates[0]
+	if len(ring) != 5 || ring[0] != ring[4] {
+		t.Fatalf("ring = %v, want a closed rectangle", ring)
+	}
+	if c := busiest.Centroid; c.Lng < ring[0][0] || c.Lng > ring[2][0] || c.Lat < ring[0][1] || c.Lat > ring[2][1] {
+		t.Errorf("centroid %+v outside the cell %v", c, ring)
+	}
+
+	if grid := Grid(boards, "2026-11-01", "2026-11-03", DefaultPrecision, 2); len(grid.Features) != 1 || grid.Stops != 4 {
+		t.Errorf("min stops 2: %+v", grid)
+	}
+}
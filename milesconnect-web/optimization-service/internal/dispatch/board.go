@@ -0,0 +1,549 @@
+// Package dispatch holds the plans each tenant dispatched for each day and
+// the live status of every stop, which is what a dispatcher's board shows.
+// State is kept in memory.
+//
+// Every change to a board raises its version. Dispatchers' edits name the
+// version they read, and fail with ErrVersion if the board has changed
+// since, so two people editing one plan cannot silently overwrite each
+// other. Drivers' reports do not depend on what they read and always apply.
+package dispatch
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is where a stop or a vehicle's run stands
+type Status string
+
+const (
+	Pending   Status = "pending"
+	EnRoute   Status = "en_route"
+	Completed Status = "completed"
+)
+
+var (
+	ErrUnknown    = errors.New("dispatch: unknown date, vehicle or stop")
+	ErrTransition = errors.New("dispatch: status change not allowed")
+	ErrStarted    = errors.New("dispatch: the day's plan is already under way")
+	ErrInvalid    = errors.New("dispatch: invalid report")
+	ErrVersion    = errors.New("dispatch: the plan has changed since it was read")
+)
+
+// order ranks statuses; a stop only moves forward
+var order = map[Status]int{Pending: 0, EnRoute: 1, Completed: 2}
+
+// Stop is one delivery on a run
+type Stop struct {
+	ID        string          `json:"id"`
+	Location  models.Location `json:"location"`
+	ETAHours  float64         `json:"eta_hours,omitempty"`
+	DueHours  float64         `json:"due_hours,omitempty"`
+	Status    Status          `json:"status"`
+	UpdatedAt time.Time       `json:"updated_at,omitzero"`
+	Customer  string          `json:"customer,omitempty"`
+	City      string          `json:"city,omitempty"`
+
+	// Reported by the driver app
+	ArrivedAt  time.Time `json:"arrived_at,omitzero"`
+	DepartedAt time.Time `json:"departed_at,omitzero"`
+	Track      *Track    `json:"track,omitempty"` // Path driven here
+}
+
+// Track is the path a driver reported driving to a stop, matched to roads
+// where possible so it can be compared with the plan's distance
+type Track struct {
+	Polyline string  `json:"polyline"`  // Encoded, precision 5
+	DrivenKm float64 `json:"driven_km"` // Along the matched path
+	RawKm    float64 `json:"raw_km"`    // Along the fixes as reported, GPS noise included
+	Source   string  `json:"source"`    // Provider that matched it, or denoised with the reason
+}
+
+// Run is one vehicle's route for the day. Its status follows its stops:
+// pending until one starts, completed once all are.
+type Run struct {
+	VehicleID  string  `json:"vehicle_id"`
+	Status     Status  `json:"status"`
+	DistanceKm float64 `json:"distance_km"`
+	LoadKg     float64 `json:"load_kg"`
+	ReturnKg   float64 `json:"return_kg,omitempty"` // Returns it collects
+	Stops      []Stop  `json:"stops"`
+
+	// Where the vehicle leaves from and returns to
+	Start models.Location `json:"start,omitzero"`
+	End   models.Location `json:"end,omitzero"`
+}
+
+// Board is a tenant's dispatched plan for a day
+type Board struct {
+	Tenant      string         `json:"tenant,omitempty"`
+	Date        string         `json:"date"`
+	Runs        []Run          `json:"runs"`
+	Unassigned  []string       `json:"unassigned_stop_ids"`
+	Counts      map[Status]int `json:"counts"` // Stops per status
+	Issues      []Issue        `json:"issues,omitempty"`
+	PublishedAt time.Time      `json:"published_at"`
+	Version     int            `json:"version"` // Raised by every change
+}
+
+// Next returns the first stop on the run that is not completed
+func (r Run) Next() (Stop, bool) {
+	for _, st := range r.Stops {
+		if st.Status != Completed {
+			return st, true
+		}
+	}
+	return Stop{}, false
+}
+
+// Slot is a place a stop could be added to a run: the index in Stops it
+// would take and the km it adds
+type Slot struct {
+	At      int
+	AddedKm float64
+}
+
+// Insertions returns every place a stop at loc could be added to the run,
+// cheapest first by km. Stops already reached stay where they are, so
+// they all come after the last of them; there are none once the run is
+// completed.
+func (r Run) Insertions(loc models.Location, km func(a, b models.Location) float64) []Slot {
+	if r.Status == Completed {
+		return nil
+	}
+	first := 0
+	for i, st := range r.Stops {
+		if st.Status != Pending {
+			first = i + 1
+		}
+	}
+	slots := make([]Slot, 0, len(r.Stops)+1-first)
+	for i := first; i <= len(r.Stops); i++ {
+		prev, next := r.Start, r.End
+		if i > 0 {
+			prev = r.Stops[i-1].Location
+		}
+		if i < len(r.Stops) {
+			next = r.Stops[i].Location
+		}
+		slots = append(slots, Slot{At: i, AddedKm: km(prev, loc) + km(loc, next) - km(prev, next)})
+	}
+	slices.SortStableFunc(slots, func(a, b Slot) int { return cmp.Compare(a.AddedKm, b.AddedKm) })
+	return slots
+}
+
+// LateAfter returns the first stop from index at on that a delay of hours
+// would bring past its due time, judged by its ETA
+func (r Run) LateAfter(at int, hours float64) (Stop, bool) {
+	for _, st := range r.Stops[at:] {
+		if st.DueHours > 0 && st.ETAHours+hours > st.DueHours {
+			return st, true
+		}
+	}
+	return Stop{}, false
+}
+
+// Store holds the boards by tenant and date. It is safe for concurrent
+// use.
+type Store struct {
+	mu     sync.RWMutex
+	boards map[key]*Board
+	ids    []ref // Every stop and unassigned shipment by ID, for Search
+	now    func() time.Time
+}
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{boards: map[key]*Board{}, now: time.Now}
+}
+
+// key names a tenant's board for a date
+type key struct{ tenant, date string }
+
+// Publish makes routes tenant's plan for date. A plan can be replaced until one
+// of its stops has started. version is that of the plan being replaced, or
+// 0 when there should be none. Stops take their customer and city from
+// consignees.
+func (s *Store) Publish(tenant, date string, version int, routes []models.FleetRoute, unassigned []string, consignees []models.Consignee) (Board, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.boards[key{tenant, date}]
+	if version != versionOf(old) {
+		return Board{}, ErrVersion
+	}
+	if ok && old.Counts[Pending] != totalStops(old) {
+		return Board{}, ErrStarted
+	}
+
+	b := &Board{Tenant: tenant, Date: date, Runs: []Run{}, Unassigned: append([]string{}, unassigned...), PublishedAt: s.now().UTC(), Version: versionOf(old) + 1}
+	byStop := map[string]models.Consignee{}
+	for _, c := range consignees {
+		byStop[c.StopID] = c
+	}
+	for _, r := range routes {
+		run := Run{VehicleID: r.VehicleID, Status: Pending, DistanceKm: r.DistanceKm, LoadKg: r.LoadKg, ReturnKg: r.ReturnKg, Stops: []Stop{}}
+		if len(r.Route) > 0 {
+			run.Start, run.End = r.Route[0], r.Route[len(r.Route)-1]
+		}
+		for i, id := range r.StopIDs {
+			st := Stop{ID: id, Status: Pending, Customer: byStop[id].Name, City: byStop[id].City}
+			if i+1 < len(r.Route) {
+				st.Location = r.Route[i+1] // Route starts at the vehicle's start
+			}
+			if i < len(r.ArrivalHours) {
+				st.ETAHours = r.ArrivalHours[i]
+			}
+			if i < len(r.DueHours) {
+				st.DueHours = r.DueHours[i]
+			}
+			run.Stops = append(run.Stops, st)
+		}
+		b.Runs = append(b.Runs, run)
+	}
+	b.recount()
+	s.boards[key{tenant, date}] = b
+	s.index(b)
+	return b.clone(), nil
+}
+
+// Boards returns every tenant's plans for from through to (YYYY-MM-DD,
+// inclusive) in date then tenant order; callers keep those the caller may
+// read
+func (s *Store) Boards(from, to string) []Board {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var list []Board
+	for k, b := range s.boards {
+		if k.date >= from && k.date <= to {
+			list = append(list, b.clone())
+		}
+	}
+	slices.SortFunc(list, func(a, b Board) int {
+		return cmp.Or(strings.Compare(a.Date, b.Date), strings.Compare(a.Tenant, b.Tenant))
+	})
+	return list
+}
+
+// Validate checks an imported board's date and stop statuses
+func (b Board) Validate() error {
+	if _, err := time.Parse(time.DateOnly, b.Date); err != nil {
+		return fmt.Errorf("board date %q must be YYYY-MM-DD", b.Date)
+	}
+	for _, r := range b.Runs {
+		for _, st := range r.Stops {
+			if _, ok := order[st.Status]; !ok {
+				return fmt.Errorf("%w: unknown status %q", ErrTransition, st.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// Restore puts back a board as it was exported, progress and all,
+// replacing its tenant's plan for its date. Its version moves past both its
+// own and the replaced plan's, so edits based on either fail.
+func (s *Store) Restore(b Board) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	b = b.clone()
+	b.recount()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key{b.Tenant, b.Date}
+	b.Version = max(b.Version, versionOf(s.boards[k])) + 1
+	s.boards[k] = &b
+	s.index(&b)
+	return nil
+}
+
+// Board returns tenant's plan for date
+func (s *Store) Board(tenant, date string) (Board, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.boards[key{tenant, date}]
+	if !ok {
+		return Board{}, false
+	}
+	return b.clone(), true
+}
+
+// SetStatus moves a stop forward on the board at version and returns its
+// run and the board's new version. A vehicle is en route to at most one
+// stop at a time.
+func (s *Store) SetStatus(tenant, date string, version int, vehicleID, stopID string, status Status) (Run, int, error) {
+	if _, ok := order[status]; !ok {
+		return Run{}, 0, fmt.Errorf("%w: unknown status %q", ErrTransition, status)
+	}
+	return s.update(tenant, date, vehicleID, stopID, func(b *Board, run *Run, stop int) error {
+		if b.Version != version {
+			return ErrVersion
+		}
+		return s.move(run, stop, status)
+	})
+}
+
+// StatusChange is one stop's move in a bulk update. With no VehicleID the
+// stop is found on whichever run has it.
+type StatusChange struct {
+	VehicleID string
+	StopID    string
+	Status    Status
+}
+
+// SetStatuses applies changes to tenant's board for date at version, or at
+// any version if it is 0, in order and each on its own, so one that fails
+// leaves the rest applied. It returns each change's error and the board's
+// version, raised once if any applied.
+func (s *Store) SetStatuses(tenant, date string, version int, changes []StatusChange) ([]error, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.boards[key{tenant, date}]
+	if !ok {
+		return nil, 0, ErrUnknown
+	}
+	if version != 0 && b.Version != version {
+		return nil, 0, ErrVersion
+	}
+
+	errs := make([]error, len(changes))
+	applied := false
+	for i, c := range changes {
+		errs[i] = s.change(b, c)
+		applied = applied || errs[i] == nil
+	}
+	if applied {
+		b.recount()
+		b.Version++
+	}
+	return errs, b.Version, nil
+}
+
+// change applies one status change to b; callers hold the lock and
+// recount
+func (s *Store) change(b *Board, c StatusChange) error {
+	if _, ok := order[c.Status]; !ok {
+		return fmt.Errorf("%w: unknown status %q", ErrTransition, c.Status)
+	}
+	run, stop := b.find(c.VehicleID, c.StopID)
+	if run == nil {
+		return ErrUnknown
+	}
+	if err := s.move(run, stop, c.Status); err != nil {
+		return err
+	}
+	run.Status = runStatus(run.Stops)
+	return nil
+}
+
+// Run returns a vehicle's run on tenant's plan for date
+func (s *Store) Run(tenant, date, vehicleID string) (Run, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.boards[key{tenant, date}]
+	if !ok {
+		return Run{}, false
+	}
+	run := b.run(vehicleID)
+	if run == nil {
+		return Run{}, false
+	}
+	return cloneRun(*run), true
+}
+
+// Arrive records the vehicle reaching a stop, which puts the stop en route
+// if it was still pending
+func (s *Store) Arrive(tenant, date, vehicleID, stopID string) (Run, error) {
+	run, _, err := s.update(tenant, date, vehicleID, stopID, func(_ *Board, run *Run, stop int) error {
+		if st := run.Stops[stop]; !st.ArrivedAt.IsZero() {
+			return fmt.Errorf("%w: already arrived at %s", ErrTransition, st.ID)
+		}
+		if err := s.move(run, stop, EnRoute); err != nil {
+			return err
+		}
+		run.Stops[stop].ArrivedAt = s.now().UTC()
+		return nil
+	})
+	return run, err
+}
+
+// RecordTrack attaches the path driven to a stop
+func (s *Store) RecordTrack(tenant, date, vehicleID, stopID string, t Track) (Run, error) {
+	run, _, err := s.update(tenant, date, vehicleID, stopID, func(_ *Board, run *Run, stop int) error {
+		run.Stops[stop].Track = &t
+		return nil
+	})
+	return run, err
+}
+
+// Depart records the vehicle leaving a stop it arrived at, which completes
+// the stop
+func (s *Store) Depart(tenant, date, vehicleID, stopID string) (Run, error) {
+	run, _, err := s.update(tenant, date, vehicleID, stopID, func(_ *Board, run *Run, stop int) error {
+		st := run.Stops[stop]
+		if st.ArrivedAt.IsZero() {
+			return fmt.Errorf("%w: not arrived at %s", ErrTransition, st.ID)
+		}
+		if !st.DepartedAt.IsZero() {
+			return fmt.Errorf("%w: already departed from %s", ErrTransition, st.ID)
+		}
+		if err := s.move(run, stop, Completed); err != nil {
+			return err
+		}
+		run.Stops[stop].DepartedAt = s.now().UTC()
+		return nil
+	})
+	return run, err
+}
+
+// update applies fn to a stop under the lock, refreshes the run status and
+// counts and raises the board's version, which it returns with the run
+func (s *Store) update(tenant, date, vehicleID, stopID string, fn func(b *Board, run *Run, stop int) error) (Run, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.boards[key{tenant, date}]
+	if !ok {
+		return Run{}, 0, ErrUnknown
+	}
+	run := b.run(vehicleID)
+	if run == nil {
+		return Run{}, 0, ErrUnknown
+	}
+	stop := run.stop(stopID)
+	if stop < 0 {
+		return Run{}, 0, ErrUnknown
+	}
+
+	if err := fn(b, run, stop); err != nil {
+		return Run{}, 0, err
+	}
+	run.Status = runStatus(run.Stops)
+	b.recount()
+	b.Version++
+	return cloneRun(*run), b.Version, nil
+}
+
+// move sets a stop's status. A vehicle is en route to at most one stop.
+func (s *Store) move(run *Run, stop int, status Status) error {
+	st := &run.Stops[stop]
+	if order[status] < order[st.Status] {
+		return fmt.Errorf("%w: stop %s is already %s", ErrTransition, st.ID, st.Status)
+	}
+	if status == EnRoute && st.Status != EnRoute {
+		for _, other := range run.Stops {
+			if other.Status == EnRoute {
+				return fmt.Errorf("%w: vehicle %s is en route to %s", ErrTransition, run.VehicleID, other.ID)
+			}
+		}
+	}
+	if status != st.Status {
+		st.Status = status
+		st.UpdatedAt = s.now().UTC()
+	}
+	return nil
+}
+
+func (b *Board) run(vehicleID string) *Run {
+	for i := range b.Runs {
+		if b.Runs[i].VehicleID == vehicleID {
+			return &b.Runs[i]
+		}
+	}
+	return nil
+}
+
+// find returns the run and index of a stop on vehicleID's run or, with no
+// vehicle, on the first run that has it
+func (b *Board) find(vehicleID, stopID string) (*Run, int) {
+	for i := range b.Runs {
+		run := &b.Runs[i]
+		if vehicleID != "" && run.VehicleID != vehicleID {
+			continue
+		}
+		if stop := run.stop(stopID); stop >= 0 {
+			return run, stop
+		}
+	}
+	return nil, -1
+}
+
+func (r *Run) stop(id string) int {
+	for i, st := range r.Stops {
+		if st.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *Board) recount() {
+	b.Counts = map[Status]int{Pending: 0, EnRoute: 0, Completed: 0}
+	for _, r := range b.Runs {
+		for _, st := range r.Stops {
+			b.Counts[st.Status]++
+		}
+	}
+}
+
+func (b *Board) clone() Board {
+	c := *b
+	c.Runs = make([]Run, len(b.Runs))
+	for i, r := range b.Runs {
+		c.Runs[i] = cloneRun(r)
+	}
+	c.Unassigned = append([]string{}, b.Unassigned...)
+	c.Issues = append([]Issue(nil), b.Issues...)
+	c.Counts = make(map[Status]int, len(b.Counts))
+	for k, v := range b.Counts {
+		c.Counts[k] = v
+	}
+	return c
+}
+
+func cloneRun(r Run) Run {
+	r.Stops = append([]Stop{}, r.Stops...)
+	return r
+}
+
+func runStatus(stops []Stop) Status {
+	done, started := 0, false
+	for _, st := range stops {
+		if st.Status == Completed {
+			done++
+		}
+		if st.Status != Pending {
+			started = true
+		}
+	}
+	switch {
+	case done == len(stops):
+		return Completed
+	case started:
+		return EnRoute
+	default:
+		return Pending
+	}
+}
+
+// versionOf returns b's version, or 0 for no board
+func versionOf(b *Board) int {
+	if b == nil {
+		return 0
+	}
+	return b.Version
+}
+
+func totalStops(b *Board) int {
+	n := 0
+	for _, r := range b.Runs {
+		n += len(r.Stops)
+	}
+	return n
+}
@@ -0,0 +1,250 @@
+package dispatch
+
+import (
+	"errors"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func plan() []models.FleetRoute {
+	loc := models.Location{Lat: 28.6, Lng: 77.2}
+	return []models.FleetRoute{
+		{VehicleID: "V1", StopIDs: []string{"A", "B"}, Route: []models.Location{loc, loc, loc, loc}, ArrivalHours: []float64{1, 2}},
+		{VehicleID: "V2", StopIDs: []string{"C"}, Route: []models.Location{loc, loc, loc}},
+	}
+}
+
+func TestStatusLifecycle(t *testing.T) {
+	s := NewStore()
+	b, err := s.Publish("acme", "2026-10-15", 0, plan(), []string{"D"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Counts[Pending] != 3 || b.Runs[0].Stops[1].ETAHours != 2 || b.Runs[0].Status != Pending || b.Version != 1 {
+		t.Fatalf("published board = %+v", b)
+	}
+
+	run, v, err := s.SetStatus("acme", "2026-10-15", b.Version, "V1", "A", EnRoute)
+	if err != nil || run.Status != EnRoute || v != 2 {
+		t.Fatalf("en route: %+v, version %d, %v", run, v, err)
+	}
+	if _, _, err := s.SetStatus("acme", "2026-10-15", v, "V1", "B", EnRoute); !errors.Is(err, ErrTransition) {
+		t.Errorf("second stop en route: got %v", err)
+	}
+	if _, v, err = s.SetStatus("acme", "2026-10-15", v, "V1", "A", Completed); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.SetStatus("acme", "2026-10-15", v, "V1", "A", Pending); !errors.Is(err, ErrTransition) {
+		t.Errorf("moving back: got %v", err)
+	}
+	run, v, err = s.SetStatus("acme", "2026-10-15", v, "V1", "B", Completed)
+	if err != nil || run.Status != Completed {
+		t.Fatalf("all done: %+v, %v", run, err)
+	}
+
+	b, _ = s.Board("acme", "2026-10-15")
+	if b.Counts[Completed] != 2 || b.Counts[Pending] != 1 || b.Runs[1].Status != Pending || b.Version != v {
+		t.Errorf("board = %+v", b)
+	}
+	if _, err := s.Publish("acme", "2026-10-15", v, plan(), nil, nil); !errors.Is(err, ErrStarted) {
+		t.Errorf("republishing a started day: got %v", err)
+	}
+
+	for _, tc := range []struct{ date, vehicle, stop string }{
+		{"2026-10-16", "V1", "A"}, {"2026-10-15", "V9", "A"}, {"2026-10-15", "V2", "A"},
+	} {
+		if _, _, err := s.SetStatus("acme", tc.date, v, tc.vehicle, tc.stop, Completed); !errors.Is(err, ErrUnknown) {
+			t.Errorf("%+v: got %v", tc, err)
+		}
+	}
+	if _, _, err := s.SetStatus("acme", "2026-10-15", v, "V2", "C", "lost"); !errors.Is(err, ErrTransition) {
+		t.Errorf("unknown status: got %v", err)
+	}
+}
+
+func TestEditsOfAnOldVersionFail(t *testing.T) {
+	s := NewStore()
+	b, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil); !errors.Is(err, ErrVersion) {
+		t.Errorf("publishing over a plan not read: got %v", err)
+	}
+
+	// A driver's report moves the board on; a dispatcher's edit of what
+	// they read before it fails
+	if _, err := s.Arrive("acme", "2026-10-15", "V2", "C"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.SetStatus("acme", "2026-10-15", b.Version, "V1", "A", EnRoute); !errors.Is(err, ErrVersion) {
+		t.Errorf("status from an old read: got %v", err)
+	}
+	now, _ := s.Board("acme", "2026-10-15")
+	if _, _, err := s.SetStatus("acme", "2026-10-15", now.Version, "V1", "A", EnRoute); err != nil {
+		t.Errorf("status from a fresh read: %v", err)
+	}
+
+	if err := s.Restore(b); err != nil {
+		t.Fatal(err)
+	}
+	if restored, _ := s.Board("acme", "2026-10-15"); restored.Version <= now.Version+1 {
+		t.Errorf("restored version %d does not move past %d", restored.Version, now.Version+1)
+	}
+}
+
+func TestBulkStatusesApplyEachOnItsOwn(t *testing.T) {
+	s := NewStore()
+	b, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, v, err := s.SetStatuses("acme", "2026-10-15", 0, []StatusChange{
+		{StopID: "C", Status: Completed},
+		{StopID: "Z", Status: Completed},
+		{VehicleID: "V2", StopID: "A", Status: EnRoute},
+		{StopID: "C", Status: Pending},
+		{StopID: "A", Status: EnRoute},
+	})
+	if err != nil || v != b.Version+1 {
+		t.Fatalf("version %d, %v", v, err)
+	}
+	want := []error{nil, ErrUnknown, ErrUnknown, ErrTransition, nil}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("change %d: got %v, want %v", i, errs[i], want[i])
+		}
+	}
+	b, _ = s.Board("acme", "2026-10-15")
+	if b.Counts[Completed] != 1 || b.Counts[EnRoute] != 1 || b.Runs[1].Status != Completed {
+		t.Errorf("board = %+v", b)
+	}
+
+	if _, _, err := s.SetStatuses("acme", "2026-10-16", 0, nil); !errors.Is(err, ErrUnknown) {
+		t.Errorf("unknown date: got %v", err)
+	}
+	if _, _, err := s.SetStatuses("acme", "2026-10-15", v-1, []StatusChange{{StopID: "A", Status: Completed}}); !errors.Is(err, ErrVersion) {
+		t.Errorf("stale version: got %v", err)
+	}
+}
+
+func TestRepublishBeforeStart(t *testing.T) {
+	s := NewStore()
+	old, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Publish("acme", "2026-10-15", old.Version, plan()[:1], nil, nil)
+	if err != nil || len(b.Runs) != 1 {
+		t.Errorf("replacing an unstarted plan: %+v, %v", b, err)
+	}
+}
+
+func TestArriveDepartAndReport(t *testing.T) {
+	s := NewStore()
+	if _, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Depart("acme", "2026-10-15", "V1", "A"); !errors.Is(err, ErrTransition) {
+		t.Errorf("departing before arriving: got %v", err)
+	}
+	run, err := s.Arrive("acme", "2026-10-15", "V1", "A")
+	if err != nil || run.Stops[0].Status != EnRoute || run.Stops[0].ArrivedAt.IsZero() {
+		t.Fatalf("arrive: %+v, %v", run, err)
+	}
+	if _, err := s.Arrive("acme", "2026-10-15", "V1", "B"); !errors.Is(err, ErrTransition) {
+		t.Errorf("arriving at a second stop: got %v", err)
+	}
+	run, err = s.Depart("acme", "2026-10-15", "V1", "A")
+	if err != nil || run.Stops[0].Status != Completed || run.Stops[0].DepartedAt.IsZero() {
+		t.Fatalf("depart: %+v, %v", run, err)
+	}
+	if next, ok := run.Next(); !ok || next.ID != "B" {
+		t.Errorf("next stop = %+v, %v, want B", next, ok)
+	}
+
+	if _, err := s.Report("acme", "2026-10-15", Issue{VehicleID: "V1", StopID: "B", Kind: "customer_absent"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Report("acme", "2026-10-15", Issue{VehicleID: "V1", Kind: "flat"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown kind: got %v", err)
+	}
+	if _, err := s.Report("acme", "2026-10-15", Issue{VehicleID: "V1", StopID: "C", Kind: "other"}); !errors.Is(err, ErrUnknown) {
+		t.Errorf("another vehicle's stop: got %v", err)
+	}
+	if b, _ := s.Board("acme", "2026-10-15"); len(b.Issues) != 1 || b.Issues[0].ReportedAt.IsZero() {
+		t.Errorf("issues = %+v", b.Issues)
+	}
+}
+
+func TestSearchByIDPrefix(t *testing.T) {
+	s := NewStore()
+	consignees := []models.Consignee{{StopID: "A", Name: "Sharma Traders", City: "Delhi"}}
+	if _, err := s.Publish("acme", "2026-10-15", 0, plan(), []string{"AB"}, consignees); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Publish("acme", "2026-10-16", 0, plan()[1:], nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.SetStatus("acme", "2026-10-15", 1, "V1", "A", EnRoute); err != nil {
+		t.Fatal(err)
+	}
+
+	hits := s.Search("A", "", "", everyone)
+	if len(hits) != 2 || hits[0].ID != "A" || hits[1].ID != "AB" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if a := hits[0]; a.VehicleID != "V1" || a.Position != 1 || a.Status != EnRoute || a.Customer != "Sharma Traders" {
+		t.Errorf("A = %+v", a)
+	}
+	if ab := hits[1]; ab.VehicleID != "" || ab.Status != Unassigned {
+		t.Errorf("AB = %+v", ab)
+	}
+
+	if hits := s.Search("C", "2026-10-16", "", everyone); len(hits) != 1 || hits[0].Date != "2026-10-16" {
+		t.Errorf("C from the 16th = %+v", hits)
+	}
+	// Republishing replaces the day's entries
+	if _, err := s.Publish("acme", "2026-10-16", 1, nil, []string{"C"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if hits := s.Search("C", "2026-10-16", "2026-10-16", everyone); len(hits) != 1 || hits[0].Status != Unassigned {
+		t.Errorf("C after republishing = %+v", hits)
+	}
+}
+
+// everyone reads every tenant's plans, as an admin does
+func everyone(string) bool { return true }
+
+func TestTenantsKeepTheirOwnBoards(t *testing.T) {
+	s := NewStore()
+	if _, err := s.Publish("acme", "2026-10-15", 0, plan(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Another tenant's plan for the same day is a board of its own
+	globex, err := s.Publish("globex", "2026-10-15", 0, plan()[1:], []string{"A"}, nil)
+	if err != nil || globex.Version != 1 || globex.Tenant != "globex" {
+		t.Fatalf("globex = %+v, %v", globex, err)
+	}
+	if _, _, err := s.SetStatus("globex", "2026-10-15", 1, "V1", "A", EnRoute); !errors.Is(err, ErrUnknown) {
+		t.Errorf("globex moved acme's stop: %v", err)
+	}
+	if _, ok := s.Run("initech", "2026-10-15", "V1"); ok {
+		t.Error("a tenant with no plan has a run")
+	}
+	if b, _ := s.Board("acme", "2026-10-15"); len(b.Runs) != 2 || b.Tenant != "acme" {
+		t.Errorf("acme = %+v", b)
+	}
+	if boards := s.Boards("2026-10-15", "2026-10-15"); len(boards) != 2 || boards[0].Tenant != "acme" || boards[1].Tenant != "globex" {
+		t.Errorf("boards = %+v", boards)
+	}
+
+	acme := func(tenant string) bool { return tenant == "acme" }
+	if hits := s.Search("A", "", "", acme); len(hits) != 1 || hits[0].Tenant != "acme" || hits[0].VehicleID != "V1" {
+		t.Errorf("acme's hits = %+v", hits)
+	}
+	if hits := s.Search("A", "", "", everyone); len(hits) != 2 {
+		t.Errorf("every tenant's hits = %+v", hits)
+	}
+}
@@ -0,0 +1,44 @@
+package dispatch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// IssueKinds are the problems a driver can report
+var IssueKinds = []string{"customer_absent", "address", "access", "damaged", "vehicle", "other"}
+
+// Issue is a problem a driver reported on the road
+type Issue struct {
+	VehicleID  string    `json:"vehicle_id"`
+	StopID     string    `json:"stop_id,omitempty"` // Empty for run-wide issues, e.g. a breakdown
+	Kind       string    `json:"kind"`
+	Note       string    `json:"note,omitempty"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// Report adds an issue to tenant's board for date. The stop, if given, must be
+// on the vehicle's run.
+func (s *Store) Report(tenant, date string, issue Issue) (Issue, error) {
+	if !slices.Contains(IssueKinds, issue.Kind) {
+		return Issue{}, fmt.Errorf("%w: issue kind must be one of %s", ErrInvalid, strings.Join(IssueKinds, ", "))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.boards[key{tenant, date}]
+	if !ok {
+		return Issue{}, ErrUnknown
+	}
+	run := b.run(issue.VehicleID)
+	if run == nil || (issue.StopID != "" && run.stop(issue.StopID) < 0) {
+		return Issue{}, ErrUnknown
+	}
+
+	issue.ReportedAt = s.now().UTC()
+	b.Issues = append(b.Issues, issue)
+	b.Version++
+	return issue, nil
+}
@@ -0,0 +1,74 @@
+package dispatch
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Unassigned is the status search gives shipments a plan left off
+const Unassigned Status = "unassigned"
+
+// ref locates a stop, or an unassigned shipment when run is -1. A board's
+// refs are replaced whenever its runs are, so the indexes stay valid while
+// statuses change.
+type ref struct {
+	id, tenant, date string
+	run, stop        int
+}
+
+// Hit is a shipment Search found: the stop as it stands and where it is on
+// the day's plan
+type Hit struct {
+	Tenant    string `json:"tenant,omitempty"`
+	Date      string `json:"date"`
+	VehicleID string `json:"vehicle_id,omitempty"` // Empty when unassigned
+	Position  int    `json:"position,omitempty"`   // 1 for the run's first stop
+	Stop
+}
+
+// index replaces the entries for b's tenant and date in the ID index with b's stops and
+// unassigned shipments. Callers hold the lock.
+func (s *Store) index(b *Board) {
+	s.ids = slices.DeleteFunc(s.ids, func(r ref) bool { return r.tenant == b.Tenant && r.date == b.Date })
+	for i, run := range b.Runs {
+		for j, st := range run.Stops {
+			s.ids = append(s.ids, ref{st.ID, b.Tenant, b.Date, i, j})
+		}
+	}
+	for j, id := range b.Unassigned {
+		s.ids = append(s.ids, ref{id, b.Tenant, b.Date, -1, j})
+	}
+	slices.SortFunc(s.ids, compareRefs)
+}
+
+func compareRefs(a, b ref) int {
+	return cmp.Or(strings.Compare(a.id, b.id), strings.Compare(a.date, b.date), strings.Compare(a.tenant, b.tenant))
+}
+
+// Search finds the shipments whose IDs start with prefix on the plans for
+// from through to (YYYY-MM-DD, inclusive; empty for no bound) of the
+// tenants reads accepts, in ID then date order. The ID index makes a
+// prefix lookup independent of how many plans are held.
+func (s *Store) Search(prefix, from, to string, reads func(tenant string) bool) []Hit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	start, _ := slices.BinarySearchFunc(s.ids, prefix, func(r ref, p string) int { return strings.Compare(r.id, p) })
+	hits := []Hit{}
+	for _, r := range s.ids[start:] {
+		if !strings.HasPrefix(r.id, prefix) {
+			break
+		}
+		if (from != "" && r.date < from) || (to != "" && r.date > to) || !reads(r.tenant) {
+			continue
+		}
+		b := s.boards[key{r.tenant, r.date}]
+		if r.run < 0 {
+			hits = append(hits, Hit{Tenant: r.tenant, Date: r.date, Stop: Stop{ID: r.id, Status: Unassigned}})
+			continue
+		}
+		run := b.Runs[r.run]
+		hits = append(hits, Hit{Tenant: r.tenant, Date: r.date, VehicleID: run.VehicleID, Position: r.stop + 1, Stop: run.Stops[r.stop]})
+	}
+	return hits
+}
@@ -0,0 +1,180 @@
+// Package elite keeps a pool of good plans per problem signature, so the
+// next solve of a similar problem (the same depot and fleet, much the same
+// stops, as in recurring daily planning) starts from them rather than from
+// nothing. Plans are kept by vehicle and stop IDs, which outlive the node
+// indices of any one problem.
+package elite
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pool defaults
+const (
+	PlansPerSignature = 5    // Diverse plans kept per signature, newest kept first
+	MaxSignatures     = 1000 // Signatures kept; the least recently used go first
+	SimilarPlans      = 0.9  // Plans sharing this share of legs count as one
+)
+
+// Plan is one solved plan: each vehicle's stop IDs in order
+type Plan struct {
+	Routes     map[string][]string `json:"routes"`
+	DistanceKm float64             `json:"distance_km"`
+	SavedAt    time.Time           `json:"saved_at"`
+}
+
+// Pool holds plans by signature. It is safe for concurrent use.
+type Pool struct {
+	mu    sync.Mutex
+	plans map[string][]Plan
+	used  map[string]time.Time
+}
+
+// New returns an empty pool
+func New() *Pool {
+	return &Pool{plans: map[string][]Plan{}, used: map[string]time.Time{}}
+}
+
+// Signature identifies the problems whose plans can seed one another: the
+// vehicles by ID with their endpoints' locations, which recurring plans
+// share while their stops vary from day to day. Scope keeps apart plans
+// that must not mix, such as different tenants'.
+func Signature(scope string, p *problem.Problem) string {
+	keys := make([]string, len(p.Vehicles), len(p.Vehicles)+1)
+	for i, v := range p.Vehicles {
+		keys[i] = v.ID + "@" + endpointKey(p, v.Start) + ">" + endpointKey(p, v.End)
+	}
+	slices.Sort(keys)
+	keys = append(keys, scope)
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(keys, "|")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func endpointKey(p *problem.Problem, n int) string {
+	if n < 0 {
+		return "-"
+	}
+	l := p.Nodes[n].Location
+	return fmt.Sprintf("%.4f,%.4f", l.Lat, l.Lng)
+}
+
+// Seeds returns the pooled plans for p's signature in scope as seeds for
+// p, by vehicle index and node index. Stops p lacks are left out.
+func (pl *Pool) Seeds(scope string, p *problem.Problem) [][][]int {
+	sig := Signature(scope, p)
+	pl.mu.Lock()
+	plans := slices.Clone(pl.plans[sig])
+	if len(plans) > 0 {
+		pl.used[sig] = time.Now()
+	}
+	pl.mu.Unlock()
+
+	node := make(map[string]int, len(p.Nodes))
+	for i, n := range p.Nodes {
+		node[n.ID] = i
+	}
+	var seeds [][][]int
+	for _, plan := range plans {
+		seed := make([][]int, len(p.Vehicles))
+		for vi, v := range p.Vehicles {
+			for _, id := range plan.Routes[v.ID] {
+				if n, ok := node[id]; ok {
+					seed[vi] = append(seed[vi], n)
+				}
+			}
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds
+}
+
+// Add pools sol, a plan for p, in scope. It replaces a pooled plan it
+// mostly repeats, or else joins the pool, which then drops its oldest plan
+// past PlansPerSignature; the pool so stays both current and diverse.
+func (pl *Pool) Add(scope string, p *problem.Problem, sol problem.Solution) {
+	plan := Plan{Routes: map[string][]string{}, DistanceKm: sol.DistanceKm, SavedAt: time.Now().UTC()}
+	for _, r := range sol.Routes {
+		if r.Vehicle < 0 || r.Vehicle >= len(p.Vehicles) {
+			continue
+		}
+		v := p.Vehicles[r.Vehicle]
+		var ids []string
+		for _, n := range r.Stops {
+			if n != v.Start && n != v.End {
+				ids = append(ids, p.Nodes[n].ID)
+			}
+		}
+		if len(ids) > 0 {
+			plan.Routes[v.ID] = ids
+		}
+	}
+	if len(plan.Routes) == 0 {
+		return
+	}
+
+	sig := Signature(scope, p)
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	plans := slices.DeleteFunc(slices.Clone(pl.plans[sig]), func(old Plan) bool {
+		return Similarity(old, plan) >= SimilarPlans
+	})
+	plans = append([]Plan{plan}, plans...)
+	pl.plans[sig] = plans[:min(len(plans), PlansPerSignature)]
+	pl.used[sig] = plan.SavedAt
+
+	if len(pl.plans) > MaxSignatures {
+		oldest := sig
+		for s, at := range pl.used {
+			if at.Before(pl.used[oldest]) {
+				oldest = s
+			}
+		}
+		delete(pl.plans, oldest)
+		delete(pl.used, oldest)
+	}
+}
+
+// Plans returns the pooled plans for signature, newest first
+func (pl *Pool) Plans(signature string) []Plan {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return slices.Clone(pl.plans[signature])
+}
+
+// Similarity is the share of legs, a vehicle driving from one stop to the
+// next, that two plans have in common: 1 for the same plan, 0 for plans
+// that share none
+func Similarity(a, b Plan) float64 {
+	la, lb := legs(a), legs(b)
+	shared := 0
+	for leg := range la {
+		if lb[leg] {
+			shared++
+		}
+	}
+	union := len(la) + len(lb) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
+
+func legs(p Plan) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range slices.Sorted(maps.Keys(p.Routes)) {
+		prev := v
+		for _, id := range p.Routes[v] {
+			set[v+"\x00"+prev+"\x00"+id] = true
+			prev = id
+		}
+	}
+	return set
+}
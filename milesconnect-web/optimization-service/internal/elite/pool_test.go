@@ -0,0 +1,106 @@
+package elite
+
+import (
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+)
+
+func fleetProblem(t *testing.T, size int) *problem.Problem {
+	t.Helper()
+	req, err := generator.FleetRequest(generator.Config{Size: size, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return problem.FromFleetRequest(req)
+}
+
+// stops are p's nodes that are no vehicle's endpoint
+func stops(p *problem.Problem) []int {
+	ends := map[int]bool{}
+	for _, v := range p.Vehicles {
+		ends[v.Start], ends[v.End] = true, true
+	}
+	var out []int
+	for i := range p.Nodes {
+		if !ends[i] {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// plan deals p's stops to its vehicles in turn, starting from stop shift
+func plan(p *problem.Problem, shift int) problem.Solution {
+	routes := make([]problem.Route, len(p.Vehicles))
+	for i, v := range p.Vehicles {
+		routes[i] = problem.Route{Vehicle: i, Stops: []int{v.Start}}
+	}
+	all := stops(p)
+	for i := range all {
+		v := i % len(routes)
+		routes[v].Stops = append(routes[v].Stops, all[(i+shift)%len(all)])
+	}
+	return problem.Solution{Routes: routes}
+}
+
+func TestPoolSeedsSimilarProblems(t *testing.T) {
+	pool := New()
+	p := fleetProblem(t, 20)
+	pool.Add("acme", p, plan(p, 0))
+	pool.Add("acme", p, plan(p, 0)) // A repeat replaces the first
+	pool.Add("acme", p, plan(p, 1))
+	if got := pool.Plans(Signature("acme", p)); len(got) != 2 {
+		t.Fatalf("pooled %d plans, want 2 different ones", len(got))
+	}
+	if seeds := pool.Seeds("other", p); len(seeds) != 0 {
+		t.Errorf("another tenant got %d seeds", len(seeds))
+	}
+
+	// Tomorrow's problem: the same fleet, one stop fewer. Seeds keep the
+	// stops it still has, by its own node indices.
+	q := fleetProblem(t, 20)
+	last := stops(q)[len(stops(q))-1]
+	q.Nodes = q.Nodes[:last]
+	if Signature("acme", q) != Signature("acme", p) {
+		t.Fatal("the same fleet has a different signature")
+	}
+	seeds := pool.Seeds("acme", q)
+	if len(seeds) != 2 {
+		t.Fatalf("%d seeds, want 2", len(seeds))
+	}
+	for _, seed := range seeds {
+		served := 0
+		for _, route := range seed {
+			for _, n := range route {
+				if n >= len(q.Nodes) {
+					t.Fatalf("seed stop %d is not one of q's", n)
+				}
+				served++
+			}
+		}
+		if want := len(stops(p)) - 1; served != want {
+			t.Errorf("seed serves %d stops, want %d", served, want)
+		}
+	}
+}
+
+func TestPoolKeepsNewestPlans(t *testing.T) {
+	pool := New()
+	p := fleetProblem(t, 30)
+	for shift := range PlansPerSignature + 2 {
+		pool.Add("", p, plan(p, shift))
+	}
+	got := pool.Plans(Signature("", p))
+	if len(got) != PlansPerSignature {
+		t.Fatalf("pooled %d plans, want %d", len(got), PlansPerSignature)
+	}
+	newest := plan(p, PlansPerSignature+1).Routes[0]
+	id, want := p.Vehicles[newest.Vehicle].ID, p.Nodes[newest.Stops[1]].ID
+	if got[0].Routes[id][0] != want {
+		t.Errorf("newest plan starts %s at %s, want %s", id, got[0].Routes[id][0], want)
+	}
+	if s := Similarity(got[0], got[1]); s >= SimilarPlans {
+		t.Errorf("pooled plans are %.2f similar", s)
+	}
+}
@@ -0,0 +1,205 @@
+// Package email sends mail with attachments through an SMTP relay, e.g. a
+// company mail server, SES or SendGrid.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a plain text mail and its attachments
+type Message struct {
+	To          []string
+	Subject     string
+	Text        string
+	Attachments []Attachment
+}
+
+// Mailer sends through one relay as one sender
+type Mailer struct {
+	host        string // host:port
+	serverName  string
+	implicitTLS bool
+	auth        smtp.Auth
+	from        *mail.Address
+}
+
+// New returns a mailer for the relay at raw, smtp://[user:pass@]host[:587]
+// or smtps://[user:pass@]host[:465] for TLS from the start; a smtp:// relay
+// that offers STARTTLS is switched to it. Mail comes from from, an address
+// with or without a display name.
+func New(raw, from string) (*Mailer, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || u.Scheme != "smtp" && u.Scheme != "smtps" {
+		return nil, errors.New("email: relay must be smtp://host[:port] or smtps://host[:port]")
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("email: sender: %w", err)
+	}
+	m := &Mailer{host: u.Host, serverName: u.Hostname(), implicitTLS: u.Scheme == "smtps", from: sender}
+	switch {
+	case u.Port() != "":
+	case m.implicitTLS:
+		m.host = net.JoinHostPort(u.Hostname(), "465")
+	default:
+		m.host = net.JoinHostPort(u.Hostname(), "587")
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		// PlainAuth refuses to send the password unless the connection is
+		// encrypted or local
+		m.auth = smtp.PlainAuth("", u.User.Username(), pass, u.Hostname())
+	}
+	return m, nil
+}
+
+// Send delivers msg to its recipients, giving up when ctx ends
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("email: no recipients")
+	}
+	body, err := m.compose(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if m.implicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.serverName}}).DialContext(ctx, "tcp", m.host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", m.host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, m.serverName)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Hello(hostname()); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok && !m.implicitTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: m.serverName}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("email: %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose encodes msg as a MIME message: the text, then each attachment
+// in base64
+func (m *Mailer) compose(msg Message, now time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	id := make([]byte, 12)
+	rand.Read(id)
+	header := func(name, value string) { fmt.Fprintf(&b, "%s: %s\r\n", name, value) }
+	header("From", m.from.String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+m.serverName+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `multipart/mixed; boundary="`+mw.Boundary()+`"`)
+	b.WriteString("\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	for _, a := range msg.Attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, a.Data)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeBase64 writes data in base64 lines of 76 characters, as MIME wants
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	w.Write([]byte(enc + "\r\n"))
+}
+
+// hostname names this host in the SMTP greeting
+func hostname() string {
+	if h, err := os.Hostname(); err == nil && h != "" {
+		return h
+	}
+	return "localhost"
+}
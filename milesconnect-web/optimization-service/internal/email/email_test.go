@@ -0,0 +1,145 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one session, answering every command with success, and
+// returns its address and the commands and message it received
+func fakeSMTP(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var session []string
+		defer func() { got <- session }()
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "220 fake ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			session = append(session, cmd)
+			switch verb, _, _ := strings.Cut(strings.ToUpper(cmd), " "); verb {
+			case "EHLO":
+				io.WriteString(conn, "250-fake\r\n250 AUTH PLAIN\r\n")
+			case "AUTH":
+				io.WriteString(conn, "235 ok\r\n")
+			case "DATA":
+				io.WriteString(conn, "354 go ahead\r\n")
+				var body strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					body.WriteString(line)
+				}
+				session = append(session, body.String())
+				io.WriteString(conn, "250 queued\r\n")
+			case "QUIT":
+				io.WriteString(conn, "221 bye\r\n")
+				return
+			default:
+				io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestSendWithAttachments(t *testing.T) {
+	addr, got := fakeSMTP(t)
+	m, err := New("smtp://planner:s3cret@"+addr, "MilesConnect <plans@milesconnect.example>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, Message{
+		To:          []string{"ops@acme.example", "hub@acme.example"},
+		Subject:     "Dispatch plan for 2026-10-15 – Pune",
+		Text:        "3 vehicles\nAttached.",
+		Attachments: []Attachment{{Name: "plan-2026-10-15.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4 fake")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := <-got
+	joined := strings.Join(session, "\n")
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00planner\x00s3cret"))
+	for _, want := range []string{"AUTH PLAIN " + plain, "MAIL FROM:<plans@milesconnect.example>", "RCPT TO:<ops@acme.example>", "RCPT TO:<hub@acme.example>"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("session lacks %q:\n%s", want, joined)
+		}
+	}
+
+	var raw string
+	for _, s := range session {
+		if strings.HasPrefix(s, "From: ") {
+			raw = s
+		}
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Dispatch plan for 2026-10-15 – Pune" {
+		t.Errorf("subject = %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	var filename string
+	for {
+		p, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		bodies = append(bodies, string(b))
+		if p.FileName() != "" {
+			filename = p.FileName()
+		}
+	}
+	if len(bodies) != 2 || bodies[0] != "3 vehicles\r\nAttached." || bodies[1] != "%PDF-1.4 fake" || filename != "plan-2026-10-15.pdf" {
+		t.Errorf("parts = %q, attachment %q", bodies, filename)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, c := range []struct{ url, from string }{
+		{"http://mail.example", "plans@milesconnect.example"},
+		{"smtp://", "plans@milesconnect.example"},
+		{"smtp://mail.example", "not an address"},
+	} {
+		if _, err := New(c.url, c.from); err == nil {
+			t.Errorf("New(%q, %q) accepted", c.url, c.from)
+		}
+	}
+	m, err := New("smtps://mail.example", "plans@milesconnect.example")
+	if err != nil || m.host != "mail.example:465" || !m.implicitTLS {
+		t.Errorf("smtps = %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,188 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decode reads one value from b, a test's stand-in for a client's decoder:
+// ints decode as int64, floats as float64
+func decode(b []byte) (any, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("short input")
+	}
+	c, b := b[0], b[1:]
+	n := func(size int) (uint64, []byte) {
+		var v uint64
+		for i := range size {
+			v = v<<8 | uint64(b[i])
+		}
+		return v, b[size:]
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), b, nil
+	case c >= 0xe0:
+		return int64(int8(c)), b, nil
+	case c&0xe0 == 0xa0:
+		l := int(c & 0x1f)
+		return string(b[:l]), b[l:], nil
+	case c&0xf0 == 0x90:
+		return decodeArray(int(c&0x0f), b)
+	case c&0xf0 == 0x80:
+		return decodeMap(int(c&0x0f), b)
+	}
+	switch c {
+	case 0xc0:
+		return nil, b, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, b, nil
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 0xcf:
+		v, rest := n(8)
+		return v, rest, nil
+	case 0xd3:
+		v, rest := n(8)
+		return int64(v), rest, nil
+	case 0xd9, 0xda, 0xdb:
+		l, rest := n(map[byte]int{0xd9: 1, 0xda: 2, 0xdb: 4}[c])
+		return string(rest[:l]), rest[l:], nil
+	case 0xdc, 0xdd:
+		l, rest := n(map[byte]int{0xdc: 2, 0xdd: 4}[c])
+		return decodeArray(int(l), rest)
+	case 0xde, 0xdf:
+		l, rest := n(map[byte]int{0xde: 2, 0xdf: 4}[c])
+		return decodeMap(int(l), rest)
+	}
+	return nil, nil, fmt.Errorf("unexpected code %#x", c)
+}
+
+func decodeArray(l int, b []byte) (any, []byte, error) {
+	out := make([]any, l)
+	for i := range out {
+		var err error
+		if out[i], b, err = decode(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return out, b, nil
+}
+
+func decodeMap(l int, b []byte) (any, []byte, error) {
+	out := make(map[string]any, l)
+	for range l {
+		k, rest, err := decode(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if out[k.(string)], b, err = decode(rest); err != nil {
+			return nil, nil, err
+		}
+	}
+	return out, b, nil
+}
+
+func roundTrip(t *testing.T, v any) any {
+	t.Helper()
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, rest, err := decode(b)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, %d bytes left", err, len(rest))
+	}
+	return got
+}
+
+type inner struct {
+	Note string `json:"note,omitempty"`
+	Rank int    `json:"rank"`
+}
+
+type outer struct {
+	inner
+	ID       string            `json:"id"`
+	Weight   float64           `json:"weight_kg"`
+	Count    int               `json:"count"`
+	Big      uint64            `json:"big"`
+	Ratio    float32           `json:"ratio"`
+	Skipped  string            `json:"-"`
+	Empty    []string          `json:"empty,omitempty"`
+	Missing  *inner            `json:"missing"`
+	Labels   map[string]string `json:"labels"`
+	ByDay    map[int]float64   `json:"by_day"`
+	At       time.Time         `json:"at"`
+	Raw      json.RawMessage   `json:"raw"`
+	Untagged bool
+	hidden   int
+}
+
+func TestFloatsStayFloats(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	v := outer{
+		inner: inner{Rank: 2}, ID: "SHP-1", Weight: 120, Count: 3, Big: math.MaxUint64, Ratio: 0.5,
+		Skipped: "x", Labels: map[string]string{"b": "2", "a": "1"}, ByDay: map[int]float64{7: 2},
+		At: at, Raw: json.RawMessage(`{"n":1.5}`), Untagged: true, hidden: 1,
+	}
+	got := roundTrip(t, v)
+	want := map[string]any{
+		"rank": int64(2), "id": "SHP-1", "weight_kg": float64(120), "count": int64(3), "big": uint64(math.MaxUint64),
+		"ratio": float64(0.5), "missing": nil, "labels": map[string]any{"a": "1", "b": "2"},
+		"by_day": map[string]any{"7": float64(2)}, "at": at.Format(time.RFC3339Nano),
+		"raw": map[string]any{"n": 1.5}, "Untagged": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestModelsRoundTrip(t *testing.T) {
+	// Whole-number coordinates and costs are the case JSON loses
+	route := models.FleetRoute{VehicleID: "V1", Route: []models.Location{{Lat: 19, Lng: 73}}, DistanceKm: 42}
+	got := roundTrip(t, []models.FleetRoute{route}).([]any)[0].(map[string]any)
+
+	want, _ := json.Marshal(route)
+	var fields map[string]any
+	json.Unmarshal(want, &fields)
+	for k := range fields {
+		if _, ok := got[k]; !ok {
+			t.Errorf("field %s missing from %v", k, got)
+		}
+	}
+	if len(got) != len(fields) {
+		t.Errorf("fields %v, JSON has %v", got, fields)
+	}
+	if d, ok := got["distance_km"].(float64); !ok || d != 42 {
+		t.Errorf("distance_km = %#v", got["distance_km"])
+	}
+	if lat, ok := got["route"].([]any)[0].(map[string]any)["lat"].(float64); !ok || lat != 19 {
+		t.Errorf("route = %#v", got["route"])
+	}
+}
+
+func TestLongCollections(t *testing.T) {
+	list := make([]float64, 70000)
+	list[69999] = 1
+	got := roundTrip(t, map[string]any{"xs": list, "s": strings.Repeat("a", 300)}).(map[string]any)
+	xs := got["xs"].([]any)
+	if len(xs) != 70000 || xs[69999] != float64(1) || len(got["s"].(string)) != 300 {
+		t.Errorf("len %d, last %#v", len(xs), xs[len(xs)-1])
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, v := range []any{math.NaN(), make(chan int), map[[2]int]int{{1, 2}: 3}} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("%T marshalled", v)
+		}
+	}
+}
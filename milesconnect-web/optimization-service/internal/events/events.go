@@ -0,0 +1,210 @@
+// Package events plugs the optimizer into the platform's message broker:
+// it consumes the shipments and vehicles other services announce, and
+// publishes the plans dispatched and the ETAs they move. Brokers are NATS,
+// spoken to directly, or Kafka through its REST Proxy; see Open. Every
+// message is an Event envelope in JSON, on the topic named by its type
+// behind a configurable prefix.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"net/url"
+	"time"
+)
+
+// Event types, which are also the topics behind the prefix
+const (
+	ShipmentCreated = "shipment.created" // Consumed; data is a Shipment
+	VehicleUpdated  = "vehicle.updated"  // Consumed; data is a Vehicle
+	PlanPublished   = "plan.published"   // Published; data is a Plan
+	ETAChanged      = "eta.changed"      // Published; data is a notify.Event
+)
+
+// Event is the envelope every message travels in
+type Event struct {
+	ID         string          `json:"id"` // Unique; receivers can deduplicate redeliveries on it
+	Type       string          `json:"type"`
+	Tenant     string          `json:"tenant,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Shipment is a shipment to plan, as the order system announces it
+type Shipment struct {
+	models.FleetStop
+	Date string `json:"date,omitempty"` // YYYY-MM-DD to deliver on, if set
+}
+
+// Vehicle is a vehicle's latest details, as the fleet system announces them
+type Vehicle struct {
+	models.VehicleInfo
+	Location *models.Location `json:"location,omitempty"` // Last known
+	Retired  bool             `json:"retired,omitempty"`  // Taken off the fleet
+}
+
+// Plan is a dispatched plan
+type Plan struct {
+	Date       string   `json:"date"`
+	Version    int      `json:"version"`
+	Vehicles   []string `json:"vehicles"`
+	Shipments  []string `json:"shipments"`
+	Unassigned []string `json:"unassigned,omitempty"`
+}
+
+// Broker is a connection to a message broker
+type Broker interface {
+	// Publish sends msg on topic
+	Publish(ctx context.Context, topic string, msg []byte) error
+	// Subscribe calls fn with each message on topic until ctx ends, when
+	// it returns nil, or the connection fails
+	Subscribe(ctx context.Context, topic string, fn func(msg []byte)) error
+	Close() error
+}
+
+// Open connects to the broker at raw: nats:// or tls:// for a NATS
+// server, with any user and password or token in the URL, or http:// or
+// https:// for a Kafka REST Proxy, where Kafka subscribers consume as group
+func Open(raw, group string) (Broker, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("events: bad broker URL")
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		return &NATS{url: u}, nil
+	case "http", "https":
+		return NewKafkaREST(raw, group), nil
+	}
+	return nil, fmt.Errorf("events: broker URL must be nats://, tls://, http:// or https://")
+}
+
+var (
+	published     = metrics.NewCounter("events_published_total", "Events published to the message broker")
+	publishFailed = metrics.NewCounter("events_publish_failed_total", "Events dropped after retries or because the publish buffer was full")
+	consumed      = metrics.NewCounter("events_consumed_total", "Events consumed from the message broker")
+	rejected      = metrics.NewCounter("events_rejected_total", "Consumed events that were malformed or could not be applied")
+)
+
+const (
+	maxAttempts = 4
+	bufferSize  = 1024
+)
+
+// Publisher publishes events from a background worker, retrying failures
+// with backoff
+type Publisher struct {
+	broker  Broker
+	prefix  string
+	backoff time.Duration
+	events  chan Event
+	done    chan struct{}
+}
+
+// NewPublisher starts publishing to b on prefix + the event type; Close
+// stops it
+func NewPublisher(b Broker, prefix string) *Publisher {
+	p := &Publisher{broker: b, prefix: prefix, backoff: time.Second, events: make(chan Event, bufferSize), done: make(chan struct{})}
+	go p.run()
+	return p
+}
+
+// Send queues an event of type typ without blocking; when the buffer is
+// full it is dropped and counted as failed. A nil Publisher discards it.
+func (p *Publisher) Send(typ, tenant string, data any) {
+	if p == nil {
+		return
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("events: encoding %s: %v", typ, err)
+		publishFailed.Inc()
+		return
+	}
+	ev := Event{ID: newID(), Type: typ, Tenant: tenant, OccurredAt: time.Now().UTC(), Data: body}
+	select {
+	case p.events <- ev:
+	default:
+		publishFailed.Inc()
+	}
+}
+
+// Close publishes what is queued and stops the worker
+func (p *Publisher) Close() {
+	close(p.events)
+	<-p.done
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for ev := range p.events {
+		msg, _ := json.Marshal(ev)
+		var err error
+		for attempt := range maxAttempts {
+			if attempt > 0 {
+				time.Sleep(p.backoff << (attempt - 1))
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err = p.broker.Publish(ctx, p.prefix+ev.Type, msg)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("events: publishing %s %s: %v", ev.Type, ev.ID, err)
+			publishFailed.Inc()
+			continue
+		}
+		published.Inc()
+	}
+}
+
+// Consume subscribes to each type in handlers on prefix + the type and
+// hands it the events that arrive, until ctx ends, resubscribing after a
+// failed connection. Events that are malformed, of another type or that
+// their handler fails are logged and skipped.
+func Consume(ctx context.Context, b Broker, prefix string, handlers map[string]func(Event) error) {
+	for typ, handle := range handlers {
+		go func() {
+			wait := time.Second
+			for ctx.Err() == nil {
+				err := b.Subscribe(ctx, prefix+typ, func(msg []byte) {
+					consumed.Inc()
+					var ev Event
+					if err := json.Unmarshal(msg, &ev); err != nil || ev.Type != typ {
+						log.Printf("events: skipping a malformed %s event", typ)
+						rejected.Inc()
+						return
+					}
+					if err := handle(ev); err != nil {
+						log.Printf("events: %s %s: %v", typ, ev.ID, err)
+						rejected.Inc()
+					}
+					wait = time.Second
+				})
+				if err == nil || ctx.Err() != nil {
+					return
+				}
+				log.Printf("events: %s subscription: %v; resubscribing in %s", typ, err, wait)
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+				wait = min(2*wait, time.Minute)
+			}
+		}()
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is just enough of a NATS server to route PUBs to SUBs
+func fakeNATS(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	subs := map[string][]net.Conn{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.WriteString(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					f := strings.Fields(line)
+					switch f[0] {
+					case "PING":
+						io.WriteString(conn, "PONG\r\n")
+					case "SUB":
+						mu.Lock()
+						subs[f[1]] = append(subs[f[1]], conn)
+						mu.Unlock()
+					case "PUB":
+						n, _ := strconv.Atoi(f[2])
+						msg := make([]byte, n+2)
+						io.ReadFull(r, msg)
+						mu.Lock()
+						for _, c := range subs[f[1]] {
+							fmt.Fprintf(c, "MSG %s 1 %d\r\n%s", f[1], n, msg)
+						}
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+	}()
+	return "nats://" + ln.Addr().String()
+}
+
+// eventually repeats send until got receives, since a subscription may
+// not be in place when the first message goes out
+func eventually[T any](t *testing.T, send func(), got <-chan T) T {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		send()
+		select {
+		case v := <-got:
+			return v
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("nothing received")
+		}
+	}
+}
+
+func TestNATSConsumeAndPublish(t *testing.T) {
+	b, err := Open(fakeNATS(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shipments := make(chan Shipment, 10)
+	Consume(ctx, b, "mc.", map[string]func(Event) error{
+		ShipmentCreated: func(ev Event) error {
+			var s Shipment
+			if err := json.Unmarshal(ev.Data, &s); err != nil {
+				return err
+			}
+			shipments <- s
+			return nil
+		},
+	})
+	msg, _ := json.Marshal(Event{ID: "e1", Type: ShipmentCreated, Tenant: "acme", Data: json.RawMessage(`{"id":"S1","demand_kg":120}`)})
+	s := eventually(t, func() { b.Publish(ctx, "mc."+ShipmentCreated, msg) }, shipments)
+	if s.ID != "S1" || s.DemandKg != 120 {
+		t.Errorf("shipment = %+v", s)
+	}
+
+	plans := make(chan Event, 10)
+	go b.Subscribe(ctx, "mc."+PlanPublished, func(msg []byte) {
+		var ev Event
+		json.Unmarshal(msg, &ev)
+		plans <- ev
+	})
+	p := NewPublisher(b, "mc.")
+	defer p.Close()
+	ev := eventually(t, func() { p.Send(PlanPublished, "", Plan{Date: "2026-10-15", Version: 2}) }, plans)
+	var plan Plan
+	if err := json.Unmarshal(ev.Data, &plan); err != nil || ev.Type != PlanPublished || ev.ID == "" || plan.Version != 2 {
+		t.Errorf("plan event = %+v", ev)
+	}
+}
+
+func TestKafkaRESTProduceAndConsume(t *testing.T) {
+	var mu sync.Mutex
+	var topic []json.RawMessage
+	read := 0
+	var proxy *httptest.Server
+	proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/topics/mc.vehicle.updated":
+			var body struct {
+				Records []struct {
+					Value json.RawMessage `json:"value"`
+				} `json:"records"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, rec := range body.Records {
+				topic = append(topic, rec.Value)
+			}
+			io.WriteString(w, `{"offsets":[{"partition":0,"offset":0}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/consumers/replica-a":
+			fmt.Fprintf(w, `{"instance_id":"i1","base_uri":"%s/consumers/replica-a/instances/i1"}`, proxy.URL)
+		case r.URL.Path == "/consumers/replica-a/instances/i1/subscription", r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/consumers/replica-a/instances/i1/records":
+			var out []map[string]json.RawMessage
+			for ; read < len(topic); read++ {
+				out = append(out, map[string]json.RawMessage{"value": topic[read]})
+			}
+			json.NewEncoder(w).Encode(out)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer proxy.Close()
+
+	b, err := Open(proxy.URL, "replica-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msg := []byte(`{"id":"e2","type":"vehicle.updated","data":{"id":"V1","capacity_kg":900}}`)
+	if err := b.Publish(ctx, "mc.vehicle.updated", msg); err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan []byte, 1)
+	go b.Subscribe(ctx, "mc.vehicle.updated", func(m []byte) { got <- m })
+	select {
+	case m := <-got:
+		if string(m) != string(msg) {
+			t.Errorf("consumed %s", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing consumed")
+	}
+	if err := b.Publish(ctx, "mc.unknown", msg); err == nil {
+		t.Error("proxy error not reported")
+	}
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kafka REST Proxy (v2) content types, with JSON-embedded values
+const (
+	kafkaJSON    = "application/vnd.kafka.json.v2+json"
+	kafkaControl = "application/vnd.kafka.v2+json"
+)
+
+// KafkaREST talks to Kafka through a Confluent-compatible REST Proxy, so
+// the service needs no Kafka client of its own. Each subscription is a
+// consumer instance in the group that commits offsets as it reads.
+type KafkaREST struct {
+	base   string
+	group  string
+	client *http.Client
+}
+
+// NewKafkaREST returns a client for the proxy at base whose subscriptions
+// consume as group
+func NewKafkaREST(base, group string) *KafkaREST {
+	return &KafkaREST{base: strings.TrimSuffix(base, "/"), group: group, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Publish produces msg, which must be JSON, to topic
+func (k *KafkaREST) Publish(ctx context.Context, topic string, msg []byte) error {
+	body, _ := json.Marshal(map[string]any{"records": []map[string]json.RawMessage{{"value": msg}}})
+	var resp struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := k.do(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(topic), kafkaJSON, body, &resp); err != nil {
+		return err
+	}
+	for _, o := range resp.Offsets {
+		if o.Error != "" {
+			return fmt.Errorf("events: producing to %s: %s", topic, o.Error)
+		}
+	}
+	return nil
+}
+
+// Subscribe creates a consumer instance subscribed to topic and polls it
+// for records, deleting the instance when done so the group rebalances
+// promptly
+func (k *KafkaREST) Subscribe(ctx context.Context, topic string, fn func(msg []byte)) error {
+	create, _ := json.Marshal(map[string]string{
+		"name":               "sub-" + newID(),
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "true",
+	})
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+	if err := k.do(ctx, http.MethodPost, k.base+"/consumers/"+url.PathEscape(k.group), kafkaControl, create, &instance); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		k.do(ctx, http.MethodDelete, instance.BaseURI, kafkaControl, nil, nil)
+	}()
+	topics, _ := json.Marshal(map[string][]string{"topics": {topic}})
+	if err := k.do(ctx, http.MethodPost, instance.BaseURI+"/subscription", kafkaControl, topics, nil); err != nil {
+		return err
+	}
+	for {
+		var records []struct {
+			Value json.RawMessage `json:"value"`
+		}
+		err := k.do(ctx, http.MethodGet, instance.BaseURI+"/records?timeout=5000", kafkaJSON, nil, &records)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			fn(r.Value)
+		}
+	}
+}
+
+// Close has nothing to release; subscriptions end with their contexts
+func (k *KafkaREST) Close() error { return nil }
+
+// do sends a request to the proxy, decoding its JSON answer into out
+func (k *KafkaREST) do(ctx context.Context, method, target, contentType string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", contentType)
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("events: Kafka REST Proxy %s %s: %s %s", method, strings.TrimPrefix(target, k.base), resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxPayload*16)).Decode(out)
+}
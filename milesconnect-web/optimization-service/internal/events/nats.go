@@ -0,0 +1,208 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPayload bounds a message read from NATS; the server's own default is
+// 1MiB
+const maxPayload = 8 << 20
+
+// NATS speaks the NATS core protocol: a connection per subscription, and
+// one shared by publishers that is dialled on first use and again after
+// it fails
+type NATS struct {
+	url *url.URL
+
+	mu  sync.Mutex
+	pub *natsConn
+}
+
+// natsConn is one connection to the server. Writes hold mu; the server's
+// PINGs are answered by whoever reads the connection.
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+	w    *bufio.Writer
+}
+
+// Publish sends msg on the subject topic
+func (n *NATS) Publish(ctx context.Context, topic string, msg []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pub == nil {
+		c, err := n.dial(ctx)
+		if err != nil {
+			return err
+		}
+		n.pub = c
+		go n.drain(c)
+	}
+	deadline, _ := ctx.Deadline() // Zero, clearing it, when ctx has none
+	n.pub.conn.SetWriteDeadline(deadline)
+	err := n.pub.write(fmt.Sprintf("PUB %s %d\r\n", topic, len(msg)), msg, []byte("\r\n"))
+	if err != nil {
+		n.pub.conn.Close()
+		n.pub = nil
+	}
+	return err
+}
+
+// drain reads the publishing connection, answering PINGs, until it fails
+func (n *NATS) drain(c *natsConn) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			break
+		}
+		if line == "PING" {
+			c.write("PONG\r\n")
+		}
+	}
+	c.conn.Close()
+	n.mu.Lock()
+	if n.pub == c {
+		n.pub = nil
+	}
+	n.mu.Unlock()
+}
+
+// Subscribe calls fn with each message on the subject topic
+func (n *NATS) Subscribe(ctx context.Context, topic string, fn func(msg []byte)) error {
+	c, err := n.dial(ctx)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+	defer c.conn.Close()
+	if err := c.write("SUB " + topic + " 1\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case "MSG": // MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				return fmt.Errorf("events: bad NATS message header %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 || size > maxPayload {
+				return fmt.Errorf("events: bad NATS message header %q", line)
+			}
+			msg := make([]byte, size+2)
+			if _, err := io.ReadFull(c.r, msg); err != nil {
+				return err
+			}
+			fn(msg[:size])
+		case "-ERR":
+			return fmt.Errorf("events: NATS: %s", args)
+		}
+	}
+}
+
+// Close closes the publishing connection; subscriptions end with their
+// contexts
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pub == nil {
+		return nil
+	}
+	err := n.pub.conn.Close()
+	n.pub = nil
+	return err
+}
+
+// dial connects and authenticates, waiting for the server to answer a PING
+// so a refused login fails here rather than on the first message
+func (n *NATS) dial(ctx context.Context) (*natsConn, error) {
+	host := n.url.Host
+	if n.url.Port() == "" {
+		host = net.JoinHostPort(n.url.Hostname(), "4222")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	raw, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	raw.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &natsConn{conn: raw, r: bufio.NewReaderSize(raw, 64<<10), w: bufio.NewWriter(raw)}
+	if line, err := c.readLine(); err != nil || !strings.HasPrefix(line, "INFO ") {
+		raw.Close()
+		return nil, errors.Join(errors.New("events: not a NATS server"), err)
+	}
+	conn := raw
+	if n.url.Scheme == "tls" { // The server upgrades after its INFO
+		conn = tls.Client(raw, &tls.Config{ServerName: n.url.Hostname()})
+		c = &natsConn{conn: conn, r: bufio.NewReaderSize(conn, 64<<10), w: bufio.NewWriter(conn)}
+	}
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "milesconnect-optimization", "lang": "go", "version": "1"}
+	if u := n.url.User; u != nil {
+		if pass, ok := u.Password(); ok {
+			opts["user"], opts["pass"] = u.Username(), pass
+		} else {
+			opts["auth_token"] = u.Username()
+		}
+	}
+	connect, _ := json.Marshal(opts)
+	if err := c.write("CONNECT " + string(connect) + "\r\nPING\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	line, err := c.readLine()
+	if err == nil && line != "PONG" {
+		err = fmt.Errorf("events: NATS refused the connection: %s", line)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *natsConn) write(head string, body ...[]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.WriteString(head)
+	for _, b := range body {
+		c.w.Write(b)
+	}
+	return c.w.Flush()
+}
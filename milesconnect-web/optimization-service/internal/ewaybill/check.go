@@ -0,0 +1,68 @@
+// Package ewaybill checks that shipments carry the GST e-way bill they need
+// before a plan sends them on the road. Moving goods worth more than the
+// threshold without a valid bill gets the vehicle detained.
+package ewaybill
+
+import (
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultThresholdInr is the consignment value above which a bill is
+// required (Rule 138 of the CGST Rules); some states set their own for
+// movement within the state
+const DefaultThresholdInr = 50000
+
+var validNumber = regexp.MustCompile(`^[0-9]{12}$`)
+
+// Error lists the shipments that cannot be dispatched and why
+type Error struct {
+	Shipments []models.ShipmentProblems
+}
+
+func (e *Error) Error() string {
+	ids := make([]string, len(e.Shipments))
+	for i, s := range e.Shipments {
+		ids[i] = s.StopID
+	}
+	return "ewaybill: missing or invalid e-way bills for " + strings.Join(ids, ", ")
+}
+
+// Check returns what is missing or wrong with a shipment's e-way bill for a
+// trip of at least minKm (the straight-line distance) that arrives by
+// arrival. Shipments worth no more than thresholdInr need no bill, but one
+// given is still checked.
+func Check(d models.ShipmentDocs, arrival time.Time, minKm, thresholdInr float64) []string {
+	var errs []string
+	if d.ValueInr <= 0 {
+		errs = append(errs, "consignment value is missing")
+	}
+	b := d.EWayBill
+	if b == nil {
+		if d.ValueInr > thresholdInr {
+			errs = append(errs, fmt.Sprintf("an e-way bill is required for goods worth more than Rs %.0f", thresholdInr))
+		}
+		return errs
+	}
+
+	if !validNumber.MatchString(b.Number) {
+		errs = append(errs, "e-way bill number must be 12 digits")
+	}
+	if b.DistanceKm <= 0 {
+		errs = append(errs, "e-way bill distance is missing")
+	} else if b.DistanceKm < math.Floor(minKm) {
+		errs = append(errs, fmt.Sprintf("e-way bill distance of %.0f km is shorter than the %.0f km straight-line trip", b.DistanceKm, minKm))
+	}
+	switch {
+	case b.ValidUntil.IsZero():
+		errs = append(errs, "e-way bill validity is missing")
+	case b.ValidUntil.Before(arrival):
+		errs = append(errs, fmt.Sprintf("e-way bill expires at %s, before the planned arrival at %s",
+			b.ValidUntil.Format(time.RFC3339), arrival.Format(time.RFC3339)))
+	}
+	return errs
+}
@@ -0,0 +1,43 @@
+package ewaybill
+
+import (
+	"milesconnect-optimization/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	arrival := time.Date(2026, 11, 5, 14, 0, 0, 0, time.UTC)
+	bill := func(number string, until time.Time, km float64) *models.EWayBill {
+		return &models.EWayBill{Number: number, ValidUntil: until, DistanceKm: km}
+	}
+	good := bill("331000123456", arrival.Add(24*time.Hour), 260)
+	tests := []struct {
+		name string
+		docs models.ShipmentDocs
+		want []string // Substrings of the errors, in order
+	}{
+		{"valid", models.ShipmentDocs{StopID: "A", ValueInr: 120000, EWayBill: good}, nil},
+		{"below threshold", models.ShipmentDocs{StopID: "A", ValueInr: 30000}, nil},
+		{"no value", models.ShipmentDocs{StopID: "A"}, []string{"value is missing"}},
+		{"no bill", models.ShipmentDocs{StopID: "A", ValueInr: 75000}, []string{"e-way bill is required"}},
+		{"bad number", models.ShipmentDocs{StopID: "A", ValueInr: 75000, EWayBill: bill("EWB-1", good.ValidUntil, 260)}, []string{"12 digits"}},
+		{"short distance", models.ShipmentDocs{StopID: "A", ValueInr: 75000, EWayBill: bill(good.Number, good.ValidUntil, 120)}, []string{"shorter than"}},
+		{"expires en route", models.ShipmentDocs{StopID: "A", ValueInr: 75000, EWayBill: bill(good.Number, arrival.Add(-time.Hour), 260)}, []string{"expires"}},
+		{"empty bill", models.ShipmentDocs{StopID: "A", ValueInr: 75000, EWayBill: &models.EWayBill{}}, []string{"12 digits", "distance is missing", "validity is missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Check(tt.docs, arrival, 250.4, DefaultThresholdInr)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %q, want %d errors", errs, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(errs[i], w) {
+					t.Errorf("error %d = %q, want it to mention %q", i, errs[i], w)
+				}
+			}
+		})
+	}
+}
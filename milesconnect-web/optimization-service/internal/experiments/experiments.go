@@ -0,0 +1,295 @@
+// Package experiments runs solver A/B tests. An experiment enrolls a share
+// of an endpoint's requests: in split mode they are served by the variant
+// solver or profile in place of the usual one, in shadow mode the variant
+// also solves them in the background and only the usual result is served.
+// Outcomes are kept per arm, and shadow runs are compared problem by
+// problem.
+package experiments
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes
+const (
+	ModeSplit  = "split"  // Enrolled requests are served by the variant
+	ModeShadow = "shadow" // Enrolled requests are also solved by the variant
+)
+
+// Arms
+const (
+	ArmControl = "control" // The solver the request would otherwise get
+	ArmVariant = "variant"
+)
+
+// Arm is a solver, a profile, or a solver under a profile
+type Arm struct {
+	Solver  string `json:"solver,omitempty"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// Experiment enrolls Percent of Endpoint's requests, from Tenants or from
+// every tenant when none are listed, that pin neither solver nor profile
+type Experiment struct {
+	Name     string    `json:"name"`
+	Endpoint string    `json:"endpoint"` // Unversioned, e.g. /optimize-fleet
+	Mode     string    `json:"mode"`
+	Percent  float64   `json:"percent"`
+	Variant  Arm       `json:"variant"`
+	Tenants  []string  `json:"tenants,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+// Validate checks e's mode, share and variant
+func (e Experiment) Validate() error {
+	switch {
+	case e.Name == "" || strings.ContainsAny(e.Name, " /?#"):
+		return errors.New("experiment name must be set and free of spaces, slashes, ? and #")
+	case e.Mode != ModeSplit && e.Mode != ModeShadow:
+		return errors.New("mode must be split or shadow")
+	case !(e.Percent > 0 && e.Percent <= 100):
+		return errors.New("percent must be above 0 and at most 100")
+	case e.Variant == Arm{}:
+		return errors.New("variant must name a solver or profile")
+	}
+	return nil
+}
+
+// enrolls reports whether e takes requests to endpoint from tenant
+func (e Experiment) enrolls(endpoint, tenant string) bool {
+	return e.Endpoint == endpoint && (len(e.Tenants) == 0 || slices.Contains(e.Tenants, tenant))
+}
+
+// Outcome is how one solve went
+type Outcome struct {
+	DistanceKm float64 `json:"distance_km"`
+	Unassigned int     `json:"unassigned"`
+	Millis     int64   `json:"ms"`
+	Failed     bool    `json:"failed,omitempty"`
+}
+
+// Compare orders outcomes by quality: a failure is worst, then fewer
+// unassigned stops win, then distance, within tieTolerance. It returns -1
+// when a is better, 1 when b is, else 0.
+func Compare(a, b Outcome) int {
+	switch {
+	case a.Failed != b.Failed:
+		if a.Failed {
+			return 1
+		}
+		return -1
+	case a.Failed:
+		return 0
+	case a.Unassigned != b.Unassigned:
+		return cmp.Compare(a.Unassigned, b.Unassigned)
+	case math.Abs(a.DistanceKm-b.DistanceKm) <= tieTolerance*math.Max(a.DistanceKm, b.DistanceKm):
+		return 0
+	}
+	return cmp.Compare(a.DistanceKm, b.DistanceKm)
+}
+
+// tieTolerance is the relative difference in distance below which two
+// plans count as equally good
+const tieTolerance = 0.001
+
+// Stats are an arm's outcomes, averaged over the solves that did not fail
+type Stats struct {
+	Runs           int     `json:"runs"`
+	Failures       int     `json:"failures"`
+	MeanKm         float64 `json:"mean_distance_km"`
+	MeanUnassigned float64 `json:"mean_unassigned"`
+	MeanMillis     float64 `json:"mean_ms"`
+}
+
+func (s *Stats) add(o Outcome) {
+	s.Runs++
+	if o.Failed {
+		s.Failures++
+		return
+	}
+	n := float64(s.Runs - s.Failures)
+	s.MeanKm += (o.DistanceKm - s.MeanKm) / n
+	s.MeanUnassigned += (float64(o.Unassigned) - s.MeanUnassigned) / n
+	s.MeanMillis += (float64(o.Millis) - s.MeanMillis) / n
+}
+
+// Pairs compares the arms on the problems both solved, in shadow mode
+type Pairs struct {
+	Count         int     `json:"count"`
+	VariantBetter int     `json:"variant_better"`
+	ControlBetter int     `json:"control_better"`
+	Ties          int     `json:"ties"`
+	MeanGapPct    float64 `json:"mean_gap_pct"`      // Variant's distance over control's, among pairs with equal unassigned
+	Skipped       int     `json:"skipped,omitempty"` // Enrolled requests not shadowed, the shadow solver being busy
+
+	gaps int
+}
+
+func (p *Pairs) add(control, variant Outcome) {
+	p.Count++
+	switch Compare(variant, control) {
+	case -1:
+		p.VariantBetter++
+	case 1:
+		p.ControlBetter++
+	default:
+		p.Ties++
+	}
+	if !control.Failed && !variant.Failed && control.Unassigned == variant.Unassigned && control.DistanceKm > 0 {
+		p.gaps++
+		gap := (variant.DistanceKm - control.DistanceKm) / control.DistanceKm * 100
+		p.MeanGapPct += (gap - p.MeanGapPct) / float64(p.gaps)
+	}
+}
+
+// Report is an experiment and how its arms have done
+type Report struct {
+	Experiment
+	Control Stats  `json:"control"`
+	Variant Stats  `json:"variant"`
+	Pairs   *Pairs `json:"pairs,omitempty"` // Shadow mode
+}
+
+// Registry holds the experiments running. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	reports map[string]*Report
+}
+
+// NewRegistry returns a registry with no experiments
+func NewRegistry() *Registry {
+	return &Registry{reports: map[string]*Report{}}
+}
+
+// ErrConflict is returned for an experiment on an endpoint another one
+// already takes requests to
+var ErrConflict = errors.New("another experiment enrolls requests to that endpoint")
+
+// Start adds e, replacing and resetting the experiment of its name. One
+// experiment at a time may enroll each tenant's requests to an endpoint.
+func (g *Registry) Start(e Experiment, now time.Time) (Experiment, error) {
+	if err := e.Validate(); err != nil {
+		return Experiment{}, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name, r := range g.reports {
+		if name != e.Name && r.Endpoint == e.Endpoint && overlap(r.Tenants, e.Tenants) {
+			return Experiment{}, fmt.Errorf("%w: %s", ErrConflict, name)
+		}
+	}
+	e.Started = now
+	r := &Report{Experiment: e}
+	if e.Mode == ModeShadow {
+		r.Pairs = &Pairs{}
+	}
+	g.reports[e.Name] = r
+	return e, nil
+}
+
+func overlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, t := range a {
+		if slices.Contains(b, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop ends the experiment name and reports whether there was one
+func (g *Registry) Stop(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.reports[name]
+	delete(g.reports, name)
+	return ok
+}
+
+// For returns the experiment taking tenant's requests to endpoint
+func (g *Registry) For(endpoint, tenant string) (Experiment, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, r := range g.reports {
+		if r.enrolls(endpoint, tenant) {
+			return r.Experiment, true
+		}
+	}
+	return Experiment{}, false
+}
+
+// Record adds an enrolled solve's outcome to an arm of the experiment name,
+// unless it has since stopped
+func (g *Registry) Record(name, arm string, o Outcome) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.reports[name]
+	switch {
+	case !ok:
+	case arm == ArmVariant:
+		r.Variant.add(o)
+	default:
+		r.Control.add(o)
+	}
+}
+
+// RecordPair adds the outcomes of both arms on one problem
+func (g *Registry) RecordPair(name string, control, variant Outcome) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r, ok := g.reports[name]; ok && r.Pairs != nil {
+		r.Control.add(control)
+		r.Variant.add(variant)
+		r.Pairs.add(control, variant)
+	}
+}
+
+// Skip counts an enrolled request the variant did not shadow
+func (g *Registry) Skip(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r, ok := g.reports[name]; ok && r.Pairs != nil {
+		r.Pairs.Skipped++
+	}
+}
+
+// Get returns the report of the experiment name
+func (g *Registry) Get(name string) (Report, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.reports[name]
+	if !ok {
+		return Report{}, false
+	}
+	return r.clone(), true
+}
+
+// List returns every experiment's report, by name
+func (g *Registry) List() []Report {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	list := make([]Report, 0, len(g.reports))
+	for _, r := range g.reports {
+		list = append(list, r.clone())
+	}
+	slices.SortFunc(list, func(a, b Report) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+func (r *Report) clone() Report {
+	c := *r
+	if r.Pairs != nil {
+		p := *r.Pairs
+		c.Pairs = &p
+	}
+	return c
+}
@@ -0,0 +1,75 @@
+package experiments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompareRanksFailuresThenUnassignedThenDistance(t *testing.T) {
+	good := Outcome{DistanceKm: 100}
+	for _, c := range []struct {
+		a, b Outcome
+		want int
+	}{
+		{good, Outcome{Failed: true}, -1},
+		{Outcome{Failed: true}, good, 1},
+		{good, Outcome{DistanceKm: 50, Unassigned: 1}, -1},
+		{good, Outcome{DistanceKm: 100.05}, 0},
+		{good, Outcome{DistanceKm: 120}, -1},
+		{Outcome{DistanceKm: 90}, good, -1},
+	} {
+		if got := Compare(c.a, c.b); got != c.want {
+			t.Errorf("Compare(%+v, %+v) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestShadowPairsReportGap(t *testing.T) {
+	g := NewRegistry()
+	e := Experiment{Name: "alns-v2", Endpoint: "/optimize-fleet", Mode: ModeShadow, Percent: 10, Variant: Arm{Solver: "tabu"}}
+	if _, err := g.Start(e, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.For("/optimize-fleet", "acme"); !ok {
+		t.Fatal("experiment does not enroll acme")
+	}
+	g.RecordPair("alns-v2", Outcome{DistanceKm: 100}, Outcome{DistanceKm: 110})
+	g.RecordPair("alns-v2", Outcome{DistanceKm: 100}, Outcome{DistanceKm: 90})
+	g.RecordPair("alns-v2", Outcome{DistanceKm: 100}, Outcome{Failed: true})
+	g.Skip("alns-v2")
+
+	r, _ := g.Get("alns-v2")
+	p := r.Pairs
+	if p.Count != 3 || p.VariantBetter != 1 || p.ControlBetter != 2 || p.Skipped != 1 || p.MeanGapPct != 0 {
+		t.Errorf("pairs = %+v", p)
+	}
+	if r.Variant.Runs != 3 || r.Variant.Failures != 1 || r.Variant.MeanKm != 100 || r.Control.MeanKm != 100 {
+		t.Errorf("arms = %+v / %+v", r.Control, r.Variant)
+	}
+}
+
+func TestOneExperimentPerEndpointAndTenant(t *testing.T) {
+	g := NewRegistry()
+	e := Experiment{Name: "a", Endpoint: "/optimize", Mode: ModeSplit, Percent: 50, Variant: Arm{Profile: "best"}, Tenants: []string{"acme"}}
+	if _, err := g.Start(e, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	other := e
+	other.Name, other.Tenants = "b", []string{"globex"}
+	if _, err := g.Start(other, time.Now()); err != nil {
+		t.Errorf("disjoint tenants: %v", err)
+	}
+	other.Name, other.Tenants = "c", nil
+	if _, err := g.Start(other, time.Now()); !errors.Is(err, ErrConflict) {
+		t.Errorf("overlapping experiment: %v", err)
+	}
+	if _, ok := g.For("/optimize", "initech"); ok {
+		t.Error("initech enrolled")
+	}
+	bad := e
+	bad.Percent = 0
+	if _, err := g.Start(bad, time.Now()); err == nil {
+		t.Error("0% accepted")
+	}
+}
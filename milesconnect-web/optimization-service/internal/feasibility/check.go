@@ -0,0 +1,188 @@
+package feasibility
+
+import (
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"strings"
+)
+
+// capacityEpsilon absorbs float rounding when summing weights
+const capacityEpsilon = 1e-6
+
+// timeEpsilon absorbs float rounding when summing travel times (hours)
+const timeEpsilon = 1e-9
+
+// Check validates sol against every constraint declared in p
+func Check(p *problem.Problem, sol problem.Solution) models.FeasibilityReport {
+	c := &checker{p: p}
+	c.coverage(sol)
+	for _, r := range sol.Routes {
+		c.route(r)
+	}
+	return c.report()
+}
+
+type checker struct {
+	p          *problem.Problem
+	violations []models.Violation
+}
+
+func (c *checker) add(v models.Violation) {
+	c.violations = append(c.violations, v)
+}
+
+func (c *checker) report() models.FeasibilityReport {
+	rep := models.FeasibilityReport{Feasible: true, Violations: []models.Violation{}}
+	for _, v := range c.violations {
+		if !v.Soft {
+			rep.Feasible = false
+		}
+		rep.Violations = append(rep.Violations, v)
+	}
+	return rep
+}
+
+// coverage checks each node is served at most once and none is forgotten
+func (c *checker) coverage(sol problem.Solution) {
+	endpoints := map[int]bool{}
+	for _, v := range c.p.Vehicles {
+		if v.Start >= 0 {
+			endpoints[v.Start] = true
+		}
+		if v.End >= 0 {
+			endpoints[v.End] = true
+		}
+	}
+
+	seen := make([]int, len(c.p.Nodes))
+	for _, r := range sol.Routes {
+		for _, n := range r.Stops {
+			if n < 0 || n >= len(c.p.Nodes) {
+				c.add(models.Violation{Constraint: "coverage", Message: fmt.Sprintf("route references unknown node %d", n)})
+				continue
+			}
+			if !endpoints[n] {
+				seen[n]++
+			}
+		}
+	}
+	for _, n := range sol.Unassigned {
+		if n >= 0 && n < len(c.p.Nodes) {
+			seen[n]++
+		}
+	}
+
+	for i, count := range seen {
+		if endpoints[i] {
+			continue
+		}
+		id := c.p.Nodes[i].ID
+		switch {
+		case count == 0:
+			c.add(models.Violation{Constraint: "coverage", NodeID: id, Message: "node is neither served nor reported unassigned"})
+		case count > 1:
+			c.add(models.Violation{Constraint: "coverage", NodeID: id, Message: fmt.Sprintf("node appears %d times", count)})
+		}
+	}
+}
+
+func (c *checker) route(r problem.Route) {
+	if r.Vehicle < 0 || r.Vehicle >= len(c.p.Vehicles) {
+		c.add(models.Violation{Constraint: "coverage", Message: fmt.Sprintf("route references unknown vehicle %d", r.Vehicle)})
+		return
+	}
+	v := c.p.Vehicles[r.Vehicle]
+
+	// Routing problems must start and end at the vehicle's fixed endpoints
+	if c.p.Type == problem.TypeRouting && len(r.Stops) > 0 {
+		if v.Start >= 0 && r.Stops[0] != v.Start {
+			c.add(models.Violation{Constraint: "endpoint", VehicleID: v.ID, Message: "route does not begin at the start location"})
+		}
+		if v.End >= 0 && r.Stops[len(r.Stops)-1] != v.End {
+			c.add(models.Violation{Constraint: "endpoint", VehicleID: v.ID, Message: "route does not finish at the end location"})
+		}
+	}
+
+	var stops []int
+	for _, n := range r.Stops {
+		if n < 0 || n >= len(c.p.Nodes) {
+			continue
+		}
+		node := c.p.Nodes[n]
+		stops = append(stops, n)
+
+		if c.p.Constraints.Compatibility && !c.p.Compatible(v, n) {
+			c.add(models.Violation{
+				Constraint: "compatibility",
+				VehicleID:  v.ID,
+				NodeID:     node.ID,
+				Message:    fmt.Sprintf("vehicle type %q may not serve it; allowed: %s", v.Type, strings.Join(node.VehicleTypes, ", ")),
+			})
+		}
+
+		if c.p.Constraints.Deadlines && node.LatePenaltyPerHour > 0 && v.DepartHours > node.DeadlineHours {
+			c.add(models.Violation{
+				Constraint: "deadline",
+				VehicleID:  v.ID,
+				NodeID:     node.ID,
+				Message:    fmt.Sprintf("departs %.1fh after the deadline", v.DepartHours-node.DeadlineHours),
+				Soft:       true,
+			})
+		}
+	}
+
+	if c.p.Constraints.TimeWindows && c.p.Type == problem.TypeRouting {
+		c.timeWindows(v, r.Stops)
+	}
+	if len(c.p.Curfews) > 0 && c.p.Type == problem.TypeRouting {
+		c.curfews(v, r.Stops)
+	}
+
+	if load := c.p.PeakLoad(v, stops); c.p.Constraints.Capacity && v.CapacityKg > 0 && load > v.CapacityKg+capacityEpsilon {
+		c.add(models.Violation{
+			Constraint: "capacity",
+			VehicleID:  v.ID,
+			Message:    fmt.Sprintf("load %.2f kg exceeds capacity %.2f kg", load, v.CapacityKg),
+		})
+	}
+}
+
+// timeWindows checks every stop is served by its due time
+func (c *checker) timeWindows(v problem.Vehicle, stops []int) {
+	for _, n := range stops {
+		if n < 0 || n >= len(c.p.Nodes) {
+			return
+		}
+	}
+	for k, t := range c.p.Schedule(v, stops) {
+		node := c.p.Nodes[stops[k]]
+		if node.DueHours > 0 && t > node.DueHours+timeEpsilon {
+			c.add(models.Violation{
+				Constraint: "time_window",
+				VehicleID:  v.ID,
+				NodeID:     node.ID,
+				Message:    fmt.Sprintf("arrives %.2fh after the window closes", t-node.DueHours),
+			})
+		}
+	}
+}
+
+// curfews warns where the schedule waits out a curfew, which it plans
+// around but which is time the vehicle stands idle
+func (c *checker) curfews(v problem.Vehicle, stops []int) {
+	for _, n := range stops {
+		if n < 0 || n >= len(c.p.Nodes) {
+			return
+		}
+	}
+	for _, h := range c.p.Holds(v, stops) {
+		c.add(models.Violation{
+			Constraint: "curfew",
+			VehicleID:  v.ID,
+			NodeID:     c.p.Nodes[h.Node].ID,
+			Message:    fmt.Sprintf("held %.2fh by curfew %s", h.Hours, h.Curfew),
+			Soft:       true,
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package feasibility
+
+import (
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"strings"
+	"testing"
+)
+
+// fleet is a depot, three stops east of it and two vehicles; b has to be
+// reached first, a takes half an hour, and c takes only a mini-truck
+func fleet() *problem.Problem {
+	return problem.FromFleetRequest(models.FleetRequest{
+		Depot: models.Location{Lat: 18.52, Lng: 73.85},
+		Vehicles: []models.VehicleInfo{
+			{ID: "mini", CapacityKg: 500, Type: "mini-truck"},
+			{ID: "big", CapacityKg: 2000, Type: "32ft"},
+		},
+		Stops: []models.FleetStop{
+			{ID: "a", Location: models.Location{Lat: 18.52, Lng: 73.95}, DemandKg: 300, ServiceHours: 0.5},
+			{ID: "b", Location: models.Location{Lat: 18.52, Lng: 74.05}, DemandKg: 300, DueHours: 0.5},
+			{ID: "c", Location: models.Location{Lat: 18.53, Lng: 73.86}, DemandKg: 100, VehicleTypes: []string{"mini-truck"}},
+		},
+	})
+}
+
+// violations summarises a report as "constraint:vehicle/node" entries
+func violations(rep models.FeasibilityReport) string {
+	var out []string
+	for _, v := range rep.Violations {
+		s := v.Constraint + ":" + v.VehicleID + "/" + v.NodeID
+		if v.Soft {
+			s += "~"
+		}
+		out = append(out, s)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestCheck(t *testing.T) {
+	p := fleet()
+	if !p.Constraints.Compatibility || !p.Constraints.TimeWindows {
+		t.Fatalf("constraints = %+v", p.Constraints)
+	}
+	for _, tc := range []struct {
+		name     string
+		sol      problem.Solution
+		want     string
+		feasible bool
+	}{
+		{
+			name:     "feasible",
+			sol:      problem.Solution{Routes: []problem.Route{{Vehicle: 0, Stops: []int{0, 3, 0}}, {Vehicle: 1, Stops: []int{0, 2, 1, 0}}}},
+			feasible: true,
+		},
+		{
+			name: "over capacity",
+			sol:  problem.Solution{Routes: []problem.Route{{Vehicle: 0, Stops: []int{0, 2, 1, 3, 0}}}},
+			want: "capacity:mini/",
+		},
+		{
+			name: "incompatible",
+			sol:  problem.Solution{Routes: []problem.Route{{Vehicle: 1, Stops: []int{0, 2, 1, 3, 0}}}},
+			want: "compatibility:big/c",
+		},
+		{
+			name: "late",
+			sol:  problem.Solution{Routes: []problem.Route{{Vehicle: 1, Stops: []int{0, 1, 2, 0}}}, Unassigned: []int{3}},
+			want: "time_window:big/b",
+		},
+		{
+			name: "missing and repeated",
+			sol:  problem.Solution{Routes: []problem.Route{{Vehicle: 1, Stops: []int{0, 2, 2, 0}}}, Unassigned: []int{3}},
+			want: "coverage:/a,coverage:/b",
+		},
+		{
+			name: "wrong endpoints",
+			sol:  problem.Solution{Routes: []problem.Route{{Vehicle: 1, Stops: []int{2, 1, 0}}}, Unassigned: []int{3}},
+			want: "endpoint:big/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rep := Check(p, tc.sol)
+			if got := violations(rep); got != tc.want || rep.Feasible != tc.feasible {
+				t.Errorf("violations %q, feasible %v; want %q, %v", got, rep.Feasible, tc.want, tc.feasible)
+			}
+		})
+	}
+}
+
+func TestCheckLoadPlan(t *testing.T) {
+	req := models.LoadRequest{
+		Vehicles: []models.VehicleInfo{{ID: "reefer", CapacityKg: 1000, Type: "reefer"}, {ID: "open", CapacityKg: 1000}},
+		Shipments: []models.ShipmentInfo{
+			{ID: "milk", WeightKg: 400, VehicleTypes: []string{"reefer"}},
+			{ID: "bricks", WeightKg: 900},
+		},
+	}
+	ok := CheckLoadPlan(req, []models.Allocation{{VehicleID: "reefer", ShipmentIDs: []string{"milk"}}, {VehicleID: "open", ShipmentIDs: []string{"bricks"}}})
+	if !ok.Feasible || len(ok.Violations) != 0 {
+		t.Errorf("valid plan: %s", violations(ok))
+	}
+	bad := CheckLoadPlan(req, []models.Allocation{{VehicleID: "open", ShipmentIDs: []string{"milk", "bricks", "sand"}}, {VehicleID: "van"}})
+	if got := violations(bad); got != "coverage:/sand,coverage:van/,compatibility:open/milk,capacity:open/" || bad.Feasible {
+		t.Errorf("invalid plan: %s", got)
+	}
+}
+
+func TestCheckRoutePlan(t *testing.T) {
+	a, b := models.Location{Lat: 1}, models.Location{Lat: 2}
+	req := models.OptimizationRequest{Start: models.Location{}, End: models.Location{Lat: 3}, Waypoints: []models.Location{a, b}}
+	if rep := CheckRoutePlan(req, []models.Location{req.Start, b, a, req.End}); !rep.Feasible {
+		t.Errorf("valid order: %s", violations(rep))
+	}
+	if got := violations(CheckRoutePlan(req, []models.Location{req.Start, a, {Lat: 9}, req.End})); got != "coverage:/,coverage:/waypoint-1" {
+		t.Errorf("invalid order: %s", got)
+	}
+}
+
+func TestPrecheck(t *testing.T) {
+	p := fleet()
+	if rep := Precheck(p); !rep.Feasible || len(rep.Violations) != 0 {
+		t.Errorf("clean fleet: %s", violations(rep))
+	}
+
+	// Without the mini-truck, stop c has no vehicle it allows, a and b are
+	// too heavy for the van left, and b cannot be reached in time
+	p.Vehicles = []problem.Vehicle{{ID: "van", CapacityKg: 250, Type: "van", Start: 0, End: 0}}
+	p.Nodes[2].DueHours = 0.01
+	rep := Precheck(p)
+	if got := violations(rep); got != "capacity:/a~,capacity:/b~,capacity:/~,compatibility:/c~,time_window:/b~" {
+		t.Errorf("Precheck = %s", got)
+	}
+	if !rep.Feasible {
+		t.Error("prechecks are warnings, not failures")
+	}
+}
@@ -0,0 +1,95 @@
+package feasibility
+
+import (
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+)
+
+// CheckLoadPlan validates allocations produced outside this service
+func CheckLoadPlan(req models.LoadRequest, allocations []models.Allocation) models.FeasibilityReport {
+	p := problem.FromLoadRequest(req)
+
+	nodeIdx := map[string]int{}
+	for i, n := range p.Nodes {
+		nodeIdx[n.ID] = i
+	}
+	vehicleIdx := map[string]int{}
+	for i, v := range p.Vehicles {
+		vehicleIdx[v.ID] = i
+	}
+
+	c := &checker{p: p}
+	sol := problem.Solution{}
+	served := map[int]bool{}
+
+	for _, a := range allocations {
+		vi, ok := vehicleIdx[a.VehicleID]
+		if !ok {
+			c.add(models.Violation{Constraint: "coverage", VehicleID: a.VehicleID, Message: "unknown vehicle"})
+			continue
+		}
+		r := problem.Route{Vehicle: vi}
+		for _, id := range a.ShipmentIDs {
+			ni, ok := nodeIdx[id]
+			if !ok {
+				c.add(models.Violation{Constraint: "coverage", NodeID: id, Message: "unknown shipment"})
+				continue
+			}
+			r.Stops = append(r.Stops, ni)
+			served[ni] = true
+		}
+		sol.Routes = append(sol.Routes, r)
+	}
+
+	// Shipments left out of the plan count as unassigned, not missing
+	for i := range p.Nodes {
+		if !served[i] {
+			sol.Unassigned = append(sol.Unassigned, i)
+		}
+	}
+
+	c.coverage(sol)
+	for _, r := range sol.Routes {
+		c.route(r)
+	}
+	return c.report()
+}
+
+// CheckRoutePlan validates a visiting order for a route request. Stops are
+// matched to request locations by exact coordinates.
+func CheckRoutePlan(req models.OptimizationRequest, plan []models.Location) models.FeasibilityReport {
+	p := problem.FromRouteRequest(req)
+	c := &checker{p: p}
+
+	used := make([]bool, len(p.Nodes))
+	stops := make([]int, 0, len(plan))
+	for i, loc := range plan {
+		idx := -1
+		switch {
+		case i == 0 && loc == req.Start:
+			idx = 0
+		case i == len(plan)-1 && loc == req.End:
+			idx = len(p.Nodes) - 1
+		default:
+			for j := 1; j < len(p.Nodes)-1; j++ {
+				if !used[j] && p.Nodes[j].Location == loc {
+					idx = j
+					break
+				}
+			}
+		}
+		if idx == -1 {
+			c.add(models.Violation{Constraint: "coverage", Message: "plan contains a location not in the request"})
+			continue
+		}
+		if idx > 0 && idx < len(p.Nodes)-1 {
+			used[idx] = true
+		}
+		stops = append(stops, idx)
+	}
+
+	sol := problem.Solution{Routes: []problem.Route{{Vehicle: 0, Stops: stops}}}
+	c.coverage(sol)
+	c.route(sol.Routes[0])
+	return c.report()
+}
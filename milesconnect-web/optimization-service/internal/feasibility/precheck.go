@@ -0,0 +1,110 @@
+package feasibility
+
+import (
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"strings"
+)
+
+// Precheck looks for what p's solve cannot fix before running it: stops no
+// vehicle has room for or is of a type allowed to serve, more demand than
+// the fleet can carry, windows that close before any vehicle can get there
+// and deadlines every vehicle departs after. Solvers leave such stops unassigned or late rather than
+// fail, so they are reported as soft violations.
+func Precheck(p *problem.Problem) models.FeasibilityReport {
+	c := &checker{p: p}
+	if p.Constraints.Capacity {
+		c.capacityTotals()
+	}
+	if p.Constraints.Compatibility {
+		c.compatibleVehicles()
+	}
+	if p.Constraints.TimeWindows && p.Type == problem.TypeRouting {
+		c.reachableWindows()
+	}
+	if p.Constraints.Deadlines {
+		c.catchableDeadlines()
+	}
+	return c.report()
+}
+
+func (c *checker) capacityTotals() {
+	room, total := 0.0, 0.0
+	for _, v := range c.p.Vehicles {
+		if v.CapacityKg <= 0 {
+			return // Unlimited
+		}
+		room = math.Max(room, v.CapacityKg-v.InitialLoadKg)
+		total += v.CapacityKg - v.InitialLoadKg
+	}
+	demand := 0.0
+	for _, n := range c.p.Stops() {
+		node := c.p.Nodes[n]
+		demand += node.DemandKg
+		if kg := math.Max(node.DemandKg, node.ReturnKg); kg > room+capacityEpsilon {
+			c.add(models.Violation{Constraint: "capacity", NodeID: node.ID, Soft: true,
+				Message: fmt.Sprintf("%.2f kg is more than any vehicle has room for (%.2f kg at most)", kg, room)})
+		}
+	}
+	if demand > total+capacityEpsilon {
+		c.add(models.Violation{Constraint: "capacity", Soft: true,
+			Message: fmt.Sprintf("shipments total %.2f kg but the fleet has room for %.2f kg; some will be left unassigned", demand, total)})
+	}
+}
+
+// compatibleVehicles checks each stop allowing only some vehicle types has
+// a vehicle of one of them in the fleet
+func (c *checker) compatibleVehicles() {
+	for _, n := range c.p.Stops() {
+		if !slices.ContainsFunc(c.p.Vehicles, func(v problem.Vehicle) bool { return c.p.Compatible(v, n) }) {
+			node := c.p.Nodes[n]
+			c.add(models.Violation{Constraint: "compatibility", NodeID: node.ID, Soft: true,
+				Message: "no vehicle is of a type allowed to serve it (" + strings.Join(node.VehicleTypes, ", ") + ")"})
+		}
+	}
+}
+
+// reachableWindows checks each stop's window against the earliest any
+// vehicle could be there, driving straight from its start
+func (c *checker) reachableWindows() {
+	for _, n := range c.p.Stops() {
+		node := c.p.Nodes[n]
+		if node.DueHours <= 0 {
+			continue
+		}
+		earliest := math.Inf(1)
+		for _, v := range c.p.Vehicles {
+			if v.Start < 0 {
+				earliest = math.Min(earliest, math.Max(v.DepartHours, node.ReadyHours))
+				continue
+			}
+			earliest = math.Min(earliest, c.p.Schedule(v, []int{v.Start, n})[1])
+		}
+		if earliest > node.DueHours+timeEpsilon {
+			c.add(models.Violation{Constraint: "time_window", NodeID: node.ID, Soft: true,
+				Message: fmt.Sprintf("no vehicle can arrive before the window closes; the earliest is %.2fh late", earliest-node.DueHours)})
+		}
+	}
+}
+
+// catchableDeadlines checks each shipment has a vehicle leaving by its
+// deadline
+func (c *checker) catchableDeadlines() {
+	for _, n := range c.p.Stops() {
+		node := c.p.Nodes[n]
+		if node.LatePenaltyPerHour <= 0 {
+			continue
+		}
+		first := math.Inf(1)
+		for _, v := range c.p.Vehicles {
+			first = math.Min(first, v.DepartHours)
+		}
+		if first > node.DeadlineHours {
+			c.add(models.Violation{Constraint: "deadline", NodeID: node.ID, Soft: true,
+				Message: fmt.Sprintf("every vehicle departs after the deadline, the first %.1fh after", first-node.DeadlineHours)})
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package fixtures
+
+import "math"
+
+// ExactPathKm solves the open path Start -> all waypoints -> End exactly with
+// Held-Karp dynamic programming over matrix m, where node 0 is the start and
+// node len(m)-1 the end. Only practical for up to ~16 nodes.
+func ExactPathKm(m [][]float64) float64 {
+	n := len(m)
+	if n <= 2 {
+		return m[0][n-1]
+	}
+
+	k := n - 2 // Waypoints are nodes 1..k
+	full := 1 << k
+	dp := make([][]float64, full)
+	for mask := range dp {
+		dp[mask] = make([]float64, k)
+		for i := range dp[mask] {
+			dp[mask][i] = math.Inf(1)
+		}
+	}
+	for i := 0; i < k; i++ {
+		dp[1<<i][i] = m[0][i+1]
+	}
+
+	for mask := 1; mask < full; mask++ {
+		for last := 0; last < k; last++ {
+			cur := dp[mask][last]
+			if math.IsInf(cur, 1) || mask&(1<<last) == 0 {
+				continue
+			}
+			for next := 0; next < k; next++ {
+				if mask&(1<<next) != 0 {
+					continue
+				}
+				nm := mask | 1<<next
+				if d := cur + m[last+1][next+1]; d < dp[nm][next] {
+					dp[nm][next] = d
+				}
+			}
+		}
+	}
+
+	best := math.Inf(1)
+	for last := 0; last < k; last++ {
+		if d := dp[full-1][last] + m[last+1][n-1]; d < best {
+			best = d
+		}
+	}
+	return best
+}
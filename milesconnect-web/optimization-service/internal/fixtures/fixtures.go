@@ -0,0 +1,118 @@
+// Package fixtures holds canonical problem instances with known optimal or
+// reference values, shared by the solver and handler tests.
+package fixtures
+
+import "milesconnect-optimization/internal/models"
+
+// RouteInstance is a TSP instance with a known optimum in km
+type RouteInstance struct {
+	Name      string
+	Request   models.OptimizationRequest
+	OptimumKm float64
+}
+
+// RouteInstances returns every canonical routing instance
+func RouteInstances() []RouteInstance {
+	return []RouteInstance{
+		square(),
+		line(),
+		burma14Instance(),
+	}
+}
+
+// square is a unit square given as a matrix; the optimal tour is its perimeter
+func square() RouteInstance {
+	return RouteInstance{
+		Name: "square",
+		Request: models.OptimizationRequest{
+			Start:     models.Location{Lat: 0, Lng: 0},
+			End:       models.Location{Lat: 0, Lng: 0},
+			Waypoints: []models.Location{{Lat: 0, Lng: 1}, {Lat: 1, Lng: 1}, {Lat: 1, Lng: 0}},
+			DistanceMatrix: [][]float64{
+				{0, 1, 1.41421356, 1, 0},
+				{1, 0, 1, 1.41421356, 1},
+				{1.41421356, 1, 0, 1, 1.41421356},
+				{1, 1.41421356, 1, 0, 1},
+				{0, 1, 1.41421356, 1, 0},
+			},
+		},
+		OptimumKm: 4,
+	}
+}
+
+// line has stops along one meridian out of order; the optimum is straight out
+// and back, so any solver should find it
+func line() RouteInstance {
+	req := models.OptimizationRequest{
+		Start: models.Location{Lat: 10, Lng: 77},
+		End:   models.Location{Lat: 10, Lng: 77},
+		Waypoints: []models.Location{
+			{Lat: 13, Lng: 77}, {Lat: 11, Lng: 77}, {Lat: 14, Lng: 77}, {Lat: 12, Lng: 77},
+		},
+	}
+	return RouteInstance{
+		Name:      "line",
+		Request:   req,
+		OptimumKm: 2 * 4 * 111.19492664455873, // 4 degrees of latitude out and back
+	}
+}
+
+// burma14Instance closes the TSPLIB tour at city 1
+func burma14Instance() RouteInstance {
+	coords := append(append([][2]float64{}, burma14...), burma14[0])
+	locs := make([]models.Location, len(coords))
+	for i, c := range coords {
+		locs[i] = models.Location{Lat: c[0], Lng: c[1]}
+	}
+
+	return RouteInstance{
+		Name: "burma14",
+		Request: models.OptimizationRequest{
+			Start:          locs[0],
+			End:            locs[len(locs)-1],
+			Waypoints:      locs[1 : len(locs)-1],
+			DistanceMatrix: geoMatrix(coords),
+		},
+		OptimumKm: 3323,
+	}
+}
+
+// LoadInstance is an allocation instance with a known outcome
+type LoadInstance struct {
+	Name           string
+	Request        models.LoadRequest
+	WantUnassigned int
+}
+
+// LoadInstances returns every canonical allocation instance
+func LoadInstances() []LoadInstance {
+	return []LoadInstance{
+		{
+			Name: "exact-fit",
+			Request: models.LoadRequest{
+				Vehicles:  []models.VehicleInfo{{ID: "V1", CapacityKg: 100}, {ID: "V2", CapacityKg: 50}},
+				Shipments: []models.ShipmentInfo{{ID: "A", WeightKg: 60}, {ID: "B", WeightKg: 40}, {ID: "C", WeightKg: 50}},
+			},
+			WantUnassigned: 0,
+		},
+		{
+			Name: "oversized",
+			Request: models.LoadRequest{
+				Vehicles:  []models.VehicleInfo{{ID: "V1", CapacityKg: 100, CurrentLoad: 20}},
+				Shipments: []models.ShipmentInfo{{ID: "A", WeightKg: 90}, {ID: "B", WeightKg: 80}},
+			},
+			WantUnassigned: 1,
+		},
+		{
+			Name: "late-drop",
+			Request: models.LoadRequest{
+				Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 100, DepartHours: 10}},
+				Shipments: []models.ShipmentInfo{
+					{ID: "A", WeightKg: 10, DeadlineHours: 2, LatePenaltyPerHour: 500, DropPenalty: 200},
+					{ID: "B", WeightKg: 10},
+				},
+			},
+			WantUnassigned: 1,
+		},
+	}
+}
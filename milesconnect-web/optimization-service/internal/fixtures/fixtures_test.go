@@ -0,0 +1,32 @@
+package fixtures
+
+import (
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+)
+
+// The declared optima must agree with an exact solve, otherwise every solver
+// test built on them is meaningless
+func TestDeclaredOptima(t *testing.T) {
+	for _, inst := range RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			m := p.Matrix
+			if m == nil {
+				m = make([][]float64, len(p.Nodes))
+				for i := range m {
+					m[i] = make([]float64, len(p.Nodes))
+					for j := range m[i] {
+						m[i][j] = p.Distance(i, j)
+					}
+				}
+			}
+
+			got := ExactPathKm(m)
+			if math.Abs(got-inst.OptimumKm) > 1e-3 {
+				t.Errorf("exact optimum = %.4f, declared %.4f", got, inst.OptimumKm)
+			}
+		})
+	}
+}
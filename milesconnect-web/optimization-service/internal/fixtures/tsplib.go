@@ -0,0 +1,41 @@
+package fixtures
+
+import "math"
+
+// TSPLIB GEO coordinates are DDD.MM (degrees and minutes), not decimal degrees
+func geoRadians(x float64) float64 {
+	const pi = 3.141592
+	deg := math.Trunc(x)
+	min := x - deg
+	return pi * (deg + 5.0*min/3.0) / 180.0
+}
+
+// geoMatrix builds the integer TSPLIB GEO distance matrix for coords
+func geoMatrix(coords [][2]float64) [][]float64 {
+	const rrr = 6378.388
+	n := len(coords)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		for j := range m[i] {
+			if i == j {
+				continue
+			}
+			lat1, lng1 := geoRadians(coords[i][0]), geoRadians(coords[i][1])
+			lat2, lng2 := geoRadians(coords[j][0]), geoRadians(coords[j][1])
+			q1 := math.Cos(lng1 - lng2)
+			q2 := math.Cos(lat1 - lat2)
+			q3 := math.Cos(lat1 + lat2)
+			m[i][j] = math.Trunc(rrr*math.Acos(0.5*((1.0+q1)*q2-(1.0-q1)*q3)) + 1.0)
+		}
+	}
+	return m
+}
+
+// burma14 from TSPLIB (optimal tour length 3323)
+var burma14 = [][2]float64{
+	{16.47, 96.10}, {16.47, 94.44}, {20.09, 92.54}, {22.39, 93.37},
+	{25.23, 97.24}, {22.00, 96.05}, {20.47, 97.02}, {17.20, 96.29},
+	{16.30, 97.38}, {14.05, 98.12}, {16.53, 97.38}, {21.52, 95.59},
+	{19.41, 97.13}, {20.09, 94.55},
+}
@@ -0,0 +1,195 @@
+// Package flags gates features per tenant, so an experimental solver or
+// constraint type can be trialled with one customer before everyone gets
+// it. A feature no flag names is on for everyone; a flag turns it on for
+// every tenant when Enabled, else only for the tenants it lists.
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"milesconnect-optimization/internal/secrets"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefixes of the flags gating solvers and constraint types, e.g.
+// "solver.alns" and "constraint.time_windows"
+const (
+	SolverPrefix     = "solver."
+	ConstraintPrefix = "constraint."
+)
+
+// RoadDistances gates fetching road distances, from the precomputed cache
+// or the routing provider, in place of great-circle ones
+const RoadDistances = "road_distances"
+
+// Flag gates the feature it names
+type Flag struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Enabled     bool     `json:"enabled"`           // On for every tenant
+	Tenants     []string `json:"tenants,omitempty"` // On for these while not Enabled
+}
+
+// On reports whether f turns its feature on for tenant
+func (f Flag) On(tenant string) bool {
+	return f.Enabled || tenant != "" && slices.Contains(f.Tenants, tenant)
+}
+
+// Validate checks f's name
+func (f Flag) Validate() error {
+	if f.Name == "" || strings.ContainsAny(f.Name, " \t\n") {
+		return fmt.Errorf("flag name %q must be set and free of spaces", f.Name)
+	}
+	return nil
+}
+
+// Set is the flags in force. It is safe for concurrent use.
+type Set struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// New returns a set with no flags: every feature on
+func New() *Set {
+	return &Set{flags: map[string]Flag{}}
+}
+
+// Allows reports whether the feature name is on for tenant
+func (s *Set) Allows(name, tenant string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.flags[name]
+	return !ok || f.On(tenant)
+}
+
+// List returns the flags sorted by name
+func (s *Set) List() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		list = append(list, f)
+	}
+	slices.SortFunc(list, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Put adds f, or replaces the flag of its name
+func (s *Set) Put(f Flag) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[f.Name] = f
+	return nil
+}
+
+// Delete drops the flag name, turning its feature on for everyone, and
+// reports whether there was one
+func (s *Set) Delete(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.flags[name]
+	delete(s.flags, name)
+	return ok
+}
+
+// Replace swaps every flag for list, as loaded from a file or fetched
+func (s *Set) Replace(list []Flag) error {
+	flags := make(map[string]Flag, len(list))
+	for _, f := range list {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		if _, dup := flags[f.Name]; dup {
+			return fmt.Errorf("flag %q is listed twice", f.Name)
+		}
+		flags[f.Name] = f
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags = flags
+	return nil
+}
+
+// Parse reads a JSON array of flags
+func Parse(body []byte) ([]Flag, error) {
+	var list []Flag
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Load reads the flags in the file at path
+func Load(path string) ([]Flag, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list, err := Parse(body)
+	if err != nil {
+		return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return list, nil
+}
+
+// maxFetchBytes bounds a fetched flag list
+const maxFetchBytes = 1 << 20
+
+// Fetch reads the flags served at url, e.g. by a config service. Its
+// errors give url masked, since it may carry a token.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]Flag, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("flags: bad URL %s", secrets.MaskURL(url))
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if ue, ok := err.(*neturl.Error); ok {
+		ue.URL = secrets.MaskURL(ue.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("flags: %s answered %s", secrets.MaskURL(url), resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(body)
+}
+
+// Poll replaces s's flags with those served at url every interval, keeping
+// the last good ones while fetching fails, until ctx is done. Failures go
+// to onError.
+func Poll(ctx context.Context, s *Set, client *http.Client, url string, every time.Duration, onError func(error)) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		list, err := Fetch(ctx, client, url)
+		if err == nil {
+			err = s.Replace(list)
+		}
+		if err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+}
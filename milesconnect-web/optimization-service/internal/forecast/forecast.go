@@ -0,0 +1,84 @@
+// Package forecast projects daily shipment volumes forward for capacity
+// planning: a trailing moving average, or additive Holt-Winters when there
+// is enough history to see the weekly pattern.
+package forecast
+
+import (
+	"errors"
+	"math"
+)
+
+// Method is a forecasting model
+type Method string
+
+const (
+	MovingAverage Method = "moving_average"
+	HoltWinters   Method = "holt_winters"
+)
+
+// Default smoothing factors for Holt-Winters: level reacts fastest, trend
+// slowest, so one busy day does not read as growth
+const (
+	DefaultAlpha = 0.3
+	DefaultBeta  = 0.05
+	DefaultGamma = 0.2
+)
+
+var ErrHistory = errors.New("forecast: not enough history")
+
+// Average forecasts every day ahead as the mean of the last window days
+func Average(series []float64, window, horizon int) ([]float64, error) {
+	if window < 1 || len(series) < window {
+		return nil, ErrHistory
+	}
+	sum := 0.0
+	for _, v := range series[len(series)-window:] {
+		sum += v
+	}
+	out := make([]float64, horizon)
+	for i := range out {
+		out[i] = sum / float64(window)
+	}
+	return out, nil
+}
+
+// Smooth forecasts with additive Holt-Winters over seasons of season days.
+// It needs two full seasons to initialise the level, trend and seasonal
+// components. Forecasts are not allowed below zero.
+func Smooth(series []float64, season, horizon int, alpha, beta, gamma float64) ([]float64, error) {
+	if season < 2 || len(series) < 2*season {
+		return nil, ErrHistory
+	}
+
+	// Level and trend from the first two seasons' means; seasonal offsets
+	// from the first season against its mean
+	first, second := mean(series[:season]), mean(series[season:2*season])
+	level := first
+	trend := (second - first) / float64(season)
+	seasonal := make([]float64, season)
+	for i := range season {
+		seasonal[i] = series[i] - first
+	}
+
+	for t := season; t < len(series); t++ {
+		s := seasonal[t%season]
+		prev := level
+		level = alpha*(series[t]-s) + (1-alpha)*(level+trend)
+		trend = beta*(level-prev) + (1-beta)*trend
+		seasonal[t%season] = gamma*(series[t]-level) + (1-gamma)*s
+	}
+
+	out := make([]float64, horizon)
+	for h := range out {
+		out[h] = math.Max(0, level+float64(h+1)*trend+seasonal[(len(series)+h)%season])
+	}
+	return out, nil
+}
+
+func mean(vals []float64) float64 {
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
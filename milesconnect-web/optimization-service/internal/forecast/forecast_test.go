@@ -0,0 +1,45 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAverage(t *testing.T) {
+	got, err := Average([]float64{100, 10, 20, 30}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 20 || got[1] != 20 {
+		t.Errorf("got %v, want [20 20]", got)
+	}
+	if _, err := Average([]float64{1, 2}, 3, 1); !errors.Is(err, ErrHistory) {
+		t.Errorf("short history: got %v", err)
+	}
+}
+
+func TestSmoothFollowsWeeklyPattern(t *testing.T) {
+	// Four weeks of a quiet Sunday and a slowly growing week
+	week := []float64{50, 120, 110, 115, 125, 140, 90}
+	var series []float64
+	for w := range 4 {
+		for _, v := range week {
+			series = append(series, v+float64(w)*7)
+		}
+	}
+	got, err := Smooth(series, 7, 7, DefaultAlpha, DefaultBeta, DefaultGamma)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range got {
+		want := week[i] + 28
+		if math.Abs(v-want) > 10 {
+			t.Errorf("day %d: got %.1f, want about %.0f", i, v, want)
+		}
+	}
+
+	if _, err := Smooth(series[:13], 7, 7, DefaultAlpha, DefaultBeta, DefaultGamma); !errors.Is(err, ErrHistory) {
+		t.Errorf("short history: got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package fuel
+
+import "time"
+
+// Efficiency is how far a vehicle ran per litre between full-tank fills
+type Efficiency struct {
+	VehicleID     string    `json:"vehicle_id"`
+	KmPerLitre    float64   `json:"km_per_litre"`
+	Km            float64   `json:"km"`
+	Litres        float64   `json:"litres"`
+	Intervals     int       `json:"intervals"` // Full-to-full stretches measured
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	PricePerLitre float64   `json:"price_per_litre,omitempty"` // At the latest priced fill
+}
+
+// Measure computes a vehicle's efficiency from its fills since since (zero
+// for all of them) by the full-to-full method: the fuel bought at every fill
+// after a full tank up to and including the next full tank was burnt over
+// the km between the two. Partial fills before the first full tank cannot
+// be attributed and are ignored. ok is false until two full tanks bound a
+// stretch with distance.
+func (l *Log) Measure(vehicleID string, since time.Time) (e Efficiency, ok bool) {
+	e.VehicleID = vehicleID
+	start := -1.0 // Odometer at the last full tank; -1 before the first
+	litres := 0.0
+	for _, f := range l.Fills(vehicleID) {
+		if f.PricePerLitre > 0 {
+			e.PricePerLitre = f.PricePerLitre
+		}
+		if f.At.Before(since) {
+			continue
+		}
+		if start < 0 {
+			if f.Full {
+				start, e.From = f.OdometerKm, f.At
+			}
+			continue
+		}
+		litres += f.Litres
+		if !f.Full {
+			continue
+		}
+		if km := f.OdometerKm - start; km > 0 {
+			e.Km += km
+			e.Litres += litres
+			e.Intervals++
+			e.To = f.At
+		}
+		start, litres = f.OdometerKm, 0
+	}
+	if e.Intervals == 0 || e.Litres == 0 {
+		return Efficiency{VehicleID: vehicleID, PricePerLitre: e.PricePerLitre}, false
+	}
+	e.KmPerLitre = e.Km / e.Litres
+	return e, true
+}
@@ -0,0 +1,175 @@
+// Package fuel keeps each vehicle's odometer readings and fuel fills and
+// measures how far it actually runs on a litre, so fuel estimates follow
+// the vehicle rather than a datasheet figure.
+package fuel
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalid  = errors.New("fuel: invalid fill")
+	ErrOdometer = errors.New("fuel: odometer reading out of order")
+)
+
+// Fill is an odometer reading and the fuel added at it. A reading without
+// fuel has zero Litres. Full marks a fill to the brim, which is what
+// efficiency is measured between.
+type Fill struct {
+	VehicleID     string    `json:"vehicle_id"`
+	At            time.Time `json:"at"`
+	OdometerKm    float64   `json:"odometer_km"`
+	Litres        float64   `json:"litres,omitempty"`
+	Full          bool      `json:"full_tank,omitempty"`
+	PricePerLitre float64   `json:"price_per_litre,omitempty"`
+}
+
+// Validate checks the fill on its own; Add also checks it against the
+// vehicle's other readings
+func (f Fill) Validate() error {
+	switch {
+	case f.VehicleID == "":
+		return fmt.Errorf("%w: vehicle_id is required", ErrInvalid)
+	case f.At.IsZero():
+		return fmt.Errorf("%w: at is required", ErrInvalid)
+	case f.OdometerKm < 0 || f.Litres < 0 || f.PricePerLitre < 0:
+		return fmt.Errorf("%w: odometer, litres and price must not be negative", ErrInvalid)
+	case f.Full && f.Litres == 0:
+		return fmt.Errorf("%w: a full tank fill needs litres", ErrInvalid)
+	}
+	return nil
+}
+
+// Log holds the fills by vehicle in time order, optionally backed by a JSON
+// Lines file. It is safe for concurrent use.
+type Log struct {
+	mu    sync.RWMutex
+	fills map[string][]Fill
+	file  *os.File
+}
+
+// Open reads the log at path and appends new fills to it. An empty path
+// keeps the log in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{fills: map[string][]Fill{}}
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		var fill Fill
+		if err := json.Unmarshal(sc.Bytes(), &fill); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("fuel: line %d: %w", line, err)
+		}
+		l.insert(fill)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+// Add records fills, all or none. Each must keep its vehicle's odometer
+// from running backwards in time.
+func (l *Log) Add(fills ...Fill) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	staged := map[string][]Fill{}
+	for _, f := range fills {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		f.At = f.At.UTC()
+		list, ok := staged[f.VehicleID]
+		if !ok {
+			list = slices.Clone(l.fills[f.VehicleID])
+		}
+		i := position(list, f.At)
+		if (i > 0 && list[i-1].OdometerKm > f.OdometerKm) || (i < len(list) && list[i].OdometerKm < f.OdometerKm) {
+			return fmt.Errorf("%w: %s at %.0f km on %s", ErrOdometer, f.VehicleID, f.OdometerKm, f.At.Format(time.RFC3339))
+		}
+		staged[f.VehicleID] = slices.Insert(list, i, f)
+	}
+
+	if l.file != nil {
+		var buf []byte
+		for _, f := range fills {
+			f.At = f.At.UTC()
+			line, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			buf = append(append(buf, line...), '\n')
+		}
+		if _, err := l.file.Write(buf); err != nil {
+			return err
+		}
+	}
+	for id, list := range staged {
+		l.fills[id] = list
+	}
+	return nil
+}
+
+// Fills returns a vehicle's fills in time order, or every vehicle's by
+// vehicle when vehicleID is empty
+func (l *Log) Fills(vehicleID string) []Fill {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if vehicleID != "" {
+		return append([]Fill{}, l.fills[vehicleID]...)
+	}
+	list := []Fill{}
+	for _, id := range slices.Sorted(maps.Keys(l.fills)) {
+		list = append(list, l.fills[id]...)
+	}
+	return list
+}
+
+// Vehicles returns the IDs of the vehicles with fills, sorted
+func (l *Log) Vehicles() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Sorted(maps.Keys(l.fills))
+}
+
+// Close closes the backing file
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+func (l *Log) insert(f Fill) {
+	list := l.fills[f.VehicleID]
+	l.fills[f.VehicleID] = slices.Insert(list, position(list, f.At), f)
+}
+
+// position is where a fill at t goes among list, after any at the same time
+func position(list []Fill, t time.Time) int {
+	i, _ := slices.BinarySearchFunc(list, t, func(f Fill, t time.Time) int {
+		if f.At.After(t) {
+			return 1
+		}
+		return -1
+	})
+	return i
+}
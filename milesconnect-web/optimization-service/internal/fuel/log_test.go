@@ -0,0 +1,72 @@
+package fuel
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+func TestMeasureFullToFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fuel.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Add(
+		Fill{VehicleID: "V1", At: day, OdometerKm: 1000, Litres: 30},                                                // Before the first full tank
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 1), OdometerKm: 1200, Litres: 60, Full: true},                   // Start
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 3), OdometerKm: 1400, Litres: 20, PricePerLitre: 94},            // Partial
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 2), OdometerKm: 1300},                                           // Reading only, out of order
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 5), OdometerKm: 1600, Litres: 80, Full: true},                   // 400 km on 100 l
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 7), OdometerKm: 1600, Litres: 5, Full: true, PricePerLitre: 96}, // Topped up standing still
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := l.Measure("V1", time.Time{})
+	if !ok || e.Km != 400 || e.Litres != 100 || e.KmPerLitre != 4 || e.Intervals != 1 || e.PricePerLitre != 96 {
+		t.Errorf("got %+v, %v", e, ok)
+	}
+	if _, ok := l.Measure("V1", day.AddDate(0, 0, 2)); ok {
+		t.Error("no full-to-full stretch starts after day 2")
+	}
+	if _, ok := l.Measure("V2", time.Time{}); ok {
+		t.Error("a vehicle without fills has no efficiency")
+	}
+
+	// The odometer cannot run backwards, and a rejected batch adds nothing
+	err = l.Add(
+		Fill{VehicleID: "V2", At: day, OdometerKm: 10},
+		Fill{VehicleID: "V1", At: day.AddDate(0, 0, 4), OdometerKm: 1700},
+	)
+	if !errors.Is(err, ErrOdometer) || len(l.Fills("V2")) != 0 {
+		t.Errorf("got %v with %d V2 fills", err, len(l.Fills("V2")))
+	}
+	l.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := reopened.Fills("V1"); len(got) != 6 || got[2].OdometerKm != 1300 {
+		t.Errorf("reopened fills = %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, f := range []Fill{
+		{At: day},
+		{VehicleID: "V1"},
+		{VehicleID: "V1", At: day, Litres: -1},
+		{VehicleID: "V1", At: day, Full: true},
+	} {
+		if err := f.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%+v: got %v", f, err)
+		}
+	}
+}
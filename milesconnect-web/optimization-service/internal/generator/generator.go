@@ -0,0 +1,185 @@
+// Package generator builds synthetic problem instances for load testing,
+// benchmarks and demos.
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"milesconnect-optimization/internal/models"
+)
+
+// Distribution controls how points are scattered over the bounding box
+type Distribution string
+
+const (
+	Random    Distribution = "random"
+	Clustered Distribution = "clustered"
+	Grid      Distribution = "grid"
+)
+
+// Bounds is a lat/lng bounding box
+type Bounds struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// IndiaBounds roughly covers mainland India
+var IndiaBounds = Bounds{MinLat: 8, MaxLat: 34, MinLng: 69, MaxLng: 92}
+
+// Config describes the instance to generate. The same Seed always yields
+// the same instance.
+type Config struct {
+	Size         int
+	Distribution Distribution
+	Clusters     int // Only for Clustered; defaults to sqrt(Size)
+	Bounds       Bounds
+	Seed         int64
+}
+
+// Points generates Size locations
+func Points(cfg Config) ([]models.Location, error) {
+	if cfg.Size < 0 {
+		return nil, fmt.Errorf("size must not be negative")
+	}
+	if cfg.Bounds == (Bounds{}) {
+		cfg.Bounds = IndiaBounds
+	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	switch cfg.Distribution {
+	case Random, "":
+		return random(rng, cfg), nil
+	case Clustered:
+		return clustered(rng, cfg), nil
+	case Grid:
+		return grid(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", cfg.Distribution)
+	}
+}
+
+// RouteRequest generates a round trip from the first point through the rest
+func RouteRequest(cfg Config) (models.OptimizationRequest, error) {
+	cfg.Size++ // One extra point for the depot
+	pts, err := Points(cfg)
+	if err != nil {
+		return models.OptimizationRequest{}, err
+	}
+	return models.OptimizationRequest{Start: pts[0], End: pts[0], Waypoints: pts[1:]}, nil
+}
+
+// LoadRequest generates Size shipments and enough vehicles to carry roughly
+// 90% of the total weight, so some shipments are usually left over
+func LoadRequest(cfg Config) models.LoadRequest {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	req := models.LoadRequest{
+		Vehicles:  []models.VehicleInfo{},
+		Shipments: make([]models.ShipmentInfo, cfg.Size),
+	}
+
+	total := 0.0
+	for i := range req.Shipments {
+		w := math.Round((5+rng.ExpFloat64()*150)*10) / 10
+		req.Shipments[i] = models.ShipmentInfo{ID: fmt.Sprintf("S%d", i+1), WeightKg: w}
+		total += w
+	}
+
+	capacities := []float64{1000, 2500, 5000, 9000}
+	for fleet := 0.0; fleet < total*0.9; {
+		c := capacities[rng.Intn(len(capacities))]
+		req.Vehicles = append(req.Vehicles, models.VehicleInfo{ID: fmt.Sprintf("V%d", len(req.Vehicles)+1), CapacityKg: c})
+		fleet += c
+	}
+	return req
+}
+
+// FleetRequest generates a depot and Size stops with demands, plus enough
+// 1000 kg vehicles to carry about 120% of the total so every stop fits
+func FleetRequest(cfg Config) (models.FleetRequest, error) {
+	cfg.Size++ // One extra point for the depot
+	pts, err := Points(cfg)
+	if err != nil {
+		return models.FleetRequest{}, err
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	req := models.FleetRequest{Depot: pts[0], Stops: make([]models.FleetStop, len(pts)-1)}
+	total := 0.0
+	for i := range req.Stops {
+		d := math.Round((10+rng.Float64()*190)*10) / 10
+		req.Stops[i] = models.FleetStop{ID: fmt.Sprintf("C%d", i+1), Location: pts[i+1], DemandKg: d}
+		total += d
+	}
+
+	const capacity = 1000
+	for n := 0; n < max(1, int(math.Ceil(total*1.2/capacity))); n++ {
+		req.Vehicles = append(req.Vehicles, models.VehicleInfo{ID: fmt.Sprintf("V%d", n+1), CapacityKg: capacity})
+	}
+	return req, nil
+}
+
+func random(rng *rand.Rand, cfg Config) []models.Location {
+	pts := make([]models.Location, cfg.Size)
+	for i := range pts {
+		pts[i] = uniform(rng, cfg.Bounds)
+	}
+	return pts
+}
+
+// clustered scatters points normally around random centres
+func clustered(rng *rand.Rand, cfg Config) []models.Location {
+	k := min(cfg.Clusters, max(cfg.Size, 1)) // More centres than points go unused
+	if k <= 0 {
+		k = max(1, int(math.Sqrt(float64(cfg.Size))))
+	}
+	centres := make([]models.Location, k)
+	for i := range centres {
+		centres[i] = uniform(rng, cfg.Bounds)
+	}
+
+	b := cfg.Bounds
+	spread := math.Min(b.MaxLat-b.MinLat, b.MaxLng-b.MinLng) / 40
+	pts := make([]models.Location, cfg.Size)
+	for i := range pts {
+		c := centres[rng.Intn(k)]
+		pts[i] = models.Location{
+			Lat: clamp(c.Lat+rng.NormFloat64()*spread, b.MinLat, b.MaxLat),
+			Lng: clamp(c.Lng+rng.NormFloat64()*spread, b.MinLng, b.MaxLng),
+		}
+	}
+	return pts
+}
+
+// grid lays points out row by row on the smallest square grid that fits
+func grid(cfg Config) []models.Location {
+	side := int(math.Ceil(math.Sqrt(float64(cfg.Size))))
+	b := cfg.Bounds
+	step := func(span float64) float64 {
+		if side <= 1 {
+			return 0
+		}
+		return span / float64(side-1)
+	}
+	latStep, lngStep := step(b.MaxLat-b.MinLat), step(b.MaxLng-b.MinLng)
+
+	pts := make([]models.Location, cfg.Size)
+	for i := range pts {
+		pts[i] = models.Location{
+			Lat: b.MinLat + float64(i/side)*latStep,
+			Lng: b.MinLng + float64(i%side)*lngStep,
+		}
+	}
+	return pts
+}
+
+func uniform(rng *rand.Rand, b Bounds) models.Location {
+	return models.Location{
+		Lat: b.MinLat + rng.Float64()*(b.MaxLat-b.MinLat),
+		Lng: b.MinLng + rng.Float64()*(b.MaxLng-b.MinLng),
+	}
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
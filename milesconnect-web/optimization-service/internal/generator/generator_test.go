@@ -0,0 +1,59 @@
+package generator
+
+import "testing"
+
+func TestPointsStayInBounds(t *testing.T) {
+	for _, d := range []Distribution{Random, Clustered, Grid} {
+		t.Run(string(d), func(t *testing.T) {
+			pts, err := Points(Config{Size: 500, Distribution: d, Seed: 7})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pts) != 500 {
+				t.Fatalf("got %d points, want 500", len(pts))
+			}
+			b := IndiaBounds
+			for _, p := range pts {
+				if p.Lat < b.MinLat || p.Lat > b.MaxLat || p.Lng < b.MinLng || p.Lng > b.MaxLng {
+					t.Fatalf("point %+v outside bounds", p)
+				}
+			}
+		})
+	}
+}
+
+func TestSeedIsDeterministic(t *testing.T) {
+	a, _ := Points(Config{Size: 50, Distribution: Clustered, Seed: 42})
+	b, _ := Points(Config{Size: 50, Distribution: Clustered, Seed: 42})
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("point %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+func TestUnknownDistribution(t *testing.T) {
+	if _, err := Points(Config{Size: 1, Distribution: "spiral"}); err == nil {
+		t.Error("expected an error for an unknown distribution")
+	}
+}
+
+func TestFleetRequestFitsFleet(t *testing.T) {
+	req, err := FleetRequest(Config{Size: 40, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Stops) != 40 {
+		t.Fatalf("got %d stops, want 40", len(req.Stops))
+	}
+	demand, capacity := 0.0, 0.0
+	for _, s := range req.Stops {
+		demand += s.DemandKg
+	}
+	for _, v := range req.Vehicles {
+		capacity += v.CapacityKg
+	}
+	if capacity < demand {
+		t.Errorf("fleet capacity %.0f kg is below total demand %.0f kg", capacity, demand)
+	}
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+// Google's documented example
+var example = []models.Location{{Lat: 38.5, Lng: -120.2}, {Lat: 40.7, Lng: -120.95}, {Lat: 43.252, Lng: -126.453}}
+
+const exampleEncoded = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+func TestEncodePolyline(t *testing.T) {
+	if got := EncodePolyline(example, Precision5); got != exampleEncoded {
+		t.Errorf("EncodePolyline = %q, want %q", got, exampleEncoded)
+	}
+	if got := EncodePolyline(nil, Precision5); got != "" {
+		t.Errorf("no points encoded as %q", got)
+	}
+}
+
+func TestDecodePolylineRoundTrips(t *testing.T) {
+	got, err := DecodePolyline(exampleEncoded, Precision5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(example) {
+		t.Fatalf("decoded %d points", len(got))
+	}
+	for i := range got {
+		if math.Abs(got[i].Lat-example[i].Lat) > 1e-9 || math.Abs(got[i].Lng-example[i].Lng) > 1e-9 {
+			t.Errorf("point %d = %v, want %v", i, got[i], example[i])
+		}
+	}
+
+	route := []models.Location{{Lat: 28.613939, Lng: 77.209021}, {Lat: 19.075984, Lng: 72.877656}, {Lat: -33.8688, Lng: 151.2093}}
+	back, err := DecodePolyline(EncodePolyline(route, Precision6), Precision6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range route {
+		if math.Abs(back[i].Lat-route[i].Lat) > 5e-7 || math.Abs(back[i].Lng-route[i].Lng) > 5e-7 {
+			t.Errorf("polyline6 point %d = %v, want %v", i, back[i], route[i])
+		}
+	}
+}
+
+func TestDecodePolylineRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"_p~iF", "_p~iF~ps|", "abc def", "~~~~~~~~~~~~~~~~~~~~~~~~~?"} {
+		if _, err := DecodePolyline(s, Precision5); !errors.Is(err, ErrPolyline) {
+			t.Errorf("%q: err = %v", s, err)
+		}
+	}
+}
+
+func TestDenoiseDropsJitterAndSpikes(t *testing.T) {
+	// Heading north along a road with a parked wobble and one wild fix
+	track := []models.Location{
+		{Lat: 28.6000, Lng: 77.2000},
+		{Lat: 28.6001, Lng: 77.2001}, // Jitter
+		{Lat: 28.6100, Lng: 77.2000},
+		{Lat: 28.6150, Lng: 77.2300}, // Spike
+		{Lat: 28.6200, Lng: 77.2000},
+		{Lat: 28.6300, Lng: 77.2000},
+	}
+	got := Denoise(track)
+	if len(got) != 4 || got[1] != track[2] || got[2] != track[4] {
+		t.Fatalf("Denoise kept %v", got)
+	}
+	if raw, clean := PathKm(track), PathKm(got); clean >= raw || clean < 3.3 || clean > 3.4 {
+		t.Errorf("denoised %.2f km of %.2f, want the 3.34 km straight run", clean, raw)
+	}
+}
+
+func TestGeohashRoundTrips(t *testing.T) {
+	// The reference example from geohash.org
+	p := models.Location{Lat: 57.64911, Lng: 10.40744}
+	if got := EncodeGeohash(p, 7); got != "u4pruyd" {
+		t.Errorf("EncodeGeohash = %q, want u4pruyd", got)
+	}
+	if got := EncodeGeohash(p, 3); got != "u4p" {
+		t.Errorf("shorter geohash = %q, want the prefix u4p", got)
+	}
+
+	sw, ne, ok := GeohashBounds("u4pruyd")
+	if !ok || p.Lat < sw.Lat || p.Lat > ne.Lat || p.Lng < sw.Lng || p.Lng > ne.Lng {
+		t.Errorf("bounds %+v %+v do not hold %+v", sw, ne, p)
+	}
+	if ne.Lat-sw.Lat > 0.0014 || ne.Lng-sw.Lng > 0.0014 {
+		t.Errorf("precision 7 cell is %v by %v degrees", ne.Lat-sw.Lat, ne.Lng-sw.Lng)
+	}
+	if _, _, ok := GeohashBounds("u4a"); ok {
+		t.Error("'a' is not in the alphabet")
+	}
+}
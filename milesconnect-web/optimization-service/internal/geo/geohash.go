@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"milesconnect-optimization/internal/models"
+	"strings"
+)
+
+// MaxGeohashPrecision is the longest geohash EncodeGeohash writes, cells of
+// about 150 m
+const MaxGeohashPrecision = 7
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of p with precision characters: bits
+// alternate longitude and latitude, each halving the cell, five to a
+// base-32 character
+func EncodeGeohash(p models.Location, precision int) string {
+	lat, lng := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var b strings.Builder
+	bit, ch := 0, 0
+	for even := true; b.Len() < precision; even = !even {
+		rng, v := &lat, p.Lat
+		if even {
+			rng, v = &lng, p.Lng
+		}
+		mid := (rng[0] + rng[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		if bit++; bit == 5 {
+			b.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return b.String()
+}
+
+// GeohashBounds returns the south-west and north-east corners of hash's
+// cell; ok is false when hash has a character outside the alphabet
+func GeohashBounds(hash string) (sw, ne models.Location, ok bool) {
+	lat, lng := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for i := range len(hash) {
+		ch := strings.IndexByte(geohashAlphabet, hash[i])
+		if ch < 0 {
+			return models.Location{}, models.Location{}, false
+		}
+		for bit := 4; bit >= 0; bit-- {
+			rng := &lat
+			if even {
+				rng = &lng
+			}
+			mid := (rng[0] + rng[1]) / 2
+			if ch>>bit&1 == 1 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	return models.Location{Lat: lat[0], Lng: lng[0]}, models.Location{Lat: lat[1], Lng: lng[1]}, true
+}
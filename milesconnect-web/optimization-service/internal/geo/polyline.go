@@ -0,0 +1,95 @@
+// Package geo holds geometry helpers shared by the API and providers.
+package geo
+
+import (
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"strings"
+)
+
+// Polyline precisions: Google Maps uses 5 decimal places, OSRM and Valhalla
+// can return 6 ("polyline6")
+const (
+	Precision5 = 5
+	Precision6 = 6
+)
+
+var ErrPolyline = errors.New("invalid encoded polyline")
+
+// EncodePolyline writes points in Google's encoded polyline format: each
+// coordinate as the delta from the previous point, rounded to precision
+// decimal places, in 5-bit chunks of printable ASCII
+func EncodePolyline(points []models.Location, precision int) string {
+	factor := math.Pow10(precision)
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat, lng := int64(math.Round(p.Lat*factor)), int64(math.Round(p.Lng*factor))
+		encodeValue(&b, lat-prevLat)
+		encodeValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	// Zig-zag so small negative deltas stay short
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	b.WriteByte(byte(u) + 63)
+}
+
+// DecodePolyline reads points written by EncodePolyline or any provider
+// using the same format at the same precision
+func DecodePolyline(s string, precision int) ([]models.Location, error) {
+	factor := math.Pow10(precision)
+	var points []models.Location
+	var lat, lng int64
+	for i := 0; i < len(s); {
+		dLat, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		dLng, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		lat, lng = lat+dLat, lng+dLng
+		p := models.Location{Lat: float64(lat) / factor, Lng: float64(lng) / factor}
+		if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+			return nil, ErrPolyline
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// decodeValue reads one value and how many bytes it took
+func decodeValue(s string) (int64, int, error) {
+	var u uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 63 || c > 126 || i >= 12 {
+			return 0, 0, ErrPolyline
+		}
+		chunk := uint64(c - 63)
+		u |= (chunk & 0x1f) << (5 * i)
+		if chunk < 0x20 {
+			v := int64(u >> 1)
+			if u&1 != 0 {
+				v = ^v
+			}
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, ErrPolyline
+}
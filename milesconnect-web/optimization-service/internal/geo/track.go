@@ -0,0 +1,45 @@
+package geo
+
+import (
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+)
+
+// Denoise thresholds: consumer GPS wanders by tens of metres when parked and
+// occasionally jumps hundreds of metres for a single fix
+const (
+	jitterKm    = 0.025
+	spikeKm     = 0.2
+	spikeDetour = 3 // A fix is a spike if visiting it is this many times longer than skipping it
+)
+
+// PathKm is the length of a path along great circles
+func PathKm(path []models.Location) float64 {
+	km := 0.0
+	for i := 1; i < len(path); i++ {
+		km += problem.Haversine(path[i-1], path[i])
+	}
+	return km
+}
+
+// Denoise drops fixes that would inflate a GPS track's length without a road
+// network to snap it to: wandering around a standing vehicle, and lone fixes
+// far off the line between their neighbours. The first and last fixes stay.
+func Denoise(track []models.Location) []models.Location {
+	if len(track) < 3 {
+		return track
+	}
+	out := []models.Location{track[0]}
+	for i := 1; i < len(track)-1; i++ {
+		prev, cur, next := out[len(out)-1], track[i], track[i+1]
+		step := problem.Haversine(prev, cur)
+		if step < jitterKm {
+			continue
+		}
+		if step > spikeKm && step+problem.Haversine(cur, next) > spikeDetour*problem.Haversine(prev, next) {
+			continue
+		}
+		out = append(out, cur)
+	}
+	return append(out, track[len(track)-1])
+}
@@ -0,0 +1,318 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Object is a type in the schema. Fields listed here are resolved by their
+// functions; any other field is read from the value's JSON encoding, so
+// plain data needs no resolvers and nested objects in it can be selected
+// from too. A field absent from the encoding is null.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a resolved field
+type Field struct {
+	Args []string // Accepted argument names
+	Type *Object  // Of the result, or of each element of a slice; nil for plain data
+
+	// Resolve returns the field's value on source, the parent object's
+	// value (nil at the root)
+	Resolve func(ctx context.Context, source any, args map[string]any) (any, error)
+}
+
+// Schema is the root query type, with the limits a request is held to;
+// zero limits are unlimited
+type Schema struct {
+	Query *Object
+
+	// MaxAliases bounds the aliased fields in a document, which can ask for
+	// the same costly field many times over
+	MaxAliases int
+	// MaxNodes bounds the fields and list elements in a response. Past it
+	// the rest of the response is null, with an error saying so.
+	MaxNodes int
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// could not be executed at all.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is a problem with the request or with resolving one field, whose
+// value is then null
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Execute runs req against s. It returns an error, and no response, if the
+// request cannot be parsed or names no operation it holds.
+func (s *Schema) Execute(ctx context.Context, req Request) (Response, error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{}, err
+	}
+	var op *operation
+	for i := range doc.operations {
+		if o := &doc.operations[i]; req.OperationName == "" || o.name == req.OperationName {
+			if op != nil {
+				return Response{}, fmt.Errorf("the document has several operations; name one in operationName")
+			}
+			op = o
+		}
+	}
+	if op == nil {
+		return Response{}, fmt.Errorf("no operation named %q", req.OperationName)
+	}
+	if s.MaxAliases > 0 && doc.aliases > s.MaxAliases {
+		return Response{}, fmt.Errorf("the document has %d aliases; at most %d are allowed", doc.aliases, s.MaxAliases)
+	}
+
+	vars := map[string]any{}
+	for name, v := range op.variables {
+		vars[name] = v
+	}
+	for name, v := range req.Variables {
+		if !op.declared[name] {
+			return Response{}, fmt.Errorf("variable $%s is not declared", name)
+		}
+		vars[name] = v
+	}
+
+	e := &executor{ctx: ctx, vars: vars, limit: s.MaxNodes}
+	data := e.object(s.Query, nil, op.selection, nil)
+	return Response{Data: data, Errors: e.errs}, nil
+}
+
+type executor struct {
+	ctx   context.Context
+	vars  map[string]any
+	errs  []Error
+	limit int // Schema.MaxNodes
+	nodes int
+}
+
+func (e *executor) fail(path []any, err error) {
+	e.errs = append(e.errs, Error{Message: err.Error(), Path: slices.Clone(path)})
+}
+
+// spend counts a node at path against the limit and reports whether it
+// fits; the first that does not is reported as an error
+func (e *executor) spend(path []any) bool {
+	if e.limit == 0 {
+		return true
+	}
+	if e.nodes++; e.nodes == e.limit+1 {
+		e.fail(path, fmt.Errorf("the response would hold more than %d fields and elements; ask for less", e.limit))
+	}
+	return e.nodes <= e.limit
+}
+
+// object resolves sels on source, a value of type obj
+func (e *executor) object(obj *Object, source any, sels []selection, path []any) ordered {
+	out := ordered{}
+	var plain map[string]any // source's JSON encoding, read once it is needed
+	for _, sel := range sels {
+		include, err := e.included(sel)
+		if err != nil {
+			e.fail(append(path, sel.key()), err)
+			continue
+		}
+		if !include || out.has(sel.key()) {
+			continue
+		}
+		p := append(path, sel.key())
+		if !e.spend(p) {
+			out = append(out, member{sel.key(), nil})
+			continue
+		}
+		if sel.name == "__typename" {
+			out = append(out, member{sel.key(), obj.Name})
+			continue
+		}
+
+		f, ok := obj.Fields[sel.name]
+		if !ok {
+			if source == nil {
+				e.fail(p, fmt.Errorf("cannot query field %q on type %q", sel.name, obj.Name))
+				out = append(out, member{sel.key(), nil})
+				continue
+			}
+			if plain == nil {
+				plain, _ = encode(source).(map[string]any)
+			}
+			out = append(out, member{sel.key(), e.plain(plain[sel.name], sel, p)})
+			continue
+		}
+
+		args := map[string]any{}
+		for name, v := range sel.args {
+			if !slices.Contains(f.Args, name) {
+				err = fmt.Errorf("unknown argument %q on field %q", name, sel.name)
+				break
+			}
+			args[name] = v.resolve(e.vars)
+		}
+		var v any
+		if err == nil {
+			err = e.ctx.Err()
+		}
+		if err == nil {
+			v, err = f.Resolve(e.ctx, source, args)
+		}
+		if err != nil {
+			e.fail(p, err)
+			out = append(out, member{sel.key(), nil})
+			continue
+		}
+		out = append(out, member{sel.key(), e.value(f, v, sel, p)})
+	}
+	return out
+}
+
+// value shapes a resolved field's value by its type
+func (e *executor) value(f *Field, v any, sel selection, path []any) any {
+	if f.Type == nil {
+		return e.plain(encode(v), sel, path)
+	}
+	if v == nil {
+		return nil
+	}
+	if sel.selection == nil {
+		e.fail(path, fmt.Errorf("field %q of type %q needs a selection of subfields", sel.name, f.Type.Name))
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Pointer && rv.IsNil():
+		return nil
+	case rv.Kind() == reflect.Slice:
+		list := make([]any, rv.Len())
+		for i := range list {
+			if p := append(path, i); e.spend(p) {
+				list[i] = e.object(f.Type, rv.Index(i).Interface(), sel.selection, p)
+			}
+		}
+		return list
+	}
+	return e.object(f.Type, v, sel.selection, path)
+}
+
+// plain selects from JSON-decoded data
+func (e *executor) plain(v any, sel selection, path []any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if sel.selection == nil {
+			return v // Whole, as on REST
+		}
+		out := ordered{}
+		for _, sub := range sel.selection {
+			include, err := e.included(sub)
+			if err != nil {
+				e.fail(append(path, sub.key()), err)
+				continue
+			}
+			if include && !out.has(sub.key()) {
+				var value any
+				if p := append(path, sub.key()); e.spend(p) {
+					value = e.plain(v[sub.name], sub, p)
+				}
+				out = append(out, member{sub.key(), value})
+			}
+		}
+		return out
+	case []any:
+		list := make([]any, len(v))
+		for i, el := range v {
+			if p := append(path, i); e.spend(p) {
+				list[i] = e.plain(el, sel, p)
+			}
+		}
+		return list
+	case nil:
+		return nil
+	}
+	if sel.selection != nil {
+		e.fail(path, fmt.Errorf("field %q has no subfields to select", sel.name))
+		return nil
+	}
+	return v
+}
+
+// included applies @include(if:) and @skip(if:)
+func (e *executor) included(sel selection) (bool, error) {
+	for _, d := range sel.directives {
+		cond, ok := d.args["if"].resolve(e.vars).(bool)
+		switch {
+		case d.name != "include" && d.name != "skip":
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		case !ok:
+			return false, fmt.Errorf("@%s needs a boolean if", d.name)
+		case d.name == "include" && !cond, d.name == "skip" && cond:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// encode round-trips v through JSON into maps, slices and scalars
+func encode(v any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber() // Keep integers exact
+	d.Decode(&out)
+	return out
+}
+
+// ordered is an object in the response, its fields in query order
+type ordered []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o ordered) has(key string) bool {
+	return slices.ContainsFunc(o, func(m member) bool { return m.key == key })
+}
+
+func (o ordered) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(m.key)
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type stop struct {
+	ID     string  `json:"id"`
+	Weight float64 `json:"weight_kg"`
+	At     struct {
+		Lat float64 `json:"lat"`
+	} `json:"at"`
+}
+
+type run struct {
+	Vehicle string `json:"vehicle_id"`
+	Stops   []stop `json:"stops"`
+}
+
+func schema() *Schema {
+	vehicle := &Object{Name: "Vehicle"}
+	runType := &Object{Name: "Run", Fields: map[string]*Field{
+		"vehicle": {Type: vehicle, Resolve: func(_ context.Context, src any, _ map[string]any) (any, error) {
+			return map[string]string{"id": src.(run).Vehicle}, nil
+		}},
+	}}
+	runs := []run{
+		{Vehicle: "V1", Stops: []stop{{ID: "A", Weight: 10}, {ID: "B", Weight: 20}}},
+		{Vehicle: "V2", Stops: []stop{{ID: "C", Weight: 5}}},
+	}
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"runs": {Args: []string{"vehicle"}, Type: runType, Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+			if v, ok := args["vehicle"].(string); ok {
+				for _, r := range runs {
+					if r.Vehicle == v {
+						return []run{r}, nil
+					}
+				}
+				return []run{}, nil
+			}
+			return runs, nil
+		}},
+		"broken": {Resolve: func(context.Context, any, map[string]any) (any, error) {
+			return nil, errors.New("resolver failed")
+		}},
+		"count": {Resolve: func(context.Context, any, map[string]any) (any, error) { return 3, nil }},
+	}}}
+}
+
+func run1(t *testing.T, req Request) string {
+	t.Helper()
+	resp, err := schema().Execute(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(resp)
+	return string(b)
+}
+
+func TestNestedSelection(t *testing.T) {
+	got := run1(t, Request{Query: `
+		# Fields come back in the order asked for
+		query Board($v: String) {
+			runs(vehicle: $v) { vehicle { id } stops { id, lat: at { lat } } __typename }
+			n: count
+		}`, Variables: map[string]any{"v": "V1"}})
+	want := `{"data":{"runs":[{"vehicle":{"id":"V1"},"stops":[{"id":"A","lat":{"lat":0}},{"id":"B","lat":{"lat":0}}],"__typename":"Run"}],"n":3}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDirectivesAndDefaults(t *testing.T) {
+	got := run1(t, Request{Query: `query ($all: Boolean = false) {
+		runs { vehicle_id stops @include(if: $all) { id } }
+		count @skip(if: true)
+	}`})
+	want := `{"data":{"runs":[{"vehicle_id":"V1"},{"vehicle_id":"V2"}]}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestFieldErrorsLeaveNulls(t *testing.T) {
+	got := run1(t, Request{Query: `{ count broken runs(color: "red") { vehicle_id } nope }`})
+	for _, want := range []string{
+		`"data":{"count":3,"broken":null,"runs":null,"nope":null}`,
+		`{"message":"resolver failed","path":["broken"]}`,
+		`unknown argument \"color\"`,
+		`cannot query field \"nope\" on type \"Query\"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("response lacks %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRejectedRequests(t *testing.T) {
+	for _, q := range []string{
+		``,
+		`{ runs { vehicle_id }`,
+		`mutation { count }`,
+		`{ ...f }`,
+		`{ runs(vehicle: "V1) { vehicle_id } }`,
+		`{ a ` + strings.Repeat("{ a ", 40) + strings.Repeat("}", 41),
+		`query A { count } query B { count }`,
+	} {
+		if _, err := schema().Execute(context.Background(), Request{Query: q}); err == nil {
+			t.Errorf("%q: want an error", q)
+		}
+	}
+	if _, err := schema().Execute(context.Background(), Request{Query: `{ count }`, Variables: map[string]any{"x": 1}}); err == nil {
+		t.Error("undeclared variable: want an error")
+	}
+	if _, err := schema().Execute(context.Background(), Request{Query: `query A { count } query B { count }`, OperationName: "B"}); err != nil {
+		t.Errorf("named operation: %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	s := schema()
+	s.MaxAliases, s.MaxNodes = 2, 6
+	if _, err := s.Execute(context.Background(), Request{Query: `{ a: count b: count c: count }`}); err == nil {
+		t.Error("three aliases: want an error")
+	}
+
+	// runs, V1's run, its vehicle_id, stops, stop A and its id use the six
+	// nodes; everything after is null
+	resp, err := s.Execute(context.Background(), Request{Query: `{ runs { vehicle_id stops { id } } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(resp)
+	if got := string(b); !strings.Contains(got, `"data":{"runs":[{"vehicle_id":"V1","stops":[{"id":"A"},null]},null]}`) ||
+		len(resp.Errors) != 1 || !strings.Contains(got, "more than 6 fields") {
+		t.Errorf("over budget: %s", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if resp, err := s.Execute(ctx, Request{Query: `{ count }`}); err != nil || len(resp.Errors) != 1 || resp.Errors[0].Message != context.Canceled.Error() {
+		t.Errorf("cancelled: %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,421 @@
+// Package graphql executes GraphQL queries against a small schema of
+// resolver functions, enough for a frontend to fetch nested data in one
+// round trip. It supports query operations with variables, aliases,
+// arguments and the @include and @skip directives; fragments, mutations,
+// subscriptions and introspection are not supported.
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds how deeply selections and values nest, so a hostile
+// query cannot exhaust the stack
+const maxDepth = 32
+
+// document is a parsed query
+type document struct {
+	operations []operation
+	aliases    int // Aliased fields, each a way to repeat a costly field
+}
+
+type operation struct {
+	name      string
+	variables map[string]any // Defaults, by name without the $
+	declared  map[string]bool
+	selection []selection
+}
+
+type selection struct {
+	alias, name string
+	args        map[string]value
+	directives  []directive
+	selection   []selection
+}
+
+type directive struct {
+	name string
+	args map[string]value
+}
+
+// value is a literal or a $variable, resolved when the query executes
+type value struct {
+	variable string // Set for $variables
+	literal  any    // string, int, float64, bool, nil, []value or map[string]value
+}
+
+// key is the field's name in the response
+func (s selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type token struct {
+	kind byte // 'n' name, 'i' int, 'f' float, 's' string, 'p' punctuator, 0 end
+	text string
+	pos  int
+}
+
+type parser struct {
+	src     string
+	pos     int
+	tok     token
+	depth   int
+	aliases int
+}
+
+func parse(src string) (doc document, err error) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			err = pe
+		}
+	}()
+	p.next()
+	for p.tok.kind != 0 {
+		doc.operations = append(doc.operations, p.operation())
+	}
+	if len(doc.operations) == 0 {
+		p.fail("the document has no operations")
+	}
+	doc.aliases = p.aliases
+	return doc, nil
+}
+
+// parseError is raised by fail and recovered by parse
+type parseError struct{ error }
+
+func (p *parser) fail(format string, args ...any) {
+	line := 1 + strings.Count(p.src[:min(p.tok.pos, len(p.src))], "\n")
+	panic(parseError{fmt.Errorf("syntax error on line %d: %s", line, fmt.Sprintf(format, args...))})
+}
+
+func (p *parser) operation() operation {
+	op := operation{variables: map[string]any{}, declared: map[string]bool{}}
+	if p.peek('p', "{") {
+		op.selection = p.selectionSet()
+		return op
+	}
+	switch kind := p.name(); kind {
+	case "query":
+	case "mutation", "subscription":
+		p.fail("only queries are supported, not %ss", kind)
+	case "fragment":
+		p.fail("fragments are not supported")
+	default:
+		p.fail("expected an operation, found %q", kind)
+	}
+	if p.tok.kind == 'n' {
+		op.name = p.name()
+	}
+	if p.accept('p', "(") {
+		for !p.accept('p', ")") {
+			p.expect('p', "$")
+			name := p.name()
+			p.expect('p', ":")
+			p.typeRef()
+			op.declared[name] = true
+			if p.accept('p', "=") {
+				v := p.value(true)
+				op.variables[name] = v.resolve(nil)
+			}
+		}
+	}
+	if p.peek('p', "@") {
+		p.fail("directives on operations are not supported")
+	}
+	op.selection = p.selectionSet()
+	return op
+}
+
+// typeRef skips a variable's type; values are checked by the resolvers
+func (p *parser) typeRef() {
+	if p.accept('p', "[") {
+		p.typeRef()
+		p.expect('p', "]")
+	} else {
+		p.name()
+	}
+	p.accept('p', "!")
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect('p', "{")
+	p.enter()
+	var sels []selection
+	for !p.accept('p', "}") {
+		if p.peek('p', "...") {
+			p.fail("fragments are not supported")
+		}
+		sels = append(sels, p.field())
+	}
+	if len(sels) == 0 {
+		p.fail("a selection set cannot be empty")
+	}
+	p.depth--
+	return sels
+}
+
+func (p *parser) field() selection {
+	s := selection{name: p.name()}
+	if p.accept('p', ":") {
+		s.alias, s.name = s.name, p.name()
+		p.aliases++
+	}
+	s.args = p.arguments()
+	for p.accept('p', "@") {
+		s.directives = append(s.directives, directive{name: p.name(), args: p.arguments()})
+	}
+	if p.peek('p', "{") {
+		s.selection = p.selectionSet()
+	}
+	return s
+}
+
+func (p *parser) arguments() map[string]value {
+	if !p.accept('p', "(") {
+		return nil
+	}
+	args := map[string]value{}
+	for !p.accept('p', ")") {
+		name := p.name()
+		p.expect('p', ":")
+		if _, dup := args[name]; dup {
+			p.fail("argument %q is given twice", name)
+		}
+		args[name] = p.value(false)
+	}
+	return args
+}
+
+// value parses a value; constant values, such as variable defaults, cannot
+// refer to variables
+func (p *parser) value(constant bool) value {
+	p.enter()
+	defer func() { p.depth-- }()
+	t := p.tok
+	switch {
+	case t.kind == 'p' && t.text == "$":
+		if constant {
+			p.fail("a default value cannot use a variable")
+		}
+		p.next()
+		return value{variable: p.name()}
+	case t.kind == 'p' && t.text == "[":
+		p.next()
+		list := []value{}
+		for !p.accept('p', "]") {
+			list = append(list, p.value(constant))
+		}
+		return value{literal: list}
+	case t.kind == 'p' && t.text == "{":
+		p.next()
+		obj := map[string]value{}
+		for !p.accept('p', "}") {
+			name := p.name()
+			p.expect('p', ":")
+			obj[name] = p.value(constant)
+		}
+		return value{literal: obj}
+	case t.kind == 'i':
+		p.next()
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			p.fail("integer %s is out of range", t.text)
+		}
+		return value{literal: n}
+	case t.kind == 'f':
+		p.next()
+		f, _ := strconv.ParseFloat(t.text, 64)
+		return value{literal: f}
+	case t.kind == 's':
+		p.next()
+		return value{literal: t.text}
+	case t.kind == 'n':
+		p.next()
+		switch t.text {
+		case "true":
+			return value{literal: true}
+		case "false":
+			return value{literal: false}
+		case "null":
+			return value{}
+		}
+		return value{literal: t.text} // Enum values arrive as strings
+	}
+	p.fail("expected a value")
+	return value{}
+}
+
+// resolve substitutes variables into v
+func (v value) resolve(vars map[string]any) any {
+	if v.variable != "" {
+		return vars[v.variable]
+	}
+	switch l := v.literal.(type) {
+	case []value:
+		out := make([]any, len(l))
+		for i, e := range l {
+			out[i] = e.resolve(vars)
+		}
+		return out
+	case map[string]value:
+		out := make(map[string]any, len(l))
+		for k, e := range l {
+			out[k] = e.resolve(vars)
+		}
+		return out
+	}
+	return v.literal
+}
+
+func (p *parser) enter() {
+	if p.depth++; p.depth > maxDepth {
+		p.fail("the query nests more than %d levels", maxDepth)
+	}
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != 'n' {
+		p.fail("expected a name")
+	}
+	s := p.tok.text
+	p.next()
+	return s
+}
+
+func (p *parser) peek(kind byte, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+func (p *parser) accept(kind byte, text string) bool {
+	if p.peek(kind, text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind byte, text string) {
+	if !p.accept(kind, text) {
+		p.fail("expected %q", text)
+	}
+}
+
+// next reads the following token, skipping whitespace, commas and comments
+func (p *parser) next() {
+skip:
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			break skip
+		}
+	}
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.src) {
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.text = 'p', "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.text = 'p', string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.text = 'n', p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.str()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *parser) number() {
+	start := p.pos
+	p.tok.kind = 'i'
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		n := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == n {
+			p.fail("malformed number")
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		p.tok.kind = 'f'
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		p.tok.kind = 'f'
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.text = p.src[start:p.pos]
+}
+
+func (p *parser) str() {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated string")
+		}
+		p.tok.kind, p.tok.text = 's', p.src[p.pos+3:p.pos+3+end]
+		p.pos += end + 6
+		return
+	}
+	// A GraphQL string's escapes are JSON's
+	i := p.pos + 1
+	for i < len(p.src) && p.src[i] != '"' && p.src[i] != '\n' {
+		if p.src[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(p.src) || p.src[i] != '"' {
+		p.fail("unterminated string")
+	}
+	var s string
+	if err := json.Unmarshal([]byte(p.src[p.pos:i+1]), &s); err != nil {
+		p.fail("malformed string")
+	}
+	p.tok.kind, p.tok.text = 's', s
+	p.pos = i + 1
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
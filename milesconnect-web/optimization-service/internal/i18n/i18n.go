@@ -0,0 +1,103 @@
+// Package i18n translates the service's user-facing text. Messages are
+// written in English in the code and looked up here by their English text,
+// so a message without a translation is simply shown in English.
+package i18n
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Languages the service speaks
+const (
+	English = "en"
+	Hindi   = "hi"
+)
+
+// Supported reports whether lang is one of the service's languages
+func Supported(lang string) bool {
+	return lang == English || catalogs[lang] != nil
+}
+
+// T returns msg in lang, or msg itself when there is no translation
+func T(lang, msg string) string {
+	if t, ok := catalogs[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Negotiate picks the language for an Accept-Language header, e.g.
+// "hi-IN,hi;q=0.9,en;q=0.8": the supported language the caller weights
+// highest, by primary tag, or fallback when it names none
+func Negotiate(header, fallback string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if Supported(primary) && q > 0 {
+			choices = append(choices, choice{primary, q})
+		}
+	}
+	if len(choices) == 0 {
+		return fallback
+	}
+	slices.SortStableFunc(choices, func(a, b choice) int { return cmp.Compare(b.q, a.q) })
+	return choices[0].lang
+}
+
+// catalogs maps each language's English messages to their translations
+var catalogs = map[string]map[string]string{
+	Hindi: {
+		// Errors
+		"Method not allowed":                                              "यह विधि अनुमत नहीं है",
+		"Invalid request body":                                            "अनुरोध का मुख्य भाग अमान्य है",
+		"Failed to encode response":                                       "उत्तर तैयार नहीं हो सका",
+		"Nothing dispatched for that date":                                "उस तारीख के लिए कुछ भी रवाना नहीं किया गया",
+		"Date must be YYYY-MM-DD":                                         "तारीख YYYY-MM-DD प्रारूप में होनी चाहिए",
+		"Dates must be YYYY-MM-DD":                                        "तारीखें YYYY-MM-DD प्रारूप में होनी चाहिए",
+		"Unknown planning session":                                        "अज्ञात योजना सत्र",
+		"Unknown template":                                                "अज्ञात टेम्पलेट",
+		"Unknown dataset":                                                 "अज्ञात डेटासेट",
+		"Unknown solver":                                                  "अज्ञात सॉल्वर",
+		"Unknown profile":                                                 "अज्ञात प्रोफ़ाइल",
+		"Solver is not enabled for your tenant":                           "यह सॉल्वर आपके टेनेंट के लिए सक्षम नहीं है",
+		"Link not found":                                                  "लिंक नहीं मिला",
+		"This link has expired":                                           "इस लिंक की अवधि समाप्त हो गई है",
+		"This plan is no longer available":                                "यह योजना अब उपलब्ध नहीं है",
+		"Driver token required":                                           "ड्राइवर टोकन आवश्यक है",
+		"Deadline exceeded waiting for a solver slot":                     "सॉल्वर की प्रतीक्षा में समय सीमा समाप्त हो गई",
+		"Deadline exceeded before a solution was found":                   "समाधान मिलने से पहले समय सीमा समाप्त हो गई",
+		"Between 1 and 1000 vehicles are required":                        "1 से 1000 वाहन आवश्यक हैं",
+		"Too many stops":                                                  "बहुत अधिक स्टॉप",
+		"Too many waypoints":                                              "बहुत अधिक पड़ाव",
+		"Depot must be valid coordinates":                                 "डिपो के निर्देशांक मान्य होने चाहिए",
+		"Stops must be valid coordinates":                                 "स्टॉप के निर्देशांक मान्य होने चाहिए",
+		"Start and end must be valid coordinates":                         "आरंभ और अंत के निर्देशांक मान्य होने चाहिए",
+		"Vehicle capacity must be positive and current load non-negative": "वाहन की क्षमता धनात्मक और वर्तमान भार ऋणात्मक नहीं होना चाहिए",
+		"Shipment weight must be positive":                                "शिपमेंट का वज़न धनात्मक होना चाहिए",
+		"Stop demand and times must not be negative":                      "स्टॉप की माँग और समय ऋणात्मक नहीं होने चाहिए",
+		"Stop due time must not be before its ready time":                 "स्टॉप का नियत समय उसके तैयार होने के समय से पहले नहीं हो सकता",
+		"Price must not be negative":                                      "मूल्य ऋणात्मक नहीं होना चाहिए",
+
+		// Shipment notifications
+		"Shipment %s will arrive between %s and %s":                                   "शिपमेंट %s %s से %s के बीच पहुँचेगा",
+		"Shipment %s will now arrive between %s and %s":                               "शिपमेंट %s अब %s से %s के बीच पहुँचेगा",
+		"Shipment %s is scheduled for delivery on %s":                                 "शिपमेंट %s की डिलीवरी %s को निर्धारित है",
+		"Shipment %s could not be scheduled for %s; we will contact you to rearrange": "शिपमेंट %s %s के लिए निर्धारित नहीं हो सका; हम पुनः व्यवस्था के लिए आपसे संपर्क करेंगे",
+	},
+}
@@ -0,0 +1,35 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct{ header, want string }{
+		{"", English},
+		{"hi-IN,hi;q=0.9,en;q=0.8", Hindi},
+		{"en-GB,hi;q=0.5", English},
+		{"fr,hi;q=0.3", Hindi},
+		{"fr,de", English},
+		{"hi;q=0", English},
+		{"en;q=0.2,HI;q=0.7", Hindi},
+	}
+	for _, c := range cases {
+		if got := Negotiate(c.header, English); got != c.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", c.header, got, c.want)
+		}
+	}
+	if got := Negotiate("fr", Hindi); got != Hindi {
+		t.Errorf("fallback = %q", got)
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(Hindi, "Method not allowed"); got == "Method not allowed" {
+		t.Error("not translated")
+	}
+	if got := T(English, "Method not allowed"); got != "Method not allowed" {
+		t.Errorf("English = %q", got)
+	}
+	if got := T(Hindi, "Nobody translated this"); got != "Nobody translated this" {
+		t.Errorf("untranslated = %q", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Package ics writes iCalendar (RFC 5545) feeds that calendar apps can
+// import or subscribe to.
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of an iCalendar feed
+const ContentType = "text/calendar; charset=utf-8"
+
+// Calendar is a feed of events
+type Calendar struct {
+	Name    string
+	Refresh time.Duration // How often subscribers should poll; 0 leaves it to them
+	Events  []Event
+}
+
+// Event is one VEVENT. A zero Start makes it an all-day event on Day.
+type Event struct {
+	UID         string
+	Start, End  time.Time
+	Day         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Lat, Lng    float64 // GEO, written when Location is set
+	Modified    time.Time
+}
+
+const stampLayout = "20060102T150405Z"
+
+// Write encodes c with CRLF line endings and lines folded at 75 octets
+func (c Calendar) Write(w io.Writer, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) { writeFolded(bw, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//MilesConnect//Optimization Service//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	if c.Refresh > 0 {
+		d := iso8601(c.Refresh)
+		line("REFRESH-INTERVAL;VALUE=DURATION", d)
+		line("X-PUBLISHED-TTL", d)
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", now.UTC().Format(stampLayout))
+		if e.Start.IsZero() {
+			line("DTSTART;VALUE=DATE", e.Day.Format("20060102"))
+		} else {
+			line("DTSTART", e.Start.UTC().Format(stampLayout))
+			line("DTEND", e.End.UTC().Format(stampLayout))
+		}
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION", escape(e.Location))
+			line("GEO", fmt.Sprintf("%.6f;%.6f", e.Lat, e.Lng))
+		}
+		if !e.Modified.IsZero() {
+			line("LAST-MODIFIED", e.Modified.UTC().Format(stampLayout))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// escape quotes the characters TEXT values reserve
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeFolded writes a content line, continuing it on lines that start with
+// a space once it passes 75 octets, without splitting a UTF-8 sequence
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // The leading space counts
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func iso8601(d time.Duration) string {
+	if m := d.Round(time.Minute) / time.Minute; m > 0 {
+		return fmt.Sprintf("PT%dM", m)
+	}
+	return "PT1M"
+}
@@ -0,0 +1,58 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	start := time.Date(2026, 11, 5, 10, 0, 0, 0, ist)
+	cal := Calendar{
+		Name:    "V1, Okhla",
+		Refresh: 15 * time.Minute,
+		Events: []Event{
+			{UID: "a@x", Start: start, End: start.Add(time.Hour), Summary: "Stop 1: A", Description: strings.Repeat("पता; ", 20), Location: "28.6,77.2", Lat: 28.6, Lng: 77.2},
+			{UID: "b@x", Day: start, Summary: "Stop 2: B"},
+		},
+	}
+	var b strings.Builder
+	if err := cal.Write(&b, time.Date(2026, 11, 4, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:V1\\, Okhla\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT15M\r\n",
+		"DTSTART:20261105T043000Z\r\nDTEND:20261105T053000Z\r\n",
+		"LOCATION:28.6\\,77.2\r\nGEO:28.600000;77.200000\r\n",
+		"DTSTART;VALUE=DATE:20261105\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VEVENT") != 2 {
+		t.Errorf("want 2 events:\n%s", out)
+	}
+
+	// Folded lines stay within 75 octets and rejoin to the original text
+	var unfolded []string
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line of %d octets: %q", len(l), l)
+		}
+		if rest, ok := strings.CutPrefix(l, " "); ok {
+			unfolded[len(unfolded)-1] += rest
+			continue
+		}
+		unfolded = append(unfolded, l)
+	}
+	if want := "DESCRIPTION:" + strings.Repeat(`पता\; `, 20); !strings.Contains(strings.Join(unfolded, "\n"), want) {
+		t.Errorf("description did not survive folding")
+	}
+}
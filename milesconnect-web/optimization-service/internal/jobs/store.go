@@ -0,0 +1,792 @@
+// Package jobs queues optimization requests to run in the background on
+// whichever replica is free. The queue is a directory every replica shares
+// (e.g. a network volume). A replica owns a job while it runs it and
+// heartbeats to keep it; a job whose owner stops heartbeating for a lease,
+// e.g. because its pod crashed, is put back in the queue for another replica
+// to restart, from the solver's last checkpoint where it keeps them. Jobs
+// therefore run at least once.
+package jobs
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpoints is the directory solvers keep running jobs' progress in, by
+// job then checkpoint name
+const checkpoints = "checkpoints"
+
+// batches is the directory of batch manifests, and of the jobs of batches
+// being submitted until they are queued
+const batches = "batches"
+
+// marks is the directory of the markers replicas claim one-off tasks with
+const marks = "marks"
+
+// Job states, which are also the directories jobs move through
+const (
+	Queued  = "queued"
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed" // Kept in done/ beside finished jobs
+)
+
+// MaxAttempts bounds how often a job is taken over before it is failed, so
+// a request that crashes its replica does not crash them all in turn
+const MaxAttempts = 3
+
+var validCheckpoint = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+var (
+	ErrNotFound = errors.New("jobs: no such job")
+	ErrLost     = errors.New("jobs: job taken over by another replica")
+)
+
+// Job is a queued optimization request and, once run, its response
+type Job struct {
+	ID     string `json:"id"`
+	Tenant string `json:"tenant"`
+	Role   string `json:"role,omitempty"`  // The submitter's, so the job runs as them
+	Actor  string `json:"actor,omitempty"` // X-Actor of the submission
+
+	// The request: a solving endpoint's unversioned path, query and body
+	Path  string          `json:"path"`
+	Query string          `json:"query,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+
+	// Object storage, for jobs too big for the API: a signed URL to read
+	// the body from in place of Body, and one to write the response to in
+	// place of Result
+	InputURL  string `json:"input_url,omitempty"`
+	OutputURL string `json:"output_url,omitempty"`
+
+	// Bulk submissions: the batch a job came in with and its line there
+	Batch string `json:"batch,omitempty"`
+	Line  int    `json:"line,omitempty"`
+
+	Status    string    `json:"status"`
+	Owner     string    `json:"owner,omitempty"` // Replica running or last to run it
+	Heartbeat time.Time `json:"heartbeat,omitempty"`
+	Attempts  int       `json:"attempts"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"` // Of the latest attempt
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// The response, once done; failed jobs that never got one have Error
+	Code   int             `json:"code,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Store is the shared job directory. Moving a job between states is a
+// rename, which is atomic, so of several replicas claiming or taking over
+// the same job exactly one succeeds.
+type Store struct {
+	dir   string
+	lease time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]string    // Queued and running jobs' tenants, by ID
+	claimed map[string]time.Time // When this replica last claimed each tenant's job
+}
+
+// Open creates the state directories under dir, and queues the jobs of
+// batches a crash interrupted once they were submitted. A running job whose
+// heartbeat is older than lease is taken to be orphaned.
+func Open(dir string, lease time.Duration) (*Store, error) {
+	for _, state := range []string{Queued, Running, Done, checkpoints, batches, marks} {
+		if err := os.MkdirAll(filepath.Join(dir, state), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	s := &Store{dir: dir, lease: lease, tenants: map[string]string{}, claimed: map[string]time.Time{}}
+	manifests, err := s.manifests()
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range manifests {
+		if err := s.release(batch); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Lease is how long a running job survives without a heartbeat
+func (s *Store) Lease() time.Duration { return s.lease }
+
+// Submit queues j under a new ID
+func (s *Store) Submit(j Job, now time.Time) (Job, error) {
+	j.ID = newID(now)
+	j.Status = Queued
+	j.CreatedAt = now.UTC()
+	j.Owner, j.Heartbeat, j.Attempts = "", time.Time{}, 0
+	j.StartedAt, j.FinishedAt = nil, nil
+	j.Code, j.Result, j.Error = 0, nil, ""
+	return j, s.write(Queued, j)
+}
+
+// Manifest lists a batch's jobs in the order they were submitted
+type Manifest struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	CreatedAt time.Time `json:"created_at"`
+	Jobs      []string  `json:"jobs"`
+}
+
+// SubmitBatch queues list as one batch under a new ID, numbering the jobs
+// by their place in it from 1. Its jobs are claimed in that order. The jobs
+// are written aside and only queued once the batch's manifest is, so a
+// batch that fails part way leaves nothing behind to run.
+func (s *Store) SubmitBatch(list []Job, now time.Time) (string, error) {
+	batch := newID(now)
+	staged := filepath.Join(s.dir, batches, batch)
+	if err := os.Mkdir(staged, 0o755); err != nil {
+		return "", err
+	}
+	m := Manifest{ID: batch, CreatedAt: now.UTC(), Jobs: make([]string, len(list))}
+	for i, j := range list {
+		j.ID = newID(now.Add(time.Duration(i))) // IDs sort by time
+		j.Batch, j.Line = batch, i+1
+		j.Status, j.CreatedAt = Queued, now.UTC()
+		j.Owner, j.Heartbeat, j.Attempts = "", time.Time{}, 0
+		j.StartedAt, j.FinishedAt = nil, nil
+		j.Code, j.Result, j.Error = 0, nil, ""
+		if err := s.write(filepath.Join(batches, batch), j); err != nil {
+			os.RemoveAll(staged)
+			return "", err
+		}
+		m.Tenant, m.Jobs[i] = j.Tenant, j.ID
+	}
+
+	body, err := json.Marshal(m)
+	if err == nil {
+		err = writeFile(filepath.Join(s.dir, batches, batch+".json"), body)
+	}
+	if err != nil {
+		os.RemoveAll(staged)
+		return "", err
+	}
+	return batch, s.release(batch)
+}
+
+// release queues the jobs a batch's submission wrote aside. Once its
+// manifest is written a batch is submitted, so Open finishes the job for a
+// replica that crashed part way.
+func (s *Store) release(batch string) error {
+	staged := filepath.Join(s.dir, batches, batch)
+	entries, err := os.ReadDir(staged)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(staged, e.Name()), s.path(Queued, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.RemoveAll(staged)
+}
+
+// Batch returns a batch's manifest and its jobs in the order they were
+// submitted, leaving out any pruned since
+func (s *Store) Batch(batch string) (Manifest, []Job, error) {
+	m, err := s.manifest(batch)
+	if err != nil {
+		return Manifest{}, nil, err
+	}
+	list := make([]Job, 0, len(m.Jobs))
+	for _, id := range m.Jobs {
+		j, err := s.Get(id)
+		if errors.Is(err, ErrNotFound) {
+			// Still being queued, or pruned
+			j, err = s.read(filepath.Join(batches, batch), id)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		if err != nil {
+			return Manifest{}, nil, err
+		}
+		list = append(list, j)
+	}
+	return m, list, nil
+}
+
+// manifest reads a batch's manifest
+func (s *Store) manifest(batch string) (Manifest, error) {
+	if !validID(batch) {
+		return Manifest{}, ErrNotFound
+	}
+	body, err := os.ReadFile(filepath.Join(s.dir, batches, batch+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, ErrNotFound
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Manifest{}, fmt.Errorf("jobs: batch %s: %w", batch, err)
+	}
+	return m, nil
+}
+
+// manifests lists the IDs of the batches with manifests, oldest first
+func (s *Store) manifests() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, batches))
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && validID(id) {
+			list = append(list, id)
+		}
+	}
+	return list, nil
+}
+
+// Get returns a job in whatever state it is
+func (s *Store) Get(id string) (Job, error) {
+	if !validID(id) {
+		return Job{}, ErrNotFound
+	}
+	// A running job is briefly out of place while a heartbeat, Finish or
+	// Reap holds it, so look twice before giving up
+	for range 2 {
+		for _, state := range []string{Done, Running, Queued} { // Latest state first, in case it moves meanwhile
+			if j, err := s.read(state, id); !errors.Is(err, os.ErrNotExist) {
+				return j, err
+			}
+		}
+	}
+	return Job{}, ErrNotFound
+}
+
+// List returns every job, oldest first
+func (s *Store) List() ([]Job, error) {
+	seen := map[string]bool{}
+	list := []Job{}
+	for _, state := range []string{Done, Running, Queued} {
+		ids, err := s.ids(state)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			j, err := s.read(state, id)
+			if errors.Is(err, os.ErrNotExist) || seen[id] {
+				continue // Moved on since listed
+			}
+			if err != nil {
+				return nil, err
+			}
+			seen[id] = true
+			list = append(list, j)
+		}
+	}
+	slices.SortFunc(list, func(a, b Job) int { return cmp.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// Claim takes a queued job for owner, marking it running. ok is false when
+// the queue is empty. Tenants take turns: the job is the oldest of the
+// tenant with the fewest jobs running, of those the one this replica
+// claimed for longest ago, so one tenant's batch does not hold up the
+// others'. Each tenant's jobs are claimed in the order they came.
+func (s *Store) Claim(owner string, now time.Time) (j Job, ok bool, err error) {
+	ids, err := s.ids(Queued)
+	if err != nil {
+		return Job{}, false, err
+	}
+	ids, err = s.fairOrder(ids)
+	if err != nil {
+		return Job{}, false, err
+	}
+	for _, id := range ids {
+		// Touch the file first: Reap goes by its modification time, which the
+		// rename keeps, until the claimed job is stamped below
+		err := os.Chtimes(s.path(Queued, id), now, now)
+		if err == nil {
+			err = os.Rename(s.path(Queued, id), s.path(Running, id))
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Another replica claimed it first
+		}
+		if err != nil {
+			return Job{}, false, err
+		}
+		if j, err = s.read(Running, id); err != nil {
+			return Job{}, false, err
+		}
+		s.mu.Lock()
+		s.claimed[j.Tenant] = now
+		s.mu.Unlock()
+		started := now.UTC()
+		j.Status, j.Owner, j.Heartbeat, j.StartedAt = Running, owner, started, &started
+		j.Attempts++
+		return j, true, s.write(Running, j)
+	}
+	return Job{}, false, nil
+}
+
+// fairOrder sorts queued, the IDs of the queued jobs oldest first, into the
+// order Claim tries them
+func (s *Store) fairOrder(queued []string) ([]string, error) {
+	running, err := s.ids(Running)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	live := make(map[string]string, len(queued)+len(running))
+	tenant := func(state, id string) (string, bool) {
+		t, ok := s.tenants[id]
+		if !ok {
+			j, err := s.read(state, id)
+			if err != nil {
+				return "", false // Moved on since listed
+			}
+			t = j.Tenant
+		}
+		live[id] = t
+		return t, true
+	}
+
+	busy := map[string]int{}
+	for _, id := range running {
+		if t, ok := tenant(Running, id); ok {
+			busy[t]++
+		}
+	}
+	first := map[string]int{}
+	list := make([]string, 0, len(queued))
+	for _, id := range queued {
+		t, ok := tenant(Queued, id)
+		if !ok {
+			continue
+		}
+		if _, seen := first[t]; !seen {
+			first[t] = len(list)
+		}
+		list = append(list, id)
+	}
+	s.tenants = live
+
+	rank := func(t string) (int, time.Time, int) { return busy[t], s.claimed[t], first[t] }
+	slices.SortStableFunc(list, func(a, b string) int {
+		ta, tb := live[a], live[b]
+		if ta == tb {
+			return 0
+		}
+		ba, ca, fa := rank(ta)
+		bb, cb, fb := rank(tb)
+		return cmp.Or(cmp.Compare(ba, bb), ca.Compare(cb), cmp.Compare(fa, fb))
+	})
+	return list, nil
+}
+
+// Heartbeat renews owner's hold on a running job, or returns ErrLost when it
+// was taken over. The renewed job is written aside first and only put in
+// place once the job is taken, so a heartbeat racing Reap cannot bring back
+// a job Reap has just queued again.
+func (s *Store) Heartbeat(j *Job, now time.Time) error {
+	beat := *j
+	beat.Heartbeat = now.UTC()
+	tmp, err := s.stage(Running, beat)
+	if err != nil {
+		return err
+	}
+	parked, cur, _, err := s.take(j.ID, now)
+	if err == nil && cur.Owner != j.Owner {
+		err = cmp.Or(s.giveBack(parked, j.ID), ErrLost)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path(Running, j.ID)); err != nil {
+		os.Remove(tmp)
+		return errors.Join(err, s.giveBack(parked, j.ID))
+	}
+	os.Remove(parked)
+	j.Heartbeat = beat.Heartbeat
+	return nil
+}
+
+// Finish stores a running job's outcome, or returns ErrLost when it was
+// taken over and the outcome belongs to the replica now running it
+func (s *Store) Finish(j Job, now time.Time) error {
+	parked, cur, _, err := s.take(j.ID, now)
+	if err == nil && cur.Owner != j.Owner {
+		err = cmp.Or(s.giveBack(parked, j.ID), ErrLost)
+	}
+	if err != nil {
+		return err
+	}
+	finished := now.UTC()
+	j.FinishedAt = &finished
+	if j.Status != Failed {
+		j.Status = Done
+	}
+	if err := s.write(Done, j); err != nil {
+		return errors.Join(err, s.giveBack(parked, j.ID))
+	}
+	os.RemoveAll(filepath.Join(s.dir, checkpoints, j.ID))
+	return os.Remove(parked)
+}
+
+// SaveCheckpoint keeps a running job's progress under name, where the
+// replica that takes the job over can resume from it. It returns ErrLost
+// when the job was taken over, so the old owner cannot overwrite the new
+// one's progress.
+func (s *Store) SaveCheckpoint(j Job, name string, body []byte) error {
+	if !validCheckpoint.MatchString(name) {
+		return fmt.Errorf("jobs: bad checkpoint name %q", name)
+	}
+	if err := s.owned(j); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.dir, checkpoints, j.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "."+name+"."+newID(time.Now())+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name+".json"))
+}
+
+// LoadCheckpoint reads a job's checkpoint called name; ok is false when it
+// has none
+func (s *Store) LoadCheckpoint(id, name string) (body []byte, ok bool, err error) {
+	if !validID(id) || !validCheckpoint.MatchString(name) {
+		return nil, false, nil
+	}
+	body, err = os.ReadFile(filepath.Join(s.dir, checkpoints, id, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	return body, err == nil, err
+}
+
+// Reap puts running jobs whose owner has not heartbeat for a lease back in
+// the queue, or fails them after MaxAttempts, returning the jobs it moved
+// as they now stand. A job's file is rewritten on every heartbeat, so its
+// modification time is when it last showed signs of life.
+func (s *Store) Reap(now time.Time) ([]Job, error) {
+	ids, err := s.ids(Running)
+	if err != nil {
+		return nil, err
+	}
+	var moved []Job
+	for _, id := range ids {
+		info, err := os.Stat(s.path(Running, id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		j, err := s.read(Running, id)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		if now.Sub(lastAlive(info, j)) < s.lease {
+			continue
+		}
+		// Take it so no other reaper moves it too and its owner finds it
+		// lost, then look again: it may have heartbeat since it was read
+		orphan, j, alive, err := s.take(id, now)
+		if errors.Is(err, ErrLost) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		if now.Sub(alive) < s.lease {
+			if err := s.giveBack(orphan, id); err != nil {
+				return moved, err
+			}
+			continue
+		}
+		state := Queued
+		if j.Attempts >= MaxAttempts {
+			finished := now.UTC()
+			j.Status, j.FinishedAt, state = Failed, &finished, Done
+			j.Error = fmt.Sprintf("abandoned by %d replicas in turn", j.Attempts)
+		} else {
+			j.Status = Queued
+		}
+		if err := s.write(state, j); err != nil {
+			return moved, errors.Join(err, s.giveBack(orphan, id))
+		}
+		if state == Done {
+			os.RemoveAll(filepath.Join(s.dir, checkpoints, id))
+		}
+		os.Remove(orphan)
+		moved = append(moved, j)
+	}
+	return moved, nil
+}
+
+// Mark claims the one-off task key, e.g. a day's mail, and reports false
+// when a replica, this one included, claimed it first. Creating the marker
+// fails if it exists, so of several replicas exactly one succeeds.
+func (s *Store) Mark(key string) (bool, error) {
+	f, err := os.OpenFile(s.markPath(key), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// Unmark gives up the claim on key, e.g. when its task failed and is to be
+// tried again
+func (s *Store) Unmark(key string) error {
+	if err := os.Remove(s.markPath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) markPath(key string) string {
+	return filepath.Join(s.dir, marks, url.PathEscape(key))
+}
+
+// Prune deletes jobs that finished before cutoff, results and all,
+// returning how many it deleted, the manifests of batches none of whose
+// jobs are left and the markers claimed before cutoff. Jobs still queued
+// or running are kept however old they are.
+func (s *Store) Prune(cutoff time.Time) (int, error) {
+	ids, err := s.ids(Done)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		// Finished jobs are not written again, so the file's time is when
+		// the job finished
+		info, err := os.Stat(s.path(Done, id))
+		if errors.Is(err, os.ErrNotExist) || err == nil && !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err == nil {
+			err = os.Remove(s.path(Done, id))
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Another replica pruned it first
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	// A batch goes once its jobs have, and a submission that never wrote
+	// its manifest once it is as old; one that did but was cut short is
+	// queued
+	list, err := s.manifests()
+	if err != nil {
+		return n, err
+	}
+	for _, batch := range list {
+		m, err := s.manifest(batch)
+		if err != nil || !m.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if slices.ContainsFunc(m.Jobs, func(id string) bool { _, err := s.Get(id); return !errors.Is(err, ErrNotFound) }) {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, batches, batch+".json"))
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, batches))
+	if err != nil {
+		return n, err
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if !e.IsDir() || err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if _, err := s.manifest(e.Name()); errors.Is(err, ErrNotFound) {
+			os.RemoveAll(filepath.Join(s.dir, batches, e.Name()))
+		} else if err == nil {
+			s.release(e.Name())
+		}
+	}
+
+	entries, err = os.ReadDir(filepath.Join(s.dir, marks))
+	if err != nil {
+		return n, err
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(s.dir, marks, e.Name()))
+		}
+	}
+	return n, nil
+}
+
+// take parks a running job under a name only the caller knows, so no other
+// replica can move it meanwhile, and reads it with when it last showed
+// signs of life. It returns ErrLost when the job is no longer running.
+func (s *Store) take(id string, now time.Time) (parked string, j Job, alive time.Time, err error) {
+	parked = filepath.Join(s.dir, Running, id+"."+newID(now)+".orphan")
+	if err := os.Rename(s.path(Running, id), parked); errors.Is(err, os.ErrNotExist) {
+		return "", Job{}, time.Time{}, ErrLost
+	} else if err != nil {
+		return "", Job{}, time.Time{}, err
+	}
+	info, err := os.Stat(parked)
+	if err == nil {
+		j, err = readFile(parked)
+	}
+	if err != nil {
+		return "", Job{}, time.Time{}, errors.Join(err, s.giveBack(parked, id))
+	}
+	return parked, j, lastAlive(info, j), nil
+}
+
+// giveBack returns a job take parked to the running jobs. Nothing else can
+// have put the job back meanwhile, since only a queued job is claimed.
+func (s *Store) giveBack(parked, id string) error {
+	return os.Rename(parked, s.path(Running, id))
+}
+
+// lastAlive is when a running job last showed signs of life: its latest
+// heartbeat or write, whichever is later
+func lastAlive(info os.FileInfo, j Job) time.Time {
+	if j.Heartbeat.After(info.ModTime()) {
+		return j.Heartbeat
+	}
+	return info.ModTime()
+}
+
+// owned returns ErrLost unless j is still running under its owner
+func (s *Store) owned(j Job) error {
+	cur, err := s.read(Running, j.ID)
+	if errors.Is(err, os.ErrNotExist) || err == nil && cur.Owner != j.Owner {
+		return ErrLost
+	}
+	return err
+}
+
+func (s *Store) path(state, id string) string {
+	return filepath.Join(s.dir, state, id+".json")
+}
+
+// ids lists a state's jobs, oldest first
+func (s *Store) ids(state string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, state))
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && validID(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil // ReadDir sorts by name, and IDs sort by submission
+}
+
+func (s *Store) read(state, id string) (Job, error) {
+	return readFile(s.path(state, id))
+}
+
+func readFile(path string) (Job, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return Job{}, err
+	}
+	var j Job
+	if err := json.Unmarshal(body, &j); err != nil {
+		return Job{}, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return j, nil
+}
+
+// write replaces a job's file through a rename, so readers on other
+// replicas never see it half written
+func (s *Store) write(state string, j Job) error {
+	tmp, err := s.stage(state, j)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(state, j.ID)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeFile replaces the file at path through a rename, as write does
+func writeFile(path string, body []byte) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+newID(time.Now())+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// stage writes j to a temporary file beside state's jobs, for the caller
+// to rename into place
+func (s *Store) stage(state string, j Job) (string, error) {
+	body, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	tmp := filepath.Join(s.dir, state, "."+j.ID+"."+newID(time.Now())+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// newID sorts by time, then at random
+func newID(now time.Time) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%016x%s", now.UnixNano(), hex.EncodeToString(b))
+}
+
+func validID(id string) bool {
+	if len(id) != 24 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
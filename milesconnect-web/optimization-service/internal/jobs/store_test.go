@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrphanedJobIsTakenOver(t *testing.T) {
+	s, err := Open(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	first, _ := s.Submit(Job{Path: "/optimize-india", Query: "solver=genetic"}, now)
+	second, _ := s.Submit(Job{Path: "/optimize"}, now.Add(time.Millisecond))
+
+	a, ok, err := s.Claim("pod-a", now)
+	if err != nil || !ok || a.ID != first.ID || a.Status != Running || a.Attempts != 1 {
+		t.Fatalf("claim: %+v, %v, %v", a, ok, err)
+	}
+	if err := s.Heartbeat(&a, now.Add(30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCheckpoint(a, "ga-1", []byte(`{"generation":40}`)); err != nil {
+		t.Fatal(err)
+	}
+	if moved, _ := s.Reap(now.Add(time.Minute)); len(moved) != 0 {
+		t.Errorf("reaped %d jobs whose owner heartbeat", len(moved))
+	}
+
+	// pod-a dies; after a lease its job goes back in the queue ahead of the
+	// second, and pod-a can no longer heartbeat or finish it
+	later := now.Add(5 * time.Minute)
+	if moved, err := s.Reap(later); len(moved) != 1 || moved[0].Status != Queued || err != nil {
+		t.Fatalf("reaped %+v: %v", moved, err)
+	}
+	if err := s.Heartbeat(&a, later); !errors.Is(err, ErrLost) {
+		t.Errorf("heartbeat after takeover: %v", err)
+	}
+	if err := s.SaveCheckpoint(a, "ga-1", []byte(`{"generation":41}`)); !errors.Is(err, ErrLost) {
+		t.Errorf("checkpoint after takeover: %v", err)
+	}
+	b, ok, _ := s.Claim("pod-b", later)
+	if !ok || b.ID != first.ID || b.Attempts != 2 || b.Owner != "pod-b" {
+		t.Fatalf("takeover claim: %+v", b)
+	}
+	if body, ok, err := s.LoadCheckpoint(b.ID, "ga-1"); !ok || err != nil || string(body) != `{"generation":40}` {
+		t.Errorf("resumed checkpoint %s, %v, %v", body, ok, err)
+	}
+	b.Code, b.Result = 200, []byte(`{"ok":true}`)
+	if err := s.Finish(a, later); !errors.Is(err, ErrLost) {
+		t.Errorf("finish by the old owner: %v", err)
+	}
+	if err := s.Finish(b, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(first.ID); got.Status != Done || got.Code != 200 || got.FinishedAt == nil {
+		t.Errorf("finished job: %+v", got)
+	}
+	if _, ok, _ := s.LoadCheckpoint(first.ID, "ga-1"); ok {
+		t.Error("checkpoint kept after the job finished")
+	}
+
+	list, err := s.List()
+	if err != nil || len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID || list[1].Status != Queued {
+		t.Errorf("list: %+v, %v", list, err)
+	}
+	if _, err := s.Get("../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("bad id: %v", err)
+	}
+}
+
+func TestHeartbeatRacingReapKeepsOneCopy(t *testing.T) {
+	s, _ := Open(t.TempDir(), time.Minute)
+	now := time.Now()
+	for range 100 {
+		j, _ := s.Submit(Job{Path: "/optimize"}, now)
+		claimed, ok, _ := s.Claim("pod-a", now)
+		if !ok {
+			t.Fatal("nothing to claim")
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for s.Heartbeat(&claimed, now) == nil {
+			}
+		}()
+		s.Reap(now.Add(time.Hour))
+		<-done
+
+		copies := 0
+		for _, state := range []string{Queued, Running, Done} {
+			if _, err := os.Stat(s.path(state, j.ID)); err == nil {
+				copies++
+			}
+		}
+		if copies != 1 {
+			t.Fatalf("job %s is in %d states", j.ID, copies)
+		}
+		if got, err := s.Get(j.ID); err != nil || got.Status != Queued {
+			t.Fatalf("reaped job: %+v, %v", got, err)
+		}
+		s.Claim("pod-b", now)
+		s.Reap(now.Add(time.Hour))
+		s.Claim("pod-b", now)
+		s.Reap(now.Add(time.Hour)) // Failed after MaxAttempts, out of the way
+	}
+}
+
+func TestPruneDropsOldFinishedJobs(t *testing.T) {
+	s, _ := Open(t.TempDir(), time.Minute)
+	now := time.Now()
+	old, _ := s.Submit(Job{Path: "/optimize"}, now)
+	recent, _ := s.Submit(Job{Path: "/optimize"}, now.Add(time.Millisecond))
+	queued, _ := s.Submit(Job{Path: "/optimize"}, now.Add(2*time.Millisecond))
+	for _, at := range []time.Time{now.Add(-48 * time.Hour), now} {
+		j, _, _ := s.Claim("pod", now)
+		j.Code, j.Result = 200, []byte(`{}`)
+		if err := s.Finish(j, now); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(s.path(Done, j.ID), at, at)
+	}
+	if n, err := s.Prune(now.Add(-24 * time.Hour)); n != 1 || err != nil {
+		t.Errorf("pruned %d: %v", n, err)
+	}
+	if _, err := s.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old job: %v", err)
+	}
+	for _, id := range []string{recent.ID, queued.ID} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("job %s: %v", id, err)
+		}
+	}
+}
+
+func TestMarksAreClaimedOnce(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := Open(dir, time.Minute)
+	b, _ := Open(dir, time.Minute)
+	key := "summary/acme/2026-10-15"
+	if ok, err := a.Mark(key); !ok || err != nil {
+		t.Fatalf("first claim: %v, %v", ok, err)
+	}
+	if ok, _ := b.Mark(key); ok {
+		t.Error("second replica claimed it too")
+	}
+	a.Unmark(key)
+	if ok, _ := b.Mark(key); !ok {
+		t.Error("not claimable once given up")
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(b.markPath(key), old, old)
+	b.Prune(time.Now().Add(-24 * time.Hour))
+	if ok, _ := a.Mark(key); !ok {
+		t.Error("old marker not pruned")
+	}
+}
+
+func TestJobFailsAfterMaxAttempts(t *testing.T) {
+	s, _ := Open(t.TempDir(), time.Minute)
+	now := time.Now()
+	j, _ := s.Submit(Job{Path: "/optimize-fleet"}, now)
+	for i := range MaxAttempts {
+		if _, ok, _ := s.Claim("pod", now); !ok {
+			t.Fatalf("attempt %d: nothing to claim", i+1)
+		}
+		now = now.Add(2 * time.Minute)
+		s.Reap(now)
+	}
+	if got, _ := s.Get(j.ID); got.Status != Failed || got.Error == "" {
+		t.Errorf("job after %d abandoned attempts: %+v", MaxAttempts, got)
+	}
+}
+
+func TestBatchKeepsSubmissionOrder(t *testing.T) {
+	s, _ := Open(t.TempDir(), time.Minute)
+	now := time.Now()
+	s.Submit(Job{Path: "/optimize"}, now)
+	batch, err := s.SubmitBatch([]Job{{Path: "/optimize-fleet"}, {Path: "/optimize-load"}, {Path: "/optimize"}}, now.Add(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, list, err := s.Batch(batch)
+	if err != nil || len(list) != 3 || m.ID != batch || len(m.Jobs) != 3 {
+		t.Fatalf("batch: %+v, %+v, %v", m, list, err)
+	}
+	for i, j := range list {
+		if j.Line != i+1 || j.Batch != batch {
+			t.Errorf("job %d: line %d of %q", i, j.Line, j.Batch)
+		}
+	}
+	if list[0].Path != "/optimize-fleet" || list[2].Path != "/optimize" {
+		t.Errorf("order: %+v", list)
+	}
+	s.Claim("pod", now) // The single job, submitted first
+	if j, _, _ := s.Claim("pod", now); j.Batch != batch || j.Line != 1 {
+		t.Errorf("claimed %+v before the batch's first line", j)
+	}
+	if _, _, err := s.Batch(newID(now)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown batch: %v", err)
+	}
+}
+
+func TestBatchIsAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, time.Minute)
+	now := time.Now()
+	if _, err := s.SubmitBatch([]Job{{Path: "/optimize"}, {Path: "/optimize", Body: []byte("{")}}, now); err == nil {
+		t.Fatal("a batch with an unwritable job was queued")
+	}
+	if list, _ := s.List(); len(list) != 0 {
+		t.Errorf("failed batch left %d jobs", len(list))
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, batches)); len(entries) != 0 {
+		t.Errorf("failed batch left %v", entries)
+	}
+
+	// A replica that crashed once the manifest was written has its batch
+	// queued by the next to open the store
+	batch, err := s.SubmitBatch([]Job{{Path: "/optimize"}, {Path: "/optimize-load"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, list, _ := s.Batch(batch)
+	os.Mkdir(filepath.Join(dir, batches, batch), 0o755)
+	os.Rename(s.path(Queued, list[1].ID), filepath.Join(dir, batches, batch, list[1].ID+".json"))
+	if _, again, _ := s.Batch(batch); len(again) != 2 {
+		t.Errorf("batch being queued shows %d jobs", len(again))
+	}
+	s, _ = Open(dir, time.Minute)
+	if ids, _ := s.ids(Queued); len(ids) != 2 {
+		t.Errorf("queued %v after reopening", ids)
+	}
+}
+
+func TestClaimsTakeTurnsByTenant(t *testing.T) {
+	s, _ := Open(t.TempDir(), time.Minute)
+	now := time.Now()
+	s.SubmitBatch([]Job{{Tenant: "acme", Path: "/optimize"}, {Tenant: "acme", Path: "/optimize"}, {Tenant: "acme", Path: "/optimize"}}, now)
+	s.Submit(Job{Tenant: "globex", Path: "/optimize"}, now.Add(time.Second))
+	s.Submit(Job{Tenant: "initech", Path: "/optimize"}, now.Add(2*time.Second))
+
+	var got []string
+	for i := range 5 {
+		j, ok, err := s.Claim("pod", now.Add(time.Duration(i)*time.Minute))
+		if !ok || err != nil {
+			t.Fatalf("claim %d: %v", i, err)
+		}
+		got = append(got, fmt.Sprintf("%s/%d", j.Tenant, j.Line))
+		if i%2 == 1 {
+			j.Code = 200
+			s.Finish(j, now)
+		}
+	}
+	if want := "acme/1 globex/0 initech/0 acme/2 acme/3"; strings.Join(got, " ") != want {
+		t.Errorf("claimed %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+// Package metrics exposes process metrics in the Prometheus text format
+// without pulling in the client library.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	v atomic.Int64
+}
+
+func (c *Counter) Inc()         { c.v.Add(1) }
+func (c *Counter) Add(n int64)  { c.v.Add(n) }
+func (c *Counter) Value() int64 { return c.v.Load() }
+
+type metric struct {
+	name, help, kind string
+	value            func() float64
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]metric{}
+)
+
+// NewCounter registers and returns a counter
+func NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	register(metric{name: name, help: help, kind: "counter", value: func() float64 { return float64(c.Value()) }})
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is read on every scrape
+func GaugeFunc(name, help string, fn func() float64) {
+	register(metric{name: name, help: help, kind: "gauge", value: fn})
+}
+
+func register(m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[m.name] = m
+}
+
+// Snapshot reads every registered metric, by name
+func Snapshot() map[string]float64 {
+	mu.RLock()
+	list := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	mu.RUnlock()
+	values := make(map[string]float64, len(list))
+	for _, m := range list {
+		values[m.name] = m.value()
+	}
+	return values
+}
+
+// Handler serves every registered metric, sorted by name
+func Handler(w http.ResponseWriter, r *http.Request) {
+	mu.RLock()
+	list := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range list {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value())
+	}
+}
@@ -1,9 +1,14 @@
 package models
 
-// Location represents a geographic point
+import "time"
+
+// Location represents a geographic point. Pincode may be given instead of
+// coordinates; it is resolved to the pincode centroid before solving.
 type Location struct {
-	Lat float64 `json:"lat"`
-	Lng float64 `json:"lng"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Pincode string  `json:"pincode,omitempty"`
+	State   string  `json:"state,omitempty"` // ISO 3166-2:IN code, e.g. KA; legs between states are held up at the border
 }
 
 type NamedLocation struct {
@@ -17,12 +22,99 @@ type OptimizationRequest struct {
 	Start     Location   `json:"start"`
 	End       Location   `json:"end"`
 	Waypoints []Location `json:"waypoints"`
+
+	// DistanceMatrix optionally replaces great-circle distances (km). Rows and
+	// columns are ordered start, waypoints..., end.
+	DistanceMatrix [][]float64 `json:"distance_matrix,omitempty"`
 }
 
 // OptimizationResponse is the output for Route Optimization
 type OptimizationResponse struct {
-	Route       []Location `json:"route"`
-	TotalDistKm float64    `json:"total_distance_km"`
+	Route       []Location         `json:"route"`
+	Polyline    string             `json:"polyline,omitempty"` // Route as an encoded polyline, with ?geometry=polyline
+	TotalDistKm float64            `json:"total_distance_km"`
+	Feasibility *FeasibilityReport `json:"feasibility,omitempty"`
+	Meta        *SolveMeta         `json:"meta,omitempty"`
+	Units       *Units             `json:"units,omitempty"` // As on FleetResponse
+}
+
+// RoutePoint is a /v2/optimize input point: a location with an optional
+// caller ID and display name that the response echoes
+type RoutePoint struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Location
+}
+
+// OptimizationRequestV2 is the input for /v2/optimize
+type OptimizationRequestV2 struct {
+	Start     RoutePoint   `json:"start"`
+	End       RoutePoint   `json:"end"`
+	Waypoints []RoutePoint `json:"waypoints"`
+
+	// DistanceMatrix is ordered start, waypoints..., end as in v1
+	DistanceMatrix [][]float64 `json:"distance_matrix,omitempty"`
+}
+
+// OptimizationResponseV2 is the output for /v2/optimize: the visiting order
+// as stops that keep the caller's IDs and names, and the legs between them
+type OptimizationResponseV2 struct {
+	APIVersion  string             `json:"api_version"`
+	Stops       []RouteStop        `json:"stops"`
+	Legs        []RouteLegV2       `json:"legs"`
+	Polyline    string             `json:"polyline,omitempty"` // Stops as an encoded polyline, with ?geometry=polyline
+	TotalDistKm float64            `json:"total_distance_km"`
+	TotalHours  float64            `json:"total_hours"` // Driving time at the problem's average speed
+	Feasibility *FeasibilityReport `json:"feasibility"`
+	Meta        *SolveMeta         `json:"meta"`
+	Units       *Units             `json:"units"`
+}
+
+// RouteStop is one point of a v2 route in visiting order
+type RouteStop struct {
+	Seq           int      `json:"seq"`
+	Kind          string   `json:"kind"`                     // start, waypoint or end
+	WaypointIndex *int     `json:"waypoint_index,omitempty"` // Position in the request's waypoints
+	ID            string   `json:"id,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Location      Location `json:"location"`
+	CumulativeKm  float64  `json:"cumulative_km"`
+}
+
+// RouteLegV2 is the drive between two consecutive stops
+type RouteLegV2 struct {
+	FromSeq    int     `json:"from_seq"`
+	ToSeq      int     `json:"to_seq"`
+	DistanceKm float64 `json:"distance_km"`
+	Hours      float64 `json:"hours"`
+}
+
+// NetworkPlan is a hierarchical hub-and-spoke plan: a local tour per hub
+// plus a line-haul tour linking the hubs
+type NetworkPlan struct {
+	Hubs        []HubPlan            `json:"hubs"`
+	LineHaul    OptimizationResponse `json:"line_haul"`
+	TotalDistKm float64              `json:"total_distance_km"`
+}
+
+type HubPlan struct {
+	Hub    NamedLocation        `json:"hub"`
+	Cities []NamedLocation      `json:"cities"`
+	Tour   OptimizationResponse `json:"tour"`
+}
+
+// RouteLeg is a single hop of a route, used when streaming responses as NDJSON
+type RouteLeg struct {
+	Seq    int      `json:"seq"`
+	From   Location `json:"from"`
+	To     Location `json:"to"`
+	DistKm float64  `json:"distance_km"`
+}
+
+// RouteSummary is the trailing line of a streamed route
+type RouteSummary struct {
+	Legs        int     `json:"legs"`
+	TotalDistKm float64 `json:"total_distance_km"`
 }
 
 // LoadRequest represents inputs for Load/Weight Optimization
@@ -34,18 +126,574 @@ type LoadRequest struct {
 type VehicleInfo struct {
 	ID          string  `json:"id"`
 	CapacityKg  float64 `json:"capacity_kg"`
-	CurrentLoad float64 `json:"current_load"` // 0 if empty
+	CurrentLoad float64 `json:"current_load"`           // 0 if empty
+	DepartHours float64 `json:"depart_hours,omitempty"` // Hours from now until the vehicle leaves
+	KmPerLitre  float64 `json:"km_per_litre,omitempty"` // Rated mileage, used for fuel estimates until the fuel log measures it
+	Class       string  `json:"class,omitempty"`        // Toll class: lcv, 2-axle (default), 3-axle or 4-axle
+
+	// Type is the body, e.g. mini-truck or 32ft, which sets how much slower
+	// or faster than the plan's speed the vehicle drives; SpeedFactor
+	// overrides it
+	Type        string  `json:"type,omitempty"`
+	SpeedFactor float64 `json:"speed_factor,omitempty"`
+
+	Crew string `json:"crew,omitempty"` // single or relay; overrides the request's
+
+	// ReservePct of the capacity is kept free for same-day orders;
+	// overrides the request's
+	ReservePct float64 `json:"reserve_pct,omitempty"`
+
+	// Fleet routing only: where the vehicle starts and ends, e.g. the
+	// driver's home. Either defaults to the depot.
+	Start *Location `json:"start,omitempty"`
+	End   *Location `json:"end,omitempty"`
 }
 
 type ShipmentInfo struct {
 	ID       string  `json:"id"`
 	WeightKg float64 `json:"weight_kg"`
+
+	// Penalty model (all optional). A shipment is late when it rides on a vehicle
+	// departing after DeadlineHours; DropPenalty of 0 means it must not be dropped
+	// voluntarily.
+	DeadlineHours      float64 `json:"deadline_hours,omitempty"`
+	LatePenaltyPerHour float64 `json:"late_penalty_per_hour,omitempty"`
+	DropPenalty        float64 `json:"drop_penalty,omitempty"`
+
+	// VehicleTypes, when set, are the only vehicle types that may carry the
+	// shipment, e.g. reefer for cold chain
+	VehicleTypes []string `json:"vehicle_types,omitempty"`
 }
 
 // LoadResponse represents the result of the allocation
 type LoadResponse struct {
 	Allocations []Allocation `json:"allocations"`
 	Unassigned  []string     `json:"unassigned_shipment_ids"`
+	Dropped     []string     `json:"dropped_shipment_ids,omitempty"` // Subset of Unassigned dropped because lateness cost more
+	PenaltyCost float64      `json:"penalty_cost"`
+
+	Feasibility *FeasibilityReport `json:"feasibility,omitempty"`
+	Meta        *SolveMeta         `json:"meta,omitempty"`
+	Units       *Units             `json:"units,omitempty"` // As on FleetResponse
+}
+
+// FleetRequest is the input for multi-vehicle routing: every vehicle leaves
+// the depot, serves a share of the stops within its capacity and returns
+type FleetRequest struct {
+	Depot     Location      `json:"depot"`
+	Vehicles  []VehicleInfo `json:"vehicles"`
+	Stops     []FleetStop   `json:"stops"`
+	SpeedKmph float64       `json:"speed_kmph,omitempty"` // Average speed for time windows; default 50
+	Date      string        `json:"date,omitempty"`       // Day planned, for vehicle maintenance; defaults to today
+
+	// FuelPricePerLitre prices the routes' fuel estimates; defaults to the
+	// price at each vehicle's latest logged fill
+	FuelPricePerLitre float64 `json:"fuel_price_per_litre,omitempty"`
+
+	// DistanceMatrix optionally replaces great-circle distances (km). Rows and
+	// columns are ordered depot, stops...
+	DistanceMatrix [][]float64 `json:"distance_matrix,omitempty"`
+
+	// Objective is what the plan minimises: distance (default), or cost,
+	// which adds tolls to distance run at CostPerKm (INR, default 30).
+	// TollMatrix optionally replaces the toll plaza estimate with a
+	// provider's tolls (INR), ordered like DistanceMatrix.
+	Objective  string      `json:"objective,omitempty"`
+	CostPerKm  float64     `json:"cost_per_km,omitempty"`
+	TollMatrix [][]float64 `json:"toll_matrix,omitempty"`
+
+	Curfews []Curfew `json:"curfews,omitempty"`
+
+	// Crew limits daily driving: single (8 h) or relay, two drivers taking
+	// turns (16 h); unset means no limit. CompareCrews also plans with every
+	// vehicle single-crewed and relayed, to weigh a second driver's cost
+	// against the time saved.
+	Crew         string `json:"crew,omitempty"`
+	CompareCrews bool   `json:"compare_crews,omitempty"`
+
+	// ReservePct of every vehicle's capacity is left out of the plan as
+	// headroom for ad-hoc same-day orders
+	ReservePct float64 `json:"reserve_pct,omitempty"`
+
+	// Overbook loads vehicles past capacity by the expected share of
+	// shipments that are cancelled or not ready at the dock: each stop's
+	// NoShowRate, else this NoShowRate. Stops beyond true capacity come
+	// back as each route's standby list.
+	Overbook   bool    `json:"overbook,omitempty"`
+	NoShowRate float64 `json:"no_show_rate,omitempty"`
+
+	// Carriers are third-party carriers shipments can be handed to; the
+	// service's configured carriers are used when none are given
+	Carriers []Carrier `json:"carriers,omitempty"`
+}
+
+// Carrier is a third-party carrier's rate: a base charge per shipment plus
+// weight and distance from the depot, for shipments up to MaxKg (0 means
+// any weight)
+type Carrier struct {
+	Name     string  `json:"name"`
+	BaseInr  float64 `json:"base_inr"`
+	PerKgInr float64 `json:"per_kg_inr,omitempty"`
+	PerKmInr float64 `json:"per_km_inr,omitempty"`
+	MaxKg    float64 `json:"max_kg,omitempty"`
+}
+
+// Curfew keeps vehicles out of an area, or off the road, between two clock
+// times (HH:MM in the plan's zone) each day; a window that ends before it
+// starts runs overnight. The area is a circle (e.g. city limits) or a
+// state; without one the curfew is a driving ban. VehicleIDs and Classes
+// narrow it to those vehicles, e.g. heavy trucks.
+type Curfew struct {
+	Name       string    `json:"name,omitempty"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Center     *Location `json:"center,omitempty"`
+	RadiusKm   float64   `json:"radius_km,omitempty"`
+	State      string    `json:"state,omitempty"`
+	VehicleIDs []string  `json:"vehicle_ids,omitempty"`
+	Classes    []string  `json:"classes,omitempty"` // Toll classes; a vehicle without one is 2-axle
+}
+
+// FleetStop is a delivery. The time window is optional and in hours from
+// now; a vehicle arriving before ReadyHours waits.
+type FleetStop struct {
+	ID           string   `json:"id"`
+	Location     Location `json:"location"`
+	DemandKg     float64  `json:"demand_kg"`
+	ReadyHours   float64  `json:"ready_hours,omitempty"`
+	DueHours     float64  `json:"due_hours,omitempty"`
+	ServiceHours float64  `json:"service_hours,omitempty"`
+	NoShowRate   float64  `json:"no_show_rate,omitempty"` // Historical share of this customer's orders cancelled or not ready
+
+	// ReturnKg is collected from the customer and brought back to the
+	// depot, in the room deliveries free up; a pure return has no demand
+	ReturnKg float64 `json:"return_kg,omitempty"`
+
+	// VehicleTypes, when set, are the only vehicle types that may serve the
+	// stop, e.g. mini-truck down lanes a 32ft cannot turn into
+	VehicleTypes []string `json:"vehicle_types,omitempty"`
+}
+
+// TemplateInstanceRequest plans a date from a route template
+type TemplateInstanceRequest struct {
+	Template   string      `json:"template"`
+	Date       string      `json:"date"` // YYYY-MM-DD
+	ExtraStops []FleetStop `json:"extra_stops,omitempty"`
+
+	Shipments  []ShipmentDocs `json:"shipments,omitempty"`  // E-way bill details, checked with ?dispatch=true
+	Consignees []Consignee    `json:"consignees,omitempty"` // Kept on the board with ?dispatch=true, for search
+}
+
+// TemplateInstanceResponse is the template's routes for the date with the
+// standing orders and extra stops merged in
+type TemplateInstanceResponse struct {
+	Template         string   `json:"template"`
+	Date             string   `json:"date"`
+	StandingOrderIDs []string `json:"standing_order_ids"` // Orders due on the date, or moved to it
+	ClosedStopIDs    []string `json:"closed_stop_ids"`    // Left out, or for orders moved on: the customer is closed or it is a holiday
+	Warnings         []string `json:"warnings,omitempty"` // E.g. the date is a holiday
+	FleetResponse
+}
+
+// DispatchRequest publishes a day's plan to the dispatch board, e.g. the
+// routes of a fleet or template response
+type DispatchRequest struct {
+	Date       string       `json:"date"` // YYYY-MM-DD
+	Routes     []FleetRoute `json:"routes"`
+	Unassigned []string     `json:"unassigned_stop_ids,omitempty"`
+
+	// Shipments carries the e-way bill details checked before the plan is
+	// published, by stop ID
+	Shipments []ShipmentDocs `json:"shipments,omitempty"`
+
+	// Consignees names who each stop is for, kept on the board so
+	// dispatchers can search by customer and city
+	Consignees []Consignee `json:"consignees,omitempty"`
+}
+
+// Consignee is the customer a shipment is for and the city it goes to
+type Consignee struct {
+	StopID string `json:"stop_id"`
+	Name   string `json:"name,omitempty"`
+	City   string `json:"city,omitempty"`
+}
+
+// MapRequest draws routes, e.g. those of a fleet response, as a PNG
+type MapRequest struct {
+	Routes []FleetRoute `json:"routes"`
+}
+
+// ShipmentDocs is a shipment's consignment value and e-way bill
+type ShipmentDocs struct {
+	StopID   string    `json:"stop_id"`
+	ValueInr float64   `json:"value_inr"`
+	EWayBill *EWayBill `json:"eway_bill,omitempty"`
+}
+
+// EWayBill is the GST e-way bill a consignment travels under
+type EWayBill struct {
+	Number     string    `json:"number"` // 12 digits
+	ValidUntil time.Time `json:"valid_until"`
+	DistanceKm float64   `json:"distance_km"` // As declared when the bill was generated
+}
+
+// ShipmentProblems is what a shipment lacks to be dispatched
+type ShipmentProblems struct {
+	StopID string   `json:"stop_id"`
+	Errors []string `json:"errors"`
+}
+
+// StopStatusUpdate reports progress on a dispatched stop
+type StopStatusUpdate struct {
+	Date      string `json:"date"`
+	VehicleID string `json:"vehicle_id"`
+	StopID    string `json:"stop_id"`
+	Status    string `json:"status"` // en_route or completed
+}
+
+// BulkStatusUpdate moves many dispatched stops at once, e.g. as parcels
+// are scanned at a hub
+type BulkStatusUpdate struct {
+	Date    string           `json:"date"`
+	Updates []StopStatusScan `json:"updates"`
+}
+
+// StopStatusScan is one update in a bulk status update
+type StopStatusScan struct {
+	StopID    string `json:"stop_id"`
+	VehicleID string `json:"vehicle_id,omitempty"` // Found from the stop when left out
+	Status    string `json:"status"`               // en_route or completed
+}
+
+// BulkStatusResult says how each update went, in request order
+type BulkStatusResult struct {
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Results []StopScanResult `json:"results"`
+}
+
+// StopScanResult is one update's outcome, with the HTTP status it would
+// have had on its own
+type StopScanResult struct {
+	StopID string `json:"stop_id"`
+	Code   int    `json:"code"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DriverStopEvent marks arrival at or departure from a stop on the
+// driver's own run
+type DriverStopEvent struct {
+	Date   string `json:"date,omitempty"` // Defaults to today
+	StopID string `json:"stop_id"`
+
+	// Track is the path driven to the stop as an encoded polyline, as
+	// navigation SDKs report it; arrivals only. TrackPrecision is 5 (the
+	// default, as Google) or 6 (OSRM's polyline6).
+	Track          string `json:"track,omitempty"`
+	TrackPrecision int    `json:"track_precision,omitempty"`
+}
+
+// MarginalCostRequest prices adding one shipment to the plan dispatched
+// for Date without replanning it. Vehicles give capacities; runs of
+// vehicles not listed are not checked for capacity.
+type MarginalCostRequest struct {
+	Date      string        `json:"date,omitempty"` // Defaults to today
+	Shipment  FleetStop     `json:"shipment"`
+	Vehicles  []VehicleInfo `json:"vehicles,omitempty"`
+	SpeedKmph float64       `json:"speed_kmph,omitempty"`  // For the arrival estimate; default 50
+	CostPerKm float64       `json:"cost_per_km,omitempty"` // Running cost in INR; default 30
+}
+
+// MarginalCost is the cheapest feasible insertion, if any, and the best
+// insertion on every run, cheapest first
+type MarginalCost struct {
+	Feasible bool        `json:"feasible"`
+	Best     *Insertion  `json:"best,omitempty"`
+	Options  []Insertion `json:"options"`
+}
+
+// Insertion places a shipment on a vehicle's run at Position in its stop
+// list, between AfterStopID and BeforeStopID (empty for the run's start or
+// end). Reason says why an infeasible one cannot be used.
+type Insertion struct {
+	VehicleID    string  `json:"vehicle_id"`
+	Position     int     `json:"position"`
+	AfterStopID  string  `json:"after_stop_id,omitempty"`
+	BeforeStopID string  `json:"before_stop_id,omitempty"`
+	AddedKm      float64 `json:"added_km"`
+	AddedCostInr float64 `json:"added_cost_inr"`
+	ArrivalHours float64 `json:"arrival_hours"` // Estimated, from the previous stop's ETA
+	Feasible     bool    `json:"feasible"`
+	Reason       string  `json:"reason,omitempty"`
+}
+
+// OrderAcceptanceRequest asks whether to take a spot order onto the plan
+// dispatched for Date. PriceInr is what the customer offers; without it
+// the order is priced from the rate card.
+type OrderAcceptanceRequest struct {
+	MarginalCostRequest
+	PriceInr float64 `json:"price_inr,omitempty"`
+}
+
+// OrderAcceptance recommends accept, reject or needs_review for a spot
+// order from its projected margin: the price less the cost of the cheapest
+// feasible insertion. MarginPct, the margin as a share of the price, is the
+// order's profitability score.
+type OrderAcceptance struct {
+	Recommendation string     `json:"recommendation"`
+	Reason         string     `json:"reason"`
+	PriceInr       float64    `json:"price_inr"`
+	RateCardInr    float64    `json:"rate_card_inr"`
+	CostInr        float64    `json:"cost_inr"`
+	MarginInr      float64    `json:"margin_inr"`
+	MarginPct      float64    `json:"margin_pct"`
+	Insertion      *Insertion `json:"insertion,omitempty"` // Where the order would go
+}
+
+// DeadheadReport is the empty running in the plans dispatched over a date
+// range: per run, per day and in total, with backhaul pairings that would
+// cut it
+type DeadheadReport struct {
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	DistanceKm  float64           `json:"distance_km"`
+	DeadheadKm  float64           `json:"deadhead_km"`
+	DeadheadPct float64           `json:"deadhead_pct"`
+	Days        []DeadheadDay     `json:"days"`
+	Runs        []DeadheadRun     `json:"runs"`
+	Pairings    []BackhaulPairing `json:"pairings"`
+}
+
+type DeadheadDay struct {
+	Date        string  `json:"date"`
+	DistanceKm  float64 `json:"distance_km"`
+	DeadheadKm  float64 `json:"deadhead_km"`
+	DeadheadPct float64 `json:"deadhead_pct"`
+}
+
+// DeadheadRun is a run's empty running: out to its first stop when it only
+// collects returns, back from its last when it only delivers
+type DeadheadRun struct {
+	Date        string  `json:"date"`
+	VehicleID   string  `json:"vehicle_id"`
+	DistanceKm  float64 `json:"distance_km"`
+	DeadheadKm  float64 `json:"deadhead_km"`
+	DeadheadPct float64 `json:"deadhead_pct"`
+}
+
+// BackhaulPairing suggests that the vehicle on one run, instead of coming
+// back empty, carries another run's loads the same or the next day from
+// near where it finished, then heads home from that run's last stop.
+// SavedKm is the empty running it cuts.
+type BackhaulPairing struct {
+	Date            string  `json:"date"`
+	VehicleID       string  `json:"vehicle_id"`
+	PairedDate      string  `json:"paired_date"`
+	PairedVehicleID string  `json:"paired_vehicle_id"`
+	DeadheadKm      float64 `json:"deadhead_km"` // Both runs' now
+	PairedKm        float64 `json:"paired_km"`   // Empty running once paired
+	SavedKm         float64 `json:"saved_km"`
+}
+
+// DensityGrid is a GeoJSON FeatureCollection of the geohash cells stops
+// were dispatched to over a date range, busiest first, for judging where a
+// hub or a dedicated route would pay
+type DensityGrid struct {
+	Type      string        `json:"type"` // Always "FeatureCollection"
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Precision int           `json:"precision"` // Geohash length
+	Days      int           `json:"days"`      // Dispatched days in the range
+	Stops     int           `json:"stops"`
+	Features  []DensityCell `json:"features"`
+}
+
+// DensityCell is a GeoJSON Feature: a geohash cell's polygon and its stops
+type DensityCell struct {
+	Type       string         `json:"type"` // Always "Feature"
+	Geometry   Polygon        `json:"geometry"`
+	Properties DensityCellKPI `json:"properties"`
+}
+
+// Polygon is a GeoJSON polygon; coordinates are [lng, lat] rings, the
+// first point repeated last
+type Polygon struct {
+	Type        string         `json:"type"` // Always "Polygon"
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+type DensityCellKPI struct {
+	Geohash     string   `json:"geohash"`
+	Stops       int      `json:"stops"`
+	SharePct    float64  `json:"share_pct"`     // Of all stops in the range
+	StopsPerDay float64  `json:"stops_per_day"` // Over the dispatched days
+	Days        int      `json:"days"`          // Days with a stop here
+	Vehicles    int      `json:"vehicles"`      // Distinct vehicles that stopped here
+	Centroid    Location `json:"centroid"`      // Of the stops, not the cell
+}
+
+// ShareRequest asks for a read-only link to a dispatched plan, or to one
+// vehicle's run when VehicleID is set
+type ShareRequest struct {
+	Date      string  `json:"date,omitempty"` // Defaults to today
+	VehicleID string  `json:"vehicle_id,omitempty"`
+	TTLHours  float64 `json:"ttl_hours,omitempty"` // Default 24, at most 168
+}
+
+// ShareLink is a minted share link; URL is relative to the service
+type ShareLink struct {
+	Token       string    `json:"token"`
+	URL         string    `json:"url"`
+	CalendarURL string    `json:"calendar_url"` // The stops as an iCalendar feed
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DriverIssueReport is a problem a driver reports, optionally at one stop
+type DriverIssueReport struct {
+	Date   string `json:"date,omitempty"` // Defaults to today
+	StopID string `json:"stop_id,omitempty"`
+	Kind   string `json:"kind"`
+	Note   string `json:"note,omitempty"`
+}
+
+// FleetResponse is the output for multi-vehicle routing
+type FleetResponse struct {
+	Routes      []FleetRoute `json:"routes"`
+	Unassigned  []string     `json:"unassigned_stop_ids"`
+	TotalDistKm float64      `json:"total_distance_km"`
+
+	Crews []CrewPlan `json:"crews,omitempty"` // With compare_crews
+
+	// Outsource suggests handing shipments to carriers: every unassigned
+	// one a carrier takes, and planned ones a carrier moves for less than
+	// they add to their route
+	Outsource []OutsourceSuggestion `json:"outsource,omitempty"`
+
+	Feasibility *FeasibilityReport `json:"feasibility,omitempty"`
+	Meta        *SolveMeta         `json:"meta,omitempty"`
+
+	// Units the figures are in; fields keep their metric and INR names
+	// whatever the deployment's units
+	Units *Units `json:"units,omitempty"`
+}
+
+// Units is a deployment's currency and measures. Requests are read and
+// responses written in them; fields named _km, _kg and _inr carry miles,
+// pounds or another currency where configured. Prose, such as allocation
+// reasons and feasibility messages, stays in km and kg.
+type Units struct {
+	Currency string `json:"currency"` // ISO 4217 code, INR by default
+	Distance string `json:"distance"` // km or mi
+	Weight   string `json:"weight"`   // kg or lb
+}
+
+// FirstMileRequest plans milk runs that collect pickups from shippers and
+// bring them to the nearest hub with vehicles. A pickup's DueHours is its
+// cutoff, the latest the shipper hands over; ReadyHours is when it is
+// packed.
+type FirstMileRequest struct {
+	Hubs      []Hub       `json:"hubs"`
+	Pickups   []FleetStop `json:"pickups"`
+	SpeedKmph float64     `json:"speed_kmph,omitempty"`
+}
+
+// Hub is where first-mile pickups are consolidated, with the vehicles based
+// there
+type Hub struct {
+	ID       string        `json:"id"`
+	Location Location      `json:"location"`
+	Vehicles []VehicleInfo `json:"vehicles"`
+}
+
+// FirstMileResponse is each hub's milk runs. Routes leave the hub empty and
+// return to it loaded.
+type FirstMileResponse struct {
+	Hubs        []HubRuns  `json:"hubs"`
+	Unassigned  []string   `json:"unassigned_pickup_ids"` // Across every hub
+	TotalDistKm float64    `json:"total_distance_km"`
+	Meta        *SolveMeta `json:"meta,omitempty"`
+	Units       *Units     `json:"units,omitempty"`
+}
+
+// HubRuns is one hub's milk runs over the pickups nearest it
+type HubRuns struct {
+	HubID string `json:"hub_id"`
+	FleetResponse
+}
+
+// OutsourceSuggestion is the cheapest carrier for a shipment. InternalInr is
+// what the shipment adds to its planned route in running cost; Reason is
+// no_capacity for shipments the fleet could not take, else cheaper.
+type OutsourceSuggestion struct {
+	StopID      string  `json:"stop_id"`
+	Carrier     string  `json:"carrier"`
+	CarrierInr  float64 `json:"carrier_inr"`
+	InternalInr float64 `json:"internal_inr,omitempty"`
+	SavingInr   float64 `json:"saving_inr,omitempty"`
+	Reason      string  `json:"reason"`
+}
+
+// CrewPlan summarises the plan with every vehicle on one crew: how many
+// vehicles it needs, when the last is back and how long they stand resting
+type CrewPlan struct {
+	Crew        string  `json:"crew"`
+	Vehicles    int     `json:"vehicles"`
+	DistanceKm  float64 `json:"distance_km"`
+	FinishHours float64 `json:"finish_hours"`
+	RestHours   float64 `json:"rest_hours"`
+	Unassigned  int     `json:"unassigned"`
+	Feasible    bool    `json:"feasible"`
+}
+
+type FleetRoute struct {
+	VehicleID    string     `json:"vehicle_id"`
+	StopIDs      []string   `json:"stop_ids"`
+	Route        []Location `json:"route"`              // Depot, stops..., depot
+	Polyline     string     `json:"polyline,omitempty"` // Route as an encoded polyline, with ?geometry=polyline
+	DistanceKm   float64    `json:"distance_km"`
+	LoadKg       float64    `json:"load_kg"`
+	ReturnKg     float64    `json:"return_kg,omitempty"`     // Returns collected
+	PeakLoadKg   float64    `json:"peak_load_kg,omitempty"`  // Most on board at once, when collecting returns
+	ArrivalHours []float64  `json:"arrival_hours,omitempty"` // Service start per stop, when time windows, border delays, curfews or speed classes apply
+	DueHours     []float64  `json:"due_hours,omitempty"`     // Due time per stop, 0 for none, when time windows apply
+
+	BorderDelayHours float64 `json:"border_delay_hours,omitempty"` // Time lost at state border checkpoints
+
+	// NavigationURLs are Google Maps links that drive the route; long
+	// routes need several, opened in turn
+	NavigationURLs []string `json:"navigation_urls,omitempty"`
+
+	Fuel *FuelEstimate `json:"fuel,omitempty"` // When the vehicle's mileage is known
+
+	// Tolls for the vehicle's class, when the plan is costed or given a
+	// toll matrix; TollPlazas are the plazas passed when estimated
+	TollInr    float64  `json:"toll_inr,omitempty"`
+	TollPlazas []string `json:"toll_plazas,omitempty"`
+
+	Capacity *CapacityUse `json:"capacity,omitempty"` // When capacity is reserved
+
+	// StandbyStopIDs are loaded only if space turns up at the dock, when
+	// the plan overbooks
+	StandbyStopIDs []string `json:"standby_stop_ids,omitempty"`
+}
+
+// CapacityUse splits a vehicle's capacity into what the plan uses, what it
+// keeps in reserve and what is free beyond that
+type CapacityUse struct {
+	CapacityKg float64 `json:"capacity_kg"`
+	ReservedKg float64 `json:"reserved_kg"`
+	UsedKg     float64 `json:"used_kg"`
+	FreeKg     float64 `json:"free_kg"`
+}
+
+// FuelEstimate is the fuel a route should burn. Basis says where the
+// mileage came from: measured from the fuel log, or rated as declared on
+// the vehicle.
+type FuelEstimate struct {
+	Litres     float64 `json:"litres"`
+	KmPerLitre float64 `json:"km_per_litre"`
+	Basis      string  `json:"basis"` // measured or rated
+	Cost       float64 `json:"cost,omitempty"`
 }
 
 type Allocation struct {
@@ -53,4 +701,275 @@ type Allocation struct {
 	ShipmentIDs    []string `json:"shipment_ids"`
 	TotalWeight    float64  `json:"total_weight"`
 	UtilizationPct float64  `json:"utilization_pct"`
+	LatePenalty    float64  `json:"late_penalty,omitempty"`
+
+	Reasons []AllocationReason `json:"reasons,omitempty"` // Why each shipment is on this vehicle, in ShipmentIDs order
+}
+
+// AllocationReason explains, for support staff, why a shipment is on its
+// vehicle rather than another, judged against the final plan. Reason is
+// one of:
+//   - only_fit: no other vehicle has room for it
+//   - on_time: the vehicles with room would carry it late, or later
+//   - tightest_fit: of the vehicles with room, this one has least left over,
+//     keeping the others' space for bigger shipments
+//   - solver_choice: another vehicle would do as well; the solver weighed
+//     the plan as a whole
+type AllocationReason struct {
+	ShipmentID   string            `json:"shipment_id"`
+	Reason       string            `json:"reason"`
+	FreeKg       float64           `json:"free_kg"` // Capacity the vehicle has left, with the shipment aboard
+	Detail       string            `json:"detail"`
+	Alternatives []RejectedVehicle `json:"alternatives,omitempty"` // The closest other vehicles, closest first
+}
+
+// RejectedVehicle is a vehicle a shipment could have gone on and why it did
+// not. Reason is capacity (it would be OverKg over), late (it would add
+// LatePenalty) or looser_fit (it would leave FreeKg, more than the chosen one).
+type RejectedVehicle struct {
+	VehicleID   string  `json:"vehicle_id"`
+	Reason      string  `json:"reason"`
+	OverKg      float64 `json:"over_kg,omitempty"`
+	FreeKg      float64 `json:"free_kg,omitempty"`
+	LatePenalty float64 `json:"late_penalty,omitempty"`
+}
+
+// SolveMeta records which solver produced a response and, for automatic
+// selection, why it was chosen
+type SolveMeta struct {
+	Solver    string `json:"solver"`
+	Reason    string `json:"reason,omitempty"`
+	Distances string `json:"distances,omitempty"` // Road distance provider, or why great-circle distances were used
+	Partial   bool   `json:"partial,omitempty"`   // The deadline passed; this is the best answer found in time
+	Degraded  string `json:"degraded,omitempty"`  // SLA tier solved on in place of the one asked for, under load
+	Seeds     int    `json:"seeds,omitempty"`     // Pooled plans for similar earlier problems the solve started from
+
+	// Experiment names the solver experiment the request was enrolled in and
+	// its arm, e.g. alns-v2/variant, or alns-v2/shadow when a variant solves
+	// it in the background
+	Experiment string `json:"experiment,omitempty"`
+}
+
+// DryRunResponse answers a request made with ?dry_run=true: it passed
+// validation, and this is what its solve would face, without solving it
+type DryRunResponse struct {
+	Solver      string             `json:"solver"`
+	Stops       int                `json:"stops"` // Stops, waypoints or shipments to place
+	Vehicles    int                `json:"vehicles"`
+	Feasibility *FeasibilityReport `json:"feasibility"` // Problems the solve cannot fix, as warnings
+
+	// EstimatedSolveSeconds is how long the solve should take; omitted when
+	// there is no estimate for the solver
+	EstimatedSolveSeconds *float64 `json:"estimated_solve_seconds,omitempty"`
+}
+
+// FeasibilityReport lists constraint violations found in a plan. Soft
+// violations (e.g. late deliveries) are priced in but do not make it infeasible.
+type FeasibilityReport struct {
+	Feasible   bool        `json:"feasible"`
+	Violations []Violation `json:"violations"`
+}
+
+type Violation struct {
+	Constraint string `json:"constraint"` // capacity, coverage, endpoint, deadline, time_window, maintenance, service_km
+	VehicleID  string `json:"vehicle_id,omitempty"`
+	NodeID     string `json:"node_id,omitempty"`
+	Message    string `json:"message"`
+	Soft       bool   `json:"soft,omitempty"`
+}
+
+// ValidatePlanRequest submits an externally produced plan for checking.
+// Set Load with Allocations for a load plan, or Route with Plan for a route.
+type ValidatePlanRequest struct {
+	Load        *LoadRequest         `json:"load,omitempty"`
+	Allocations []Allocation         `json:"allocations,omitempty"`
+	Route       *OptimizationRequest `json:"route,omitempty"`
+	Plan        []Location           `json:"plan,omitempty"`
+}
+
+// ForecastRequest asks for projected daily volumes per lane (or zone) from
+// their history, and the fleet that would carry them
+type ForecastRequest struct {
+	History     []DemandDay `json:"history"`
+	Method      string      `json:"method,omitempty"`       // moving_average or holt_winters; default holt_winters when there are two seasons of history
+	HorizonDays int         `json:"horizon_days,omitempty"` // Default 7, at most 90
+	WindowDays  int         `json:"window_days,omitempty"`  // Moving average window; default 7
+	SeasonDays  int         `json:"season_days,omitempty"`  // Holt-Winters season; default 7 (weekly)
+
+	VehicleCapacityKg float64 `json:"vehicle_capacity_kg,omitempty"`
+	StopsPerVehicle   int     `json:"stops_per_vehicle,omitempty"` // Shipments one vehicle can deliver in a day
+}
+
+// DemandDay is one lane's volume on one day. Days missing between a lane's
+// first and last day count as zero.
+type DemandDay struct {
+	Date      string  `json:"date"`
+	Lane      string  `json:"lane"`
+	Shipments float64 `json:"shipments"`
+	WeightKg  float64 `json:"weight_kg,omitempty"`
+}
+
+// ForecastResponse is the projection per lane and in total. Each lane is
+// served by its own vehicles, so the total needs the sum of theirs;
+// SuggestedFleetSize is the busiest day's.
+type ForecastResponse struct {
+	Lanes              []LaneForecast `json:"lanes"`
+	Total              []ForecastDay  `json:"total"`
+	SuggestedFleetSize int            `json:"suggested_fleet_size"`
+}
+
+type LaneForecast struct {
+	Lane   string        `json:"lane"`
+	Method string        `json:"method"`
+	Days   []ForecastDay `json:"days"`
+}
+
+type ForecastDay struct {
+	Date      string  `json:"date"`
+	Shipments float64 `json:"shipments"`
+	WeightKg  float64 `json:"weight_kg"`
+	Vehicles  int     `json:"vehicles,omitempty"`
+}
+
+// OverloadCheckRequest asks whether vehicles are, or are planned to be,
+// loaded beyond their legal gross vehicle weight. Planned loads come from
+// Routes or, when there are none, the runs dispatched for Date; Actual
+// holds weighbridge readings.
+type OverloadCheckRequest struct {
+	Vehicles []VehicleWeights     `json:"vehicles"`
+	Routes   []FleetRoute         `json:"routes,omitempty"`
+	Date     string               `json:"date,omitempty"`
+	Actual   []WeighbridgeReading `json:"actual,omitempty"`
+}
+
+// VehicleWeights is what the law weighs a vehicle against: its class and
+// unladen weight, and the GVW on its registration certificate, which takes
+// precedence over the class's limit
+type VehicleWeights struct {
+	ID        string  `json:"id"`
+	Class     string  `json:"class,omitempty"` // lcv, 2-axle, 3-axle or 4-axle
+	UnladenKg float64 `json:"unladen_kg"`
+	GVWKg     float64 `json:"gvw_kg,omitempty"`
+}
+
+// WeighbridgeReading is a vehicle's measured weight: GrossKg as weighed, or
+// PayloadKg alone when only the cargo was weighed
+type WeighbridgeReading struct {
+	VehicleID string  `json:"vehicle_id"`
+	GrossKg   float64 `json:"gross_kg,omitempty"`
+	PayloadKg float64 `json:"payload_kg,omitempty"`
+}
+
+// OverloadReport lists a finding per planned or weighed vehicle; Compliant
+// is false if any is overloaded
+type OverloadReport struct {
+	Compliant bool              `json:"compliant"`
+	Findings  []OverloadFinding `json:"findings"`
+}
+
+type OverloadFinding struct {
+	VehicleID   string  `json:"vehicle_id"`
+	Source      string  `json:"source"` // planned or actual
+	GrossKg     float64 `json:"gross_kg"`
+	LegalGVWKg  float64 `json:"legal_gvw_kg"`
+	OverloadKg  float64 `json:"overload_kg,omitempty"`
+	OverloadPct float64 `json:"overload_pct,omitempty"`
+	Status      string  `json:"status"`             // ok, near_limit or overloaded
+	FineInr     float64 `json:"fine_inr,omitempty"` // Under section 194(1) of the Motor Vehicles Act
+}
+
+// PlanningSession gathers shipments from several clients until Cutoff,
+// then plans them all in one fleet optimization so loads are not split
+// across separately planned requests. Fleet holds the depot, vehicles and
+// options; its stops are every submission's, in the order they came in.
+type PlanningSession struct {
+	Name        string              `json:"name"`
+	Cutoff      time.Time           `json:"cutoff"`
+	Fleet       FleetRequest        `json:"fleet"`
+	Status      string              `json:"status,omitempty"` // open, closed, planning or planned
+	Submissions []SessionSubmission `json:"submissions,omitempty"`
+	Plan        *FleetResponse      `json:"plan,omitempty"` // Once planned
+	Tenant      string              `json:"tenant,omitempty"`
+}
+
+// SessionSubmission is one client's shipments for a planning session. Stop
+// IDs must be unique across the session.
+type SessionSubmission struct {
+	Client      string      `json:"client"`
+	Stops       []FleetStop `json:"stops"`
+	SubmittedAt time.Time   `json:"submitted_at,omitempty"`
+}
+
+// Scenario is a candidate plan for a day saved under a name, so planners
+// can keep several (more vehicles, tighter windows, another depot) and
+// compare them before dispatching one. KPIs are worked out when it is
+// saved; running cost is distance at CostPerKm plus tolls.
+type Scenario struct {
+	Name      string        `json:"name"`
+	Date      string        `json:"date"`
+	Plan      FleetResponse `json:"plan"`
+	CostPerKm float64       `json:"cost_per_km,omitempty"` // INR; default 30
+	Note      string        `json:"note,omitempty"`
+	KPIs      ScenarioKPIs  `json:"kpis"`
+	SavedAt   time.Time     `json:"saved_at"`
+	Tenant    string        `json:"tenant,omitempty"`
+}
+
+// ScenarioKPIs are what scenarios are compared on. Violations counts the
+// plan's feasibility report, soft ones included.
+type ScenarioKPIs struct {
+	DistanceKm float64 `json:"distance_km"`
+	CostInr    float64 `json:"cost_inr"`
+	Vehicles   int     `json:"vehicles"` // With at least one stop
+	Stops      int     `json:"stops"`
+	Unassigned int     `json:"unassigned"`
+	Violations int     `json:"violations"`
+}
+
+// ScenarioComparison sets two scenarios for a day side by side. Delta is
+// B less A, so a negative distance or cost means B is cheaper.
+type ScenarioComparison struct {
+	Date  string       `json:"date"`
+	A     string       `json:"a"`
+	B     string       `json:"b"`
+	AKPIs ScenarioKPIs `json:"a_kpis"`
+	BKPIs ScenarioKPIs `json:"b_kpis"`
+	Delta ScenarioKPIs `json:"delta"`
+}
+
+// TenantUsage is a tenant's optimization requests and solver seconds in a
+// month beside its monthly quotas, which are 0 when unlimited
+type TenantUsage struct {
+	Tenant               string  `json:"tenant"`
+	Month                string  `json:"month"` // YYYY-MM
+	Requests             int     `json:"requests"`
+	SolverSeconds        float64 `json:"solver_seconds"`
+	MonthlyRequests      int     `json:"monthly_requests,omitempty"`
+	MonthlySolverSeconds float64 `json:"monthly_solver_seconds,omitempty"`
+}
+
+// Credential is a configured secret as admins see it: never its value,
+// only a masked form. Rotate marks values sealed with a key that is no
+// longer the keyring's first.
+type Credential struct {
+	Name   string `json:"name"`
+	Masked string `json:"masked"`
+	Sealed bool   `json:"sealed"`
+	KeyID  string `json:"key_id,omitempty"`
+	Rotate bool   `json:"rotate,omitempty"`
+}
+
+// BackupImport counts the records a bundle import stored
+type BackupImport struct {
+	Templates      int `json:"templates"`
+	StandingOrders int `json:"standing_orders"`
+	Maintenance    int `json:"maintenance"`
+	Sessions       int `json:"sessions"`
+	Boards         int `json:"boards"`
+}
+
+// BulkSubmission is a batch of jobs queued from an NDJSON stream
+type BulkSubmission struct {
+	Batch string `json:"batch"`
+	Jobs  int    `json:"jobs"`
 }
@@ -0,0 +1,166 @@
+// Package network builds hierarchical hub-and-spoke plans: regional hubs,
+// local tours from each hub, and a line-haul tour linking the hubs.
+package network
+
+import (
+	"context"
+	"errors"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/spatial"
+)
+
+// SolveFunc solves a single-vehicle routing problem
+type SolveFunc func(ctx context.Context, p *problem.Problem) (problem.Solution, error)
+
+// medoidRounds bounds the hub refinement loop; it converges in a few rounds
+// on city-sized inputs
+const medoidRounds = 10
+
+// Plan picks k hubs among cities, assigns every city to its nearest hub, and
+// routes each territory plus the inter-hub line haul. The first city is always
+// a hub and starts the line haul.
+func Plan(ctx context.Context, cities []models.NamedLocation, k int, solve SolveFunc) (models.NetworkPlan, error) {
+	if len(cities) == 0 {
+		return models.NetworkPlan{}, errors.New("no cities to plan")
+	}
+	k = max(1, min(k, len(cities)))
+
+	hubs := selectHubs(cities, k)
+	territories := assign(cities, hubs)
+
+	plan := models.NetworkPlan{Hubs: make([]models.HubPlan, len(hubs))}
+	for i, h := range hubs {
+		hub := cities[h]
+		members := []models.NamedLocation{}
+		waypoints := []models.Location{}
+		for _, c := range territories[i] {
+			if c == h {
+				continue
+			}
+			members = append(members, cities[c])
+			waypoints = append(waypoints, location(cities[c]))
+		}
+
+		tour, err := route(ctx, location(hub), waypoints, solve)
+		if err != nil {
+			return models.NetworkPlan{}, err
+		}
+		plan.Hubs[i] = models.HubPlan{Hub: hub, Cities: members, Tour: tour}
+		plan.TotalDistKm += tour.TotalDistKm
+	}
+
+	hubLocs := make([]models.Location, 0, len(hubs)-1)
+	for _, h := range hubs[1:] {
+		hubLocs = append(hubLocs, location(cities[h]))
+	}
+	lineHaul, err := route(ctx, location(cities[hubs[0]]), hubLocs, solve)
+	if err != nil {
+		return models.NetworkPlan{}, err
+	}
+	plan.LineHaul = lineHaul
+	plan.TotalDistKm += lineHaul.TotalDistKm
+
+	return plan, nil
+}
+
+func route(ctx context.Context, depot models.Location, waypoints []models.Location, solve SolveFunc) (models.OptimizationResponse, error) {
+	p := problem.FromRouteRequest(models.OptimizationRequest{Start: depot, End: depot, Waypoints: waypoints})
+	sol, err := solve(ctx, p)
+	if err != nil {
+		return models.OptimizationResponse{}, err
+	}
+	return sol.ToRouteResponse(p), nil
+}
+
+// selectHubs seeds hubs farthest-first from city 0, then refines them as
+// medoids (the member minimizing total distance to its territory). City 0
+// stays pinned as the first hub. Fewer than k come back when the rest of
+// the cities share a hub's coordinates.
+func selectHubs(cities []models.NamedLocation, k int) []int {
+	hubs := []int{0}
+	picked := map[int]bool{0: true}
+	for len(hubs) < k {
+		best, bestDist := -1, 0.0
+		for i := range cities {
+			if picked[i] {
+				continue
+			}
+			if d := nearestHubDist(cities, hubs, i); d > bestDist {
+				best, bestDist = i, d
+			}
+		}
+		if best < 0 {
+			break // Every city left sits on a hub
+		}
+		hubs = append(hubs, best)
+		picked[best] = true
+	}
+
+	for round := 0; round < medoidRounds; round++ {
+		territories := assign(cities, hubs)
+		changed := false
+		for i := 1; i < len(hubs); i++ {
+			if m := medoid(cities, territories[i]); m != hubs[i] {
+				hubs[i] = m
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return hubs
+}
+
+// assign groups city indices by nearest hub; territories[i] belongs to hubs[i]
+func assign(cities []models.NamedLocation, hubs []int) [][]int {
+	locs := make([]models.Location, len(hubs))
+	own := map[int]int{} // Hub city to its hub, so a hub never joins a co-located one
+	for i, h := range hubs {
+		locs[i] = location(cities[h])
+		own[h] = i
+	}
+	index := spatial.New(locs)
+
+	territories := make([][]int, len(hubs))
+	for c := range cities {
+		best, ok := own[c]
+		if !ok {
+			best = index.Nearest(location(cities[c]), 1)[0]
+		}
+		territories[best] = append(territories[best], c)
+	}
+	return territories
+}
+
+func medoid(cities []models.NamedLocation, members []int) int {
+	best, bestSum := members[0], math.MaxFloat64
+	for _, m := range members {
+		sum := 0.0
+		for _, o := range members {
+			sum += dist(cities[m], cities[o])
+		}
+		if sum < bestSum {
+			best, bestSum = m, sum
+		}
+	}
+	return best
+}
+
+func nearestHubDist(cities []models.NamedLocation, hubs []int, c int) float64 {
+	d := math.MaxFloat64
+	for _, h := range hubs {
+		d = math.Min(d, dist(cities[c], cities[h]))
+	}
+	return d
+}
+
+func dist(a, b models.NamedLocation) float64 {
+	return problem.Haversine(location(a), location(b))
+}
+
+func location(c models.NamedLocation) models.Location {
+	return models.Location{Lat: c.Lat, Lng: c.Lng}
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"context"
+	"milesconnect-optimization/internal/data"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"slices"
+	"testing"
+)
+
+func nearestNeighbor(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	return solver.NearestNeighbor(p), nil
+}
+
+func TestPlanCoversEveryCityOnce(t *testing.T) {
+	cities := data.IndianCities
+	plan, err := Plan(context.Background(), cities, 6, nearestNeighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Hubs) != 6 {
+		t.Fatalf("got %d hubs, want 6", len(plan.Hubs))
+	}
+	if plan.Hubs[0].Hub.Name != cities[0].Name {
+		t.Errorf("first hub = %s, want %s", plan.Hubs[0].Hub.Name, cities[0].Name)
+	}
+
+	seen := map[string]int{}
+	for _, h := range plan.Hubs {
+		seen[h.Hub.Name]++
+		for _, c := range h.Cities {
+			seen[c.Name]++
+		}
+		if want := len(h.Cities) + 2; len(h.Tour.Route) != want {
+			t.Errorf("hub %s tour has %d stops, want %d", h.Hub.Name, len(h.Tour.Route), want)
+		}
+	}
+	for _, c := range cities {
+		if seen[c.Name] != 1 {
+			t.Errorf("%s appears %d times", c.Name, seen[c.Name])
+		}
+	}
+	if want := len(plan.Hubs) + 1; len(plan.LineHaul.Route) != want {
+		t.Errorf("line haul has %d stops, want %d", len(plan.LineHaul.Route), want)
+	}
+}
+
+func TestPlanClampsHubCount(t *testing.T) {
+	plan, err := Plan(context.Background(), data.IndianCities[:3], 10, nearestNeighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Hubs) != 3 {
+		t.Errorf("got %d hubs, want 3", len(plan.Hubs))
+	}
+}
+
+func TestPlanWithColocatedCities(t *testing.T) {
+	// Three depots in one warehouse park and one city elsewhere: two hubs
+	// are all there is to pick
+	cities := append(slices.Repeat(data.IndianCities[:1], 3), data.IndianCities[1])
+	for i := range cities[:3] {
+		cities[i].Name += string(rune('A' + i))
+	}
+	plan, err := Plan(context.Background(), cities, 4, nearestNeighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Hubs) != 2 || plan.Hubs[0].Hub.Name == plan.Hubs[1].Hub.Name {
+		t.Fatalf("hubs = %+v", plan.Hubs)
+	}
+	if got := len(plan.Hubs[0].Cities) + len(plan.Hubs[1].Cities); got != 2 {
+		t.Errorf("%d cities assigned to hubs, want 2", got)
+	}
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	chatDelivered = metrics.NewCounter("chat_messages_delivered_total", "Planning messages accepted by Slack or Teams")
+	chatFailed    = metrics.NewCounter("chat_messages_failed_total", "Planning messages dropped after retries or because the buffer was full")
+)
+
+// ChatMessage is a note for planners in a Slack or Teams channel: a title,
+// a line of text and name-value facts under it
+type ChatMessage struct {
+	Kind  string // slack or teams
+	URL   string // The channel's incoming webhook
+	Title string
+	Text  string
+	Facts []Fact
+}
+
+// Fact is one line of a message's details
+type Fact struct {
+	Name, Value string
+}
+
+// Chat posts messages to Slack and Teams incoming webhooks from a
+// background worker, retrying as Webhook does
+type Chat struct {
+	client   *http.Client
+	backoff  time.Duration
+	messages chan ChatMessage
+	done     chan struct{}
+}
+
+// NewChat starts a chat sender posting with client; Close stops it
+func NewChat(client *http.Client) *Chat {
+	c := &Chat{
+		client:   client,
+		backoff:  time.Second,
+		messages: make(chan ChatMessage, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Send queues msg without blocking; when the buffer is full it is dropped
+// and counted as failed. A nil Chat discards it.
+func (c *Chat) Send(msg ChatMessage) {
+	if c == nil {
+		return
+	}
+	select {
+	case c.messages <- msg:
+	default:
+		chatFailed.Inc()
+	}
+}
+
+// Close delivers the queued messages and stops the worker
+func (c *Chat) Close() {
+	close(c.messages)
+	<-c.done
+}
+
+func (c *Chat) run() {
+	defer close(c.done)
+	for msg := range c.messages {
+		if err := c.deliver(msg); err != nil {
+			chatFailed.Inc()
+			log.Printf("notify: dropping %s message %q: %v", msg.Kind, msg.Title, err)
+			continue
+		}
+		chatDelivered.Inc()
+	}
+}
+
+func (c *Chat) deliver(msg ChatMessage) error {
+	body, err := json.Marshal(msg.payload())
+	if err != nil {
+		return err
+	}
+	return retry(c.backoff, func() (bool, error) { return c.post(msg.URL, body) })
+}
+
+func (c *Chat) post(webhook string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("invalid webhook URL") // The error quotes it, token and all
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if ue, ok := err.(*url.Error); ok {
+		// The URL's path is the webhook's token; name only its host
+		return true, fmt.Errorf("%s webhook: %w", req.URL.Host, ue.Err)
+	}
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("%s webhook returned %s", req.URL.Host, resp.Status)
+	}
+	return false, nil
+}
+
+// payload is the webhook body for the message's kind: Slack's mrkdwn text,
+// or a Teams message carrying an Adaptive Card
+func (msg ChatMessage) payload() any {
+	if msg.Kind == "teams" {
+		body := []map[string]any{
+			{"type": "TextBlock", "text": msg.Title, "weight": "Bolder", "size": "Medium", "wrap": true},
+			{"type": "TextBlock", "text": msg.Text, "wrap": true},
+		}
+		if len(msg.Facts) > 0 {
+			facts := make([]map[string]string, len(msg.Facts))
+			for i, f := range msg.Facts {
+				facts[i] = map[string]string{"title": f.Name, "value": f.Value}
+			}
+			body = append(body, map[string]any{"type": "FactSet", "facts": facts})
+		}
+		return map[string]any{
+			"type": "message",
+			"attachments": []map[string]any{{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			}},
+		}
+	}
+	var b strings.Builder
+	b.WriteString("*" + slackEscape(msg.Title) + "*\n" + slackEscape(msg.Text))
+	for _, f := range msg.Facts {
+		b.WriteString("\n• " + slackEscape(f.Name) + ": " + slackEscape(f.Value))
+	}
+	return map[string]string{"text": b.String()}
+}
+
+// slackEscape escapes the characters Slack's mrkdwn reads as markup
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
@@ -0,0 +1,148 @@
+// Package notify turns dispatch board changes into per-shipment events and
+// delivers them to a webhook, from where customer-facing systems send SMS or
+// WhatsApp updates.
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/i18n"
+	"time"
+)
+
+// Event types
+const (
+	ETAPublished = "eta.published"       // The shipment is on a dispatched plan for the first time
+	ETAUpdated   = "eta.updated"         // A replanned board moved its ETA or vehicle materially
+	Unassigned   = "shipment.unassigned" // A replanned board dropped it
+)
+
+// Event is one notification about one shipment
+type Event struct {
+	ID         string    `json:"id"` // Unique; receivers can deduplicate retries on it
+	Type       string    `json:"type"`
+	Date       string    `json:"date"`
+	ShipmentID string    `json:"shipment_id"`
+	VehicleID  string    `json:"vehicle_id,omitempty"`
+	Window     *Window   `json:"eta_window,omitempty"` // Absent when the plan has no time windows
+	Previous   *Window   `json:"previous_eta_window,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// Message is the text to send the customer, in the policy's language
+	Message string `json:"message"`
+}
+
+// Window is the arrival window promised to the customer
+type Window struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Policy turns plan-relative ETAs into clock windows and decides which
+// changes are worth telling the customer about
+type Policy struct {
+	DayStart  time.Duration  // Clock time routes leave the depot, e.g. 9h
+	Zone      *time.Location // Zone DayStart is in
+	Margin    time.Duration  // Window is ETA ± Margin
+	Threshold time.Duration  // Smaller ETA moves are not sent
+	Language  string         // Of event messages: en or hi
+}
+
+// DefaultPolicy starts routes at 09:00 IST and promises ±30 minute windows
+var DefaultPolicy = Policy{
+	DayStart:  9 * time.Hour,
+	Zone:      time.FixedZone("IST", 5*3600+1800),
+	Margin:    30 * time.Minute,
+	Threshold: 15 * time.Minute,
+	Language:  i18n.English,
+}
+
+// Diff returns the events for publishing cur over prev, which is nil the
+// first time a date is dispatched
+func Diff(prev *dispatch.Board, cur dispatch.Board, p Policy) []Event {
+	type placed struct {
+		vehicle string
+		eta     float64
+	}
+	before := map[string]placed{}
+	if prev != nil {
+		for _, r := range prev.Runs {
+			for _, st := range r.Stops {
+				before[st.ID] = placed{r.VehicleID, st.ETAHours}
+			}
+		}
+	}
+
+	var events []Event
+	seen := map[string]bool{}
+	for _, r := range cur.Runs {
+		for _, st := range r.Stops {
+			seen[st.ID] = true
+			ev := Event{Date: cur.Date, ShipmentID: st.ID, VehicleID: r.VehicleID, Window: p.Window(cur.Date, st.ETAHours)}
+			old, ok := before[st.ID]
+			switch {
+			case !ok:
+				ev.Type = ETAPublished
+			case old.vehicle != r.VehicleID || math.Abs(st.ETAHours-old.eta)*float64(time.Hour) >= float64(p.Threshold):
+				ev.Type = ETAUpdated
+				ev.Previous = p.Window(cur.Date, old.eta)
+			default:
+				continue
+			}
+			events = append(events, ev)
+		}
+	}
+	if prev != nil {
+		for _, r := range prev.Runs {
+			for _, st := range r.Stops {
+				if !seen[st.ID] {
+					events = append(events, Event{Type: Unassigned, Date: cur.Date, ShipmentID: st.ID, Previous: p.Window(cur.Date, st.ETAHours)})
+				}
+			}
+		}
+	}
+
+	for i := range events {
+		events[i].ID = newID()
+		events[i].OccurredAt = cur.PublishedAt
+		events[i].Message = p.message(events[i])
+	}
+	return events
+}
+
+// Window is the promised arrival window for a stop ETAHours into date's
+// plan, or nil when the plan has no ETA for it
+func (p Policy) Window(date string, etaHours float64) *Window {
+	if etaHours == 0 {
+		return nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, p.Zone)
+	if err != nil {
+		return nil
+	}
+	eta := day.Add(p.DayStart + time.Duration(etaHours*float64(time.Hour))).Round(time.Minute)
+	return &Window{From: eta.Add(-p.Margin), To: eta.Add(p.Margin)}
+}
+
+// message words ev for the customer
+func (p Policy) message(ev Event) string {
+	t := func(msg string) string { return i18n.T(p.Language, msg) }
+	switch {
+	case ev.Type == Unassigned:
+		return fmt.Sprintf(t("Shipment %s could not be scheduled for %s; we will contact you to rearrange"), ev.ShipmentID, ev.Date)
+	case ev.Window == nil:
+		return fmt.Sprintf(t("Shipment %s is scheduled for delivery on %s"), ev.ShipmentID, ev.Date)
+	case ev.Type == ETAUpdated:
+		return fmt.Sprintf(t("Shipment %s will now arrive between %s and %s"), ev.ShipmentID, ev.Window.From.Format("15:04"), ev.Window.To.Format("15:04"))
+	}
+	return fmt.Sprintf(t("Shipment %s will arrive between %s and %s"), ev.ShipmentID, ev.Window.From.Format("15:04"), ev.Window.To.Format("15:04"))
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"milesconnect-optimization/internal/dispatch"
+	"milesconnect-optimization/internal/i18n"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func board(runs ...dispatch.Run) dispatch.Board {
+	return dispatch.Board{Date: "2026-10-15", Runs: runs, PublishedAt: time.Now()}
+}
+
+func run(vehicle string, stops ...dispatch.Stop) dispatch.Run {
+	return dispatch.Run{VehicleID: vehicle, Stops: stops}
+}
+
+func TestDiff(t *testing.T) {
+	first := board(run("V1", dispatch.Stop{ID: "A", ETAHours: 1}, dispatch.Stop{ID: "B", ETAHours: 2}, dispatch.Stop{ID: "C", ETAHours: 3}))
+	events := Diff(nil, first, DefaultPolicy)
+	if len(events) != 3 || events[0].Type != ETAPublished {
+		t.Fatalf("first publish = %+v", events)
+	}
+	// 1h after the 09:00 IST start, ±30 minutes
+	if w := events[0].Window; w == nil || w.From.Format("15:04") != "09:30" || w.To.Format("15:04") != "10:30" {
+		t.Errorf("window = %+v", w)
+	}
+
+	// A moves 6 minutes, B 1 hour, C changes vehicle and D drops out
+	first.Runs[0].Stops = append(first.Runs[0].Stops, dispatch.Stop{ID: "D", ETAHours: 4})
+	second := board(
+		run("V1", dispatch.Stop{ID: "A", ETAHours: 1.1}, dispatch.Stop{ID: "B", ETAHours: 3}),
+		run("V2", dispatch.Stop{ID: "C", ETAHours: 3}),
+	)
+	got := map[string]Event{}
+	for _, ev := range Diff(&first, second, DefaultPolicy) {
+		got[ev.ShipmentID] = ev
+	}
+	if _, ok := got["A"]; ok || len(got) != 3 {
+		t.Fatalf("replan events = %+v, want B, C and D", got)
+	}
+	if ev := got["B"]; ev.Type != ETAUpdated || ev.Previous == nil || ev.Previous.From.Format("15:04") != "10:30" {
+		t.Errorf("B = %+v", ev)
+	}
+	if ev := got["C"]; ev.Type != ETAUpdated || ev.VehicleID != "V2" {
+		t.Errorf("C = %+v", ev)
+	}
+	if ev := got["D"]; ev.Type != Unassigned || ev.Window != nil {
+		t.Errorf("D = %+v", ev)
+	}
+}
+
+func TestDiffMessages(t *testing.T) {
+	b := board(run("V1", dispatch.Stop{ID: "A", ETAHours: 1}))
+	if got := Diff(nil, b, DefaultPolicy)[0].Message; got != "Shipment A will arrive between 09:30 and 10:30" {
+		t.Errorf("English message = %q", got)
+	}
+	p := DefaultPolicy
+	p.Language = i18n.Hindi
+	if got := Diff(nil, b, p)[0].Message; got != "शिपमेंट A 09:30 से 10:30 के बीच पहुँचेगा" {
+		t.Errorf("Hindi message = %q", got)
+	}
+	if got := Diff(&b, board(), p)[0].Message; !strings.Contains(got, "2026-10-15") || !strings.HasPrefix(got, "शिपमेंट A") {
+		t.Errorf("Hindi unassigned message = %q", got)
+	}
+}
+
+func TestWebhookRetriesAndSigns(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		received []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		m := hmac.New(sha256.New, []byte("k"))
+		m.Write(body)
+		if r.Header.Get(SignatureHeader) != "sha256="+hex.EncodeToString(m.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		var ev Event
+		json.Unmarshal(body, &ev)
+		received = append(received, ev)
+	}))
+	defer srv.Close()
+
+	h := NewWebhook(srv.URL, "k")
+	h.backoff = time.Millisecond
+	h.Send(Diff(nil, board(run("V1", dispatch.Stop{ID: "A"}, dispatch.Stop{ID: "B"})), DefaultPolicy))
+	h.Close()
+
+	if attempts != 3 || len(received) != 2 || received[0].ShipmentID != "A" || received[0].ID == "" {
+		t.Errorf("attempts = %d, received = %+v", attempts, received)
+	}
+}
+
+func TestChatPayloads(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies = map[string]map[string]any{}
+		tries  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tries++; tries == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies[r.URL.Path] = body
+	}))
+	defer srv.Close()
+
+	c := NewChat(srv.Client())
+	c.backoff = time.Millisecond
+	msg := ChatMessage{Title: "Plan published for 2026-10-15", Text: "3 vehicles <run> 12 stops", Facts: []Fact{{"Unassigned", "S1 & S2"}}}
+	slack, teams := msg, msg
+	slack.Kind, slack.URL = "slack", srv.URL+"/slack"
+	teams.Kind, teams.URL = "teams", srv.URL+"/teams"
+	c.Send(slack)
+	c.Send(teams)
+	c.Close()
+
+	if want := "*Plan published for 2026-10-15*\n3 vehicles &lt;run&gt; 12 stops\n• Unassigned: S1 &amp; S2"; bodies["/slack"]["text"] != want {
+		t.Errorf("slack text = %q", bodies["/slack"]["text"])
+	}
+	card, _ := json.Marshal(bodies["/teams"])
+	for _, want := range []string{`"type":"message"`, `"contentType":"application/vnd.microsoft.card.adaptive"`, `"type":"FactSet"`, `"title":"Unassigned","value":"S1 \u0026 S2"`} {
+		if !strings.Contains(string(card), want) {
+			t.Errorf("teams card lacks %s: %s", want, card)
+		}
+	}
+}
+
+func TestChatErrorsHideTheWebhookToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	hook := srv.URL + "/services/T0/B0/token-xyz"
+	srv.Close()
+	c := &Chat{client: http.DefaultClient}
+	for _, url := range []string{hook, "https://hooks.slack.com/services/\x7ftoken-xyz"} {
+		if _, err := c.post(url, []byte(`{}`)); err == nil || strings.Contains(err.Error(), "token-xyz") {
+			t.Errorf("post: %v", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"milesconnect-optimization/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of the body>" when a secret is set
+const SignatureHeader = "X-Milesconnect-Signature"
+
+const (
+	maxAttempts = 4
+	bufferSize  = 1024
+)
+
+var (
+	delivered = metrics.NewCounter("notify_events_delivered_total", "Shipment events accepted by the webhook")
+	failed    = metrics.NewCounter("notify_events_failed_total", "Shipment events dropped after retries or because the buffer was full")
+)
+
+// Webhook posts events one per request to a URL from a background worker,
+// retrying 5xx responses and network errors with backoff
+type Webhook struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	backoff time.Duration
+	events  chan Event
+	done    chan struct{}
+}
+
+// NewWebhook starts a webhook sender; Close stops it
+func NewWebhook(url, secret string) *Webhook {
+	h := &Webhook{
+		url:     url,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: time.Second,
+		events:  make(chan Event, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// Send queues events without blocking; when the buffer is full the rest are
+// dropped and counted as failed. A nil Webhook discards them.
+func (h *Webhook) Send(events []Event) {
+	if h == nil {
+		return
+	}
+	for _, ev := range events {
+		select {
+		case h.events <- ev:
+		default:
+			failed.Inc()
+		}
+	}
+}
+
+// Close delivers the queued events and stops the worker
+func (h *Webhook) Close() {
+	close(h.events)
+	<-h.done
+}
+
+func (h *Webhook) run() {
+	defer close(h.done)
+	for ev := range h.events {
+		if err := h.deliver(ev); err != nil {
+			failed.Inc()
+			log.Printf("notify: dropping %s for %s: %v", ev.Type, ev.ShipmentID, err)
+			continue
+		}
+		delivered.Inc()
+	}
+}
+
+func (h *Webhook) deliver(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	return retry(h.backoff, func() (bool, error) { return h.post(body) })
+}
+
+// retry makes up to maxAttempts attempts, doubling the wait from backoff
+// between them, while attempt reports failures worth retrying
+func retry(backoff time.Duration, attempt func() (bool, error)) error {
+	wait := backoff
+	for n := 1; ; n++ {
+		again, err := attempt()
+		if err == nil || !again || n == maxAttempts {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends one attempt and reports whether a failure is worth retrying
+func (h *Webhook) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.secret) > 0 {
+		m := hmac.New(sha256.New, h.secret)
+		m.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(m.Sum(nil)))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,167 @@
+// Package objstore reads job requests from and writes their results to
+// object storage, S3 or GCS, through signed URLs the submitter hands over,
+// for jobs too big to pass through the API. Signed URLs carry their own
+// credentials, so the service holds none; it only fetches from the hosts it
+// is told to trust, over HTTPS and without following redirects, so a job
+// cannot point it at internal services.
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultHosts are S3's regional endpoints, path-style and with a bucket
+// subdomain, and GCS's
+var DefaultHosts = []string{"s3.*.amazonaws.com", "*.s3.*.amazonaws.com", "storage.googleapis.com"}
+
+var errRedirect = errors.New("objstore: redirects are not followed")
+
+// Store fetches and writes objects through signed URLs
+type Store struct {
+	hosts  []string
+	client *http.Client
+}
+
+// New returns a store trusting hosts, each a host name or a pattern where
+// * stands for any one label, e.g. "*.s3.*.amazonaws.com". It uses a copy
+// of client that refuses redirects, since a trusted host could otherwise
+// send it anywhere.
+func New(hosts []string, client *http.Client) *Store {
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return errRedirect }
+	return &Store{hosts: hosts, client: &c}
+}
+
+// PublicTransport returns a transport that will not connect to loopback,
+// private, link-local or other non-public addresses, for a store that only
+// trusts public object storage: a trusted name that resolves to an internal
+// address is refused when dialled.
+func PublicTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+	t.DialContext = d.DialContext
+	return t
+}
+
+// publicOnly refuses to dial an address that is not a public unicast one
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("objstore: dialling %s: %w", address, err)
+	}
+	ip := ap.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("objstore: %s is not a public address", ip)
+	}
+	return nil
+}
+
+// Check returns why the store would not use raw, if it would not
+func (s *Store) Check(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("objstore: %s is not an https URL", Location(raw))
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range s.hosts {
+		if hostMatches(host, h) {
+			return nil
+		}
+	}
+	return fmt.Errorf("objstore: %s is not an object storage host", host)
+}
+
+// hostMatches reports whether host is pattern, label for label, where a *
+// in the pattern matches any one label
+func hostMatches(host, pattern string) bool {
+	labels, want := strings.Split(host, "."), strings.Split(strings.ToLower(pattern), ".")
+	if len(labels) != len(want) {
+		return false
+	}
+	for i, l := range labels {
+		if l == "" || want[i] != "*" && want[i] != l {
+			return false
+		}
+	}
+	return true
+}
+
+// Get reads the object at a signed URL, failing when it is over max bytes
+func (s *Store) Get(ctx context.Context, raw string, max int64) ([]byte, error) {
+	if err := s.Check(raw); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("objstore: reading %s: %w", Location(raw), scrub(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("objstore: reading %s: %s", Location(raw), resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("objstore: reading %s: %w", Location(raw), scrub(err))
+	}
+	if int64(len(body)) > max {
+		return nil, fmt.Errorf("objstore: %s is over %d bytes", Location(raw), max)
+	}
+	return body, nil
+}
+
+// Put writes body to the object at a signed URL. contentType must be the
+// one the URL was signed for, if it was signed for one.
+func (s *Store) Put(ctx context.Context, raw string, body []byte, contentType string) error {
+	if err := s.Check(raw); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, raw, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("objstore: writing %s: %w", Location(raw), scrub(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("objstore: writing %s: %s", Location(raw), resp.Status)
+	}
+	return nil
+}
+
+// Location is where a signed URL points, without the signature in its
+// query, so it can be shown and logged
+func Location(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(unparseable URL)"
+	}
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	return u.String()
+}
+
+// scrub drops the URL, signature and all, from the client's errors
+func scrub(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return ue.Err
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package objstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckTrustsOnlyObjectStorageOverHTTPS(t *testing.T) {
+	s := New(DefaultHosts, http.DefaultClient)
+	for raw, ok := range map[string]bool{
+		"https://my-bucket.s3.ap-south-1.amazonaws.com/in/plan.json?X-Amz-Signature=abc": true,
+		"https://storage.googleapis.com/my-bucket/in.json?X-Goog-Signature=abc":          true,
+		"https://s3.eu-west-1.amazonaws.com/my-bucket/in.json":                           true,
+		"https://my-bucket.s3.amazonaws.com/in.json":                                     false,
+		"https://attacker.elb.amazonaws.com/in.json":                                     false,
+		"https://b.storage.googleapis.com.evil.io/in.json":                               false,
+		"http://my-bucket.s3.amazonaws.com/in.json":                                      false,
+		"https://evilamazonaws.com/in.json":                                              false,
+		"https://169.254.169.254/latest/meta-data":                                       false,
+	} {
+		if err := s.Check(raw); (err == nil) != ok {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+	if got := Location("https://b.s3.amazonaws.com/k.json?X-Amz-Signature=secret"); got != "https://b.s3.amazonaws.com/k.json" {
+		t.Errorf("location = %s", got)
+	}
+}
+
+func TestGetAndPutThroughSignedURLs(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]string{"/in.json": `{"big":true}`}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "ok" {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, body)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		}
+	}))
+	defer srv.Close()
+	s := New([]string{"127.0.0.1"}, srv.Client())
+	ctx := context.Background()
+
+	body, err := s.Get(ctx, srv.URL+"/in.json?sig=ok", 1<<20)
+	if err != nil || string(body) != `{"big":true}` {
+		t.Fatalf("get: %s, %v", body, err)
+	}
+	if _, err := s.Get(ctx, srv.URL+"/in.json?sig=ok", 4); err == nil {
+		t.Error("object over the limit read")
+	}
+	_, err = s.Get(ctx, srv.URL+"/in.json?sig=forged", 1<<20)
+	if err == nil || strings.Contains(err.Error(), "forged") {
+		t.Errorf("bad signature: %v", err)
+	}
+	if err := s.Put(ctx, srv.URL+"/out.json?sig=ok", []byte(`{"done":true}`), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if objects["/out.json"] != `{"done":true}` {
+		t.Errorf("objects = %v", objects)
+	}
+}
+
+func TestRedirectsAndInternalAddressesAreRefused(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/in.json" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "secret")
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	s := New([]string{"127.0.0.1"}, srv.Client())
+	if body, err := s.Get(ctx, srv.URL+"/in.json", 1<<20); err == nil {
+		t.Errorf("redirect followed: %s", body)
+	}
+
+	public := *srv.Client()
+	public.Transport = PublicTransport()
+	s = New([]string{"127.0.0.1"}, &public)
+	if _, err := s.Get(ctx, srv.URL+"/other.json", 1<<20); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("loopback dialled: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+// Package pdf writes plain PDF reports: a title and tables of text set in
+// Courier on landscape A4 pages, for printing and mailing. It needs no fonts
+// of its own, since Courier is one of the standard fonts every reader has,
+// but is limited to the Latin-1 characters the standard fonts carry; others
+// print as '?'.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of a PDF document
+const ContentType = "application/pdf"
+
+// Document is a report: a title, then its tables in order
+type Document struct {
+	Title   string
+	Created time.Time
+	Tables  []Table
+}
+
+// Table is a heading over a header row and rows of text. Columns are as
+// wide as their widest cell, up to maxCell characters; a table that runs
+// over a page carries on on the next under its header row again.
+type Table struct {
+	Heading string
+	Header  []string
+	Rows    [][]string
+}
+
+// Page geometry, in points: landscape A4, 9pt Courier (5.4pt a character)
+// on 11pt lines
+const (
+	pageWidth, pageHeight = 842, 595
+	margin                = 40
+	fontSize, leading     = 9, 11
+	lineChars             = (pageWidth - 2*margin) * 10 / 54
+	pageLines             = (pageHeight - 2*margin - 30) / leading
+	maxCell               = 40
+)
+
+// line is a line of a page, in bold for headings and header rows
+type line struct {
+	text string
+	bold bool
+}
+
+// Write encodes d as a PDF file
+func (d Document) Write(w io.Writer) error {
+	pages := d.layout()
+	var buf bytes.Buffer
+	offsets := []int{0} // By object number; 0 is the free list head
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets)-1, body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are the catalog, page tree, fonts and info; pages follow
+	// as page and content pairs from 5
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	obj("<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >> " +
+		"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >> >>")
+	info := "<< /Producer (MilesConnect Optimization Service) /Title " + literal(d.Title)
+	if !d.Created.IsZero() {
+		info += " /CreationDate (D:" + d.Created.UTC().Format("20060102150405") + "Z)"
+	}
+	obj(info + " >>")
+	for i, page := range pages {
+		var c strings.Builder
+		fmt.Fprintf(&c, "BT /F2 12 Tf %d %d Td %s Tj ET\n", margin, pageHeight-margin, literal(d.Title))
+		fmt.Fprintf(&c, "BT /F1 8 Tf %d %d Td %s Tj ET\n", pageWidth-margin-90, margin/2, literal(fmt.Sprintf("Page %d of %d", i+1, len(pages))))
+		fmt.Fprintf(&c, "BT %d TL %d %d Td\n", leading, margin, pageHeight-margin-30)
+		for _, l := range page {
+			font := "/F1"
+			if l.bold {
+				font = "/F2"
+			}
+			fmt.Fprintf(&c, "%s %d Tf %s Tj T*\n", font, fontSize, literal(l.text))
+		}
+		c.WriteString("ET")
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font 3 0 R >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", c.Len(), c.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets))
+	for _, off := range offsets[1:] {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets), xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// layout sets the tables out as lines and breaks them into pages
+func (d Document) layout() [][]line {
+	var pages [][]line
+	var page []line
+	newPage := func() {
+		if len(page) > 0 {
+			pages = append(pages, page)
+		}
+		page = nil
+	}
+	for ti, t := range d.Tables {
+		widths := columnWidths(t)
+		header := row(t.Header, widths)
+		// Keep a heading with its header row and first row
+		if ti > 0 && len(page) > 0 {
+			if len(page)+4 > pageLines {
+				newPage()
+			} else {
+				page = append(page, line{})
+			}
+		}
+		if t.Heading != "" {
+			page = append(page, line{clip(t.Heading, lineChars), true})
+		}
+		if header != "" {
+			page = append(page, line{header, true})
+		}
+		for _, cells := range t.Rows {
+			if len(page) >= pageLines {
+				newPage()
+				if header != "" {
+					page = append(page, line{header, true})
+				}
+			}
+			page = append(page, line{row(cells, widths), false})
+		}
+	}
+	newPage()
+	if len(pages) == 0 {
+		pages = [][]line{nil}
+	}
+	return pages
+}
+
+// columnWidths fits each column to its widest cell
+func columnWidths(t Table) []int {
+	var widths []int
+	for _, cells := range append([][]string{t.Header}, t.Rows...) {
+		for i, cell := range cells {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], min(utf8.RuneCountInString(cell), maxCell))
+		}
+	}
+	return widths
+}
+
+// row sets cells out in columns two spaces apart, clipped to the page
+func row(cells []string, widths []int) string {
+	var b strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		cell = clip(cell, widths[i])
+		b.WriteString(cell)
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+	}
+	return clip(strings.TrimRight(b.String(), " "), lineChars)
+}
+
+// clip cuts s to n characters, marking the cut with '~'
+func clip(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "~"
+	}
+	return s
+}
+
+// literal encodes s as a PDF string in WinAnsi, the standard fonts'
+// encoding
+func literal(s string) string {
+	b := []byte{'('}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r >= ' ' && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return string(append(b, ')'))
+}
@@ -0,0 +1,64 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDocument(t *testing.T) {
+	runs := Table{Heading: "V1", Header: []string{"#", "Stop", "Customer"}}
+	for i := range 100 {
+		runs.Rows = append(runs.Rows, []string{strconv.Itoa(i + 1), fmt.Sprintf("S%03d", i), "Café (Pune) \\ 東京"})
+	}
+	doc := Document{
+		Title:   "Dispatch plan for 2026-10-15",
+		Created: time.Date(2026, 10, 15, 6, 0, 0, 0, time.UTC),
+		Tables: []Table{
+			{Heading: "Vehicles", Header: []string{"Vehicle", "Stops"}, Rows: [][]string{{"V1", "100"}, {"a vehicle with a name far longer than any column should be", "0"}}},
+			runs,
+		},
+	}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("not a PDF:\n%s", out)
+	}
+
+	// Every xref entry points at its object
+	xref, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)[1])
+	if err != nil || !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	for i, m := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xref:], -1) {
+		off, _ := strconv.Atoi(m[1])
+		if want := fmt.Sprintf("%d 0 obj", i+1); !strings.HasPrefix(out[off:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+
+	if !strings.Contains(out, "/Count 3 ") {
+		t.Error("103 lines of tables should take 3 pages")
+	}
+	for _, want := range []string{
+		"(Page 3 of 3)",
+		`(100  S099  Caf` + "\xe9" + ` \(Pune\) \\ ??) Tj`,
+		"(a vehicle with a name far longer than a~  0)",
+		"/CreationDate (D:20261015060000Z)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+	// The run's header row starts each page it carries on to
+	if n := strings.Count(out, "/F2 9 Tf (#    Stop  Customer)"); n != 3 {
+		t.Errorf("run header printed %d times", n)
+	}
+}
@@ -0,0 +1,166 @@
+// Package pincode resolves Indian PIN codes to centroid coordinates so
+// addresses given only by pincode can be optimized without a geocoder.
+package pincode
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"milesconnect-optimization/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Entry is one known pincode centroid
+type Entry struct {
+	Pincode string  `json:"pincode"`
+	Name    string  `json:"name,omitempty"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+}
+
+// Result is a resolved pincode. Approximate is set when the exact code is
+// unknown and the centroid of codes sharing its prefix was used instead.
+type Result struct {
+	Entry
+	Approximate bool `json:"approximate"`
+}
+
+// ErrUnknown is returned when neither the code nor its district prefix is known
+var ErrUnknown = errors.New("unknown pincode")
+
+// minPrefix is the sorting-district part of a PIN; shorter matches are too
+// coarse to be useful
+const minPrefix = 3
+
+// Resolver looks up pincodes. The zero value is empty; use Load to fill it.
+type Resolver struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewResolver builds a resolver from CSV data (see Load)
+func NewResolver(r io.Reader) (*Resolver, error) {
+	res := &Resolver{}
+	if err := res.Load(r); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Load adds entries from CSV with pincode, lat and lng columns and an
+// optional name column. Later entries override earlier ones.
+func (res *Resolver) Load(r io.Reader) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	pinCol, ok1 := col["pincode"]
+	latCol, ok2 := col["lat"]
+	lngCol, ok3 := col["lng"]
+	if !ok1 || !ok2 || !ok3 {
+		return errors.New("pincode CSV header must contain pincode, lat and lng")
+	}
+	nameCol, hasName := col["name"]
+
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if res.entries == nil {
+		res.entries = map[string]Entry{}
+	}
+
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		pin := strings.TrimSpace(rec[pinCol])
+		lat, err1 := strconv.ParseFloat(rec[latCol], 64)
+		lng, err2 := strconv.ParseFloat(rec[lngCol], 64)
+		if !valid(pin) || err1 != nil || err2 != nil {
+			return fmt.Errorf("line %d: invalid pincode entry", line)
+		}
+
+		e := Entry{Pincode: pin, Lat: lat, Lng: lng}
+		if hasName {
+			e.Name = rec[nameCol]
+		}
+		res.entries[pin] = e
+	}
+}
+
+// Resolve returns the centroid for pin, falling back to the mean of all known
+// codes with the longest shared prefix (at least the 3-digit district)
+func (res *Resolver) Resolve(pin string) (Result, error) {
+	pin = strings.TrimSpace(pin)
+	if !valid(pin) {
+		return Result{}, fmt.Errorf("%w: %q is not a 6-digit PIN", ErrUnknown, pin)
+	}
+
+	res.mu.RLock()
+	defer res.mu.RUnlock()
+
+	if e, ok := res.entries[pin]; ok {
+		return Result{Entry: e}, nil
+	}
+
+	for n := len(pin) - 1; n >= minPrefix; n-- {
+		prefix := pin[:n]
+		var lat, lng float64
+		count := 0
+		for code, e := range res.entries {
+			if strings.HasPrefix(code, prefix) {
+				lat += e.Lat
+				lng += e.Lng
+				count++
+			}
+		}
+		if count > 0 {
+			return Result{
+				Entry:       Entry{Pincode: pin, Lat: lat / float64(count), Lng: lng / float64(count)},
+				Approximate: true,
+			}, nil
+		}
+	}
+	return Result{}, fmt.Errorf("%w: %s", ErrUnknown, pin)
+}
+
+// Locate resolves pin straight to a Location
+func (res *Resolver) Locate(pin string) (models.Location, error) {
+	r, err := res.Resolve(pin)
+	if err != nil {
+		return models.Location{}, err
+	}
+	return models.Location{Lat: r.Lat, Lng: r.Lng, Pincode: pin}, nil
+}
+
+// Len reports how many exact pincodes are known
+func (res *Resolver) Len() int {
+	res.mu.RLock()
+	defer res.mu.RUnlock()
+	return len(res.entries)
+}
+
+// valid checks the 6-digit format; Indian PINs never start with 0
+func valid(pin string) bool {
+	if len(pin) != 6 || pin[0] == '0' {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
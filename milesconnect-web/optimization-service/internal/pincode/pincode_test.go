@@ -0,0 +1,55 @@
+package pincode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const sample = `pincode,name,lat,lng
+560001,Bengaluru (MG Road),12.9757,77.6011
+560066,Bengaluru (Whitefield),12.9698,77.7500
+110001,New Delhi,28.6315,77.2167
+`
+
+func newSample(t *testing.T) *Resolver {
+	t.Helper()
+	r, err := NewResolver(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestResolveExact(t *testing.T) {
+	got, err := newSample(t).Resolve("110001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Approximate || got.Lat != 28.6315 || got.Name != "New Delhi" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestResolveFallsBackToPrefix(t *testing.T) {
+	got, err := newSample(t).Resolve("560034")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Approximate {
+		t.Error("expected an approximate match")
+	}
+	// Mean of the two 5600xx entries
+	if want := (12.9757 + 12.9698) / 2; got.Lat != want {
+		t.Errorf("lat = %v, want %v", got.Lat, want)
+	}
+}
+
+func TestResolveUnknown(t *testing.T) {
+	r := newSample(t)
+	for _, pin := range []string{"999999", "12345", "0110001", "abcdef", "012345"} {
+		if _, err := r.Resolve(pin); !errors.Is(err, ErrUnknown) {
+			t.Errorf("Resolve(%q) err = %v, want ErrUnknown", pin, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package predict
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"time"
+)
+
+// Kinds of instance a target is benchmarked on
+const (
+	KindRoute = "route" // One vehicle through every waypoint
+	KindFleet = "fleet" // Capacitated vehicles from a depot
+	KindLoad  = "load"  // Shipments onto vehicles
+)
+
+// Target is a solver and parameter set to calibrate, on instances of Kind
+type Target struct {
+	Solver string
+	Params map[string]float64
+	Kind   string
+}
+
+// DefaultSizes are the instance sizes, in stops, targets are run at
+var DefaultSizes = []int{10, 25, 50, 100}
+
+// Calibrate runs each target on generated instances of each size and fits
+// its runtime. Targets whose solver is not registered are skipped, as are
+// sizes a solver refuses as too large.
+func Calibrate(ctx context.Context, targets []Target, sizes []int) (Model, error) {
+	m := Model{CalibratedAt: time.Now().UTC(), Entries: []Entry{}}
+	for _, t := range targets {
+		s, ok := solver.Get(t.Solver)
+		if !ok {
+			continue
+		}
+		var samples []Sample
+		for i, size := range sizes {
+			p, err := instance(t.Kind, size, int64(i+1))
+			if err != nil {
+				return Model{}, err
+			}
+			p.SolverParams = t.Params
+			start := time.Now()
+			_, err = s.Solve(ctx, p)
+			if errors.Is(err, solver.ErrProblemTooLarge) {
+				continue
+			}
+			if err != nil {
+				return Model{}, fmt.Errorf("%s on %d stops: %w", Key(t.Solver, t.Params), size, err)
+			}
+			samples = append(samples, Sample{Stops: len(p.Stops()), Seconds: time.Since(start).Seconds()})
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		a, b := Fit(samples)
+		m.Entries = append(m.Entries, Entry{Solver: t.Solver, Params: t.Params, A: a, B: b, Samples: len(samples)})
+	}
+	return m, nil
+}
+
+func instance(kind string, size int, seed int64) (*problem.Problem, error) {
+	cfg := generator.Config{Size: size, Distribution: generator.Clustered, Seed: seed}
+	switch kind {
+	case KindRoute:
+		req, err := generator.RouteRequest(cfg)
+		return problem.FromRouteRequest(req), err
+	case KindFleet:
+		req, err := generator.FleetRequest(cfg)
+		return problem.FromFleetRequest(req), err
+	case KindLoad:
+		return problem.FromLoadRequest(generator.LoadRequest(cfg)), nil
+	}
+	return nil, fmt.Errorf("unknown instance kind %q", kind)
+}
@@ -0,0 +1,140 @@
+// Package predict estimates how long a solver takes on an instance, from a
+// power law in the number of stops fitted to benchmark runs: seconds =
+// A * stops^B for each solver and parameter set. Solvers with a time limit
+// never take longer than it.
+package predict
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Entry is the fitted runtime of one solver under one parameter set
+type Entry struct {
+	Solver  string             `json:"solver"`
+	Params  map[string]float64 `json:"params,omitempty"`
+	A       float64            `json:"a"`
+	B       float64            `json:"b"`
+	Samples int                `json:"samples"`
+}
+
+// Model is a set of fitted entries
+type Model struct {
+	CalibratedAt time.Time `json:"calibrated_at"`
+	Entries      []Entry   `json:"entries"`
+}
+
+//go:embed model.json
+var defaultModel []byte
+
+// Default returns the model calibrated from the benchmark suite on a
+// reference machine; see cmd/calibrate
+func Default() Model {
+	var m Model
+	if err := json.Unmarshal(defaultModel, &m); err != nil {
+		panic(fmt.Sprintf("predict: embedded model: %v", err))
+	}
+	return m
+}
+
+// Load reads a model saved by Save
+func Load(path string) (Model, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+	var m Model
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Model{}, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return m, nil
+}
+
+// Save writes m to path
+func (m Model) Save(path string) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(body, '\n'), 0o644)
+}
+
+// Predict estimates how long s takes on p under params. It uses the entry
+// fitted for those very parameters, else the solver's default one; ok is
+// false when s was never calibrated and has no time limit.
+func (m Model) Predict(s solver.Solver, params map[string]float64, p *problem.Problem) (time.Duration, bool) {
+	e, found := m.entry(s.Name(), params)
+	var d time.Duration
+	if found {
+		stops := math.Max(1, float64(len(p.Stops())))
+		d = time.Duration(e.A * math.Pow(stops, e.B) * float64(time.Second))
+	}
+	if b, ok := s.(solver.TimeBounded); ok {
+		q := *p
+		q.SolverParams = params
+		limit := b.TimeLimit(&q)
+		if !found || d > limit {
+			return limit, true
+		}
+	}
+	return d, found
+}
+
+func (m Model) entry(name string, params map[string]float64) (Entry, bool) {
+	key := Key(name, params)
+	var fallback Entry
+	found := false
+	for _, e := range m.Entries {
+		switch {
+		case Key(e.Solver, e.Params) == key:
+			return e, true
+		case e.Solver == name && len(e.Params) == 0:
+			fallback, found = e, true
+		}
+	}
+	return fallback, found
+}
+
+// Key identifies a solver under a parameter set
+func Key(name string, params map[string]float64) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		fmt.Fprintf(&b, " %s=%g", k, params[k])
+	}
+	return b.String()
+}
+
+// Fit fits seconds = A * stops^B to samples by least squares on their
+// logarithms. One sample, or samples all of one size, fit a constant.
+func Fit(samples []Sample) (a, b float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	var sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x := math.Log(math.Max(1, float64(s.Stops)))
+		y := math.Log(math.Max(1e-6, s.Seconds))
+		sx, sy, sxx, sxy = sx+x, sy+y, sxx+x*x, sxy+x*y
+	}
+	n := float64(len(samples))
+	if d := n*sxx - sx*sx; d > 1e-12 {
+		b = (n*sxy - sx*sy) / d
+	}
+	return math.Exp((sy - b*sx) / n), b
+}
+
+// Sample is one benchmark run
+type Sample struct {
+	Stops   int
+	Seconds float64
+}
@@ -0,0 +1,61 @@
+package predict
+
+import (
+	"math"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"testing"
+	"time"
+)
+
+func TestFitRecoversPowerLaw(t *testing.T) {
+	var samples []Sample
+	for _, n := range []int{10, 20, 40, 80} {
+		samples = append(samples, Sample{Stops: n, Seconds: 0.002 * math.Pow(float64(n), 1.5)})
+	}
+	if a, b := Fit(samples); math.Abs(a-0.002) > 1e-9 || math.Abs(b-1.5) > 1e-9 {
+		t.Errorf("fit a=%g b=%g, want 0.002 and 1.5", a, b)
+	}
+	if a, b := Fit(samples[:1]); b != 0 || math.Abs(a-samples[0].Seconds) > 1e-9 {
+		t.Errorf("one sample fit a=%g b=%g", a, b)
+	}
+}
+
+func TestPredictUsesParamsAndTimeLimit(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 100, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromFleetRequest(req)
+	alns, _ := solver.Get("alns")
+	fast := map[string]float64{"time_limit_ms": 1000}
+	m := Model{Entries: []Entry{
+		{Solver: "alns", A: 0.001, B: 1},
+		{Solver: "alns", Params: fast, A: 0.1, B: 1},
+	}}
+
+	if d, ok := m.Predict(alns, nil, p); !ok || d != 100*time.Millisecond {
+		t.Errorf("defaults: %v, %v", d, ok)
+	}
+	// 10s fitted, but the solver stops at its 1s limit
+	if d, ok := m.Predict(alns, fast, p); !ok || d != time.Second {
+		t.Errorf("fast: %v, %v", d, ok)
+	}
+	// Uncalibrated parameters fall back to the defaults' entry
+	if d, _ := m.Predict(alns, map[string]float64{"seed": 1}, p); d != 100*time.Millisecond {
+		t.Errorf("other params: %v", d)
+	}
+	// Uncalibrated solvers are predicted from their time limit, else not at all
+	gls, _ := solver.Get("guided-local-search")
+	if d, ok := (Model{}).Predict(gls, nil, p); !ok || d != solver.GLSTimeLimit {
+		t.Errorf("uncalibrated time-bounded solver: %v, %v", d, ok)
+	}
+	twoOpt, _ := solver.Get("two-opt")
+	if _, ok := (Model{}).Predict(twoOpt, nil, p); ok {
+		t.Error("predicted an uncalibrated solver without a time limit")
+	}
+	if len(Default().Entries) == 0 {
+		t.Error("the built-in model is empty")
+	}
+}
@@ -0,0 +1,35 @@
+package problem
+
+// Crew limits how long a vehicle drives each day. The day's driving window
+// opens when the vehicle departs and lasts DrivingHours; the vehicle then
+// stands until the next day's opens.
+type Crew struct {
+	Name         string
+	DrivingHours float64
+}
+
+// A single driver may drive 8 hours a day (Motor Transport Workers Act);
+// with two drivers relaying, the truck keeps going for both their shifts
+var (
+	SingleCrew = Crew{Name: "single", DrivingHours: 8}
+	RelayCrew  = Crew{Name: "relay", DrivingHours: 16}
+)
+
+// crewDays is how many days of rests a crew's curfew covers, enough for
+// the longest all-India haul
+const crewDays = 14
+
+// Rest names the crew's rests among a schedule's holds
+func (c Crew) Rest() string {
+	return c.Name + "-crew rest"
+}
+
+// Rests returns the crew's daily rests for v as a driving ban
+func (c Crew) Rests(v Vehicle) Curfew {
+	rest := Curfew{Name: c.Rest(), Vehicles: map[string]bool{v.ID: true}}
+	for day := range crewDays {
+		start := v.DepartHours + float64(day)*24
+		rest.Windows = append(rest.Windows, Window{From: start + c.DrivingHours, To: start + 24})
+	}
+	return rest
+}
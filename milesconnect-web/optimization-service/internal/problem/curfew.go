@@ -0,0 +1,117 @@
+package problem
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Curfew keeps vehicles out of an area, or off the road altogether, during
+// its windows
+type Curfew struct {
+	Name     string
+	Vehicles map[string]bool // Vehicle IDs it applies to; nil means all
+	Nodes    []bool          // Nodes inside the area; nil makes it a driving ban
+	Windows  []Window
+}
+
+// Window is a stretch of time in hours from the plan start
+type Window struct {
+	From, To float64
+}
+
+// Hold is time a vehicle loses to a curfew on the way to a node
+type Hold struct {
+	Node   int
+	Curfew string
+	Hours  float64
+}
+
+func (c Curfew) appliesTo(v Vehicle) bool {
+	return c.Vehicles == nil || c.Vehicles[v.ID]
+}
+
+// Arrival returns when v, leaving node i at depart, can start serving node
+// j: after the drive and any driving ban it sits out on the way, no earlier
+// than j's ready time, and once no curfew keeps v out of j's area
+func (p *Problem) Arrival(v Vehicle, i, j int, depart float64) float64 {
+	t, _ := p.arrival(v, i, j, depart)
+	return t
+}
+
+func (p *Problem) arrival(v Vehicle, i, j int, depart float64) (float64, []Hold) {
+	drive := p.VehicleTravelHours(v, i, j)
+	if len(p.Curfews) == 0 {
+		return math.Max(depart+drive, p.Nodes[j].ReadyHours), nil
+	}
+
+	var holds []Hold
+	hold := func(name string, hours float64) {
+		if hours > 0 {
+			holds = append(holds, Hold{Node: j, Curfew: name, Hours: hours})
+		}
+	}
+
+	// Driving bans pause the drive, which resumes when they lift
+	type ban struct {
+		Window
+		name string
+	}
+	var bans []ban
+	for _, c := range p.Curfews {
+		if c.Nodes == nil && c.appliesTo(v) {
+			for _, w := range c.Windows {
+				bans = append(bans, ban{w, c.Name})
+			}
+		}
+	}
+	slices.SortFunc(bans, func(a, b ban) int { return cmp.Compare(a.From, b.From) })
+	now, remaining := depart, drive
+	for _, b := range bans {
+		if remaining <= 0 || now+remaining <= b.From {
+			break
+		}
+		if b.To <= now {
+			continue
+		}
+		start := math.Max(now, b.From)
+		remaining -= start - now
+		hold(b.name, b.To-start)
+		now = b.To
+	}
+	t := math.Max(now+remaining, p.Nodes[j].ReadyHours)
+
+	// Area curfews hold the vehicle outside until they lift; one lifting
+	// can land it in another, so repeat until none applies
+	for moved := true; moved; {
+		moved = false
+		for _, c := range p.Curfews {
+			if c.Nodes == nil || !c.Nodes[j] || !c.appliesTo(v) {
+				continue
+			}
+			for _, w := range c.Windows {
+				if t >= w.From && t < w.To {
+					hold(c.Name, w.To-t)
+					t, moved = w.To, true
+				}
+			}
+		}
+	}
+	return t, holds
+}
+
+// Holds returns the time v loses to curfews serving stops in order
+func (p *Problem) Holds(v Vehicle, stops []int) []Hold {
+	var holds []Hold
+	t := v.DepartHours
+	for k, n := range stops {
+		if k == 0 {
+			t = math.Max(t, p.Nodes[n].ReadyHours)
+			continue
+		}
+		var h []Hold
+		t, h = p.arrival(v, stops[k-1], n, t+p.Nodes[stops[k-1]].ServiceHours)
+		holds = append(holds, h...)
+	}
+	return holds
+}
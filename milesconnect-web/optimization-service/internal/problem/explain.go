@@ -0,0 +1,96 @@
+package problem
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"slices"
+)
+
+// maxAlternatives bounds the rejected vehicles listed per shipment
+const maxAlternatives = 3
+
+// allocationReasons explains why each shipment on route r is there rather
+// than on another vehicle. It judges against the final plan, moving one
+// shipment at a time, whatever order the solver placed them in.
+func (s Solution) allocationReasons(p *Problem, r Route) []models.AllocationReason {
+	free := make([]float64, len(p.Vehicles))
+	for i, v := range p.Vehicles {
+		free[i] = v.CapacityKg - v.InitialLoadKg
+	}
+	for _, rt := range s.Routes {
+		free[rt.Vehicle] -= rt.LoadKg - p.Vehicles[rt.Vehicle].InitialLoadKg
+	}
+
+	v := p.Vehicles[r.Vehicle]
+	reasons := make([]models.AllocationReason, 0, len(r.Stops))
+	for _, n := range r.Stops {
+		node := p.Nodes[n]
+		late := lateness(node, v)
+		var alts []models.RejectedVehicle
+		fits, unexplained, looser := 0, false, false
+		for i, u := range p.Vehicles {
+			if i == r.Vehicle {
+				continue
+			}
+			left := free[i] - node.DemandKg
+			if left < 0 {
+				alts = append(alts, models.RejectedVehicle{VehicleID: u.ID, Reason: "capacity", OverKg: round2(-left)})
+				continue
+			}
+			fits++
+			switch lu := lateness(node, u); {
+			case lu > late:
+				alts = append(alts, models.RejectedVehicle{VehicleID: u.ID, Reason: "late", LatePenalty: round2(lu - late)})
+			case lu == late && left >= free[r.Vehicle]:
+				looser = true
+				alts = append(alts, models.RejectedVehicle{VehicleID: u.ID, Reason: "looser_fit", FreeKg: round2(left)})
+			default:
+				unexplained = true
+			}
+		}
+
+		reason := models.AllocationReason{ShipmentID: node.ID, FreeKg: round2(free[r.Vehicle])}
+		switch {
+		case fits == 0:
+			reason.Reason = "only_fit"
+			reason.Detail = fmt.Sprintf("only vehicle with room for its %g kg", node.DemandKg)
+		case unexplained:
+			reason.Reason = "solver_choice"
+			reason.Detail = "another vehicle would take it as well; chosen for the plan as a whole"
+		case looser:
+			reason.Reason = "tightest_fit"
+			reason.Detail = fmt.Sprintf("tightest remaining capacity: %g kg left with it aboard", reason.FreeKg)
+		default:
+			reason.Reason = "on_time"
+			reason.Detail = "every other vehicle with room would carry it later"
+		}
+		slices.SortStableFunc(alts, closest)
+		reason.Alternatives = alts[:min(len(alts), maxAlternatives)]
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
+
+// closest orders rejected vehicles by how near they came to taking the
+// shipment: looser fits, then later ones, then those without room
+func closest(a, b models.RejectedVehicle) int {
+	rank := map[string]int{"looser_fit": 0, "late": 1, "capacity": 2}
+	if c := cmp.Compare(rank[a.Reason], rank[b.Reason]); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.FreeKg+a.LatePenalty+a.OverKg, b.FreeKg+b.LatePenalty+b.OverKg)
+}
+
+// lateness is the late penalty of carrying node on v
+func lateness(node Node, v Vehicle) float64 {
+	if node.LatePenaltyPerHour <= 0 || v.DepartHours <= node.DeadlineHours {
+		return 0
+	}
+	return (v.DepartHours - node.DeadlineHours) * node.LatePenaltyPerHour
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
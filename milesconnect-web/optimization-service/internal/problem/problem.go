@@ -0,0 +1,265 @@
+package problem
+
+import (
+	"math"
+	"milesconnect-optimization/internal/models"
+	"slices"
+)
+
+// Type tells solvers which objective a Problem carries
+type Type int
+
+const (
+	TypeRouting    Type = iota // Order the nodes into a route per vehicle
+	TypeAllocation             // Assign node demands to vehicles
+)
+
+// Node is a stop or shipment. Routing problems use Location; allocation
+// problems use DemandKg and the penalty fields.
+type Node struct {
+	ID       string
+	Location models.Location
+	DemandKg float64
+	ReturnKg float64 // Picked up here and carried back to the vehicle's end
+
+	DeadlineHours      float64
+	LatePenaltyPerHour float64
+	DropPenalty        float64
+
+	// Hard time window in hours from now; DueHours 0 means no due time
+	ReadyHours   float64
+	DueHours     float64
+	ServiceHours float64
+
+	// VehicleTypes are the only vehicle types that may serve the node; any
+	// may when empty
+	VehicleTypes []string
+}
+
+// Vehicle is a resource that serves nodes. Start and End are node indices,
+// or -1 when the vehicle has no fixed endpoint.
+type Vehicle struct {
+	ID            string
+	CapacityKg    float64 // 0 means unlimited
+	InitialLoadKg float64
+	DepartHours   float64
+	Start         int
+	End           int
+
+	// SpeedFactor scales the problem's speed for this vehicle, e.g. 0.8 for
+	// a 32 ft truck; 0 means 1
+	SpeedFactor float64
+
+	Type string // Body, e.g. mini-truck, for nodes that allow only some
+}
+
+// Constraints records which constraint families the request declared
+type Constraints struct {
+	Capacity      bool
+	Deadlines     bool
+	TimeWindows   bool
+	Compatibility bool // Some nodes allow only some vehicle types
+}
+
+// Problem is the normalized representation every solver works from
+type Problem struct {
+	Type        Type
+	Nodes       []Node
+	Vehicles    []Vehicle
+	Constraints Constraints
+
+	// Matrix optionally overrides great-circle distances (km), indexed by node
+	Matrix [][]float64
+
+	// SpeedKmph converts distance to travel time for time windows; 0 means
+	// DefaultSpeedKmph
+	SpeedKmph float64
+
+	// BorderDelays adds checkpoint time to legs between nodes in different
+	// states
+	BorderDelays BorderDelays
+
+	// Curfews hold vehicles out of areas, or off the road, at set times
+	Curfews []Curfew
+
+	// Tolls optionally prices each leg in INR, indexed by node. Solvers then
+	// minimise Cost, which adds the toll converted to km at CostPerKm.
+	Tolls     [][]float64
+	CostPerKm float64
+
+	// Batch means the caller accepts a slower solve for a better result
+	Batch bool
+
+	// Seeds are good plans for similar earlier problems, each holding every
+	// vehicle's inner stops (endpoints excluded) by vehicle index. Solvers
+	// that take them start from whichever fits this problem best.
+	Seeds [][][]int
+
+	// SolverParams tunes the solver (e.g. from a tuning profile); each solver
+	// reads the keys it knows and ignores the rest
+	SolverParams map[string]float64
+}
+
+// DefaultSpeedKmph is the average road speed assumed for scheduling
+const DefaultSpeedKmph = 50
+
+// BorderDelays is the time in hours trucks lose at the checkpoints between
+// two states, keyed by the states' codes joined in either order (e.g.
+// "KA-TN"). The "*" entry applies to borders without their own.
+type BorderDelays map[string]float64
+
+// Hours returns the delay on a leg from state a to state b. Legs within a
+// state or from an unknown one have none; states passed through on the way
+// are not counted.
+func (d BorderDelays) Hours(a, b string) float64 {
+	if a == "" || b == "" || a == b {
+		return 0
+	}
+	if h, ok := d[a+"-"+b]; ok {
+		return h
+	}
+	if h, ok := d[b+"-"+a]; ok {
+		return h
+	}
+	return d["*"]
+}
+
+// TravelHours returns the driving time between two nodes, including any
+// wait at a state border
+func (p *Problem) TravelHours(i, j int) float64 {
+	speed := p.SpeedKmph
+	if speed <= 0 {
+		speed = DefaultSpeedKmph
+	}
+	return p.Distance(i, j)/speed + p.BorderDelay(i, j)
+}
+
+// VehicleTravelHours is TravelHours for v, driving at its speed factor
+func (p *Problem) VehicleTravelHours(v Vehicle, i, j int) float64 {
+	if v.SpeedFactor <= 0 || v.SpeedFactor == 1 {
+		return p.TravelHours(i, j)
+	}
+	speed := p.SpeedKmph
+	if speed <= 0 {
+		speed = DefaultSpeedKmph
+	}
+	return p.Distance(i, j)/(speed*v.SpeedFactor) + p.BorderDelay(i, j)
+}
+
+// BorderDelay returns the checkpoint time on the leg between two nodes
+func (p *Problem) BorderDelay(i, j int) float64 {
+	return p.BorderDelays.Hours(p.Nodes[i].Location.State, p.Nodes[j].Location.State)
+}
+
+// Schedule returns the service start time (hours from now) at each of stops
+// when v departs its first stop at DepartHours, waiting at nodes that are not
+// ready yet or under curfew and spending each node's service time
+func (p *Problem) Schedule(v Vehicle, stops []int) []float64 {
+	times := make([]float64, len(stops))
+	t := v.DepartHours
+	for k, n := range stops {
+		if k > 0 {
+			t = p.Arrival(v, stops[k-1], n, t+p.Nodes[stops[k-1]].ServiceHours)
+		} else {
+			t = math.Max(t, p.Nodes[n].ReadyHours)
+		}
+		times[k] = t
+	}
+	return times
+}
+
+// Distance returns the km distance between two nodes
+func (p *Problem) Distance(i, j int) float64 {
+	if p.Matrix != nil {
+		return p.Matrix[i][j]
+	}
+	return Haversine(p.Nodes[i].Location, p.Nodes[j].Location)
+}
+
+// Route is one vehicle's share of a solution. Stops are node indices; for
+// routing problems they include the vehicle's start and end nodes.
+type Route struct {
+	Vehicle     int
+	Stops       []int
+	DistanceKm  float64
+	LoadKg      float64
+	LatePenalty float64
+}
+
+// Solution is the normalized output of a solver
+type Solution struct {
+	Routes      []Route
+	Unassigned  []int // Node indices that could not be served
+	Dropped     []int // Subset of Unassigned dropped on penalty grounds
+	DistanceKm  float64
+	PenaltyCost float64
+
+	// Solver and Reason are set by delegating solvers (e.g. auto) to record
+	// which algorithm actually ran and why
+	Solver string
+	Reason string
+}
+
+// Cost returns what solvers minimise on the leg between two nodes: its
+// distance, plus its toll as the km that money would run when tolls are
+// priced
+func (p *Problem) Cost(i, j int) float64 {
+	if p.Tolls == nil || p.CostPerKm <= 0 {
+		return p.Distance(i, j)
+	}
+	return p.Distance(i, j) + p.Tolls[i][j]/p.CostPerKm
+}
+
+// Haversine calculates distance between two points in km
+func Haversine(p1, p2 models.Location) float64 {
+	const R = 6371 // Earth radius in km
+	dLat := (p2.Lat - p1.Lat) * (math.Pi / 180.0)
+	dLon := (p2.Lng - p1.Lng) * (math.Pi / 180.0)
+
+	lat1 := p1.Lat * (math.Pi / 180.0)
+	lat2 := p2.Lat * (math.Pi / 180.0)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Sin(dLon/2)*math.Sin(dLon/2)*math.Cos(lat1)*math.Cos(lat2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return R * c
+}
+
+// PeakLoad returns the most v carries serving inner in order. It leaves with
+// every delivery on board; each stop unloads its delivery and then loads
+// its return, so room freed early in the route carries returns later.
+func (p *Problem) PeakLoad(v Vehicle, inner []int) float64 {
+	load := v.InitialLoadKg
+	for _, n := range inner {
+		load += p.Nodes[n].DemandKg
+	}
+	peak := load
+	for _, n := range inner {
+		load += p.Nodes[n].ReturnKg - p.Nodes[n].DemandKg
+		peak = math.Max(peak, load)
+	}
+	return peak
+}
+
+// Compatible reports whether v may serve node n: always, unless the node
+// allows only some vehicle types and v's is not among them
+func (p *Problem) Compatible(v Vehicle, n int) bool {
+	types := p.Nodes[n].VehicleTypes
+	return len(types) == 0 || slices.Contains(types, v.Type)
+}
+
+// Stops returns the nodes that are no vehicle's start or end, in order
+func (p *Problem) Stops() []int {
+	ends := map[int]bool{}
+	for _, v := range p.Vehicles {
+		ends[v.Start], ends[v.End] = true, true
+	}
+	var stops []int
+	for i := range p.Nodes {
+		if !ends[i] {
+			stops = append(stops, i)
+		}
+	}
+	return stops
+}
@@ -0,0 +1,255 @@
+package problem
+
+import (
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/models"
+)
+
+// FromRouteRequest builds a single-vehicle routing problem. Node 0 is the
+// start, the waypoints follow in order, and the last node is the end.
+func FromRouteRequest(req models.OptimizationRequest) *Problem {
+	nodes := make([]Node, 0, len(req.Waypoints)+2)
+	nodes = append(nodes, Node{ID: "start", Location: req.Start})
+	for i, wp := range req.Waypoints {
+		nodes = append(nodes, Node{ID: fmt.Sprintf("waypoint-%d", i), Location: wp})
+	}
+	nodes = append(nodes, Node{ID: "end", Location: req.End})
+
+	return &Problem{
+		Type:     TypeRouting,
+		Nodes:    nodes,
+		Vehicles: []Vehicle{{ID: "vehicle", Start: 0, End: len(nodes) - 1}},
+		Matrix:   req.DistanceMatrix,
+	}
+}
+
+// FromLoadRequest builds an allocation problem with one node per shipment
+func FromLoadRequest(req models.LoadRequest) *Problem {
+	p := &Problem{
+		Type:        TypeAllocation,
+		Nodes:       make([]Node, len(req.Shipments)),
+		Vehicles:    make([]Vehicle, len(req.Vehicles)),
+		Constraints: Constraints{Capacity: true},
+	}
+
+	for i, s := range req.Shipments {
+		p.Nodes[i] = Node{
+			ID:                 s.ID,
+			DemandKg:           s.WeightKg,
+			DeadlineHours:      s.DeadlineHours,
+			LatePenaltyPerHour: s.LatePenaltyPerHour,
+			DropPenalty:        s.DropPenalty,
+			VehicleTypes:       s.VehicleTypes,
+		}
+		if s.LatePenaltyPerHour > 0 {
+			p.Constraints.Deadlines = true
+		}
+		if len(s.VehicleTypes) > 0 {
+			p.Constraints.Compatibility = true
+		}
+	}
+	for i, v := range req.Vehicles {
+		p.Vehicles[i] = Vehicle{
+			ID:            v.ID,
+			CapacityKg:    v.CapacityKg,
+			InitialLoadKg: v.CurrentLoad,
+			DepartHours:   v.DepartHours,
+			Start:         -1,
+			End:           -1,
+			Type:          v.Type,
+		}
+	}
+	return p
+}
+
+// FromFleetRequest builds a multi-vehicle routing problem. Node 0 is the
+// depot, where every vehicle starts and ends; the stops follow in order.
+func FromFleetRequest(req models.FleetRequest) *Problem {
+	p := &Problem{
+		Type:        TypeRouting,
+		Nodes:       make([]Node, 0, len(req.Stops)+1),
+		Vehicles:    make([]Vehicle, len(req.Vehicles)),
+		Constraints: Constraints{Capacity: true},
+		Matrix:      req.DistanceMatrix,
+		SpeedKmph:   req.SpeedKmph,
+	}
+
+	p.Nodes = append(p.Nodes, Node{ID: "depot", Location: req.Depot})
+	for i, s := range req.Stops {
+		id := s.ID
+		if id == "" {
+			id = fmt.Sprintf("stop-%d", i)
+		}
+		p.Nodes = append(p.Nodes, Node{
+			ID:           id,
+			Location:     s.Location,
+			DemandKg:     s.DemandKg,
+			ReturnKg:     s.ReturnKg,
+			ReadyHours:   s.ReadyHours,
+			DueHours:     s.DueHours,
+			ServiceHours: s.ServiceHours,
+			VehicleTypes: s.VehicleTypes,
+		})
+		if s.ReadyHours > 0 || s.DueHours > 0 {
+			p.Constraints.TimeWindows = true
+		}
+		if len(s.VehicleTypes) > 0 {
+			p.Constraints.Compatibility = true
+		}
+	}
+	// Vehicle endpoints away from the depot become extra nodes after the
+	// stops, shared by vehicles with the same location
+	endpoints := map[models.Location]int{}
+	endpoint := func(loc *models.Location) int {
+		if loc == nil {
+			return 0
+		}
+		key := models.Location{Lat: loc.Lat, Lng: loc.Lng, State: loc.State}
+		if idx, ok := endpoints[key]; ok {
+			return idx
+		}
+		endpoints[key] = len(p.Nodes)
+		p.Nodes = append(p.Nodes, Node{ID: fmt.Sprintf("endpoint-%d", len(endpoints)), Location: key})
+		return len(p.Nodes) - 1
+	}
+	for i, v := range req.Vehicles {
+		p.Vehicles[i] = Vehicle{
+			ID:            v.ID,
+			CapacityKg:    v.CapacityKg,
+			InitialLoadKg: v.CurrentLoad,
+			DepartHours:   v.DepartHours,
+			Start:         endpoint(v.Start),
+			End:           endpoint(v.End),
+			SpeedFactor:   v.SpeedFactor,
+			Type:          v.Type,
+		}
+	}
+	return p
+}
+
+// ToRouteResponse renders the first route of a routing solution
+func (s Solution) ToRouteResponse(p *Problem) models.OptimizationResponse {
+	route := []models.Location{}
+	if len(s.Routes) > 0 {
+		for _, idx := range s.Routes[0].Stops {
+			route = append(route, p.Nodes[idx].Location)
+		}
+	}
+
+	return models.OptimizationResponse{
+		Route:       route,
+		TotalDistKm: s.DistanceKm,
+	}
+}
+
+// ToLoadResponse renders an allocation solution
+func (s Solution) ToLoadResponse(p *Problem) models.LoadResponse {
+	allocations := []models.Allocation{}
+	for _, r := range s.Routes {
+		if len(r.Stops) == 0 {
+			continue
+		}
+		v := p.Vehicles[r.Vehicle]
+		utilization := (r.LoadKg / v.CapacityKg) * 100
+		allocations = append(allocations, models.Allocation{
+			VehicleID:      v.ID,
+			ShipmentIDs:    p.nodeIDs(r.Stops),
+			TotalWeight:    r.LoadKg,
+			UtilizationPct: math.Round(utilization*100) / 100,
+			LatePenalty:    math.Round(r.LatePenalty*100) / 100,
+			Reasons:        s.allocationReasons(p, r),
+		})
+	}
+
+	resp := models.LoadResponse{
+		Allocations: allocations,
+		PenaltyCost: math.Round(s.PenaltyCost*100) / 100,
+	}
+	if len(s.Unassigned) > 0 {
+		resp.Unassigned = p.nodeIDs(s.Unassigned)
+	}
+	if len(s.Dropped) > 0 {
+		resp.Dropped = p.nodeIDs(s.Dropped)
+	}
+	return resp
+}
+
+// ToFleetResponse renders a multi-vehicle routing solution. Route stop IDs
+// exclude the vehicle's start and end.
+func (s Solution) ToFleetResponse(p *Problem) models.FleetResponse {
+	if p.Tolls != nil {
+		s = s.measured(p)
+	}
+	routes := []models.FleetRoute{}
+	for _, r := range s.Routes {
+		if len(r.Stops) <= 2 {
+			continue
+		}
+		v := p.Vehicles[r.Vehicle]
+		fr := models.FleetRoute{
+			VehicleID:  v.ID,
+			StopIDs:    p.nodeIDs(r.Stops[1 : len(r.Stops)-1]),
+			Route:      make([]models.Location, len(r.Stops)),
+			DistanceKm: r.DistanceKm,
+			LoadKg:     r.LoadKg,
+		}
+		for i, idx := range r.Stops {
+			fr.Route[i] = p.Nodes[idx].Location
+		}
+		for _, n := range r.Stops {
+			fr.ReturnKg += p.Nodes[n].ReturnKg
+		}
+		if fr.ReturnKg > 0 {
+			fr.PeakLoadKg = p.PeakLoad(v, r.Stops)
+		}
+		for k := 1; k < len(r.Stops); k++ {
+			fr.BorderDelayHours += p.BorderDelay(r.Stops[k-1], r.Stops[k])
+		}
+		fr.BorderDelayHours = math.Round(fr.BorderDelayHours*100) / 100
+		if p.Constraints.TimeWindows || fr.BorderDelayHours > 0 || len(p.Curfews) > 0 || v.SpeedFactor > 0 {
+			times := p.Schedule(v, r.Stops)
+			fr.ArrivalHours = make([]float64, 0, len(times)-2)
+			for _, t := range times[1 : len(times)-1] {
+				fr.ArrivalHours = append(fr.ArrivalHours, math.Round(t*100)/100)
+			}
+		}
+		if p.Constraints.TimeWindows {
+			fr.DueHours = make([]float64, 0, len(r.Stops)-2)
+			for _, n := range r.Stops[1 : len(r.Stops)-1] {
+				fr.DueHours = append(fr.DueHours, p.Nodes[n].DueHours)
+			}
+		}
+		routes = append(routes, fr)
+	}
+
+	return models.FleetResponse{
+		Routes:      routes,
+		Unassigned:  p.nodeIDs(s.Unassigned),
+		TotalDistKm: s.DistanceKm,
+	}
+}
+
+// measured replaces the costs solvers report as route distances with the
+// routes' length in km
+func (s Solution) measured(p *Problem) Solution {
+	s.Routes = append([]Route(nil), s.Routes...)
+	s.DistanceKm = 0
+	for i, r := range s.Routes {
+		r.DistanceKm = 0
+		for k := 1; k < len(r.Stops); k++ {
+			r.DistanceKm += p.Distance(r.Stops[k-1], r.Stops[k])
+		}
+		s.Routes[i] = r
+		s.DistanceKm += r.DistanceKm
+	}
+	return s
+}
+
+func (p *Problem) nodeIDs(idxs []int) []string {
+	ids := make([]string, len(idxs))
+	for i, idx := range idxs {
+		ids[i] = p.Nodes[idx].ID
+	}
+	return ids
+}
@@ -0,0 +1,133 @@
+// Package provider wraps external data providers, such as a road distance
+// service, so that a slow or failing one degrades answers instead of hanging
+// requests: calls time out, are retried with jitter, and a circuit breaker
+// stops calling a provider that keeps failing until it has had time to
+// recover.
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned without calling the provider while its breaker is open
+var ErrOpen = errors.New("provider: circuit open")
+
+// State is a breaker's position
+type State int
+
+const (
+	Closed   State = iota // Calls go through
+	Open                  // Calls fail fast until the cooldown ends
+	HalfOpen              // One trial call decides whether to close again
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker opens after Threshold consecutive failures and lets a trial call
+// through once Cooldown has passed. It is safe for concurrent use.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	trial     bool // A half-open trial is in flight
+	now       func() time.Time
+}
+
+// NewBreaker returns a closed breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: max(1, threshold), cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may go ahead. Every allowed call must be
+// followed by Success, Failure or Release.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state, b.trial = HalfOpen, true
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state, b.failures, b.trial = Closed, 0, false
+}
+
+// Failure counts a failed call and opens the breaker at the threshold or
+// when a half-open trial fails
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt, b.trial = Open, b.now(), false
+	}
+}
+
+// Release ends an allowed call without an outcome, e.g. when the caller
+// gave up before the provider answered
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// State returns the breaker's position and its consecutive failures
+func (b *Breaker) State() (State, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
+
+// retry calls fn up to attempts times, sleeping a random ("full jitter")
+// share of an exponentially growing backoff between calls. It stops early
+// when ctx ends.
+func retry(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for i := range attempts {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == attempts-1 {
+			break
+		}
+		wait := time.Duration(rand.Int64N(int64(backoff<<i) + 1))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+	return err
+}
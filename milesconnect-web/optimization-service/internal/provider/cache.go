@@ -0,0 +1,203 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache holds a precomputed road distance matrix among known locations
+// (depots and regular customers) so planning among them needs no provider
+// call. Locations match to about a metre.
+type Cache struct {
+	path string
+
+	mu    sync.RWMutex
+	table cacheTable
+	index map[string]int
+}
+
+// cacheTable is the file format
+type cacheTable struct {
+	Source      string            `json:"source"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Points      []models.Location `json:"points"`
+	Km          [][]float64       `json:"km"`
+}
+
+// OpenCache loads the matrix saved at path, if any; Replace writes there.
+// An empty path keeps the cache in memory.
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, index: map[string]int{}}
+	if path == "" {
+		return c, nil
+	}
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t cacheTable
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(t.Km) != len(t.Points) {
+		return nil, fmt.Errorf("%s: %d rows for %d points", path, len(t.Km), len(t.Points))
+	}
+	c.set(t)
+	return c, nil
+}
+
+func (c *Cache) set(t cacheTable) {
+	index := make(map[string]int, len(t.Points))
+	for i, p := range t.Points {
+		index[cacheKey(p)] = i
+	}
+	c.table, c.index = t, index
+}
+
+func cacheKey(l models.Location) string {
+	return fmt.Sprintf("%.5f,%.5f", l.Lat, l.Lng)
+}
+
+// Lookup returns the distances between locs if every one of them is cached
+func (c *Cache) Lookup(locs []models.Location) ([][]float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	idx := make([]int, len(locs))
+	for i, l := range locs {
+		j, ok := c.index[cacheKey(l)]
+		if !ok {
+			return nil, false
+		}
+		idx[i] = j
+	}
+	m := make([][]float64, len(locs))
+	for i, a := range idx {
+		m[i] = make([]float64, len(locs))
+		for j, b := range idx {
+			m[i][j] = c.table.Km[a][b]
+		}
+	}
+	return m, true
+}
+
+// Replace swaps in a new matrix among points and saves it
+func (c *Cache) Replace(source string, points []models.Location, km [][]float64) error {
+	t := cacheTable{Source: source, GeneratedAt: time.Now().UTC(), Points: points, Km: km}
+	if c.path != "" {
+		body, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		// Write then rename so a crash never leaves half a matrix
+		tmp, err := os.CreateTemp(filepath.Dir(c.path), ".distances-*")
+		if err != nil {
+			return err
+		}
+		if _, err := tmp.Write(body); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		if err := os.Rename(tmp.Name(), c.path); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+	return nil
+}
+
+// CacheInfo describes what a Cache holds
+type CacheInfo struct {
+	Points      int       `json:"points"`
+	Source      string    `json:"source,omitempty"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+}
+
+// Info reports the cache's size and age
+func (c *Cache) Info() CacheInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return CacheInfo{Points: len(c.table.Points), Source: c.table.Source, GeneratedAt: c.table.GeneratedAt}
+}
+
+// Precompute fetches the full matrix among locs from r and replaces the
+// cache with it, returning how many provider calls it took. Requests pair up
+// blocks of half MaxTablePoints, so n points take about (n/50)²/2 calls. It
+// stops at the first block r cannot answer, leaving the old matrix in place,
+// since a partial one would be mostly great-circle guesses; ctx bounds the
+// whole job.
+func (c *Cache) Precompute(ctx context.Context, r *Resilient, locs []models.Location) (int, error) {
+	// Duplicates would waste table slots
+	points := []models.Location{}
+	seen := map[string]bool{}
+	for _, l := range locs {
+		if k := cacheKey(l); !seen[k] {
+			seen[k] = true
+			points = append(points, l)
+		}
+	}
+
+	half := MaxTablePoints / 2
+	var blocks [][]int
+	for start := 0; start < len(points); start += half {
+		var b []int
+		for i := start; i < min(start+half, len(points)); i++ {
+			b = append(b, i)
+		}
+		blocks = append(blocks, b)
+	}
+
+	km := make([][]float64, len(points))
+	for i := range km {
+		km[i] = make([]float64, len(points))
+	}
+	calls := 0
+	fetch := func(idx []int) error {
+		sub := make([]models.Location, len(idx))
+		for i, p := range idx {
+			sub[i] = points[p]
+		}
+		calls++
+		m, source := r.Matrix(ctx, sub)
+		if m == nil {
+			return fmt.Errorf("%d of %d points: %s", len(sub), len(points), source)
+		}
+		for i, a := range idx {
+			for j, b := range idx {
+				km[a][b] = m[i][j]
+			}
+		}
+		return nil
+	}
+
+	if len(blocks) == 1 {
+		if err := fetch(blocks[0]); err != nil {
+			return calls, err
+		}
+	}
+	for i := range blocks {
+		for j := i + 1; j < len(blocks); j++ {
+			if err := fetch(append(append([]int{}, blocks[i]...), blocks[j]...)); err != nil {
+				return calls, err
+			}
+		}
+	}
+	return calls, c.Replace(r.name, points, km)
+}
@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/metrics"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxTablePoints is the largest matrix requested from OSRM, matching its
+// default --max-table-size
+const MaxTablePoints = 100
+
+// MatrixSource returns road distances in km between every pair of locs
+type MatrixSource interface {
+	Matrix(ctx context.Context, locs []models.Location) ([][]float64, error)
+}
+
+// OSRM calls the table service of an OSRM server
+type OSRM struct {
+	URL  string // Base URL, e.g. http://osrm:5000
+	HTTP *http.Client
+}
+
+// Matrix implements MatrixSource. Pairs OSRM cannot route between fall back
+// to great-circle distance.
+func (o OSRM) Matrix(ctx context.Context, locs []models.Location) ([][]float64, error) {
+	var body struct {
+		osrmStatus
+		Distances [][]*float64 `json:"distances"` // Metres; null when unroutable
+	}
+	if err := o.get(ctx, "table", locs, "annotations=distance", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Distances) != len(locs) {
+		return nil, fmt.Errorf("osrm: %d rows for %d points", len(body.Distances), len(locs))
+	}
+
+	m := make([][]float64, len(locs))
+	for i, row := range body.Distances {
+		if len(row) != len(locs) {
+			return nil, fmt.Errorf("osrm: row %d has %d entries", i, len(row))
+		}
+		m[i] = make([]float64, len(locs))
+		for j, d := range row {
+			if d == nil {
+				m[i][j] = problem.Haversine(locs[i], locs[j])
+			} else {
+				m[i][j] = *d / 1000
+			}
+		}
+	}
+	return m, nil
+}
+
+// osrmStatus is the part of every OSRM response that says whether it worked
+type osrmStatus struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s osrmStatus) status() osrmStatus { return s }
+
+// get calls an OSRM service with locs as its coordinates and decodes the
+// response into body, which embeds osrmStatus
+func (o OSRM) get(ctx context.Context, service string, locs []models.Location, query string, body interface{ status() osrmStatus }) error {
+	coords := make([]string, len(locs))
+	for i, l := range locs {
+		coords[i] = fmt.Sprintf("%.6f,%.6f", l.Lng, l.Lat)
+	}
+	url := strings.TrimSuffix(o.URL, "/") + "/" + service + "/v1/driving/" + strings.Join(coords, ";") + "?" + query
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := o.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("osrm: decoding response (%s): %w", resp.Status, err)
+	}
+	if st := body.status(); resp.StatusCode != http.StatusOK || st.Code != "Ok" {
+		return fmt.Errorf("osrm: %s %s: %s", resp.Status, st.Code, st.Message)
+	}
+	return nil
+}
+
+// Config tunes a Resilient provider; zero fields take the defaults
+type Config struct {
+	Timeout   time.Duration // Per attempt; default 3s
+	Attempts  int           // Default 3
+	Backoff   time.Duration // Base retry backoff; default 100ms
+	Threshold int           // Consecutive failures that open the breaker; default 5
+	Cooldown  time.Duration // How long the breaker stays open; default 30s
+}
+
+// Health is a provider's state for readiness checks
+type Health struct {
+	Name        string    `json:"name"`
+	Breaker     string    `json:"breaker"` // closed, open or half_open
+	Failures    int       `json:"consecutive_failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+}
+
+// Resilient guards a MatrixSource with timeouts, retries and a breaker so
+// callers can fall back to great-circle distances when it cannot answer. If
+// the source is also a TrackMatcher, track matching shares the breaker.
+type Resilient struct {
+	name    string
+	src     MatrixSource
+	cfg     Config
+	breaker *Breaker
+
+	mu          sync.Mutex
+	lastError   string
+	lastSuccess time.Time
+
+	failures, fallbacks *metrics.Counter
+}
+
+// NewResilient wraps src. name appears in health reports and metric names.
+func NewResilient(name string, src MatrixSource, cfg Config) *Resilient {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 100 * time.Millisecond
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+
+	r := &Resilient{
+		name:      name,
+		src:       src,
+		cfg:       cfg,
+		breaker:   NewBreaker(cfg.Threshold, cfg.Cooldown),
+		failures:  metrics.NewCounter("provider_"+name+"_failures_total", "Failed calls to the "+name+" provider, counting each retry"),
+		fallbacks: metrics.NewCounter("provider_"+name+"_fallbacks_total", "Requests answered by a local fallback instead of "+name),
+	}
+	metrics.GaugeFunc("provider_"+name+"_breaker_open", "1 while the "+name+" circuit breaker is open", func() float64 {
+		if s, _ := r.breaker.State(); s == Open {
+			return 1
+		}
+		return 0
+	})
+	return r
+}
+
+// Matrix returns distances between locs and where they came from: the
+// provider's name, or a nil matrix and "haversine" with the reason the
+// provider was skipped, meaning great-circle distances should be used. ctx
+// bounds how long the provider is tried.
+func (r *Resilient) Matrix(ctx context.Context, locs []models.Location) ([][]float64, string) {
+	if len(locs) > MaxTablePoints {
+		r.fallbacks.Inc()
+		return nil, fmt.Sprintf("haversine (%d points exceed the %s limit of %d)", len(locs), r.name, MaxTablePoints)
+	}
+	var m [][]float64
+	if why, ok := r.call(ctx, func(ctx context.Context) error {
+		var err error
+		m, err = r.src.Matrix(ctx, locs)
+		return err
+	}); !ok {
+		return nil, "haversine (" + why + ")"
+	}
+	return m, r.name
+}
+
+// call runs fn under the breaker, with a timeout per attempt and retries.
+// When it gives up it returns why, e.g. "osrm timed out", for the caller's
+// note on the fallback it used.
+func (r *Resilient) call(ctx context.Context, fn func(ctx context.Context) error) (string, bool) {
+	if !r.breaker.Allow() {
+		r.fallbacks.Inc()
+		return r.name + " circuit open", false
+	}
+
+	err := retry(ctx, r.cfg.Attempts, r.cfg.Backoff, func() error {
+		attempt, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+		err := fn(attempt)
+		if err != nil {
+			r.failures.Inc()
+		}
+		return err
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.fallbacks.Inc()
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider
+			r.breaker.Release()
+			return r.name + " not tried before the deadline", false
+		}
+		r.breaker.Failure()
+		r.lastError = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return r.name + " timed out", false
+		}
+		return r.name + " failed", false
+	}
+	r.breaker.Success()
+	r.lastSuccess = time.Now().UTC()
+	return "", true
+}
+
+// Health reports the breaker state and the last outcome
+func (r *Resilient) Health() Health {
+	state, failures := r.breaker.State()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Health{Name: r.name, Breaker: state.String(), Failures: failures, LastError: r.lastError, LastSuccess: r.lastSuccess}
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+)
+
+// MaxMatchPoints is the longest trace sent to OSRM's match service at once,
+// matching its default --max-matching-size
+const MaxMatchPoints = 100
+
+// TrackMatcher snaps a GPS trace to the road network, returning the path
+// the vehicle most likely drove and its length in km
+type TrackMatcher interface {
+	Match(ctx context.Context, track []models.Location) ([]models.Location, float64, error)
+}
+
+// Match implements TrackMatcher with OSRM's match service. Points it cannot
+// place (gaps, outliers) are dropped rather than failing the trace.
+func (o OSRM) Match(ctx context.Context, track []models.Location) ([]models.Location, float64, error) {
+	var body struct {
+		osrmStatus
+		Matchings []struct {
+			Distance float64 `json:"distance"` // Metres
+			Geometry string  `json:"geometry"`
+		} `json:"matchings"`
+	}
+	if err := o.get(ctx, "match", track, "overview=full&geometries=polyline6&gaps=ignore&tidy=true", &body); err != nil {
+		return nil, 0, err
+	}
+
+	var path []models.Location
+	km := 0.0
+	for _, m := range body.Matchings {
+		pts, err := geo.DecodePolyline(m.Geometry, geo.Precision6)
+		if err != nil {
+			return nil, 0, fmt.Errorf("osrm: matched geometry: %w", err)
+		}
+		path = append(path, pts...)
+		km += m.Distance / 1000
+	}
+	if len(path) == 0 {
+		return nil, 0, fmt.Errorf("osrm: no matchings for %d points", len(track))
+	}
+	return path, km, nil
+}
+
+// Match snaps track to roads through the wrapped source, in pieces of
+// MaxMatchPoints that share their end points. Like Matrix, it returns nil
+// and why when the source cannot answer, so the caller can fall back.
+func (r *Resilient) Match(ctx context.Context, track []models.Location) ([]models.Location, float64, string) {
+	m, ok := r.src.(TrackMatcher)
+	if !ok {
+		return nil, 0, r.name + " cannot match tracks"
+	}
+	if len(track) < 2 {
+		return track, 0, r.name
+	}
+
+	var path []models.Location
+	km := 0.0
+	for start := 0; start < len(track)-1; start += MaxMatchPoints - 1 {
+		piece := track[start:min(start+MaxMatchPoints, len(track))]
+		var matched []models.Location
+		var pieceKm float64
+		if why, ok := r.call(ctx, func(ctx context.Context) error {
+			var err error
+			matched, pieceKm, err = m.Match(ctx, piece)
+			return err
+		}); !ok {
+			return nil, 0, why
+		}
+		path = append(path, matched...)
+		km += pieceKm
+	}
+	return path, km, r.name
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/geo"
+	"milesconnect-optimization/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var locs = []models.Location{{Lat: 28.61, Lng: 77.21}, {Lat: 28.70, Lng: 77.10}}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if !b.Allow() {
+		t.Fatal("opened before the threshold")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("allowed a call while open")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow() || b.Allow() {
+		t.Fatal("half-open should allow exactly one trial")
+	}
+	b.Failure()
+	if s, _ := b.State(); s != Open {
+		t.Fatalf("failed trial left breaker %s", s)
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Success()
+	if s, n := b.State(); s != Closed || n != 0 {
+		t.Errorf("after a good trial: %s with %d failures", s, n)
+	}
+}
+
+func TestOSRMMatrix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/table/v1/driving/77.210000,28.610000;77.100000,28.700000") {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"code":"Ok","distances":[[0,15250.5],[null,0]]}`))
+	}))
+	defer srv.Close()
+
+	m, err := OSRM{URL: srv.URL}.Matrix(context.Background(), locs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[0][1] != 15.2505 || m[1][0] < 13 || m[1][0] > 15 {
+		t.Errorf("matrix = %v, want OSRM km and great-circle for the unroutable pair", m)
+	}
+}
+
+type flaky struct {
+	calls atomic.Int32
+	err   error
+	delay time.Duration
+}
+
+func (f *flaky) Matrix(ctx context.Context, locs []models.Location) ([][]float64, error) {
+	f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return [][]float64{{0, 1}, {1, 0}}, nil
+}
+
+func TestResilientFallsBack(t *testing.T) {
+	src := &flaky{err: errors.New("503")}
+	r := NewResilient("test_flaky", src, Config{Attempts: 2, Backoff: time.Millisecond, Threshold: 2, Cooldown: time.Hour})
+
+	for range 2 {
+		if m, source := r.Matrix(context.Background(), locs); m != nil || source != "haversine (test_flaky failed)" {
+			t.Fatalf("Matrix = %v, %q", m, source)
+		}
+	}
+	if m, source := r.Matrix(context.Background(), locs); m != nil || !strings.Contains(source, "circuit open") {
+		t.Fatalf("with the breaker open: %v, %q", m, source)
+	}
+	if calls := src.calls.Load(); calls != 4 {
+		t.Errorf("provider called %d times, want 2 requests x 2 attempts", calls)
+	}
+	if h := r.Health(); h.Breaker != "open" || h.LastError != "503" {
+		t.Errorf("health = %+v", h)
+	}
+}
+
+func TestResilientTimesOut(t *testing.T) {
+	src := &flaky{delay: time.Hour}
+	r := NewResilient("test_slow", src, Config{Timeout: 10 * time.Millisecond, Attempts: 1})
+
+	start := time.Now()
+	if m, source := r.Matrix(context.Background(), locs); m != nil || source != "haversine (test_slow timed out)" {
+		t.Errorf("Matrix = %v, %q", m, source)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("a hung provider held the call for %s", time.Since(start))
+	}
+
+	src.delay = 0
+	if m, source := r.Matrix(context.Background(), locs); m == nil || source != "test_slow" {
+		t.Errorf("after recovery: %v, %q", m, source)
+	}
+}
+
+// latDiff reports the latitude difference as the distance, so any entry of
+// a stitched matrix can be checked
+type latDiff struct{ calls atomic.Int32 }
+
+func (l *latDiff) Matrix(ctx context.Context, locs []models.Location) ([][]float64, error) {
+	l.calls.Add(1)
+	m := make([][]float64, len(locs))
+	for i, a := range locs {
+		m[i] = make([]float64, len(locs))
+		for j, b := range locs {
+			m[i][j] = b.Lat - a.Lat
+		}
+	}
+	return m, nil
+}
+
+func TestCachePrecomputesInBlocks(t *testing.T) {
+	var points []models.Location
+	for i := range 120 {
+		points = append(points, models.Location{Lat: 20 + float64(i)/100, Lng: 77})
+	}
+	src := &latDiff{}
+	path := filepath.Join(t.TempDir(), "distances.json")
+	c, err := OpenCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := c.Precompute(context.Background(), NewResilient("test_table", src, Config{}), append(points, points[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || src.calls.Load() != 3 {
+		t.Errorf("%d calls for 120 points, want 3 pairs of 50-point blocks", calls)
+	}
+
+	reopened, err := OpenCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := reopened.Info(); info.Points != 120 || info.Source != "test_table" {
+		t.Errorf("reloaded %+v", info)
+	}
+	m, ok := reopened.Lookup([]models.Location{points[119], points[3], points[60]})
+	if !ok {
+		t.Fatal("cached points missed")
+	}
+	if d := m[1][0] - 1.16; d > 1e-9 || d < -1e-9 || m[0][2] > -0.58 {
+		t.Errorf("stitched matrix = %v", m)
+	}
+	if _, ok := reopened.Lookup([]models.Location{points[0], {Lat: 1, Lng: 1}}); ok {
+		t.Error("an uncached point hit")
+	}
+}
+
+func TestOSRMMatchSplitsLongTracks(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		coords := strings.Split(strings.TrimPrefix(r.URL.Path, "/match/v1/driving/"), ";")
+		if len(coords) > MaxMatchPoints || r.URL.Query().Get("geometries") != "polyline6" {
+			t.Errorf("%d points, query %s", len(coords), r.URL.RawQuery)
+		}
+		first, last := locs[0], locs[1]
+		fmt.Fprintf(w, `{"code":"Ok","matchings":[{"distance":1500,"geometry":%q}]}`, geo.EncodePolyline([]models.Location{first, last}, geo.Precision6))
+	}))
+	defer srv.Close()
+
+	track := make([]models.Location, 150)
+	for i := range track {
+		track[i] = models.Location{Lat: 28.6 + float64(i)/1000, Lng: 77.2}
+	}
+	r := NewResilient("test_match", OSRM{URL: srv.URL}, Config{})
+	path, km, source := r.Match(context.Background(), track)
+	if source != "test_match" || requests.Load() != 2 || len(path) != 4 || km != 3 {
+		t.Errorf("Match = %d points, %v km from %q in %d requests", len(path), km, source, requests.Load())
+	}
+
+	if path, _, source := NewResilient("test_nomatch", &flaky{}, Config{}).Match(context.Background(), track); path != nil || source != "test_nomatch cannot match tracks" {
+		t.Errorf("matrix-only source: %v, %q", path, source)
+	}
+}
@@ -0,0 +1,85 @@
+// Package queue bounds how many CPU-heavy solves run at once. Callers beyond
+// the limit wait in a bounded queue for up to a timeout.
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("solver queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting for a solver slot")
+)
+
+// Pool admits at most Concurrency solves, with up to Depth callers waiting
+type Pool struct {
+	slots   chan struct{}
+	depth   int64
+	timeout time.Duration
+
+	waiting atomic.Int64
+	running atomic.Int64
+}
+
+// New creates a pool. timeout <= 0 means callers wait until their context ends.
+func New(concurrency, depth int, timeout time.Duration) *Pool {
+	return &Pool{
+		slots:   make(chan struct{}, max(1, concurrency)),
+		depth:   int64(max(0, depth)),
+		timeout: timeout,
+	}
+}
+
+// Acquire blocks until a slot is free and returns its release func
+func (p *Pool) Acquire(ctx context.Context) (func(), error) {
+	// Fast path: a slot is free right now
+	select {
+	case p.slots <- struct{}{}:
+		p.running.Add(1)
+		return p.release, nil
+	default:
+	}
+
+	if p.waiting.Add(1) > p.depth {
+		p.waiting.Add(-1)
+		return nil, ErrQueueFull
+	}
+	defer p.waiting.Add(-1)
+
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		t := time.NewTimer(p.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		p.running.Add(1)
+		return p.release, nil
+	case <-timeout:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	p.running.Add(-1)
+	<-p.slots
+}
+
+// Waiting is the current queue depth
+func (p *Pool) Waiting() int64 { return p.waiting.Load() }
+
+// Running is the number of solves holding a slot
+func (p *Pool) Running() int64 { return p.running.Load() }
+
+// Load is the share of the pool taken, running or waiting: 0 when idle, 1
+// when every slot is busy and the queue is full
+func (p *Pool) Load() float64 {
+	return float64(p.running.Load()+p.waiting.Load()) / float64(int64(cap(p.slots))+p.depth)
+}
@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolRejectsBeyondDepth(t *testing.T) {
+	p := New(1, 1, time.Second)
+	release, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One caller may queue...
+	queued := make(chan error, 1)
+	go func() {
+		rel, err := p.Acquire(context.Background())
+		if err == nil {
+			rel()
+		}
+		queued <- err
+	}()
+	for p.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if p.Load() != 1 {
+		t.Errorf("load = %v with the slot and queue taken, want 1", p.Load())
+	}
+
+	// ...the next is turned away
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Fatalf("queued caller failed: %v", err)
+	}
+	if p.Running() != 0 || p.Waiting() != 0 {
+		t.Errorf("running=%d waiting=%d after release, want 0/0", p.Running(), p.Waiting())
+	}
+}
+
+func TestPoolQueueTimeout(t *testing.T) {
+	p := New(1, 5, 10*time.Millisecond)
+	release, _ := p.Acquire(context.Background())
+	defer release()
+
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("err = %v, want ErrQueueTimeout", err)
+	}
+}
+
+func TestPoolHonoursContext(t *testing.T) {
+	p := New(1, 5, 0)
+	release, _ := p.Acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
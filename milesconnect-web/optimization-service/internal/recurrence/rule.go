@@ -0,0 +1,201 @@
+// Package recurrence evaluates a subset of iCalendar RRULEs (RFC 5545) on
+// whole days: FREQ=DAILY|WEEKLY|MONTHLY with INTERVAL, BYDAY, BYMONTHDAY,
+// COUNT and UNTIL, e.g. "FREQ=WEEKLY;BYDAY=MO,TH" or
+// "FREQ=MONTHLY;BYMONTHDAY=1,-1".
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Freq is how often a rule repeats
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+)
+
+// maxCountDays bounds the scan that checks COUNT
+const maxCountDays = 20 * 366
+
+var byDay = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Rule is a parsed recurrence starting on Start (a date; the time of day is
+// ignored)
+type Rule struct {
+	Freq      Freq
+	Interval  int
+	Weekdays  []time.Weekday // BYDAY
+	MonthDays []int          // BYMONTHDAY; negative counts from the month's end
+	Count     int            // 0 means unbounded
+	Until     time.Time      // Zero means unbounded
+	Start     time.Time
+}
+
+// Parse reads rule, which starts on start
+func Parse(rule string, start time.Time) (Rule, error) {
+	r := Rule{Interval: 1, Start: day(start)}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("recurrence: malformed part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Freq(strings.ToUpper(val))
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly {
+				return Rule{}, fmt.Errorf("recurrence: unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+			if err == nil && r.Count < 1 {
+				err = errors.New("must be positive")
+			}
+		case "UNTIL":
+			// Dates, or date-times of which only the date counts
+			r.Until, err = time.Parse("20060102", val[:min(len(val), 8)])
+		case "BYDAY":
+			for _, d := range strings.Split(strings.ToUpper(val), ",") {
+				wd, ok := byDay[d]
+				if !ok {
+					return Rule{}, fmt.Errorf("recurrence: unsupported BYDAY %q", d)
+				}
+				r.Weekdays = append(r.Weekdays, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Rule{}, fmt.Errorf("recurrence: bad BYMONTHDAY %q", d)
+				}
+				r.MonthDays = append(r.MonthDays, n)
+			}
+		default:
+			return Rule{}, fmt.Errorf("recurrence: unsupported part %q", key)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("recurrence: bad %s %q: %v", key, val, err)
+		}
+	}
+	if r.Freq == "" {
+		return Rule{}, errors.New("recurrence: FREQ is required")
+	}
+	if r.Start.IsZero() {
+		return Rule{}, errors.New("recurrence: a start date is required")
+	}
+	return r, nil
+}
+
+// Occurs reports whether the rule has an occurrence on date
+func (r Rule) Occurs(date time.Time) bool {
+	date = day(date)
+	if !r.matches(date) {
+		return false
+	}
+	if r.Count == 0 {
+		return true
+	}
+
+	// Count the occurrences before date
+	n := 0
+	for d := r.Start; d.Before(date) && n < r.Count; d = d.AddDate(0, 0, 1) {
+		if d.Sub(r.Start) > maxCountDays*24*time.Hour {
+			return false
+		}
+		if r.matches(d) {
+			n++
+		}
+	}
+	return n < r.Count
+}
+
+// matches checks date against everything but COUNT
+func (r Rule) matches(date time.Time) bool {
+	if date.Before(r.Start) || (!r.Until.IsZero() && date.After(r.Until)) {
+		return false
+	}
+
+	switch r.Freq {
+	case Daily:
+		days := int(date.Sub(r.Start).Hours() / 24)
+		return days%r.Interval == 0 && r.onWeekday(date, len(r.Weekdays) == 0) && r.onMonthDay(date, len(r.MonthDays) == 0)
+	case Weekly:
+		weeks := int(weekStart(date).Sub(weekStart(r.Start)).Hours() / (24 * 7))
+		return weeks%r.Interval == 0 && r.onWeekday(date, false) && r.onMonthDay(date, len(r.MonthDays) == 0)
+	case Monthly:
+		months := (date.Year()-r.Start.Year())*12 + int(date.Month()-r.Start.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		if len(r.Weekdays) > 0 && len(r.MonthDays) == 0 {
+			return r.onWeekday(date, false)
+		}
+		return r.onMonthDay(date, false) && r.onWeekday(date, len(r.Weekdays) == 0)
+	}
+	return false
+}
+
+// onWeekday checks BYDAY, defaulting to the start's weekday; all says an
+// empty BYDAY matches every day
+func (r Rule) onWeekday(date time.Time, all bool) bool {
+	if len(r.Weekdays) == 0 {
+		return all || date.Weekday() == r.Start.Weekday()
+	}
+	for _, wd := range r.Weekdays {
+		if date.Weekday() == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// onMonthDay checks BYMONTHDAY, defaulting to the start's day of month; all
+// says an empty BYMONTHDAY matches every day. A day past the month's end
+// (e.g. 31 in April) does not occur, as RFC 5545 specifies.
+func (r Rule) onMonthDay(date time.Time, all bool) bool {
+	if len(r.MonthDays) == 0 {
+		return all || date.Day() == r.Start.Day()
+	}
+	last := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.MonthDays {
+		if md < 0 {
+			md = last + 1 + md
+		}
+		if date.Day() == md {
+			return true
+		}
+	}
+	return false
+}
+
+// day truncates t to its calendar date in UTC
+func day(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart is the Monday of t's week (RFC 5545's default WKST)
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
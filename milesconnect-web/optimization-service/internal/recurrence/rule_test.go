@@ -0,0 +1,63 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestOccurs(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start string
+		yes   []string
+		no    []string
+	}{
+		{"FREQ=DAILY", "2026-10-01", []string{"2026-10-01", "2026-10-02", "2027-01-01"}, []string{"2026-09-30"}},
+		{"FREQ=DAILY;INTERVAL=3", "2026-10-01", []string{"2026-10-04", "2026-10-07"}, []string{"2026-10-02", "2026-10-05"}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "2026-10-01", []string{"2026-10-02", "2026-10-05"}, []string{"2026-10-03", "2026-10-04"}},
+		{"FREQ=WEEKLY", "2026-10-01", []string{"2026-10-08"}, []string{"2026-10-02"}}, // Thursdays
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", "2026-10-01", []string{"2026-10-01", "2026-10-12", "2026-10-15"}, []string{"2026-10-05", "2026-10-08"}},
+		{"FREQ=MONTHLY", "2026-01-31", []string{"2026-03-31"}, []string{"2026-02-28", "2026-04-30"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "2026-01-01", []string{"2026-02-01", "2026-02-28", "2028-02-29"}, []string{"2026-02-27"}},
+		{"FREQ=MONTHLY;BYDAY=SA", "2026-10-01", []string{"2026-10-03", "2026-11-28"}, []string{"2026-10-04"}},
+		{"FREQ=DAILY;COUNT=3", "2026-10-01", []string{"2026-10-03"}, []string{"2026-10-04"}},
+		{"RRULE:FREQ=DAILY;UNTIL=20261005T235959Z", "2026-10-01", []string{"2026-10-05"}, []string{"2026-10-06"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.rule, func(t *testing.T) {
+			r, err := Parse(tc.rule, date(tc.start))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range tc.yes {
+				if !r.Occurs(date(d)) {
+					t.Errorf("%s: expected an occurrence", d)
+				}
+			}
+			for _, d := range tc.no {
+				if r.Occurs(date(d)) {
+					t.Errorf("%s: unexpected occurrence", d)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"", "INTERVAL=2", "FREQ=YEARLY", "FREQ=DAILY;INTERVAL=0", "FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=DAILY;BYHOUR=9", "FREQ=DAILY;COUNT", "FREQ=DAILY;UNTIL=soon",
+	} {
+		if _, err := Parse(rule, date("2026-10-01")); err == nil {
+			t.Errorf("%q: expected an error", rule)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package secrets keeps credentials such as webhook secrets and provider
+// URLs carrying API keys out of plaintext config. A sealed value is
+// encrypted with AES-256-GCM under one of the keys in a keyring and is
+// written "sealed:<key id>:<base64>"; it can sit in the environment or a
+// config file where anyone reading it sees only ciphertext.
+//
+// Keys rotate by putting a new key first in the keyring: values are sealed
+// with the first key, every key listed still opens, and Reseal moves old
+// values onto the new key before the old one is dropped.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const prefix = "sealed:"
+
+var (
+	ErrNoKey   = errors.New("secrets: sealed with a key not in the keyring")
+	ErrCorrupt = errors.New("secrets: sealed value is corrupt")
+)
+
+// Keyring holds the keys secrets are sealed with. The zero value has none
+// and passes plaintext through, so unsealed deployments keep working.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring reads a keyring written "id=base64key,id=base64key,...",
+// each key 32 bytes; the first seals new values
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("secrets: keyring entry %q must be id=base64key", entry)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("secrets: key %q is listed twice", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("secrets: key %q must be 32 bytes in base64", id)
+		}
+		block, _ := aes.NewCipher(raw)
+		aead, _ := cipher.NewGCM(block)
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	return k, nil
+}
+
+// NewKey returns a fresh key for a keyring, in base64
+func NewKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Current returns the ID of the key new values are sealed with
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// Seal encrypts plain under the current key
+func (k *Keyring) Seal(plain string) (string, error) {
+	if k.current == "" {
+		return "", errors.New("secrets: the keyring is empty")
+	}
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	// The key ID is authenticated so a value cannot be moved under another key
+	box := aead.Seal(nonce, nonce, []byte(plain), []byte(k.current))
+	return prefix + k.current + ":" + base64.StdEncoding.EncodeToString(box), nil
+}
+
+// Open decrypts a sealed value; a plaintext value is returned as it is
+func (k *Keyring) Open(value string) (string, error) {
+	if !Sealed(value) {
+		return value, nil
+	}
+	id, encoded, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", ErrNoKey
+	}
+	box, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(box) < aead.NonceSize() {
+		return "", ErrCorrupt
+	}
+	plain, err := aead.Open(nil, box[:aead.NonceSize()], box[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	return string(plain), nil
+}
+
+// Reseal opens value and seals it again under the current key, to rotate
+// it off an older one; plaintext values are sealed for the first time
+func (k *Keyring) Reseal(value string) (string, error) {
+	plain, err := k.Open(value)
+	if err != nil {
+		return "", err
+	}
+	return k.Seal(plain)
+}
+
+// Sealed reports whether value is sealed rather than plaintext
+func Sealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID returns the ID of the key value is sealed with, or "" for plaintext
+func KeyID(value string) string {
+	if !Sealed(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+// Mask shows enough of a secret to tell which one is configured: its last
+// four characters, or nothing of a short one
+func Mask(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+// MaskURL hides the credentials a provider URL may carry, its password and
+// query string, leaving the host for logs and admin listings
+func MaskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return Mask(s)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "****"
+	}
+	return u.Redacted()
+}
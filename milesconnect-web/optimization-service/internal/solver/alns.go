@@ -0,0 +1,331 @@
+package solver
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"milesconnect-optimization/internal/problem"
+	"sort"
+	"time"
+)
+
+// ALNS defaults; alns_iterations, time_limit_ms and seed override them
+const (
+	ALNSIterations = 5000
+	ALNSTimeLimit  = 5 * time.Second
+
+	alnsSegment      = 100  // Iterations between operator weight updates
+	alnsReaction     = 0.2  // How far weights move towards the segment's scores
+	alnsMinRemoval   = 0.1  // Share of customers removed per iteration, lower bound
+	alnsMaxRemoval   = 0.3  // ... and upper bound
+	alnsMaxRemoved   = 60   // Absolute cap on removed customers
+	alnsRandomness   = 3    // Bias of worst/related removal towards their ranking
+	alnsStartWorse   = 0.05 // A 5% worse plan is accepted with probability 1/2 at the start
+	alnsFinalCooling = 1e-3 // Temperature at the last iteration, relative to the start
+)
+
+// Operator scores: new best, improved on current, accepted though worse
+const (
+	scoreBest     = 33
+	scoreImproved = 9
+	scoreAccepted = 13
+)
+
+type destroyOp func(p *problem.Problem, pl *vrpPlan, q int, rng *rand.Rand)
+type repairOp func(p *problem.Problem, pl *vrpPlan, rng *rand.Rand)
+
+// ALNS runs adaptive large neighbourhood search: each iteration ruins part
+// of the plan with a removal heuristic (random, worst or related) and
+// recreates it with an insertion heuristic (greedy or regret-2). Operators
+// that lead to good plans are picked more often, and worse plans are
+// accepted by simulated annealing so the search keeps moving. It starts
+// from the cheaper of a fresh insertion plan and p's seeds.
+func ALNS(ctx context.Context, p *problem.Problem) problem.Solution {
+	iterations := ALNSIterations
+	if v := int(p.SolverParams["alns_iterations"]); v > 0 {
+		iterations = v
+	}
+	seed := time.Now().UnixNano()
+	if v, ok := p.SolverParams["seed"]; ok {
+		seed = int64(v)
+	}
+	trace := TraceFrom(ctx)
+	rng := rand.New(rand.NewSource(trace.Seed(seed)))
+	deadline := time.Now().Add(timeLimit(p, ALNSTimeLimit))
+
+	p = withDistanceMatrix(p)
+	cur := cheapestInsertion(p)
+	customers := len(vrpCustomers(p))
+	if customers == 0 || len(p.Vehicles) == 0 {
+		return cur.solution(p)
+	}
+
+	penalty := UnassignedPenalty(p)
+	cost := func(pl vrpPlan) float64 { return pl.distance(p) + penalty*float64(len(pl.unassigned)) }
+	for _, seed := range p.Seeds {
+		if pl := seededPlan(p, seed); cost(pl) < cost(cur) {
+			cur = pl
+		}
+	}
+
+	destroys := []destroyOp{randomRemoval, worstRemoval, relatedRemoval}
+	repairs := []repairOp{greedyInsertion, regretInsertion}
+	dw, rw := uniformWeights(len(destroys)), uniformWeights(len(repairs))
+	dScore, rScore := make([]float64, len(destroys)), make([]float64, len(repairs))
+	dUsed, rUsed := make([]int, len(destroys)), make([]int, len(repairs))
+
+	best, curCost := cur.clone(), cost(cur)
+	bestCost := curCost
+	trace.Notef("alns: %d stops on %d vehicles, start %.1f km with %d unassigned", customers, len(p.Vehicles), cur.distance(p), len(cur.unassigned))
+	temp := alnsStartWorse * curCost / math.Ln2
+	cooling := math.Pow(alnsFinalCooling, 1/float64(iterations))
+
+	it := 0
+	for ; it < iterations; it++ {
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			break
+		}
+
+		di, ri := roulette(dw, rng), roulette(rw, rng)
+		next := cur.clone()
+		q := removalCount(customers, rng)
+		destroys[di](p, &next, q, rng)
+		dropInfeasibleRoutes(p, &next)
+		repairs[ri](p, &next, rng)
+		nextCost := cost(next)
+
+		score := 0.0
+		switch {
+		case nextCost < bestCost-vrpEpsilon:
+			best, bestCost = next.clone(), nextCost
+			trace.Verbosef("alns: iteration %d: best %.1f km with %d unassigned", it, next.distance(p), len(next.unassigned))
+			cur, curCost = next, nextCost
+			score = scoreBest
+		case nextCost < curCost-vrpEpsilon:
+			cur, curCost = next, nextCost
+			score = scoreImproved
+		case rng.Float64() < math.Exp((curCost-nextCost)/temp):
+			cur, curCost = next, nextCost
+			score = scoreAccepted
+		}
+		dScore[di] += score
+		rScore[ri] += score
+		dUsed[di]++
+		rUsed[ri]++
+		temp *= cooling
+
+		if (it+1)%alnsSegment == 0 {
+			updateWeights(dw, dScore, dUsed)
+			updateWeights(rw, rScore, rUsed)
+		}
+	}
+
+	for vi := range best.routes {
+		best.routes[vi] = polishRoute(p, vi, best.routes[vi])
+	}
+	trace.Notef("alns: %d of %d iterations, best %.1f km with %d unassigned; destroy weights %.2f, repair weights %.2f",
+		it, iterations, best.distance(p), len(best.unassigned), dw, rw)
+	return best.solution(p)
+}
+
+// UnassignedPenalty prices an unserved node above the cost of any single
+// out-and-back trip, so serving a node always beats leaving it out
+func UnassignedPenalty(p *problem.Problem) float64 {
+	worst := 0.0
+	for _, n := range vrpCustomers(p) {
+		for _, v := range p.Vehicles {
+			worst = math.Max(worst, p.Cost(v.Start, n)+p.Cost(n, v.End))
+		}
+	}
+	return 2*worst + 1
+}
+
+func removalCount(customers int, rng *rand.Rand) int {
+	lo := max(1, int(alnsMinRemoval*float64(customers)))
+	hi := max(lo, min(alnsMaxRemoved, int(alnsMaxRemoval*float64(customers))))
+	return lo + rng.Intn(hi-lo+1)
+}
+
+func uniformWeights(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1
+	}
+	return w
+}
+
+func roulette(w []float64, rng *rand.Rand) int {
+	total := 0.0
+	for _, x := range w {
+		total += x
+	}
+	r := rng.Float64() * total
+	for i, x := range w {
+		if r < x {
+			return i
+		}
+		r -= x
+	}
+	return len(w) - 1
+}
+
+// updateWeights blends each operator's average segment score into its
+// weight and resets the segment counters
+func updateWeights(w, score []float64, used []int) {
+	for i := range w {
+		if used[i] > 0 {
+			w[i] = math.Max(0.1, (1-alnsReaction)*w[i]+alnsReaction*score[i]/float64(used[i]))
+		}
+		score[i], used[i] = 0, 0
+	}
+}
+
+// routedNodes lists every served node
+func routedNodes(pl *vrpPlan) []int {
+	var nodes []int
+	for _, inner := range pl.routes {
+		nodes = append(nodes, inner...)
+	}
+	return nodes
+}
+
+// removeNodes takes nodes out of their routes and marks them unassigned
+func removeNodes(pl *vrpPlan, nodes []int) {
+	drop := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		drop[n] = true
+	}
+	for vi, inner := range pl.routes {
+		kept := inner[:0]
+		for _, n := range inner {
+			if !drop[n] {
+				kept = append(kept, n)
+			}
+		}
+		pl.routes[vi] = kept
+	}
+	pl.unassigned = append(pl.unassigned, nodes...)
+}
+
+// pickBiased picks q entries of ranked, favouring the front of the list
+func pickBiased(ranked []int, q int, rng *rand.Rand) []int {
+	picked := make([]int, 0, q)
+	for len(picked) < q && len(ranked) > 0 {
+		i := int(math.Pow(rng.Float64(), alnsRandomness) * float64(len(ranked)))
+		picked = append(picked, ranked[i])
+		ranked = append(ranked[:i], ranked[i+1:]...)
+	}
+	return picked
+}
+
+// randomRemoval removes q served nodes chosen uniformly
+func randomRemoval(p *problem.Problem, pl *vrpPlan, q int, rng *rand.Rand) {
+	nodes := routedNodes(pl)
+	rng.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	removeNodes(pl, nodes[:min(q, len(nodes))])
+}
+
+// worstRemoval removes nodes whose detour costs the most
+func worstRemoval(p *problem.Problem, pl *vrpPlan, q int, rng *rand.Rand) {
+	saving := map[int]float64{}
+	var nodes []int
+	for vi, inner := range pl.routes {
+		v := p.Vehicles[vi]
+		for i, n := range inner {
+			prev, next := neighbours(v, inner, i)
+			saving[n] = p.Cost(prev, n) + p.Cost(n, next) - p.Cost(prev, next)
+			if len(inner) == 1 {
+				saving[n] += p.Cost(v.Start, v.End)
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return saving[nodes[i]] > saving[nodes[j]] })
+	removeNodes(pl, pickBiased(nodes, q, rng))
+}
+
+// relatedRemoval removes a random node and those closest to it, which
+// frees a neighbourhood for the repair step to rearrange across routes
+func relatedRemoval(p *problem.Problem, pl *vrpPlan, q int, rng *rand.Rand) {
+	nodes := routedNodes(pl)
+	if len(nodes) == 0 {
+		return
+	}
+	seed := nodes[rng.Intn(len(nodes))]
+	sort.Slice(nodes, func(i, j int) bool { return p.Cost(seed, nodes[i]) < p.Cost(seed, nodes[j]) })
+	removeNodes(pl, pickBiased(nodes, q, rng))
+}
+
+// dropInfeasibleRoutes unassigns every node of a route that removal made
+// infeasible, which can happen when the distances break the triangle
+// inequality
+func dropInfeasibleRoutes(p *problem.Problem, pl *vrpPlan) {
+	for vi, inner := range pl.routes {
+		if !routeFeasible(p, p.Vehicles[vi], inner) {
+			pl.unassigned = append(pl.unassigned, inner...)
+			pl.routes[vi] = nil
+		}
+	}
+}
+
+// greedyInsertion inserts the unassigned nodes in random order, each at its
+// cheapest feasible position
+func greedyInsertion(p *problem.Problem, pl *vrpPlan, rng *rand.Rand) {
+	rng.Shuffle(len(pl.unassigned), func(i, j int) {
+		pl.unassigned[i], pl.unassigned[j] = pl.unassigned[j], pl.unassigned[i]
+	})
+	fillUnassigned(p, pl)
+}
+
+// regretInsertion repeatedly inserts the node with the largest regret: the
+// gap between its best and second-best route. Nodes with few options go
+// first, before the routes they fit fill up.
+func regretInsertion(p *problem.Problem, pl *vrpPlan, rng *rand.Rand) {
+	pending := pl.unassigned
+	pl.unassigned = nil
+	slots := make([][]slot, len(pending))
+	var buf []int
+	for k, n := range pending {
+		slots[k] = make([]slot, len(p.Vehicles))
+		for vi := range p.Vehicles {
+			slots[k][vi], buf = bestSlot(p, vi, pl.routes[vi], n, buf)
+		}
+	}
+
+	for len(pending) > 0 {
+		pick, route := -1, -1
+		pickRegret, pickDelta := math.Inf(-1), math.Inf(1)
+		for k := range pending {
+			first, second, bestV := math.Inf(1), math.Inf(1), -1
+			for vi, s := range slots[k] {
+				switch {
+				case !s.ok:
+				case s.delta < first:
+					first, second, bestV = s.delta, first, vi
+				case s.delta < second:
+					second = s.delta
+				}
+			}
+			if bestV < 0 {
+				continue
+			}
+			regret := second - first
+			if regret > pickRegret || (regret == pickRegret && first < pickDelta) {
+				pick, route, pickRegret, pickDelta = k, bestV, regret, first
+			}
+		}
+		if pick < 0 {
+			break
+		}
+
+		n := pending[pick]
+		pl.routes[route] = insertAt(nil, pl.routes[route], slots[pick][route].pos, n)
+		last := len(pending) - 1
+		pending[pick], slots[pick] = pending[last], slots[last]
+		pending, slots = pending[:last], slots[:last]
+		for k, m := range pending {
+			slots[k][route], buf = bestSlot(p, route, pl.routes[route], m, buf)
+		}
+	}
+	pl.unassigned = append(pl.unassigned, pending...)
+}
@@ -0,0 +1,170 @@
+package solver
+
+import (
+	"context"
+	"math"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+)
+
+func fleetProblem(t *testing.T, size int, seed int64) *problem.Problem {
+	t.Helper()
+	req, err := generator.FleetRequest(generator.Config{Size: size, Distribution: generator.Clustered, Seed: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return problem.FromFleetRequest(req)
+}
+
+func TestALNSImprovesOnConstruction(t *testing.T) {
+	p := fleetProblem(t, 60, 3)
+	p.SolverParams = map[string]float64{"alns_iterations": 500, "seed": 1}
+
+	start := cheapestInsertion(p).solution(p)
+	sol := ALNS(context.Background(), p)
+	checkVRP(t, p, sol)
+
+	if len(sol.Unassigned) > len(start.Unassigned) {
+		t.Errorf("ALNS left %d stops unassigned, construction %d", len(sol.Unassigned), len(start.Unassigned))
+	}
+	if sol.DistanceKm > start.DistanceKm+1e-6 {
+		t.Errorf("ALNS distance %.1f is worse than construction %.1f", sol.DistanceKm, start.DistanceKm)
+	}
+}
+
+func TestALNSSeedIsDeterministic(t *testing.T) {
+	run := func() float64 {
+		p := fleetProblem(t, 30, 4)
+		p.SolverParams = map[string]float64{"alns_iterations": 200, "seed": 42}
+		return ALNS(context.Background(), p).DistanceKm
+	}
+	if a, b := run(), run(); a != b {
+		t.Errorf("same seed gave %.4f and %.4f", a, b)
+	}
+}
+
+func TestALNSStartsFromSeeds(t *testing.T) {
+	p := fleetProblem(t, 40, 5)
+	p.SolverParams = map[string]float64{"alns_iterations": 1000, "seed": 1}
+	good := ALNS(context.Background(), p)
+
+	// A seed from the long run, and one that has lost a stop, which the
+	// solve must insert again
+	seed := make([][]int, len(p.Vehicles))
+	for _, r := range good.Routes {
+		v := p.Vehicles[r.Vehicle]
+		for _, n := range r.Stops {
+			if n != v.Start && n != v.End {
+				seed[r.Vehicle] = append(seed[r.Vehicle], n)
+			}
+		}
+	}
+	short := make([][]int, len(seed))
+	for i, stops := range seed {
+		short[i] = append([]int(nil), stops...)
+	}
+	for i := range short {
+		if len(short[i]) > 0 {
+			short[i] = short[i][1:]
+			break
+		}
+	}
+
+	p.Seeds = [][][]int{short, seed}
+	p.SolverParams = map[string]float64{"alns_iterations": 1, "seed": 1}
+	sol := ALNS(context.Background(), p)
+	checkVRP(t, p, sol)
+	if sol.DistanceKm > good.DistanceKm+1e-6 || len(sol.Unassigned) > len(good.Unassigned) {
+		t.Errorf("seeded solve %.1f km, %d unassigned; seed %.1f km, %d unassigned", sol.DistanceKm, len(sol.Unassigned), good.DistanceKm, len(good.Unassigned))
+	}
+
+	p.Seeds = [][][]int{short}
+	checkVRP(t, p, ALNS(context.Background(), p))
+}
+
+func TestALNSRespectsTimeWindows(t *testing.T) {
+	p := fleetProblem(t, 30, 5)
+	p.Constraints.TimeWindows = true
+	for i := 1; i < len(p.Nodes); i++ {
+		if i%2 == 0 {
+			p.Nodes[i].DueHours = 24
+		}
+		if i%3 == 0 {
+			p.Nodes[i].ReadyHours = 10
+		}
+		p.Nodes[i].ServiceHours = 0.5
+	}
+	p.SolverParams = map[string]float64{"alns_iterations": 300}
+
+	checkVRP(t, p, ALNS(context.Background(), p))
+}
+
+func TestRegretInsertionServesEveryFittingNode(t *testing.T) {
+	p := fleetProblem(t, 40, 6)
+	pl := vrpPlan{routes: make([][]int, len(p.Vehicles)), unassigned: vrpCustomers(p)}
+	regretInsertion(p, &pl, nil)
+	checkVRP(t, p, pl.solution(p))
+	if len(pl.unassigned) != 0 {
+		t.Errorf("%d nodes left unassigned with spare capacity", len(pl.unassigned))
+	}
+}
+
+func TestVehicleEndpointsAwayFromDepot(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 40, Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// V1 starts at its driver's home and ends at the depot; V2 runs the other way
+	home := models.Location{Lat: req.Depot.Lat + 0.5, Lng: req.Depot.Lng + 0.5}
+	req.Vehicles[0].Start = &home
+	req.Vehicles[1].End = &home
+	p := problem.FromFleetRequest(req)
+	if p.Vehicles[0].Start != p.Vehicles[1].End || p.Vehicles[0].Start != len(req.Stops)+1 {
+		t.Fatalf("endpoints = %+v, want a shared node after the stops", p.Vehicles[:2])
+	}
+	p.SolverParams = map[string]float64{"alns_iterations": 300, "seed": 1, "tabu_iterations": 50}
+
+	for name, sol := range map[string]problem.Solution{
+		"alns": ALNS(context.Background(), p),
+		"tabu": TabuSearch(context.Background(), p),
+	} {
+		checkVRP(t, p, sol)
+		total := 0.0
+		for _, r := range sol.Routes {
+			total += RouteDistance(p, r.Stops)
+		}
+		if math.Abs(total-sol.DistanceKm) > 1e-6 {
+			t.Errorf("%s: distance %.3f, routes add up to %.3f", name, sol.DistanceKm, total)
+		}
+	}
+}
+
+func TestALNSServesStopsWithAllowedTypes(t *testing.T) {
+	p := fleetProblem(t, 40, 6)
+	p.SolverParams = map[string]float64{"alns_iterations": 300, "seed": 1}
+	p.Vehicles[0].Type = "mini-truck"
+	narrow := vrpCustomers(p)[:10]
+	for _, n := range narrow {
+		p.Nodes[n].VehicleTypes = []string{"mini-truck"}
+	}
+	p.Constraints.Compatibility = true
+
+	sol := ALNS(context.Background(), p)
+	checkVRP(t, p, sol)
+	served := 0
+	for _, r := range sol.Routes {
+		for _, n := range r.Stops {
+			if len(p.Nodes[n].VehicleTypes) > 0 {
+				served++
+				if r.Vehicle != 0 {
+					t.Errorf("vehicle %s serves narrow stop %s", p.Vehicles[r.Vehicle].ID, p.Nodes[n].ID)
+				}
+			}
+		}
+	}
+	if served == 0 {
+		t.Error("no narrow stop was served")
+	}
+}
@@ -0,0 +1,113 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"milesconnect-optimization/internal/problem"
+	"strings"
+)
+
+// Thresholds for the auto solver, in waypoints
+const (
+	autoBatchMaxGA    = 150  // GA is quadratic per child; beyond this 2-opt wins on time and quality
+	autoMaxImprovable = 2000 // Beyond this even 2-opt passes are too slow for a request
+	autoDelegateStops = 200  // Constrained fleet problems this large go to OR-Tools when available
+)
+
+func init() {
+	Register(autoSolver{})
+}
+
+// autoSolver inspects the instance and delegates to the most suitable solver,
+// recording the choice and reason on the solution
+type autoSolver struct{}
+
+func (autoSolver) Name() string { return "auto" }
+func (autoSolver) Capabilities() Capabilities {
+	return CapRouting | CapAllocation | CapCapacity | CapTimeWindows | CapMatrix | CapCompatibility
+}
+
+// allowedKey holds the check, from the caller's feature flags, of which
+// solvers auto may delegate to
+type allowedKey struct{}
+
+// WithAllowed limits the solvers auto delegates to under ctx to those
+// allowed accepts by name
+func WithAllowed(ctx context.Context, allowed func(name string) bool) context.Context {
+	return context.WithValue(ctx, allowedKey{}, allowed)
+}
+
+// allowedFrom reports whether solves under ctx may delegate to name
+func allowedFrom(ctx context.Context, name string) bool {
+	allowed, ok := ctx.Value(allowedKey{}).(func(string) bool)
+	return !ok || allowed(name)
+}
+
+// autoChoice is a solver auto may delegate to and why
+type autoChoice struct{ name, reason string }
+
+func (a autoSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	var passed []string // Why better choices were passed over
+	for _, c := range a.choose(p) {
+		s, ok := Get(c.name)
+		if !ok {
+			continue
+		}
+		if !allowedFrom(ctx, c.name) {
+			passed = append(passed, c.name+" is not enabled")
+			continue
+		}
+
+		sol, err := s.Solve(ctx, p)
+		if err != nil && c.name == "ortools" && ctx.Err() == nil {
+			// The sidecar is an optimization, not a dependency
+			passed = append(passed, fmt.Sprintf("OR-Tools failed (%v)", err))
+			continue
+		}
+		if err != nil {
+			return sol, err
+		}
+		sol.Solver = c.name
+		sol.Reason = c.reason
+		if len(passed) > 0 {
+			sol.Reason = fmt.Sprintf("%s; %s, fell back to %s", c.reason, strings.Join(passed, "; "), c.name)
+		}
+		return sol, nil
+	}
+	return problem.Solution{}, fmt.Errorf("auto: %w", ErrNotEnabled)
+}
+
+// choose lists the solvers suited to p, best first; Solve takes the first
+// that is registered and enabled
+func (autoSolver) choose(p *problem.Problem) []autoChoice {
+	if p.Type == problem.TypeAllocation {
+		return []autoChoice{{"best-fit-decreasing", "allocation problem"}}
+	}
+
+	if len(p.Vehicles) > 1 || p.Constraints.Capacity || p.Constraints.TimeWindows {
+		var choices []autoChoice
+		stops := len(vrpCustomers(p))
+		if p.Constraints.TimeWindows && !p.Constraints.Compatibility && stops >= autoDelegateStops {
+			choices = append(choices, autoChoice{"ortools", fmt.Sprintf("%d stops with time windows delegated to OR-Tools", stops)})
+		}
+		reason := fmt.Sprintf("%d vehicles with capacity or time window constraints", len(p.Vehicles))
+		return append(choices, autoChoice{"alns", reason}, autoChoice{"tabu", reason})
+	}
+
+	var choices []autoChoice
+	n := len(p.Nodes) - 2
+	if n <= MaxExactWaypoints {
+		choices = append(choices, autoChoice{"exact", fmt.Sprintf("%d waypoints is small enough to solve optimally", n)})
+	}
+	if p.Batch && n <= autoBatchMaxGA {
+		choices = append(choices, autoChoice{"genetic", fmt.Sprintf("batch request with %d waypoints", n)})
+	}
+	if n <= autoMaxImprovable {
+		choices = append(choices, autoChoice{"two-opt", fmt.Sprintf("%d waypoints: nearest neighbor refined by 2-opt", n)})
+	}
+	reason := fmt.Sprintf("%d waypoints is too large for local search", n)
+	if n <= autoMaxImprovable {
+		reason = fmt.Sprintf("%d waypoints: nearest neighbor only", n)
+	}
+	return append(choices, autoChoice{"nearest-neighbor", reason})
+}
@@ -0,0 +1,64 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"strings"
+	"testing"
+)
+
+func TestAutoChoosesBySize(t *testing.T) {
+	tests := []struct {
+		size  int
+		batch bool
+		want  string
+	}{
+		{5, false, "exact"},
+		{50, false, "two-opt"},
+		{2500, false, "nearest-neighbor"},
+	}
+
+	for _, tt := range tests {
+		req, err := generator.RouteRequest(generator.Config{Size: tt.size, Seed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := problem.FromRouteRequest(req)
+		p.Batch = tt.batch
+
+		sol, err := autoSolver{}.Solve(context.Background(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sol.Solver != tt.want {
+			t.Errorf("size %d: chose %q, want %q", tt.size, sol.Solver, tt.want)
+		}
+		if sol.Reason == "" {
+			t.Errorf("size %d: no reason recorded", tt.size)
+		}
+	}
+}
+
+func TestAutoSkipsSolversNotAllowed(t *testing.T) {
+	req, err := generator.RouteRequest(generator.Config{Size: 5, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromRouteRequest(req)
+
+	ctx := WithAllowed(context.Background(), func(name string) bool { return name != "exact" })
+	sol, err := autoSolver{}.Solve(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Solver != "two-opt" || !strings.Contains(sol.Reason, "exact is not enabled") {
+		t.Errorf("chose %q (%s), want two-opt", sol.Solver, sol.Reason)
+	}
+
+	ctx = WithAllowed(context.Background(), func(string) bool { return false })
+	if _, err := (autoSolver{}).Solve(ctx, p); !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("with nothing allowed: %v", err)
+	}
+}
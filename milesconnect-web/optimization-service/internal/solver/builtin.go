@@ -0,0 +1,126 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"milesconnect-optimization/internal/problem"
+	"time"
+)
+
+func init() {
+	Register(nearestNeighborSolver{})
+	Register(twoOptSolver{})
+	Register(guidedLocalSearchSolver{})
+	Register(exactSolver{})
+	Register(bestFitDecreasingSolver{})
+	Register(tabuSolver{})
+	Register(alnsSolver{})
+}
+
+type nearestNeighborSolver struct{}
+
+func (nearestNeighborSolver) Name() string               { return "nearest-neighbor" }
+func (nearestNeighborSolver) Capabilities() Capabilities { return CapRouting | CapMatrix }
+
+func (nearestNeighborSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return NearestNeighbor(p), nil
+}
+
+type twoOptSolver struct{}
+
+func (twoOptSolver) Name() string               { return "two-opt" }
+func (twoOptSolver) Capabilities() Capabilities { return CapRouting | CapMatrix }
+
+func (twoOptSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return TwoOptContext(ctx, p, NearestNeighbor(p)), nil
+}
+
+type guidedLocalSearchSolver struct{}
+
+func (guidedLocalSearchSolver) Name() string               { return "guided-local-search" }
+func (guidedLocalSearchSolver) Capabilities() Capabilities { return CapRouting | CapMatrix }
+func (guidedLocalSearchSolver) CPUIntensive() bool         { return true }
+
+func (guidedLocalSearchSolver) TimeLimit(p *problem.Problem) time.Duration {
+	return timeLimit(p, GLSTimeLimit)
+}
+
+func (guidedLocalSearchSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return GuidedLocalSearch(ctx, p), nil
+}
+
+type exactSolver struct{}
+
+func (exactSolver) Name() string               { return "exact" }
+func (exactSolver) Capabilities() Capabilities { return CapRouting | CapMatrix }
+
+func (exactSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	if len(p.Nodes)-2 > MaxExactWaypoints {
+		return problem.Solution{}, fmt.Errorf("%w: exact solver handles at most %d waypoints", ErrProblemTooLarge, MaxExactWaypoints)
+	}
+	return Exact(p), nil
+}
+
+type bestFitDecreasingSolver struct{}
+
+func (bestFitDecreasingSolver) Name() string { return "best-fit-decreasing" }
+func (bestFitDecreasingSolver) Capabilities() Capabilities {
+	return CapAllocation | CapCapacity | CapTimeWindows | CapCompatibility
+}
+
+func (bestFitDecreasingSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeAllocation {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return BestFitDecreasing(p), nil
+}
+
+type tabuSolver struct{}
+
+func (tabuSolver) Name() string { return "tabu" }
+func (tabuSolver) Capabilities() Capabilities {
+	return CapRouting | CapCapacity | CapTimeWindows | CapMatrix | CapCompatibility
+}
+
+func (tabuSolver) CPUIntensive() bool { return true }
+func (tabuSolver) TimeLimit(p *problem.Problem) time.Duration {
+	return timeLimit(p, TabuTimeLimit)
+}
+
+func (tabuSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return TabuSearch(ctx, p), nil
+}
+
+type alnsSolver struct{}
+
+func (alnsSolver) Name() string { return "alns" }
+func (alnsSolver) Capabilities() Capabilities {
+	return CapRouting | CapCapacity | CapTimeWindows | CapMatrix | CapCompatibility
+}
+
+func (alnsSolver) CPUIntensive() bool { return true }
+func (alnsSolver) TimeLimit(p *problem.Problem) time.Duration {
+	return timeLimit(p, ALNSTimeLimit)
+}
+
+func (alnsSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, ErrUnsupportedProblem
+	}
+	return ALNS(ctx, p), nil
+}
@@ -0,0 +1,29 @@
+package solver
+
+import "context"
+
+// Checkpointer keeps a long solve's progress outside the process, so a solve
+// restarted elsewhere (e.g. a background job taken over from a crashed
+// replica) resumes where it was rather than from scratch. Names tell apart
+// several solves in one request.
+type Checkpointer interface {
+	// Save replaces the checkpoint called name with v
+	Save(name string, v any) error
+	// Load reads the checkpoint called name into v; ok is false when there
+	// is none
+	Load(name string, v any) (ok bool, err error)
+}
+
+type checkpointKey struct{}
+
+// WithCheckpointer hands c to the solves run under ctx
+func WithCheckpointer(ctx context.Context, c Checkpointer) context.Context {
+	return context.WithValue(ctx, checkpointKey{}, c)
+}
+
+// CheckpointerFrom returns the checkpointer solves under ctx save to, or
+// nil when their progress is not kept
+func CheckpointerFrom(ctx context.Context) Checkpointer {
+	c, _ := ctx.Value(checkpointKey{}).(Checkpointer)
+	return c
+}
@@ -0,0 +1,100 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+)
+
+// deltaMaxPasses bounds the relocation passes over the inserted nodes
+const deltaMaxPasses = 20
+
+// ExtendPlan keeps a fixed plan and routes only what it leaves out. fixed
+// holds each vehicle's inner stops (endpoints excluded) in order; every
+// other customer is inserted at its cheapest feasible position, then the
+// inserted nodes alone are relocated while that shortens the plan. Fixed
+// stops never change vehicle or relative order.
+func ExtendPlan(ctx context.Context, p *problem.Problem, fixed [][]int) problem.Solution {
+	pl := vrpPlan{routes: make([][]int, len(p.Vehicles))}
+	inPlan := make([]bool, len(p.Nodes))
+	for vi := range pl.routes {
+		if vi < len(fixed) {
+			pl.routes[vi] = append([]int(nil), fixed[vi]...)
+			for _, n := range fixed[vi] {
+				inPlan[n] = true
+			}
+		}
+	}
+
+	var added []int
+	for _, n := range vrpCustomers(p) {
+		if inPlan[n] {
+			continue
+		}
+		if insertCheapest(p, &pl, n) {
+			added = append(added, n)
+		} else {
+			pl.unassigned = append(pl.unassigned, n)
+		}
+	}
+
+	for pass, improved := 0, true; improved && pass < deltaMaxPasses && ctx.Err() == nil; pass++ {
+		improved = false
+		for _, n := range added {
+			vi, pos := locate(&pl, n)
+			before := pl.distance(p)
+			inner := pl.routes[vi]
+			pl.routes[vi] = append(inner[:pos:pos], inner[pos+1:]...)
+			insertCheapest(p, &pl, n) // Its old slot is still feasible
+			if pl.distance(p) < before-vrpEpsilon {
+				improved = true
+			}
+		}
+	}
+	return pl.solution(p)
+}
+
+// seededPlan fits a seed plan to p: its routes keep the stops that are still
+// customers, a route no longer feasible (e.g. a smaller truck) gives its
+// stops up, and every customer left over is inserted at its cheapest
+func seededPlan(p *problem.Problem, seed [][]int) vrpPlan {
+	pl := vrpPlan{routes: make([][]int, len(p.Vehicles))}
+	customer := make([]bool, len(p.Nodes))
+	for _, n := range vrpCustomers(p) {
+		customer[n] = true
+	}
+	inPlan := make([]bool, len(p.Nodes))
+	for vi := range min(len(seed), len(p.Vehicles)) {
+		var inner []int
+		for _, n := range seed[vi] {
+			if n >= 0 && n < len(p.Nodes) && customer[n] && !inPlan[n] {
+				inner = append(inner, n)
+				inPlan[n] = true
+			}
+		}
+		if routeFeasible(p, p.Vehicles[vi], inner) {
+			pl.routes[vi] = inner
+			continue
+		}
+		for _, n := range inner {
+			inPlan[n] = false
+		}
+	}
+	for _, n := range vrpCustomers(p) {
+		if !inPlan[n] && !insertCheapest(p, &pl, n) {
+			pl.unassigned = append(pl.unassigned, n)
+		}
+	}
+	return pl
+}
+
+// locate returns the vehicle and position of n in pl
+func locate(pl *vrpPlan, n int) (int, int) {
+	for vi, inner := range pl.routes {
+		for k, m := range inner {
+			if m == n {
+				return vi, k
+			}
+		}
+	}
+	return -1, -1
+}
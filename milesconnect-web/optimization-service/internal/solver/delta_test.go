@@ -0,0 +1,40 @@
+package solver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExtendPlanKeepsFixedRoutes(t *testing.T) {
+	p := fleetProblem(t, 30, 4)
+
+	// The first 20 stops form the fixed plan, as a solver would route them
+	base := *p
+	base.Nodes = p.Nodes[:21]
+	plan := cheapestInsertion(&base)
+
+	sol := ExtendPlan(context.Background(), p, plan.routes)
+	checkVRP(t, p, sol)
+	if len(sol.Unassigned) != 0 {
+		t.Errorf("%d stops unassigned", len(sol.Unassigned))
+	}
+
+	// Each vehicle still visits its fixed stops in the same order
+	for _, r := range sol.Routes {
+		var kept []int
+		for _, n := range r.Stops[1 : len(r.Stops)-1] {
+			if n <= 20 {
+				kept = append(kept, n)
+			}
+		}
+		fixed := plan.routes[r.Vehicle]
+		if len(kept) != len(fixed) {
+			t.Fatalf("vehicle %d kept %v, want %v", r.Vehicle, kept, fixed)
+		}
+		for k := range kept {
+			if kept[k] != fixed[k] {
+				t.Fatalf("vehicle %d kept %v, want %v", r.Vehicle, kept, fixed)
+			}
+		}
+	}
+}
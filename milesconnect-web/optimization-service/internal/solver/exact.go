@@ -0,0 +1,100 @@
+package solver
+
+import (
+	"math"
+	"milesconnect-optimization/internal/problem"
+)
+
+// MaxExactWaypoints bounds Held-Karp, which is O(2^n * n^2) in time and memory
+const MaxExactWaypoints = 12
+
+// Exact finds the optimal route for the first vehicle with Held-Karp dynamic
+// programming. Callers must check the waypoint count against MaxExactWaypoints.
+func Exact(p *problem.Problem) problem.Solution {
+	v := p.Vehicles[0]
+	waypoints := routeWaypoints(p, v)
+	k := len(waypoints)
+	if k == 0 {
+		return singleRoute(p, []int{v.Start, v.End})
+	}
+
+	full := 1 << k
+	dp := make([]float64, full*k)
+	parent := make([]int, full*k)
+	for i := range dp {
+		dp[i] = math.Inf(1)
+	}
+	for i, w := range waypoints {
+		dp[(1<<i)*k+i] = p.Cost(v.Start, w)
+		parent[(1<<i)*k+i] = -1
+	}
+
+	for mask := 1; mask < full; mask++ {
+		for last := 0; last < k; last++ {
+			cur := dp[mask*k+last]
+			if mask&(1<<last) == 0 || math.IsInf(cur, 1) {
+				continue
+			}
+			for next := 0; next < k; next++ {
+				if mask&(1<<next) != 0 {
+					continue
+				}
+				nm := mask | 1<<next
+				if d := cur + p.Cost(waypoints[last], waypoints[next]); d < dp[nm*k+next] {
+					dp[nm*k+next] = d
+					parent[nm*k+next] = last
+				}
+			}
+		}
+	}
+
+	best, bestLast := math.Inf(1), 0
+	for last := 0; last < k; last++ {
+		if d := dp[(full-1)*k+last] + p.Cost(waypoints[last], v.End); d < best {
+			best, bestLast = d, last
+		}
+	}
+
+	// Walk parents back from the last waypoint
+	order := make([]int, k)
+	mask, last := full-1, bestLast
+	for i := k - 1; i >= 0; i-- {
+		order[i] = waypoints[last]
+		prev := parent[mask*k+last]
+		mask &^= 1 << last
+		last = prev
+	}
+
+	stops := append(append([]int{v.Start}, order...), v.End)
+	return singleRoute(p, stops)
+}
+
+// routeWaypoints lists every node except the vehicle's endpoints
+func routeWaypoints(p *problem.Problem, v problem.Vehicle) []int {
+	waypoints := make([]int, 0, len(p.Nodes))
+	for i := range p.Nodes {
+		if i != v.Start && i != v.End {
+			waypoints = append(waypoints, i)
+		}
+	}
+	return waypoints
+}
+
+// singleRoute wraps stops as the first vehicle's route, computing its length
+func singleRoute(p *problem.Problem, stops []int) problem.Solution {
+	dist := RouteDistance(p, stops)
+	return problem.Solution{
+		Routes:     []problem.Route{{Vehicle: 0, Stops: stops, DistanceKm: dist}},
+		DistanceKm: dist,
+	}
+}
+
+// RouteDistance sums the legs of stops by Cost, their length unless tolls
+// are priced
+func RouteDistance(p *problem.Problem, stops []int) float64 {
+	dist := 0.0
+	for i := 1; i < len(stops); i++ {
+		dist += p.Cost(stops[i-1], stops[i])
+	}
+	return dist
+}
@@ -0,0 +1,59 @@
+package solver
+
+import (
+	"encoding/json"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+// The solver entry points trust the handlers for shape checks (matrix size,
+// array limits) but must not panic on any values, including NaN and Inf
+func FuzzNearestNeighbor(f *testing.F) {
+	for _, inst := range fixtures.RouteInstances() {
+		b, _ := json.Marshal(inst.Request)
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var req models.OptimizationRequest
+		if json.Unmarshal(body, &req) != nil || len(req.Waypoints) > 200 {
+			t.Skip()
+		}
+		if req.DistanceMatrix != nil && !squareOf(req.DistanceMatrix, len(req.Waypoints)+2) {
+			t.Skip()
+		}
+
+		resp := SolveTSPNearestNeighbor(req)
+		if len(resp.Route) < 2 {
+			t.Fatalf("route must at least contain start and end, got %d stops", len(resp.Route))
+		}
+	})
+}
+
+func FuzzFleetAllocation(f *testing.F) {
+	for _, inst := range fixtures.LoadInstances() {
+		b, _ := json.Marshal(inst.Request)
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var req models.LoadRequest
+		if json.Unmarshal(body, &req) != nil || len(req.Shipments) > 500 {
+			t.Skip()
+		}
+		OptimizeFleetAllocation(req)
+	})
+}
+
+func squareOf(m [][]float64, n int) bool {
+	if len(m) != n {
+		return false
+	}
+	for _, row := range m {
+		if len(row) != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package genetic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"time"
+)
+
+// checkpointEvery is how often a checkpointed run saves its population:
+// rarely enough not to slow it, often enough that a restart loses little
+var checkpointEvery = 15 * time.Second
+
+// checkpoint is a run's state after a generation. Distances are not kept;
+// they are recomputed on resuming.
+type checkpoint struct {
+	Generation   int       `json:"generation"` // The last one completed
+	MutationRate float64   `json:"mutation_rate"`
+	Paths        [][]int   `json:"paths"`
+	SavedAt      time.Time `json:"saved_at"`
+}
+
+func newCheckpoint(gen int, rate float64, pop *Population) checkpoint {
+	c := checkpoint{Generation: gen, MutationRate: rate, SavedAt: time.Now().UTC()}
+	for _, t := range pop.Tours {
+		c.Paths = append(c.Paths, append([]int(nil), t.Path...))
+	}
+	return c
+}
+
+func (c checkpoint) population() *Population {
+	pop := &Population{Tours: make([]Tour, len(c.Paths))}
+	for i, path := range c.Paths {
+		pop.Tours[i] = Tour{Path: path}
+	}
+	return pop
+}
+
+// loadCheckpoint reads the checkpoint called name from cp, when there is
+// one and it is a population of size permutations of n waypoints
+func loadCheckpoint(cp solver.Checkpointer, name string, n, size int) (checkpoint, bool) {
+	if cp == nil {
+		return checkpoint{}, false
+	}
+	var c checkpoint
+	if ok, err := cp.Load(name, &c); !ok || err != nil || len(c.Paths) != size {
+		return checkpoint{}, false
+	}
+	for _, path := range c.Paths {
+		seen := make([]bool, n)
+		if len(path) != n {
+			return checkpoint{}, false
+		}
+		for _, idx := range path {
+			if idx < 0 || idx >= n || seen[idx] {
+				return checkpoint{}, false
+			}
+			seen[idx] = true
+		}
+	}
+	return c, true
+}
+
+// checkpointName identifies a run by its problem and parameters, so a
+// checkpoint is only resumed by the run that saved it
+func checkpointName(p *problem.Problem, params Params, waypoints []int) string {
+	h := fnv.New64a()
+	v := p.Vehicles[0]
+	for _, x := range []float64{float64(v.Start), float64(v.End), float64(params.PopulationSize), float64(params.Generations), params.MutationRate, float64(params.TournamentSize)} {
+		binary.Write(h, binary.LittleEndian, math.Float64bits(x))
+	}
+	for _, i := range waypoints {
+		binary.Write(h, binary.LittleEndian, math.Float64bits(p.Nodes[i].Location.Lat))
+		binary.Write(h, binary.LittleEndian, math.Float64bits(p.Nodes[i].Location.Lng))
+	}
+	return fmt.Sprintf("ga-%016x", h.Sum64())
+}
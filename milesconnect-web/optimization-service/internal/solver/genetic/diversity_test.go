@@ -0,0 +1,55 @@
+package genetic
+
+import (
+	"math/rand"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+)
+
+func TestRemoveDuplicatesKeepsBestAndRestoresDiversity(t *testing.T) {
+	p := problem.FromRouteRequest(fixtures.RouteInstances()[0].Request)
+	v := p.Vehicles[0]
+	var waypoints []int
+	for i := range p.Nodes {
+		if i != v.Start && i != v.End {
+			waypoints = append(waypoints, i)
+		}
+	}
+
+	best := Tour{Path: []int{0, 1, 2}}
+	best.Distance = calculateDistance(best.Path, p, v, waypoints)
+	pop := &Population{Tours: make([]Tour, 10)}
+	for i := range pop.Tours {
+		pop.Tours[i] = Tour{Path: append([]int(nil), best.Path...), Distance: best.Distance}
+	}
+
+	if div := removeDuplicates(rand.New(rand.NewSource(1)), pop, p, v, waypoints); div != 0.1 {
+		t.Errorf("diversity = %v, want 0.1", div)
+	}
+	if pop.Tours[0].Distance > best.Distance {
+		t.Errorf("best tour lost: %v > %v", pop.Tours[0].Distance, best.Distance)
+	}
+	for _, tour := range pop.Tours {
+		if !isPermutation(tour.Path, len(waypoints)) {
+			t.Fatalf("immigrant %v is not a permutation", tour.Path)
+		}
+	}
+}
+
+func TestAdaptiveMutationRate(t *testing.T) {
+	tests := []struct {
+		base, diversity, want float64
+	}{
+		{0.05, 1, 0.05},
+		{0.05, MinDiversity, 0.05},
+		{0.05, 0, MaxMutationRate},
+		{0.05, MinDiversity / 2, (0.05 + MaxMutationRate) / 2},
+		{0.8, 0, 0.8},
+	}
+	for _, tt := range tests {
+		if got := adaptiveMutationRate(tt.base, tt.diversity); got-tt.want > 1e-9 || tt.want-got > 1e-9 {
+			t.Errorf("adaptiveMutationRate(%v, %v) = %v, want %v", tt.base, tt.diversity, got, tt.want)
+		}
+	}
+}
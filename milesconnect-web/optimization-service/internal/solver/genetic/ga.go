@@ -1,10 +1,13 @@
 package genetic
 
 import (
-	"math"
+	"context"
 	"math/rand"
 	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -25,76 +28,184 @@ const (
 	TournamentSize = 5
 )
 
+// Diversity maintenance: below MinDiversity (share of distinct tours) the
+// mutation rate ramps up towards MaxMutationRate so a converged population
+// keeps exploring
+const (
+	MinDiversity    = 0.5
+	MaxMutationRate = 0.5
+)
+
+// Params tunes a single GA run; the zero value of a field means its default
+type Params struct {
+	PopulationSize int     `json:"population_size"`
+	Generations    int     `json:"generations"`
+	MutationRate   float64 `json:"mutation_rate"`
+	TournamentSize int     `json:"tournament_size"`
+}
+
+// DefaultParams are the hand-picked constants above
+var DefaultParams = Params{
+	PopulationSize: PopulationSize,
+	Generations:    Generations,
+	MutationRate:   MutationRate,
+	TournamentSize: TournamentSize,
+}
+
+// ParamsFrom overlays solver parameters (as carried on a Problem) on the
+// defaults. Unknown keys are ignored so profiles can be shared across solvers.
+func ParamsFrom(values map[string]float64) Params {
+	params := DefaultParams
+	if v := int(values["population_size"]); v >= 2 {
+		params.PopulationSize = v
+	}
+	if v := int(values["generations"]); v > 0 {
+		params.Generations = v
+	}
+	if v, ok := values["mutation_rate"]; ok && v >= 0 && v <= 1 {
+		params.MutationRate = v
+	}
+	if v := int(values["tournament_size"]); v > 0 {
+		params.TournamentSize = v
+	}
+	return params
+}
+
+// Values is the inverse of ParamsFrom
+func (params Params) Values() map[string]float64 {
+	return map[string]float64{
+		"population_size": float64(params.PopulationSize),
+		"generations":     float64(params.Generations),
+		"mutation_rate":   params.MutationRate,
+		"tournament_size": float64(params.TournamentSize),
+	}
+}
+
 // SolveTSPGenetic runs the genetic algorithm to solve TSP
 func SolveTSPGenetic(req models.OptimizationRequest) models.OptimizationResponse {
-	rand.Seed(time.Now().UnixNano())
+	p := problem.FromRouteRequest(req)
+	return Solve(p).ToRouteResponse(p)
+}
+
+// Solve runs the GA over the first vehicle of p using p.SolverParams
+func Solve(p *problem.Problem) problem.Solution {
+	return SolveWithParams(p, ParamsFrom(p.SolverParams))
+}
 
-	// Combine Start, Waypoints, End into a single list of points for the GA to optimize (excluding start/end fixed positions if we want closed loop,
-	// but here we treat it as Open TSP: Start -> [Visit All] -> End)
-	// Actually, for standard TSP, we want to optimize the order of waypoints.
-	// Start and End are fixed.
+// SolveWithParams runs the GA over the first vehicle of p. Its start and end
+// nodes are fixed (Open TSP: Start -> [Visit All] -> End); every other node is
+// a waypoint whose order is optimized.
+func SolveWithParams(p *problem.Problem, params Params) problem.Solution {
+	return evolve(context.Background(), p, params, newRand(context.Background(), p), nil, nil)
+}
+
+// newRand returns the source a solve draws from, seeded by p's seed
+// parameter or else the clock. The seed goes in ctx's trace, and a replay
+// fixes it there, so a traced solve can be run again as it went.
+func newRand(ctx context.Context, p *problem.Problem) *rand.Rand {
+	seed := time.Now().UnixNano()
+	if v, ok := p.SolverParams["seed"]; ok {
+		seed = int64(v)
+	}
+	return rand.New(rand.NewSource(solver.TraceFrom(ctx).Seed(seed)))
+}
+
+// generationHook runs after each generation is evaluated; it may rewrite
+// tours in place and must leave the population re-sorted
+type generationHook func(gen int, pop *Population, waypoints []int)
+
+// evolve runs the GA drawing from rng, resuming from cp's checkpoint of the
+// same problem and saving one to it every checkpointEvery when cp is not nil.
+// Once ctx is done it stops before the next generation and returns the best
+// tour so far.
+func evolve(ctx context.Context, p *problem.Problem, params Params, rng *rand.Rand, hook generationHook, cp solver.Checkpointer) problem.Solution {
+	v := p.Vehicles[0]
+	waypoints := make([]int, 0, len(p.Nodes))
+	for i := range p.Nodes {
+		if i != v.Start && i != v.End {
+			waypoints = append(waypoints, i)
+		}
+	}
 
-	waypoints := req.Waypoints
 	n := len(waypoints)
 	if n == 0 {
-		return models.OptimizationResponse{
-			Route:       []models.Location{req.Start, req.End},
-			TotalDistKm: haversine(req.Start, req.End),
+		dist := p.Cost(v.Start, v.End)
+		return problem.Solution{
+			Routes:     []problem.Route{{Vehicle: 0, Stops: []int{v.Start, v.End}, DistanceKm: dist}},
+			DistanceKm: dist,
 		}
 	}
 
 	// Initialize Population
 	// Each individual is a permutation of indices 0 to n-1 (representing waypoints)
-	pop := initializePopulation(n, PopulationSize)
+	pop := initializePopulation(rng, n, params.PopulationSize)
+	rate := params.MutationRate
+	first := 0
+	name := checkpointName(p, params, waypoints)
+	if c, ok := loadCheckpoint(cp, name, n, params.PopulationSize); ok {
+		pop, rate, first = c.population(), c.MutationRate, c.Generation+1
+	}
 
 	// Evaluate initial fitness
-	evaluatePopulation(pop, req.Start, req.End, waypoints)
+	evaluatePopulation(pop, p, v, waypoints)
+	saved := time.Now()
 
 	// Evolution Loop
-	for g := 0; g < Generations; g++ {
-		newTours := make([]Tour, 0, PopulationSize)
+	for g := first; g < params.Generations && ctx.Err() == nil; g++ {
+		newTours := make([]Tour, 0, params.PopulationSize)
 
 		// Elitism: Keep the best one
 		newTours = append(newTours, pop.Tours[0])
 
-		for len(newTours) < PopulationSize {
+		for len(newTours) < params.PopulationSize {
 			// Selection
-			p1 := tournamentSelection(pop)
-			p2 := tournamentSelection(pop)
+			p1 := tournamentSelection(rng, pop, params.TournamentSize)
+			p2 := tournamentSelection(rng, pop, params.TournamentSize)
 
 			// Crossover
-			childPath := orderedCrossover(p1.Path, p2.Path)
+			childPath := orderedCrossover(rng, p1.Path, p2.Path)
 
 			// Mutation
-			if rand.Float64() < MutationRate {
-				mutate(childPath)
+			if rng.Float64() < rate {
+				mutate(rng, childPath)
 			}
 
 			newTours = append(newTours, Tour{Path: childPath})
 		}
 
 		pop.Tours = newTours
-		evaluatePopulation(pop, req.Start, req.End, waypoints)
+		evaluatePopulation(pop, p, v, waypoints)
+		rate = adaptiveMutationRate(params.MutationRate, removeDuplicates(rng, pop, p, v, waypoints))
+
+		if hook != nil {
+			hook(g, pop, waypoints)
+		}
+		if cp != nil && time.Since(saved) >= checkpointEvery {
+			if err := cp.Save(name, newCheckpoint(g, rate, pop)); err != nil {
+				cp = nil // Lost to another worker, or unwritable; carry on without
+			}
+			saved = time.Now()
+		}
 	}
 
 	// Best tour is at index 0 (sorted)
 	bestTour := pop.Tours[0]
 
 	// Construct Result
-	optimizedRoute := make([]models.Location, 0, n+2)
-	optimizedRoute = append(optimizedRoute, req.Start)
+	stops := make([]int, 0, n+2)
+	stops = append(stops, v.Start)
 	for _, idx := range bestTour.Path {
-		optimizedRoute = append(optimizedRoute, waypoints[idx])
+		stops = append(stops, waypoints[idx])
 	}
-	optimizedRoute = append(optimizedRoute, req.End)
+	stops = append(stops, v.End)
 
-	return models.OptimizationResponse{
-		Route:       optimizedRoute,
-		TotalDistKm: bestTour.Distance,
+	return problem.Solution{
+		Routes:     []problem.Route{{Vehicle: 0, Stops: stops, DistanceKm: bestTour.Distance}},
+		DistanceKm: bestTour.Distance,
 	}
 }
 
-func initializePopulation(n int, size int) *Population {
+func initializePopulation(rng *rand.Rand, n int, size int) *Population {
 	pop := &Population{Tours: make([]Tour, size)}
 	base := make([]int, n)
 	for i := 0; i < n; i++ {
@@ -104,15 +215,15 @@ func initializePopulation(n int, size int) *Population {
 	for i := 0; i < size; i++ {
 		perm := make([]int, n)
 		copy(perm, base)
-		rand.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+		rng.Shuffle(n, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
 		pop.Tours[i] = Tour{Path: perm}
 	}
 	return pop
 }
 
-func evaluatePopulation(pop *Population, start, end models.Location, waypoints []models.Location) {
+func evaluatePopulation(pop *Population, p *problem.Problem, v problem.Vehicle, waypoints []int) {
 	for i := range pop.Tours {
-		pop.Tours[i].Distance = calculateDistance(pop.Tours[i].Path, start, end, waypoints)
+		pop.Tours[i].Distance = calculateDistance(pop.Tours[i].Path, p, v, waypoints)
 	}
 	// Sort by distance (asc)
 	sort.Slice(pop.Tours, func(i, j int) bool {
@@ -120,39 +231,67 @@ func evaluatePopulation(pop *Population, start, end models.Location, waypoints [
 	})
 }
 
-func calculateDistance(path []int, start, end models.Location, waypoints []models.Location) float64 {
+// removeDuplicates replaces repeated tours with random immigrants and
+// returns the population's diversity (share of distinct tours) before the
+// replacement. The population must be sorted, so the first copy kept is
+// the fittest.
+func removeDuplicates(rng *rand.Rand, pop *Population, p *problem.Problem, v problem.Vehicle, waypoints []int) float64 {
+	seen := make(map[string]bool, len(pop.Tours))
+	replaced := 0
+	for i := range pop.Tours {
+		key := pathKey(pop.Tours[i].Path)
+		if !seen[key] {
+			seen[key] = true
+			continue
+		}
+		path := rng.Perm(len(waypoints))
+		pop.Tours[i] = Tour{Path: path, Distance: calculateDistance(path, p, v, waypoints)}
+		replaced++
+	}
+	if replaced > 0 {
+		sort.Slice(pop.Tours, func(i, j int) bool {
+			return pop.Tours[i].Distance < pop.Tours[j].Distance
+		})
+	}
+	return float64(len(seen)) / float64(len(pop.Tours))
+}
+
+// adaptiveMutationRate scales base linearly from 1x at MinDiversity up to
+// MaxMutationRate at zero diversity; it never lowers a base already above it
+func adaptiveMutationRate(base, diversity float64) float64 {
+	if diversity >= MinDiversity || base >= MaxMutationRate {
+		return base
+	}
+	return base + (MaxMutationRate-base)*(MinDiversity-diversity)/MinDiversity
+}
+
+func pathKey(path []int) string {
+	b := make([]byte, 0, len(path)*4)
+	for _, idx := range path {
+		b = strconv.AppendInt(b, int64(idx), 10)
+		b = append(b, ',')
+	}
+	return string(b)
+}
+
+func calculateDistance(path []int, p *problem.Problem, v problem.Vehicle, waypoints []int) float64 {
 	dist := 0.0
-	current := start
+	current := v.Start
 
 	for _, idx := range path {
 		next := waypoints[idx]
-		dist += haversine(current, next)
+		dist += p.Cost(current, next)
 		current = next
 	}
 
-	dist += haversine(current, end)
+	dist += p.Cost(current, v.End)
 	return dist
 }
 
-func haversine(p1, p2 models.Location) float64 {
-	const R = 6371 // Earth radius in km
-	dLat := (p2.Lat - p1.Lat) * (math.Pi / 180.0)
-	dLon := (p2.Lng - p1.Lng) * (math.Pi / 180.0)
-
-	lat1 := p1.Lat * (math.Pi / 180.0)
-	lat2 := p2.Lat * (math.Pi / 180.0)
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Sin(dLon/2)*math.Sin(dLon/2)*math.Cos(lat1)*math.Cos(lat2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	return R * c
-}
-
-func tournamentSelection(pop *Population) Tour {
-	best := pop.Tours[rand.Intn(len(pop.Tours))]
-	for i := 0; i < TournamentSize; i++ {
-		contestant := pop.Tours[rand.Intn(len(pop.Tours))]
+func tournamentSelection(rng *rand.Rand, pop *Population, size int) Tour {
+	best := pop.Tours[rng.Intn(len(pop.Tours))]
+	for i := 0; i < size; i++ {
+		contestant := pop.Tours[rng.Intn(len(pop.Tours))]
 		if contestant.Distance < best.Distance {
 			best = contestant
 		}
@@ -161,10 +300,10 @@ func tournamentSelection(pop *Population) Tour {
 }
 
 // Ordered Crossover (OX1)
-func orderedCrossover(p1, p2 []int) []int {
+func orderedCrossover(rng *rand.Rand, p1, p2 []int) []int {
 	size := len(p1)
-	start := rand.Intn(size)
-	end := rand.Intn(size)
+	start := rng.Intn(size)
+	end := rng.Intn(size)
 	if start > end {
 		start, end = end, start
 	}
@@ -199,9 +338,9 @@ func orderedCrossover(p1, p2 []int) []int {
 	return child
 }
 
-func mutate(path []int) {
-	i := rand.Intn(len(path))
-	j := rand.Intn(len(path))
+func mutate(rng *rand.Rand, path []int) {
+	i := rng.Intn(len(path))
+	j := rng.Intn(len(path))
 	path[i], path[j] = path[j], path[i]
 }
 
@@ -0,0 +1,163 @@
+package genetic
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"slices"
+	"testing"
+	"time"
+)
+
+// maxGapPct is how far above the known optimum the GA may finish. The GA is
+// stochastic, so this is a regression bound rather than an exactness check.
+const maxGapPct = 30
+
+func TestGeneticWithinOptimalityGap(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			resp := SolveTSPGenetic(inst.Request)
+
+			if resp.TotalDistKm < inst.OptimumKm-1e-6 {
+				t.Fatalf("distance %.4f beats the known optimum %.4f", resp.TotalDistKm, inst.OptimumKm)
+			}
+			gap := (resp.TotalDistKm - inst.OptimumKm) / inst.OptimumKm * 100
+			if gap > maxGapPct {
+				t.Errorf("gap %.2f%% exceeds %d%% (got %.2f, optimum %.2f)", gap, maxGapPct, resp.TotalDistKm, inst.OptimumKm)
+			}
+			if want := len(inst.Request.Waypoints) + 2; len(resp.Route) != want {
+				t.Errorf("route has %d stops, want %d", len(resp.Route), want)
+			}
+		})
+	}
+}
+
+// The memetic hybrid polishes with local search, so it must land much closer
+// to the optimum than the plain GA bound
+func TestMemeticWithinOptimalityGap(t *testing.T) {
+	const memeticGapPct = 5
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			sol := SolveMemetic(p)
+
+			if sol.DistanceKm < inst.OptimumKm-1e-6 {
+				t.Fatalf("distance %.4f beats the known optimum %.4f", sol.DistanceKm, inst.OptimumKm)
+			}
+			if gap := (sol.DistanceKm - inst.OptimumKm) / inst.OptimumKm * 100; gap > memeticGapPct {
+				t.Errorf("gap %.2f%% exceeds %d%%", gap, memeticGapPct)
+			}
+		})
+	}
+}
+
+// memoryCheckpoints keeps checkpoints as JSON, like a job store would
+type memoryCheckpoints map[string][]byte
+
+func (m memoryCheckpoints) Save(name string, v any) error {
+	body, err := json.Marshal(v)
+	m[name] = body
+	return err
+}
+
+func (m memoryCheckpoints) Load(name string, v any) (bool, error) {
+	body, ok := m[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(body, v)
+}
+
+func TestResumesFromCheckpoint(t *testing.T) {
+	defer func(d time.Duration) { checkpointEvery = d }(checkpointEvery)
+	checkpointEvery = 0
+
+	p := problem.FromRouteRequest(fixtures.RouteInstances()[0].Request)
+	params := Params{PopulationSize: 10, Generations: 20, MutationRate: 0.05, TournamentSize: 3}
+	cp := memoryCheckpoints{}
+	evolve(context.Background(), p, params, rand.New(rand.NewSource(1)), nil, cp)
+	if len(cp) != 1 {
+		t.Fatalf("saved %d checkpoints, want 1", len(cp))
+	}
+	var name string
+	var c checkpoint
+	for name = range cp {
+		cp.Load(name, &c)
+	}
+	if c.Generation != params.Generations-1 || len(c.Paths) != params.PopulationSize {
+		t.Fatalf("checkpoint after generation %d of %d tours", c.Generation, len(c.Paths))
+	}
+
+	// A run that had finished every generation resumes to its saved best
+	identity := make([]int, len(c.Paths[0]))
+	for i := range identity {
+		identity[i] = i
+	}
+	for i := range c.Paths {
+		c.Paths[i] = identity
+	}
+	cp.Save(name, c)
+	sol := evolve(context.Background(), p, params, rand.New(rand.NewSource(1)), nil, cp)
+	for i, stop := range sol.Routes[0].Stops[1 : len(identity)+1] {
+		if stop != i+1 {
+			t.Fatalf("resumed route %v, want the checkpoint's tour", sol.Routes[0].Stops)
+		}
+	}
+
+	// Another problem's checkpoint is not resumed
+	params.PopulationSize = 12
+	if _, ok := loadCheckpoint(cp, checkpointName(p, params, identity), len(identity), 12); ok {
+		t.Error("resumed a checkpoint saved with other parameters")
+	}
+}
+
+func TestTracedSeedReplays(t *testing.T) {
+	p := problem.FromRouteRequest(fixtures.RouteInstances()[2].Request)
+	p.SolverParams = map[string]float64{"generations": 30, "population_size": 20}
+	trace := solver.NewTrace(false)
+	first, _ := gaSolver{}.Solve(solver.WithTrace(context.Background(), trace), p)
+	seeds := trace.Report().Seeds
+	if len(seeds) != 1 {
+		t.Fatalf("traced seeds %v", seeds)
+	}
+
+	replay := solver.NewTrace(false)
+	replay.FixSeeds(seeds...)
+	again, _ := gaSolver{}.Solve(solver.WithTrace(context.Background(), replay), p)
+	if !slices.Equal(first.Routes[0].Stops, again.Routes[0].Stops) {
+		t.Errorf("replayed route %v, traced %v", again.Routes[0].Stops, first.Routes[0].Stops)
+	}
+}
+
+// A large instance must come back soon after the request deadline, with
+// the best tour found so far
+func TestStopsAtDeadline(t *testing.T) {
+	req, err := generator.RouteRequest(generator.Config{Size: 1500, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"genetic", "memetic", "two-opt"} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := solver.Get(name)
+			p := problem.FromRouteRequest(req)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			sol, err := s.Solve(ctx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if took := time.Since(start); took > 2*time.Second {
+				t.Errorf("returned %v after a 200ms deadline", took)
+			}
+			if got, want := len(sol.Routes[0].Stops), len(req.Waypoints)+2; got != want {
+				t.Errorf("route has %d stops, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package genetic
+
+import (
+	"context"
+	"math/rand"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"sort"
+)
+
+// Memetic defaults: polish the elite every few generations so good building
+// blocks spread through crossover
+const (
+	LocalSearchInterval = 25
+	LocalSearchElites   = 5
+)
+
+// SolveMemetic runs the GA with periodic 2-opt/Or-opt improvement of the
+// elite tours, and a final polish of the best one. Extra parameters:
+// local_search_interval and local_search_elites.
+func SolveMemetic(p *problem.Problem) problem.Solution {
+	return solveMemetic(context.Background(), p, newRand(context.Background(), p), nil)
+}
+
+func solveMemetic(ctx context.Context, p *problem.Problem, rng *rand.Rand, cp solver.Checkpointer) problem.Solution {
+	params := ParamsFrom(p.SolverParams)
+	interval, elites := LocalSearchInterval, LocalSearchElites
+	if v := int(p.SolverParams["local_search_interval"]); v > 0 {
+		interval = v
+	}
+	if v := int(p.SolverParams["local_search_elites"]); v > 0 {
+		elites = v
+	}
+
+	v := p.Vehicles[0]
+	hook := func(gen int, pop *Population, waypoints []int) {
+		if gen%interval != 0 {
+			return
+		}
+		for i := 0; i < min(elites, len(pop.Tours)); i++ {
+			improveTour(ctx, p, v, waypoints, &pop.Tours[i])
+		}
+		sort.Slice(pop.Tours, func(i, j int) bool {
+			return pop.Tours[i].Distance < pop.Tours[j].Distance
+		})
+	}
+
+	sol := evolve(ctx, p, params, rng, hook, cp)
+	if len(sol.Routes) > 0 {
+		stops := sol.Routes[0].Stops
+		solver.ImproveRouteContext(ctx, p, stops)
+		dist := solver.RouteDistance(p, stops)
+		sol.Routes[0].DistanceKm = dist
+		sol.DistanceKm = dist
+	}
+	return sol
+}
+
+// improveTour runs local search on a tour, mapping its waypoint-position
+// path to node stops and back
+func improveTour(ctx context.Context, p *problem.Problem, v problem.Vehicle, waypoints []int, t *Tour) {
+	pos := make(map[int]int, len(waypoints))
+	stops := make([]int, 0, len(t.Path)+2)
+	stops = append(stops, v.Start)
+	for _, idx := range t.Path {
+		stops = append(stops, waypoints[idx])
+		pos[waypoints[idx]] = idx
+	}
+	stops = append(stops, v.End)
+
+	solver.ImproveRouteContext(ctx, p, stops)
+
+	for i, node := range stops[1 : len(stops)-1] {
+		t.Path[i] = pos[node]
+	}
+	t.Distance = solver.RouteDistance(p, stops)
+}
@@ -0,0 +1,44 @@
+package genetic
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+)
+
+func init() {
+	solver.Register(gaSolver{})
+	solver.Register(memeticSolver{})
+}
+
+type gaSolver struct{}
+
+func (gaSolver) Name() string { return "genetic" }
+func (gaSolver) Capabilities() solver.Capabilities {
+	return solver.CapRouting | solver.CapMatrix
+}
+
+func (gaSolver) CPUIntensive() bool { return true }
+
+func (gaSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, solver.ErrUnsupportedProblem
+	}
+	return evolve(ctx, p, ParamsFrom(p.SolverParams), newRand(ctx, p), nil, solver.CheckpointerFrom(ctx)), nil
+}
+
+type memeticSolver struct{}
+
+func (memeticSolver) Name() string { return "memetic" }
+func (memeticSolver) Capabilities() solver.Capabilities {
+	return solver.CapRouting | solver.CapMatrix
+}
+
+func (memeticSolver) CPUIntensive() bool { return true }
+
+func (memeticSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	if p.Type != problem.TypeRouting {
+		return problem.Solution{}, solver.ErrUnsupportedProblem
+	}
+	return solveMemetic(ctx, p, newRand(ctx, p), solver.CheckpointerFrom(ctx)), nil
+}
@@ -0,0 +1,54 @@
+package genetic
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// permPair generates two random permutations of the same size
+type permPair struct {
+	P1, P2 []int
+}
+
+func (permPair) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 1 + r.Intn(size+1)
+	return reflect.ValueOf(permPair{P1: r.Perm(n), P2: r.Perm(n)})
+}
+
+func isPermutation(path []int, n int) bool {
+	if len(path) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, v := range path {
+		if v < 0 || v >= n || seen[v] {
+			return false
+		}
+		seen[v] = true
+	}
+	return true
+}
+
+func TestCrossoverYieldsPermutation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	prop := func(pp permPair) bool {
+		return isPermutation(orderedCrossover(rng, pp.P1, pp.P2), len(pp.P1))
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMutationPreservesPermutation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	prop := func(pp permPair) bool {
+		path := append([]int(nil), pp.P1...)
+		mutate(rng, path)
+		return isPermutation(path, len(pp.P1))
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
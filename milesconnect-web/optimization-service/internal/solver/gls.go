@@ -0,0 +1,208 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"time"
+)
+
+// Guided local search defaults; time_limit_ms overrides the budget
+const (
+	GLSTimeLimit  = 200 * time.Millisecond
+	glsMaxRounds  = 1000 // Small instances converge long before the budget
+	glsAlpha      = 0.3  // Penalty weight relative to the average edge length
+	glsCandidates = 10   // Nearest neighbours each node may be reconnected to
+)
+
+// glsEdge keys an edge penalty; symmetric problems store i < j
+type glsEdge struct{ i, j int }
+
+// GuidedLocalSearch escapes 2-opt local optima by penalizing edges of the
+// current tour that are long and have rarely been penalized before, then
+// re-optimizing around them under distance plus penalties. The shortest tour
+// seen by true distance is returned once the time budget or round limit is
+// spent. Only the nearest-neighbour start and candidate lists (O(n^2), or
+// O(n log n) with the spatial index on large instances) run outside the
+// budget.
+func GuidedLocalSearch(ctx context.Context, p *problem.Problem) problem.Solution {
+	deadline := time.Now().Add(timeLimit(p, GLSTimeLimit))
+	asymmetric := p.Matrix != nil
+
+	stops := append([]int(nil), NearestNeighbor(p).Routes[0].Stops...)
+	if len(stops) < 4 {
+		return singleRoute(p, stops)
+	}
+	var near [][]int
+	if !asymmetric {
+		near = nearestCandidates(p, glsCandidates)
+	}
+	localSearch := func(dist func(i, j int) float64, active []int) {
+		if asymmetric {
+			twoOptWith(dist, true, deadline, stops)
+			return
+		}
+		focusedTwoOpt(dist, near, deadline, stops, active)
+	}
+
+	localSearch(p.Distance, stops)
+	best := append([]int(nil), stops...)
+	bestDist := RouteDistance(p, best)
+
+	key := func(i, j int) glsEdge {
+		if !asymmetric && j < i {
+			i, j = j, i
+		}
+		return glsEdge{i, j}
+	}
+	penalty := map[glsEdge]int{}
+	lambda := glsAlpha * bestDist / float64(len(stops)-1)
+	augmented := func(i, j int) float64 {
+		return p.Cost(i, j) + lambda*float64(penalty[key(i, j)])
+	}
+
+	trace := TraceFrom(ctx)
+	trace.Notef("gls: %d stops, local optimum %.1f km", len(stops)-2, bestDist)
+	round := 0
+	for ; round < glsMaxRounds; round++ {
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			break
+		}
+
+		// Penalize the edges with the highest utility: long, and not yet
+		// penalized often. Their endpoints seed the next local search.
+		maxUtil := 0.0
+		for k := 0; k+1 < len(stops); k++ {
+			e := key(stops[k], stops[k+1])
+			if u := p.Cost(stops[k], stops[k+1]) / float64(1+penalty[e]); u > maxUtil {
+				maxUtil = u
+			}
+		}
+		var active []int
+		for k := 0; k+1 < len(stops); k++ {
+			e := key(stops[k], stops[k+1])
+			if p.Cost(stops[k], stops[k+1])/float64(1+penalty[e]) >= maxUtil-1e-12 {
+				penalty[e]++
+				active = append(active, stops[k], stops[k+1])
+			}
+		}
+
+		localSearch(augmented, active)
+		if d := RouteDistance(p, stops); d < bestDist-1e-9 {
+			best, bestDist = append(best[:0], stops...), d
+			trace.Verbosef("gls: round %d: best %.1f km", round, d)
+		}
+	}
+
+	if !asymmetric {
+		focusedTwoOpt(p.Distance, near, deadline, best, best)
+	}
+	trace.Notef("gls: %d rounds, best %.1f km", round, RouteDistance(p, best))
+	return singleRoute(p, best)
+}
+
+// nearestCandidates lists, for every node, its k nearest other nodes, in
+// O(n log n) through a k-d tree when the instance is large and geometric
+func nearestCandidates(p *problem.Problem, k int) [][]int {
+	near := make([][]int, len(p.Nodes))
+	if useSpatialIndex(p) {
+		index := nodeIndex(p)
+		for i, n := range p.Nodes {
+			// One extra for the node itself, which comes first unless another
+			// node shares its location
+			list := slices.DeleteFunc(index.Nearest(n.Location, k+1), func(j int) bool { return j == i })
+			near[i] = list[:min(k, len(list))]
+		}
+		return near
+	}
+
+	dists := make([]float64, 0, k+1)
+	for i := range p.Nodes {
+		list := make([]int, 0, k+1)
+		dists = dists[:0]
+		for j := range p.Nodes {
+			if j == i {
+				continue
+			}
+			// Insertion into a short sorted list beats sorting every row
+			d := p.Cost(i, j)
+			if len(list) == k && d >= dists[k-1] {
+				continue
+			}
+			at := len(list)
+			for at > 0 && dists[at-1] > d {
+				at--
+			}
+			list = append(list[:at], append([]int{j}, list[at:]...)...)
+			dists = append(dists[:at], append([]float64{d}, dists[at:]...)...)
+			if len(list) > k {
+				list, dists = list[:k], dists[:k]
+			}
+		}
+		near[i] = list
+	}
+	return near
+}
+
+// focusedTwoOpt is 2-opt for symmetric costs that only re-examines active
+// nodes ("don't look bits") and only tries to connect a node to its
+// candidate neighbours. The endpoints of each applied move become active
+// again. The first and last stops stay fixed.
+func focusedTwoOpt(dist func(i, j int) float64, near [][]int, deadline time.Time, stops []int, active []int) {
+	n := len(stops)
+	pos := make([]int, len(near))
+	for k, s := range stops {
+		pos[s] = k
+	}
+	queued := make([]bool, len(near))
+	queue := make([]int, 0, len(active))
+	push := func(s int) {
+		if !queued[s] {
+			queued[s] = true
+			queue = append(queue, s)
+		}
+	}
+	for _, s := range active {
+		push(s)
+	}
+
+	// try reverses stops[x+1..y] when that shortens the tour
+	try := func(e, f int) bool {
+		x, y := min(e, f), max(e, f)
+		if x < 0 || y > n-2 || y-x < 2 {
+			return false
+		}
+		a, b, c, d := stops[x], stops[x+1], stops[y], stops[y+1]
+		if dist(a, c)+dist(b, d)-dist(a, b)-dist(c, d) >= -1e-9 {
+			return false
+		}
+		reverse(stops[x+1 : y+1])
+		for k := x + 1; k <= y; k++ {
+			pos[stops[k]] = k
+		}
+		push(a)
+		push(b)
+		push(c)
+		push(d)
+		return true
+	}
+
+	for len(queue) > 0 {
+		if time.Now().After(deadline) {
+			return
+		}
+		s := queue[0]
+		queue = queue[1:]
+		queued[s] = false
+
+		// Connect s to a candidate t, either replacing the edges leaving
+		// both or the edges entering both
+		for _, t := range near[s] {
+			i, j := pos[s], pos[t]
+			if try(i, j) || try(i-1, j-1) {
+				push(s)
+				break
+			}
+		}
+	}
+}
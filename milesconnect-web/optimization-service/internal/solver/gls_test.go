@@ -0,0 +1,64 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+	"time"
+)
+
+// glsGapPct bounds how far above the known optimum GLS may finish on the
+// small fixtures, which it fully explores within its round limit
+const glsGapPct = 2
+
+func TestGuidedLocalSearchNearOptimum(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			sol := GuidedLocalSearch(context.Background(), p)
+
+			if sol.DistanceKm < inst.OptimumKm-1e-6 {
+				t.Fatalf("distance %.4f beats the known optimum %.4f", sol.DistanceKm, inst.OptimumKm)
+			}
+			if gap := (sol.DistanceKm - inst.OptimumKm) / inst.OptimumKm * 100; gap > glsGapPct {
+				t.Errorf("gap %.2f%% exceeds %d%%", gap, glsGapPct)
+			}
+		})
+	}
+}
+
+func TestGuidedLocalSearchBeatsTwoOpt(t *testing.T) {
+	req, err := generator.RouteRequest(generator.Config{Size: 150, Seed: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromRouteRequest(req)
+	p.SolverParams = map[string]float64{"time_limit_ms": 1000}
+
+	two := TwoOpt(p, NearestNeighbor(p))
+	gls := GuidedLocalSearch(context.Background(), p)
+	if stops := gls.Routes[0].Stops; len(stops) != len(p.Nodes) || stops[0] != 0 || stops[len(stops)-1] != len(p.Nodes)-1 {
+		t.Fatalf("route %v does not visit every node between the endpoints", stops)
+	}
+	if gls.DistanceKm > two.DistanceKm+1e-6 {
+		t.Errorf("GLS %.1f km is worse than 2-opt %.1f km", gls.DistanceKm, two.DistanceKm)
+	}
+}
+
+func TestGuidedLocalSearchKeepsBudget(t *testing.T) {
+	req, err := generator.RouteRequest(generator.Config{Size: 800, Seed: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromRouteRequest(req)
+	p.SolverParams = map[string]float64{"time_limit_ms": 20}
+
+	start := time.Now()
+	GuidedLocalSearch(context.Background(), p)
+	// Construction is outside the budget; allow generously for it
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v with a 20ms budget", elapsed)
+	}
+}
@@ -3,78 +3,84 @@ package solver
 import (
 	"math"
 	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
 	"sort"
 )
 
 // OptimizeFleetAllocation solves the fleet assignment problem using Best Fit Decreasing
 func OptimizeFleetAllocation(req models.LoadRequest) models.LoadResponse {
+	p := problem.FromLoadRequest(req)
+	return BestFitDecreasing(p).ToLoadResponse(p)
+}
+
+// BestFitDecreasing assigns node demands to vehicles, heaviest first
+func BestFitDecreasing(p *problem.Problem) problem.Solution {
 	// 1. Sort shipments by weight (Descending) - heavier items first are harder to place
-	shipments := make([]models.ShipmentInfo, len(req.Shipments))
-	copy(shipments, req.Shipments)
-	sort.Slice(shipments, func(i, j int) bool {
-		return shipments[i].WeightKg > shipments[j].WeightKg
+	order := make([]int, len(p.Nodes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return p.Nodes[order[i]].DemandKg > p.Nodes[order[j]].DemandKg
 	})
 
 	// Initialize vehicles
-	// We create a map to track current state
-	type VehicleState struct {
-		Info     models.VehicleInfo
-		LoadedKg float64
-		Assigned []string
-	}
-
-	vStates := make([]*VehicleState, len(req.Vehicles))
-	for i, v := range req.Vehicles {
-		vStates[i] = &VehicleState{
-			Info:     v,
-			LoadedKg: v.CurrentLoad,
-			Assigned: []string{},
-		}
+	routes := make([]problem.Route, len(p.Vehicles))
+	for i, v := range p.Vehicles {
+		routes[i] = problem.Route{Vehicle: i, Stops: []int{}, LoadKg: v.InitialLoadKg}
 	}
 
-	var unassigned []string
+	sol := problem.Solution{}
 
 	// 2. Iterate through shipments and find Best Fit vehicle
-	for _, s := range shipments {
+	// Late penalty is minimized first; remaining capacity breaks ties.
+	for _, n := range order {
+		s := p.Nodes[n]
 		bestIdx := -1
+		minLate := math.MaxFloat64
 		minRemaining := math.MaxFloat64
 
-		for i, v := range vStates {
-			remaining := v.Info.CapacityKg - (v.LoadedKg + s.WeightKg)
+		for i, v := range p.Vehicles {
+			remaining := v.CapacityKg - (routes[i].LoadKg + s.DemandKg)
+			if remaining < 0 || !p.Compatible(v, n) {
+				continue
+			}
 
-			// If it fits and is tighter fit than current best
-			if remaining >= 0 && remaining < minRemaining {
+			late := LatePenalty(s, v)
+			if late < minLate || (late == minLate && remaining < minRemaining) {
+				minLate = late
 				minRemaining = remaining
 				bestIdx = i
 			}
 		}
 
+		// Dropping is cheaper than delivering late
+		if bestIdx != -1 && s.DropPenalty > 0 && minLate > s.DropPenalty {
+			sol.Dropped = append(sol.Dropped, n)
+			bestIdx = -1
+		}
+
 		if bestIdx != -1 {
 			// Assign to vehicle
-			vStates[bestIdx].LoadedKg += s.WeightKg
-			vStates[bestIdx].Assigned = append(vStates[bestIdx].Assigned, s.ID)
+			routes[bestIdx].LoadKg += s.DemandKg
+			routes[bestIdx].Stops = append(routes[bestIdx].Stops, n)
+			routes[bestIdx].LatePenalty += minLate
+			sol.PenaltyCost += minLate
 		} else {
-			// Cannot fit anywhere
-			unassigned = append(unassigned, s.ID)
+			// Cannot fit anywhere (or dropped)
+			sol.Unassigned = append(sol.Unassigned, n)
+			sol.PenaltyCost += s.DropPenalty
 		}
 	}
 
-	// 3. Construct response
-	allocations := []models.Allocation{}
-	for _, v := range vStates {
-		if len(v.Assigned) > 0 {
-			utilization := (v.LoadedKg / v.Info.CapacityKg) * 100
-			allocations = append(allocations, models.Allocation{
-				VehicleID:      v.Info.ID,
-				ShipmentIDs:    v.Assigned,
-				TotalWeight:    v.LoadedKg,
-				UtilizationPct: math.Round(utilization*100) / 100,
-			})
-		}
-	}
+	sol.Routes = routes
+	return sol
+}
 
-	return models.LoadResponse{
-		Allocations: allocations,
-		Unassigned:  unassigned,
+// LatePenalty is the cost of carrying shipment s on vehicle v given its departure time
+func LatePenalty(s problem.Node, v problem.Vehicle) float64 {
+	if s.LatePenaltyPerHour <= 0 || v.DepartHours <= s.DeadlineHours {
+		return 0
 	}
+	return (v.DepartHours - s.DeadlineHours) * s.LatePenaltyPerHour
 }
@@ -0,0 +1,38 @@
+package solver
+
+import (
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func TestFleetAllocationFixtures(t *testing.T) {
+	for _, inst := range fixtures.LoadInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			resp := OptimizeFleetAllocation(inst.Request)
+			if got := len(resp.Unassigned); got != inst.WantUnassigned {
+				t.Errorf("unassigned = %v, want %d", resp.Unassigned, inst.WantUnassigned)
+			}
+		})
+	}
+}
+
+func TestFleetAllocationPenalties(t *testing.T) {
+	req := models.LoadRequest{
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 100, DepartHours: 5}},
+		Shipments: []models.ShipmentInfo{
+			// 3h late at 100/h = 300 < 1000 drop: carry it late
+			{ID: "keep", WeightKg: 10, DeadlineHours: 2, LatePenaltyPerHour: 100, DropPenalty: 1000},
+			// 5h late at 5000/h far exceeds the 200 drop penalty
+			{ID: "drop", WeightKg: 10, LatePenaltyPerHour: 5000, DropPenalty: 200},
+		},
+	}
+
+	resp := OptimizeFleetAllocation(req)
+	if len(resp.Dropped) != 1 || resp.Dropped[0] != "drop" {
+		t.Fatalf("dropped = %v, want [drop]", resp.Dropped)
+	}
+	if resp.PenaltyCost != 500 {
+		t.Errorf("penalty cost = %v, want 500", resp.PenaltyCost)
+	}
+}
@@ -0,0 +1,88 @@
+package milp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Solver statuses in the service protocol
+const (
+	StatusOptimal    = "optimal"
+	StatusFeasible   = "feasible"
+	StatusInfeasible = "infeasible"
+)
+
+// Client talks to a MILP solver service (see the package doc)
+type Client struct {
+	URL       string
+	TimeLimit time.Duration
+	HTTP      *http.Client
+}
+
+// NewClient returns a client whose HTTP timeout leaves the solver its full
+// time limit plus a margin for transfer
+func NewClient(url string, timeLimit time.Duration) *Client {
+	return &Client{
+		URL:       url,
+		TimeLimit: timeLimit,
+		HTTP:      &http.Client{Timeout: timeLimit + 10*time.Second},
+	}
+}
+
+type solveRequest struct {
+	Format           string  `json:"format"`
+	Model            string  `json:"model"`
+	TimeLimitSeconds float64 `json:"time_limit_seconds"`
+}
+
+// Result is the solver service's answer
+type Result struct {
+	Status    string             `json:"status"`
+	Objective float64            `json:"objective"`
+	Values    map[string]float64 `json:"values"`
+	Message   string             `json:"message,omitempty"`
+}
+
+// Solve sends m to the service and returns its result. Statuses other than
+// optimal or feasible are reported as errors.
+func (c *Client) Solve(ctx context.Context, m *Model) (Result, error) {
+	var lp strings.Builder
+	if err := m.WriteLP(&lp); err != nil {
+		return Result{}, err
+	}
+	body, err := json.Marshal(solveRequest{Format: "lp", Model: lp.String(), TimeLimitSeconds: c.TimeLimit.Seconds()})
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("milp: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("milp: solver service returned %s", resp.Status)
+	}
+	var res Result
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("milp: decoding solver response: %w", err)
+	}
+
+	switch res.Status {
+	case StatusOptimal, StatusFeasible:
+		return res, nil
+	default:
+		return res, fmt.Errorf("milp: solver status %q: %s", res.Status, res.Message)
+	}
+}
@@ -0,0 +1,228 @@
+package milp
+
+import (
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+)
+
+// Size limits beyond which the formulations get too big to prove optimal
+// in a request's time
+const (
+	MaxRoutingWaypoints = 40
+	MaxAllocationPairs  = 20000 // Shipments x vehicles
+)
+
+// decoder turns solver variable values back into a solution
+type decoder func(values map[string]float64) (problem.Solution, error)
+
+// errBadSolution is returned when the solver's values do not decode into a
+// valid solution, which points at a solver or protocol bug
+var errBadSolution = errors.New("milp: solver returned an invalid solution")
+
+func set(values map[string]float64, v string) bool { return values[v] > 0.5 }
+
+func xVar(i, j int) string { return fmt.Sprintf("x_%d_%d", i, j) }
+func uVar(i int) string    { return fmt.Sprintf("u_%d", i) }
+func yVar(s, v int) string { return fmt.Sprintf("y_%d_%d", s, v) }
+func zVar(s int) string    { return fmt.Sprintf("z_%d", s) }
+
+// formulateRouting models the single-vehicle open path from the start node
+// through every waypoint to the end node. x_i_j is 1 when the path goes
+// from i to j; Miller-Tucker-Zemlin order variables u_i rule out subtours.
+func formulateRouting(p *problem.Problem) (*Model, decoder, error) {
+	if len(p.Vehicles) != 1 || p.Constraints.Capacity || p.Constraints.TimeWindows {
+		return nil, nil, solver.ErrUnsupportedProblem
+	}
+	v := p.Vehicles[0]
+	if v.Start == v.End {
+		return nil, nil, solver.ErrUnsupportedProblem
+	}
+
+	var waypoints []int
+	for i := range p.Nodes {
+		if i != v.Start && i != v.End {
+			waypoints = append(waypoints, i)
+		}
+	}
+	k := len(waypoints)
+	if k > MaxRoutingWaypoints {
+		return nil, nil, fmt.Errorf("%w: MILP routing handles at most %d waypoints", solver.ErrProblemTooLarge, MaxRoutingWaypoints)
+	}
+
+	m := &Model{Name: "milesconnect routing"}
+	arc := func(i, j int) bool {
+		return i != j && i != v.End && j != v.Start && !(i == v.Start && j == v.End && k > 0)
+	}
+	for i := range p.Nodes {
+		for j := range p.Nodes {
+			if arc(i, j) {
+				m.Objective = append(m.Objective, Term{p.Cost(i, j), xVar(i, j)})
+				m.Binaries = append(m.Binaries, xVar(i, j))
+			}
+		}
+	}
+
+	// Every node but the end is left once; every node but the start is entered once
+	for i := range p.Nodes {
+		var out, in []Term
+		for j := range p.Nodes {
+			if arc(i, j) {
+				out = append(out, Term{1, xVar(i, j)})
+			}
+			if arc(j, i) {
+				in = append(in, Term{1, xVar(j, i)})
+			}
+		}
+		if i != v.End {
+			m.Constraints = append(m.Constraints, Constraint{Name: fmt.Sprintf("out_%d", i), Terms: out, Op: "=", RHS: 1})
+		}
+		if i != v.Start {
+			m.Constraints = append(m.Constraints, Constraint{Name: fmt.Sprintf("in_%d", i), Terms: in, Op: "=", RHS: 1})
+		}
+	}
+
+	// u_i - u_j + k x_i_j <= k - 1 orders the waypoints along the path
+	for _, i := range waypoints {
+		m.Bounds = append(m.Bounds, Bound{Var: uVar(i), Lo: 1, Hi: float64(k)})
+		for _, j := range waypoints {
+			if i != j {
+				m.Constraints = append(m.Constraints, Constraint{
+					Name:  fmt.Sprintf("mtz_%d_%d", i, j),
+					Terms: []Term{{1, uVar(i)}, {-1, uVar(j)}, {float64(k), xVar(i, j)}},
+					Op:    "<=",
+					RHS:   float64(k - 1),
+				})
+			}
+		}
+	}
+
+	decode := func(values map[string]float64) (problem.Solution, error) {
+		stops := []int{v.Start}
+		for cur := v.Start; cur != v.End; {
+			next := -1
+			for j := range p.Nodes {
+				if arc(cur, j) && set(values, xVar(cur, j)) {
+					next = j
+					break
+				}
+			}
+			if next < 0 || len(stops) > len(p.Nodes) {
+				return problem.Solution{}, errBadSolution
+			}
+			stops = append(stops, next)
+			cur = next
+		}
+		if len(stops) != len(p.Nodes) {
+			return problem.Solution{}, errBadSolution
+		}
+		dist := solver.RouteDistance(p, stops)
+		return problem.Solution{
+			Routes:     []problem.Route{{Vehicle: 0, Stops: stops, DistanceKm: dist}},
+			DistanceKm: dist,
+		}, nil
+	}
+	return m, decode, nil
+}
+
+// formulateAllocation models shipment-to-vehicle assignment. y_s_v is 1
+// when shipment s rides on vehicle v and z_s when it is left unassigned.
+// The objective is late penalties plus drop penalties; a shipment without
+// a drop penalty costs more to leave behind than every penalty combined.
+func formulateAllocation(p *problem.Problem) (*Model, decoder, error) {
+	if len(p.Nodes)*len(p.Vehicles) > MaxAllocationPairs {
+		return nil, nil, fmt.Errorf("%w: MILP allocation handles at most %d shipment-vehicle pairs", solver.ErrProblemTooLarge, MaxAllocationPairs)
+	}
+
+	fits := func(s, v int) bool {
+		veh := p.Vehicles[v]
+		return p.Nodes[s].DemandKg <= veh.CapacityKg-veh.InitialLoadKg
+	}
+	mustServe := 1.0
+	for s, n := range p.Nodes {
+		mustServe += n.DropPenalty
+		worst := 0.0
+		for v, veh := range p.Vehicles {
+			if fits(s, v) {
+				worst = max(worst, solver.LatePenalty(n, veh))
+			}
+		}
+		mustServe += worst
+	}
+
+	m := &Model{Name: "milesconnect allocation"}
+	for s, n := range p.Nodes {
+		assign := []Term{{1, zVar(s)}}
+		for v, veh := range p.Vehicles {
+			if fits(s, v) {
+				m.Objective = append(m.Objective, Term{solver.LatePenalty(n, veh), yVar(s, v)})
+				m.Binaries = append(m.Binaries, yVar(s, v))
+				assign = append(assign, Term{1, yVar(s, v)})
+			}
+		}
+		cost := mustServe
+		if n.DropPenalty > 0 {
+			cost = n.DropPenalty
+		}
+		m.Objective = append(m.Objective, Term{cost, zVar(s)})
+		m.Binaries = append(m.Binaries, zVar(s))
+		m.Constraints = append(m.Constraints, Constraint{Name: fmt.Sprintf("assign_%d", s), Terms: assign, Op: "=", RHS: 1})
+	}
+	for v, veh := range p.Vehicles {
+		var load []Term
+		for s, n := range p.Nodes {
+			if fits(s, v) {
+				load = append(load, Term{n.DemandKg, yVar(s, v)})
+			}
+		}
+		if len(load) > 0 {
+			m.Constraints = append(m.Constraints, Constraint{Name: fmt.Sprintf("cap_%d", v), Terms: load, Op: "<=", RHS: veh.CapacityKg - veh.InitialLoadKg})
+		}
+	}
+
+	decode := func(values map[string]float64) (problem.Solution, error) {
+		sol := problem.Solution{Routes: make([]problem.Route, len(p.Vehicles))}
+		for v, veh := range p.Vehicles {
+			sol.Routes[v] = problem.Route{Vehicle: v, Stops: []int{}, LoadKg: veh.InitialLoadKg}
+		}
+		for s, n := range p.Nodes {
+			assigned := -1
+			for v := range p.Vehicles {
+				if fits(s, v) && set(values, yVar(s, v)) {
+					if assigned >= 0 {
+						return problem.Solution{}, errBadSolution
+					}
+					assigned = v
+				}
+			}
+			if assigned < 0 {
+				sol.Unassigned = append(sol.Unassigned, s)
+				sol.PenaltyCost += n.DropPenalty
+				continue
+			}
+			late := solver.LatePenalty(n, p.Vehicles[assigned])
+			r := &sol.Routes[assigned]
+			r.Stops = append(r.Stops, s)
+			r.LoadKg += n.DemandKg
+			r.LatePenalty += late
+			sol.PenaltyCost += late
+		}
+
+		// A shipment with a drop penalty that still fits somewhere was
+		// dropped by choice rather than for lack of room
+		for _, s := range sol.Unassigned {
+			if p.Nodes[s].DropPenalty <= 0 {
+				continue
+			}
+			for v, veh := range p.Vehicles {
+				if sol.Routes[v].LoadKg+p.Nodes[s].DemandKg <= veh.CapacityKg {
+					sol.Dropped = append(sol.Dropped, s)
+					break
+				}
+			}
+		}
+		return sol, nil
+	}
+	return m, decode, nil
+}
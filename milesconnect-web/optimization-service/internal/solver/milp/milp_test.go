@@ -0,0 +1,159 @@
+package milp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLP(t *testing.T) {
+	m := &Model{
+		Name:        "tiny",
+		Objective:   []Term{{2, "a"}, {-1.5, "b"}},
+		Constraints: []Constraint{{Name: "c1", Terms: []Term{{1, "a"}, {1, "b"}}, Op: "<=", RHS: 1}},
+		Bounds:      []Bound{{Var: "b", Lo: 0, Hi: 4}},
+		Binaries:    []string{"a"},
+		Integers:    []string{"b"},
+	}
+	var sb strings.Builder
+	if err := m.WriteLP(&sb); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `\ tiny
+Minimize
+ obj: + 2 a - 1.5 b
+Subject To
+ c1: + 1 a + 1 b <= 1
+Bounds
+ 0 <= b <= 4
+Binaries
+  a
+Generals
+  b
+End
+`
+	if sb.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+// fakeService answers every solve with status and the values from answer
+func fakeService(t *testing.T, status string, answer func() map[string]float64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req solveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Format != "lp" || !strings.HasPrefix(req.Model, "\\") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(Result{Status: status, Values: answer()})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRoutingRoundTrip(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+
+			// The fake service answers with the Held-Karp tour as arc values
+			srv := fakeService(t, StatusOptimal, func() map[string]float64 {
+				values := map[string]float64{}
+				stops := solver.Exact(p).Routes[0].Stops
+				for k := 1; k < len(stops); k++ {
+					values[xVar(stops[k-1], stops[k])] = 1
+				}
+				return values
+			})
+
+			sol, err := milpSolver{client: NewClient(srv.URL, time.Second)}.Solve(context.Background(), p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := sol.DistanceKm - inst.OptimumKm; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("distance %.4f, want %.4f", sol.DistanceKm, inst.OptimumKm)
+			}
+			if sol.Reason != "proven optimal" {
+				t.Errorf("reason = %q", sol.Reason)
+			}
+		})
+	}
+}
+
+func TestAllocationRoundTrip(t *testing.T) {
+	p := problem.FromLoadRequest(models.LoadRequest{
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 100, DepartHours: 5}},
+		Shipments: []models.ShipmentInfo{
+			{ID: "A", WeightKg: 60},
+			{ID: "B", WeightKg: 30, DeadlineHours: 2, LatePenaltyPerHour: 10, DropPenalty: 20},
+			{ID: "C", WeightKg: 70},
+		},
+	})
+	// A rides; B is dropped (30 late cost > 20) though it fits; C does not fit
+	srv := fakeService(t, StatusFeasible, func() map[string]float64 {
+		return map[string]float64{yVar(0, 0): 1, zVar(1): 1, zVar(2): 1}
+	})
+
+	sol, err := milpSolver{client: NewClient(srv.URL, time.Second)}.Solve(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sol.Routes[0].Stops; len(got) != 1 || got[0] != 0 {
+		t.Errorf("vehicle carries %v, want [0]", got)
+	}
+	if len(sol.Unassigned) != 2 || len(sol.Dropped) != 1 || sol.Dropped[0] != 1 {
+		t.Errorf("unassigned %v dropped %v, want [1 2] and [1]", sol.Unassigned, sol.Dropped)
+	}
+	if sol.PenaltyCost != 20 {
+		t.Errorf("penalty = %v, want 20", sol.PenaltyCost)
+	}
+	if !strings.Contains(sol.Reason, "not proven") {
+		t.Errorf("reason = %q", sol.Reason)
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	infeasible := fakeService(t, StatusInfeasible, func() map[string]float64 { return nil })
+	s := milpSolver{client: NewClient(infeasible.URL, time.Second)}
+
+	small := problem.FromRouteRequest(fixtures.RouteInstances()[0].Request)
+	if _, err := s.Solve(context.Background(), small); err == nil {
+		t.Error("infeasible status: expected an error")
+	}
+
+	big, _ := generator.RouteRequest(generator.Config{Size: MaxRoutingWaypoints + 1, Seed: 1})
+	if _, err := s.Solve(context.Background(), problem.FromRouteRequest(big)); !errors.Is(err, solver.ErrProblemTooLarge) {
+		t.Errorf("oversized routing: got %v", err)
+	}
+
+	fleet, _ := generator.FleetRequest(generator.Config{Size: 5, Seed: 1})
+	if _, err := s.Solve(context.Background(), problem.FromFleetRequest(fleet)); !errors.Is(err, solver.ErrUnsupportedProblem) {
+		t.Errorf("fleet routing: got %v", err)
+	}
+}
+
+func TestCapabilitiesMatchFormulations(t *testing.T) {
+	caps := milpSolver{}.Capabilities()
+	// A fleet solve needs capacity and the routing formulation rejects it,
+	// so the solver must not be offered for one
+	fleet := &problem.Problem{Type: problem.TypeRouting, Vehicles: []problem.Vehicle{{Start: 0, End: 0}, {Start: 0, End: 0}}, Constraints: problem.Constraints{Capacity: true}}
+	if _, _, err := formulateRouting(fleet); err == nil || caps.Has(solver.CapCapacity) {
+		t.Errorf("capacitated routing: err %v, capabilities %v", err, caps.Names())
+	}
+	windows := &problem.Problem{Type: problem.TypeRouting, Nodes: make([]problem.Node, 3), Vehicles: []problem.Vehicle{{Start: 0, End: 2}}, Constraints: problem.Constraints{TimeWindows: true}}
+	if _, _, err := formulateRouting(windows); err == nil || caps.Has(solver.CapTimeWindows) {
+		t.Errorf("routing with windows: err %v, capabilities %v", err, caps.Names())
+	}
+}
@@ -0,0 +1,124 @@
+// Package milp formulates small routing and allocation problems as
+// mixed-integer linear programs and solves them on an external MILP solver,
+// returning provably optimal answers when the solver proves optimality.
+//
+// Models are sent in CPLEX LP format, which CBC, HiGHS, SCIP, Gurobi and
+// CPLEX all read, to a solver service speaking a small JSON protocol:
+//
+//	POST <url>
+//	{"format": "lp", "model": "<LP text>", "time_limit_seconds": 30}
+//
+//	200 OK
+//	{"status": "optimal", "objective": 123.4, "values": {"x_0_3": 1, ...}}
+//
+// status is one of optimal, feasible (a limit was hit before optimality was
+// proven), infeasible or error. A sidecar wrapping e.g. highspy or
+// python-mip implements this in a few lines.
+package milp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// Term is coef * variable
+type Term struct {
+	Coef float64
+	Var  string
+}
+
+// Constraint is a named linear row: sum(Terms) Op RHS, with Op one of
+// "<=", ">=" or "="
+type Constraint struct {
+	Name  string
+	Terms []Term
+	Op    string
+	RHS   float64
+}
+
+// Bound limits a continuous or general integer variable
+type Bound struct {
+	Var    string
+	Lo, Hi float64
+}
+
+// Model is a minimization MILP. Variables are continuous and non-negative
+// unless listed in Binaries or Integers or bounded otherwise.
+type Model struct {
+	Name        string
+	Objective   []Term
+	Constraints []Constraint
+	Bounds      []Bound
+	Binaries    []string
+	Integers    []string
+}
+
+// termsPerLine keeps rows well under the 510 character LP line limit
+const termsPerLine = 8
+
+// WriteLP writes the model in CPLEX LP format
+func (m *Model) WriteLP(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if m.Name != "" {
+		bw.WriteString("\\ " + m.Name + "\n")
+	}
+
+	bw.WriteString("Minimize\n obj:")
+	writeTerms(bw, m.Objective)
+	bw.WriteString("\nSubject To\n")
+	for _, c := range m.Constraints {
+		bw.WriteString(" " + c.Name + ":")
+		writeTerms(bw, c.Terms)
+		bw.WriteString(" " + c.Op + " " + num(c.RHS) + "\n")
+	}
+
+	if len(m.Bounds) > 0 {
+		bw.WriteString("Bounds\n")
+		for _, b := range m.Bounds {
+			bw.WriteString(" " + num(b.Lo) + " <= " + b.Var + " <= " + num(b.Hi) + "\n")
+		}
+	}
+	writeVars(bw, "Binaries", m.Binaries)
+	writeVars(bw, "Generals", m.Integers)
+	bw.WriteString("End\n")
+	return bw.Flush()
+}
+
+func writeTerms(bw *bufio.Writer, terms []Term) {
+	if len(terms) == 0 {
+		bw.WriteString(" 0")
+		return
+	}
+	for i, t := range terms {
+		if i > 0 && i%termsPerLine == 0 {
+			bw.WriteString("\n  ")
+		}
+		if t.Coef < 0 {
+			bw.WriteString(" - " + num(-t.Coef) + " " + t.Var)
+		} else {
+			bw.WriteString(" + " + num(t.Coef) + " " + t.Var)
+		}
+	}
+}
+
+func writeVars(bw *bufio.Writer, section string, vars []string) {
+	if len(vars) == 0 {
+		return
+	}
+	bw.WriteString(section + "\n")
+	for i, v := range vars {
+		if i%termsPerLine == 0 {
+			if i > 0 {
+				bw.WriteString("\n")
+			}
+			bw.WriteString(" ")
+		}
+		bw.WriteString(" " + v)
+	}
+	bw.WriteString("\n")
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,64 @@
+package milp
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"time"
+)
+
+// Register makes the "milp" solver available, backed by the service at url.
+// It is optional because it needs that external service.
+func Register(url string, timeLimit time.Duration) {
+	solver.Register(milpSolver{client: NewClient(url, timeLimit)})
+}
+
+type milpSolver struct {
+	client *Client
+}
+
+func (milpSolver) Name() string { return "milp" }
+
+// Capabilities are what the formulations enforce: routing is one vehicle's
+// path, without capacity or time windows, and allocation the capacities and
+// deadlines every allocation carries, so neither flag is claimed for it
+func (milpSolver) Capabilities() solver.Capabilities {
+	return solver.CapRouting | solver.CapAllocation | solver.CapMatrix
+}
+
+func (s milpSolver) TimeLimit(*problem.Problem) time.Duration { return s.client.TimeLimit }
+
+func (s milpSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	var (
+		m      *Model
+		decode decoder
+		err    error
+	)
+	switch p.Type {
+	case problem.TypeRouting:
+		m, decode, err = formulateRouting(p)
+	case problem.TypeAllocation:
+		m, decode, err = formulateAllocation(p)
+	default:
+		err = solver.ErrUnsupportedProblem
+	}
+	if err != nil {
+		return problem.Solution{}, err
+	}
+
+	res, err := s.client.Solve(ctx, m)
+	if err != nil {
+		return problem.Solution{}, err
+	}
+	sol, err := decode(res.Values)
+	if err != nil {
+		return problem.Solution{}, err
+	}
+
+	if res.Status == StatusOptimal {
+		sol.Reason = "proven optimal"
+	} else {
+		sol.Reason = "time limit reached; best solution found, optimality not proven"
+	}
+	return sol, nil
+}
@@ -0,0 +1,74 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"time"
+)
+
+// maxOrOptSegment is the longest run of stops Or-opt tries to relocate
+const maxOrOptSegment = 3
+
+// ImproveRoute runs 2-opt and Or-opt on stops in place until neither finds
+// an improvement. The first and last stops stay fixed.
+func ImproveRoute(p *problem.Problem, stops []int) {
+	ImproveRouteContext(context.Background(), p, stops)
+}
+
+// ImproveRouteContext is ImproveRoute that gives up once ctx is done,
+// leaving stops as improved so far
+func ImproveRouteContext(ctx context.Context, p *problem.Problem, stops []int) {
+	for pass := 0; pass < maxTwoOptPasses && ctx.Err() == nil; pass++ {
+		twoOptRoute(ctx, p, stops)
+		deadline, _ := ctx.Deadline()
+		if !orOptRoute(p, deadline, stops) {
+			return
+		}
+	}
+}
+
+// orOptRoute relocates short segments to the cheapest other position,
+// keeping their direction, giving up before the next segment once deadline
+// (if set) has passed. Reports whether anything moved.
+func orOptRoute(p *problem.Problem, deadline time.Time, stops []int) bool {
+	n := len(stops)
+	moved := false
+
+	for segLen := 1; segLen <= maxOrOptSegment; segLen++ {
+		for i := 1; i+segLen < n; i++ {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return moved
+			}
+			j := i + segLen - 1 // Segment is stops[i..j]
+			prev, next := stops[i-1], stops[j+1]
+			first, last := stops[i], stops[j]
+			removeGain := p.Cost(prev, first) + p.Cost(last, next) - p.Cost(prev, next)
+
+			bestDelta, bestK := -1e-9, -1
+			for k := 0; k < n-1; k++ {
+				if k >= i-1 && k <= j {
+					continue // Insertion edge touches the segment
+				}
+				a, b := stops[k], stops[k+1]
+				delta := p.Cost(a, first) + p.Cost(last, b) - p.Cost(a, b) - removeGain
+				if delta < bestDelta {
+					bestDelta, bestK = delta, k
+				}
+			}
+			if bestK == -1 {
+				continue
+			}
+
+			seg := append([]int(nil), stops[i:j+1]...)
+			rest := append(append([]int(nil), stops[:i]...), stops[j+1:]...)
+			insertAt := bestK + 1
+			if bestK > j {
+				insertAt -= segLen
+			}
+			out := append(append(append(stops[:0:0], rest[:insertAt]...), seg...), rest[insertAt:]...)
+			copy(stops, out)
+			moved = true
+		}
+	}
+	return moved
+}
@@ -0,0 +1,62 @@
+package ortools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client calls the sidecar's transcoded Solve endpoint
+type Client struct {
+	URL       string // Base URL; /v1/solve is appended
+	TimeLimit time.Duration
+	HTTP      *http.Client
+}
+
+// NewClient returns a client whose HTTP timeout leaves the sidecar its full
+// time limit plus a margin for transfer
+func NewClient(url string, timeLimit time.Duration) *Client {
+	return &Client{
+		URL:       strings.TrimSuffix(url, "/"),
+		TimeLimit: timeLimit,
+		HTTP:      &http.Client{Timeout: timeLimit + 10*time.Second},
+	}
+}
+
+// solve sends req and returns the sidecar's response. Statuses without a
+// solution are reported as errors.
+func (c *Client) solve(ctx context.Context, req solveRequest) (solveResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return solveResponse{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/v1/solve", bytes.NewReader(body))
+	if err != nil {
+		return solveResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return solveResponse{}, fmt.Errorf("ortools: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return solveResponse{}, fmt.Errorf("ortools: sidecar returned %s", resp.Status)
+	}
+	var res solveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return solveResponse{}, fmt.Errorf("ortools: decoding sidecar response: %w", err)
+	}
+
+	switch res.Status {
+	case StatusOptimal, StatusFeasible:
+		return res, nil
+	default:
+		return res, fmt.Errorf("ortools: sidecar status %q", res.Status)
+	}
+}
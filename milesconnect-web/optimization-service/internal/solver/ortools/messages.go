@@ -0,0 +1,57 @@
+// Package ortools bridges to an OR-Tools sidecar that serves the
+// RoutingBridge service in bridge.proto. The adapter is not a gRPC client:
+// it calls the same method as JSON over HTTP, through the sidecar's gRPC-JSON
+// transcoding endpoint (POST /v1/solve with the proto3 JSON mapping), which
+// keeps the service free of gRPC and protobuf dependencies. A sidecar must
+// therefore expose that gateway (grpc-gateway or Envoy); one that serves
+// only gRPC cannot be reached.
+//
+// The bridge lets hard instances be delegated to OR-Tools and lets its
+// results be compared with the Go solvers on the same request.
+package ortools
+
+// The types below mirror the messages in bridge.proto, with proto3 JSON
+// field names
+
+type solveRequest struct {
+	NumNodes       int32     `json:"numNodes"`
+	DistanceMatrix []int32   `json:"distanceMatrix"`
+	TimeMatrix     []int32   `json:"timeMatrix,omitempty"`
+	Nodes          []node    `json:"nodes"`
+	Vehicles       []vehicle `json:"vehicles"`
+	TimeLimitMs    int32     `json:"timeLimitMs"`
+}
+
+type node struct {
+	Demand      int32 `json:"demand,omitempty"`
+	ReadyS      int32 `json:"readyS,omitempty"`
+	DueS        int32 `json:"dueS,omitempty"`
+	ServiceS    int32 `json:"serviceS,omitempty"`
+	DropPenalty int32 `json:"dropPenalty,omitempty"`
+}
+
+type vehicle struct {
+	Start    int32 `json:"start"`
+	End      int32 `json:"end"`
+	Capacity int32 `json:"capacity"`
+	DepartS  int32 `json:"departS,omitempty"`
+}
+
+// Statuses of SolveResponse, as proto3 JSON enum names
+const (
+	StatusOptimal    = "OPTIMAL"
+	StatusFeasible   = "FEASIBLE"
+	StatusInfeasible = "INFEASIBLE"
+	StatusTimeout    = "TIMEOUT"
+)
+
+type route struct {
+	Vehicle int32   `json:"vehicle"`
+	Nodes   []int32 `json:"nodes"`
+}
+
+type solveResponse struct {
+	Status    string  `json:"status"`
+	Routes    []route `json:"routes"`
+	Objective float64 `json:"objective"`
+}
@@ -0,0 +1,160 @@
+package ortools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSidecar answers every solve with status and the routes from answer
+func fakeSidecar(t *testing.T, status string, answer func(req solveRequest) []route) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req solveRequest
+		if r.URL.Path != "/v1/solve" || json.NewDecoder(r.Body).Decode(&req) != nil ||
+			len(req.DistanceMatrix) != int(req.NumNodes*req.NumNodes) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(solveResponse{Status: status, Routes: answer(req)})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fleetProblem is a generated fleet instance with loose time windows
+func fleetProblem(t *testing.T, size int) *problem.Problem {
+	t.Helper()
+	req, err := generator.FleetRequest(generator.Config{Size: size, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromFleetRequest(req)
+	p.Constraints.TimeWindows = true
+	for i := 1; i < len(p.Nodes); i++ {
+		p.Nodes[i].DueHours = 1000
+	}
+	return p
+}
+
+// toRoutes encodes a solution's routes as the sidecar would
+func toRoutes(sol problem.Solution) []route {
+	var routes []route
+	for _, r := range sol.Routes {
+		nodes := make([]int32, len(r.Stops))
+		for k, s := range r.Stops {
+			nodes[k] = int32(s)
+		}
+		routes = append(routes, route{Vehicle: int32(r.Vehicle), Nodes: nodes})
+	}
+	return routes
+}
+
+func TestFleetRoundTrip(t *testing.T) {
+	p := fleetProblem(t, 30)
+	p.SolverParams = map[string]float64{"alns_iterations": 200, "seed": 1}
+	alns, _ := solver.Get("alns")
+	want, err := alns.Solve(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := fakeSidecar(t, StatusOptimal, func(req solveRequest) []route {
+		if len(req.TimeMatrix) != len(req.DistanceMatrix) || req.Nodes[0].DropPenalty != 0 || req.Nodes[1].DropPenalty <= 0 {
+			t.Errorf("unexpected request encoding")
+		}
+		return toRoutes(want)
+	})
+	sol, err := ortoolsSolver{client: NewClient(srv.URL, time.Second)}.Solve(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := sol.DistanceKm - want.DistanceKm; diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("distance %.4f, want %.4f", sol.DistanceKm, want.DistanceKm)
+	}
+	if len(sol.Unassigned) != len(want.Unassigned) {
+		t.Errorf("unassigned %v, want %v", sol.Unassigned, want.Unassigned)
+	}
+	if sol.Reason != "OR-Tools: proven optimal" {
+		t.Errorf("reason = %q", sol.Reason)
+	}
+}
+
+func TestDroppedNodesAreUnassigned(t *testing.T) {
+	p := fleetProblem(t, 5)
+	srv := fakeSidecar(t, StatusFeasible, func(req solveRequest) []route {
+		return []route{{Vehicle: 0, Nodes: []int32{0, 2, 0}}}
+	})
+	sol, err := ortoolsSolver{client: NewClient(srv.URL, time.Second)}.Solve(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sol.Unassigned; len(got) != 4 || got[0] != 1 {
+		t.Errorf("unassigned = %v, want [1 3 4 5]", got)
+	}
+	if !strings.Contains(sol.Reason, "time limit") {
+		t.Errorf("reason = %q", sol.Reason)
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	p := fleetProblem(t, 5)
+	cases := []struct {
+		name   string
+		status string
+		routes []route
+	}{
+		{"infeasible", StatusInfeasible, nil},
+		{"repeated node", StatusFeasible, []route{{Vehicle: 0, Nodes: []int32{0, 1, 1, 0}}}},
+		{"wrong start", StatusFeasible, []route{{Vehicle: 0, Nodes: []int32{1, 0}}}},
+		{"unknown vehicle", StatusFeasible, []route{{Vehicle: 99, Nodes: []int32{0, 0}}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := fakeSidecar(t, tc.status, func(solveRequest) []route { return tc.routes })
+			if _, err := (ortoolsSolver{client: NewClient(srv.URL, time.Second)}).Solve(context.Background(), p); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	s := ortoolsSolver{client: NewClient("http://127.0.0.1:0", time.Second)}
+	big := fleetProblem(t, MaxNodes)
+	if _, err := s.Solve(context.Background(), big); !errors.Is(err, solver.ErrProblemTooLarge) {
+		t.Errorf("oversized: got %v", err)
+	}
+	alloc := &problem.Problem{Type: problem.TypeAllocation}
+	if _, err := s.Solve(context.Background(), alloc); !errors.Is(err, solver.ErrUnsupportedProblem) {
+		t.Errorf("allocation: got %v", err)
+	}
+}
+
+func TestAutoFallsBackWhenSidecarFails(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+	Register(down.URL, time.Second)
+
+	p := fleetProblem(t, 200)
+	p.SolverParams = map[string]float64{"alns_iterations": 50, "seed": 1}
+	auto, _ := solver.Get("auto")
+	sol, err := auto.Solve(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Solver != "alns" || !strings.Contains(sol.Reason, "fell back") {
+		t.Errorf("solver %q reason %q, want a fallback to alns", sol.Solver, sol.Reason)
+	}
+	if len(sol.Unassigned) != 0 {
+		t.Errorf("%d stops unassigned", len(sol.Unassigned))
+	}
+}
@@ -0,0 +1,53 @@
+package ortools
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"time"
+)
+
+// Register makes the "ortools" solver available, backed by the sidecar at
+// url. It is optional because it needs that sidecar.
+func Register(url string, timeLimit time.Duration) {
+	solver.Register(ortoolsSolver{client: NewClient(url, timeLimit)})
+}
+
+type ortoolsSolver struct {
+	client *Client
+}
+
+func (ortoolsSolver) Name() string { return "ortools" }
+func (ortoolsSolver) Capabilities() solver.Capabilities {
+	return solver.CapRouting | solver.CapCapacity | solver.CapTimeWindows | solver.CapMatrix
+}
+
+func (s ortoolsSolver) TimeLimit(p *problem.Problem) time.Duration {
+	limit := s.client.TimeLimit
+	if ms := p.SolverParams["time_limit_ms"]; ms > 0 {
+		limit = min(limit, time.Duration(ms*float64(time.Millisecond)))
+	}
+	return limit
+}
+
+func (s ortoolsSolver) Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error) {
+	req, err := toRequest(p, s.TimeLimit(p))
+	if err != nil {
+		return problem.Solution{}, err
+	}
+	res, err := s.client.solve(ctx, req)
+	if err != nil {
+		return problem.Solution{}, err
+	}
+	sol, err := fromResponse(p, res)
+	if err != nil {
+		return problem.Solution{}, err
+	}
+
+	if res.Status == StatusOptimal {
+		sol.Reason = "OR-Tools: proven optimal"
+	} else {
+		sol.Reason = "OR-Tools: best solution within the time limit"
+	}
+	return sol, nil
+}
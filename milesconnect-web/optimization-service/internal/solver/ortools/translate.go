@@ -0,0 +1,145 @@
+package ortools
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"time"
+)
+
+// MaxNodes bounds the instances sent to the sidecar; the dense matrices
+// grow quadratically
+const MaxNodes = 1000
+
+// errBadResponse is returned when the sidecar's routes do not describe a
+// valid plan, which points at a sidecar or protocol bug
+var errBadResponse = errors.New("ortools: sidecar returned an invalid solution")
+
+// OR-Tools works in integers: metres, grams and seconds
+func metres(km float64) int32 { return clamp(km * 1000) }
+func grams(kg float64) int32  { return clamp(kg * 1000) }
+func seconds(h float64) int32 { return clamp(h * 3600) }
+func clamp(x float64) int32   { return int32(math.Min(math.Round(x), math.MaxInt32)) }
+
+// toRequest translates a routing problem into a SolveRequest. Nodes that
+// are not vehicle endpoints may be dropped at a penalty above any single
+// detour, so an instance that cannot be fully served still returns a plan.
+func toRequest(p *problem.Problem, limit time.Duration) (solveRequest, error) {
+	if p.Type != problem.TypeRouting {
+		return solveRequest{}, solver.ErrUnsupportedProblem
+	}
+	n := len(p.Nodes)
+	if n > MaxNodes {
+		return solveRequest{}, fmt.Errorf("%w: the OR-Tools bridge handles at most %d nodes", solver.ErrProblemTooLarge, MaxNodes)
+	}
+	for _, v := range p.Vehicles {
+		if v.Start < 0 || v.End < 0 {
+			return solveRequest{}, solver.ErrUnsupportedProblem
+		}
+	}
+	// The bridge's capacity dimension only accumulates, so it cannot free
+	// room for returns
+	for _, nd := range p.Nodes {
+		if nd.ReturnKg > 0 {
+			return solveRequest{}, fmt.Errorf("%w: the OR-Tools bridge does not plan returns", solver.ErrUnsupportedProblem)
+		}
+	}
+
+	req := solveRequest{
+		NumNodes:       int32(n),
+		DistanceMatrix: make([]int32, 0, n*n),
+		Nodes:          make([]node, n),
+		Vehicles:       make([]vehicle, len(p.Vehicles)),
+		TimeLimitMs:    int32(limit.Milliseconds()),
+	}
+	for i := range n {
+		for j := range n {
+			req.DistanceMatrix = append(req.DistanceMatrix, metres(p.Cost(i, j)))
+		}
+	}
+	if p.Constraints.TimeWindows {
+		req.TimeMatrix = make([]int32, 0, n*n)
+		for i := range n {
+			for j := range n {
+				req.TimeMatrix = append(req.TimeMatrix, seconds(p.TravelHours(i, j)))
+			}
+		}
+	}
+
+	endpoint := make([]bool, n)
+	for vi, v := range p.Vehicles {
+		endpoint[v.Start], endpoint[v.End] = true, true
+		capacity := int32(math.MaxInt32)
+		if p.Constraints.Capacity && v.CapacityKg > 0 {
+			capacity = grams(v.CapacityKg - v.InitialLoadKg)
+		}
+		req.Vehicles[vi] = vehicle{Start: int32(v.Start), End: int32(v.End), Capacity: capacity, DepartS: seconds(v.DepartHours)}
+	}
+
+	penalty := metres(solver.UnassignedPenalty(p))
+	for i, nd := range p.Nodes {
+		out := &req.Nodes[i]
+		if p.Constraints.Capacity {
+			out.Demand = grams(nd.DemandKg)
+		}
+		if p.Constraints.TimeWindows {
+			out.ReadyS, out.DueS, out.ServiceS = seconds(nd.ReadyHours), seconds(nd.DueHours), seconds(nd.ServiceHours)
+		}
+		if !endpoint[i] {
+			out.DropPenalty = max(penalty, 1)
+		}
+	}
+	return req, nil
+}
+
+// fromResponse rebuilds a solution from the sidecar's routes, checking
+// that they start and end where the vehicles do and visit each node at
+// most once. Distances and loads are recomputed in km and kg; vehicles
+// without stops get no route.
+func fromResponse(p *problem.Problem, resp solveResponse) (problem.Solution, error) {
+	var sol problem.Solution
+	endpoint := make([]bool, len(p.Nodes))
+	for _, v := range p.Vehicles {
+		endpoint[v.Start], endpoint[v.End] = true, true
+	}
+	seen := make([]bool, len(p.Nodes))
+	routed := make([]bool, len(p.Vehicles))
+	for _, r := range resp.Routes {
+		vi := int(r.Vehicle)
+		if vi < 0 || vi >= len(p.Vehicles) || routed[vi] || len(r.Nodes) < 2 {
+			return problem.Solution{}, errBadResponse
+		}
+		routed[vi] = true
+		v := p.Vehicles[vi]
+
+		stops := make([]int, len(r.Nodes))
+		for k, nd := range r.Nodes {
+			stops[k] = int(nd)
+		}
+		if stops[0] != v.Start || stops[len(stops)-1] != v.End {
+			return problem.Solution{}, errBadResponse
+		}
+		load := v.InitialLoadKg
+		for _, s := range stops[1 : len(stops)-1] {
+			if s < 0 || s >= len(p.Nodes) || endpoint[s] || seen[s] {
+				return problem.Solution{}, errBadResponse
+			}
+			seen[s] = true
+			load += p.Nodes[s].DemandKg
+		}
+		if len(stops) > 2 {
+			r := problem.Route{Vehicle: vi, Stops: stops, DistanceKm: solver.RouteDistance(p, stops), LoadKg: load}
+			sol.Routes = append(sol.Routes, r)
+			sol.DistanceKm += r.DistanceKm
+		}
+	}
+
+	for i := range p.Nodes {
+		if !endpoint[i] && !seen[i] {
+			sol.Unassigned = append(sol.Unassigned, i)
+		}
+	}
+	return sol, nil
+}
@@ -0,0 +1,96 @@
+package solver
+
+import (
+	"fmt"
+	"math/rand"
+	"milesconnect-optimization/internal/models"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// randomLoad generates a load request with a mix of fitting and oversized shipments
+type randomLoad struct {
+	Req models.LoadRequest
+}
+
+func (randomLoad) Generate(r *rand.Rand, size int) reflect.Value {
+	var req models.LoadRequest
+	for i := 0; i < r.Intn(5); i++ {
+		capacity := 50 + r.Float64()*950
+		req.Vehicles = append(req.Vehicles, models.VehicleInfo{
+			ID:          fmt.Sprintf("V%d", i),
+			CapacityKg:  capacity,
+			CurrentLoad: r.Float64() * capacity / 2,
+			DepartHours: r.Float64() * 12,
+		})
+	}
+	for i := 0; i < r.Intn(size+1); i++ {
+		s := models.ShipmentInfo{ID: fmt.Sprintf("S%d", i), WeightKg: 1 + r.Float64()*400}
+		if r.Intn(2) == 0 {
+			s.DeadlineHours = r.Float64() * 12
+			s.LatePenaltyPerHour = r.Float64() * 1000
+			s.DropPenalty = r.Float64() * 2000
+		}
+		req.Shipments = append(req.Shipments, s)
+	}
+	return reflect.ValueOf(randomLoad{Req: req})
+}
+
+func TestAllocationRespectsCapacity(t *testing.T) {
+	prop := func(rl randomLoad) bool {
+		capacity := map[string]models.VehicleInfo{}
+		for _, v := range rl.Req.Vehicles {
+			capacity[v.ID] = v
+		}
+		weight := map[string]float64{}
+		for _, s := range rl.Req.Shipments {
+			weight[s.ID] = s.WeightKg
+		}
+
+		for _, a := range OptimizeFleetAllocation(rl.Req).Allocations {
+			v := capacity[a.VehicleID]
+			load := v.CurrentLoad
+			for _, id := range a.ShipmentIDs {
+				load += weight[id]
+			}
+			if load > v.CapacityKg+1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocationPartitionsShipments(t *testing.T) {
+	prop := func(rl randomLoad) bool {
+		resp := OptimizeFleetAllocation(rl.Req)
+
+		seen := map[string]int{}
+		for _, a := range resp.Allocations {
+			for _, id := range a.ShipmentIDs {
+				seen[id]++
+			}
+		}
+		for _, id := range resp.Unassigned {
+			seen[id]++
+		}
+
+		// assigned ∪ unassigned == input, and the two are disjoint
+		if len(seen) != len(rl.Req.Shipments) {
+			return false
+		}
+		for _, s := range rl.Req.Shipments {
+			if seen[s.ID] != 1 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
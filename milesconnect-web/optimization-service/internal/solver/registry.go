@@ -0,0 +1,127 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"milesconnect-optimization/internal/problem"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Capabilities is a bit set describing what a solver can handle
+type Capabilities uint
+
+const (
+	CapRouting       Capabilities = 1 << iota // Orders stops into a route
+	CapAllocation                             // Assigns shipments to vehicles
+	CapCapacity                               // Respects vehicle capacity
+	CapTimeWindows                            // Respects time windows / deadlines
+	CapMatrix                                 // Accepts a custom distance matrix
+	CapCompatibility                          // Serves stops only with the vehicle types they allow
+)
+
+// Has reports whether all flags in want are set
+func (c Capabilities) Has(want Capabilities) bool {
+	return c&want == want
+}
+
+// Names lists the set flags, for API output
+func (c Capabilities) Names() []string {
+	names := []string{}
+	for _, f := range []struct {
+		flag Capabilities
+		name string
+	}{
+		{CapRouting, "routing"},
+		{CapAllocation, "allocation"},
+		{CapCapacity, "capacity"},
+		{CapTimeWindows, "time_windows"},
+		{CapMatrix, "matrix"},
+		{CapCompatibility, "compatibility"},
+	} {
+		if c.Has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// Solver is implemented by every optimization algorithm. New algorithms
+// register themselves with Register and become available to the handlers.
+type Solver interface {
+	Name() string
+	Capabilities() Capabilities
+	Solve(ctx context.Context, p *problem.Problem) (problem.Solution, error)
+}
+
+// CPUIntensive is optionally implemented by solvers whose runs are heavy
+// enough to be admission-controlled (e.g. metaheuristics)
+type CPUIntensive interface {
+	CPUIntensive() bool
+}
+
+// IsCPUIntensive reports whether s opted into admission control
+func IsCPUIntensive(s Solver) bool {
+	c, ok := s.(CPUIntensive)
+	return ok && c.CPUIntensive()
+}
+
+// TimeBounded is optionally implemented by solvers that stop at a time
+// limit, so a solve of p takes at most TimeLimit(p)
+type TimeBounded interface {
+	TimeLimit(p *problem.Problem) time.Duration
+}
+
+var (
+	// ErrUnsupportedProblem is returned when a solver is handed a problem it cannot solve
+	ErrUnsupportedProblem = errors.New("solver does not support this problem type")
+
+	// ErrProblemTooLarge is returned when an instance exceeds a solver's size limit
+	ErrProblemTooLarge = errors.New("problem too large for this solver")
+
+	// ErrNotEnabled is returned when every solver suited to a problem is
+	// switched off for the caller
+	ErrNotEnabled = errors.New("no solver suited to this problem is enabled")
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Solver{}
+)
+
+// Register makes a solver available by name. It panics on duplicates,
+// since that can only be a programming error.
+func Register(s Solver) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[s.Name()]; dup {
+		panic("solver: Register called twice for " + s.Name())
+	}
+	registry[s.Name()] = s
+}
+
+// Get looks up a registered solver by name
+func Get(name string) (Solver, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	s, ok := registry[name]
+	return s, ok
+}
+
+// List returns all registered solvers sorted by name
+func List() []Solver {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]Solver, 0, len(registry))
+	for _, s := range registry {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
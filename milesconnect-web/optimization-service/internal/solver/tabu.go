@@ -0,0 +1,319 @@
+package solver
+
+import (
+	"context"
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"time"
+)
+
+// Tabu search defaults; tabu_iterations, tabu_tenure and time_limit_ms
+// override them
+const (
+	TabuIterations = 300
+	TabuTenure     = 12
+	TabuTimeLimit  = 5 * time.Second
+	tabuMaxStall   = 80 // Iterations without a new best plan before giving up
+
+	// Up to this many nodes the search precomputes great-circle distances;
+	// beyond it the matrix would cost more memory than it saves time
+	maxCachedMatrixNodes = 2000
+)
+
+// tabuKey forbids node from re-entering vehicle's route
+type tabuKey struct{ node, vehicle int }
+
+// tabuMove rewrites the routes of vehicles a and b
+type tabuMove struct {
+	a, b       int
+	newA, newB []int
+	delta      float64
+	tabu       []tabuKey
+}
+
+// TabuSearch improves a cheapest-insertion plan with inter-route relocate,
+// exchange and 2-opt* moves. Each iteration applies the best admissible move
+// even when it is worse, which lets the search climb out of local optima; a
+// node moved out of a route may not return to it for the tenure unless that
+// yields a new best plan. Routes touched by a move are re-polished with
+// 2-opt/Or-opt.
+func TabuSearch(ctx context.Context, p *problem.Problem) problem.Solution {
+	iterations, tenure := TabuIterations, TabuTenure
+	if v := int(p.SolverParams["tabu_iterations"]); v > 0 {
+		iterations = v
+	}
+	if v := int(p.SolverParams["tabu_tenure"]); v > 0 {
+		tenure = v
+	}
+	deadline := time.Now().Add(timeLimit(p, TabuTimeLimit))
+
+	p = withDistanceMatrix(p)
+	cur := cheapestInsertion(p)
+	for vi := range cur.routes {
+		cur.routes[vi] = polishRoute(p, vi, cur.routes[vi])
+	}
+	best := cur.clone()
+	bestDist := best.distance(p)
+	tabu := map[tabuKey]int{}
+
+	for it, stall := 0, 0; it < iterations && stall < tabuMaxStall; it++ {
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			break
+		}
+
+		// Aspiration: a tabu move is allowed when it beats the best plan
+		aspire := math.Inf(-1)
+		if len(cur.unassigned) == len(best.unassigned) {
+			aspire = bestDist - cur.distance(p)
+		}
+		mv, ok := bestTabuMove(p, cur, tabu, it, aspire)
+		if !ok {
+			break
+		}
+
+		cur.routes[mv.a] = polishRoute(p, mv.a, mv.newA)
+		cur.routes[mv.b] = polishRoute(p, mv.b, mv.newB)
+		for _, k := range mv.tabu {
+			tabu[k] = it + tenure
+		}
+		fillUnassigned(p, &cur)
+
+		if cur.better(p, best) {
+			best, bestDist, stall = cur.clone(), cur.distance(p), 0
+		} else {
+			stall++
+		}
+	}
+	return best.solution(p)
+}
+
+// bestTabuMove scans the relocate, exchange and 2-opt* neighbourhoods for
+// the feasible move with the lowest distance delta that is not tabu, or is
+// tabu but has a delta below aspire
+func bestTabuMove(p *problem.Problem, pl vrpPlan, tabu map[tabuKey]int, it int, aspire float64) (tabuMove, bool) {
+	d := p.Distance
+	isTabu := func(node, vehicle int) bool { return tabu[tabuKey{node, vehicle}] > it }
+
+	best := tabuMove{delta: math.Inf(1)}
+	found := false
+	var bufA, bufB []int
+	admissible := func(delta float64, tabued bool) bool {
+		return delta < best.delta-vrpEpsilon && (!tabued || delta < aspire-vrpEpsilon)
+	}
+	take := func(a, b int, delta float64, keys ...tabuKey) {
+		if !routeFeasible(p, p.Vehicles[a], bufA) || !routeFeasible(p, p.Vehicles[b], bufB) {
+			return
+		}
+		best = tabuMove{
+			a: a, b: b,
+			newA:  append([]int(nil), bufA...),
+			newB:  append([]int(nil), bufB...),
+			delta: delta,
+			tabu:  keys,
+		}
+		found = true
+	}
+
+	// Prefix distances: pre[v][k] is the distance from v's start to its k-th stop
+	pre := make([][]float64, len(pl.routes))
+	cost := make([]float64, len(pl.routes))
+	for vi, inner := range pl.routes {
+		v := p.Vehicles[vi]
+		pre[vi] = make([]float64, len(inner)+1)
+		prev := v.Start
+		for k, n := range inner {
+			pre[vi][k+1] = pre[vi][k] + d(prev, n)
+			prev = n
+		}
+		cost[vi] = pre[vi][len(inner)] + d(prev, v.End)
+	}
+	// Emptying a route stands its vehicle down; filling one dispatches it
+	idle := func(vi int, inner []int) float64 {
+		if len(inner) == 0 {
+			v := p.Vehicles[vi]
+			return d(v.Start, v.End)
+		}
+		return 0
+	}
+	for vi, inner := range pl.routes {
+		cost[vi] -= idle(vi, inner)
+	}
+
+	for a, A := range pl.routes {
+		va := p.Vehicles[a]
+		for i, n := range A {
+			pa, na := neighbours(va, A, i)
+			removal := d(pa, na) - d(pa, n) - d(n, na)
+
+			// Relocate n into another route
+			for b, B := range pl.routes {
+				if b == a {
+					continue
+				}
+				vb := p.Vehicles[b]
+				for j := 0; j <= len(B); j++ {
+					u, w := vb.Start, vb.End
+					if j > 0 {
+						u = B[j-1]
+					}
+					if j < len(B) {
+						w = B[j]
+					}
+					delta := removal + d(u, n) + d(n, w) - d(u, w) + idle(b, B)
+					if len(A) == 1 {
+						delta -= d(va.Start, va.End)
+					}
+					if !admissible(delta, isTabu(n, b)) {
+						continue
+					}
+					bufA = append(append(bufA[:0], A[:i]...), A[i+1:]...)
+					bufB = insertAt(bufB[:0], B, j, n)
+					take(a, b, delta, tabuKey{n, a})
+				}
+			}
+
+			// Exchange n with a node of another route
+			for b := a + 1; b < len(pl.routes); b++ {
+				B, vb := pl.routes[b], p.Vehicles[b]
+				for j, m := range B {
+					pb, nb := neighbours(vb, B, j)
+					delta := d(pa, m) + d(m, na) - d(pa, n) - d(n, na) +
+						d(pb, n) + d(n, nb) - d(pb, m) - d(m, nb)
+					if !admissible(delta, isTabu(n, b) || isTabu(m, a)) {
+						continue
+					}
+					bufA = append(bufA[:0], A...)
+					bufA[i] = m
+					bufB = append(bufB[:0], B...)
+					bufB[j] = n
+					take(a, b, delta, tabuKey{n, a}, tabuKey{m, b})
+				}
+			}
+		}
+
+		// 2-opt*: swap the tails of two routes
+		for b := a + 1; b < len(pl.routes); b++ {
+			B, vb := pl.routes[b], p.Vehicles[b]
+			for i := 0; i <= len(A); i++ {
+				for j := 0; j <= len(B); j++ {
+					if i == len(A) && j == len(B) {
+						continue
+					}
+					headA, headB := va.Start, vb.Start
+					if i > 0 {
+						headA = A[i-1]
+					}
+					if j > 0 {
+						headB = B[j-1]
+					}
+					delta := pre[a][i] + joinTail(d, headA, B, pre[b], j, va.End) +
+						pre[b][j] + joinTail(d, headB, A, pre[a], i, vb.End) -
+						cost[a] - cost[b]
+					if i == 0 && j == len(B) {
+						delta -= d(va.Start, va.End)
+					}
+					if j == 0 && i == len(A) {
+						delta -= d(vb.Start, vb.End)
+					}
+
+					tabued := (i < len(A) && isTabu(A[i], b)) || (j < len(B) && isTabu(B[j], a))
+					if !admissible(delta, tabued) {
+						continue
+					}
+					var keys []tabuKey
+					if i < len(A) {
+						keys = append(keys, tabuKey{A[i], a})
+					}
+					if j < len(B) {
+						keys = append(keys, tabuKey{B[j], b})
+					}
+					bufA = append(append(bufA[:0], A[:i]...), B[j:]...)
+					bufB = append(append(bufB[:0], B[:j]...), A[i:]...)
+					take(a, b, delta, keys...)
+				}
+			}
+		}
+	}
+	return best, found
+}
+
+// neighbours returns the nodes before and after position i of v's route
+func neighbours(v problem.Vehicle, inner []int, i int) (int, int) {
+	prev, next := v.Start, v.End
+	if i > 0 {
+		prev = inner[i-1]
+	}
+	if i < len(inner)-1 {
+		next = inner[i+1]
+	}
+	return prev, next
+}
+
+// joinTail is the distance from head through inner[j:] to end, using the
+// prefix distances of inner's route
+func joinTail(d func(int, int) float64, head int, inner []int, pre []float64, j, end int) float64 {
+	if j == len(inner) {
+		return d(head, end)
+	}
+	last := inner[len(inner)-1]
+	return d(head, inner[j]) + pre[len(inner)] - pre[j+1] + d(last, end)
+}
+
+// polishRoute reorders one route with 2-opt/Or-opt, keeping the result only
+// if it still meets the time windows and, with returns aboard, capacity
+func polishRoute(p *problem.Problem, vi int, inner []int) []int {
+	if len(inner) < 2 {
+		return inner
+	}
+	v := p.Vehicles[vi]
+	stops := make([]int, 0, len(inner)+2)
+	stops = append(stops, v.Start)
+	stops = append(stops, inner...)
+	stops = append(stops, v.End)
+
+	ImproveRoute(p, stops)
+	polished := stops[1 : len(stops)-1]
+	if !routeFeasible(p, v, polished) {
+		return inner
+	}
+	return polished
+}
+
+// fillUnassigned retries every unassigned node, since a move may have
+// opened up room for it
+func fillUnassigned(p *problem.Problem, pl *vrpPlan) {
+	left := pl.unassigned[:0]
+	for _, n := range pl.unassigned {
+		if !insertCheapest(p, pl, n) {
+			left = append(left, n)
+		}
+	}
+	pl.unassigned = left
+}
+
+// withDistanceMatrix returns a shallow copy of p with its great-circle
+// distances precomputed, or p itself when it already has a matrix or is too
+// large to cache. The matrix holds distances alone: Cost still adds tolls.
+func withDistanceMatrix(p *problem.Problem) *problem.Problem {
+	if p.Matrix != nil || len(p.Nodes) > maxCachedMatrixNodes {
+		return p
+	}
+	m := make([][]float64, len(p.Nodes))
+	for i := range m {
+		m[i] = make([]float64, len(p.Nodes))
+		for j := range m[i] {
+			m[i][j] = p.Distance(i, j)
+		}
+	}
+	q := *p
+	q.Matrix = m
+	return &q
+}
+
+// timeLimit reads the time_limit_ms solver parameter, falling back to def
+func timeLimit(p *problem.Problem, def time.Duration) time.Duration {
+	if ms := p.SolverParams["time_limit_ms"]; ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	return def
+}
@@ -0,0 +1,138 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"testing"
+)
+
+// checkVRP fails the test unless every customer is served once or reported
+// unassigned and every route respects its constraints
+func checkVRP(t *testing.T, p *problem.Problem, sol problem.Solution) {
+	t.Helper()
+	seen := map[int]int{}
+	for _, r := range sol.Routes {
+		v := p.Vehicles[r.Vehicle]
+		if r.Stops[0] != v.Start || r.Stops[len(r.Stops)-1] != v.End {
+			t.Fatalf("vehicle %s route %v does not run between its endpoints", v.ID, r.Stops)
+		}
+		inner := r.Stops[1 : len(r.Stops)-1]
+		if !routeFeasible(p, v, inner) {
+			t.Errorf("vehicle %s route %v is infeasible", v.ID, inner)
+		}
+		for _, n := range inner {
+			seen[n]++
+		}
+	}
+	for _, n := range sol.Unassigned {
+		seen[n]++
+	}
+	for _, n := range vrpCustomers(p) {
+		if seen[n] != 1 {
+			t.Errorf("node %d appears %d times", n, seen[n])
+		}
+	}
+}
+
+func TestTabuImprovesOnConstruction(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 60, Distribution: generator.Clustered, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromFleetRequest(req)
+
+	start := cheapestInsertion(p).solution(p)
+	sol := TabuSearch(context.Background(), p)
+	checkVRP(t, p, sol)
+
+	if len(sol.Unassigned) > len(start.Unassigned) {
+		t.Errorf("tabu left %d stops unassigned, construction %d", len(sol.Unassigned), len(start.Unassigned))
+	}
+	if sol.DistanceKm > start.DistanceKm+1e-6 {
+		t.Errorf("tabu distance %.1f is worse than construction %.1f", sol.DistanceKm, start.DistanceKm)
+	}
+}
+
+func TestTabuRespectsTimeWindows(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 30, Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Half the stops must be reached within a day, some of them not before noon
+	for i := range req.Stops {
+		if i%2 == 0 {
+			req.Stops[i].DueHours = 24
+		}
+		if i%4 == 0 {
+			req.Stops[i].ReadyHours = 12
+		}
+		req.Stops[i].ServiceHours = 0.5
+	}
+	p := problem.FromFleetRequest(req)
+	if !p.Constraints.TimeWindows {
+		t.Fatal("time windows not detected")
+	}
+
+	checkVRP(t, p, TabuSearch(context.Background(), p))
+}
+
+func TestTabuLeavesOversizedStopUnassigned(t *testing.T) {
+	req, err := generator.FleetRequest(generator.Config{Size: 10, Seed: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Stops[0].DemandKg = 5000
+	p := problem.FromFleetRequest(req)
+
+	sol := TabuSearch(context.Background(), p)
+	checkVRP(t, p, sol)
+	if len(sol.Unassigned) != 1 || sol.Unassigned[0] != 1 {
+		t.Errorf("unassigned = %v, want [1]", sol.Unassigned)
+	}
+}
+
+func TestDistanceMatrixCountsTollsOnce(t *testing.T) {
+	p := fleetProblem(t, 8, 2)
+	p.CostPerKm = 20
+	p.Tolls = make([][]float64, len(p.Nodes))
+	for i := range p.Tolls {
+		p.Tolls[i] = make([]float64, len(p.Nodes))
+		for j := range p.Tolls[i] {
+			p.Tolls[i][j] = float64(100 * (i + j))
+		}
+	}
+	q := withDistanceMatrix(p)
+	for i := range p.Nodes {
+		for j := range p.Nodes {
+			if q.Cost(i, j) != p.Cost(i, j) || q.Distance(i, j) != p.Distance(i, j) {
+				t.Fatalf("leg %d-%d costs %.3f cached, %.3f direct", i, j, q.Cost(i, j), p.Cost(i, j))
+			}
+		}
+	}
+
+	// With every node in one place only the tolls are left to pay
+	for i := range p.Nodes {
+		p.Nodes[i].Location = p.Nodes[0].Location
+	}
+	q = withDistanceMatrix(p)
+	sol := TabuSearch(context.Background(), q)
+	want := 0.0
+	for _, r := range sol.Routes {
+		for k := 1; k < len(r.Stops); k++ {
+			want += p.Tolls[r.Stops[k-1]][r.Stops[k]] / p.CostPerKm
+		}
+	}
+	if got := (vrpPlan{routes: innerRoutes(p, sol)}).distance(q); got != want {
+		t.Errorf("objective %.3f, tolls alone %.3f", got, want)
+	}
+}
+
+// innerRoutes is sol's routes without their endpoints, by vehicle index
+func innerRoutes(p *problem.Problem, sol problem.Solution) [][]int {
+	routes := make([][]int, len(p.Vehicles))
+	for _, r := range sol.Routes {
+		routes[r.Vehicle] = r.Stops[1 : len(r.Stops)-1]
+	}
+	return routes
+}
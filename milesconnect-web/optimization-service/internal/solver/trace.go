@@ -0,0 +1,113 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Trace notes limits: a debug bundle keeps a solve's summary, a verbose
+// replay its progress too
+const (
+	traceNotes        = 100
+	verboseTraceNotes = 5000
+)
+
+// Trace collects what a solve did, to debug it afterwards: the random seeds
+// it drew and notes on its progress. A replay fixes the seeds to rerun a
+// solve as it went. A nil Trace records nothing.
+type Trace struct {
+	// Verbose asks for every improvement, not only each solve's summary
+	Verbose bool
+
+	mu      sync.Mutex
+	started time.Time
+	fixed   []int64
+	seeds   []int64
+	notes   []TraceNote
+	dropped int
+}
+
+// TraceNote is one line of a trace, Millis after it began
+type TraceNote struct {
+	Millis  int64  `json:"ms"`
+	Message string `json:"message"`
+}
+
+// TraceReport is a finished trace
+type TraceReport struct {
+	Seeds   []int64     `json:"seeds,omitempty"`
+	Notes   []TraceNote `json:"notes"`
+	Dropped int         `json:"dropped,omitempty"` // Notes past the limit, not kept
+}
+
+// NewTrace starts a trace
+func NewTrace(verbose bool) *Trace {
+	return &Trace{Verbose: verbose, started: time.Now()}
+}
+
+// FixSeeds has solves under t draw seeds in order, as a traced solve drew
+// them; solves past the last draw their own
+func (t *Trace) FixSeeds(seeds ...int64) {
+	t.fixed = append([]int64(nil), seeds...)
+}
+
+// Seed returns the seed a solve is to use in place of drawn, and notes it
+func (t *Trace) Seed(drawn int64) int64 {
+	if t == nil {
+		return drawn
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.fixed) > 0 {
+		drawn, t.fixed = t.fixed[0], t.fixed[1:]
+	}
+	t.seeds = append(t.seeds, drawn)
+	return drawn
+}
+
+// Notef adds a note
+func (t *Trace) Notef(format string, args ...any) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limit := traceNotes
+	if t.Verbose {
+		limit = verboseTraceNotes
+	}
+	if len(t.notes) >= limit {
+		t.dropped++
+		return
+	}
+	t.notes = append(t.notes, TraceNote{Millis: time.Since(t.started).Milliseconds(), Message: fmt.Sprintf(format, args...)})
+}
+
+// Verbosef adds a note when t is verbose
+func (t *Trace) Verbosef(format string, args ...any) {
+	if t != nil && t.Verbose {
+		t.Notef(format, args...)
+	}
+}
+
+// Report returns what t has collected so far
+func (t *Trace) Report() TraceReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TraceReport{Seeds: append([]int64(nil), t.seeds...), Notes: append([]TraceNote{}, t.notes...), Dropped: t.dropped}
+}
+
+type traceKey struct{}
+
+// WithTrace has solves run under ctx record to t
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace solves under ctx record to, or nil
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
@@ -3,24 +3,71 @@ package solver
 import (
 	"math"
 	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/spatial"
 )
 
+// spatialIndexNodes is the size from which nearest-neighbour searches over
+// great-circle distances go through a k-d tree instead of scanning every
+// node; below it the scan is as fast
+const spatialIndexNodes = 200
+
+// useSpatialIndex reports whether p is large enough for a k-d tree and its
+// distances are great-circle ones the tree can answer for
+func useSpatialIndex(p *problem.Problem) bool {
+	return p.Matrix == nil && len(p.Nodes) >= spatialIndexNodes
+}
+
+// nodeIndex builds a k-d tree over p's nodes
+func nodeIndex(p *problem.Problem) *spatial.Tree {
+	locs := make([]models.Location, len(p.Nodes))
+	for i, n := range p.Nodes {
+		locs[i] = n.Location
+	}
+	return spatial.New(locs)
+}
+
 // SolveTSPNearestNeighbor solves the TSP using the Nearest Neighbor heuristic
 func SolveTSPNearestNeighbor(req models.OptimizationRequest) models.OptimizationResponse {
-	// 1. Start at 'Start'
-	current := req.Start
-	route := []models.Location{current}
-	visited := make([]bool, len(req.Waypoints))
+	p := problem.FromRouteRequest(req)
+	return NearestNeighbor(p).ToRouteResponse(p)
+}
+
+// NearestNeighbor routes the first vehicle of p through every other node
+func NearestNeighbor(p *problem.Problem) problem.Solution {
+	v := p.Vehicles[0]
+
+	// 1. Start at the vehicle's start node
+	current := v.Start
+	route := []int{current}
+	visited := make([]bool, len(p.Nodes))
+	visited[v.Start] = true
+	visited[v.End] = true
 	totalDist := 0.0
 
-	count := len(req.Waypoints)
+	count := len(p.Nodes) - 2
+	var index *spatial.Tree
+	if useSpatialIndex(p) {
+		index = nodeIndex(p)
+		index.Remove(v.Start)
+		index.Remove(v.End)
+	}
 	for i := 0; i < count; i++ {
+		if index != nil {
+			next := index.Nearest(p.Nodes[current].Location, 1)[0]
+			index.Remove(next)
+			totalDist += p.Cost(current, next)
+			current = next
+			route = append(route, current)
+			continue
+		}
+
 		nearestIdx := -1
 		minDist := math.MaxFloat64
 
-		for j, wp := range req.Waypoints {
+		for j := range p.Nodes {
 			if !visited[j] {
-				dist := haversine(current, wp)
+				dist := p.Cost(current, j)
 				if dist < minDist {
 					minDist = dist
 					nearestIdx = j
@@ -30,35 +77,24 @@ func SolveTSPNearestNeighbor(req models.OptimizationRequest) models.Optimization
 
 		if nearestIdx != -1 {
 			visited[nearestIdx] = true
-			current = req.Waypoints[nearestIdx]
+			current = nearestIdx
 			route = append(route, current)
 			totalDist += minDist
 		}
 	}
 
-	// 2. Finally go to 'End'
-	finalLeg := haversine(current, req.End)
-	route = append(route, req.End)
+	// 2. Finally go to the end node
+	finalLeg := p.Cost(current, v.End)
+	route = append(route, v.End)
 	totalDist += finalLeg
 
-	return models.OptimizationResponse{
-		Route:       route,
-		TotalDistKm: totalDist,
+	return problem.Solution{
+		Routes:     []problem.Route{{Vehicle: 0, Stops: route, DistanceKm: totalDist}},
+		DistanceKm: totalDist,
 	}
 }
 
-// haversine calculates distance between two points in km
-func haversine(p1, p2 models.Location) float64 {
-	const R = 6371 // Earth radius in km
-	dLat := (p2.Lat - p1.Lat) * (math.Pi / 180.0)
-	dLon := (p2.Lng - p1.Lng) * (math.Pi / 180.0)
-
-	lat1 := p1.Lat * (math.Pi / 180.0)
-	lat2 := p2.Lat * (math.Pi / 180.0)
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Sin(dLon/2)*math.Sin(dLon/2)*math.Cos(lat1)*math.Cos(lat2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	return R * c
+// DistanceKm returns the great-circle distance between two points in km
+func DistanceKm(p1, p2 models.Location) float64 {
+	return problem.Haversine(p1, p2)
 }
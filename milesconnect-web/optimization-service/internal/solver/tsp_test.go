@@ -0,0 +1,127 @@
+package solver
+
+import (
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"testing"
+)
+
+func TestNearestNeighborBounds(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			sol := NearestNeighbor(p)
+
+			if got := sol.DistanceKm; got < inst.OptimumKm-1e-6 {
+				t.Fatalf("distance %.4f beats the known optimum %.4f", got, inst.OptimumKm)
+			}
+			if stops := sol.Routes[0].Stops; len(stops) != len(p.Nodes) {
+				t.Fatalf("route has %d stops, want %d", len(stops), len(p.Nodes))
+			}
+		})
+	}
+}
+
+func TestNearestNeighborLineIsOptimal(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		if inst.Name != "line" {
+			continue
+		}
+		resp := SolveTSPNearestNeighbor(inst.Request)
+		if diff := resp.TotalDistKm - inst.OptimumKm; diff > 1e-6 {
+			t.Errorf("line instance: got %.4f, want %.4f", resp.TotalDistKm, inst.OptimumKm)
+		}
+	}
+}
+
+func TestExactMatchesKnownOptima(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		if len(inst.Request.Waypoints) > MaxExactWaypoints {
+			continue
+		}
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			sol := Exact(p)
+			if diff := sol.DistanceKm - inst.OptimumKm; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("exact = %.4f, want %.4f", sol.DistanceKm, inst.OptimumKm)
+			}
+			if got := RouteDistance(p, sol.Routes[0].Stops); got-sol.DistanceKm > 1e-6 {
+				t.Errorf("reported distance %.4f does not match the route (%.4f)", sol.DistanceKm, got)
+			}
+		})
+	}
+}
+
+func TestTwoOptNeverWorsensNearestNeighbor(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			nn := NearestNeighbor(p)
+			improved := TwoOpt(p, nn)
+
+			if improved.DistanceKm > nn.DistanceKm+1e-6 {
+				t.Errorf("2-opt %.4f is worse than nearest neighbor %.4f", improved.DistanceKm, nn.DistanceKm)
+			}
+			if improved.DistanceKm < inst.OptimumKm-1e-6 {
+				t.Errorf("2-opt %.4f beats the known optimum %.4f", improved.DistanceKm, inst.OptimumKm)
+			}
+		})
+	}
+}
+
+func TestImproveRouteKeepsEveryStop(t *testing.T) {
+	for _, inst := range fixtures.RouteInstances() {
+		t.Run(inst.Name, func(t *testing.T) {
+			p := problem.FromRouteRequest(inst.Request)
+			stops := append([]int(nil), NearestNeighbor(p).Routes[0].Stops...)
+			before := RouteDistance(p, stops)
+
+			ImproveRoute(p, stops)
+
+			seen := map[int]bool{}
+			for _, s := range stops {
+				seen[s] = true
+			}
+			if len(seen) != len(p.Nodes) || stops[0] != 0 || stops[len(stops)-1] != len(p.Nodes)-1 {
+				t.Fatalf("improved route %v is not a valid tour", stops)
+			}
+			if after := RouteDistance(p, stops); after > before+1e-6 {
+				t.Errorf("distance grew from %.4f to %.4f", before, after)
+			}
+		})
+	}
+}
+
+func TestNearestNeighborIndexMatchesScan(t *testing.T) {
+	req, err := generator.RouteRequest(generator.Config{Size: 3 * spatialIndexNodes, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := problem.FromRouteRequest(req)
+	if !useSpatialIndex(p) {
+		t.Fatal("instance too small to use the index")
+	}
+
+	// The same distances as a matrix force the scan
+	scan := *p
+	scan.Matrix = make([][]float64, len(p.Nodes))
+	for i := range p.Nodes {
+		scan.Matrix[i] = make([]float64, len(p.Nodes))
+		for j := range p.Nodes {
+			scan.Matrix[i][j] = p.Distance(i, j)
+		}
+	}
+
+	got, want := NearestNeighbor(p), NearestNeighbor(&scan)
+	if !slices.Equal(got.Routes[0].Stops, want.Routes[0].Stops) {
+		t.Error("indexed tour differs from the scanned one")
+	}
+	near, wantNear := nearestCandidates(p, glsCandidates), nearestCandidates(&scan, glsCandidates)
+	for i := range near {
+		if !slices.Equal(near[i], wantNear[i]) {
+			t.Fatalf("candidates of %d: %v, want %v", i, near[i], wantNear[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package solver
+
+import (
+	"context"
+	"milesconnect-optimization/internal/problem"
+	"time"
+)
+
+// maxTwoOptPasses caps improvement passes; each pass is O(n^2)
+const maxTwoOptPasses = 50
+
+// TwoOpt improves the first route of sol by reversing segments while that
+// shortens it. The start and end stops stay fixed.
+func TwoOpt(p *problem.Problem, sol problem.Solution) problem.Solution {
+	return TwoOptContext(context.Background(), p, sol)
+}
+
+// TwoOptContext is TwoOpt that stops improving once ctx's deadline has
+// passed, returning the route as it stands
+func TwoOptContext(ctx context.Context, p *problem.Problem, sol problem.Solution) problem.Solution {
+	if len(sol.Routes) == 0 {
+		return sol
+	}
+	stops := append([]int(nil), sol.Routes[0].Stops...)
+	twoOptRoute(ctx, p, stops)
+	return singleRoute(p, stops)
+}
+
+// twoOptRoute improves stops in place until ctx's deadline, if any
+func twoOptRoute(ctx context.Context, p *problem.Problem, stops []int) {
+	deadline, _ := ctx.Deadline()
+	twoOptWith(p.Distance, p.Matrix != nil, deadline, stops)
+}
+
+// twoOptWith improves stops in place under an arbitrary edge cost, giving up
+// after the current row once deadline (if set) has passed
+func twoOptWith(dist func(i, j int) float64, asymmetric bool, deadline time.Time, stops []int) {
+	n := len(stops)
+
+	for pass := 0; pass < maxTwoOptPasses; pass++ {
+		improved := false
+		for i := 1; i < n-2; i++ {
+			for j := i + 1; j < n-1; j++ {
+				a, b, c, d := stops[i-1], stops[i], stops[j], stops[j+1]
+				delta := dist(a, c) + dist(b, d) - dist(a, b) - dist(c, d)
+				if asymmetric {
+					// Reversing the segment also flips the direction of its inner legs
+					for k := i; k < j; k++ {
+						delta += dist(stops[k+1], stops[k]) - dist(stops[k], stops[k+1])
+					}
+				}
+				if delta < -1e-9 {
+					reverse(stops[i : j+1])
+					improved = true
+				}
+			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func reverse(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,219 @@
+package solver
+
+import (
+	"math"
+	"milesconnect-optimization/internal/problem"
+	"sort"
+)
+
+// vrpEpsilon absorbs float rounding in capacity, schedule and cost checks
+const vrpEpsilon = 1e-9
+
+// vrpPlan is the working form of a multi-vehicle routing solution: the
+// inner stops of each vehicle's route (endpoints excluded), by vehicle index
+type vrpPlan struct {
+	routes     [][]int
+	unassigned []int
+}
+
+func (pl vrpPlan) clone() vrpPlan {
+	c := vrpPlan{routes: make([][]int, len(pl.routes)), unassigned: append([]int(nil), pl.unassigned...)}
+	for i, r := range pl.routes {
+		c.routes[i] = append([]int(nil), r...)
+	}
+	return c
+}
+
+// better reports whether pl serves more nodes than other, or as many over a
+// shorter distance
+func (pl vrpPlan) better(p *problem.Problem, other vrpPlan) bool {
+	if len(pl.unassigned) != len(other.unassigned) {
+		return len(pl.unassigned) < len(other.unassigned)
+	}
+	return pl.distance(p) < other.distance(p)-vrpEpsilon
+}
+
+func (pl vrpPlan) distance(p *problem.Problem) float64 {
+	total := 0.0
+	for vi, inner := range pl.routes {
+		total += innerDistance(p, p.Vehicles[vi], inner)
+	}
+	return total
+}
+
+// solution renders the plan; vehicles without stops get no route
+func (pl vrpPlan) solution(p *problem.Problem) problem.Solution {
+	sol := problem.Solution{Unassigned: append([]int(nil), pl.unassigned...)}
+	for vi, inner := range pl.routes {
+		if len(inner) == 0 {
+			continue
+		}
+		v := p.Vehicles[vi]
+		stops := make([]int, 0, len(inner)+2)
+		stops = append(stops, v.Start)
+		stops = append(stops, inner...)
+		stops = append(stops, v.End)
+
+		r := problem.Route{Vehicle: vi, Stops: stops, DistanceKm: RouteDistance(p, stops), LoadKg: v.InitialLoadKg}
+		for _, n := range inner {
+			r.LoadKg += p.Nodes[n].DemandKg
+		}
+		sol.Routes = append(sol.Routes, r)
+		sol.DistanceKm += r.DistanceKm
+	}
+	return sol
+}
+
+// innerDistance is the length of v's route through inner. An idle vehicle
+// is not dispatched, so it costs nothing even when its start and end differ.
+func innerDistance(p *problem.Problem, v problem.Vehicle, inner []int) float64 {
+	if len(inner) == 0 {
+		return 0
+	}
+	d, prev := 0.0, v.Start
+	for _, n := range inner {
+		d += p.Cost(prev, n)
+		prev = n
+	}
+	return d + p.Cost(prev, v.End)
+}
+
+// routeFeasible reports whether v may serve every stop of inner, within its
+// capacity at every point of the route once returns come aboard, and,
+// when the problem declares them, every time window
+func routeFeasible(p *problem.Problem, v problem.Vehicle, inner []int) bool {
+	if p.Constraints.Compatibility {
+		for _, n := range inner {
+			if !p.Compatible(v, n) {
+				return false
+			}
+		}
+	}
+	if p.Constraints.Capacity && v.CapacityKg > 0 && p.PeakLoad(v, inner) > v.CapacityKg+vrpEpsilon {
+		return false
+	}
+
+	if p.Constraints.TimeWindows {
+		t, prev := v.DepartHours, v.Start
+		for _, n := range inner {
+			node := p.Nodes[n]
+			t = p.Arrival(v, prev, n, t+p.Nodes[prev].ServiceHours)
+			if node.DueHours > 0 && t > node.DueHours+vrpEpsilon {
+				return false
+			}
+			prev = n
+		}
+	}
+	return true
+}
+
+// vrpCustomers lists the nodes to route: everything that is not a vehicle
+// endpoint
+func vrpCustomers(p *problem.Problem) []int {
+	endpoint := make([]bool, len(p.Nodes))
+	for _, v := range p.Vehicles {
+		if v.Start >= 0 {
+			endpoint[v.Start] = true
+		}
+		if v.End >= 0 {
+			endpoint[v.End] = true
+		}
+	}
+
+	customers := make([]int, 0, len(p.Nodes))
+	for i := range p.Nodes {
+		if !endpoint[i] {
+			customers = append(customers, i)
+		}
+	}
+	return customers
+}
+
+// cheapestInsertion builds a plan by inserting nodes, tightest due time
+// first and then heaviest first, at the cheapest feasible position of any
+// route. Nodes no vehicle can take are left unassigned.
+func cheapestInsertion(p *problem.Problem) vrpPlan {
+	order := vrpCustomers(p)
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := p.Nodes[order[i]], p.Nodes[order[j]]
+		if (a.DueHours > 0) != (b.DueHours > 0) {
+			return a.DueHours > 0
+		}
+		if a.DueHours != b.DueHours {
+			return a.DueHours < b.DueHours
+		}
+		return a.DemandKg > b.DemandKg
+	})
+
+	pl := vrpPlan{routes: make([][]int, len(p.Vehicles))}
+	for _, n := range order {
+		if !insertCheapest(p, &pl, n) {
+			pl.unassigned = append(pl.unassigned, n)
+		}
+	}
+	return pl
+}
+
+// insertCheapest inserts n where it adds the least distance without breaking
+// a constraint, and reports whether any position was feasible
+func insertCheapest(p *problem.Problem, pl *vrpPlan, n int) bool {
+	best, bestV := slot{delta: math.Inf(1)}, -1
+	var buf []int
+	for vi := range p.Vehicles {
+		var s slot
+		s, buf = bestSlot(p, vi, pl.routes[vi], n, buf)
+		if s.ok && s.delta < best.delta {
+			best, bestV = s, vi
+		}
+	}
+
+	if bestV < 0 {
+		return false
+	}
+	pl.routes[bestV] = insertAt(nil, pl.routes[bestV], best.pos, n)
+	return true
+}
+
+// slot is the cheapest feasible insertion of a node into one route
+type slot struct {
+	pos   int
+	delta float64
+	ok    bool
+}
+
+// bestSlot finds the cheapest feasible position for n in vehicle vi's route
+func bestSlot(p *problem.Problem, vi int, inner []int, n int, buf []int) (slot, []int) {
+	v := p.Vehicles[vi]
+	best := slot{delta: math.Inf(1)}
+	if !p.Compatible(v, n) {
+		return best, buf
+	}
+	for pos := 0; pos <= len(inner); pos++ {
+		prev, next := v.Start, v.End
+		if pos > 0 {
+			prev = inner[pos-1]
+		}
+		if pos < len(inner) {
+			next = inner[pos]
+		}
+		delta := p.Cost(prev, n) + p.Cost(n, next) - p.Cost(prev, next)
+		if len(inner) == 0 {
+			delta += p.Cost(v.Start, v.End) // Dispatching an idle vehicle
+		}
+		if delta >= best.delta {
+			continue
+		}
+		buf = insertAt(buf[:0], inner, pos, n)
+		if routeFeasible(p, v, buf) {
+			best = slot{pos: pos, delta: delta, ok: true}
+		}
+	}
+	return best, buf
+}
+
+// insertAt appends inner with n inserted at position i to dst
+func insertAt(dst, inner []int, i, n int) []int {
+	dst = append(dst, inner[:i]...)
+	dst = append(dst, n)
+	return append(dst, inner[i:]...)
+}
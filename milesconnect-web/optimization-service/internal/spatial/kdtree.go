@@ -0,0 +1,170 @@
+// Package spatial answers nearest-neighbour queries over locations in
+// O(log n) rather than scanning every point.
+package spatial
+
+import (
+	"math"
+	"milesconnect-optimization/internal/models"
+	"sort"
+)
+
+// Tree is a k-d tree over points on the unit sphere. Straight-line (chord)
+// distance between unit vectors grows with great-circle distance, so the
+// nearest points by chord are exactly the nearest by haversine, with no
+// trouble at the poles or the antimeridian. Points can be removed, which
+// greedy constructions use to skip nodes they have already placed.
+type Tree struct {
+	xyz   [][3]float64 // By point index
+	order []int        // Point indices; the median of each range splits it
+	pos   []int        // Where each point sits in order
+	alive []int        // Live points in the subtree rooted at each slot
+	dead  []bool       // By point index
+}
+
+// New indexes points; queries return indices into it
+func New(points []models.Location) *Tree {
+	n := len(points)
+	t := &Tree{
+		xyz:   make([][3]float64, n),
+		order: make([]int, n),
+		pos:   make([]int, n),
+		alive: make([]int, n),
+		dead:  make([]bool, n),
+	}
+	for i, p := range points {
+		t.xyz[i] = unit(p)
+		t.order[i] = i
+	}
+	t.build(0, n, 0)
+	for slot, i := range t.order {
+		t.pos[i] = slot
+	}
+	return t
+}
+
+func unit(p models.Location) [3]float64 {
+	lat, lng := p.Lat*math.Pi/180, p.Lng*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lng), math.Cos(lat) * math.Sin(lng), math.Sin(lat)}
+}
+
+// build arranges order[lo:hi] so its middle slot splits the rest on axis
+func (t *Tree) build(lo, hi, axis int) {
+	if lo >= hi {
+		return
+	}
+	seg := t.order[lo:hi]
+	sort.Slice(seg, func(a, b int) bool {
+		pa, pb := t.xyz[seg[a]][axis], t.xyz[seg[b]][axis]
+		if pa != pb {
+			return pa < pb
+		}
+		return seg[a] < seg[b]
+	})
+	mid := (lo + hi) / 2
+	t.alive[mid] = hi - lo
+	t.build(lo, mid, (axis+1)%3)
+	t.build(mid+1, hi, (axis+1)%3)
+}
+
+// Len is the number of points not removed
+func (t *Tree) Len() int {
+	if len(t.order) == 0 {
+		return 0
+	}
+	return t.alive[len(t.order)/2]
+}
+
+// Remove drops point i from later queries
+func (t *Tree) Remove(i int) {
+	if t.dead[i] {
+		return
+	}
+	t.dead[i] = true
+	target := t.pos[i]
+	lo, hi := 0, len(t.order)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		t.alive[mid]--
+		switch {
+		case target == mid:
+			return
+		case target < mid:
+			hi = mid
+		default:
+			lo = mid + 1
+		}
+	}
+}
+
+// Nearest returns up to k live points closest to q, nearest first; equally
+// distant points come in index order
+func (t *Tree) Nearest(q models.Location, k int) []int {
+	if k <= 0 {
+		return nil
+	}
+	s := search{t: t, q: unit(q), k: k}
+	s.visit(0, len(t.order), 0)
+	out := make([]int, len(s.best))
+	for i, c := range s.best {
+		out[i] = c.idx
+	}
+	return out
+}
+
+type candidate struct {
+	idx int
+	d   float64 // Squared chord
+}
+
+// search keeps the k best candidates found so far, sorted
+type search struct {
+	t    *Tree
+	q    [3]float64
+	k    int
+	best []candidate
+}
+
+func (s *search) visit(lo, hi, axis int) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	if s.t.alive[mid] == 0 {
+		return
+	}
+	i := s.t.order[mid]
+	if !s.t.dead[i] {
+		s.offer(candidate{i, sq(s.q, s.t.xyz[i])})
+	}
+
+	diff := s.q[axis] - s.t.xyz[i][axis]
+	near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+	if diff > 0 {
+		near, far = far, near
+	}
+	next := (axis + 1) % 3
+	s.visit(near[0], near[1], next)
+	if len(s.best) < s.k || diff*diff <= s.best[len(s.best)-1].d {
+		s.visit(far[0], far[1], next)
+	}
+}
+
+func (s *search) offer(c candidate) {
+	at := sort.Search(len(s.best), func(j int) bool {
+		b := s.best[j]
+		return b.d > c.d || (b.d == c.d && b.idx > c.idx)
+	})
+	if at >= s.k {
+		return
+	}
+	if len(s.best) < s.k {
+		s.best = append(s.best, candidate{})
+	}
+	copy(s.best[at+1:], s.best[at:])
+	s.best[at] = c
+}
+
+func sq(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,70 @@
+package spatial
+
+import (
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/problem"
+	"slices"
+	"testing"
+)
+
+// bruteNearest is the scan the tree replaces
+func bruteNearest(points []models.Location, dead map[int]bool, q models.Location, k int) []int {
+	var idx []int
+	for i := range points {
+		if !dead[i] {
+			idx = append(idx, i)
+		}
+	}
+	slices.SortStableFunc(idx, func(a, b int) int {
+		da, db := problem.Haversine(q, points[a]), problem.Haversine(q, points[b])
+		switch {
+		case da < db:
+			return -1
+		case da > db:
+			return 1
+		}
+		return 0
+	})
+	return idx[:min(k, len(idx))]
+}
+
+func TestNearestMatchesScan(t *testing.T) {
+	points, err := generator.Points(generator.Config{Size: 1500, Distribution: generator.Clustered, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Points across the antimeridian and near a pole
+	points = append(points, models.Location{Lat: 10, Lng: 179.9}, models.Location{Lat: 10, Lng: -179.9}, models.Location{Lat: 89.9, Lng: 0})
+
+	tree := New(points)
+	dead := map[int]bool{}
+	queries := append(points[:50:50], models.Location{Lat: 10, Lng: 179.95}, models.Location{Lat: 89.95, Lng: 120})
+	for round := range 3 {
+		for _, q := range queries {
+			got, want := tree.Nearest(q, 5), bruteNearest(points, dead, q, 5)
+			if !slices.Equal(got, want) {
+				t.Fatalf("round %d, near %v: got %v, want %v", round, q, got, want)
+			}
+		}
+		// Remove a tenth of the points and check again
+		for i := round; i < len(points); i += 10 {
+			tree.Remove(i)
+			dead[i] = true
+		}
+	}
+	if tree.Len() != len(points)-len(dead) {
+		t.Errorf("Len = %d, want %d", tree.Len(), len(points)-len(dead))
+	}
+}
+
+func TestNearestEmpty(t *testing.T) {
+	tree := New([]models.Location{{Lat: 1, Lng: 1}})
+	tree.Remove(0)
+	if got := tree.Nearest(models.Location{}, 3); len(got) != 0 {
+		t.Errorf("got %v from an emptied tree", got)
+	}
+	if got := New(nil).Nearest(models.Location{}, 1); len(got) != 0 {
+		t.Errorf("got %v from an empty tree", got)
+	}
+}
@@ -0,0 +1,199 @@
+// Package staticmap draws routes onto a PNG for emails and PDFs, where an
+// interactive map cannot run. It projects the routes with Web Mercator
+// onto a plain background sized to fit them, so it needs no tile provider
+// or network access; the image shows the routes' shape and stop order
+// rather than the streets under them.
+package staticmap
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"milesconnect-optimization/internal/models"
+)
+
+const (
+	MaxSize  = 2048 // Pixels on either side
+	padding  = 24   // Pixels kept clear around the routes
+	lineHalf = 2    // Route lines are 2*lineHalf+1 pixels wide
+	stopR    = 5    // Stop marker radius
+	depotR   = 7    // Half the side of the start's square marker
+)
+
+var (
+	background = color.RGBA{0xf4, 0xf1, 0xea, 0xff}
+	grid       = color.RGBA{0xe2, 0xdd, 0xd2, 0xff}
+	outline    = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	ink        = color.RGBA{0x33, 0x33, 0x33, 0xff}
+
+	// palette colours routes in turn
+	palette = []color.RGBA{
+		{0x1f, 0x77, 0xb4, 0xff}, {0xd6, 0x27, 0x28, 0xff}, {0x2c, 0xa0, 0x2c, 0xff},
+		{0xff, 0x7f, 0x0e, 0xff}, {0x94, 0x67, 0xbd, 0xff}, {0x8c, 0x56, 0x4b, 0xff},
+		{0xe3, 0x77, 0xc2, 0xff}, {0x17, 0xbe, 0xcf, 0xff},
+	}
+)
+
+// ErrNothingToDraw is returned for routes without a point
+var ErrNothingToDraw = errors.New("staticmap: no points to draw")
+
+// Render draws routes, each a vehicle's path from its start through its
+// stops, onto a width by height PNG. The start is a square, stops are dots
+// and a path back to the start is drawn without a second marker.
+func Render(w io.Writer, routes [][]models.Location, width, height int) error {
+	if width < 2*padding+1 || height < 2*padding+1 || width > MaxSize || height > MaxSize {
+		return fmt.Errorf("staticmap: size must be between %d and %d pixels a side", 2*padding+1, MaxSize)
+	}
+	proj, ok := fit(routes, width, height)
+	if !ok {
+		return ErrNothingToDraw
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := background
+			if x%64 == 0 || y%64 == 0 {
+				c = grid
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for i, route := range routes {
+		c := palette[i%len(palette)]
+		for j := 1; j < len(route); j++ {
+			ax, ay := proj(route[j-1])
+			bx, by := proj(route[j])
+			line(img, ax, ay, bx, by, c)
+		}
+	}
+	// Markers go over every line so no route hides another's stops
+	for i, route := range routes {
+		c := palette[i%len(palette)]
+		for j, p := range route {
+			x, y := proj(p)
+			switch {
+			case j == 0:
+				square(img, x, y, depotR+1, outline)
+				square(img, x, y, depotR, ink)
+			case j == len(route)-1 && p == route[0]:
+				// Back at the start
+			default:
+				disc(img, x, y, stopR+1, outline)
+				disc(img, x, y, stopR, c)
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// fit returns a projection from coordinates to pixels that fits every
+// point in the image with padding, the same scale on both axes
+func fit(routes [][]models.Location, width, height int) (func(models.Location) (int, int), bool) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, route := range routes {
+		for _, p := range route {
+			x, y := mercator(p)
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return nil, false
+	}
+	spanX, spanY := maxX-minX, maxY-minY
+	innerW, innerH := float64(width-2*padding), float64(height-2*padding)
+	scale := math.Inf(1)
+	if spanX > 0 {
+		scale = innerW / spanX
+	}
+	if spanY > 0 {
+		scale = min(scale, innerH/spanY)
+	}
+	if math.IsInf(scale, 1) { // A single point
+		scale = 0
+	}
+	// Centre the routes on both axes
+	offX := (float64(width) - spanX*scale) / 2
+	offY := (float64(height) - spanY*scale) / 2
+	return func(p models.Location) (int, int) {
+		x, y := mercator(p)
+		return int(math.Round(offX + (x-minX)*scale)), int(math.Round(offY + (y-minY)*scale))
+	}, true
+}
+
+// mercator projects p onto the unit square, y growing southward
+func mercator(p models.Location) (float64, float64) {
+	lat := math.Max(-85, math.Min(85, p.Lat)) * math.Pi / 180
+	x := (p.Lng + 180) / 360
+	y := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2
+	return x, y
+}
+
+// line draws a thick segment with Bresenham's algorithm
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+	for {
+		square(img, x0, y0, lineHalf, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func square(img *image.RGBA, cx, cy, half int, c color.RGBA) {
+	for y := cy - half; y <= cy+half; y++ {
+		for x := cx - half; x <= cx+half; x++ {
+			set(img, x, y, c)
+		}
+	}
+}
+
+func disc(img *image.RGBA, cx, cy, r int, c color.RGBA) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				set(img, cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+func set(img *image.RGBA, x, y int, c color.RGBA) {
+	if image.Pt(x, y).In(img.Rect) {
+		img.SetRGBA(x, y, c)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package staticmap
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"milesconnect-optimization/internal/models"
+	"testing"
+)
+
+func TestRenderDrawsRoutesInsideTheImage(t *testing.T) {
+	depot := models.Location{Lat: 28.61, Lng: 77.21}
+	routes := [][]models.Location{
+		{depot, {Lat: 28.70, Lng: 77.10}, {Lat: 28.75, Lng: 77.20}, depot},
+		{depot, {Lat: 28.40, Lng: 77.30}},
+	}
+	var buf bytes.Buffer
+	if err := Render(&buf, routes, 320, 200); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 200 {
+		t.Fatalf("bounds = %v", b)
+	}
+
+	proj, _ := fit(routes, 320, 200)
+	for i, route := range routes {
+		for _, p := range route[1:] {
+			if p == route[0] {
+				continue // The return to the start has no marker of its own
+			}
+			x, y := proj(p)
+			if x < padding || x > 320-padding || y < padding || y > 200-padding {
+				t.Errorf("%+v projects outside the padding to %d,%d", p, x, y)
+			}
+			if r, g, b, _ := img.At(x, y).RGBA(); r>>8 != uint32(palette[i].R) || g>>8 != uint32(palette[i].G) || b>>8 != uint32(palette[i].B) {
+				t.Errorf("stop %+v is not drawn in route %d's colour", p, i)
+			}
+		}
+	}
+	// North is up
+	if _, north := proj(models.Location{Lat: 28.75, Lng: 77.2}); north >= 100 {
+		t.Errorf("northernmost stop at y = %d", north)
+	}
+}
+
+func TestRenderRejects(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, [][]models.Location{{}}, 320, 200); !errors.Is(err, ErrNothingToDraw) {
+		t.Errorf("no points: got %v", err)
+	}
+	if err := Render(&buf, [][]models.Location{{{Lat: 1, Lng: 1}}}, 10, 5000); err == nil {
+		t.Error("bad size: want an error")
+	}
+	// One point is drawn in the middle
+	if err := Render(&buf, [][]models.Location{{{Lat: 1, Lng: 1}}}, 100, 100); err != nil {
+		t.Errorf("one point: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package templates
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+)
+
+// maintenanceSubdir holds vehicle maintenance schedules beside the templates
+const maintenanceSubdir = "maintenance"
+
+// Maintenance is a vehicle's service schedule: the days it is off the road
+// and how far it may run between services. Planning leaves a vehicle out on
+// its downtime days and once it is due for service.
+type Maintenance struct {
+	VehicleID      string     `json:"vehicle_id"`
+	Downtime       []Downtime `json:"downtime,omitempty"`
+	ServiceEveryKm float64    `json:"service_every_km,omitempty"` // 0 when mileage is not tracked
+	KmSinceService float64    `json:"km_since_service,omitempty"`
+	Tenant         string     `json:"tenant,omitempty"` // Owner, when planners are scoped to tenants
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Downtime is an inclusive range of days a vehicle is unavailable
+type Downtime struct {
+	From   string `json:"from"` // YYYY-MM-DD
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate checks the vehicle ID, the downtime dates and the mileage
+func (m Maintenance) Validate() error {
+	if !validName.MatchString(m.VehicleID) {
+		return errors.New("maintenance vehicle ID may only contain letters, digits, - and _")
+	}
+	for _, d := range m.Downtime {
+		from, err1 := time.Parse(time.DateOnly, d.From)
+		to, err2 := time.Parse(time.DateOnly, d.To)
+		if err1 != nil || err2 != nil {
+			return errors.New("downtime dates must be YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			return errors.New("downtime must end on or after its start")
+		}
+	}
+	if m.ServiceEveryKm < 0 || m.KmSinceService < 0 {
+		return errors.New("service mileage must not be negative")
+	}
+	return nil
+}
+
+// Down returns the downtime covering date, if any
+func (m Maintenance) Down(date time.Time) (Downtime, bool) {
+	day := date.Format(time.DateOnly)
+	for _, d := range m.Downtime {
+		if d.From <= day && day <= d.To {
+			return d, true
+		}
+	}
+	return Downtime{}, false
+}
+
+// RemainingKm is how far the vehicle may run before its next service; ok
+// is false when mileage is not tracked
+func (m Maintenance) RemainingKm() (km float64, ok bool) {
+	if m.ServiceEveryKm == 0 {
+		return 0, false
+	}
+	return m.ServiceEveryKm - m.KmSinceService, true
+}
+
+// Due reports whether the vehicle has run its service interval
+func (m Maintenance) Due() bool {
+	km, ok := m.RemainingKm()
+	return ok && km <= 0
+}
+
+// SaveMaintenance writes m to dir/maintenance/<vehicle>.json
+func SaveMaintenance(dir string, m Maintenance) error {
+	return saveJSON(filepath.Join(dir, maintenanceSubdir), m.VehicleID, m)
+}
+
+// DeleteMaintenance removes dir/maintenance/<vehicle>.json; a missing file
+// is not an error
+func DeleteMaintenance(dir, vehicleID string) error {
+	return deleteJSON(filepath.Join(dir, maintenanceSubdir), vehicleID)
+}
+
+// LoadMaintenance reads every schedule in dir/maintenance, sorted by vehicle
+func LoadMaintenance(dir string) ([]Maintenance, error) {
+	return loadJSON[Maintenance](filepath.Join(dir, maintenanceSubdir))
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"errors"
+	"milesconnect-optimization/internal/calendar"
+	"milesconnect-optimization/internal/models"
+	"milesconnect-optimization/internal/recurrence"
+	"time"
+)
+
+// StandingOrder is a recurring delivery, e.g. a daily drop at a store. It is
+// defined once and the template's daily plan includes every occurrence.
+type StandingOrder struct {
+	ID        string           `json:"id"`
+	Template  string           `json:"template"` // Template whose daily plan includes it
+	Stop      models.FleetStop `json:"stop"`     // Its ID is the order's
+	Schedule  string           `json:"schedule"` // RRULE subset, e.g. FREQ=WEEKLY;BYDAY=MO,TH
+	StartDate string           `json:"start_date"`
+	Closed    calendar.Closure `json:"closed,omitzero"`  // Occurrences on the customer's closed days move to the next open day
+	Tenant    string           `json:"tenant,omitempty"` // Owner, when planners are scoped to tenants
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Rule parses the order's schedule
+func (o StandingOrder) Rule() (recurrence.Rule, error) {
+	start, err := time.Parse(time.DateOnly, o.StartDate)
+	if err != nil {
+		return recurrence.Rule{}, errors.New("start_date must be YYYY-MM-DD")
+	}
+	return recurrence.Parse(o.Schedule, start)
+}
+
+// Validate checks the order's ID, template and schedule; the stop is
+// checked like any fleet stop when a plan includes it
+func (o StandingOrder) Validate() error {
+	if !validName.MatchString(o.ID) {
+		return errors.New("standing order ID may only contain letters, digits, - and _")
+	}
+	if !validName.MatchString(o.Template) {
+		return errors.New("standing order needs a template")
+	}
+	if _, err := o.Rule(); err != nil {
+		return err
+	}
+	return o.Closed.Validate()
+}
+
+// maxCarryDays bounds how far back Due looks for occurrences moved off
+// non-working days
+const maxCarryDays = 31
+
+// Due returns the stops of the orders for template due on date, and the
+// IDs of those that occur on date but are moved because the customer is
+// closed or holiday reports it a holiday. A moved occurrence is delivered
+// on the next day that is neither, so the stops include orders that fell
+// on the non-working days just before date. holiday may be nil.
+func Due(orders []StandingOrder, template string, date time.Time, holiday func(time.Time) bool) (stops []models.FleetStop, closed []string) {
+	stops = []models.FleetStop{}
+	for _, o := range orders {
+		if o.Template != template {
+			continue
+		}
+		rule, err := o.Rule()
+		if err != nil {
+			continue
+		}
+		working := func(d time.Time) bool {
+			return !o.Closed.Closed(d) && (holiday == nil || !holiday(d))
+		}
+		if !working(date) {
+			if rule.Occurs(date) {
+				closed = append(closed, o.ID)
+			}
+			continue
+		}
+		due := rule.Occurs(date)
+		for d, n := date.AddDate(0, 0, -1), 0; !due && n < maxCarryDays && !working(d); d, n = d.AddDate(0, 0, -1), n+1 {
+			due = rule.Occurs(d)
+		}
+		if !due {
+			continue
+		}
+		s := o.Stop
+		s.ID = o.ID
+		stops = append(stops, s)
+	}
+	return stops, closed
+}
@@ -0,0 +1,92 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ordersSubdir holds standing orders beside the templates
+const ordersSubdir = "orders"
+
+var errBadName = errors.New("name may only contain letters, digits, - and _")
+
+// Save writes t to dir/<name>.json
+func Save(dir string, t Template) error {
+	return saveJSON(dir, t.Name, t)
+}
+
+// Delete removes dir/<name>.json; a missing file is not an error
+func Delete(dir, name string) error {
+	return deleteJSON(dir, name)
+}
+
+// Load reads every *.json template in dir, sorted by name. A missing
+// directory simply has no templates.
+func Load(dir string) ([]Template, error) {
+	return loadJSON[Template](dir)
+}
+
+// SaveOrder writes o to dir/orders/<id>.json
+func SaveOrder(dir string, o StandingOrder) error {
+	return saveJSON(filepath.Join(dir, ordersSubdir), o.ID, o)
+}
+
+// DeleteOrder removes dir/orders/<id>.json; a missing file is not an error
+func DeleteOrder(dir, id string) error {
+	return deleteJSON(filepath.Join(dir, ordersSubdir), id)
+}
+
+// LoadOrders reads every standing order in dir/orders, sorted by ID
+func LoadOrders(dir string) ([]StandingOrder, error) {
+	return loadJSON[StandingOrder](filepath.Join(dir, ordersSubdir))
+}
+
+func saveJSON(dir, name string, v any) error {
+	if !validName.MatchString(name) {
+		return errBadName
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), body, 0o644)
+}
+
+func deleteJSON(dir, name string) error {
+	if !validName.MatchString(name) {
+		return errBadName
+	}
+	err := os.Remove(filepath.Join(dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// loadJSON reads every *.json file in dir, in file name order
+func loadJSON[T any](dir string) ([]T, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	list := []T{}
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var v T
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
@@ -0,0 +1,162 @@
+// Package templates stores recurring (weekly) route plans and turns them
+// into a day's fleet request. Many customers run mostly fixed milk runs: the
+// template fixes which vehicle serves which stops in which order, and only
+// that day's extra shipments need to be planned.
+package templates
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/calendar"
+	"milesconnect-optimization/internal/models"
+	"regexp"
+	"time"
+)
+
+// Template is a named weekly plan for one depot
+type Template struct {
+	Name      string               `json:"name"`
+	Depot     models.Location      `json:"depot"`
+	State     string               `json:"state,omitempty"` // ISO 3166-2:IN code for state holidays, e.g. MH
+	Vehicles  []models.VehicleInfo `json:"vehicles"`
+	SpeedKmph float64              `json:"speed_kmph,omitempty"`
+	Routes    []Route              `json:"routes"`
+	Tenant    string               `json:"tenant,omitempty"` // Owner, when planners are scoped to tenants
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// Route is a fixed stop sequence that one vehicle runs on the given days
+type Route struct {
+	VehicleID string   `json:"vehicle_id"`
+	Days      []string `json:"days,omitempty"` // mon..sun; empty means every day
+	Stops     []Stop   `json:"stops"`
+}
+
+// Stop is a template delivery; Days narrows the route's days for this stop
+// and Closed lists when the customer does not accept deliveries
+type Stop struct {
+	models.FleetStop
+	Days   []string         `json:"days,omitempty"`
+	Closed calendar.Closure `json:"closed,omitzero"`
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// runsOn reports whether days (empty meaning every day) includes day
+func runsOn(days []string, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if wd, err := calendar.ParseWeekday(d); err == nil && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the template's structure: known vehicles and days, at
+// most one route per vehicle per day, and unique stop IDs within a day.
+// Coordinates and quantities are checked like any fleet request when the
+// template is instantiated.
+func (t Template) Validate() error {
+	if !validName.MatchString(t.Name) {
+		return errors.New("template name may only contain letters, digits, - and _")
+	}
+	if len(t.Routes) == 0 {
+		return errors.New("template needs at least one route")
+	}
+	if !calendar.ValidState(t.State) {
+		return errors.New("template state must be a two-letter state code")
+	}
+	vehicles := map[string]bool{}
+	for _, v := range t.Vehicles {
+		if v.ID == "" || vehicles[v.ID] {
+			return errors.New("template vehicle IDs must be set and unique")
+		}
+		vehicles[v.ID] = true
+	}
+	for _, r := range t.Routes {
+		if !vehicles[r.VehicleID] {
+			return fmt.Errorf("route vehicle %q is not in the template", r.VehicleID)
+		}
+		if _, err := calendar.ParseWeekdays(r.Days); err != nil {
+			return err
+		}
+		for _, s := range r.Stops {
+			if s.ID == "" {
+				return errors.New("template stops need an ID")
+			}
+			if _, err := calendar.ParseWeekdays(s.Days); err != nil {
+				return err
+			}
+			if err := s.Closed.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		busy, seen := map[string]bool{}, map[string]bool{}
+		for _, r := range t.Routes {
+			if !runsOn(r.Days, day) {
+				continue
+			}
+			if busy[r.VehicleID] {
+				return fmt.Errorf("vehicle %q has two routes on %s", r.VehicleID, day)
+			}
+			busy[r.VehicleID] = true
+			for _, s := range r.Stops {
+				if !runsOn(s.Days, day) {
+					continue
+				}
+				if seen[s.ID] {
+					return fmt.Errorf("stop %q is served twice on %s", s.ID, day)
+				}
+				seen[s.ID] = true
+			}
+		}
+	}
+	return nil
+}
+
+// Instantiate builds the fleet request for date: the template stops that run
+// that weekday followed by the extra stops. fixed maps each vehicle ID to its
+// template stop IDs in route order; closed lists the template stops left out
+// because the customer is closed that day.
+func (t Template) Instantiate(date time.Time, extra []models.FleetStop) (req models.FleetRequest, fixed map[string][]string, closed []string, err error) {
+	day := date.Weekday()
+	depot := t.Depot
+	depot.State = cmp.Or(depot.State, t.State)
+	req = models.FleetRequest{
+		Depot:     depot,
+		Vehicles:  t.Vehicles,
+		SpeedKmph: t.SpeedKmph,
+		Stops:     []models.FleetStop{},
+	}
+	fixed = map[string][]string{}
+	seen := map[string]bool{}
+	for _, r := range t.Routes {
+		if !runsOn(r.Days, day) {
+			continue
+		}
+		for _, s := range r.Stops {
+			if runsOn(s.Days, day) && s.Closed.Closed(date) {
+				closed = append(closed, s.ID)
+			} else if runsOn(s.Days, day) {
+				req.Stops = append(req.Stops, s.FleetStop)
+				fixed[r.VehicleID] = append(fixed[r.VehicleID], s.ID)
+				seen[s.ID] = true
+			}
+		}
+	}
+	for _, s := range extra {
+		if s.ID == "" || seen[s.ID] {
+			return models.FleetRequest{}, nil, nil, errors.New("extra stops and standing orders need unique IDs distinct from the template's stops")
+		}
+		seen[s.ID] = true
+		req.Stops = append(req.Stops, s)
+	}
+	return req, fixed, closed, nil
+}
@@ -0,0 +1,257 @@
+package templates
+
+import (
+	"milesconnect-optimization/internal/calendar"
+	"milesconnect-optimization/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func stop(id string, days ...string) Stop {
+	return Stop{FleetStop: models.FleetStop{ID: id, Location: models.Location{Lat: 28.6, Lng: 77.2}, DemandKg: 10}, Days: days}
+}
+
+func milkRun() Template {
+	return Template{
+		Name:     "milk-run",
+		Depot:    models.Location{Lat: 28.61, Lng: 77.21},
+		Vehicles: []models.VehicleInfo{{ID: "V1", CapacityKg: 100}, {ID: "V2", CapacityKg: 100}},
+		Routes: []Route{
+			{VehicleID: "V1", Stops: []Stop{stop("A"), stop("B", "mon", "thu"), stop("C")}},
+			{VehicleID: "V2", Days: []string{"mon", "wed", "fri"}, Stops: []Stop{stop("D"), stop("E")}},
+			{VehicleID: "V2", Days: []string{"Tue"}, Stops: []Stop{stop("B")}},
+		},
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	tmpl := milkRun()
+	if err := tmpl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	req, fixed, _, err := tmpl.Instantiate(monday, []models.FleetStop{{ID: "X"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"V1": {"A", "B", "C"}, "V2": {"D", "E"}}
+	if !reflect.DeepEqual(fixed, want) {
+		t.Errorf("monday fixed = %v, want %v", fixed, want)
+	}
+	if n := len(req.Stops); n != 6 || req.Stops[5].ID != "X" {
+		t.Errorf("monday stops = %+v, want 5 template stops then X", req.Stops)
+	}
+
+	_, fixed, _, _ = tmpl.Instantiate(monday.AddDate(0, 0, 1), nil)
+	want = map[string][]string{"V1": {"A", "C"}, "V2": {"B"}}
+	if !reflect.DeepEqual(fixed, want) {
+		t.Errorf("tuesday fixed = %v, want %v", fixed, want)
+	}
+
+	// C's customer closes on Wednesdays
+	tmpl.Routes[0].Stops[2].Closed = calendar.Closure{Weekdays: []string{"wed"}}
+	_, fixed, closed, _ := tmpl.Instantiate(monday.AddDate(0, 0, 2), nil)
+	if !reflect.DeepEqual(fixed["V1"], []string{"A"}) || !reflect.DeepEqual(closed, []string{"C"}) {
+		t.Errorf("wednesday V1 = %v closed = %v, want [A] and [C]", fixed["V1"], closed)
+	}
+
+	if _, _, _, err := tmpl.Instantiate(monday, []models.FleetStop{{ID: "A"}}); err == nil {
+		t.Error("extra stop reusing a template ID: expected an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Template)
+	}{
+		{"bad name", func(t *Template) { t.Name = "a/b" }},
+		{"unknown vehicle", func(t *Template) { t.Routes[0].VehicleID = "V9" }},
+		{"unknown day", func(t *Template) { t.Routes[1].Days = []string{"someday"} }},
+		{"two routes on a day", func(t *Template) { t.Routes[2].Days = []string{"wed"} }},
+		{"stop twice on a day", func(t *Template) { t.Routes[1].Stops = append(t.Routes[1].Stops, stop("A")) }},
+		{"missing stop id", func(t *Template) { t.Routes[0].Stops[0].ID = "" }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := milkRun()
+			tc.mutate(&tmpl)
+			if err := tmpl.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSaveLoadDelete(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := milkRun()
+	if err := Save(dir, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	list, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !reflect.DeepEqual(list[0], tmpl) {
+		t.Fatalf("loaded %+v, want %+v", list, tmpl)
+	}
+
+	order := StandingOrder{ID: "daily", Template: tmpl.Name, Schedule: "FREQ=DAILY", StartDate: "2026-10-01"}
+	if err := SaveOrder(dir, order); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := Load(dir); err != nil || len(list) != 1 {
+		t.Errorf("templates beside an order: %d, %v", len(list), err)
+	}
+	if orders, err := LoadOrders(dir); err != nil || len(orders) != 1 || orders[0].ID != "daily" {
+		t.Errorf("loaded orders %+v, %v", orders, err)
+	}
+
+	if err := Delete(dir, tmpl.Name); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := Load(dir); len(list) != 0 {
+		t.Errorf("%d templates left after delete", len(list))
+	}
+}
+
+func TestDue(t *testing.T) {
+	orders := []StandingOrder{
+		{ID: "daily", Template: "milk-run", Schedule: "FREQ=DAILY", StartDate: "2026-10-01", Stop: models.FleetStop{ID: "ignored", DemandKg: 5}},
+		{ID: "mondays", Template: "milk-run", Schedule: "FREQ=WEEKLY;BYDAY=MO", StartDate: "2026-10-01"},
+		{ID: "other", Template: "other-run", Schedule: "FREQ=DAILY", StartDate: "2026-10-01"},
+		{ID: "broken", Template: "milk-run", Schedule: "FREQ=HOURLY", StartDate: "2026-10-01"},
+		{ID: "closed", Template: "milk-run", Schedule: "FREQ=DAILY", StartDate: "2026-10-01", Closed: calendar.Closure{Dates: []string{"2026-10-12"}}},
+	}
+	for _, o := range orders[:3] {
+		if err := o.Validate(); err != nil {
+			t.Fatalf("%s: %v", o.ID, err)
+		}
+	}
+	if err := orders[3].Validate(); err == nil {
+		t.Error("unsupported schedule: expected an error")
+	}
+
+	ids := func(stops []models.FleetStop) []string {
+		var list []string
+		for _, s := range stops {
+			list = append(list, s.ID)
+		}
+		return list
+	}
+	oct := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+
+	due, closed := Due(orders, "milk-run", oct(12), nil)
+	if len(due) != 2 || due[0].ID != "daily" || due[0].DemandKg != 5 || due[1].ID != "mondays" {
+		t.Errorf("monday due = %+v, want daily and mondays", due)
+	}
+	if len(closed) != 1 || closed[0] != "closed" {
+		t.Errorf("monday closed = %v, want [closed]", closed)
+	}
+	// The closed day's delivery moves to the next day, once
+	if due, _ := Due(orders, "milk-run", oct(13), nil); !slices.Equal(ids(due), []string{"daily", "closed"}) {
+		t.Errorf("tuesday due = %v", ids(due))
+	}
+	if due, _ := Due(orders, "milk-run", time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), nil); len(due) != 0 {
+		t.Errorf("before the start date due = %+v", due)
+	}
+
+	// A holiday on the Monday moves every order due then to Tuesday,
+	// Mondays' included
+	holiday := func(d time.Time) bool { return d.Equal(oct(12)) }
+	if due, closed := Due(orders, "milk-run", oct(12), holiday); len(due) != 0 || !slices.Equal(closed, []string{"daily", "mondays", "closed"}) {
+		t.Errorf("holiday due %v, closed %v", ids(due), closed)
+	}
+	if due, _ := Due(orders, "milk-run", oct(13), holiday); !slices.Equal(ids(due), []string{"daily", "mondays", "closed"}) {
+		t.Errorf("after the holiday due = %v", ids(due))
+	}
+	if due, _ := Due(orders, "milk-run", oct(14), holiday); !slices.Equal(ids(due), []string{"daily", "closed"}) {
+		t.Errorf("wednesday due = %v", ids(due))
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	m := Maintenance{
+		VehicleID:      "V1",
+		Downtime:       []Downtime{{From: "2026-10-14", To: "2026-10-16", Reason: "brakes"}},
+		ServiceEveryKm: 10000,
+		KmSinceService: 9800,
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for day, want := range map[int]bool{13: false, 14: true, 16: true, 17: false} {
+		if _, down := m.Down(time.Date(2026, 10, day, 0, 0, 0, 0, time.UTC)); down != want {
+			t.Errorf("October %d: down = %v, want %v", day, down, want)
+		}
+	}
+	if km, ok := m.RemainingKm(); !ok || km != 200 || m.Due() {
+		t.Errorf("remaining %v, %v; due %v", km, ok, m.Due())
+	}
+	m.KmSinceService = 10000
+	if !m.Due() {
+		t.Error("expected the vehicle to be due at its interval")
+	}
+	if _, ok := (Maintenance{VehicleID: "V2"}).RemainingKm(); ok {
+		t.Error("mileage is not tracked without an interval")
+	}
+
+	for _, bad := range []Maintenance{
+		{VehicleID: "V 1"},
+		{VehicleID: "V1", Downtime: []Downtime{{From: "2026-10-16", To: "2026-10-14"}}},
+		{VehicleID: "V1", ServiceEveryKm: -1},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
+
+func TestTenantSettings(t *testing.T) {
+	dir := t.TempDir()
+	if list, err := LoadTenantSettings(dir); err != nil || len(list) != 0 {
+		t.Fatalf("no file: %+v, %v", list, err)
+	}
+	saved := []TenantSettings{{Tenant: "globex", MaxStops: 50}, {Tenant: "acme", Profile: "fast", CostPerKm: 24}}
+	if err := SaveTenantSettings(dir, saved); err != nil {
+		t.Fatal(err)
+	}
+	list, err := LoadTenantSettings(dir)
+	if err != nil || len(list) != 2 || list[0].Tenant != "acme" || list[0].CostPerKm != 24 {
+		t.Fatalf("loaded %+v, %v", list, err)
+	}
+	if err := SaveTenantSettings(dir, saved[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(filepath.Join(dir, "tenants")); len(files) != 1 {
+		t.Errorf("files left after a rewrite: %v", files)
+	}
+	if templates, err := Load(dir); err != nil || len(templates) != 0 {
+		t.Errorf("settings read as templates: %+v, %v", templates, err)
+	}
+
+	for _, bad := range []TenantSettings{{Tenant: "a:b"}, {Objective: "time"}, {CostPerKm: -1}, {MaxStops: -1},
+		{Summary: &SummarySchedule{At: "07:30"}},
+		{Summary: &SummarySchedule{Recipients: []string{"Ops <ops@acme.example>"}, At: "07:30"}},
+		{Summary: &SummarySchedule{Recipients: []string{"ops@acme.example"}, At: "7.30am"}},
+		{Summary: &SummarySchedule{Recipients: []string{"ops@acme.example"}, At: "07:30", Formats: []string{"docx"}}},
+		{Chat: []ChatChannel{{Kind: "discord", URL: "https://discord.example/hook"}}},
+		{Chat: []ChatChannel{{Kind: "slack", URL: "http://hooks.slack.com/services/T0/B0/x"}}},
+		{Chat: []ChatChannel{{Kind: "teams", URL: "https://acme.webhook.office.com/x", Events: []string{"plan.deleted"}}}},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+	ok := TenantSettings{Summary: &SummarySchedule{Recipients: []string{"ops@acme.example", "hub@acme.example"}, At: "07:30", Formats: []string{SummaryPDF}},
+		Chat: []ChatChannel{{Kind: "slack", URL: "https://hooks.slack.com/services/T0/B0/x", Events: []string{ChatJobFailed}, UnassignedAbove: 5}}}
+	if err := ok.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,230 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"milesconnect-optimization/internal/models"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tenantsFile holds every tenant's settings beside the templates, in a
+// subdirectory so Load does not take it for one
+const tenantsFile = "tenants/settings.json"
+
+// TenantSettings are a tenant's defaults, applied to its planners' requests
+// wherever a request leaves a field unset, and its limits on request size.
+// Tenant is empty for the one unnamed tenant of a single-tenant deployment.
+type TenantSettings struct {
+	Tenant string `json:"tenant"`
+
+	// Profile is the SLA tier or tuned profile used when a request names
+	// neither a solver nor a profile
+	Profile string `json:"profile,omitempty"`
+
+	// Cost model for fleet plans, in the tenant's units
+	Objective         string  `json:"objective,omitempty"` // distance or cost
+	CostPerKm         float64 `json:"cost_per_km,omitempty"`
+	SpeedKmph         float64 `json:"speed_kmph,omitempty"`
+	FuelPricePerLitre float64 `json:"fuel_price_per_litre,omitempty"`
+
+	Units *models.Units `json:"units,omitempty"` // Replaces the deployment's
+
+	// Limits below the service's own; 0 leaves the service's
+	MaxStops    int `json:"max_stops,omitempty"` // Stops, waypoints, pickups or shipments
+	MaxVehicles int `json:"max_vehicles,omitempty"`
+
+	// Monthly quotas on optimization requests and the CPU seconds their
+	// solves use; 0 is unlimited
+	MonthlyRequests      int     `json:"monthly_requests,omitempty"`
+	MonthlySolverSeconds float64 `json:"monthly_solver_seconds,omitempty"`
+
+	// Summary mails the day's published plan to a distribution list
+	Summary *SummarySchedule `json:"summary,omitempty"`
+
+	// Chat posts planning events to Slack or Teams channels
+	Chat []ChatChannel `json:"chat,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SummarySchedule is when and to whom a tenant's daily plan summary goes.
+// It goes at At, or as soon after as the day's plan is published.
+type SummarySchedule struct {
+	Recipients []string `json:"recipients"`
+	At         string   `json:"at"`                // HH:MM, in the dispatch time zone
+	Formats    []string `json:"formats,omitempty"` // Attachments, pdf and xlsx; default both
+}
+
+// Summary attachment formats
+const (
+	SummaryPDF  = "pdf"
+	SummaryXLSX = "xlsx"
+)
+
+// ChatChannel is a Slack or Teams incoming webhook and the planning events
+// posted to it
+type ChatChannel struct {
+	Kind   string   `json:"kind"` // slack or teams
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // Default all
+
+	// UnassignedAbove is how many shipments a published plan may leave
+	// unassigned before the channel hears of them
+	UnassignedAbove int `json:"unassigned_above,omitempty"`
+}
+
+// Planning events posted to chat channels
+const (
+	ChatPlanPublished = "plan.published"
+	ChatJobFailed     = "job.failed"
+	ChatUnassigned    = "shipments.unassigned"
+)
+
+// Wants reports whether the channel is subscribed to event
+func (c ChatChannel) Wants(event string) bool {
+	return len(c.Events) == 0 || slices.Contains(c.Events, event)
+}
+
+// maxChatChannels bounds a tenant's chat channels
+const maxChatChannels = 10
+
+// maxRecipients bounds a distribution list; bigger lists belong on the
+// mail server
+const maxRecipients = 50
+
+// Validate checks the cost model, limits and quotas; units and the profile are
+// checked against what the service knows
+func (s TenantSettings) Validate() error {
+	if strings.Contains(s.Tenant, ":") {
+		return errors.New("tenant must be free of colons")
+	}
+	switch s.Objective {
+	case "", "distance", "cost":
+	default:
+		return errors.New("objective must be distance or cost")
+	}
+	if s.CostPerKm < 0 || s.SpeedKmph < 0 || s.FuelPricePerLitre < 0 {
+		return errors.New("cost per km, speed and fuel price must not be negative")
+	}
+	if s.MaxStops < 0 || s.MaxVehicles < 0 || s.MonthlyRequests < 0 || s.MonthlySolverSeconds < 0 {
+		return errors.New("limits and quotas must not be negative")
+	}
+	if s.Summary != nil {
+		if err := s.Summary.validate(); err != nil {
+			return err
+		}
+	}
+	if len(s.Chat) > maxChatChannels {
+		return fmt.Errorf("at most %d chat channels", maxChatChannels)
+	}
+	for _, c := range s.Chat {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c ChatChannel) validate() error {
+	switch c.Kind {
+	case "slack", "teams":
+	default:
+		return errors.New("chat kind must be slack or teams")
+	}
+	if u, err := url.Parse(c.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("chat url must be an https webhook URL")
+	}
+	for _, ev := range c.Events {
+		if ev != ChatPlanPublished && ev != ChatJobFailed && ev != ChatUnassigned {
+			return fmt.Errorf("chat events must be %s, %s or %s", ChatPlanPublished, ChatJobFailed, ChatUnassigned)
+		}
+	}
+	if c.UnassignedAbove < 0 {
+		return errors.New("chat unassigned_above must not be negative")
+	}
+	return nil
+}
+
+func (s SummarySchedule) validate() error {
+	if len(s.Recipients) == 0 || len(s.Recipients) > maxRecipients {
+		return fmt.Errorf("summary needs 1 to %d recipients", maxRecipients)
+	}
+	for _, to := range s.Recipients {
+		if a, err := mail.ParseAddress(to); err != nil || a.Address != to {
+			return fmt.Errorf("summary recipient %q is not a mail address", to)
+		}
+	}
+	if _, err := time.Parse("15:04", s.At); err != nil {
+		return errors.New("summary at must be HH:MM")
+	}
+	for _, f := range s.Formats {
+		if f != SummaryPDF && f != SummaryXLSX {
+			return errors.New("summary formats must be pdf or xlsx")
+		}
+	}
+	return nil
+}
+
+// SaveTenantSettings writes every tenant's settings to
+// dir/tenants/settings.json, sorted by tenant. It writes a temporary file
+// and renames it over the old one, so a crash never leaves half the
+// settings.
+func SaveTenantSettings(dir string, list []TenantSettings) error {
+	path := filepath.Join(dir, tenantsFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	list = slices.Clone(list)
+	slices.SortFunc(list, func(a, b TenantSettings) int { return strings.Compare(a.Tenant, b.Tenant) })
+	body, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".settings-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// LoadTenantSettings reads dir/tenants/settings.json. A missing file simply
+// has no settings.
+func LoadTenantSettings(dir string) ([]TenantSettings, error) {
+	path := filepath.Join(dir, tenantsFile)
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []TenantSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []TenantSettings
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return list, nil
+}
@@ -0,0 +1,49 @@
+package templates
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+)
+
+// trashSubdir holds deleted records until their retention ends
+const trashSubdir = "trash"
+
+// Trashed is a deleted record, kept so it can be restored. Kind says what
+// Record holds: template, standing_order, maintenance or session.
+type Trashed struct {
+	Kind      string          `json:"kind"`
+	ID        string          `json:"id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	DeletedAt time.Time       `json:"deleted_at"`
+	PurgeAt   time.Time       `json:"purge_at"`
+	Record    json.RawMessage `json:"record"`
+}
+
+// SaveTrashed writes t to dir/trash/<kind>/<id>.json
+func SaveTrashed(dir string, t Trashed) error {
+	return saveJSON(filepath.Join(dir, trashSubdir, t.Kind), t.ID, t)
+}
+
+// DeleteTrashed removes a trashed record for good; a missing file is not
+// an error
+func DeleteTrashed(dir, kind, id string) error {
+	return deleteJSON(filepath.Join(dir, trashSubdir, kind), id)
+}
+
+// LoadTrash reads every trashed record in dir/trash
+func LoadTrash(dir string) ([]Trashed, error) {
+	kinds, err := filepath.Glob(filepath.Join(dir, trashSubdir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	list := []Trashed{}
+	for _, k := range kinds {
+		items, err := loadJSON[Trashed](k)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, items...)
+	}
+	return list, nil
+}
@@ -0,0 +1,207 @@
+// Package toll prices highway tolls on route legs from a directory of toll
+// plazas, so plans can weigh a tolled expressway against a longer free road.
+package toll
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"milesconnect-optimization/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Classes are the vehicle classes plaza rates are given for, as in the
+// legal GVW classes: light commercial, then trucks by axle count with
+// 4-axle covering multi-axle vehicles
+var Classes = []string{"lcv", "2-axle", "3-axle", "4-axle"}
+
+// DefaultClass prices vehicles that do not declare a class
+const DefaultClass = "2-axle"
+
+// DefaultCostPerKm is the running cost (fuel, tyres, wear) in INR that
+// converts tolls into distance when they are part of the objective
+const DefaultCostPerKm = 30
+
+// CorridorKm is how far a plaza may lie from the straight line of a leg and
+// still count as crossed. Roads wander, so this trades missed plazas for
+// plazas on parallel highways.
+const CorridorKm = 5
+
+// Plaza is a toll plaza and its single-journey rates in INR by class
+type Plaza struct {
+	ID      string             `json:"id"`
+	Name    string             `json:"name"`
+	Highway string             `json:"highway,omitempty"`
+	Lat     float64            `json:"lat"`
+	Lng     float64            `json:"lng"`
+	Rates   map[string]float64 `json:"rates"`
+}
+
+// Directory holds the known plazas. The zero value is empty; use Load to
+// fill it.
+type Directory struct {
+	mu     sync.RWMutex
+	plazas map[string]Plaza
+}
+
+// NewDirectory builds a directory from CSV data (see Load)
+func NewDirectory(r io.Reader) (*Directory, error) {
+	d := &Directory{}
+	if err := d.Load(r); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Load adds plazas from CSV with id, name, lat and lng columns, an optional
+// highway column and a rate column per class. Later plazas override
+// earlier ones with the same ID.
+func (d *Directory) Load(r io.Reader) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	idCol, ok1 := col["id"]
+	latCol, ok2 := col["lat"]
+	lngCol, ok3 := col["lng"]
+	if !ok1 || !ok2 || !ok3 {
+		return errors.New("toll plaza CSV header must contain id, lat and lng")
+	}
+
+	var plazas []Plaza
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		p := Plaza{ID: strings.TrimSpace(rec[idCol]), Rates: map[string]float64{}}
+		lat, err1 := strconv.ParseFloat(rec[latCol], 64)
+		lng, err2 := strconv.ParseFloat(rec[lngCol], 64)
+		if p.ID == "" || err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return fmt.Errorf("line %d: invalid toll plaza", line)
+		}
+		p.Lat, p.Lng = lat, lng
+		if c, ok := col["name"]; ok {
+			p.Name = rec[c]
+		}
+		if c, ok := col["highway"]; ok {
+			p.Highway = rec[c]
+		}
+		for _, class := range Classes {
+			c, ok := col[class]
+			if !ok || rec[c] == "" {
+				continue
+			}
+			rate, err := strconv.ParseFloat(rec[c], 64)
+			if err != nil || rate < 0 || math.IsInf(rate, 0) {
+				return fmt.Errorf("line %d: invalid %s rate", line, class)
+			}
+			p.Rates[class] = rate
+		}
+		plazas = append(plazas, p)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.plazas == nil {
+		d.plazas = map[string]Plaza{}
+	}
+	for _, p := range plazas {
+		d.plazas[p.ID] = p
+	}
+	return nil
+}
+
+// Len returns the number of known plazas
+func (d *Directory) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.plazas)
+}
+
+// Plazas returns every known plaza ordered by ID
+func (d *Directory) Plazas() []Plaza {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	list := make([]Plaza, 0, len(d.plazas))
+	for _, p := range d.plazas {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b Plaza) int { return strings.Compare(a.ID, b.ID) })
+	return list
+}
+
+// Leg returns the toll a vehicle of class pays driving from a to b and the
+// plazas it crosses: those within CorridorKm of the straight line between
+// them, in the order they are passed. An empty class is DefaultClass.
+func (d *Directory) Leg(a, b models.Location, class string) (float64, []string) {
+	if class == "" {
+		class = DefaultClass
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	type crossing struct {
+		id    string
+		along float64
+	}
+	bx, by := project(a, b)
+	length2 := bx*bx + by*by
+	if length2 == 0 {
+		return 0, nil
+	}
+	var crossed []crossing
+	total := 0.0
+	for _, p := range d.plazas {
+		px, py := project(a, models.Location{Lat: p.Lat, Lng: p.Lng})
+		t := (px*bx + py*by) / length2
+		if t < 0 || t > 1 || math.Hypot(px-t*bx, py-t*by) > CorridorKm {
+			continue
+		}
+		crossed = append(crossed, crossing{p.ID, t})
+		total += p.Rates[class]
+	}
+	slices.SortFunc(crossed, func(x, y crossing) int { return cmp.Compare(x.along, y.along) })
+	ids := make([]string, len(crossed))
+	for i, c := range crossed {
+		ids[i] = c.id
+	}
+	return total, ids
+}
+
+// Matrix returns the toll for class between every pair of locs
+func (d *Directory) Matrix(locs []models.Location, class string) [][]float64 {
+	m := make([][]float64, len(locs))
+	for i := range locs {
+		m[i] = make([]float64, len(locs))
+		for j := range locs {
+			if i != j {
+				m[i][j], _ = d.Leg(locs[i], locs[j], class)
+			}
+		}
+	}
+	return m
+}
+
+// project places p on a flat km grid centred on origin, which is close
+// enough over the length of a leg
+func project(origin, p models.Location) (x, y float64) {
+	const kmPerDegree = 111.32
+	x = (p.Lng - origin.Lng) * kmPerDegree * math.Cos(origin.Lat*math.Pi/180)
+	y = (p.Lat - origin.Lat) * kmPerDegree
+	return x, y
+}
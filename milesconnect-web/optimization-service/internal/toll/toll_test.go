@@ -0,0 +1,79 @@
+package toll
+
+import (
+	"milesconnect-optimization/internal/models"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sample = `id,name,highway,lat,lng,lcv,2-axle,3-axle,4-axle
+NH44-ATTIBELE,Attibele,NH44,12.7700,77.7700,110,230,250,360
+NH44-KRISHNAGIRI,Krishnagiri,NH44,12.5100,78.2200,125,260,285,410
+NH48-NELAMANGALA,Nelamangala,NH48,13.1000,77.3900,120,250,275,395
+`
+
+var (
+	bengaluru  = models.Location{Lat: 12.9716, Lng: 77.5946}
+	electronic = models.Location{Lat: 12.84, Lng: 77.66} // Electronic City
+	dharmapuri = models.Location{Lat: 12.30, Lng: 78.60}
+	vellore    = models.Location{Lat: 12.9165, Lng: 79.1325}
+)
+
+func newSample(t *testing.T) *Directory {
+	t.Helper()
+	d, err := NewDirectory(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLegCrossesPlazasOnTheWay(t *testing.T) {
+	d := newSample(t)
+	cost, plazas := d.Leg(electronic, dharmapuri, "3-axle")
+	if cost != 535 || !slices.Equal(plazas, []string{"NH44-ATTIBELE", "NH44-KRISHNAGIRI"}) {
+		t.Errorf("Leg = %v %v, want Attibele then Krishnagiri at 3-axle rates", cost, plazas)
+	}
+
+	// Heading the other way passes them in reverse; an unset class pays the
+	// 2-axle rate
+	if cost, plazas := d.Leg(dharmapuri, electronic, ""); cost != 490 || plazas[0] != "NH44-KRISHNAGIRI" {
+		t.Errorf("return Leg = %v %v", cost, plazas)
+	}
+
+	// Nelamangala is behind Bengaluru on the way to Vellore
+	if cost, plazas := d.Leg(bengaluru, vellore, "lcv"); cost != 0 || len(plazas) != 0 {
+		t.Errorf("Leg to Vellore = %v %v, want no plazas in the corridor", cost, plazas)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	m := newSample(t).Matrix([]models.Location{electronic, dharmapuri}, "lcv")
+	if m[0][0] != 0 || m[0][1] != 235 || m[1][0] != 235 {
+		t.Errorf("Matrix = %v", m)
+	}
+}
+
+func TestLoadRejectsBadRows(t *testing.T) {
+	for name, csv := range map[string]string{
+		"missing columns": "id,name\nA,Plaza A\n",
+		"bad coordinates": "id,lat,lng\nA,north,77\n",
+		"negative rate":   "id,lat,lng,lcv\nA,12,77,-5\n",
+	} {
+		if _, err := NewDirectory(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadOverridesByID(t *testing.T) {
+	d := newSample(t)
+	if err := d.Load(strings.NewReader("id,lat,lng,2-axle\nNH44-ATTIBELE,12.77,77.77,300\n")); err != nil {
+		t.Fatal(err)
+	}
+	plazas := d.Plazas()
+	if len(plazas) != 3 || plazas[0].ID != "NH44-ATTIBELE" || plazas[0].Rates["2-axle"] != 300 {
+		t.Errorf("Plazas = %+v", plazas)
+	}
+}
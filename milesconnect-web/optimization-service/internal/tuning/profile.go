@@ -0,0 +1,64 @@
+package tuning
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Profile is a named, persisted parameter set for one solver
+type Profile struct {
+	Name      string             `json:"name"`
+	Solver    string             `json:"solver"`
+	Params    map[string]float64 `json:"params"`
+	GapPct    float64            `json:"gap_pct"`
+	Trials    int                `json:"trials"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// SaveProfile writes p to dir/<name>.json
+func SaveProfile(dir string, p Profile) error {
+	if !validName.MatchString(p.Name) {
+		return errors.New("profile name may only contain letters, digits, - and _")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, p.Name+".json"), body, 0o644)
+}
+
+// LoadProfiles reads every *.json profile in dir, sorted by name. A missing
+// directory simply has no profiles.
+func LoadProfiles(dir string) ([]Profile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []Profile{}
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var p Profile
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+		}
+		profiles = append(profiles, p)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
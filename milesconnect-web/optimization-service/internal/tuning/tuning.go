@@ -0,0 +1,148 @@
+// Package tuning searches solver hyperparameters against a benchmark suite
+// and persists the winners as named profiles.
+package tuning
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/generator"
+	"milesconnect-optimization/internal/problem"
+	"milesconnect-optimization/internal/solver"
+	"sort"
+	"time"
+)
+
+// Range is the search interval for one parameter
+type Range struct {
+	Min, Max float64
+	Integer  bool
+}
+
+// Space maps parameter names (as read from Problem.SolverParams) to ranges
+type Space map[string]Range
+
+// GeneticSpace covers the GA parameters
+var GeneticSpace = Space{
+	"population_size": {Min: 20, Max: 300, Integer: true},
+	"generations":     {Min: 100, Max: 2000, Integer: true},
+	"mutation_rate":   {Min: 0.01, Max: 0.4},
+	"tournament_size": {Min: 2, Max: 10, Integer: true},
+}
+
+// Benchmark is one instance with the distance scores are measured against
+type Benchmark struct {
+	Name        string
+	Problem     *problem.Problem
+	ReferenceKm float64
+}
+
+// DefaultSuite is the canonical fixtures plus generated mid-sized instances.
+// Generated instances use 2-opt as their reference since no optimum is known.
+func DefaultSuite() []Benchmark {
+	var suite []Benchmark
+	for _, inst := range fixtures.RouteInstances() {
+		suite = append(suite, Benchmark{
+			Name:        inst.Name,
+			Problem:     problem.FromRouteRequest(inst.Request),
+			ReferenceKm: inst.OptimumKm,
+		})
+	}
+
+	for _, cfg := range []generator.Config{
+		{Size: 40, Distribution: generator.Clustered, Seed: 1},
+		{Size: 60, Distribution: generator.Random, Seed: 2},
+	} {
+		req, _ := generator.RouteRequest(cfg)
+		p := problem.FromRouteRequest(req)
+		suite = append(suite, Benchmark{
+			Name:        fmt.Sprintf("%s-%d", cfg.Distribution, cfg.Size),
+			Problem:     p,
+			ReferenceKm: solver.TwoOpt(p, solver.NearestNeighbor(p)).DistanceKm,
+		})
+	}
+	return suite
+}
+
+// Config controls a random search
+type Config struct {
+	Solver string
+	Space  Space
+	Suite  []Benchmark
+	Trials int
+	Seed   int64
+
+	// TimeWeight trades quality for speed: percentage points of gap one
+	// second of mean solve time is worth
+	TimeWeight float64
+}
+
+// Trial is one evaluated parameter set
+type Trial struct {
+	Params     map[string]float64 `json:"params"`
+	GapPct     float64            `json:"gap_pct"` // Mean gap to the suite references
+	MeanMillis float64            `json:"mean_millis"`
+	Score      float64            `json:"score"` // Lower is better
+}
+
+// RandomSearch samples Trials parameter sets and returns them best first
+func RandomSearch(ctx context.Context, cfg Config) ([]Trial, error) {
+	s, ok := solver.Get(cfg.Solver)
+	if !ok {
+		return nil, fmt.Errorf("unknown solver %q", cfg.Solver)
+	}
+	if len(cfg.Suite) == 0 {
+		return nil, fmt.Errorf("empty benchmark suite")
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	trials := make([]Trial, 0, cfg.Trials)
+	for i := 0; i < cfg.Trials; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		t, err := evaluate(ctx, s, cfg, sample(rng, cfg.Space))
+		if err != nil {
+			return nil, err
+		}
+		trials = append(trials, t)
+	}
+
+	sort.Slice(trials, func(i, j int) bool { return trials[i].Score < trials[j].Score })
+	return trials, nil
+}
+
+func sample(rng *rand.Rand, space Space) map[string]float64 {
+	params := make(map[string]float64, len(space))
+	for name, r := range space {
+		v := r.Min + rng.Float64()*(r.Max-r.Min)
+		if r.Integer {
+			v = math.Round(v)
+		}
+		params[name] = v
+	}
+	return params
+}
+
+func evaluate(ctx context.Context, s solver.Solver, cfg Config, params map[string]float64) (Trial, error) {
+	var gap, elapsed float64
+	for _, b := range cfg.Suite {
+		p := *b.Problem
+		p.SolverParams = params
+
+		start := time.Now()
+		sol, err := s.Solve(ctx, &p)
+		if err != nil {
+			return Trial{}, fmt.Errorf("%s: %w", b.Name, err)
+		}
+		elapsed += time.Since(start).Seconds()
+		gap += (sol.DistanceKm - b.ReferenceKm) / b.ReferenceKm * 100
+	}
+
+	n := float64(len(cfg.Suite))
+	t := Trial{Params: params, GapPct: gap / n, MeanMillis: elapsed / n * 1000}
+	t.Score = t.GapPct + cfg.TimeWeight*elapsed/n
+	return t, nil
+}
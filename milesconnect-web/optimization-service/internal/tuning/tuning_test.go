@@ -0,0 +1,66 @@
+package tuning
+
+import (
+	"context"
+	"milesconnect-optimization/internal/fixtures"
+	"milesconnect-optimization/internal/problem"
+	_ "milesconnect-optimization/internal/solver/genetic"
+	"testing"
+)
+
+func smallSuite() []Benchmark {
+	var suite []Benchmark
+	for _, inst := range fixtures.RouteInstances() {
+		if inst.Name == "burma14" {
+			continue
+		}
+		suite = append(suite, Benchmark{Name: inst.Name, Problem: problem.FromRouteRequest(inst.Request), ReferenceKm: inst.OptimumKm})
+	}
+	return suite
+}
+
+func TestRandomSearchSortsBestFirst(t *testing.T) {
+	space := Space{
+		"population_size": {Min: 10, Max: 20, Integer: true},
+		"generations":     {Min: 10, Max: 50, Integer: true},
+	}
+	trials, err := RandomSearch(context.Background(), Config{Solver: "genetic", Space: space, Suite: smallSuite(), Trials: 4, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trials) != 4 {
+		t.Fatalf("got %d trials, want 4", len(trials))
+	}
+	for i := 1; i < len(trials); i++ {
+		if trials[i].Score < trials[i-1].Score {
+			t.Fatalf("trials not sorted by score: %v", trials)
+		}
+	}
+	for _, tr := range trials {
+		if g := tr.Params["generations"]; g < 10 || g > 50 || g != float64(int(g)) {
+			t.Errorf("generations %v outside the integer range [10, 50]", g)
+		}
+	}
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	want := Profile{Name: "fast", Solver: "genetic", Params: map[string]float64{"generations": 100}}
+	if err := SaveProfile(dir, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "fast" || got[0].Params["generations"] != 100 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestSaveProfileRejectsPathNames(t *testing.T) {
+	if err := SaveProfile(t.TempDir(), Profile{Name: "../escape"}); err == nil {
+		t.Error("expected an error for a name containing a path")
+	}
+}
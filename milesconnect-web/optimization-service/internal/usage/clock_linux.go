@@ -0,0 +1,19 @@
+package usage
+
+import (
+	"syscall"
+	"time"
+)
+
+// rusageThread is Linux's RUSAGE_THREAD, which package syscall does not name
+const rusageThread = 1
+
+// ThreadCPU returns the user and system CPU time the calling OS thread has
+// used. Callers lock their goroutine to its thread around what they time.
+func ThreadCPU() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package usage
+
+import "time"
+
+var epoch = time.Now()
+
+// ThreadCPU stands in for the calling thread's CPU time where the platform
+// cannot report it per thread: the wall time since the process started, so
+// solves are metered by how long they ran
+func ThreadCPU() time.Duration {
+	return time.Since(epoch)
+}
@@ -0,0 +1,159 @@
+// Package usage meters what each tenant's solves cost the service: requests
+// and solver seconds per calendar month (UTC), so hosted plans can be billed
+// and capped by quota.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Solve is one metered optimization request. SolverSeconds is the CPU time
+// its solve took, not counting time spent waiting for a slot or the network.
+type Solve struct {
+	Tenant        string    `json:"tenant"`
+	At            time.Time `json:"at"`
+	Endpoint      string    `json:"endpoint"`
+	SolverSeconds float64   `json:"solver_seconds"`
+}
+
+// Totals are a tenant's requests and solver seconds in one month
+type Totals struct {
+	Tenant        string  `json:"tenant"`
+	Month         string  `json:"month"` // YYYY-MM
+	Requests      int     `json:"requests"`
+	SolverSeconds float64 `json:"solver_seconds"`
+}
+
+// Month names the calendar month t falls in, in UTC
+func Month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// NextMonth returns when the month after t's starts, in UTC
+func NextMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Meter sums solves by month and tenant, optionally backed by a JSON Lines
+// file of every solve. It is safe for concurrent use.
+type Meter struct {
+	mu     sync.RWMutex
+	months map[string]map[string]*Totals // By month, then tenant
+	file   *os.File
+}
+
+// Open reads the meter's log at path and appends new solves to it, dropping
+// a last line a crash cut short. An empty path keeps the totals in memory
+// only.
+func Open(path string) (*Meter, error) {
+	m := &Meter{months: map[string]map[string]*Totals{}}
+	if path == "" {
+		return m, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	rd := bufio.NewReader(f)
+	var kept int64
+	for line := 1; ; line++ {
+		b, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			// A crash mid-write leaves the last solve without its newline;
+			// drop it so the next one starts a line of its own
+			if len(b) > 0 {
+				if err := f.Truncate(kept); err != nil {
+					f.Close()
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var s Solve
+		if err := json.Unmarshal(b, &s); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("usage: line %d: %w", line, err)
+		}
+		m.add(s)
+		kept += int64(len(b))
+	}
+	m.file = f
+	return m, nil
+}
+
+// Record meters a solve
+func (m *Meter) Record(s Solve) error {
+	s.At = s.At.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.file != nil {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if _, err := m.file.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	m.add(s)
+	return nil
+}
+
+// Used returns a tenant's totals for month; zero when it solved nothing
+func (m *Meter) Used(tenant, month string) Totals {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if t, ok := m.months[month][tenant]; ok {
+		return *t
+	}
+	return Totals{Tenant: tenant, Month: month}
+}
+
+// Report returns every tenant's totals for month, sorted by tenant
+func (m *Meter) Report(month string) []Totals {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := []Totals{}
+	for _, tenant := range slices.Sorted(maps.Keys(m.months[month])) {
+		list = append(list, *m.months[month][tenant])
+	}
+	return list
+}
+
+// Close closes the backing file
+func (m *Meter) Close() error {
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
+
+func (m *Meter) add(s Solve) {
+	month := Month(s.At)
+	tenants, ok := m.months[month]
+	if !ok {
+		tenants = map[string]*Totals{}
+		m.months[month] = tenants
+	}
+	t, ok := tenants[s.Tenant]
+	if !ok {
+		t = &Totals{Tenant: s.Tenant, Month: month}
+		tenants[s.Tenant] = t
+	}
+	t.Requests++
+	t.SolverSeconds += s.SolverSeconds
+}
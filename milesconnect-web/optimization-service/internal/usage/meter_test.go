@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMeterSumsByMonthAndReplays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oct := time.Date(2026, 10, 31, 23, 0, 0, 0, time.UTC)
+	for _, s := range []Solve{
+		{Tenant: "acme", At: oct, Endpoint: "/optimize-fleet", SolverSeconds: 2.5},
+		{Tenant: "acme", At: oct.Add(30 * time.Minute), Endpoint: "/optimize", SolverSeconds: 0.5},
+		{Tenant: "acme", At: oct.Add(2 * time.Hour), Endpoint: "/optimize", SolverSeconds: 1}, // November
+		{Tenant: "globex", At: oct, Endpoint: "/optimize-load", SolverSeconds: 0.25},
+	} {
+		if err := m.Record(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Close()
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if got := m.Used("acme", "2026-10"); got.Requests != 2 || got.SolverSeconds != 3 {
+		t.Errorf("acme in October: %+v", got)
+	}
+	if got := m.Used("acme", "2026-11"); got.Requests != 1 {
+		t.Errorf("acme in November: %+v", got)
+	}
+	if got := m.Used("initech", "2026-10"); got.Requests != 0 || got.Tenant != "initech" {
+		t.Errorf("idle tenant: %+v", got)
+	}
+	if r := m.Report("2026-10"); len(r) != 2 || r[0].Tenant != "acme" || r[1].Tenant != "globex" {
+		t.Errorf("report: %+v", r)
+	}
+	if got := NextMonth(oct.Add(2 * time.Hour)); !got.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("next month = %v", got)
+	}
+}
+
+func TestOpenDropsATornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	whole := `{"tenant":"acme","at":"2026-10-15T09:00:00Z","endpoint":"/optimize","solver_seconds":1}` + "\n"
+	if err := os.WriteFile(path, []byte(whole+`{"tenant":"acme","at":"2026-1`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Record(Solve{Tenant: "acme", At: at, Endpoint: "/optimize", SolverSeconds: 2}); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if got := m.Used("acme", "2026-10"); got.Requests != 2 || got.SolverSeconds != 3 {
+		t.Errorf("after a torn line: %+v", got)
+	}
+	if err := os.WriteFile(path, []byte("not json\n"+whole), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("a corrupt line before the last opened")
+	}
+}
@@ -0,0 +1,57 @@
+const map = L.map('map').setView([22.5, 79], 5);
+L.tileLayer('https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png', {
+  attribution: '&copy; OpenStreetMap contributors',
+}).addTo(map);
+
+let points = [];
+let markers = L.layerGroup().addTo(map);
+let routeLine = null;
+const result = document.getElementById('result');
+
+map.on('click', (e) => {
+  points.push({ lat: e.latlng.lat, lng: e.latlng.lng });
+  L.marker(e.latlng).addTo(markers).bindTooltip(String(points.length));
+});
+
+document.getElementById('clear').onclick = () => {
+  points = [];
+  markers.clearLayers();
+  if (routeLine) routeLine.remove();
+  result.textContent = 'No result yet.';
+};
+
+document.getElementById('solve').onclick = async () => {
+  const solver = document.getElementById('solver').value;
+  let res;
+  try {
+    if (solver === 'all-india') {
+      res = await fetch('/v1/optimize-india');
+    } else {
+      if (points.length < 2) {
+        result.textContent = 'Add at least two points.';
+        return;
+      }
+      res = await fetch('/v1/optimize?solver=' + solver, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ start: points[0], end: points[0], waypoints: points.slice(1) }),
+      });
+    }
+    if (!res.ok) throw new Error(await res.text());
+    const data = await res.json();
+    draw(data);
+  } catch (err) {
+    result.textContent = 'Error: ' + err.message;
+  }
+};
+
+function draw(data) {
+  if (routeLine) routeLine.remove();
+  const latlngs = data.route.map((p) => [p.lat, p.lng]);
+  routeLine = L.polyline(latlngs, { color: '#2563eb' }).addTo(map);
+  map.fitBounds(routeLine.getBounds(), { padding: [20, 20] });
+  result.textContent = JSON.stringify({
+    stops: data.route.length,
+    total_distance_km: Math.round(data.total_distance_km * 10) / 10,
+  }, null, 2);
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Handler serves the built-in demo UI so the solvers can be exercised
+// without running the separate web app
+func Handler() http.Handler {
+	root, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // Embedded tree is fixed at build time
+	}
+	return http.FileServer(http.FS(root))
+}
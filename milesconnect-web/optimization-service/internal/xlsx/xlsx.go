@@ -0,0 +1,220 @@
+// Package xlsx writes Office Open XML spreadsheets (.xlsx) that Excel,
+// LibreOffice and Google Sheets open: plain cells, a bold header row kept in
+// view while scrolling, and no formulas. Text goes in as inline strings, so
+// a value starting with = is shown rather than evaluated.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of an XLSX workbook
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Workbook is a set of sheets, shown in order
+type Workbook struct {
+	Sheets []Sheet
+}
+
+// Sheet is a header row and rows of cells under it. A cell is a string, a
+// number (any int or float type), a bool or nil for an empty cell; anything
+// else is written as fmt.Sprint gives it.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// maxName is the longest sheet name Excel accepts
+const maxName = 31
+
+// Write encodes b as an XLSX file. Sheet names are cut to Excel's 31
+// characters, stripped of the characters it forbids and numbered where
+// that makes two alike.
+func (b Workbook) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+	names := sheetNames(b.Sheets)
+	part := func(name, body string) error {
+		f, err := z.Create(name)
+		if err == nil {
+			_, err = io.WriteString(f, xml.Header+body)
+		}
+		return err
+	}
+
+	var types, sheets, rels strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(names)+1)
+
+	err := part("[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`+
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`+
+		`<Default Extension="xml" ContentType="application/xml"/>`+
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`+
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`+
+		types.String()+`</Types>`)
+	if err == nil {
+		err = part("_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>`+
+			`</Relationships>`)
+	}
+	if err == nil {
+		err = part("xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`+
+			`<sheets>`+sheets.String()+`</sheets></workbook>`)
+	}
+	if err == nil {
+		err = part("xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+rels.String()+`</Relationships>`)
+	}
+	if err == nil {
+		err = part("xl/styles.xml", styles)
+	}
+	for i, s := range b.Sheets {
+		if err != nil {
+			break
+		}
+		var f io.Writer
+		if f, err = z.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)); err == nil {
+			err = s.write(f)
+		}
+	}
+	if err != nil {
+		z.Close()
+		return err
+	}
+	return z.Close()
+}
+
+// styles has two cell formats: 0 plain, 1 bold for headers
+const styles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// write encodes the sheet's worksheet part
+func (s Sheet) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.Header) > 0 {
+		bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	bw.WriteString(`<sheetData>`)
+	n := 0
+	row := func(cells []any, style int) {
+		n++
+		fmt.Fprintf(bw, `<row r="%d">`, n)
+		for i, v := range cells {
+			writeCell(bw, column(i)+strconv.Itoa(n), v, style)
+		}
+		bw.WriteString(`</row>`)
+	}
+	if len(s.Header) > 0 {
+		header := make([]any, len(s.Header))
+		for i, h := range s.Header {
+			header[i] = h
+		}
+		row(header, 1)
+	}
+	for _, cells := range s.Rows {
+		row(cells, 0)
+	}
+	bw.WriteString(`</sheetData></worksheet>`)
+	return bw.Flush()
+}
+
+func writeCell(w *bufio.Writer, ref string, v any, style int) {
+	attrs := `r="` + ref + `"`
+	if style != 0 {
+		attrs += ` s="` + strconv.Itoa(style) + `"`
+	}
+	var num string
+	switch v := v.(type) {
+	case nil:
+		return
+	case string:
+		fmt.Fprintf(w, `<c %s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, attrs, escape(v))
+		return
+	case bool:
+		num = "0"
+		if v {
+			num = "1"
+		}
+		fmt.Fprintf(w, `<c %s t="b"><v>%s</v></c>`, attrs, num)
+		return
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		num = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		num = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		num = fmt.Sprint(v)
+	default:
+		fmt.Fprintf(w, `<c %s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, attrs, escape(fmt.Sprint(v)))
+		return
+	}
+	fmt.Fprintf(w, `<c %s><v>%s</v></c>`, attrs, num)
+}
+
+// column returns the letters of the i-th column from 0: A, B, ..., Z, AA
+func column(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// sheetNames makes the sheets' names ones Excel accepts, and unique
+func sheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	seen := map[string]bool{}
+	for i, s := range sheets {
+		base := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '_'
+			}
+			return r
+		}, strings.Trim(s.Name, "'"))
+		if base == "" {
+			base = "Sheet" + strconv.Itoa(i+1)
+		}
+		name := truncate(base, maxName)
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = strings.TrimRight(truncate(base, maxName-len(suffix)), " ") + suffix
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// escape makes s safe as XML text or an attribute value
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
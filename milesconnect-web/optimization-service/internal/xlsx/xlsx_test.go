@@ -0,0 +1,80 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriteWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	err := Workbook{Sheets: []Sheet{
+		{Name: "Summary", Header: []string{"Vehicle", "Load"}, Rows: [][]any{{"V1 <&>", 1250.5}, {"=SUM(A1)", 3, nil, true}, {math.NaN()}}},
+		{Name: "MH12/AB:1234 [reefer] long name here"},
+		{Name: "mh12_ab_1234 _reefer_ long name here"},
+		{Name: ""},
+	}}.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range z.File {
+		r, _ := f.Open()
+		b, _ := io.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(b)
+		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+			if err := xml.Unmarshal(b, new(struct{})); err != nil {
+				t.Errorf("%s is not XML: %v", f.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet4.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("no %s", name)
+		}
+	}
+
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xml.Unmarshal([]byte(parts["xl/workbook.xml"]), &wb)
+	var names []string
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, "|"); got != "Summary|MH12_AB_1234 _reefer_ long name|mh12_ab_1234 _reefer_ long (2)|Sheet4" {
+		t.Errorf("sheet names = %s", got)
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Vehicle</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">V1 &lt;&amp;&gt;</t></is></c><c r="B2"><v>1250.5</v></c>`,
+		`<c r="A3" t="inlineStr"><is><t xml:space="preserve">=SUM(A1)</t></is></c><c r="B3"><v>3</v></c><c r="D3" t="b"><v>1</v></c>`,
+		`<row r="4"></row>`,
+		`state="frozen"`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) = %s, want %s", i, got, want)
+		}
+	}
+}